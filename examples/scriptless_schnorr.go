@@ -0,0 +1,92 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package main
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/keyfuse/tokucore/xcrypto"
+)
+
+func assertNil(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Demo for scriptless BIP340 Schnorr adaptor signature.
+func main() {
+	hash := xcrypto.DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+
+	// Adaptor.
+	secret := new(big.Int).SetInt64(2019)
+	adaptor := xcrypto.PrvKeyFromBytes(secret.Bytes()).PubKey()
+
+	// Party 1.
+	p1, _ := new(big.Int).SetString("15bafcb56279dbfd985d4d17cdaf9bbfc6701b628f9fb00d6d1e0d2cb503ede3", 16)
+	prv1 := xcrypto.PrvKeyFromBytes(p1.Bytes())
+	alice := xcrypto.NewSchnorrAdaptorParty(prv1)
+	defer alice.Close()
+
+	// Party 2.
+	p2, _ := new(big.Int).SetString("76818c328b8aa1e8f17bd599016fef8134b7d5ec315e0b6373953da7e8b5c0c9", 16)
+	prv2 := xcrypto.PrvKeyFromBytes(p2.Bytes())
+	bob := xcrypto.NewSchnorrAdaptorParty(prv2)
+	defer bob.Close()
+
+	// Phase 1.
+	sharepub, err := alice.Phase1(prv2.PubKey())
+	assertNil(err)
+	_, err = bob.Phase1(prv1.PubKey())
+	assertNil(err)
+
+	// Phase 2.
+	commit1, err := alice.Phase2(hash)
+	assertNil(err)
+	commit2, err := bob.Phase2(hash)
+	assertNil(err)
+
+	// Phase 3.
+	R1, err := alice.Phase3(commit2)
+	assertNil(err)
+	R2, err := bob.Phase3(commit1)
+	assertNil(err)
+
+	// Phase 4.
+	s1, err := alice.Phase4(R2, adaptor)
+	assertNil(err)
+	s2, err := bob.Phase4(R1, adaptor)
+	assertNil(err)
+
+	// Phase 5.
+	presig, err := alice.Phase5(s2)
+	assertNil(err)
+	_, err = bob.Phase5(s1)
+	assertNil(err)
+
+	// Adapt.
+	err = xcrypto.SchnorrAdaptorVerify(sharepub, hash, adaptor, presig)
+	assertNil(err)
+	sig, err := xcrypto.SchnorrAdaptorAdapt(presig, secret)
+	assertNil(err)
+
+	// Verify.
+	err = xcrypto.SchnorrBIP340Verify(sharepub, hash, sig)
+
+	// Extract.
+	extracted, err1 := xcrypto.SchnorrAdaptorExtract(presig, sig)
+	assertNil(err1)
+
+	fmt.Printf("\nAdaptor secret: %x\nKeys\n  x1: %x\n  x2: %x\n  Q:  %x\n\nSignatures\n  %x\nIs valid under Q?: %v\nRecovered secret: %x\n",
+		secret.Bytes(),
+		p1.Bytes(),
+		p2.Bytes(),
+		sharepub.SerializeXOnly(),
+		sig,
+		err == nil,
+		extracted.Bytes())
+}
@@ -0,0 +1,147 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package main
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xcore"
+	"github.com/keyfuse/tokucore/xcore/bip32"
+	"github.com/keyfuse/tokucore/xcrypto"
+)
+
+func assertNil(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Demo for spending from a 2-of-3 threshold ECDSA P2PKH address, signed by parties 1 and 3.
+func main() {
+	// Bohu.
+	seed := []byte("this.is.bohu.seed.")
+	bohuHDKey := bip32.NewHDKey(seed)
+	bohuPub := bohuHDKey.PublicKey()
+	bohu := xcore.NewPayToPubKeyHashAddress(bohuPub.Hash160())
+
+	// KeyGen.
+	ids := []uint32{1, 2, 3}
+	parties := make([]*xcrypto.EcdsaThresholdParty, len(ids))
+	var msgs1 []*xcrypto.EcdsaThresholdKeyGenMsg1
+	for i, id := range ids {
+		party, err := xcrypto.NewEcdsaThresholdParty(id, ids, 2)
+		assertNil(err)
+		defer party.Close()
+		parties[i] = party
+
+		msg, err := party.KeyGenPhase1()
+		assertNil(err)
+		msgs1 = append(msgs1, msg)
+	}
+	var msgs2 []*xcrypto.EcdsaThresholdKeyGenMsg2
+	shares := make(map[uint32]map[uint32]*big.Int)
+	for _, party := range parties {
+		msg, out, err := party.KeyGenPhase2(msgs1)
+		assertNil(err)
+		msgs2 = append(msgs2, msg)
+		for to, share := range out {
+			if shares[to] == nil {
+				shares[to] = make(map[uint32]*big.Int)
+			}
+			shares[to][party.ID()] = share
+		}
+	}
+	var sharepub *xcrypto.PubKey
+	for _, party := range parties {
+		pub, err := party.KeyGenPhase3(msgs2, shares[party.ID()])
+		assertNil(err)
+		sharepub = pub
+	}
+
+	// Shared address.
+	shared := xcore.NewPayToPubKeyHashAddress(sharepub.Hash160())
+	fmt.Printf("shared.addr:%v\n", shared.ToString(network.TestNet))
+
+	// Presign with parties 1 and 3, before the transaction is known.
+	signers := []*xcrypto.EcdsaThresholdParty{parties[0], parties[2]}
+	signerIDs := []uint32{1, 3}
+	var pmsgs1 []*xcrypto.EcdsaThresholdPresignMsg1
+	for _, party := range signers {
+		msg, err := party.PresignPhase1(signerIDs)
+		assertNil(err)
+		pmsgs1 = append(pmsgs1, msg)
+	}
+	pmsgs2 := make(map[uint32][]*xcrypto.EcdsaThresholdPresignMsg2)
+	for _, party := range signers {
+		out, err := party.PresignPhase2(pmsgs1)
+		assertNil(err)
+		for to, msg := range out {
+			pmsgs2[to] = append(pmsgs2[to], msg)
+		}
+	}
+	var pmsgs3 []*xcrypto.EcdsaThresholdPresignMsg3
+	for _, party := range signers {
+		msg, err := party.PresignPhase3(pmsgs2[party.ID()])
+		assertNil(err)
+		pmsgs3 = append(pmsgs3, msg)
+	}
+	var pmsgs4 []*xcrypto.EcdsaThresholdPresignMsg4
+	for _, party := range signers {
+		msg, err := party.PresignPhase4(pmsgs3)
+		assertNil(err)
+		pmsgs4 = append(pmsgs4, msg)
+	}
+	var presigs []*xcrypto.EcdsaThresholdPresignature
+	for _, party := range signers {
+		presig, err := party.PresignPhase5(pmsgs4)
+		assertNil(err)
+		presigs = append(presigs, presig)
+	}
+
+	// Spending.
+	{
+		shareCoin := xcore.NewCoinBuilder().AddOutput(
+			"baf5ac09c6047e0f5e082afe37d4a368e40a40a4025ea60057877ea8998f0c60",
+			0,
+			4000,
+			fmt.Sprintf("76a914%x88ac", sharepub.Hash160()),
+		).ToCoins()[0]
+
+		tx, err := xcore.NewTransactionBuilder().
+			AddCoin(shareCoin).
+			To(bohu, 3000).
+			Then().
+			BuildTransaction()
+		assertNil(err)
+
+		// SigHash of index 0.
+		idx0sighash := tx.RawSignatureHash(0, xcore.SigHashAll)
+
+		// Online signing, one round.
+		partials := make(map[uint32]*big.Int)
+		for i, presig := range presigs {
+			s, err := presig.Sign(idx0sighash)
+			assertNil(err)
+			partials[signerIDs[i]] = s
+		}
+		sharesig, err := presigs[0].Combine(idx0sighash, partials)
+		assertNil(err)
+
+		// EmbedIdxSignature.
+		err = tx.EmbedIdxEcdsaSignature(0, sharepub, sharesig, xcore.SigHashAll)
+		assertNil(err)
+
+		// Verify.
+		err = tx.Verify()
+		assertNil(err)
+
+		fmt.Printf("threshold-ecdsa.p2pkh.spend:%v\n", tx.ToString())
+		fmt.Printf("threshold-ecdsa.p2pkh.spend.txid:%v\n", tx.ID())
+		fmt.Printf("threshold-ecdsa.p2pkh.spend.tx:%x\n", tx.Serialize())
+	}
+}
@@ -21,6 +21,16 @@ type Network struct {
 	HDPrivateKeyID []byte
 	HDPublicKeyID  []byte
 
+	// SLIP-132 extended key magics of the segwit script types.
+	HDSegwitP2SHPrivateKeyID         []byte // P2WPKH-in-P2SH
+	HDSegwitP2SHPublicKeyID          []byte
+	HDSegwitP2SHMultisigPrivateKeyID []byte // P2WSH-in-P2SH
+	HDSegwitP2SHMultisigPublicKeyID  []byte
+	HDSegwitPrivateKeyID             []byte // P2WPKH
+	HDSegwitPublicKeyID              []byte
+	HDSegwitMultisigPrivateKeyID     []byte // P2WSH
+	HDSegwitMultisigPublicKeyID      []byte
+
 	// Protocol.
 	Magic           []byte
 	Port            uint32
@@ -46,6 +56,16 @@ var (
 		HDPrivateKeyID: []byte{0x04, 0x88, 0xad, 0xe4}, // starts with xprv
 		HDPublicKeyID:  []byte{0x04, 0x88, 0xb2, 0x1e}, // starts with xpub
 
+		// SLIP-132 extended key magics
+		HDSegwitP2SHPrivateKeyID:         []byte{0x04, 0x9d, 0x78, 0x78}, // starts with yprv
+		HDSegwitP2SHPublicKeyID:          []byte{0x04, 0x9d, 0x7c, 0xb2}, // starts with ypub
+		HDSegwitP2SHMultisigPrivateKeyID: []byte{0x02, 0x95, 0xb0, 0x05}, // starts with Yprv
+		HDSegwitP2SHMultisigPublicKeyID:  []byte{0x02, 0x95, 0xb4, 0x3f}, // starts with Ypub
+		HDSegwitPrivateKeyID:             []byte{0x04, 0xb2, 0x43, 0x0c}, // starts with zprv
+		HDSegwitPublicKeyID:              []byte{0x04, 0xb2, 0x47, 0x46}, // starts with zpub
+		HDSegwitMultisigPrivateKeyID:     []byte{0x02, 0xaa, 0x7a, 0x99}, // starts with Zprv
+		HDSegwitMultisigPublicKeyID:      []byte{0x02, 0xaa, 0x7e, 0xd3}, // starts with Zpub
+
 		// Protocol.
 		Magic:           []byte{0xf9, 0xbe, 0xb4, 0xd9},
 		Port:            8333,
@@ -70,6 +90,16 @@ var (
 		HDPrivateKeyID: []byte{0x04, 0x35, 0x83, 0x94}, // starts with tprv
 		HDPublicKeyID:  []byte{0x04, 0x35, 0x87, 0xcf}, // starts with tpub
 
+		// SLIP-132 extended key magics
+		HDSegwitP2SHPrivateKeyID:         []byte{0x04, 0x4a, 0x4e, 0x28}, // starts with uprv
+		HDSegwitP2SHPublicKeyID:          []byte{0x04, 0x4a, 0x52, 0x62}, // starts with upub
+		HDSegwitP2SHMultisigPrivateKeyID: []byte{0x02, 0x42, 0x85, 0xb5}, // starts with Uprv
+		HDSegwitP2SHMultisigPublicKeyID:  []byte{0x02, 0x42, 0x89, 0xef}, // starts with Upub
+		HDSegwitPrivateKeyID:             []byte{0x04, 0x5f, 0x18, 0xbc}, // starts with vprv
+		HDSegwitPublicKeyID:              []byte{0x04, 0x5f, 0x1c, 0xf6}, // starts with vpub
+		HDSegwitMultisigPrivateKeyID:     []byte{0x02, 0x57, 0x50, 0x48}, // starts with Vprv
+		HDSegwitMultisigPublicKeyID:      []byte{0x02, 0x57, 0x54, 0x83}, // starts with Vpub
+
 		// Protocol.
 		Magic:           []byte{0x0b, 0x11, 0x09, 0x07},
 		Port:            18333,
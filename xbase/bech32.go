@@ -14,9 +14,28 @@ var (
 	charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
 )
 
+const (
+	// bech32Const -- the checksum constant of bech32, BIP173.
+	bech32Const = 1
+
+	// bech32mConst -- the checksum constant of bech32m, BIP350.
+	bech32mConst = 0x2bc830a3
+)
+
 // Bech32Decode --
 // decodes a bech32 encoded string, returning the human-readable part and the data part excluding the checksum.
 func Bech32Decode(bech string) (string, []byte, error) {
+	return bech32Decode(bech, bech32Const)
+}
+
+// Bech32mDecode --
+// decodes a bech32m encoded string, returning the human-readable part and the data part excluding the checksum.
+// https://github.com/bitcoin/bips/blob/master/bip-0350.mediawiki
+func Bech32mDecode(bech string) (string, []byte, error) {
+	return bech32Decode(bech, bech32mConst)
+}
+
+func bech32Decode(bech string, constant int) (string, []byte, error) {
 	// The maximum allowed length for a bech32 string is 90. It must also
 	// be at least 8 characters, since it needs a non-empty HRP, a
 	// separator, and a 6 character checksum.
@@ -59,11 +78,11 @@ func Bech32Decode(bech string) (string, []byte, error) {
 		return "", nil, fmt.Errorf("failed converting data to bytes: %v", err)
 	}
 
-	if !bech32VerifyChecksum(hrp, decoded) {
+	if !bech32VerifyChecksum(hrp, decoded, constant) {
 		moreInfo := ""
 		checksum := bech[len(bech)-6:]
 		expected, err := toChars(bech32Checksum(hrp,
-			decoded[:len(decoded)-6]))
+			decoded[:len(decoded)-6], constant))
 		if err == nil {
 			moreInfo = fmt.Sprintf("Expected %v, got %v.", expected, checksum)
 		}
@@ -77,8 +96,19 @@ func Bech32Decode(bech string) (string, []byte, error) {
 // encodes a byte slice into a bech32 string with the human-readable part hrb.
 // Note that the bytes must each encode 5 bits (base32).
 func Bech32Encode(hrp string, data []byte) (string, error) {
+	return bech32Encode(hrp, data, bech32Const)
+}
+
+// Bech32mEncode --
+// encodes a byte slice into a bech32m string with the human-readable part hrb.
+// Note that the bytes must each encode 5 bits (base32).
+func Bech32mEncode(hrp string, data []byte) (string, error) {
+	return bech32Encode(hrp, data, bech32mConst)
+}
+
+func bech32Encode(hrp string, data []byte, constant int) (string, error) {
 	// Calculate the checksum of the data and append it at the end.
-	checksum := bech32Checksum(hrp, data)
+	checksum := bech32Checksum(hrp, data, constant)
 	combined := append(data, checksum...)
 
 	// The resulting bech32 string is the concatenation of the hrp, the
@@ -116,8 +146,8 @@ func toChars(data []byte) (string, error) {
 	return string(result), nil
 }
 
-// For more details on the checksum calculation, please refer to BIP 173.
-func bech32Checksum(hrp string, data []byte) []byte {
+// For more details on the checksum calculation, please refer to BIP 173 and BIP 350.
+func bech32Checksum(hrp string, data []byte, constant int) []byte {
 	// Convert the bytes to list of integers, as this is needed for the
 	// checksum calculation.
 	integers := make([]int, len(data))
@@ -126,7 +156,7 @@ func bech32Checksum(hrp string, data []byte) []byte {
 	}
 	values := append(bech32HrpExpand(hrp), integers...)
 	values = append(values, []int{0, 0, 0, 0, 0, 0}...)
-	polymod := bech32Polymod(values) ^ 1
+	polymod := bech32Polymod(values) ^ constant
 	var res []byte
 	for i := 0; i < 6; i++ {
 		res = append(res, byte((polymod>>uint(5*(5-i)))&31))
@@ -164,12 +194,12 @@ func bech32HrpExpand(hrp string) []int {
 	return v
 }
 
-// For more details on the checksum verification, please refer to BIP 173.
-func bech32VerifyChecksum(hrp string, data []byte) bool {
+// For more details on the checksum verification, please refer to BIP 173 and BIP 350.
+func bech32VerifyChecksum(hrp string, data []byte, constant int) bool {
 	integers := make([]int, len(data))
 	for i, b := range data {
 		integers[i] = int(b)
 	}
 	concat := append(bech32HrpExpand(hrp), integers...)
-	return bech32Polymod(concat) == 1
+	return bech32Polymod(concat) == constant
 }
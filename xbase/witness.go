@@ -31,6 +31,35 @@ func WitnessEncode(hrp string, version byte, program []byte) (string, error) {
 	return Bech32Encode(hrp, append([]byte{version}, data...))
 }
 
+// WitnessBech32mDecode -- decodes the bech32m segwit address(version 1+) to hrp, version and pubkeyscript.
+// https://github.com/bitcoin/bips/blob/master/bip-0350.mediawiki
+func WitnessBech32mDecode(addr string) (string, byte, []byte, error) {
+	hrp, data, err := Bech32mDecode(addr)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	if len(data) == 0 || data[0] == 0 {
+		return "", 0, nil, fmt.Errorf("invalid bech32m witness version")
+	}
+	res, err := convertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	return hrp, data[0], res, nil
+}
+
+// WitnessBech32mEncode -- encodes to bech32m segwit address(version 1+).
+func WitnessBech32mEncode(hrp string, version byte, program []byte) (string, error) {
+	if version == 0 {
+		return "", fmt.Errorf("invalid bech32m witness version")
+	}
+	data, err := convertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	return Bech32mEncode(hrp, append([]byte{version}, data...))
+}
+
 func convertBits(data []byte, frombits, tobits uint, pad bool) ([]byte, error) {
 	acc := 0
 	bits := uint(0)
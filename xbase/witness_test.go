@@ -6,6 +6,7 @@
 package xbase
 
 import (
+	"encoding/hex"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -76,3 +77,43 @@ func TestWitnessAddress(t *testing.T) {
 		assert.Equal(t, test.address, addr)
 	}
 }
+
+func TestWitnessBech32mAddress(t *testing.T) {
+	tests := []struct {
+		address        string
+		witnessProgram string
+	}{
+		{
+			"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0",
+			"512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		},
+		{
+			"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y",
+			"5128751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6",
+		},
+		{
+			"bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs",
+			"5210751e76e8199196d454941c45d1b3a323",
+		},
+	}
+
+	for _, test := range tests {
+		hrp, version, program, err := WitnessBech32mDecode(test.address)
+		assert.Nil(t, err)
+		assert.Equal(t, test.witnessProgram, hex.EncodeToString(witnessScriptPubkey(version, program)))
+
+		addr, err := WitnessBech32mEncode(hrp, version, program)
+		assert.Nil(t, err)
+		assert.Equal(t, test.address, addr)
+	}
+
+	// The bech32 checksum is invalid for bech32m, and the other way round.
+	_, _, _, err := WitnessBech32mDecode("bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7k7grplx")
+	assert.NotNil(t, err)
+	_, _, _, err = WitnessDecode("bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0")
+	assert.NotNil(t, err)
+
+	// Version 0 must use bech32.
+	_, err = WitnessBech32mEncode("bc", 0, make([]byte, 20))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,158 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"fmt"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xcore/bip32"
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/keyfuse/tokucore/xerror"
+)
+
+// The BIP43 purposes of the single key accounts.
+const (
+	// PurposeP2PKH -- BIP44, legacy P2PKH addresses.
+	PurposeP2PKH uint32 = 44
+	// PurposeP2WPKHInP2SH -- BIP49, P2SH-P2WPKH addresses.
+	PurposeP2WPKHInP2SH uint32 = 49
+	// PurposeP2WPKH -- BIP84, native P2WPKH addresses.
+	PurposeP2WPKH uint32 = 84
+	// PurposeP2TR -- BIP86, key path only P2TR addresses.
+	PurposeP2TR uint32 = 86
+)
+
+// The BIP44 chains of an account.
+const (
+	// ChainReceive -- the external chain for the receive addresses.
+	ChainReceive uint32 = 0
+	// ChainChange -- the internal chain for the change addresses.
+	ChainChange uint32 = 1
+)
+
+// purposeKeyTypes -- the SLIP-132 key type of the account extended key.
+var purposeKeyTypes = map[uint32]bip32.KeyType{
+	PurposeP2PKH:        bip32.KeyTypeP2PKH,
+	PurposeP2WPKHInP2SH: bip32.KeyTypeP2WPKHInP2SH,
+	PurposeP2WPKH:       bip32.KeyTypeP2WPKH,
+	PurposeP2TR:         bip32.KeyTypeP2PKH,
+}
+
+// Account -- a BIP44/49/84/86 account, m/purpose'/coin_type'/account'.
+type Account struct {
+	purpose uint32
+	key     *bip32.HDKey
+}
+
+// NewAccount -- derives the account key m/purpose'/coinType'/account' from the master key.
+func NewAccount(master *bip32.HDKey, purpose uint32, coinType uint32, account uint32) (*Account, error) {
+	if _, ok := purposeKeyTypes[purpose]; !ok {
+		return nil, xerror.NewError(Errors, ER_ACCOUNT_PURPOSE_UNSUPPORTED, purpose)
+	}
+	key, err := master.DeriveByPath(fmt.Sprintf("m/%d'/%d'/%d'", purpose, coinType, account))
+	if err != nil {
+		return nil, err
+	}
+	return &Account{purpose: purpose, key: key}, nil
+}
+
+// NewAccountFromString -- imports the account extended key, the SLIP-132 version must match the purpose.
+// A public key such as a zpub gives a watch-only account.
+func NewAccountFromString(key string, net *network.Network, purpose uint32) (*Account, error) {
+	want, ok := purposeKeyTypes[purpose]
+	if !ok {
+		return nil, xerror.NewError(Errors, ER_ACCOUNT_PURPOSE_UNSUPPORTED, purpose)
+	}
+	hdkey, keyType, err := bip32.NewHDKeyFromStringWithNetwork(key, net)
+	if err != nil {
+		return nil, err
+	}
+	if keyType != want {
+		return nil, xerror.NewError(Errors, ER_ACCOUNT_KEY_TYPE_MISMATCH, purpose, keyType)
+	}
+	return &Account{purpose: purpose, key: hdkey}, nil
+}
+
+// NewAccountFromHDKey -- creates the account of the purpose from the account extended key,
+// such as the key of an output descriptor which is always serialized as xpub.
+func NewAccountFromHDKey(key *bip32.HDKey, purpose uint32) (*Account, error) {
+	if _, ok := purposeKeyTypes[purpose]; !ok {
+		return nil, xerror.NewError(Errors, ER_ACCOUNT_PURPOSE_UNSUPPORTED, purpose)
+	}
+	return &Account{purpose: purpose, key: key}, nil
+}
+
+// Purpose -- the BIP43 purpose of the account.
+func (a *Account) Purpose() uint32 {
+	return a.purpose
+}
+
+// HDKey -- the account extended key.
+func (a *Account) HDKey() *bip32.HDKey {
+	return a.key
+}
+
+// ToString -- the account extended key with the SLIP-132 version of the purpose.
+func (a *Account) ToString(net *network.Network) string {
+	return a.key.ToStringWithType(net, purposeKeyTypes[a.purpose])
+}
+
+// ToPublicString -- the account extended public key with the SLIP-132 version of the purpose.
+func (a *Account) ToPublicString(net *network.Network) string {
+	return a.key.HDPublicKey().ToStringWithType(net, purposeKeyTypes[a.purpose])
+}
+
+// DeriveKey -- derives the key of the chain at the index, m/purpose'/coin_type'/account'/chain/index.
+func (a *Account) DeriveKey(chain uint32, index uint32) (*bip32.HDKey, error) {
+	key, err := a.key.Derive(chain)
+	if err != nil {
+		return nil, err
+	}
+	return key.Derive(index)
+}
+
+// ReceiveAddress -- the receive address at the index.
+func (a *Account) ReceiveAddress(index uint32) (Address, error) {
+	return a.Address(ChainReceive, index)
+}
+
+// ChangeAddress -- the change address at the index.
+func (a *Account) ChangeAddress(index uint32) (Address, error) {
+	return a.Address(ChainChange, index)
+}
+
+// Address -- the address of the chain at the index with the script type of the purpose.
+func (a *Account) Address(chain uint32, index uint32) (Address, error) {
+	key, err := a.DeriveKey(chain, index)
+	if err != nil {
+		return nil, err
+	}
+	return accountAddress(a.purpose, key.PublicKey())
+}
+
+// accountAddress -- the address of the public key for the purpose.
+func accountAddress(purpose uint32, pub *xcrypto.PubKey) (Address, error) {
+	switch purpose {
+	case PurposeP2PKH:
+		return NewPayToPubKeyHashAddress(pub.Hash160()), nil
+	case PurposeP2WPKHInP2SH:
+		redeem, err := p2shP2WPKHRedeemScript(pub)
+		if err != nil {
+			return nil, err
+		}
+		return NewPayToScriptHashAddress(xcrypto.Hash160(redeem)), nil
+	case PurposeP2WPKH:
+		return NewPayToWitnessV0PubKeyHashAddress(pub.Hash160()), nil
+	case PurposeP2TR:
+		output, err := xcrypto.TaprootTweakPubKey(pub, nil)
+		if err != nil {
+			return nil, err
+		}
+		return NewPayToTaprootAddress(output.SerializeXOnly()), nil
+	}
+	return nil, xerror.NewError(Errors, ER_ACCOUNT_PURPOSE_UNSUPPORTED, purpose)
+}
@@ -0,0 +1,113 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"testing"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xcore/bip32"
+	"github.com/keyfuse/tokucore/xcore/bip39"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccount(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	master := bip32.NewHDKey(bip39.NewSeed(mnemonic, ""))
+
+	tests := []struct {
+		name     string
+		net      *network.Network
+		purpose  uint32
+		coinType uint32
+		xprv     string
+		xpub     string
+		receive  []string
+		change   string
+	}{
+		{
+			name:    "bip44",
+			net:     network.MainNet,
+			purpose: PurposeP2PKH,
+			xpub:    "xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj",
+			receive: []string{"1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA"},
+		},
+		{
+			name:     "bip49",
+			net:      network.TestNet,
+			purpose:  PurposeP2WPKHInP2SH,
+			coinType: 1,
+			xpub:     "upub5EFU65HtV5TeiSHmZZm7FUffBGy8UKeqp7vw43jYbvZPpoVsgU93oac7Wk3u6moKegAEWtGNF8DehrnHtv21XXEMYRUocHqguyjknFHYfgY",
+			receive:  []string{"2Mww8dCYPUpKHofjgcXcBCEGmniw9CoaiD2"},
+		},
+		{
+			name:    "bip84",
+			net:     network.MainNet,
+			purpose: PurposeP2WPKH,
+			xprv:    "zprvAdG4iTXWBoARxkkzNpNh8r6Qag3irQB8PzEMkAFeTRXxHpbF9z4QgEvBRmfvqWvGp42t42nvgGpNgYSJA9iefm1yYNZKEm7z6qUWCroSQnE",
+			xpub:    "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs",
+			receive: []string{"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g"},
+			change:  "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el",
+		},
+		{
+			name:    "bip86",
+			net:     network.MainNet,
+			purpose: PurposeP2TR,
+			xprv:    "xprv9xgqHN7yz9MwCkxsBPN5qetuNdQSUttZNKw1dcYTV4mkaAFiBVGQziHs3NRSWMkCzvgjEe3n9xV8oYywvM8at9yRqyaZVz6TYYhX98VjsUk",
+			xpub:    "xpub6BgBgsespWvERF3LHQu6CnqdvfEvtMcQjYrcRzx53QJjSxarj2afYWcLteoGVky7D3UKDP9QyrLprQ3VCECoY49yfdDEHGCtMMj92pReUsQ",
+			receive: []string{"bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr", "bc1p4qhjn9zdvkux4e44uhx8tc55attvtyu358kutcqkudyccelu0was9fqzwh"},
+			change:  "bc1p3qkhfews2uk44qtvauqyr2ttdsw7svhkl9nkm9s9c3x4ax5h60wqwruhk7",
+		},
+	}
+
+	for _, test := range tests {
+		account, err := NewAccount(master, test.purpose, test.coinType, 0)
+		assert.Nil(t, err, test.name)
+		assert.Equal(t, test.purpose, account.Purpose(), test.name)
+		if test.xprv != "" {
+			assert.Equal(t, test.xprv, account.ToString(test.net), test.name)
+		}
+		assert.Equal(t, test.xpub, account.ToPublicString(test.net), test.name)
+		for i, want := range test.receive {
+			addr, err := account.ReceiveAddress(uint32(i))
+			assert.Nil(t, err, test.name)
+			assert.Equal(t, want, addr.ToString(test.net), test.name)
+		}
+		if test.change != "" {
+			addr, err := account.ChangeAddress(0)
+			assert.Nil(t, err, test.name)
+			assert.Equal(t, test.change, addr.ToString(test.net), test.name)
+		}
+
+		// Watch-only from the account public key.
+		watch, err := NewAccountFromString(test.xpub, test.net, test.purpose)
+		assert.Nil(t, err, test.name)
+		assert.Nil(t, watch.HDKey().PrivateKey(), test.name)
+		for i, want := range test.receive {
+			addr, err := watch.ReceiveAddress(uint32(i))
+			assert.Nil(t, err, test.name)
+			assert.Equal(t, want, addr.ToString(test.net), test.name)
+		}
+	}
+}
+
+func TestAccountError(t *testing.T) {
+	master := bip32.NewHDKey([]byte("this.is.alice.seed.at.2018"))
+	_, err := NewAccount(master, 45, 0, 0)
+	assert.EqualError(t, err, "account.purpose[45].unsupported (errno 2301) (state TACC0)")
+
+	account, err := NewAccount(master, PurposeP2WPKH, 0, 0)
+	assert.Nil(t, err)
+	zpub := account.ToPublicString(network.MainNet)
+	_, err = NewAccountFromString(zpub, network.MainNet, 45)
+	assert.EqualError(t, err, "account.purpose[45].unsupported (errno 2301) (state TACC0)")
+	_, err = NewAccountFromString(zpub, network.MainNet, PurposeP2WPKHInP2SH)
+	assert.EqualError(t, err, "account.purpose[49].key.type[p2wpkh].mismatch (errno 2302) (state TACC0)")
+	_, err = NewAccountFromString(zpub, network.TestNet, PurposeP2WPKH)
+	assert.NotNil(t, err)
+	_, err = NewAccountFromHDKey(account.HDKey(), 45)
+	assert.EqualError(t, err, "account.purpose[45].unsupported (errno 2301) (state TACC0)")
+}
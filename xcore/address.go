@@ -18,6 +18,8 @@ import (
 // 1. pay-to-pubkey-hash (P2PKH)
 // 2. pay-to-script-hash (P2SH)
 // 3. pay-to-witness-pubkey-hash (P2WPKH)
+// 4. pay-to-witness-script-hash (P2WSH)
+// 5. pay-to-taproot (P2TR)
 type Address interface {
 	// ToString returns the string of the address with base58 encoding.
 	ToString(net *network.Network) string
@@ -37,8 +39,11 @@ func DecodeAddress(addr string, net *network.Network) (Address, error) {
 		prefix := addr[:oneIndex]
 		if prefix == net.Bech32HRPSegwit {
 			_, version, witnessProgram, err := xbase.WitnessDecode(addr)
-			if err != nil {
-				return nil, err
+			if err != nil || version != 0x00 {
+				// Version 1+ is encoded with bech32m, BIP350.
+				if _, version, witnessProgram, err = xbase.WitnessBech32mDecode(addr); err != nil {
+					return nil, err
+				}
 			}
 			switch version {
 			case 0x00:
@@ -48,6 +53,10 @@ func DecodeAddress(addr string, net *network.Network) (Address, error) {
 				case 32:
 					return NewPayToWitnessV0ScriptHashAddress(witnessProgram), nil
 				}
+			case 0x01:
+				if len(witnessProgram) == 32 {
+					return NewPayToTaprootAddress(witnessProgram), nil
+				}
 			default:
 				return nil, xerror.NewError(Errors, ER_ADDRESS_WITNESS_VERSION_UNSUPPORTED, version)
 			}
@@ -0,0 +1,59 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xbase"
+)
+
+// *******************************************
+// PayToTaprootAddress(P2TR)
+// *******************************************
+
+// PayToTaprootAddress -- is an Address for a pay-to-taproot (P2TR) output.
+// witness program = the 32-byte x-only output key.
+// Encode into bech32m by providing the witness program, bc as the human readable part and 1 as witness version.
+// https://github.com/bitcoin/bips/blob/master/bip-0341.mediawiki
+// https://github.com/bitcoin/bips/blob/master/bip-0350.mediawiki
+type PayToTaprootAddress struct {
+	witnessVersion byte
+	witnessProgram [32]byte
+}
+
+// NewPayToTaprootAddress -- create new PayToTaprootAddress.
+func NewPayToTaprootAddress(outputKey []byte) Address {
+	if len(outputKey) != 32 {
+		return nil
+	}
+
+	var witness [32]byte
+	copy(witness[:], outputKey)
+	return &PayToTaprootAddress{
+		witnessVersion: 0x01,
+		witnessProgram: witness,
+	}
+}
+
+// ToString -- the implementation method for xcore.Address interface.
+func (a *PayToTaprootAddress) ToString(net *network.Network) string {
+	str, err := xbase.WitnessBech32mEncode(net.Bech32HRPSegwit, a.witnessVersion, a.witnessProgram[:])
+	if err != nil {
+		return ""
+	}
+	return str
+}
+
+// Hash160 -- the address hash160 bytes.
+// Here is the x-only output key, not hash160.
+func (a *PayToTaprootAddress) Hash160() []byte {
+	return a.witnessProgram[:]
+}
+
+// LockingScript -- the address locking script.
+func (a *PayToTaprootAddress) LockingScript() ([]byte, error) {
+	return NewPayToTaprootScript(a.Hash160()).GetRawLockingScriptBytes()
+}
@@ -0,0 +1,45 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressP2TR(t *testing.T) {
+	net := network.MainNet
+	{
+		hexstr := "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+		addr := "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"
+		hex, _ := hex.DecodeString(hexstr)
+		address := NewPayToTaprootAddress(hex)
+		assert.Equal(t, addr, address.ToString(net))
+		assert.Equal(t, hex, address.Hash160())
+
+		decode, err := DecodeAddress(addr, net)
+		assert.Nil(t, err)
+		assert.Equal(t, address, decode)
+	}
+
+	// Version 1 with the bech32 checksum.
+	{
+		addr := "bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7k7grplx"
+		_, err := DecodeAddress(addr, net)
+		assert.NotNil(t, err)
+	}
+
+	// nil.
+	{
+		hexstr := "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f817"
+		hex, _ := hex.DecodeString(hexstr)
+		address := NewPayToTaprootAddress(hex)
+		assert.Nil(t, address)
+	}
+}
@@ -0,0 +1,42 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package bip32
+
+import (
+	"github.com/keyfuse/tokucore/xerror"
+)
+
+// Error type.
+const (
+	ER_HDKEY_DERIVE_HARDENED_FROM_PUBLIC int = 6101
+	ER_HDKEY_PATH_INVALID                int = 6102
+	ER_HDKEY_PATH_NOT_DESCENDANT         int = 6103
+	ER_HDKEY_ORIGIN_UNKNOWN              int = 6104
+	ER_HDKEY_ORIGIN_INVALID              int = 6105
+	ER_HDKEY_CHAINCODE_SIZE_INVALID      int = 6201
+	ER_HDKEY_SERIALIZED_SIZE_INVALID     int = 6202
+	ER_HDKEY_CHECKSUM_MISMATCH           int = 6203
+	ER_HDKEY_PUBKEY_INVALID              int = 6204
+	ER_HDKEY_VERSION_UNKNOWN             int = 6205
+	ER_HDKEY_VERSION_PRIVATE_MISMATCH    int = 6206
+	ER_HDKEY_VERSION_PUBLIC_MISMATCH     int = 6207
+)
+
+// Errors -- the jump table of error.
+var Errors = map[int]*xerror.Error{
+	ER_HDKEY_DERIVE_HARDENED_FROM_PUBLIC: {Num: ER_HDKEY_DERIVE_HARDENED_FROM_PUBLIC, State: "THD00", Message: "hdkey.derive.a.hardened.key[%v].from.public.key"},
+	ER_HDKEY_PATH_INVALID:                {Num: ER_HDKEY_PATH_INVALID, State: "THD00", Message: "hdkey.derive.path.invalid[%v]"},
+	ER_HDKEY_PATH_NOT_DESCENDANT:         {Num: ER_HDKEY_PATH_NOT_DESCENDANT, State: "THD00", Message: "hdkey.derive.path[%v].not.under.key.path[%v]"},
+	ER_HDKEY_ORIGIN_UNKNOWN:              {Num: ER_HDKEY_ORIGIN_UNKNOWN, State: "THD00", Message: "hdkey.derive.path[%v].absolute.but.key.origin.unknown"},
+	ER_HDKEY_ORIGIN_INVALID:              {Num: ER_HDKEY_ORIGIN_INVALID, State: "THD00", Message: "hdkey.origin.fingerprint[%x].path[%v].invalid.for.depth[%v]"},
+	ER_HDKEY_CHAINCODE_SIZE_INVALID:      {Num: ER_HDKEY_CHAINCODE_SIZE_INVALID, State: "THD01", Message: "hdkey.chaincode.size[%v].invalid"},
+	ER_HDKEY_SERIALIZED_SIZE_INVALID:     {Num: ER_HDKEY_SERIALIZED_SIZE_INVALID, State: "THD01", Message: "hdkey.serialized.key.wrong.size.want[%v].got[%v]"},
+	ER_HDKEY_CHECKSUM_MISMATCH:           {Num: ER_HDKEY_CHECKSUM_MISMATCH, State: "THD01", Message: "hdkey.checksum.mismatch"},
+	ER_HDKEY_PUBKEY_INVALID:              {Num: ER_HDKEY_PUBKEY_INVALID, State: "THD01", Message: "hdkey.pubkey.invalid[%v]"},
+	ER_HDKEY_VERSION_UNKNOWN:             {Num: ER_HDKEY_VERSION_UNKNOWN, State: "THD01", Message: "hdkey.version[%x].unknown"},
+	ER_HDKEY_VERSION_PRIVATE_MISMATCH:    {Num: ER_HDKEY_VERSION_PRIVATE_MISMATCH, State: "THD01", Message: "hdkey.version[%x].is.private.but.key.is.public"},
+	ER_HDKEY_VERSION_PUBLIC_MISMATCH:     {Num: ER_HDKEY_VERSION_PUBLIC_MISMATCH, State: "THD01", Message: "hdkey.version[%x].is.public.but.key.is.private"},
+}
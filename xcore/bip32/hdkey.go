@@ -8,9 +8,6 @@ package bip32
 import (
 	"bytes"
 	"crypto/rand"
-	"fmt"
-	"strconv"
-	"strings"
 
 	"crypto/hmac"
 	"crypto/sha512"
@@ -19,6 +16,7 @@ import (
 	"github.com/keyfuse/tokucore/network"
 	"github.com/keyfuse/tokucore/xbase"
 	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/keyfuse/tokucore/xerror"
 )
 
 // Formulas:
@@ -49,6 +47,7 @@ const (
 
 // HDKey -- a BIP32 Hierarchically Derived key.
 type HDKey struct {
+	version   []byte // 4 bytes, only set by the import
 	childNum  []byte // 4 bytes
 	parentFP  []byte // 4bytes
 	chainCode []byte // 32 bytes
@@ -56,6 +55,10 @@ type HDKey struct {
 	isPrivate bool   // Unserialized
 	prvkey    *xcrypto.PrvKey
 	pubkey    *xcrypto.PubKey
+
+	// The key origin, nil if unknown, such as a non-master key imported from string.
+	masterFP []byte
+	path     Path
 }
 
 // NewHDKey -- creates a new master HDKey from a seed.
@@ -89,6 +92,22 @@ func NewHDKeyRand() (*HDKey, error) {
 	return NewHDKey(seed), nil
 }
 
+// NewHDPublicKey -- creates a master public HDKey from the public key and chain code,
+// such as the joint key of the MPC parties.
+func NewHDPublicKey(pubkey *xcrypto.PubKey, chainCode []byte) (*HDKey, error) {
+	if len(chainCode) != 32 {
+		return nil, xerror.NewError(Errors, ER_HDKEY_CHAINCODE_SIZE_INVALID, len(chainCode))
+	}
+	return &HDKey{
+		childNum:  []byte{0x00, 0x00, 0x00, 0x00},
+		parentFP:  []byte{0x00, 0x00, 0x00, 0x00},
+		chainCode: chainCode,
+		depth:     0x00,
+		isPrivate: false,
+		pubkey:    pubkey,
+	}, nil
+}
+
 // Derive -- returns a derived child extended key at the given index.
 // When this extended key is a private extended key (as determined by the IsPrivate
 // function), a private extended key will be derived.  Otherwise, the derived
@@ -122,7 +141,7 @@ func (k *HDKey) Derive(childIdx uint32) (*HDKey, error) {
 	// extended key.
 	isChildHardened := childIdx >= HardenedKeyStart
 	if !k.isPrivate && isChildHardened {
-		return nil, fmt.Errorf("hdkey.derive.a.hardened.key.from.public.key.erro")
+		return nil, xerror.NewError(Errors, ER_HDKEY_DERIVE_HARDENED_FROM_PUBLIC, childIdx-HardenedKeyStart)
 	}
 
 	// Split "I" into two 32-byte sequences Il and Ir where:
@@ -139,6 +158,10 @@ func (k *HDKey) Derive(childIdx uint32) (*HDKey, error) {
 		chainCode: intermediary[32:],
 		depth:     k.depth + 1,
 		isPrivate: k.isPrivate,
+		masterFP:  k.MasterFingerprint(),
+	}
+	if path := k.Path(); path != nil {
+		childKey.path = append(path[:len(path):len(path)], childIdx)
 	}
 
 	prvkeyBytes := intermediary[:32]
@@ -162,35 +185,28 @@ func (k *HDKey) Derive(childIdx uint32) (*HDKey, error) {
 	return childKey, nil
 }
 
-// DeriveByPath -- derive by path.
-// m/1/2/3
+// DeriveByPath -- derives the key by the path, see ParsePath for the formats.
+// A relative path (0/1) is derived from this key, an absolute path (m/84'/0'/0'/0/1)
+// must be under the path of this key and only the remaining steps are derived.
 func (k *HDKey) DeriveByPath(path string) (*HDKey, error) {
-	if path == "" {
-		return k, nil
+	steps, absolute, err := ParsePath(path)
+	if err != nil {
+		return nil, err
 	}
-
-	steps := strings.Split(path, "/")
-	if steps[0] != "m" {
-		return nil, fmt.Errorf("hdkey.derive.path.invalid[%v]", path)
+	if absolute {
+		origin := k.Path()
+		if origin == nil {
+			return nil, xerror.NewError(Errors, ER_HDKEY_ORIGIN_UNKNOWN, path)
+		}
+		if !steps.hasPrefix(origin) {
+			return nil, xerror.NewError(Errors, ER_HDKEY_PATH_NOT_DESCENDANT, path, origin)
+		}
+		steps = steps[len(origin):]
 	}
 
 	hd := k
-	for i := 1; i < len(steps); i++ {
-		var isHardened bool
-		step := steps[i]
-		if step[len(step)-1] == '\'' {
-			isHardened = true
-			step = step[:len(step)-1]
-		}
-
-		idx, err := strconv.ParseUint(step, 10, 32)
-		if err != nil {
-			return nil, fmt.Errorf("hdkey.derive.path.invalid[%v]", path)
-		}
-		if isHardened {
-			idx += HardenedKeyStart
-		}
-		hd, err = hd.Derive(uint32(idx))
+	for _, idx := range steps {
+		hd, err = hd.Derive(idx)
 		if err != nil {
 			return nil, err
 		}
@@ -198,6 +214,51 @@ func (k *HDKey) DeriveByPath(path string) (*HDKey, error) {
 	return hd, nil
 }
 
+// Fingerprint -- the first 4 bytes of the hash160 of the compressed public key.
+func (k *HDKey) Fingerprint() []byte {
+	return xcrypto.Hash160(k.PublicKey().Serialize())[:4]
+}
+
+// ParentFingerprint -- the fingerprint of the parent key, zeros for the master key.
+func (k *HDKey) ParentFingerprint() []byte {
+	return k.parentFP
+}
+
+// MasterFingerprint -- the fingerprint of the master key this key derives from, nil if unknown.
+func (k *HDKey) MasterFingerprint() []byte {
+	if k.depth == 0 {
+		return k.Fingerprint()
+	}
+	return k.masterFP
+}
+
+// Path -- the derivation path from the master key, nil if unknown.
+func (k *HDKey) Path() Path {
+	if k.depth == 0 {
+		return Path{}
+	}
+	return k.path
+}
+
+// Depth -- the depth of the key, 0 for the master key.
+func (k *HDKey) Depth() byte {
+	return k.depth
+}
+
+// SetOrigin -- sets the master fingerprint and the path of an imported key, such as from
+// the descriptor key origin [d34db33f/84'/0'/0']xpub..., the path length must be the depth.
+func (k *HDKey) SetOrigin(masterFP []byte, path Path) error {
+	if len(masterFP) != 4 || len(path) != int(k.depth) {
+		return xerror.NewError(Errors, ER_HDKEY_ORIGIN_INVALID, masterFP, path, k.depth)
+	}
+	if len(path) > 0 && path[len(path)-1] != binary.BigEndian.Uint32(k.childNum) {
+		return xerror.NewError(Errors, ER_HDKEY_ORIGIN_INVALID, masterFP, path, k.depth)
+	}
+	k.masterFP = append([]byte(nil), masterFP...)
+	k.path = append(Path(nil), path...)
+	return nil
+}
+
 // getIntermediary --
 // get intermediary to create key and chaincode.
 // Hardened children are based on the private key.
@@ -236,6 +297,8 @@ func (k *HDKey) HDPublicKey() *HDKey {
 		chainCode: k.chainCode,
 		isPrivate: false,
 		pubkey:    pubkey,
+		masterFP:  k.masterFP,
+		path:      k.path,
 	}
 }
 
@@ -255,14 +318,16 @@ func (k *HDKey) PrivateKey() *xcrypto.PrvKey {
 
 // ToString -- the HDkey as a human-readable base58-encoded string, WIF, 78bytes.
 func (k *HDKey) ToString(net *network.Network) string {
+	return k.ToStringWithType(net, KeyTypeP2PKH)
+}
+
+// serialize -- the base58-encoded string with the version.
+func (k *HDKey) serialize(version []byte) string {
 	var keyBytes []byte
-	var version []byte
 
 	if k.isPrivate {
-		version = net.HDPrivateKeyID
 		keyBytes = append([]byte{0x0}, k.prvkey.Serialize()...)
 	} else {
-		version = net.HDPublicKeyID
 		keyBytes = k.pubkey.Serialize()
 	}
 
@@ -286,13 +351,20 @@ func (k *HDKey) ToString(net *network.Network) string {
 func NewHDKeyFromString(wif string) (*HDKey, error) {
 	data := xbase.Base58Decode(wif)
 	if len(data) != (serializedKeyLen + 4) {
-		return nil, fmt.Errorf("hdkey.serialized.key.wrong.size.want[%v].got[%v]", serializedKeyLen+4, len(data))
+		return nil, xerror.NewError(Errors, ER_HDKEY_SERIALIZED_SIZE_INVALID, serializedKeyLen+4, len(data))
+	}
+
+	// validate checksum
+	cs1 := xcrypto.DoubleSha256(data[0 : len(data)-4])[:4]
+	if !bytes.Equal(cs1, data[len(data)-4:]) {
+		return nil, xerror.NewError(Errors, ER_HDKEY_CHECKSUM_MISMATCH)
 	}
 
 	// The serialized format is:
 	//   version (4) || depth (1) || parent fingerprint (4)) ||
 	//   child num (4) || chain code (32) || key data (33) || checksum (4)
 	var k = &HDKey{}
+	k.version = data[0:4]
 	k.depth = data[4]
 	k.parentFP = data[5:9]
 	k.childNum = data[9:13]
@@ -305,19 +377,10 @@ func NewHDKeyFromString(wif string) (*HDKey, error) {
 		k.isPrivate = false
 		pubkey, err := xcrypto.PubKeyFromBytes(data[45:78])
 		if err != nil {
-			return nil, err
+			return nil, xerror.NewError(Errors, ER_HDKEY_PUBKEY_INVALID, err)
 		}
 		k.pubkey = pubkey
 	}
-
-	// validate checksum
-	cs1 := xcrypto.DoubleSha256(data[0 : len(data)-4])[:4]
-	cs2 := data[len(data)-4:]
-	for i := range cs1 {
-		if cs1[i] != cs2[i] {
-			return nil, fmt.Errorf("hdkey.checksum.mismatch")
-		}
-	}
 	return k, nil
 }
 
@@ -7,10 +7,12 @@ package bip32
 
 import (
 	"encoding/hex"
+	"fmt"
 	"testing"
 
-	"github.com/stretchr/testify/assert"
 	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/stretchr/testify/assert"
 )
 
 // TestBIP0032Vectors tests the vectors provided by [BIP32] to ensure the
@@ -397,3 +399,161 @@ func TestHDKeyRand(t *testing.T) {
 		t.Logf("%s", wif)
 	}
 }
+
+func TestNewHDPublicKey(t *testing.T) {
+	hdkey := NewHDKey([]byte("this.is.alice.seed."))
+	xpub, err := NewHDPublicKey(hdkey.PublicKey(), hdkey.chainCode)
+	assert.Nil(t, err)
+	assert.Equal(t, hdkey.HDPublicKey().ToString(network.MainNet), xpub.ToString(network.MainNet))
+
+	want, err := hdkey.DeriveByPath("m/0/5")
+	assert.Nil(t, err)
+	got, err := xpub.DeriveByPath("m/0/5")
+	assert.Nil(t, err)
+	assert.Equal(t, want.PublicKey(), got.PublicKey())
+
+	_, err = NewHDPublicKey(hdkey.PublicKey(), hdkey.chainCode[1:])
+	assert.NotNil(t, err)
+}
+
+func TestHDKeyMpc(t *testing.T) {
+	prv1 := NewHDKey([]byte("this.is.alice.seed.")).PrivateKey()
+	prv2 := NewHDKey([]byte("this.is.bob.seed.")).PrivateKey()
+
+	// 2P-ECDSA.
+	{
+		party1 := xcrypto.NewEcdsaParty(prv1)
+		defer party1.Close()
+		party2 := xcrypto.NewEcdsaParty(prv2)
+		defer party2.Close()
+		c1, err := party1.ChainCodePhase1()
+		assert.Nil(t, err)
+		c2, err := party2.ChainCodePhase1()
+		assert.Nil(t, err)
+		s1, err := party1.ChainCodePhase2(c2)
+		assert.Nil(t, err)
+		s2, err := party2.ChainCodePhase2(c1)
+		assert.Nil(t, err)
+		chain, err := party1.ChainCodePhase3(s2)
+		assert.Nil(t, err)
+		_, err = party2.ChainCodePhase3(s1)
+		assert.Nil(t, err)
+
+		xpub, err := NewHDPublicKey(party1.Phase1(party2.PubKey()), chain)
+		assert.Nil(t, err)
+		for i := 0; i < 3; i++ {
+			path := fmt.Sprintf("m/0/%v", i)
+			want, err := xpub.DeriveByPath(path)
+			assert.Nil(t, err)
+			_, got, err := party2.Derive(party1.PubKey(), path)
+			assert.Nil(t, err)
+			assert.Equal(t, want.PublicKey(), got)
+		}
+	}
+
+	// MPC Schnorr.
+	{
+		party1, _ := xcrypto.NewSchnorrParty(prv1)
+		defer party1.Close()
+		party2, _ := xcrypto.NewSchnorrParty(prv2)
+		defer party2.Close()
+		c1, err := party1.ChainCodePhase1()
+		assert.Nil(t, err)
+		c2, err := party2.ChainCodePhase1()
+		assert.Nil(t, err)
+		s1, err := party1.ChainCodePhase2(c2)
+		assert.Nil(t, err)
+		s2, err := party2.ChainCodePhase2(c1)
+		assert.Nil(t, err)
+		chain, err := party1.ChainCodePhase3(s2)
+		assert.Nil(t, err)
+		_, err = party2.ChainCodePhase3(s1)
+		assert.Nil(t, err)
+
+		xpub, err := NewHDPublicKey(party1.Phase1(party2.PubKey()), chain)
+		assert.Nil(t, err)
+		for i := 0; i < 3; i++ {
+			path := fmt.Sprintf("m/0/%v", i)
+			want, err := xpub.DeriveByPath(path)
+			assert.Nil(t, err)
+			child1, got, err := party1.Derive([]*xcrypto.PubKey{party2.PubKey()}, path)
+			assert.Nil(t, err)
+			child2, _, err := party2.Derive([]*xcrypto.PubKey{party1.PubKey()}, path)
+			assert.Nil(t, err)
+			assert.Equal(t, want.PublicKey(), got)
+			assert.Equal(t, want.PublicKey(), child1.Phase1(child2.PubKey()))
+		}
+	}
+}
+
+func TestHDKeyOrigin(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master := NewHDKey(seed)
+	assert.Equal(t, "3442193e", hex.EncodeToString(master.Fingerprint()))
+	assert.Equal(t, master.Fingerprint(), master.MasterFingerprint())
+	assert.Equal(t, Path{}, master.Path())
+	assert.Equal(t, byte(0), master.Depth())
+
+	// Relative and absolute paths reach the same key.
+	child, err := master.DeriveByPath("m/0h")
+	assert.Nil(t, err)
+	assert.Equal(t, "5c1bd648", hex.EncodeToString(child.Fingerprint()))
+	assert.Equal(t, master.Fingerprint(), child.ParentFingerprint())
+	key1, err := master.DeriveByPath("m/0'/1/2H")
+	assert.Nil(t, err)
+	key2, err := child.DeriveByPath("1/2'")
+	assert.Nil(t, err)
+	key3, err := child.DeriveByPath("m/0'/1/2'")
+	assert.Nil(t, err)
+	for _, key := range []*HDKey{key1, key2, key3, key1.HDPublicKey()} {
+		assert.Equal(t, "m/0'/1/2'", key.Path().String())
+		assert.Equal(t, master.Fingerprint(), key.MasterFingerprint())
+		assert.Equal(t, byte(3), key.Depth())
+	}
+	assert.Equal(t, key1.ToString(network.MainNet), key2.ToString(network.MainNet))
+	assert.Equal(t, key1.ToString(network.MainNet), key3.ToString(network.MainNet))
+
+	// The absolute path must be under the key.
+	_, err = child.DeriveByPath("m/1/2")
+	assert.EqualError(t, err, "hdkey.derive.path[m/1/2].not.under.key.path[m/0'] (errno 6103) (state THD00)")
+
+	// The imported non-master key has no origin until it is set.
+	imported, err := NewHDKeyFromString(child.HDPublicKey().ToString(network.MainNet))
+	assert.Nil(t, err)
+	assert.Nil(t, imported.MasterFingerprint())
+	assert.Nil(t, imported.Path())
+	_, err = imported.DeriveByPath("m/0'/1")
+	assert.EqualError(t, err, "hdkey.derive.path[m/0'/1].absolute.but.key.origin.unknown (errno 6104) (state THD00)")
+	relative, err := imported.DeriveByPath("1")
+	assert.Nil(t, err)
+	assert.Nil(t, relative.Path())
+
+	err = imported.SetOrigin(master.Fingerprint(), Path{1})
+	assert.EqualError(t, err, "hdkey.origin.fingerprint[3442193e].path[m/1].invalid.for.depth[1] (errno 6105) (state THD00)")
+	err = imported.SetOrigin(master.Fingerprint()[:3], Path{HardenedKeyStart})
+	assert.NotNil(t, err)
+	assert.Nil(t, imported.SetOrigin(master.Fingerprint(), Path{HardenedKeyStart}))
+	got, err := imported.DeriveByPath("m/0'/1")
+	assert.Nil(t, err)
+	assert.Equal(t, "m/0'/1", got.Path().String())
+	assert.Equal(t, master.Fingerprint(), got.MasterFingerprint())
+}
+
+func TestHDKeyError(t *testing.T) {
+	master := NewHDKey([]byte("this.is.alice.seed."))
+
+	_, err := master.HDPublicKey().DeriveByPath("m/1'")
+	assert.EqualError(t, err, "hdkey.derive.a.hardened.key[1].from.public.key (errno 6101) (state THD00)")
+	_, err = NewHDPublicKey(master.PublicKey(), master.chainCode[1:])
+	assert.EqualError(t, err, "hdkey.chaincode.size[31].invalid (errno 6201) (state THD01)")
+	_, err = NewHDKeyFromString("xpub")
+	assert.EqualError(t, err, "hdkey.serialized.key.wrong.size.want[82].got[3] (errno 6202) (state THD01)")
+
+	str := master.ToString(network.MainNet)
+	broken := str[:len(str)-1] + "1"
+	if broken == str {
+		broken = str[:len(str)-1] + "2"
+	}
+	_, err = NewHDKeyFromString(broken)
+	assert.EqualError(t, err, "hdkey.checksum.mismatch (errno 6203) (state THD01)")
+}
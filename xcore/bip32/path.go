@@ -0,0 +1,82 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package bip32
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/keyfuse/tokucore/xerror"
+)
+
+// Path -- the child indexes of a BIP32 derivation path.
+type Path []uint32
+
+// ParsePath -- parses the derivation path, absolute paths start with "m",
+// the hardened steps end with one of the markers ', h or H.
+// m/84'/0'/0' and m/84h/0h/0h are absolute, 0/1 is relative.
+func ParsePath(path string) (Path, bool, error) {
+	if path == "" {
+		return Path{}, false, nil
+	}
+
+	steps := strings.Split(path, "/")
+	absolute := steps[0] == "m"
+	if absolute {
+		steps = steps[1:]
+	}
+
+	indexes := make(Path, 0, len(steps))
+	for _, step := range steps {
+		var isHardened bool
+		if step != "" {
+			switch step[len(step)-1] {
+			case '\'', 'h', 'H':
+				isHardened = true
+				step = step[:len(step)-1]
+			}
+		}
+
+		idx, err := strconv.ParseUint(step, 10, 32)
+		if err != nil || idx >= HardenedKeyStart {
+			return nil, false, xerror.NewError(Errors, ER_HDKEY_PATH_INVALID, path)
+		}
+		if isHardened {
+			idx += HardenedKeyStart
+		}
+		indexes = append(indexes, uint32(idx))
+	}
+	return indexes, absolute, nil
+}
+
+// String -- the absolute path with the ' hardened marker.
+func (p Path) String() string {
+	var b strings.Builder
+	b.WriteString("m")
+	for _, idx := range p {
+		b.WriteByte('/')
+		if idx >= HardenedKeyStart {
+			b.WriteString(strconv.FormatUint(uint64(idx-HardenedKeyStart), 10))
+			b.WriteByte('\'')
+		} else {
+			b.WriteString(strconv.FormatUint(uint64(idx), 10))
+		}
+	}
+	return b.String()
+}
+
+// hasPrefix -- returns whether the path starts with the prefix.
+func (p Path) hasPrefix(prefix Path) bool {
+	if len(p) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if p[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package bip32
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePath(t *testing.T) {
+	h := uint32(HardenedKeyStart)
+	tests := []struct {
+		path     string
+		want     Path
+		absolute bool
+		str      string
+	}{
+		{"", Path{}, false, "m"},
+		{"m", Path{}, true, "m"},
+		{"m/84'/0'/0'/0/1", Path{84 + h, h, h, 0, 1}, true, "m/84'/0'/0'/0/1"},
+		{"m/84h/0H/0h", Path{84 + h, h, h}, true, "m/84'/0'/0'"},
+		{"0/1", Path{0, 1}, false, "m/0/1"},
+		{"1'/2147483647", Path{1 + h, 2147483647}, false, "m/1'/2147483647"},
+		{"m/2147483647'", Path{0xffffffff}, true, "m/2147483647'"},
+	}
+	for _, test := range tests {
+		got, absolute, err := ParsePath(test.path)
+		assert.Nil(t, err, test.path)
+		assert.Equal(t, test.want, got, test.path)
+		assert.Equal(t, test.absolute, absolute, test.path)
+		assert.Equal(t, test.str, got.String(), test.path)
+	}
+
+	for _, path := range []string{"m/", "/0", "m//1", "m/1/", "M/1", "m/x", "m/1''", "m/-1", "m/2147483648", "m/1'h", "n/1"} {
+		_, _, err := ParsePath(path)
+		assert.EqualError(t, err, "hdkey.derive.path.invalid["+path+"] (errno 6102) (state THD00)", path)
+	}
+}
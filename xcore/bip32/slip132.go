@@ -0,0 +1,98 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package bip32
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xerror"
+)
+
+// KeyType -- the script type of the SLIP-132 extended key version.
+// https://github.com/satoshilabs/slips/blob/master/slip-0132.md
+type KeyType int
+
+const (
+	// KeyTypeP2PKH -- xprv/xpub, tprv/tpub, also used by the BIP86 P2TR accounts.
+	KeyTypeP2PKH KeyType = iota
+	// KeyTypeP2WPKHInP2SH -- yprv/ypub, uprv/upub.
+	KeyTypeP2WPKHInP2SH
+	// KeyTypeP2WSHInP2SH -- Yprv/Ypub, Uprv/Upub.
+	KeyTypeP2WSHInP2SH
+	// KeyTypeP2WPKH -- zprv/zpub, vprv/vpub.
+	KeyTypeP2WPKH
+	// KeyTypeP2WSH -- Zprv/Zpub, Vprv/Vpub.
+	KeyTypeP2WSH
+)
+
+var keyTypeNames = map[KeyType]string{
+	KeyTypeP2PKH:        "p2pkh",
+	KeyTypeP2WPKHInP2SH: "p2wpkh-p2sh",
+	KeyTypeP2WSHInP2SH:  "p2wsh-p2sh",
+	KeyTypeP2WPKH:       "p2wpkh",
+	KeyTypeP2WSH:        "p2wsh",
+}
+
+// String -- the name of the key type.
+func (t KeyType) String() string {
+	if name, ok := keyTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", int(t))
+}
+
+// versions -- returns the private and public versions of the key type in the network.
+func (t KeyType) versions(net *network.Network) ([]byte, []byte) {
+	switch t {
+	case KeyTypeP2PKH:
+		return net.HDPrivateKeyID, net.HDPublicKeyID
+	case KeyTypeP2WPKHInP2SH:
+		return net.HDSegwitP2SHPrivateKeyID, net.HDSegwitP2SHPublicKeyID
+	case KeyTypeP2WSHInP2SH:
+		return net.HDSegwitP2SHMultisigPrivateKeyID, net.HDSegwitP2SHMultisigPublicKeyID
+	case KeyTypeP2WPKH:
+		return net.HDSegwitPrivateKeyID, net.HDSegwitPublicKeyID
+	case KeyTypeP2WSH:
+		return net.HDSegwitMultisigPrivateKeyID, net.HDSegwitMultisigPublicKeyID
+	}
+	return nil, nil
+}
+
+// ToStringWithType -- the HDKey as a base58-encoded string with the SLIP-132 version of the key type.
+func (k *HDKey) ToStringWithType(net *network.Network, t KeyType) string {
+	prv, pub := t.versions(net)
+	if k.isPrivate {
+		return k.serialize(prv)
+	}
+	return k.serialize(pub)
+}
+
+// NewHDKeyFromStringWithNetwork -- imports the extended key string of the network,
+// returns the HDKey and the key type of its SLIP-132 version.
+func NewHDKeyFromStringWithNetwork(key string, net *network.Network) (*HDKey, KeyType, error) {
+	k, err := NewHDKeyFromString(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	for t := range keyTypeNames {
+		prv, pub := t.versions(net)
+		switch {
+		case bytes.Equal(k.version, prv):
+			if !k.isPrivate {
+				return nil, 0, xerror.NewError(Errors, ER_HDKEY_VERSION_PRIVATE_MISMATCH, k.version)
+			}
+			return k, t, nil
+		case bytes.Equal(k.version, pub):
+			if k.isPrivate {
+				return nil, 0, xerror.NewError(Errors, ER_HDKEY_VERSION_PUBLIC_MISMATCH, k.version)
+			}
+			return k, t, nil
+		}
+	}
+	return nil, 0, xerror.NewError(Errors, ER_HDKEY_VERSION_UNKNOWN, k.version)
+}
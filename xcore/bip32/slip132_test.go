@@ -0,0 +1,75 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package bip32
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSLIP132(t *testing.T) {
+	key := NewHDKey([]byte("this.is.alice.seed.at.2018"))
+	account, err := key.DeriveByPath("m/84'/0'/0'")
+	assert.Nil(t, err)
+
+	tests := []struct {
+		net     *network.Network
+		keyType KeyType
+		prefix  string
+	}{
+		{network.MainNet, KeyTypeP2PKH, "x"},
+		{network.MainNet, KeyTypeP2WPKHInP2SH, "y"},
+		{network.MainNet, KeyTypeP2WSHInP2SH, "Y"},
+		{network.MainNet, KeyTypeP2WPKH, "z"},
+		{network.MainNet, KeyTypeP2WSH, "Z"},
+		{network.TestNet, KeyTypeP2PKH, "t"},
+		{network.TestNet, KeyTypeP2WPKHInP2SH, "u"},
+		{network.TestNet, KeyTypeP2WSHInP2SH, "U"},
+		{network.TestNet, KeyTypeP2WPKH, "v"},
+		{network.TestNet, KeyTypeP2WSH, "V"},
+	}
+
+	for _, test := range tests {
+		for _, k := range []*HDKey{account, account.HDPublicKey()} {
+			kind := "pub"
+			if k.isPrivate {
+				kind = "prv"
+			}
+			str := k.ToStringWithType(test.net, test.keyType)
+			assert.True(t, strings.HasPrefix(str, test.prefix+kind), str)
+
+			got, keyType, err := NewHDKeyFromStringWithNetwork(str, test.net)
+			assert.Nil(t, err)
+			assert.Equal(t, test.keyType, keyType)
+			assert.Equal(t, str, got.ToStringWithType(test.net, keyType))
+			assert.Equal(t, k.PublicKey(), got.PublicKey())
+		}
+	}
+	assert.Equal(t, account.ToString(network.MainNet), account.ToStringWithType(network.MainNet, KeyTypeP2PKH))
+	assert.Equal(t, "p2wpkh", KeyTypeP2WPKH.String())
+	assert.Equal(t, "unknown(9)", KeyType(9).String())
+}
+
+func TestSLIP132Error(t *testing.T) {
+	key := NewHDKey([]byte("this.is.alice.seed.at.2018"))
+
+	// Testnet version on mainnet.
+	_, _, err := NewHDKeyFromStringWithNetwork(key.ToStringWithType(network.TestNet, KeyTypeP2WPKH), network.MainNet)
+	assert.EqualError(t, err, "hdkey.version[045f18bc].unknown (errno 6205) (state THD01)")
+
+	// Private version with the public key data.
+	pub := key.HDPublicKey()
+	_, _, err = NewHDKeyFromStringWithNetwork(pub.serialize(network.MainNet.HDSegwitPrivateKeyID), network.MainNet)
+	assert.EqualError(t, err, "hdkey.version[04b2430c].is.private.but.key.is.public (errno 6206) (state THD01)")
+	_, _, err = NewHDKeyFromStringWithNetwork(key.serialize(network.MainNet.HDSegwitPublicKeyID), network.MainNet)
+	assert.EqualError(t, err, "hdkey.version[04b24746].is.public.but.key.is.private (errno 6207) (state THD01)")
+
+	_, _, err = NewHDKeyFromStringWithNetwork("xpub", network.MainNet)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,465 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package bip38
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/keyfuse/tokucore/xcrypto/scrypt"
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+)
+
+// https://github.com/bitcoin/bips/blob/master/bip-0038.mediawiki
+const (
+	scryptN   = 16384
+	scryptR   = 8
+	scryptP   = 8
+	ecScryptN = 1024
+	ecScryptR = 1
+	ecScryptP = 1
+
+	encryptedKeySize     = 39
+	intermediateCodeSize = 49
+	confirmationCodeSize = 51
+	ownerEntropySize     = 8
+	seedSize             = 24
+
+	flagNonEC       byte = 0xc0
+	flagCompressed  byte = 0x20
+	flagLotSequence byte = 0x04
+
+	// MaxLot -- the maximum lot number of the intermediate code.
+	MaxLot = 1048575
+	// MaxSequence -- the maximum sequence number of the intermediate code.
+	MaxSequence = 4095
+)
+
+var (
+	prefixNonEC       = []byte{0x01, 0x42}
+	prefixEC          = []byte{0x01, 0x43}
+	magicIntermediate = []byte{0x2c, 0xe9, 0xb3, 0xe1, 0xff, 0x39, 0xe2}
+	magicConfirmation = []byte{0x64, 0x3b, 0xf6, 0xa8, 0x9a}
+)
+
+const (
+	magicLotSequence   byte = 0x51
+	magicNoLotSequence byte = 0x53
+)
+
+// GeneratedKey -- the result of the EC-multiply key generation from an intermediate code.
+type GeneratedKey struct {
+	EncryptedKey     string
+	ConfirmationCode string
+	Address          string
+}
+
+// Encrypt -- encrypts the private key with the passphrase in the non-EC-multiply mode,
+// the compressed flag selects the address which the key is bound to.
+// The passphrase should be NFC normalized by the caller.
+func Encrypt(prv *xcrypto.PrvKey, passphrase string, compressed bool) (string, error) {
+	flag := flagNonEC
+	if compressed {
+		flag |= flagCompressed
+	}
+	addrHash := addressHash(prv.PubKey(), compressed)
+	derived, err := scrypt.Key([]byte(passphrase), addrHash, scryptN, scryptR, scryptP, 64)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(derived[32:])
+	if err != nil {
+		return "", err
+	}
+
+	key := prv.Serialize()
+	buf := make([]byte, 0, encryptedKeySize)
+	buf = append(buf, prefixNonEC...)
+	buf = append(buf, flag)
+	buf = append(buf, addrHash...)
+	buf = append(buf, encryptBlock(block, xorBytes(key[:16], derived[:16]))...)
+	buf = append(buf, encryptBlock(block, xorBytes(key[16:], derived[16:32]))...)
+	return encode(buf), nil
+}
+
+// Decrypt -- decrypts the 6P... encrypted key of both the non-EC-multiply and the EC-multiply mode.
+// Returns the private key and whether its address uses the compressed public key.
+func Decrypt(encrypted string, passphrase string) (*xcrypto.PrvKey, bool, error) {
+	buf, err := decode(encrypted)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(buf) != encryptedKeySize {
+		return nil, false, fmt.Errorf("bip38.encrypted.key.size[%v].invalid", len(buf))
+	}
+
+	flag := buf[2]
+	compressed := flag&flagCompressed != 0
+	switch {
+	case buf[0] == prefixNonEC[0] && buf[1] == prefixNonEC[1]:
+		if flag&^flagCompressed != flagNonEC {
+			return nil, false, fmt.Errorf("bip38.flag[%x].invalid", flag)
+		}
+		prv, err := decryptNonEC(buf, passphrase, compressed)
+		return prv, compressed, err
+	case buf[0] == prefixEC[0] && buf[1] == prefixEC[1]:
+		if flag&^(flagCompressed|flagLotSequence) != 0 {
+			return nil, false, fmt.Errorf("bip38.flag[%x].invalid", flag)
+		}
+		prv, err := decryptEC(buf, passphrase, compressed)
+		return prv, compressed, err
+	default:
+		return nil, false, fmt.Errorf("bip38.prefix[%x].invalid", buf[:2])
+	}
+}
+
+// NewIntermediateCode -- creates the passphrase... intermediate code with a random owner salt,
+// the owner gives it to a party which generates the encrypted keys without knowing the passphrase.
+func NewIntermediateCode(passphrase string) (string, error) {
+	ownerEntropy := make([]byte, ownerEntropySize)
+	if _, err := rand.Read(ownerEntropy); err != nil {
+		return "", err
+	}
+	return intermediateCode(passphrase, ownerEntropy, false)
+}
+
+// NewIntermediateCodeWithLotSequence -- creates the intermediate code with the lot and sequence numbers,
+// which are recovered from every encrypted key and confirmation code generated from it.
+func NewIntermediateCodeWithLotSequence(passphrase string, lot uint32, sequence uint32) (string, error) {
+	if lot > MaxLot {
+		return "", fmt.Errorf("bip38.lot[%v].invalid", lot)
+	}
+	if sequence > MaxSequence {
+		return "", fmt.Errorf("bip38.sequence[%v].invalid", sequence)
+	}
+	ownerEntropy := make([]byte, ownerEntropySize)
+	if _, err := rand.Read(ownerEntropy[:4]); err != nil {
+		return "", err
+	}
+	binary.BigEndian.PutUint32(ownerEntropy[4:], lot*(MaxSequence+1)+sequence)
+	return intermediateCode(passphrase, ownerEntropy, true)
+}
+
+// GenerateEncryptedKey -- generates a new encrypted key and its confirmation code from the intermediate code.
+func GenerateEncryptedKey(intermediate string, compressed bool) (*GeneratedKey, error) {
+	seed := make([]byte, seedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	return generateEncryptedKey(intermediate, compressed, seed)
+}
+
+// VerifyConfirmationCode -- checks the cfrm38... confirmation code with the passphrase,
+// returns the address of the encrypted key.
+func VerifyConfirmationCode(confirmation string, passphrase string) (string, error) {
+	buf, err := decode(confirmation)
+	if err != nil {
+		return "", err
+	}
+	if len(buf) != confirmationCodeSize {
+		return "", fmt.Errorf("bip38.confirmation.code.size[%v].invalid", len(buf))
+	}
+	for i, b := range magicConfirmation {
+		if buf[i] != b {
+			return "", fmt.Errorf("bip38.confirmation.code.magic[%x].invalid", buf[:len(magicConfirmation)])
+		}
+	}
+
+	flag := buf[5]
+	if flag&^(flagCompressed|flagLotSequence) != 0 {
+		return "", fmt.Errorf("bip38.flag[%x].invalid", flag)
+	}
+	compressed := flag&flagCompressed != 0
+	addrHash := buf[6:10]
+	ownerEntropy := buf[10:18]
+
+	passFactor, err := passFactor(passphrase, ownerEntropy, flag&flagLotSequence != 0)
+	if err != nil {
+		return "", err
+	}
+	passPoint := xcrypto.PrvKeyFromBytes(passFactor).PubKey().SerializeCompressed()
+	derived, block, err := ecDerive(passPoint, addrHash, ownerEntropy)
+	if err != nil {
+		return "", err
+	}
+
+	pointb := make([]byte, 0, 33)
+	pointb = append(pointb, buf[18]^(derived[63]&0x01))
+	pointb = append(pointb, xorBytes(decryptBlock(block, buf[19:35]), derived[:16])...)
+	pointb = append(pointb, xorBytes(decryptBlock(block, buf[35:51]), derived[16:32])...)
+	if pointb[0] != 0x02 && pointb[0] != 0x03 {
+		return "", fmt.Errorf("bip38.passphrase.invalid")
+	}
+	pub, err := xcrypto.PubKeyFromBytes(pointb)
+	if err != nil {
+		return "", fmt.Errorf("bip38.passphrase.invalid")
+	}
+	pub = scalarMult(pub, passFactor)
+	addr := address(pub, compressed)
+	if !bytes.Equal(addrHash, xcrypto.DoubleSha256([]byte(addr))[:4]) {
+		return "", fmt.Errorf("bip38.passphrase.invalid")
+	}
+	return addr, nil
+}
+
+// decryptNonEC -- decrypts the key halves with the derived key of the passphrase and the address hash.
+func decryptNonEC(buf []byte, passphrase string, compressed bool) (*xcrypto.PrvKey, error) {
+	addrHash := buf[3:7]
+	derived, err := scrypt.Key([]byte(passphrase), addrHash, scryptN, scryptR, scryptP, 64)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(derived[32:])
+	if err != nil {
+		return nil, err
+	}
+
+	key := make([]byte, 0, xcrypto.PrvKeyBytesLen)
+	key = append(key, xorBytes(decryptBlock(block, buf[7:23]), derived[:16])...)
+	key = append(key, xorBytes(decryptBlock(block, buf[23:39]), derived[16:32])...)
+	return checkPrvKey(key, addrHash, compressed)
+}
+
+// decryptEC -- recovers the seedb from the encrypted parts, the key is passfactor*factorb.
+func decryptEC(buf []byte, passphrase string, compressed bool) (*xcrypto.PrvKey, error) {
+	flag := buf[2]
+	addrHash := buf[3:7]
+	ownerEntropy := buf[7:15]
+
+	passFactor, err := passFactor(passphrase, ownerEntropy, flag&flagLotSequence != 0)
+	if err != nil {
+		return nil, err
+	}
+	passPoint := xcrypto.PrvKeyFromBytes(passFactor).PubKey().SerializeCompressed()
+	derived, block, err := ecDerive(passPoint, addrHash, ownerEntropy)
+	if err != nil {
+		return nil, err
+	}
+
+	// encryptedpart2 decrypts to encryptedpart1[8:16] || seedb[16:24].
+	tail := xorBytes(decryptBlock(block, buf[23:39]), derived[16:32])
+	part1 := make([]byte, 0, 16)
+	part1 = append(part1, buf[15:23]...)
+	part1 = append(part1, tail[:8]...)
+	seed := make([]byte, 0, seedSize)
+	seed = append(seed, xorBytes(decryptBlock(block, part1), derived[:16])...)
+	seed = append(seed, tail[8:]...)
+
+	n := secp256k1.SECP256K1().Params().N
+	k := new(big.Int).SetBytes(xcrypto.DoubleSha256(seed))
+	k.Mul(k, new(big.Int).SetBytes(passFactor))
+	k.Mod(k, n)
+	key := make([]byte, xcrypto.PrvKeyBytesLen)
+	return checkPrvKey(k.FillBytes(key), addrHash, compressed)
+}
+
+// generateEncryptedKey -- generates the encrypted key and the confirmation code with the seedb.
+func generateEncryptedKey(intermediate string, compressed bool, seed []byte) (*GeneratedKey, error) {
+	buf, err := decode(intermediate)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) != intermediateCodeSize {
+		return nil, fmt.Errorf("bip38.intermediate.code.size[%v].invalid", len(buf))
+	}
+	for i, b := range magicIntermediate {
+		if buf[i] != b {
+			return nil, fmt.Errorf("bip38.intermediate.code.magic[%x].invalid", buf[:8])
+		}
+	}
+
+	var flag byte
+	switch buf[7] {
+	case magicLotSequence:
+		flag |= flagLotSequence
+	case magicNoLotSequence:
+	default:
+		return nil, fmt.Errorf("bip38.intermediate.code.magic[%x].invalid", buf[:8])
+	}
+	if compressed {
+		flag |= flagCompressed
+	}
+	ownerEntropy := buf[8:16]
+	passPoint, err := xcrypto.PubKeyFromBytes(buf[16:49])
+	if err != nil {
+		return nil, fmt.Errorf("bip38.passpoint.invalid")
+	}
+
+	factorb := xcrypto.DoubleSha256(seed)
+	if !validScalar(factorb) {
+		return nil, fmt.Errorf("bip38.factorb.invalid")
+	}
+	pub := scalarMult(passPoint, factorb)
+	addr := address(pub, compressed)
+	addrHash := xcrypto.DoubleSha256([]byte(addr))[:4]
+	derived, block, err := ecDerive(buf[16:49], addrHash, ownerEntropy)
+	if err != nil {
+		return nil, err
+	}
+
+	part1 := encryptBlock(block, xorBytes(seed[:16], derived[:16]))
+	tail := make([]byte, 0, 16)
+	tail = append(tail, part1[8:]...)
+	tail = append(tail, seed[16:]...)
+	part2 := encryptBlock(block, xorBytes(tail, derived[16:32]))
+
+	key := make([]byte, 0, encryptedKeySize)
+	key = append(key, prefixEC...)
+	key = append(key, flag)
+	key = append(key, addrHash...)
+	key = append(key, ownerEntropy...)
+	key = append(key, part1[:8]...)
+	key = append(key, part2...)
+
+	pointb := xcrypto.PrvKeyFromBytes(factorb).PubKey().SerializeCompressed()
+	code := make([]byte, 0, confirmationCodeSize)
+	code = append(code, magicConfirmation...)
+	code = append(code, flag)
+	code = append(code, addrHash...)
+	code = append(code, ownerEntropy...)
+	code = append(code, pointb[0]^(derived[63]&0x01))
+	code = append(code, encryptBlock(block, xorBytes(pointb[1:17], derived[:16]))...)
+	code = append(code, encryptBlock(block, xorBytes(pointb[17:33], derived[16:32]))...)
+
+	return &GeneratedKey{
+		EncryptedKey:     encode(key),
+		ConfirmationCode: encode(code),
+		Address:          addr,
+	}, nil
+}
+
+// intermediateCode -- encodes magic || ownerentropy || passpoint.
+func intermediateCode(passphrase string, ownerEntropy []byte, lotSequence bool) (string, error) {
+	passFactor, err := passFactor(passphrase, ownerEntropy, lotSequence)
+	if err != nil {
+		return "", err
+	}
+	magic := magicNoLotSequence
+	if lotSequence {
+		magic = magicLotSequence
+	}
+	buf := make([]byte, 0, intermediateCodeSize)
+	buf = append(buf, magicIntermediate...)
+	buf = append(buf, magic)
+	buf = append(buf, ownerEntropy...)
+	buf = append(buf, xcrypto.PrvKeyFromBytes(passFactor).PubKey().SerializeCompressed()...)
+	return encode(buf), nil
+}
+
+// passFactor -- returns the scrypt of the passphrase with the owner salt,
+// with the lot and sequence it is hashed again with the owner entropy.
+func passFactor(passphrase string, ownerEntropy []byte, lotSequence bool) ([]byte, error) {
+	salt := ownerEntropy
+	if lotSequence {
+		salt = ownerEntropy[:4]
+	}
+	factor, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	if lotSequence {
+		factor = xcrypto.DoubleSha256(append(factor, ownerEntropy...))
+	}
+	if !validScalar(factor) {
+		return nil, fmt.Errorf("bip38.passfactor.invalid")
+	}
+	return factor, nil
+}
+
+// ecDerive -- returns the derived halves of the passpoint and the AES cipher of the second half.
+func ecDerive(passPoint []byte, addrHash []byte, ownerEntropy []byte) ([]byte, cipher.Block, error) {
+	salt := make([]byte, 0, 12)
+	salt = append(salt, addrHash...)
+	salt = append(salt, ownerEntropy...)
+	derived, err := scrypt.Key(passPoint, salt, ecScryptN, ecScryptR, ecScryptP, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(derived[32:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return derived, block, nil
+}
+
+// checkPrvKey -- checks the decrypted key against the address hash, a mismatch is a wrong passphrase.
+func checkPrvKey(key []byte, addrHash []byte, compressed bool) (*xcrypto.PrvKey, error) {
+	if !validScalar(key) {
+		return nil, fmt.Errorf("bip38.passphrase.invalid")
+	}
+	prv := xcrypto.PrvKeyFromBytes(key)
+	if !bytes.Equal(addrHash, addressHash(prv.PubKey(), compressed)) {
+		return nil, fmt.Errorf("bip38.passphrase.invalid")
+	}
+	return prv, nil
+}
+
+// address -- the mainnet P2PKH address which BIP38 binds the key to.
+func address(pub *xcrypto.PubKey, compressed bool) string {
+	data := pub.SerializeUncompressed()
+	if compressed {
+		data = pub.SerializeCompressed()
+	}
+	return xbase.Base58CheckEncode(xcrypto.Hash160(data), network.MainNet.PubKeyHashAddrID)
+}
+
+// addressHash -- the first 4 bytes of the double sha256 of the address string.
+func addressHash(pub *xcrypto.PubKey, compressed bool) []byte {
+	return xcrypto.DoubleSha256([]byte(address(pub, compressed)))[:4]
+}
+
+// scalarMult -- returns k*P.
+func scalarMult(pub *xcrypto.PubKey, k []byte) *xcrypto.PubKey {
+	x, y := pub.Curve.ScalarMult(pub.X, pub.Y, k)
+	return &xcrypto.PubKey{Curve: pub.Curve, X: x, Y: y}
+}
+
+// validScalar -- returns whether k is in [1, N).
+func validScalar(k []byte) bool {
+	v := new(big.Int).SetBytes(k)
+	n := secp256k1.SECP256K1().Params().N
+	return v.Sign() > 0 && v.Cmp(n) < 0
+}
+
+func encode(buf []byte) string {
+	return xbase.Base58CheckEncode(buf[1:], buf[0])
+}
+
+func decode(s string) ([]byte, error) {
+	payload, version, err := xbase.Base58CheckDecode(s)
+	if err != nil {
+		return nil, fmt.Errorf("bip38.base58.decode.error[%v]", err)
+	}
+	return append([]byte{version}, payload...), nil
+}
+
+func encryptBlock(block cipher.Block, src []byte) []byte {
+	dst := make([]byte, aes.BlockSize)
+	block.Encrypt(dst, src)
+	return dst
+}
+
+func decryptBlock(block cipher.Block, src []byte) []byte {
+	dst := make([]byte, aes.BlockSize)
+	block.Decrypt(dst, src)
+	return dst
+}
+
+func xorBytes(a []byte, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
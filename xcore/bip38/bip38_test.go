@@ -0,0 +1,204 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package bip38
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	tests := []struct {
+		name       string
+		passphrase string
+		key        string
+		compressed bool
+		encrypted  string
+	}{
+		{
+			name:       "no.compression",
+			passphrase: "TestingOneTwoThree",
+			key:        "cbf4b9f70470856bb4f40f80b87edb90865997ffee6df315ab166d713af433a5",
+			encrypted:  "6PRVWUbkzzsbcVac2qwfssoUJAN1Xhrg6bNk8J7Nzm5H7kxEbn2Nh2ZoGg",
+		},
+		{
+			name:       "no.compression",
+			passphrase: "Satoshi",
+			key:        "09c2686880095b1a4c249ee3ac4eea8a014f11e6f986d0b5025ac1f39afbd9ae",
+			encrypted:  "6PRNFFkZc2NZ6dJqFfhRoFNMR9Lnyj7dYGrzdgXXVMXcxoKTePPX1dWByq",
+		},
+		{
+			name:       "compression",
+			passphrase: "TestingOneTwoThree",
+			key:        "cbf4b9f70470856bb4f40f80b87edb90865997ffee6df315ab166d713af433a5",
+			compressed: true,
+			encrypted:  "6PYNKZ1EAgYgmQfmNVamxyXVWHzK5s6DGhwP4J5o44cvXdoY7sRzhtpUeo",
+		},
+		{
+			name:       "compression",
+			passphrase: "Satoshi",
+			key:        "09c2686880095b1a4c249ee3ac4eea8a014f11e6f986d0b5025ac1f39afbd9ae",
+			compressed: true,
+			encrypted:  "6PYLtMnXvfG3oJde97zRyLYFZCYizPU5T3LwgdYJz1fRhh16bU7u6PPmY7",
+		},
+	}
+
+	for _, test := range tests {
+		key, _ := hex.DecodeString(test.key)
+		prv := xcrypto.PrvKeyFromBytes(key)
+		encrypted, err := Encrypt(prv, test.passphrase, test.compressed)
+		assert.Nil(t, err, test.name)
+		assert.Equal(t, test.encrypted, encrypted, test.name)
+
+		got, compressed, err := Decrypt(test.encrypted, test.passphrase)
+		assert.Nil(t, err, test.name)
+		assert.Equal(t, test.compressed, compressed, test.name)
+		assert.Equal(t, test.key, hex.EncodeToString(got.Serialize()), test.name)
+	}
+}
+
+func TestDecryptECMultiply(t *testing.T) {
+	tests := []struct {
+		name         string
+		passphrase   string
+		intermediate string
+		encrypted    string
+		confirmation string
+		address      string
+		key          string
+	}{
+		{
+			name:         "no.lot.sequence",
+			passphrase:   "TestingOneTwoThree",
+			intermediate: "passphrasepxFy57B9v8HtUsszJYKReoNDV6VHjUSGt8EVJmux9n1J3Ltf1gRxyDGXqnf9qm",
+			encrypted:    "6PfQu77ygVyJLZjfvMLyhLMQbYnu5uguoJJ4kMCLqWwPEdfpwANVS76gTX",
+			address:      "1PE6TQi6HTVNz5DLwB1LcpMBALubfuN2z2",
+			key:          "a43a940577f4e97f5c4d39eb14ff083a98187c64ea7c99ef7ce460833959a519",
+		},
+		{
+			name:         "no.lot.sequence",
+			passphrase:   "Satoshi",
+			intermediate: "passphraseoRDGAXTWzbp72eVbtUDdn1rwpgPUGjNZEc6CGBo8i5EC1FPW8wcnLdq4ThKzAS",
+			encrypted:    "6PfLGnQs6VZnrNpmVKfjotbnQuaJK4KZoPFrAjx1JMJUa1Ft8gnf5WxfKd",
+			address:      "1CqzrtZC6mXSAhoxtFwVjz8LtwLJjDYU3V",
+			key:          "c2c8036df268f498099350718c4a3ef3984d2be84618c2650f5171dcc5eb660a",
+		},
+		{
+			name:         "lot.sequence",
+			passphrase:   "MOLON LABE",
+			intermediate: "passphraseaB8feaLQDENqCgr4gKZpmf4VoaT6qdjJNJiv7fsKvjqavcJxvuR1hy25aTu5sX",
+			encrypted:    "6PgNBNNzDkKdhkT6uJntUXwwzQV8Rr2tZcbkDcuC9DZRsS6AtHts4Ypo1j",
+			confirmation: "cfrm38V8aXBn7JWA1ESmFMUn6erxeBGZGAxJPY4e36S9QWkzZKtaVqLNMgnifETYw7BPwWC9aPD",
+			address:      "1Jscj8ALrYu2y9TD8NrpvDBugPedmbj4Yh",
+			key:          "44ea95afbf138356a05ea32110dfd627232d0f2991ad221187be356f19fa8190",
+		},
+		{
+			name:         "lot.sequence",
+			passphrase:   "ΜΟΛΩΝ ΛΑΒΕ",
+			intermediate: "passphrased3z9rQJHSyBkNBwTRPkUGNVEVrUAcfAXDyRU1V28ie6hNFbqDwbFBvsTK7yWVK",
+			encrypted:    "6PgGWtx25kUg8QWvwuJAgorN6k9FbE25rv5dMRwu5SKMnfpfVe5mar2ngH",
+			confirmation: "cfrm38V8G4qq2ywYEFfWLD5Cc6msj9UwsG2Mj4Z6QdGJAFQpdatZLavkgRd1i4iBMdRngDqDs51",
+			address:      "1Lurmih3KruL4xDB5FmHof38yawNtP9oGf",
+			key:          "ca2759aa4adb0f96c414f36abeb8db59342985be9fa50faac228c8e7d90e3006",
+		},
+	}
+
+	for _, test := range tests {
+		prv, compressed, err := Decrypt(test.encrypted, test.passphrase)
+		assert.Nil(t, err, test.name)
+		assert.False(t, compressed, test.name)
+		assert.Equal(t, test.key, hex.EncodeToString(prv.Serialize()), test.name)
+		assert.Equal(t, test.address, address(prv.PubKey(), false), test.name)
+
+		// The intermediate code from the owner entropy of the vector.
+		buf, err := decode(test.intermediate)
+		assert.Nil(t, err, test.name)
+		intermediate, err := intermediateCode(test.passphrase, buf[8:16], buf[7] == magicLotSequence)
+		assert.Nil(t, err, test.name)
+		assert.Equal(t, test.intermediate, intermediate, test.name)
+
+		if test.confirmation != "" {
+			addr, err := VerifyConfirmationCode(test.confirmation, test.passphrase)
+			assert.Nil(t, err, test.name)
+			assert.Equal(t, test.address, addr, test.name)
+		}
+	}
+}
+
+func TestGenerateEncryptedKey(t *testing.T) {
+	passphrase := "TestingOneTwoThree"
+	codes := make([]string, 2)
+	var err error
+	codes[0], err = NewIntermediateCode(passphrase)
+	assert.Nil(t, err)
+	codes[1], err = NewIntermediateCodeWithLotSequence(passphrase, 263183, 1)
+	assert.Nil(t, err)
+
+	for _, code := range codes {
+		assert.True(t, strings.HasPrefix(code, "passphrase"))
+		for _, compressed := range []bool{false, true} {
+			gen, err := GenerateEncryptedKey(code, compressed)
+			assert.Nil(t, err)
+			assert.True(t, strings.HasPrefix(gen.EncryptedKey, "6P"))
+			assert.True(t, strings.HasPrefix(gen.ConfirmationCode, "cfrm38"))
+
+			addr, err := VerifyConfirmationCode(gen.ConfirmationCode, passphrase)
+			assert.Nil(t, err)
+			assert.Equal(t, gen.Address, addr)
+			_, err = VerifyConfirmationCode(gen.ConfirmationCode, "Satoshi")
+			assert.EqualError(t, err, "bip38.passphrase.invalid")
+
+			prv, got, err := Decrypt(gen.EncryptedKey, passphrase)
+			assert.Nil(t, err)
+			assert.Equal(t, compressed, got)
+			assert.Equal(t, gen.Address, address(prv.PubKey(), compressed))
+		}
+	}
+}
+
+func TestBip38Error(t *testing.T) {
+	// Wrong passphrase.
+	{
+		_, _, err := Decrypt("6PRVWUbkzzsbcVac2qwfssoUJAN1Xhrg6bNk8J7Nzm5H7kxEbn2Nh2ZoGg", "Satoshi")
+		assert.EqualError(t, err, "bip38.passphrase.invalid")
+		_, _, err = Decrypt("6PfLGnQs6VZnrNpmVKfjotbnQuaJK4KZoPFrAjx1JMJUa1Ft8gnf5WxfKd", "TestingOneTwoThree")
+		assert.EqualError(t, err, "bip38.passphrase.invalid")
+	}
+
+	// Encoding.
+	{
+		_, _, err := Decrypt("6PRVWUbkzzsbcVac2qwfssoUJAN1Xhrg6bNk8J7Nzm5H7kxEbn2Nh2ZoGh", "")
+		assert.EqualError(t, err, "bip38.base58.decode.error[checksum error]")
+		_, _, err = Decrypt(encode([]byte{0x01, 0x42, 0xc0}), "")
+		assert.EqualError(t, err, "bip38.encrypted.key.size[3].invalid")
+		buf := make([]byte, encryptedKeySize)
+		buf[0], buf[1], buf[2] = 0x01, 0x44, 0xc0
+		_, _, err = Decrypt(encode(buf), "")
+		assert.EqualError(t, err, "bip38.prefix[0144].invalid")
+		buf[1], buf[2] = 0x42, 0xc4
+		_, _, err = Decrypt(encode(buf), "")
+		assert.EqualError(t, err, "bip38.flag[c4].invalid")
+		buf[1], buf[2] = 0x43, 0xc0
+		_, _, err = Decrypt(encode(buf), "")
+		assert.EqualError(t, err, "bip38.flag[c0].invalid")
+	}
+
+	// Intermediate code.
+	{
+		_, err := NewIntermediateCodeWithLotSequence("", MaxLot+1, 0)
+		assert.EqualError(t, err, "bip38.lot[1048576].invalid")
+		_, err = NewIntermediateCodeWithLotSequence("", 0, MaxSequence+1)
+		assert.EqualError(t, err, "bip38.sequence[4096].invalid")
+		_, err = GenerateEncryptedKey("6PfLGnQs6VZnrNpmVKfjotbnQuaJK4KZoPFrAjx1JMJUa1Ft8gnf5WxfKd", false)
+		assert.EqualError(t, err, "bip38.intermediate.code.size[39].invalid")
+		_, err = VerifyConfirmationCode("6PfLGnQs6VZnrNpmVKfjotbnQuaJK4KZoPFrAjx1JMJUa1Ft8gnf5WxfKd", "")
+		assert.EqualError(t, err, "bip38.confirmation.code.size[39].invalid")
+	}
+}
@@ -5,12 +5,37 @@
 
 package xcore
 
+import (
+	"encoding/hex"
+)
+
 // Coin -- the coin output from the pre tx.
 type Coin struct {
 	txID   string
 	n      uint32
 	value  uint64
 	script string
+	weight int64
+}
+
+// TxID -- the id of the previous transaction.
+func (c *Coin) TxID() string {
+	return c.txID
+}
+
+// N -- the output index in the previous transaction.
+func (c *Coin) N() uint32 {
+	return c.n
+}
+
+// Value -- the output amount.
+func (c *Coin) Value() uint64 {
+	return c.value
+}
+
+// Script -- the hex locking script of the output.
+func (c *Coin) Script() string {
+	return c.script
 }
 
 // CoinBuilder --
@@ -35,6 +60,24 @@ func (b *CoinBuilder) AddOutput(txID string, n uint32, value uint64, script stri
 	return b
 }
 
+// AddOutputWithSpendWeight -- add output with the weight of the signed input spending it,
+// for the scripts whose spend weight can not be told from the locking script such as P2SH and P2WSH.
+func (b *CoinBuilder) AddOutputWithSpendWeight(txID string, n uint32, value uint64, script string, weight int64) *CoinBuilder {
+	b.AddOutput(txID, n, value, script)
+	b.coins[len(b.coins)-1].weight = weight
+	return b
+}
+
+// AddTransactionOutput -- add the n-th output of the tx, such as an output of an unconfirmed parent.
+// The n out of the outputs range is skipped.
+func (b *CoinBuilder) AddTransactionOutput(tx *Transaction, n uint32) *CoinBuilder {
+	if int(n) >= len(tx.outputs) {
+		return b
+	}
+	out := tx.outputs[n]
+	return b.AddOutput(tx.ID(), n, out.Value, hex.EncodeToString(out.Script))
+}
+
 // ToCoins -- returns the coin slice.
 func (b *CoinBuilder) ToCoins() []*Coin {
 	return b.coins
@@ -0,0 +1,443 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"encoding/hex"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/keyfuse/tokucore/xerror"
+)
+
+const (
+	// DefaultLongTermFeeRate -- the fee rate(sat/vB) expected to spend the change later.
+	DefaultLongTermFeeRate = 10
+
+	// DustRelayFeeRate -- the fee rate(sat/vB) the dust threshold of an output is computed at.
+	DustRelayFeeRate = 3
+
+	// bnbMaxTries -- the search limit of the Branch-and-Bound.
+	bnbMaxTries = 100000
+
+	// knapsackIterations -- the rounds of the stochastic approximation.
+	knapsackIterations = 1000
+)
+
+// CoinSelectionStrategy -- the algorithm to pick the inputs.
+type CoinSelectionStrategy int
+
+const (
+	// SelectBranchAndBound -- searches the input set whose value lands in the changeless window,
+	// the set with the lowest waste wins.
+	SelectBranchAndBound CoinSelectionStrategy = iota
+
+	// SelectKnapsack -- the stochastic subset sum approximation with change.
+	SelectKnapsack
+
+	// SelectLargestFirst -- adds the coins from the largest until the target is reached.
+	SelectLargestFirst
+
+	// SelectSingleRandomDraw -- adds the coins at random until the target with change is reached.
+	SelectSingleRandomDraw
+)
+
+var coinSelectionStrategyNames = map[CoinSelectionStrategy]string{
+	SelectBranchAndBound:   "bnb",
+	SelectKnapsack:         "knapsack",
+	SelectLargestFirst:     "largest-first",
+	SelectSingleRandomDraw: "srd",
+}
+
+// String -- the name of the strategy.
+func (s CoinSelectionStrategy) String() string {
+	if name, ok := coinSelectionStrategyNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// CoinSelectionParams -- the target and the fee parameters of the selection, the weights are in weight units.
+type CoinSelectionParams struct {
+	Target            uint64 // The sum of the recipient outputs.
+	FeeRate           uint64 // The fee rate(sat/vB) of the transaction.
+	LongTermFeeRate   uint64 // The fee rate(sat/vB) expected to spend the inputs later.
+	BaseWeight        int64  // The transaction weight without inputs and change.
+	ChangeWeight      int64  // The weight of the change output.
+	ChangeSpendWeight int64  // The weight of the input spending the change later.
+	MinChange         uint64 // The change below it is dropped to the fees.
+}
+
+// NewCoinSelectionParams -- creates the params paying the outputs at the fee rate with change to the script.
+// The base weight counts the segwit marker and flag, the change threshold is the dust limit of the change.
+func NewCoinSelectionParams(outputs []*TxOut, changeScript []byte, feeRate uint64) (*CoinSelectionParams, error) {
+	changeSpendWeight, err := scriptSpendWeight(changeScript)
+	if err != nil {
+		return nil, err
+	}
+
+	var target uint64
+	base := 4 + 4 + xbase.VarIntSerializeSize(1) + xbase.VarIntSerializeSize(uint64(len(outputs)+1))
+	for _, out := range outputs {
+		target += out.Value
+		base += outputSize(out.Script)
+	}
+	changeWeight := int64(outputSize(changeScript) * witnessScaleFactor)
+	return &CoinSelectionParams{
+		Target:            target,
+		FeeRate:           feeRate,
+		LongTermFeeRate:   DefaultLongTermFeeRate,
+		BaseWeight:        int64(base*witnessScaleFactor + 2),
+		ChangeWeight:      changeWeight,
+		ChangeSpendWeight: changeSpendWeight,
+		MinChange:         uint64(dustThreshold(changeScript)),
+	}, nil
+}
+
+// costOfChange -- the fee of creating the change now and spending it later.
+func (p *CoinSelectionParams) costOfChange() int64 {
+	return EstimateFeesForWeight(p.ChangeWeight, p.FeeRate) + EstimateFeesForWeight(p.ChangeSpendWeight, p.LongTermFeeRate)
+}
+
+// CoinSelection -- the selected coins and the metrics of the selection.
+type CoinSelection struct {
+	Strategy   CoinSelectionStrategy
+	Coins      []*Coin
+	InputValue uint64
+	Fees       uint64
+	Change     uint64 // Zero if changeless.
+	Weight     int64  // The estimated weight of the signed transaction.
+
+	// Waste -- the fees paid now over the long term fee rate for the inputs,
+	// plus the cost of the change or the excess dropped to the fees without change.
+	// The lower the better.
+	Waste int64
+}
+
+// selectionCoin -- a coin with its spend weight and fees.
+type selectionCoin struct {
+	coin        *Coin
+	weight      int64
+	fee         int64
+	longTermFee int64
+	effective   int64
+}
+
+// SelectCoins -- selects the coins paying the params target by the strategy.
+func SelectCoins(coins []*Coin, params *CoinSelectionParams, strategy CoinSelectionStrategy) (*CoinSelection, error) {
+	pool := make([]*selectionCoin, 0, len(coins))
+	var available int64
+	for _, coin := range coins {
+		weight, err := coinSpendWeight(coin)
+		if err != nil {
+			return nil, err
+		}
+		sc := &selectionCoin{
+			coin:        coin,
+			weight:      weight,
+			fee:         EstimateFeesForWeight(weight, params.FeeRate),
+			longTermFee: EstimateFeesForWeight(weight, params.LongTermFeeRate),
+		}
+		sc.effective = int64(coin.value) - sc.fee
+		// Coins costing more than their value are never selected.
+		if sc.effective > 0 {
+			pool = append(pool, sc)
+			available += sc.effective
+		}
+	}
+
+	target := int64(params.Target) + EstimateFeesForWeight(params.BaseWeight, params.FeeRate)
+	if available < target {
+		return nil, xerror.NewError(Errors, ER_COIN_SELECTION_AMOUNT_NOT_ENOUGH, available, target)
+	}
+
+	var selected []*selectionCoin
+	allowChange := true
+	switch strategy {
+	case SelectBranchAndBound:
+		selected = selectBranchAndBound(pool, target, params.costOfChange(), params.FeeRate > params.LongTermFeeRate)
+		allowChange = false
+	case SelectKnapsack:
+		selected = selectKnapsack(pool, target, int64(params.MinChange)+EstimateFeesForWeight(params.ChangeWeight, params.FeeRate))
+	case SelectLargestFirst:
+		selected = selectLargestFirst(pool, target)
+	case SelectSingleRandomDraw:
+		selected = selectSingleRandomDraw(pool, target+int64(params.MinChange)+EstimateFeesForWeight(params.ChangeWeight, params.FeeRate))
+	}
+	if selected == nil {
+		return nil, xerror.NewError(Errors, ER_COIN_SELECTION_NO_SOLUTION, strategy)
+	}
+	return newCoinSelection(strategy, selected, params, allowChange), nil
+}
+
+// SelectCoinsMinWaste -- runs the strategies (all if none) and returns the selection with the lowest waste.
+func SelectCoinsMinWaste(coins []*Coin, params *CoinSelectionParams, strategies ...CoinSelectionStrategy) (*CoinSelection, error) {
+	if len(strategies) == 0 {
+		strategies = []CoinSelectionStrategy{SelectBranchAndBound, SelectKnapsack, SelectLargestFirst, SelectSingleRandomDraw}
+	}
+
+	var best *CoinSelection
+	var lastErr error
+	for _, strategy := range strategies {
+		selection, err := SelectCoins(coins, params, strategy)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if best == nil || selection.Waste < best.Waste {
+			best = selection
+		}
+	}
+	if best == nil {
+		return nil, lastErr
+	}
+	return best, nil
+}
+
+// newCoinSelection -- computes the fees, change and waste of the selected coins.
+func newCoinSelection(strategy CoinSelectionStrategy, selected []*selectionCoin, params *CoinSelectionParams, allowChange bool) *CoinSelection {
+	selection := &CoinSelection{Strategy: strategy}
+
+	var inputWaste int64
+	weight := params.BaseWeight
+	for _, sc := range selected {
+		selection.Coins = append(selection.Coins, sc.coin)
+		selection.InputValue += sc.coin.value
+		weight += sc.weight
+		inputWaste += sc.fee - sc.longTermFee
+	}
+
+	excess := int64(selection.InputValue) - int64(params.Target) - EstimateFeesForWeight(weight, params.FeeRate)
+	if allowChange {
+		changeFee := EstimateFeesForWeight(weight+params.ChangeWeight, params.FeeRate)
+		change := int64(selection.InputValue) - int64(params.Target) - changeFee
+		if change > 0 && change >= int64(params.MinChange) {
+			selection.Change = uint64(change)
+			selection.Fees = uint64(changeFee)
+			selection.Weight = weight + params.ChangeWeight
+			selection.Waste = inputWaste + params.costOfChange()
+			return selection
+		}
+	}
+	selection.Fees = selection.InputValue - params.Target
+	selection.Weight = weight
+	selection.Waste = inputWaste + excess
+	return selection
+}
+
+// selectBranchAndBound -- the depth-first search of the coins whose effective value is in [target, target+costOfChange].
+func selectBranchAndBound(pool []*selectionCoin, target int64, costOfChange int64, wasteGrows bool) []*selectionCoin {
+	coins := make([]*selectionCoin, len(pool))
+	copy(coins, pool)
+	sort.SliceStable(coins, func(i, j int) bool { return coins[i].effective > coins[j].effective })
+
+	remaining := make([]int64, len(coins)+1)
+	for i := len(coins) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + coins[i].effective
+	}
+
+	var best []*selectionCoin
+	var selected []*selectionCoin
+	bestWaste := int64(math.MaxInt64)
+	tries := 0
+
+	var search func(i int, value int64, waste int64)
+	search = func(i int, value int64, waste int64) {
+		if tries >= bnbMaxTries {
+			return
+		}
+		tries++
+
+		if value > target+costOfChange {
+			return
+		}
+		// Each more input adds waste at a fee rate above the long term one.
+		if wasteGrows && waste > bestWaste {
+			return
+		}
+		if value >= target {
+			if waste+value-target <= bestWaste {
+				bestWaste = waste + value - target
+				best = append([]*selectionCoin(nil), selected...)
+			}
+			return
+		}
+		if i == len(coins) || value+remaining[i] < target {
+			return
+		}
+
+		coin := coins[i]
+		selected = append(selected, coin)
+		search(i+1, value+coin.effective, waste+coin.fee-coin.longTermFee)
+		selected = selected[:len(selected)-1]
+
+		// Omitting the coin, the equivalent ones behind it lead to the same sets.
+		j := i + 1
+		for j < len(coins) && coins[j].effective == coin.effective && coins[j].fee == coin.fee {
+			j++
+		}
+		search(j, value, waste)
+	}
+	search(0, 0, 0)
+	return best
+}
+
+// selectKnapsack -- the exact match, or the stochastic subset reaching target+changeTarget,
+// or the smallest coin larger than it.
+func selectKnapsack(pool []*selectionCoin, target int64, changeTarget int64) []*selectionCoin {
+	coins := make([]*selectionCoin, len(pool))
+	copy(coins, pool)
+	rand.Shuffle(len(coins), func(i, j int) { coins[i], coins[j] = coins[j], coins[i] })
+
+	var smaller []*selectionCoin
+	var lowestLarger *selectionCoin
+	var smallerTotal int64
+	for _, coin := range coins {
+		switch {
+		case coin.effective == target:
+			return []*selectionCoin{coin}
+		case coin.effective < target+changeTarget:
+			smaller = append(smaller, coin)
+			smallerTotal += coin.effective
+		case lowestLarger == nil || coin.effective < lowestLarger.effective:
+			lowestLarger = coin
+		}
+	}
+
+	if smallerTotal == target {
+		return smaller
+	}
+	if smallerTotal < target {
+		if lowestLarger == nil {
+			return nil
+		}
+		return []*selectionCoin{lowestLarger}
+	}
+
+	sort.SliceStable(smaller, func(i, j int) bool { return smaller[i].effective > smaller[j].effective })
+	best, bestValue := approximateBestSubset(smaller, smallerTotal, target)
+	if bestValue != target && smallerTotal >= target+changeTarget {
+		best, bestValue = approximateBestSubset(smaller, smallerTotal, target+changeTarget)
+	}
+
+	// The larger coin is preferred if the subset makes change too small or spends more.
+	if lowestLarger != nil && ((bestValue != target && bestValue < target+changeTarget) || lowestLarger.effective <= bestValue) {
+		return []*selectionCoin{lowestLarger}
+	}
+	return best
+}
+
+// approximateBestSubset -- the random subsets of the coins reaching the target with the least value over it.
+func approximateBestSubset(coins []*selectionCoin, total int64, target int64) ([]*selectionCoin, int64) {
+	bestIncluded := make([]bool, len(coins))
+	for i := range bestIncluded {
+		bestIncluded[i] = true
+	}
+	bestValue := total
+
+	included := make([]bool, len(coins))
+	for rep := 0; rep < knapsackIterations && bestValue != target; rep++ {
+		for i := range included {
+			included[i] = false
+		}
+		var value int64
+		reached := false
+		for pass := 0; pass < 2 && !reached; pass++ {
+			for i, coin := range coins {
+				// The first pass picks at random, the second one fills with the rest.
+				if (pass == 0 && rand.Intn(2) == 1) || (pass == 1 && !included[i]) {
+					value += coin.effective
+					included[i] = true
+					if value >= target {
+						reached = true
+						if value < bestValue {
+							bestValue = value
+							copy(bestIncluded, included)
+						}
+						value -= coin.effective
+						included[i] = false
+					}
+				}
+			}
+		}
+	}
+
+	var best []*selectionCoin
+	for i, coin := range coins {
+		if bestIncluded[i] {
+			best = append(best, coin)
+		}
+	}
+	return best, bestValue
+}
+
+// selectLargestFirst -- adds the coins by the effective value descending until the target is reached.
+func selectLargestFirst(pool []*selectionCoin, target int64) []*selectionCoin {
+	coins := make([]*selectionCoin, len(pool))
+	copy(coins, pool)
+	sort.SliceStable(coins, func(i, j int) bool { return coins[i].effective > coins[j].effective })
+
+	var value int64
+	for i, coin := range coins {
+		value += coin.effective
+		if value >= target {
+			return coins[:i+1]
+		}
+	}
+	return nil
+}
+
+// selectSingleRandomDraw -- adds the coins in random order until the target is reached.
+func selectSingleRandomDraw(pool []*selectionCoin, target int64) []*selectionCoin {
+	coins := make([]*selectionCoin, len(pool))
+	copy(coins, pool)
+	rand.Shuffle(len(coins), func(i, j int) { coins[i], coins[j] = coins[j], coins[i] })
+
+	var value int64
+	for i, coin := range coins {
+		value += coin.effective
+		if value >= target {
+			return coins[:i+1]
+		}
+	}
+	return nil
+}
+
+// coinSpendWeight -- the spend weight set on the coin, or the one of its script type.
+func coinSpendWeight(coin *Coin) (int64, error) {
+	if coin.weight > 0 {
+		return coin.weight, nil
+	}
+	script, err := hex.DecodeString(coin.script)
+	if err != nil {
+		return 0, err
+	}
+	return scriptSpendWeight(script)
+}
+
+// scriptSpendWeight -- the weight of the input spending the single key locking script.
+func scriptSpendWeight(script []byte) (int64, error) {
+	instance, err := ParseLockingScript(script)
+	if err != nil {
+		return 0, err
+	}
+	switch instance.(type) {
+	case *PayToPubKeyHashScript:
+		return SpendWeightP2PKH, nil
+	case *PayToWitnessV0PubKeyHashScript:
+		return SpendWeightP2WPKH, nil
+	case *PayToTaprootScript:
+		return SpendWeightP2TR, nil
+	}
+	return 0, xerror.NewError(Errors, ER_COIN_SELECTION_SPEND_WEIGHT_UNKNOWN, hex.EncodeToString(script))
+}
+
+// outputSize -- the serialized size of the output.
+func outputSize(script []byte) int {
+	return 8 + xbase.VarIntSerializeSize(uint64(len(script))) + len(script)
+}
@@ -0,0 +1,211 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/keyfuse/tokucore/xcore/bip32"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoinSelection(t *testing.T) {
+	key := bip32.NewHDKey([]byte("this.is.coin.selection.seed."))
+	addr := NewPayToWitnessV0PubKeyHashAddress(key.PublicKey().Hash160())
+	script, err := addr.LockingScript()
+	assert.Nil(t, err)
+
+	params, err := NewCoinSelectionParams([]*TxOut{NewTxOut(100000, script)}, script, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(100000), params.Target)
+	assert.Equal(t, int64(166), params.BaseWeight)
+	assert.Equal(t, int64(124), params.ChangeWeight)
+	assert.Equal(t, int64(SpendWeightP2WPKH), params.ChangeSpendWeight)
+	assert.Equal(t, uint64(297), params.MinChange)
+
+	// The 100550 coin pays the target and the fees(base 42vB + input 68vB at 5sat/vB) exactly.
+	builder := NewCoinBuilder()
+	for i, value := range []uint64{50000, 70000, 100550, 200000, 100} {
+		builder.AddOutput(fmt.Sprintf("%064x", i+1), 0, value, fmt.Sprintf("%x", script))
+	}
+	coins := builder.ToCoins()
+
+	// Branch-and-Bound.
+	{
+		selection, err := SelectCoins(coins, params, SelectBranchAndBound)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(selection.Coins))
+		assert.Equal(t, uint64(100550), selection.InputValue)
+		assert.Equal(t, uint64(550), selection.Fees)
+		assert.Equal(t, uint64(0), selection.Change)
+		assert.Equal(t, int64(166+SpendWeightP2WPKH), selection.Weight)
+		assert.Equal(t, int64(340-680), selection.Waste)
+	}
+
+	// Largest-first.
+	{
+		selection, err := SelectCoins(coins, params, SelectLargestFirst)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(selection.Coins))
+		assert.Equal(t, uint64(200000), selection.InputValue)
+		assert.Equal(t, uint64(705), selection.Fees)
+		assert.Equal(t, uint64(99295), selection.Change)
+		assert.Equal(t, int64(340-680+155+680), selection.Waste)
+	}
+
+	// All strategies pay the target and the fees.
+	for _, strategy := range []CoinSelectionStrategy{SelectBranchAndBound, SelectKnapsack, SelectLargestFirst, SelectSingleRandomDraw} {
+		for i := 0; i < 20; i++ {
+			selection, err := SelectCoins(coins, params, strategy)
+			assert.Nil(t, err, strategy.String())
+			assert.Equal(t, selection.InputValue, params.Target+selection.Fees+selection.Change, strategy.String())
+			assert.True(t, selection.Fees >= uint64(EstimateFeesForWeight(selection.Weight, params.FeeRate)), strategy.String())
+			assert.True(t, selection.Change == 0 || selection.Change >= params.MinChange, strategy.String())
+			if strategy == SelectSingleRandomDraw {
+				assert.True(t, selection.Change > 0, strategy.String())
+			}
+			// The 100 coin costs more than its value.
+			for _, coin := range selection.Coins {
+				assert.NotEqual(t, uint64(100), coin.Value(), strategy.String())
+			}
+		}
+	}
+
+	// Lowest waste.
+	{
+		selection, err := SelectCoinsMinWaste(coins, params)
+		assert.Nil(t, err)
+		assert.Equal(t, SelectBranchAndBound, selection.Strategy)
+	}
+}
+
+func TestCoinSelectionKnapsack(t *testing.T) {
+	key := bip32.NewHDKey([]byte("this.is.coin.selection.seed."))
+	script, err := NewPayToPubKeyHashAddress(key.PublicKey().Hash160()).LockingScript()
+	assert.Nil(t, err)
+
+	params, err := NewCoinSelectionParams([]*TxOut{NewTxOut(250000, script)}, script, 1)
+	assert.Nil(t, err)
+
+	// No single coin is large enough.
+	builder := NewCoinBuilder()
+	for i := 0; i < 10; i++ {
+		builder.AddOutput(fmt.Sprintf("%064x", i+1), 0, 40000, fmt.Sprintf("%x", script))
+	}
+	coins := builder.ToCoins()
+
+	selection, err := SelectCoins(coins, params, SelectKnapsack)
+	assert.Nil(t, err)
+	assert.Equal(t, 7, len(selection.Coins))
+	assert.Equal(t, uint64(280000), selection.InputValue)
+	assert.Equal(t, selection.InputValue, params.Target+selection.Fees+selection.Change)
+	assert.True(t, selection.Change >= params.MinChange)
+}
+
+func TestCoinSelectionTransactionBuilder(t *testing.T) {
+	key := bip32.NewHDKey([]byte("this.is.coin.selection.seed."))
+	addr := NewPayToWitnessV0PubKeyHashAddress(key.PublicKey().Hash160())
+	script, err := addr.LockingScript()
+	assert.Nil(t, err)
+
+	builder := NewCoinBuilder()
+	for i, value := range []uint64{30000, 45000, 60000, 80000} {
+		builder.AddOutput(fmt.Sprintf("%064x", i+1), uint32(i), value, fmt.Sprintf("%x", script))
+	}
+	coins := builder.ToCoins()
+
+	params, err := NewCoinSelectionParams([]*TxOut{NewTxOut(100000, script)}, script, 2)
+	assert.Nil(t, err)
+	selection, err := SelectCoins(coins, params, SelectLargestFirst)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(selection.Coins))
+
+	tx, err := NewTransactionBuilder().
+		To(addr, 100000).
+		AddCoinSelection(selection, key.PrivateKey()).
+		SetChange(addr).
+		Sign().
+		BuildTransaction()
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Verify())
+	assert.Equal(t, int(selection.Fees), tx.Fees())
+
+	// The estimate is an upper bound of the weight.
+	assert.True(t, int64(tx.Weight()) <= selection.Weight)
+	assert.True(t, int64(tx.Weight()) > selection.Weight-8)
+}
+
+func TestCoinSelectionTransactionBuilderKeys(t *testing.T) {
+	aliceKey := bip32.NewHDKey([]byte("this.is.alice.seed.at.2018"))
+	alice := NewPayToWitnessV0PubKeyHashAddress(aliceKey.PublicKey().Hash160())
+	aliceScript, err := alice.LockingScript()
+	assert.Nil(t, err)
+	bobKey := bip32.NewHDKey([]byte("this.is.bob.seed.at.2018"))
+	bob := NewPayToPubKeyHashAddress(bobKey.PublicKey().Hash160())
+	bobScript, err := bob.LockingScript()
+	assert.Nil(t, err)
+
+	// The wallet pool has one key per address.
+	coins := NewCoinBuilder().
+		AddOutput(fmt.Sprintf("%064x", 1), 0, 60000, fmt.Sprintf("%x", aliceScript)).
+		AddOutput(fmt.Sprintf("%064x", 2), 0, 60000, fmt.Sprintf("%x", bobScript)).
+		ToCoins()
+
+	params, err := NewCoinSelectionParams([]*TxOut{NewTxOut(100000, aliceScript)}, aliceScript, 2)
+	assert.Nil(t, err)
+	selection, err := SelectCoins(coins, params, SelectLargestFirst)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(selection.Coins))
+
+	tx, err := NewTransactionBuilder().
+		To(alice, 100000).
+		AddCoinSelection(selection, aliceKey.PrivateKey(), bobKey.PrivateKey()).
+		SetChange(alice).
+		Sign().
+		BuildTransaction()
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Verify())
+	assert.Equal(t, int(selection.Fees), tx.Fees())
+}
+
+func TestCoinSelectionError(t *testing.T) {
+	key := bip32.NewHDKey([]byte("this.is.coin.selection.seed."))
+	script, err := NewPayToWitnessV0PubKeyHashAddress(key.PublicKey().Hash160()).LockingScript()
+	assert.Nil(t, err)
+	p2sh, err := NewPayToScriptHashAddress(key.PublicKey().Hash160()).LockingScript()
+	assert.Nil(t, err)
+
+	params, err := NewCoinSelectionParams([]*TxOut{NewTxOut(100000, script)}, script, 5)
+	assert.Nil(t, err)
+
+	{
+		coins := NewCoinBuilder().AddOutput(fmt.Sprintf("%064x", 1), 0, 50000, fmt.Sprintf("%x", script)).ToCoins()
+		_, err := SelectCoins(coins, params, SelectLargestFirst)
+		assert.EqualError(t, err, "coin.selection.available[49660].not.enough.for.target[100210] (errno 5601) (state TCS00)")
+	}
+
+	{
+		coins := NewCoinBuilder().AddOutput(fmt.Sprintf("%064x", 1), 0, 500000, fmt.Sprintf("%x", script)).ToCoins()
+		_, err := SelectCoins(coins, params, SelectBranchAndBound)
+		assert.EqualError(t, err, "coin.selection.strategy[bnb].no.solution (errno 5602) (state TCS00)")
+
+		selection, err := SelectCoinsMinWaste(coins, params)
+		assert.Nil(t, err)
+		assert.NotEqual(t, SelectBranchAndBound, selection.Strategy)
+	}
+
+	{
+		coins := NewCoinBuilder().AddOutput(fmt.Sprintf("%064x", 1), 0, 500000, fmt.Sprintf("%x", p2sh)).ToCoins()
+		_, err := SelectCoins(coins, params, SelectLargestFirst)
+		assert.EqualError(t, err, fmt.Sprintf("coin.selection.script[%x].spend.weight.unknown (errno 5603) (state TCS00)", p2sh))
+
+		coins = NewCoinBuilder().AddOutputWithSpendWeight(fmt.Sprintf("%064x", 1), 0, 500000, fmt.Sprintf("%x", p2sh), SpendWeightP2WPKHInP2SH).ToCoins()
+		selection, err := SelectCoins(coins, params, SelectLargestFirst)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(params.BaseWeight+SpendWeightP2WPKHInP2SH+params.ChangeWeight), selection.Weight)
+	}
+}
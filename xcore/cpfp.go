@@ -0,0 +1,99 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+const (
+	// MinRelayFeeRate -- the fee rate(sat/vB) a transaction must pay on its own to be relayed.
+	MinRelayFeeRate = 1
+)
+
+// PackageTransaction -- an unconfirmed transaction of a package with its fees and virtual size.
+type PackageTransaction struct {
+	TxID  string
+	Fees  int64
+	Vsize int64
+}
+
+// NewPackageTransaction -- creates the package entry of the tx, the values of its inputs must be set.
+func NewPackageTransaction(tx *Transaction) *PackageTransaction {
+	return &PackageTransaction{
+		TxID:  tx.ID(),
+		Fees:  int64(tx.Fees()),
+		Vsize: int64(tx.Vsize()),
+	}
+}
+
+// Package -- the unconfirmed ancestors of a child transaction, relayed and mined together with it.
+type Package struct {
+	txs []*PackageTransaction
+}
+
+// NewPackage -- creates the package of the unconfirmed parents.
+func NewPackage(txs ...*PackageTransaction) *Package {
+	return &Package{txs: txs}
+}
+
+// Add -- adds the unconfirmed transaction by its fees and virtual size, such as the mempool entry of a node.
+func (p *Package) Add(txid string, fees int64, vsize int64) *Package {
+	p.txs = append(p.txs, &PackageTransaction{TxID: txid, Fees: fees, Vsize: vsize})
+	return p
+}
+
+// AddTransaction -- adds the unconfirmed tx, the values of its inputs must be set.
+func (p *Package) AddTransaction(tx *Transaction) *Package {
+	p.txs = append(p.txs, NewPackageTransaction(tx))
+	return p
+}
+
+// Transactions -- returns the transactions of the package.
+func (p *Package) Transactions() []*PackageTransaction {
+	return p.txs
+}
+
+// Fees -- returns the total fees of the package.
+func (p *Package) Fees() int64 {
+	var fees int64
+	for _, tx := range p.txs {
+		fees += tx.Fees
+	}
+	return fees
+}
+
+// Vsize -- returns the total virtual size of the package.
+func (p *Package) Vsize() int64 {
+	var vsize int64
+	for _, tx := range p.txs {
+		vsize += tx.Vsize
+	}
+	return vsize
+}
+
+// FeeRate -- returns the ancestor fee rate in sat/vB, the total fees over the total virtual size.
+func (p *Package) FeeRate() float64 {
+	vsize := p.Vsize()
+	if vsize == 0 {
+		return 0
+	}
+	return float64(p.Fees()) / float64(vsize)
+}
+
+// ChildFees -- returns the fees of the child of the weight to bring the package with it to the fee rate in sat/vB.
+// The child pays at least the minimum relay fees of its own size.
+func (p *Package) ChildFees(childWeight int64, satPerVByte uint64) int64 {
+	childVsize := EstimateVsize(childWeight)
+	fees := (p.Vsize()+childVsize)*int64(satPerVByte) - p.Fees()
+	if min := childVsize * MinRelayFeeRate; fees < min {
+		fees = min
+	}
+	return fees
+}
+
+// WithChild -- returns the package including the child of the fees and virtual size.
+func (p *Package) WithChild(child *PackageTransaction) *Package {
+	txs := make([]*PackageTransaction, len(p.txs), len(p.txs)+1)
+	copy(txs, p.txs)
+	return NewPackage(append(txs, child)...)
+}
@@ -0,0 +1,96 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/keyfuse/tokucore/xcore/bip32"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackage(t *testing.T) {
+	pkg := NewPackage().
+		Add("a", 200, 200).
+		Add("b", 300, 100)
+	assert.Equal(t, 2, len(pkg.Transactions()))
+	assert.Equal(t, int64(500), pkg.Fees())
+	assert.Equal(t, int64(300), pkg.Vsize())
+	assert.InDelta(t, 1.6667, pkg.FeeRate(), 0.0001)
+	assert.Equal(t, float64(0), NewPackage().FeeRate())
+
+	// (300 + 110) * 10 - 500.
+	assert.Equal(t, int64(3600), pkg.ChildFees(440, 10))
+	// The package already pays 1 sat/vB, the child pays its own relay fees.
+	assert.Equal(t, int64(110), pkg.ChildFees(440, 1))
+
+	withChild := pkg.WithChild(&PackageTransaction{TxID: "c", Fees: 3600, Vsize: 110})
+	assert.Equal(t, 2, len(pkg.Transactions()))
+	assert.Equal(t, float64(10), withChild.FeeRate())
+}
+
+func TestPackageChildTransaction(t *testing.T) {
+	aliceKey := bip32.NewHDKey([]byte("this.is.alice.seed.at.2018"))
+	alice := NewPayToWitnessV0PubKeyHashAddress(aliceKey.PublicKey().Hash160())
+	aliceScript, _ := alice.LockingScript()
+	bobKey := bip32.NewHDKey([]byte("this.is.bob.seed.at.2018"))
+	bob := NewPayToWitnessV0PubKeyHashAddress(bobKey.PublicKey().Hash160())
+
+	// The stuck parent pays bob at 1 sat/vB.
+	coin := NewCoinBuilder().AddOutput(fmt.Sprintf("%064x", 1), 0, 100000, fmt.Sprintf("%x", aliceScript)).ToCoins()[0]
+	parent, err := NewTransactionBuilder().
+		AddCoin(coin).
+		AddKeys(aliceKey.PrivateKey()).
+		To(bob, 50000).
+		Then().
+		SetChange(alice).
+		SetFeeRate(1).
+		Sign().
+		BuildTransaction()
+	assert.Nil(t, err)
+	assert.Nil(t, parent.Verify())
+
+	// The output out of range is skipped.
+	assert.Equal(t, 0, len(NewCoinBuilder().AddTransactionOutput(parent, 2).ToCoins()))
+	assert.Equal(t, 1, len(NewCoinBuilder().AddTransactionOutput(parent, 2).AddTransactionOutput(parent, 1).ToCoins()))
+
+	// Bob spends the output and pays for the parent.
+	parents := NewPackage().AddTransaction(parent)
+	assert.Equal(t, int64(parent.Fees()), parents.Fees())
+	assert.Equal(t, int64(parent.Vsize()), parents.Vsize())
+
+	child, err := NewTransactionBuilder().
+		AddCoin(NewCoinBuilder().AddTransactionOutput(parent, 0).ToCoins()[0]).
+		AddKeys(bobKey.PrivateKey()).
+		To(bob, 40000).
+		Then().
+		SetChange(bob).
+		SetFeeRate(20).
+		SetParents(parents).
+		Sign().
+		BuildTransaction()
+	assert.Nil(t, err)
+	assert.Nil(t, child.Verify())
+	assert.Equal(t, parent.Hash(), child.Inputs()[0].Hash)
+
+	pkg := parents.WithChild(NewPackageTransaction(child))
+	assert.True(t, pkg.FeeRate() >= 20)
+	assert.True(t, pkg.FeeRate() < 21)
+	assert.True(t, float64(child.Fees())/float64(child.Vsize()) > 20)
+
+	// The fee rate is required.
+	_, err = NewTransactionBuilder().
+		AddCoin(NewCoinBuilder().AddTransactionOutput(parent, 0).ToCoins()[0]).
+		AddKeys(bobKey.PrivateKey()).
+		To(bob, 40000).
+		Then().
+		SetChange(bob).
+		SetParents(parents).
+		Sign().
+		BuildTransaction()
+	assert.EqualError(t, err, "transaction.builder.package.fee.rate.is.empty (errno 5108) (state TTB00)")
+}
@@ -12,6 +12,14 @@ import (
 // Error type.
 const (
 	ER_KEY_SIGNATURE_VERIFY_FAILED                 int = 2101
+	ER_WIF_FORMAT_MALFORMED                        int = 2201
+	ER_WIF_SIZE_MALFORMED                          int = 2202
+	ER_WIF_COMPRESS_FLAG_INVALID                   int = 2203
+	ER_WIF_PRVKEY_INVALID                          int = 2204
+	ER_WIF_NETWORK_MISMATCH                        int = 2205
+	ER_WIF_NETWORK_UNKNOWN                         int = 2206
+	ER_ACCOUNT_PURPOSE_UNSUPPORTED                 int = 2301
+	ER_ACCOUNT_KEY_TYPE_MISMATCH                   int = 2302
 	ER_ADDRESS_CHECKSUM_MISMATCH                   int = 3101
 	ER_ADDRESS_TYPE_UNKNOWN                        int = 3102
 	ER_ADDRESS_FORMAT_MALFORMED                    int = 3103
@@ -24,6 +32,8 @@ const (
 	ER_TRANSACTION_SIGN_OUT_INDEX                  int = 5000
 	ER_TRANSACTION_SIGN_REDEEM_EMPTY               int = 5001
 	ER_TRANSACTION_VERIFY_FAILED                   int = 5002
+	ER_TRANSACTION_SIGHASH_SINGLE_NO_OUTPUT        int = 5003
+	ER_TRANSACTION_SIGHASH_TYPE_INVALID            int = 5004
 	ER_TRANSACTION_BUILDER_AMOUNT_NOT_ENOUGH_ERROR int = 5101
 	ER_TRANSACTION_BUILDER_FROM_EMPTY              int = 5102
 	ER_TRANSACTION_BUILDER_CHANGETO_EMPTY          int = 5103
@@ -31,14 +41,57 @@ const (
 	ER_TRANSACTION_BUILDER_SIGN_KEY_EMPTY          int = 5105
 	ER_TRANSACTION_BUILDER_MIN_FEE_NOT_ENOUGH      int = 5106
 	ER_TRANSACTION_BUILDER_FEE_TOO_HIGH            int = 5107
+	ER_TRANSACTION_BUILDER_PACKAGE_FEE_RATE_EMPTY  int = 5108
+	ER_TRANSACTION_BUILDER_RELATIVE_LOCK_VERSION   int = 5109
 	ER_TRANSACTION_PARTIALLY_MAGIC_MISMATCH        int = 5201
 	ER_MICROPAYMENT_LOCKTIME_MISMATCH              int = 5301
 	ER_MICROPAYMENT_REFUND_BOND_MISMATCH           int = 5302
+	ER_SWAP_LOCK_MISSING                           int = 5401
+	ER_SWAP_LOCK_OUTPUT_MISMATCH                   int = 5402
+	ER_SWAP_CLAIM_MISSING                          int = 5403
+	ER_SWAP_CLAIM_INPUT_MISMATCH                   int = 5404
+	ER_SWAP_PRESIGNATURE_MISSING                   int = 5405
+	ER_SWAP_AMOUNT_NOT_ENOUGH                      int = 5406
+	ER_SWAP_REFUND_MISSING                         int = 5407
+	ER_SWAP_LOCKTIME_INVALID                       int = 5408
+	ER_MESSAGE_ADDRESS_TYPE_UNSUPPORTED            int = 5501
+	ER_MESSAGE_KEY_ADDRESS_MISMATCH                int = 5502
+	ER_MESSAGE_SIGNATURE_MALFORMED                 int = 5503
+	ER_MESSAGE_SIGNATURE_HEADER_MISMATCH           int = 5504
+	ER_MESSAGE_VERIFY_FAILED                       int = 5505
+	ER_MESSAGE_PROOF_MISMATCH                      int = 5506
+	ER_COIN_SELECTION_AMOUNT_NOT_ENOUGH            int = 5601
+	ER_COIN_SELECTION_NO_SOLUTION                  int = 5602
+	ER_COIN_SELECTION_SPEND_WEIGHT_UNKNOWN         int = 5603
+	ER_ESTIMATE_INPUT_UNSUPPORTED                  int = 5701
+	ER_RBF_COINS_MISMATCH                          int = 5801
+	ER_RBF_INPUT_COIN_MISMATCH                     int = 5802
+	ER_RBF_SIGN_KEY_MISSING                        int = 5803
+	ER_RBF_FEE_NOT_ENOUGH                          int = 5804
+	ER_RBF_CHANGE_EMPTY                            int = 5805
+	ER_SEQUENCE_SECONDS_OUT_OF_RANGE               int = 5901
+	ER_SEQUENCE_LOCKTIME_UNSATISFIED               int = 5902
+	ER_SEQUENCE_RELATIVE_LOCK_UNSATISFIED          int = 5903
+	ER_HTLC_SCRIPT_MALFORMED                       int = 6001
+	ER_HTLC_FORM_UNKNOWN                           int = 6002
+	ER_HTLC_FUNDING_MISSING                        int = 6003
+	ER_HTLC_FUNDING_OUTPUT_MISSING                 int = 6004
+	ER_HTLC_PREIMAGE_MISMATCH                      int = 6005
+	ER_HTLC_AMOUNT_NOT_ENOUGH                      int = 6006
+	ER_HTLC_CLAIM_INPUT_MISMATCH                   int = 6007
 )
 
 // Errors -- the jump table of error.
 var Errors = map[int]*xerror.Error{
 	ER_KEY_SIGNATURE_VERIFY_FAILED:                 {Num: ER_KEY_SIGNATURE_VERIFY_FAILED, State: "TKS00", Message: "key.signature.verify.failed"},
+	ER_WIF_FORMAT_MALFORMED:                        {Num: ER_WIF_FORMAT_MALFORMED, State: "TWIF0", Message: "wif.format.malformed[%v]"},
+	ER_WIF_SIZE_MALFORMED:                          {Num: ER_WIF_SIZE_MALFORMED, State: "TWIF0", Message: "wif.size[%v].invalid"},
+	ER_WIF_COMPRESS_FLAG_INVALID:                   {Num: ER_WIF_COMPRESS_FLAG_INVALID, State: "TWIF0", Message: "wif.compress.flag[%02x].invalid"},
+	ER_WIF_PRVKEY_INVALID:                          {Num: ER_WIF_PRVKEY_INVALID, State: "TWIF0", Message: "wif.prvkey.out.of.range"},
+	ER_WIF_NETWORK_MISMATCH:                        {Num: ER_WIF_NETWORK_MISMATCH, State: "TWIF0", Message: "wif.network.id[%02x].mismatch.want[%02x]"},
+	ER_WIF_NETWORK_UNKNOWN:                         {Num: ER_WIF_NETWORK_UNKNOWN, State: "TWIF0", Message: "wif.network.id[%02x].unknown"},
+	ER_ACCOUNT_PURPOSE_UNSUPPORTED:                 {Num: ER_ACCOUNT_PURPOSE_UNSUPPORTED, State: "TACC0", Message: "account.purpose[%v].unsupported"},
+	ER_ACCOUNT_KEY_TYPE_MISMATCH:                   {Num: ER_ACCOUNT_KEY_TYPE_MISMATCH, State: "TACC0", Message: "account.purpose[%v].key.type[%v].mismatch"},
 	ER_ADDRESS_CHECKSUM_MISMATCH:                   {Num: ER_ADDRESS_CHECKSUM_MISMATCH, State: "THK00", Message: "address.checksum.mismatch"},
 	ER_ADDRESS_TYPE_UNKNOWN:                        {Num: ER_ADDRESS_TYPE_UNKNOWN, State: "TADDR0", Message: "address.unknown.type[%v]"},
 	ER_ADDRESS_FORMAT_MALFORMED:                    {Num: ER_ADDRESS_FORMAT_MALFORMED, State: "TADDR0", Message: "address.unknown.format[%v]"},
@@ -51,6 +104,8 @@ var Errors = map[int]*xerror.Error{
 	ER_TRANSACTION_SIGN_OUT_INDEX:                  {Num: ER_TRANSACTION_SIGN_OUT_INDEX, State: "TTX00", Message: "transaction.sign.idx[%v].out.index[%v]"},
 	ER_TRANSACTION_SIGN_REDEEM_EMPTY:               {Num: ER_TRANSACTION_SIGN_REDEEM_EMPTY, State: "TTX00", Message: "transaction.sign.idx[%v].redeem.can.not.be.nil.since.keys[%v]>1"},
 	ER_TRANSACTION_VERIFY_FAILED:                   {Num: ER_TRANSACTION_VERIFY_FAILED, State: "TTX00", Message: "transaction.verify.for.input[%v].referencing[%v].at[%v].failed"},
+	ER_TRANSACTION_SIGHASH_SINGLE_NO_OUTPUT:        {Num: ER_TRANSACTION_SIGHASH_SINGLE_NO_OUTPUT, State: "TTX00", Message: "transaction.sighash.single.input[%v].has.no.output.outputs[%v]"},
+	ER_TRANSACTION_SIGHASH_TYPE_INVALID:            {Num: ER_TRANSACTION_SIGHASH_TYPE_INVALID, State: "TTX00", Message: "transaction.sighash.type[%v].invalid"},
 	ER_TRANSACTION_BUILDER_AMOUNT_NOT_ENOUGH_ERROR: {Num: ER_TRANSACTION_BUILDER_AMOUNT_NOT_ENOUGH_ERROR, State: "TTB00", Message: "transaction.builder.amount.totalout[%v].more.than.totalin[%v]"},
 	ER_TRANSACTION_BUILDER_FROM_EMPTY:              {Num: ER_TRANSACTION_BUILDER_FROM_EMPTY, State: "TTB00", Message: "transaction.builder.from.is.empty"},
 	ER_TRANSACTION_BUILDER_CHANGETO_EMPTY:          {Num: ER_TRANSACTION_BUILDER_CHANGETO_EMPTY, State: "TTB00", Message: "transaction.builder.changeto.is.empty"},
@@ -58,7 +113,42 @@ var Errors = map[int]*xerror.Error{
 	ER_TRANSACTION_BUILDER_SIGN_KEY_EMPTY:          {Num: ER_TRANSACTION_BUILDER_SIGN_KEY_EMPTY, State: "TTB00", Message: "transaction.builder.sign.but.key.is.empty.at.input.idx[%v]"},
 	ER_TRANSACTION_BUILDER_MIN_FEE_NOT_ENOUGH:      {Num: ER_TRANSACTION_BUILDER_MIN_FEE_NOT_ENOUGH, State: "TTB00", Message: "transaction.builder.min.fee[%v].not.enough.from.change.value[%v]"},
 	ER_TRANSACTION_BUILDER_FEE_TOO_HIGH:            {Num: ER_TRANSACTION_BUILDER_FEE_TOO_HIGH, State: "TTB00", Message: "transaction.builder.fee[%v].too.high.than.max.fee[%v]"},
+	ER_TRANSACTION_BUILDER_PACKAGE_FEE_RATE_EMPTY:  {Num: ER_TRANSACTION_BUILDER_PACKAGE_FEE_RATE_EMPTY, State: "TTB00", Message: "transaction.builder.package.fee.rate.is.empty"},
+	ER_TRANSACTION_BUILDER_RELATIVE_LOCK_VERSION:   {Num: ER_TRANSACTION_BUILDER_RELATIVE_LOCK_VERSION, State: "TTB00", Message: "transaction.builder.input[%v].relative.lock.requires.version[%v]"},
 	ER_TRANSACTION_PARTIALLY_MAGIC_MISMATCH:        {Num: ER_TRANSACTION_PARTIALLY_MAGIC_MISMATCH, State: "TTP00", Message: "transaction.partially.request.magic.mismatch.want[%x].got[%x]"},
 	ER_MICROPAYMENT_LOCKTIME_MISMATCH:              {Num: ER_MICROPAYMENT_LOCKTIME_MISMATCH, State: "TM000", Message: "micropayment.locktime.mismatch.want[%v].got[%v]"},
 	ER_MICROPAYMENT_REFUND_BOND_MISMATCH:           {Num: ER_MICROPAYMENT_REFUND_BOND_MISMATCH, State: "TM000", Message: "micropayment.refund.bond.mismatch"},
+	ER_SWAP_LOCK_MISSING:                           {Num: ER_SWAP_LOCK_MISSING, State: "TSW00", Message: "swap.lock.transaction.missing"},
+	ER_SWAP_LOCK_OUTPUT_MISMATCH:                   {Num: ER_SWAP_LOCK_OUTPUT_MISMATCH, State: "TSW00", Message: "swap.lock.output.does.not.pay.to.the.joint.key"},
+	ER_SWAP_CLAIM_MISSING:                          {Num: ER_SWAP_CLAIM_MISSING, State: "TSW00", Message: "swap.claim.transaction.missing"},
+	ER_SWAP_CLAIM_INPUT_MISMATCH:                   {Num: ER_SWAP_CLAIM_INPUT_MISMATCH, State: "TSW00", Message: "swap.claim.transaction[%v].does.not.spend.the.lock"},
+	ER_SWAP_PRESIGNATURE_MISSING:                   {Num: ER_SWAP_PRESIGNATURE_MISSING, State: "TSW00", Message: "swap.presignature.missing"},
+	ER_SWAP_AMOUNT_NOT_ENOUGH:                      {Num: ER_SWAP_AMOUNT_NOT_ENOUGH, State: "TSW00", Message: "swap.amount[%v].not.enough.for.fees[%v]"},
+	ER_SWAP_REFUND_MISSING:                         {Num: ER_SWAP_REFUND_MISSING, State: "TSW00", Message: "swap.refund.transaction.missing"},
+	ER_SWAP_LOCKTIME_INVALID:                       {Num: ER_SWAP_LOCKTIME_INVALID, State: "TSW00", Message: "swap.first.locktime[%v].not.later.than.second.locktime[%v].by.margin[%v]"},
+	ER_MESSAGE_ADDRESS_TYPE_UNSUPPORTED:            {Num: ER_MESSAGE_ADDRESS_TYPE_UNSUPPORTED, State: "TMS00", Message: "message.address.type[%T].unsupported"},
+	ER_MESSAGE_KEY_ADDRESS_MISMATCH:                {Num: ER_MESSAGE_KEY_ADDRESS_MISMATCH, State: "TMS00", Message: "message.key.does.not.own.the.address[%x]"},
+	ER_MESSAGE_SIGNATURE_MALFORMED:                 {Num: ER_MESSAGE_SIGNATURE_MALFORMED, State: "TMS00", Message: "message.signature.malformed[%v]"},
+	ER_MESSAGE_SIGNATURE_HEADER_MISMATCH:           {Num: ER_MESSAGE_SIGNATURE_HEADER_MISMATCH, State: "TMS00", Message: "message.signature.header[%v].mismatch.address.type[%T]"},
+	ER_MESSAGE_VERIFY_FAILED:                       {Num: ER_MESSAGE_VERIFY_FAILED, State: "TMS00", Message: "message.verify.failed"},
+	ER_MESSAGE_PROOF_MISMATCH:                      {Num: ER_MESSAGE_PROOF_MISMATCH, State: "TMS00", Message: "message.proof.does.not.spend.the.to_spend[%x]"},
+	ER_COIN_SELECTION_AMOUNT_NOT_ENOUGH:            {Num: ER_COIN_SELECTION_AMOUNT_NOT_ENOUGH, State: "TCS00", Message: "coin.selection.available[%v].not.enough.for.target[%v]"},
+	ER_COIN_SELECTION_NO_SOLUTION:                  {Num: ER_COIN_SELECTION_NO_SOLUTION, State: "TCS00", Message: "coin.selection.strategy[%v].no.solution"},
+	ER_COIN_SELECTION_SPEND_WEIGHT_UNKNOWN:         {Num: ER_COIN_SELECTION_SPEND_WEIGHT_UNKNOWN, State: "TCS00", Message: "coin.selection.script[%v].spend.weight.unknown"},
+	ER_ESTIMATE_INPUT_UNSUPPORTED:                  {Num: ER_ESTIMATE_INPUT_UNSUPPORTED, State: "TES00", Message: "estimate.input.script[%v].unsupported"},
+	ER_RBF_COINS_MISMATCH:                          {Num: ER_RBF_COINS_MISMATCH, State: "TRBF0", Message: "rbf.coins[%v].mismatch.inputs[%v]"},
+	ER_RBF_INPUT_COIN_MISMATCH:                     {Num: ER_RBF_INPUT_COIN_MISMATCH, State: "TRBF0", Message: "rbf.input[%v].does.not.spend.coin[%v:%v]"},
+	ER_RBF_SIGN_KEY_MISSING:                        {Num: ER_RBF_SIGN_KEY_MISSING, State: "TRBF0", Message: "rbf.input[%v].sign.key.missing"},
+	ER_RBF_FEE_NOT_ENOUGH:                          {Num: ER_RBF_FEE_NOT_ENOUGH, State: "TRBF0", Message: "rbf.fee[%v].more.than.available[%v]"},
+	ER_RBF_CHANGE_EMPTY:                            {Num: ER_RBF_CHANGE_EMPTY, State: "TRBF0", Message: "rbf.change.is.empty.for.the.extra.inputs"},
+	ER_SEQUENCE_SECONDS_OUT_OF_RANGE:               {Num: ER_SEQUENCE_SECONDS_OUT_OF_RANGE, State: "TSQ00", Message: "sequence.seconds[%v].out.of.range[%v]"},
+	ER_SEQUENCE_LOCKTIME_UNSATISFIED:               {Num: ER_SEQUENCE_LOCKTIME_UNSATISFIED, State: "TSQ00", Message: "sequence.locktime[%v].unsatisfied.by.tx.locktime[%v]"},
+	ER_SEQUENCE_RELATIVE_LOCK_UNSATISFIED:          {Num: ER_SEQUENCE_RELATIVE_LOCK_UNSATISFIED, State: "TSQ00", Message: "sequence.relative.lock[%v].unsatisfied.by.input[%v].sequence[%v]"},
+	ER_HTLC_SCRIPT_MALFORMED:                       {Num: ER_HTLC_SCRIPT_MALFORMED, State: "THT00", Message: "htlc.script[%v].malformed"},
+	ER_HTLC_FORM_UNKNOWN:                           {Num: ER_HTLC_FORM_UNKNOWN, State: "THT00", Message: "htlc.form[%v].unknown"},
+	ER_HTLC_FUNDING_MISSING:                        {Num: ER_HTLC_FUNDING_MISSING, State: "THT00", Message: "htlc.funding.transaction.missing"},
+	ER_HTLC_FUNDING_OUTPUT_MISSING:                 {Num: ER_HTLC_FUNDING_OUTPUT_MISSING, State: "THT00", Message: "htlc.funding.transaction[%v].does.not.pay.to.the.htlc"},
+	ER_HTLC_PREIMAGE_MISMATCH:                      {Num: ER_HTLC_PREIMAGE_MISMATCH, State: "THT00", Message: "htlc.preimage[%x].does.not.match.the.hash[%x]"},
+	ER_HTLC_AMOUNT_NOT_ENOUGH:                      {Num: ER_HTLC_AMOUNT_NOT_ENOUGH, State: "THT00", Message: "htlc.amount[%v].not.enough.for.fees[%v]"},
+	ER_HTLC_CLAIM_INPUT_MISMATCH:                   {Num: ER_HTLC_CLAIM_INPUT_MISMATCH, State: "THT00", Message: "htlc.claim.transaction[%v].does.not.spend.the.funding"},
 }
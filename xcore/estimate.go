@@ -7,6 +7,8 @@ package xcore
 
 import (
 	"github.com/keyfuse/tokucore/xbase"
+	"github.com/keyfuse/tokucore/xerror"
+	"github.com/keyfuse/tokucore/xvm"
 )
 
 const (
@@ -41,6 +43,7 @@ const (
 // signed transaction that spends inputCount number of compressed P2PKH outputs
 // and contains each transaction output from txOuts.  The estimated size is
 // incremented for an additional P2PKH change output if addChangeOutput is true.
+// EstimateWeight has the worst case weights by the input script types.
 func EstimateSize(txins []*TxIn, txouts []*TxOut) int64 {
 	baseSize := 0
 	witnessSize := 0
@@ -133,3 +136,219 @@ func EstimateFees(estimateSize int64, relayFeePerKb int64) int64 {
 	}
 	return int64(fees)
 }
+
+const (
+	// maxSignatureSize -- the largest standard ecdsa signature:
+	// 71 bytes low-S DER signature + 1 byte sighash.
+	maxSignatureSize = 72
+
+	// inputOutPointSequenceSize -- 32 bytes previous tx + 4 bytes output index + 4 bytes sequence.
+	inputOutPointSequenceSize = 32 + 4 + 4
+
+	// schnorrSignatureSize -- the BIP340 signature with the default sighash.
+	schnorrSignatureSize = 64
+
+	// taprootControlBlockBaseSize -- the leaf version byte and the 32 bytes internal key.
+	taprootControlBlockBaseSize = 33
+
+	// SpendWeightP2PKH -- the weight of an input spending a compressed P2PKH output:
+	// scriptSig <signature> <pubkey>.
+	SpendWeightP2PKH = (inputOutPointSequenceSize + 1 + 1 + maxSignatureSize + 1 + 33) * witnessScaleFactor
+
+	// SpendWeightP2WPKHInP2SH -- the weight of an input spending a P2SH-P2WPKH output:
+	// scriptSig pushing the 22 bytes witness program, and the P2WPKH witness.
+	SpendWeightP2WPKHInP2SH = (inputOutPointSequenceSize+1+23)*witnessScaleFactor + spendWitnessP2WPKH
+
+	// SpendWeightP2WPKH -- the weight of an input spending a P2WPKH output:
+	// empty scriptSig, witness <signature> <pubkey>.
+	SpendWeightP2WPKH = (inputOutPointSequenceSize+1)*witnessScaleFactor + spendWitnessP2WPKH
+
+	// SpendWeightP2TR -- the weight of an input spending a P2TR output by the key path with the default sighash:
+	// empty scriptSig, witness <signature>.
+	SpendWeightP2TR = (inputOutPointSequenceSize+1)*witnessScaleFactor + 1 + 1 + schnorrSignatureSize
+
+	// spendWitnessP2WPKH -- items count, <signature>, <pubkey>.
+	spendWitnessP2WPKH = 1 + 1 + maxSignatureSize + 1 + 33
+)
+
+// InputWeightP2PKH -- the worst case weight of an input spending a P2PKH output.
+func InputWeightP2PKH(compressed bool) int64 {
+	pubkeySize := 33
+	if !compressed {
+		pubkeySize = 65
+	}
+	scriptSig := 1 + maxSignatureSize + 1 + pubkeySize
+	return int64(inputOutPointSequenceSize+xbase.VarIntSerializeSize(uint64(scriptSig))+scriptSig) * witnessScaleFactor
+}
+
+// InputWeightP2SHMultiSig -- the worst case weight of an input spending a P2SH m-of-n multisig output
+// with compressed public keys, scriptSig OP_0 <sig>...<sig> <redeemScript>.
+func InputWeightP2SHMultiSig(m int, n int) int64 {
+	redeem := multiSigScriptSize(n)
+	scriptSig := 1 + m*(1+maxSignatureSize) + pushDataSize(redeem)
+	return int64(inputOutPointSequenceSize+xbase.VarIntSerializeSize(uint64(scriptSig))+scriptSig) * witnessScaleFactor
+}
+
+// InputWeightP2WSHMultiSig -- the worst case weight of an input spending a P2WSH m-of-n multisig output
+// with compressed public keys, witness <> <sig>...<sig> <witnessScript>.
+func InputWeightP2WSHMultiSig(m int, n int) int64 {
+	redeem := multiSigScriptSize(n)
+	witness := xbase.VarIntSerializeSize(uint64(m+2)) + 1 + m*(1+maxSignatureSize) + xbase.VarIntSerializeSize(uint64(redeem)) + redeem
+	return int64(inputOutPointSequenceSize+1)*witnessScaleFactor + int64(witness)
+}
+
+// InputWeightP2TRKeyPath -- the weight of an input spending a P2TR output by the key path,
+// the signature has the sighash byte unless the hash type is SigHashDefault.
+func InputWeightP2TRKeyPath(hashType SigHashType) int64 {
+	sig := schnorrSignatureSize
+	if hashType != SigHashDefault {
+		sig++
+	}
+	return int64(inputOutPointSequenceSize+1)*witnessScaleFactor + int64(1+1+sig)
+}
+
+// InputWeightP2TRScriptPath -- the weight of an input spending a P2TR output by the script path,
+// witness <stack items>... <script> <control block> where the leaf is at the depth of the script tree.
+func InputWeightP2TRScriptPath(stackSizes []int, scriptSize int, depth int) int64 {
+	controlBlock := taprootControlBlockBaseSize + 32*depth
+	witness := xbase.VarIntSerializeSize(uint64(len(stackSizes) + 2))
+	for _, size := range append(stackSizes, scriptSize, controlBlock) {
+		witness += xbase.VarIntSerializeSize(uint64(size)) + size
+	}
+	return int64(inputOutPointSequenceSize+1)*witnessScaleFactor + int64(witness)
+}
+
+// InputWeight -- the worst case weight of the input by its locking and redeem scripts:
+// P2PKH with the compressed or uncompressed public key, P2SH multisig, P2SH-P2WPKH, P2WPKH, P2WSH multisig and P2TR key path.
+func InputWeight(txin *TxIn, compressed bool) (int64, error) {
+	instance, err := ParseLockingScript(txin.RawLockingScript)
+	if err != nil {
+		return 0, err
+	}
+	switch instance.(type) {
+	case *PayToPubKeyHashScript:
+		return InputWeightP2PKH(compressed), nil
+	case *PayToScriptHashScript:
+		if isWitnessV0PubKeyHashProgram(txin.RedeemScript) {
+			return SpendWeightP2WPKHInP2SH, nil
+		}
+		if m, n, ok := multiSigParams(txin.RedeemScript); ok {
+			return InputWeightP2SHMultiSig(m, n), nil
+		}
+	case *PayToWitnessV0PubKeyHashScript:
+		return SpendWeightP2WPKH, nil
+	case *PayToWitnessV0ScriptHashScript:
+		if m, n, ok := multiSigParams(txin.RedeemScript); ok {
+			return InputWeightP2WSHMultiSig(m, n), nil
+		}
+	case *PayToTaprootScript:
+		return InputWeightP2TRKeyPath(SigHashDefault), nil
+	}
+	return 0, xerror.NewError(Errors, ER_ESTIMATE_INPUT_UNSUPPORTED, xvm.DisasmString(txin.RawLockingScript))
+}
+
+// EstimateWeight -- the worst case weight of the signed transaction,
+// the P2PKH inputs are signed with the compressed or uncompressed public keys.
+func EstimateWeight(txins []*TxIn, txouts []*TxOut, compressed bool) (int64, error) {
+	base := 4 + xbase.VarIntSerializeSize(uint64(len(txins))) + xbase.VarIntSerializeSize(uint64(len(txouts))) + 4
+	for _, out := range txouts {
+		base += outputSize(out.Script)
+	}
+
+	weight := int64(base * witnessScaleFactor)
+	legacy := 0
+	for _, in := range txins {
+		w, err := InputWeight(in, compressed)
+		if err != nil {
+			return 0, err
+		}
+		weight += w
+		if !in.HasWitness() {
+			legacy++
+		}
+	}
+	// Marker, flag and the empty witness of the legacy inputs.
+	if legacy < len(txins) {
+		weight += int64(2 + legacy)
+	}
+	return weight, nil
+}
+
+// EstimateVsize -- the virtual size of the weight, rounded up.
+func EstimateVsize(weight int64) int64 {
+	return (weight + witnessScaleFactor - 1) / witnessScaleFactor
+}
+
+// EstimateFeesForWeight -- the fees of the weight at the fee rate in sat/vB.
+func EstimateFeesForWeight(weight int64, satPerVByte uint64) int64 {
+	return EstimateVsize(weight) * int64(satPerVByte)
+}
+
+// DummySignIndex -- fills the input with worst case sized dummy signatures and public keys,
+// so the transaction has the worst-case size of the signed one before the real signing.
+func (tx *Transaction) DummySignIndex(idx int, compressed bool, hashType SigHashType) error {
+	txIn := tx.inputs[idx]
+	instance, err := ParseLockingScript(txIn.RawLockingScript)
+	if err != nil {
+		return err
+	}
+
+	pubkey := make([]byte, 33)
+	if !compressed {
+		pubkey = make([]byte, 65)
+	}
+	ecdsaSig := make([]byte, maxSignatureSize)
+	ecdsaSig[maxSignatureSize-1] = byte(hashType)
+
+	switch instance.(type) {
+	case *PayToPubKeyHashScript, *PayToWitnessV0PubKeyHashScript:
+		return tx.EmbedIdxSignature(idx, []PubKeySign{{PubKey: pubkey, Signature: ecdsaSig}})
+	case *PayToScriptHashScript, *PayToWitnessV0ScriptHashScript:
+		m, _, ok := multiSigParams(txIn.RedeemScript)
+		if !ok {
+			break
+		}
+		signs := make([]PubKeySign, m)
+		for i := range signs {
+			signs[i] = PubKeySign{PubKey: pubkey, Signature: ecdsaSig}
+		}
+		return tx.EmbedIdxSignature(idx, signs)
+	case *PayToTaprootScript:
+		return tx.EmbedIdxSchnorrSignature(idx, make([]byte, schnorrSignatureSize), hashType)
+	}
+	return xerror.NewError(Errors, ER_ESTIMATE_INPUT_UNSUPPORTED, xvm.DisasmString(txIn.RawLockingScript))
+}
+
+// multiSigScriptSize -- the size of the m-of-n multisig script with compressed public keys:
+// OP_m <pubkey>... OP_n OP_CHECKMULTISIG.
+func multiSigScriptSize(n int) int {
+	return 1 + n*(1+33) + 1 + 1
+}
+
+// multiSigParams -- the m and n of the multisig script.
+func multiSigParams(script []byte) (int, int, bool) {
+	instrs, err := xvm.NewScriptReader(script).AllInstructions()
+	if err != nil || !isMultiSig(instrs) {
+		return 0, 0, false
+	}
+	return asSmallInt(&instrs[0]), asSmallInt(&instrs[len(instrs)-2]), true
+}
+
+// isWitnessV0PubKeyHashProgram -- the P2SH-P2WPKH redeem script OP_0 <20 bytes>.
+func isWitnessV0PubKeyHashProgram(script []byte) bool {
+	instrs, err := xvm.NewScriptReader(script).AllInstructions()
+	return err == nil && isWitnessV0PubKeyHash(instrs)
+}
+
+// pushDataSize -- the size of pushing the data of the size.
+func pushDataSize(size int) int {
+	switch {
+	case size < int(xvm.OP_PUSHDATA1):
+		return 1 + size
+	case size <= 0xff:
+		return 2 + size
+	case size <= 0xffff:
+		return 3 + size
+	}
+	return 5 + size
+}
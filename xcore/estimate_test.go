@@ -6,8 +6,11 @@
 package xcore
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/keyfuse/tokucore/xcore/bip32"
+	"github.com/keyfuse/tokucore/xcrypto"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,3 +18,114 @@ func TestEstimateNormalSize(t *testing.T) {
 	size := EstimateNormalSize(2, 2)
 	assert.Equal(t, int64(374), size)
 }
+
+func TestInputWeight(t *testing.T) {
+	assert.Equal(t, int64(592), InputWeightP2PKH(true))
+	assert.Equal(t, int64(720), InputWeightP2PKH(false))
+	assert.Equal(t, int64(1188), InputWeightP2SHMultiSig(2, 3))
+	assert.Equal(t, int64(418), InputWeightP2WSHMultiSig(2, 3))
+	assert.Equal(t, int64(230), InputWeightP2TRKeyPath(SigHashDefault))
+	assert.Equal(t, int64(231), InputWeightP2TRKeyPath(SigHashAll))
+	assert.Equal(t, int64(331), InputWeightP2TRScriptPath([]int{64}, 34, 1))
+	assert.Equal(t, int64(SpendWeightP2PKH), InputWeightP2PKH(true))
+	assert.Equal(t, int64(272), int64(SpendWeightP2WPKH))
+	assert.Equal(t, int64(364), int64(SpendWeightP2WPKHInP2SH))
+	assert.Equal(t, int64(SpendWeightP2TR), InputWeightP2TRKeyPath(SigHashDefault))
+
+	assert.Equal(t, int64(68), EstimateVsize(272))
+	assert.Equal(t, int64(58), EstimateVsize(230))
+	assert.Equal(t, int64(290), EstimateFeesForWeight(230, 5))
+}
+
+func TestEstimateWeight(t *testing.T) {
+	var keys []*bip32.HDKey
+	var pubs [][]byte
+	for _, seed := range []string{"this.is.a.seed.", "this.is.b.seed.", "this.is.c.seed."} {
+		key := bip32.NewHDKey([]byte(seed))
+		keys = append(keys, key)
+		pubs = append(pubs, key.PublicKey().Serialize())
+	}
+	redeem, err := NewPayToMultiSigScript(2, pubs...).GetLockingScriptBytes()
+	assert.Nil(t, err)
+
+	a := keys[0]
+	p2pkh, _ := NewPayToPubKeyHashAddress(a.PublicKey().Hash160()).LockingScript()
+	p2sh, _ := NewPayToScriptHashAddress(xcrypto.Hash160(redeem)).LockingScript()
+	p2wpkh, _ := NewPayToWitnessV0PubKeyHashAddress(a.PublicKey().Hash160()).LockingScript()
+	p2wsh, _ := NewPayToWitnessV0ScriptHashAddress(xcrypto.Sha256(redeem)).LockingScript()
+	p2tr, _ := NewPayToTaprootAddress(a.PublicKey().SerializeXOnly()).LockingScript()
+	coins := NewCoinBuilder().
+		AddOutput(fmt.Sprintf("%064x", 1), 0, 100000, fmt.Sprintf("%x", p2pkh)).
+		AddOutput(fmt.Sprintf("%064x", 2), 1, 100000, fmt.Sprintf("%x", p2sh)).
+		AddOutput(fmt.Sprintf("%064x", 3), 2, 100000, fmt.Sprintf("%x", p2wpkh)).
+		AddOutput(fmt.Sprintf("%064x", 4), 3, 100000, fmt.Sprintf("%x", p2wsh)).
+		AddOutput(fmt.Sprintf("%064x", 5), 4, 100000, fmt.Sprintf("%x", p2tr)).
+		ToCoins()
+	to := NewPayToWitnessV0PubKeyHashAddress(keys[1].PublicKey().Hash160())
+	change := NewPayToTaprootAddress(a.PublicKey().SerializeXOnly())
+
+	build := func(b *TransactionBuilder) (*Transaction, error) {
+		return b.AddCoin(coins[0]).AddKeys(a.PrivateKey()).To(to, 300000).
+			Then().AddCoin(coins[1]).AddKeys(a.PrivateKey(), keys[2].PrivateKey()).SetRedeemScript(redeem).
+			Then().AddCoin(coins[2]).AddKeys(a.PrivateKey()).
+			Then().AddCoin(coins[3]).AddKeys(a.PrivateKey(), keys[1].PrivateKey()).SetRedeemScript(redeem).
+			Then().AddCoin(coins[4]).AddKeys(a.PrivateKey()).SetSigHashType(SigHashDefault).
+			Then().SetChange(change).
+			BuildTransaction()
+	}
+
+	signed, err := build(NewTransactionBuilder().SendFees(5000).Sign())
+	assert.Nil(t, err)
+	assert.Nil(t, signed.Verify())
+	assert.Equal(t, signed.Weight(), len(signed.SerializeNoWitness())*(witnessScaleFactor-1)+len(signed.Serialize()))
+
+	dryRun, err := build(NewTransactionBuilder().SendFees(5000).DryRun())
+	assert.Nil(t, err)
+	assert.NotEqual(t, signed.ID(), dryRun.ID())
+
+	// The dummy signatures have the worst case size of the estimation.
+	estimate, err := EstimateWeight(signed.inputs, signed.outputs, true)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(dryRun.Weight()), estimate)
+	assert.Equal(t, int64(4+1+1+31+43+4)*witnessScaleFactor+2+2+
+		InputWeightP2PKH(true)+InputWeightP2SHMultiSig(2, 3)+SpendWeightP2WPKH+InputWeightP2WSHMultiSig(2, 3)+SpendWeightP2TR, estimate)
+	assert.True(t, signed.Weight() <= dryRun.Weight())
+	assert.True(t, signed.Weight() > dryRun.Weight()-4*5)
+
+	// The P2PKH input with the uncompressed public key.
+	{
+		pub := a.PrivateKey().PubKey().SerializeUncompressed()
+		script, _ := NewPayToPubKeyHashAddress(xcrypto.Hash160(pub)).LockingScript()
+		coin := NewCoinBuilder().AddOutput(fmt.Sprintf("%064x", 6), 0, 100000, fmt.Sprintf("%x", script)).ToCoins()[0]
+		tx, err := NewTransactionBuilder().AddCoin(coin).AddKeys(a.PrivateKey()).SetPubKeyUncompressed().To(to, 90000).
+			Then().SetChange(change).SendFees(1000).DryRun().
+			BuildTransaction()
+		assert.Nil(t, err)
+		uncompressed, err := EstimateWeight(tx.inputs, tx.outputs, false)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(tx.Weight()), uncompressed)
+		compressed, err := EstimateWeight(tx.inputs, tx.outputs, true)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(128), uncompressed-compressed)
+	}
+
+	// The fee rate in sat/vB.
+	paid, err := build(NewTransactionBuilder().SetFeeRate(7).Sign())
+	assert.Nil(t, err)
+	assert.Nil(t, paid.Verify())
+	assert.Equal(t, dryRun.Vsize()*7, paid.Fees())
+	assert.True(t, paid.Vsize()*7 <= paid.Fees())
+}
+
+func TestEstimateError(t *testing.T) {
+	script := []byte{0x6a, 0x01, 0x01}
+	_, err := InputWeight(&TxIn{RawLockingScript: script}, true)
+	assert.NotNil(t, err)
+
+	p2sh, _ := NewPayToScriptHashAddress(make([]byte, 20)).LockingScript()
+	tx := NewTransaction()
+	tx.AddInput(&TxIn{Hash: make([]byte, 32), RawLockingScript: p2sh, RedeemScript: []byte{0x51}})
+	_, err = EstimateWeight(tx.inputs, nil, true)
+	assert.EqualError(t, err, "estimate.input.script[OP_HASH160 OP_DATA_20 0000000000000000000000000000000000000000 OP_EQUAL].unsupported (errno 5701) (state TES00)")
+	assert.NotNil(t, tx.DummySignIndex(0, true, SigHashAll))
+}
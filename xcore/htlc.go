@@ -0,0 +1,575 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/keyfuse/tokucore/xerror"
+	"github.com/keyfuse/tokucore/xvm"
+)
+
+// The hashed-timelock contract pays the receiver who reveals the preimage of
+// the hash, or refunds the sender after the timeout. The atomic swap locks the
+// coins on two chains to the same hash: the receiver of the first chain claims
+// with the preimage, which reveals it to the other party on the second chain.
+// The first chain times out later than the second one.
+
+const (
+	// htlcPreimageSize -- the preimage must be 32 bytes, the same on every chain of the swap.
+	htlcPreimageSize = 32
+
+	// htlcInternalKey -- the BIP341 NUMS point H, the P2TR form of the HTLC has no key path.
+	htlcInternalKey = "50929b74c1a04954b78b4b6035e97a5e078a5a0f28ec96d547bfee9ace803ac0"
+)
+
+// HTLCForm -- the output form of the HTLC.
+type HTLCForm int
+
+const (
+	// HTLCP2SH -- the HTLC script as the P2SH redeem script.
+	HTLCP2SH HTLCForm = iota
+
+	// HTLCP2WSH -- the HTLC script as the P2WSH witness script.
+	HTLCP2WSH
+
+	// HTLCP2TR -- the claim and the refund as the two tapscript leaves of the NUMS internal key.
+	HTLCP2TR
+)
+
+var htlcFormNames = map[HTLCForm]string{
+	HTLCP2SH:  "p2sh",
+	HTLCP2WSH: "p2wsh",
+	HTLCP2TR:  "p2tr",
+}
+
+// String -- the name of the form.
+func (f HTLCForm) String() string {
+	if name, ok := htlcFormNames[f]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// HTLCScript -- the hashed-timelock contract script.
+//
+//	OP_IF
+//	  OP_SIZE 32 OP_EQUALVERIFY OP_SHA256 <hash> OP_EQUALVERIFY <receiver pubkey>
+//	OP_ELSE
+//	  <timeout> OP_CHECKLOCKTIMEVERIFY|OP_CHECKSEQUENCEVERIFY OP_DROP <sender pubkey>
+//	OP_ENDIF
+//	OP_CHECKSIG
+//
+// The script is unlocked by <receiver sig> <preimage> OP_TRUE, or by <sender sig> OP_FALSE after the timeout.
+type HTLCScript struct {
+	hash     []byte
+	receiver *xcrypto.PubKey
+	sender   *xcrypto.PubKey
+	timeout  uint32
+	relative bool
+	preimage []byte
+}
+
+// NewHTLCScript -- creates the HTLC refunded after the absolute locktime, a height or a time.
+func NewHTLCScript(hash []byte, receiver *xcrypto.PubKey, sender *xcrypto.PubKey, lockTime uint32) *HTLCScript {
+	return &HTLCScript{
+		hash:     hash,
+		receiver: receiver,
+		sender:   sender,
+		timeout:  lockTime,
+	}
+}
+
+// NewRelativeHTLCScript -- creates the HTLC refunded after the BIP68 relative locktime of the sequence,
+// see NewSequenceFromBlocks and NewSequenceFromSeconds.
+func NewRelativeHTLCScript(hash []byte, receiver *xcrypto.PubKey, sender *xcrypto.PubKey, sequence uint32) *HTLCScript {
+	return &HTLCScript{
+		hash:     hash,
+		receiver: receiver,
+		sender:   sender,
+		timeout:  sequence,
+		relative: true,
+	}
+}
+
+// ParseHTLCScript -- parses the HTLC script, such as the P2SH redeem script or the P2WSH witness script.
+func ParseHTLCScript(script []byte) (*HTLCScript, error) {
+	instrs, err := xvm.NewScriptReader(script).AllInstructions()
+	if err != nil || !isHTLC(instrs) {
+		return nil, xerror.NewError(Errors, ER_HTLC_SCRIPT_MALFORMED, xvm.DisasmString(script))
+	}
+	return newHTLCScriptFromInstructions(instrs)
+}
+
+// Hash -- returns the sha256 hash of the preimage.
+func (s *HTLCScript) Hash() []byte {
+	return s.hash
+}
+
+// Receiver -- returns the public key claiming with the preimage.
+func (s *HTLCScript) Receiver() *xcrypto.PubKey {
+	return s.receiver
+}
+
+// Sender -- returns the public key refunding after the timeout.
+func (s *HTLCScript) Sender() *xcrypto.PubKey {
+	return s.sender
+}
+
+// Timeout -- returns the locktime, or the sequence if the timeout is relative.
+func (s *HTLCScript) Timeout() uint32 {
+	return s.timeout
+}
+
+// IsRelative -- returns whether the timeout is the BIP68 relative locktime checked by OP_CHECKSEQUENCEVERIFY.
+func (s *HTLCScript) IsRelative() bool {
+	return s.relative
+}
+
+// SetPreimage -- sets the preimage of the hash, the unlocking is the claim with it and the refund without it.
+func (s *HTLCScript) SetPreimage(preimage []byte) error {
+	if len(preimage) != htlcPreimageSize || !bytes.Equal(xcrypto.Sha256(preimage), s.hash) {
+		return xerror.NewError(Errors, ER_HTLC_PREIMAGE_MISMATCH, preimage, s.hash)
+	}
+	s.preimage = preimage
+	return nil
+}
+
+// GetAddress -- returns the Address interface.
+// The bare HTLC has no address, see Address for the P2SH, P2WSH and P2TR forms.
+func (s *HTLCScript) GetAddress() Address {
+	return nil
+}
+
+// GetRawLockingScriptBytes -- used to get locking script bytes.
+func (s *HTLCScript) GetRawLockingScriptBytes() ([]byte, error) {
+	lockOp := byte(xvm.OP_CHECKLOCKTIMEVERIFY)
+	if s.relative {
+		lockOp = xvm.OP_CHECKSEQUENCEVERIFY
+	}
+	return xvm.NewScriptBuilder().
+		AddOp(xvm.OP_IF).
+		AddOp(xvm.OP_SIZE).
+		AddInt64(htlcPreimageSize).
+		AddOp(xvm.OP_EQUALVERIFY).
+		AddOp(xvm.OP_SHA256).
+		AddData(s.hash).
+		AddOp(xvm.OP_EQUALVERIFY).
+		AddData(s.receiver.SerializeCompressed()).
+		AddOp(xvm.OP_ELSE).
+		AddInt64(int64(s.timeout)).
+		AddOp(lockOp).
+		AddOp(xvm.OP_DROP).
+		AddData(s.sender.SerializeCompressed()).
+		AddOp(xvm.OP_ENDIF).
+		AddOp(xvm.OP_CHECKSIG).
+		Script()
+}
+
+// GetFinalLockingScriptBytes -- used to get the re-written locking for witness.
+// Same as raw.
+func (s *HTLCScript) GetFinalLockingScriptBytes(redeem []byte) ([]byte, error) {
+	return s.GetRawLockingScriptBytes()
+}
+
+// GetRawUnlockingScriptBytes -- used to get raw unlocking script bytes.
+// unlocking: <receiver sig> <preimage> OP_TRUE [redeemScript]
+// or:        <sender sig> OP_FALSE [redeemScript]
+func (s *HTLCScript) GetRawUnlockingScriptBytes(signs []PubKeySign, redeem []byte) ([]byte, error) {
+	builder := xvm.NewScriptBuilder()
+	for _, item := range s.unlockingItems(signs) {
+		builder.AddData(item)
+	}
+	if redeem != nil {
+		builder.AddData(redeem)
+	}
+	return builder.Script()
+}
+
+// GetWitnessUnlockingScriptBytes -- used to get witness script bytes.
+// The bare HTLC has no witness, with the witness script it is the P2WSH witness.
+func (s *HTLCScript) GetWitnessUnlockingScriptBytes(signs []PubKeySign, redeem []byte) ([][]byte, error) {
+	if redeem == nil {
+		return nil, nil
+	}
+	return append(s.unlockingItems(signs), redeem), nil
+}
+
+// GetWitnessScriptCode -- used to get the witness script for sighash of this txin.
+func (s *HTLCScript) GetWitnessScriptCode(redeem []byte) ([]byte, error) {
+	return nil, nil
+}
+
+// GetScriptVersion -- used to get the version of this script.
+func (s *HTLCScript) GetScriptVersion() ScriptVersion {
+	return BASE
+}
+
+// WitnessToUnlockingScriptBytes -- converts witness slice to unlocking script.
+// For txn deserialize from hex.
+func (s *HTLCScript) WitnessToUnlockingScriptBytes(witness [][]byte) ([]byte, error) {
+	return nil, nil
+}
+
+// ClaimLeaf -- returns the tapscript leaf of the claim with the x-only receiver key.
+//
+// OP_SIZE 32 OP_EQUALVERIFY OP_SHA256 <hash> OP_EQUALVERIFY <receiver x-only pubkey> OP_CHECKSIG
+func (s *HTLCScript) ClaimLeaf() ([]byte, error) {
+	return xvm.NewScriptBuilder().
+		AddOp(xvm.OP_SIZE).
+		AddInt64(htlcPreimageSize).
+		AddOp(xvm.OP_EQUALVERIFY).
+		AddOp(xvm.OP_SHA256).
+		AddData(s.hash).
+		AddOp(xvm.OP_EQUALVERIFY).
+		AddData(s.receiver.SerializeXOnly()).
+		AddOp(xvm.OP_CHECKSIG).
+		Script()
+}
+
+// RefundLeaf -- returns the tapscript leaf of the refund with the x-only sender key.
+//
+// <timeout> OP_CHECKLOCKTIMEVERIFY|OP_CHECKSEQUENCEVERIFY OP_DROP <sender x-only pubkey> OP_CHECKSIG
+func (s *HTLCScript) RefundLeaf() ([]byte, error) {
+	lockOp := byte(xvm.OP_CHECKLOCKTIMEVERIFY)
+	if s.relative {
+		lockOp = xvm.OP_CHECKSEQUENCEVERIFY
+	}
+	return xvm.NewScriptBuilder().
+		AddInt64(int64(s.timeout)).
+		AddOp(lockOp).
+		AddOp(xvm.OP_DROP).
+		AddData(s.sender.SerializeXOnly()).
+		AddOp(xvm.OP_CHECKSIG).
+		Script()
+}
+
+// TaprootMerkleRoot -- returns the merkle root of the claim and the refund leaves.
+func (s *HTLCScript) TaprootMerkleRoot() ([]byte, error) {
+	claim, err := s.ClaimLeaf()
+	if err != nil {
+		return nil, err
+	}
+	refund, err := s.RefundLeaf()
+	if err != nil {
+		return nil, err
+	}
+	return TapBranchHash(TapLeafHash(claim), TapLeafHash(refund)), nil
+}
+
+// Address -- returns the address of the HTLC in the form.
+func (s *HTLCScript) Address(form HTLCForm) (Address, error) {
+	switch form {
+	case HTLCP2SH, HTLCP2WSH:
+		script, err := s.GetRawLockingScriptBytes()
+		if err != nil {
+			return nil, err
+		}
+		if form == HTLCP2SH {
+			return NewPayToScriptHashAddress(xcrypto.Hash160(script)), nil
+		}
+		return NewPayToWitnessV0ScriptHashAddress(xcrypto.Sha256(script)), nil
+	case HTLCP2TR:
+		root, err := s.TaprootMerkleRoot()
+		if err != nil {
+			return nil, err
+		}
+		output, err := xcrypto.TaprootTweakPubKey(htlcNUMSKey(), root)
+		if err != nil {
+			return nil, err
+		}
+		return NewPayToTaprootAddress(output.SerializeXOnly()), nil
+	}
+	return nil, xerror.NewError(Errors, ER_HTLC_FORM_UNKNOWN, form)
+}
+
+// unlockingItems -- the stack items of the claim with the preimage, otherwise of the refund.
+func (s *HTLCScript) unlockingItems(signs []PubKeySign) [][]byte {
+	var items [][]byte
+	for _, sign := range signs {
+		items = append(items, sign.Signature)
+	}
+	if s.preimage != nil {
+		return append(items, s.preimage, []byte{0x01})
+	}
+	return append(items, []byte{})
+}
+
+// HTLC -- the builder of the funding, the claim and the refund transactions of the HTLC in the form.
+type HTLC struct {
+	script  *HTLCScript
+	form    HTLCForm
+	funding *Transaction
+	n       uint32
+}
+
+// NewHTLC -- creates new HTLC.
+func NewHTLC(script *HTLCScript, form HTLCForm) *HTLC {
+	return &HTLC{
+		script: script,
+		form:   form,
+	}
+}
+
+// Script -- returns the HTLC script.
+func (h *HTLC) Script() *HTLCScript {
+	return h.script
+}
+
+// Address -- returns the address of the HTLC.
+func (h *HTLC) Address() (Address, error) {
+	return h.script.Address(h.form)
+}
+
+// BuildFunding -- builds the funding transaction of the sender, signed with the key of the coin.
+// The HTLC output is the first one.
+func (h *HTLC) BuildFunding(coin *Coin, key *xcrypto.PrvKey, amount uint64, change Address, fees uint64) (*Transaction, error) {
+	addr, err := h.Address()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := NewTransactionBuilder().
+		AddCoin(coin).
+		AddKeys(key).
+		To(addr, amount).
+		Then().
+		SetChange(change).
+		SendFees(fees).
+		Sign().
+		BuildTransaction()
+	if err != nil {
+		return nil, err
+	}
+	if err := h.SetFunding(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// SetFunding -- sets the funding transaction, its first output paying to the HTLC is spent.
+func (h *HTLC) SetFunding(tx *Transaction) error {
+	addr, err := h.Address()
+	if err != nil {
+		return err
+	}
+	script, err := addr.LockingScript()
+	if err != nil {
+		return err
+	}
+	for i, out := range tx.outputs {
+		if bytes.Equal(out.Script, script) {
+			h.funding = tx
+			h.n = uint32(i)
+			return nil
+		}
+	}
+	return xerror.NewError(Errors, ER_HTLC_FUNDING_OUTPUT_MISSING, tx.ID())
+}
+
+// BuildClaim -- builds the claim transaction of the receiver which spends the HTLC to the address with the preimage.
+func (h *HTLC) BuildClaim(to Address, fees uint64, preimage []byte, key *xcrypto.PrvKey) (*Transaction, error) {
+	script := *h.script
+	if err := script.SetPreimage(preimage); err != nil {
+		return nil, err
+	}
+	return h.spend(&script, to, fees, key)
+}
+
+// BuildRefund -- builds the refund transaction of the sender which spends the HTLC to the address after the timeout.
+// The tx locktime or the input sequence is set to the timeout.
+func (h *HTLC) BuildRefund(to Address, fees uint64, key *xcrypto.PrvKey) (*Transaction, error) {
+	script := *h.script
+	script.preimage = nil
+	return h.spend(&script, to, fees, key)
+}
+
+// ExtractPreimage -- extracts the preimage from the claim transaction seen on the chain.
+func (h *HTLC) ExtractPreimage(claimed *Transaction) ([]byte, error) {
+	if h.funding == nil {
+		return nil, xerror.NewError(Errors, ER_HTLC_FUNDING_MISSING)
+	}
+	for _, in := range claimed.inputs {
+		if !bytes.Equal(in.Hash, h.funding.Hash()) || in.Index != h.n {
+			continue
+		}
+		// The claim is <sig> <preimage> OP_TRUE <redeem>, or <sig> <preimage> <leaf> <control block>.
+		items := in.Witness
+		if h.form == HTLCP2SH {
+			instrs, err := xvm.NewScriptReader(in.RawUnlockingScript).AllInstructions()
+			if err != nil {
+				return nil, err
+			}
+			items = nil
+			for _, instr := range instrs {
+				items = append(items, instr.Data())
+			}
+		}
+		if len(items) == 4 && bytes.Equal(xcrypto.Sha256(items[1]), h.script.hash) {
+			return items[1], nil
+		}
+	}
+	return nil, xerror.NewError(Errors, ER_HTLC_CLAIM_INPUT_MISMATCH, claimed.ID())
+}
+
+// spend -- builds and signs the transaction which spends the HTLC output by the claim or the refund of the script.
+func (h *HTLC) spend(script *HTLCScript, to Address, fees uint64, key *xcrypto.PrvKey) (*Transaction, error) {
+	if h.funding == nil {
+		return nil, xerror.NewError(Errors, ER_HTLC_FUNDING_MISSING)
+	}
+	out := h.funding.outputs[h.n]
+	if out.Value <= fees {
+		return nil, xerror.NewError(Errors, ER_HTLC_AMOUNT_NOT_ENOUGH, out.Value, fees)
+	}
+	toScript, err := to.LockingScript()
+	if err != nil {
+		return nil, err
+	}
+	var redeem []byte
+	if h.form != HTLCP2TR {
+		if redeem, err = script.GetRawLockingScriptBytes(); err != nil {
+			return nil, err
+		}
+	}
+	txin, err := NewTxIn(h.funding.Hash(), h.n, out.Value, out.Script, redeem)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := NewTransaction()
+	if script.preimage == nil {
+		if script.relative {
+			tx.SetVersion(RelativeLockTimeVersion)
+			txin.Sequence = script.timeout
+		} else {
+			tx.SetLockTime(script.timeout)
+			txin.Sequence = swapRefundSequence
+		}
+	}
+	tx.AddInput(txin)
+	tx.AddOutput(NewTxOut(out.Value-fees, toScript))
+
+	// Sign.
+	switch h.form {
+	case HTLCP2SH:
+		sig, err := tx.RawSignature(0, SigHashAll, key)
+		if err != nil {
+			return nil, err
+		}
+		signs := []PubKeySign{{PubKey: key.PubKey().SerializeCompressed(), Signature: sig}}
+		if txin.RawUnlockingScript, err = script.GetRawUnlockingScriptBytes(signs, redeem); err != nil {
+			return nil, err
+		}
+	case HTLCP2WSH:
+		sig, err := tx.WitnessSignature(0, SigHashAll, key)
+		if err != nil {
+			return nil, err
+		}
+		signs := []PubKeySign{{PubKey: key.PubKey().SerializeCompressed(), Signature: sig}}
+		if txin.RawUnlockingScript, err = script.GetRawUnlockingScriptBytes(signs, redeem); err != nil {
+			return nil, err
+		}
+		if txin.Witness, err = script.GetWitnessUnlockingScriptBytes(signs, redeem); err != nil {
+			return nil, err
+		}
+	case HTLCP2TR:
+		claim, err := script.ClaimLeaf()
+		if err != nil {
+			return nil, err
+		}
+		refund, err := script.RefundLeaf()
+		if err != nil {
+			return nil, err
+		}
+		leaf, sibling := refund, claim
+		if script.preimage != nil {
+			leaf, sibling = claim, refund
+		}
+		root := TapBranchHash(TapLeafHash(leaf), TapLeafHash(sibling))
+		control, err := NewTaprootControlBlock(htlcNUMSKey(), root, TapLeafHash(sibling))
+		if err != nil {
+			return nil, err
+		}
+		sig, err := tx.TapscriptSignature(0, SigHashDefault, TapLeafHash(leaf), key)
+		if err != nil {
+			return nil, err
+		}
+		// The leaves have no branch selector: <sig> <preimage> <leaf> <control block>, or <sig> <leaf> <control block>.
+		txin.Witness = [][]byte{sig}
+		if script.preimage != nil {
+			txin.Witness = append(txin.Witness, script.preimage)
+		}
+		txin.Witness = append(txin.Witness, leaf, control)
+	}
+
+	if err := tx.Verify(); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// htlcNUMSKey -- returns the internal key without the known private key.
+func htlcNUMSKey() *xcrypto.PubKey {
+	key, _ := hex.DecodeString(htlcInternalKey)
+	pub, _ := xcrypto.PubKeyFromXOnly(key)
+	return pub
+}
+
+// newHTLCScriptFromInstructions -- the HTLC script of the instructions matched by isHTLC.
+func newHTLCScriptFromInstructions(instrs []xvm.Instruction) (*HTLCScript, error) {
+	receiver, err := xcrypto.PubKeyFromBytes(instrs[7].Data())
+	if err != nil {
+		return nil, err
+	}
+	sender, err := xcrypto.PubKeyFromBytes(instrs[12].Data())
+	if err != nil {
+		return nil, err
+	}
+	timeout := int64(0)
+	if isSmallInt(&instrs[9]) {
+		timeout = int64(asSmallInt(&instrs[9]))
+	} else {
+		n, err := xvm.MakeScriptNum(instrs[9].Data(), 5)
+		if err != nil {
+			return nil, err
+		}
+		timeout = int64(n)
+	}
+	if timeout < 0 || timeout > 0xffffffff {
+		return nil, xerror.NewError(Errors, ER_HTLC_SCRIPT_MALFORMED, timeout)
+	}
+	if instrs[10].OpCode() == xvm.OP_CHECKSEQUENCEVERIFY {
+		return NewRelativeHTLCScript(instrs[5].Data(), receiver, sender, uint32(timeout)), nil
+	}
+	return NewHTLCScript(instrs[5].Data(), receiver, sender, uint32(timeout)), nil
+}
+
+// isHTLC --
+// returns true if the script passed is the HTLC script, false otherwise.
+func isHTLC(instrs []xvm.Instruction) bool {
+	if len(instrs) != 15 {
+		return false
+	}
+	isPubKey := func(instr *xvm.Instruction) bool {
+		return instr.OpCode() == xvm.OP_DATA_33
+	}
+	return instrs[0].OpCode() == xvm.OP_IF &&
+		instrs[1].OpCode() == xvm.OP_SIZE &&
+		instrs[2].OpCode() == xvm.OP_DATA_1 && bytes.Equal(instrs[2].Data(), []byte{htlcPreimageSize}) &&
+		instrs[3].OpCode() == xvm.OP_EQUALVERIFY &&
+		instrs[4].OpCode() == xvm.OP_SHA256 &&
+		instrs[5].OpCode() == xvm.OP_DATA_32 &&
+		instrs[6].OpCode() == xvm.OP_EQUALVERIFY &&
+		isPubKey(&instrs[7]) &&
+		instrs[8].OpCode() == xvm.OP_ELSE &&
+		(isSmallInt(&instrs[9]) || (instrs[9].OpCode() >= xvm.OP_DATA_1 && instrs[9].OpCode() <= xvm.OP_DATA_5)) &&
+		(instrs[10].OpCode() == xvm.OP_CHECKLOCKTIMEVERIFY || instrs[10].OpCode() == xvm.OP_CHECKSEQUENCEVERIFY) &&
+		instrs[11].OpCode() == xvm.OP_DROP &&
+		isPubKey(&instrs[12]) &&
+		instrs[13].OpCode() == xvm.OP_ENDIF &&
+		instrs[14].OpCode() == xvm.OP_CHECKSIG
+}
@@ -0,0 +1,178 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xcore/bip32"
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTLCScript(t *testing.T) {
+	aliceKey := bip32.NewHDKey([]byte("this.is.alice.seed.at.2018"))
+	bobKey := bip32.NewHDKey([]byte("this.is.bob.seed.at.2018"))
+	preimage := xcrypto.Sha256([]byte("this.is.the.swap.secret"))
+	hash := xcrypto.Sha256(preimage)
+
+	scripts := []*HTLCScript{
+		NewHTLCScript(hash, bobKey.PublicKey(), aliceKey.PublicKey(), 600000),
+		NewHTLCScript(hash, bobKey.PublicKey(), aliceKey.PublicKey(), 10),
+		NewRelativeHTLCScript(hash, bobKey.PublicKey(), aliceKey.PublicKey(), NewSequenceFromBlocks(144)),
+	}
+	for _, script := range scripts {
+		locking, err := script.GetRawLockingScriptBytes()
+		assert.Nil(t, err)
+
+		parsed, err := ParseHTLCScript(locking)
+		assert.Nil(t, err)
+		assert.Equal(t, script.Hash(), parsed.Hash())
+		assert.Equal(t, script.Timeout(), parsed.Timeout())
+		assert.Equal(t, script.IsRelative(), parsed.IsRelative())
+		assert.Equal(t, script.Receiver().SerializeCompressed(), parsed.Receiver().SerializeCompressed())
+		assert.Equal(t, script.Sender().SerializeCompressed(), parsed.Sender().SerializeCompressed())
+	}
+
+	// Preimage.
+	script := scripts[0]
+	assert.Nil(t, script.SetPreimage(preimage))
+	err := script.SetPreimage(hash)
+	assert.EqualError(t, err, fmt.Sprintf("htlc.preimage[%x].does.not.match.the.hash[%x] (errno 6005) (state THT00)", hash, hash))
+
+	// Malformed.
+	_, err = ParseHTLCScript([]byte{0x51})
+	assert.EqualError(t, err, "htlc.script[OP_1].malformed (errno 6001) (state THT00)")
+
+	// Addresses.
+	for _, form := range []HTLCForm{HTLCP2SH, HTLCP2WSH, HTLCP2TR} {
+		addr, err := script.Address(form)
+		assert.Nil(t, err)
+		locking, err := addr.LockingScript()
+		assert.Nil(t, err)
+		parsed, err := ParseLockingScript(locking)
+		assert.Nil(t, err)
+		assert.Equal(t, addr.ToString(network.TestNet), parsed.GetAddress().ToString(network.TestNet))
+	}
+	_, err = script.Address(HTLCForm(9))
+	assert.EqualError(t, err, "htlc.form[unknown].unknown (errno 6002) (state THT00)")
+	assert.Equal(t, "p2tr", HTLCP2TR.String())
+}
+
+func TestHTLC(t *testing.T) {
+	aliceKey := bip32.NewHDKey([]byte("this.is.alice.seed.at.2018"))
+	alice := NewPayToWitnessV0PubKeyHashAddress(aliceKey.PublicKey().Hash160())
+	aliceScript, _ := alice.LockingScript()
+	bobKey := bip32.NewHDKey([]byte("this.is.bob.seed.at.2018"))
+	bob := NewPayToWitnessV0PubKeyHashAddress(bobKey.PublicKey().Hash160())
+	preimage := xcrypto.Sha256([]byte("this.is.the.swap.secret"))
+	hash := xcrypto.Sha256(preimage)
+
+	tests := []struct {
+		name   string
+		script *HTLCScript
+		form   HTLCForm
+	}{
+		{"p2sh.cltv", NewHTLCScript(hash, bobKey.PublicKey(), aliceKey.PublicKey(), 600000), HTLCP2SH},
+		{"p2wsh.cltv", NewHTLCScript(hash, bobKey.PublicKey(), aliceKey.PublicKey(), 600000), HTLCP2WSH},
+		{"p2wsh.csv", NewRelativeHTLCScript(hash, bobKey.PublicKey(), aliceKey.PublicKey(), NewSequenceFromBlocks(144)), HTLCP2WSH},
+		{"p2tr.cltv", NewHTLCScript(hash, bobKey.PublicKey(), aliceKey.PublicKey(), 600000), HTLCP2TR},
+		{"p2tr.csv", NewRelativeHTLCScript(hash, bobKey.PublicKey(), aliceKey.PublicKey(), NewSequenceFromBlocks(144)), HTLCP2TR},
+	}
+	for i, test := range tests {
+		coin := NewCoinBuilder().AddOutput(fmt.Sprintf("%064x", i+1), 0, 100000, fmt.Sprintf("%x", aliceScript)).ToCoins()[0]
+		htlc := NewHTLC(test.script, test.form)
+
+		// Not funded.
+		_, err := htlc.BuildRefund(alice, 1000, aliceKey.PrivateKey())
+		assert.EqualError(t, err, "htlc.funding.transaction.missing (errno 6003) (state THT00)", test.name)
+
+		// Alice funds the HTLC.
+		funding, err := htlc.BuildFunding(coin, aliceKey.PrivateKey(), 50000, alice, 1000)
+		assert.Nil(t, err, test.name)
+		assert.Nil(t, funding.Verify(), test.name)
+
+		// Bob claims with the preimage.
+		claim, err := htlc.BuildClaim(bob, 1000, preimage, bobKey.PrivateKey())
+		assert.Nil(t, err, test.name)
+		assert.Nil(t, claim.Verify(), test.name)
+		assert.Equal(t, uint64(49000), claim.Outputs()[0].Value, test.name)
+
+		// The tapscript signature with the explicit or an invalid hash type fails.
+		if test.form == HTLCP2TR {
+			sig := claim.inputs[0].Witness[0]
+			for _, hashType := range []byte{0x00, 0x84} {
+				claim.inputs[0].Witness[0] = append(sig[:64:64], hashType)
+				assert.NotNil(t, claim.Verify(), test.name)
+			}
+			claim.inputs[0].Witness[0] = sig
+		}
+
+		// Alice learns the preimage from the claim.
+		extracted, err := htlc.ExtractPreimage(claim)
+		assert.Nil(t, err, test.name)
+		assert.Equal(t, preimage, extracted, test.name)
+
+		// The claim round trips.
+		decoded := NewTransaction()
+		if test.form == HTLCP2SH {
+			assert.Nil(t, decoded.DeserializeNoWitness(claim.Serialize()), test.name)
+		} else {
+			assert.Nil(t, decoded.Deserialize(claim.Serialize()), test.name)
+		}
+		assert.Equal(t, claim.ID(), decoded.ID(), test.name)
+		extracted, err = htlc.ExtractPreimage(decoded)
+		assert.Nil(t, err, test.name)
+		assert.Equal(t, preimage, extracted, test.name)
+
+		// The wrong preimage.
+		_, err = htlc.BuildClaim(bob, 1000, hash, bobKey.PrivateKey())
+		assert.NotNil(t, err, test.name)
+
+		// Alice can not claim.
+		_, err = htlc.BuildClaim(alice, 1000, preimage, aliceKey.PrivateKey())
+		assert.NotNil(t, err, test.name)
+
+		// Alice refunds after the timeout.
+		refund, err := htlc.BuildRefund(alice, 1000, aliceKey.PrivateKey())
+		assert.Nil(t, err, test.name)
+		assert.Nil(t, refund.Verify(), test.name)
+		if test.script.IsRelative() {
+			assert.Equal(t, uint32(2), refund.Version(), test.name)
+			assert.Equal(t, uint32(144), refund.Inputs()[0].Sequence, test.name)
+			assert.False(t, refund.IsRelativeLockFinal(0, 100, 0, 243, 0), test.name)
+			assert.True(t, refund.IsRelativeLockFinal(0, 100, 0, 244, 0), test.name)
+		} else {
+			assert.Equal(t, uint32(600000), refund.LockTime(), test.name)
+			assert.False(t, refund.IsFinal(600000, 0), test.name)
+			assert.True(t, refund.IsFinal(600001, 0), test.name)
+		}
+		_, err = htlc.ExtractPreimage(refund)
+		assert.EqualError(t, err, fmt.Sprintf("htlc.claim.transaction[%v].does.not.spend.the.funding (errno 6007) (state THT00)", refund.ID()), test.name)
+
+		// The refund before the timeout fails.
+		if test.script.IsRelative() {
+			refund.inputs[0].Sequence = NewSequenceFromBlocks(143)
+		} else {
+			refund.SetLockTime(599999)
+		}
+		assert.NotNil(t, refund.Verify(), test.name)
+
+		// Bob can not refund.
+		_, err = htlc.BuildRefund(bob, 1000, bobKey.PrivateKey())
+		assert.NotNil(t, err, test.name)
+
+		// Fees.
+		_, err = htlc.BuildRefund(alice, 50000, aliceKey.PrivateKey())
+		assert.EqualError(t, err, "htlc.amount[50000].not.enough.for.fees[50000] (errno 6006) (state THT00)", test.name)
+
+		// The funding does not pay to the HTLC.
+		err = htlc.SetFunding(claim)
+		assert.EqualError(t, err, fmt.Sprintf("htlc.funding.transaction[%v].does.not.pay.to.the.htlc (errno 6004) (state THT00)", claim.ID()), test.name)
+	}
+}
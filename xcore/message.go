@@ -0,0 +1,352 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"bytes"
+	"encoding/base64"
+
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/keyfuse/tokucore/xerror"
+	"github.com/keyfuse/tokucore/xvm"
+)
+
+const (
+	// messageMagic -- the prefix of the legacy signed message hash.
+	messageMagic = "Bitcoin Signed Message:\n"
+
+	// messageTag -- the tag of the BIP322 message hash.
+	messageTag = "BIP0322-signed-message"
+)
+
+// The BIP137 header classes, the header is 27 + 4*class + recid.
+// https://github.com/bitcoin/bips/blob/master/bip-0137.mediawiki
+const (
+	messageHeaderBase        byte = 27
+	messageHeaderRecIDs      byte = 4
+	messageHeaderLimit       byte = 43
+	messageClassUncompressed byte = 0
+	messageClassCompressed   byte = 1
+	messageClassP2SHP2WPKH   byte = 2
+	messageClassP2WPKH       byte = 3
+)
+
+// messageHash -- the double sha256 of the magic and the message, both var-string.
+func messageHash(msg string) []byte {
+	buffer := xbase.NewBuffer()
+	buffer.WriteVarString(messageMagic)
+	buffer.WriteVarString(msg)
+	return xcrypto.DoubleSha256(buffer.Bytes())
+}
+
+// p2shP2WPKHRedeemScript -- the redeem script of the P2SH-P2WPKH: 0 <hash160(pubkey)>.
+func p2shP2WPKHRedeemScript(pub *xcrypto.PubKey) ([]byte, error) {
+	return NewPayToWitnessV0PubKeyHashScript(pub.Hash160()).GetRawLockingScriptBytes()
+}
+
+// messageKeyCompressed -- checks the pub owns the address and returns whether the pub is serialized compressed.
+// The P2SH address is taken as the P2SH-P2WPKH, the P2TR address must be the output key.
+func messageKeyCompressed(addr Address, pub *xcrypto.PubKey) (bool, error) {
+	var match bool
+	var compressed bool
+
+	switch addr.(type) {
+	case *PayToPubKeyHashAddress:
+		switch {
+		case bytes.Equal(pub.Hash160(), addr.Hash160()):
+			match, compressed = true, true
+		case bytes.Equal(xcrypto.Hash160(pub.SerializeUncompressed()), addr.Hash160()):
+			match, compressed = true, false
+		}
+	case *PayToScriptHashAddress:
+		redeem, err := p2shP2WPKHRedeemScript(pub)
+		if err != nil {
+			return false, err
+		}
+		match, compressed = bytes.Equal(xcrypto.Hash160(redeem), addr.Hash160()), true
+	case *PayToWitnessV0PubKeyHashAddress:
+		match, compressed = bytes.Equal(pub.Hash160(), addr.Hash160()), true
+	case *PayToTaprootAddress:
+		match, compressed = bytes.Equal(pub.SerializeXOnly(), addr.Hash160()), true
+	default:
+		return false, xerror.NewError(Errors, ER_MESSAGE_ADDRESS_TYPE_UNSUPPORTED, addr)
+	}
+	if !match {
+		return false, xerror.NewError(Errors, ER_MESSAGE_KEY_ADDRESS_MISMATCH, addr.Hash160())
+	}
+	return compressed, nil
+}
+
+// SignMessage -- signs the message with the legacy "Bitcoin Signed Message:\n" format, returns the base64 signature.
+// The header byte of the compact signature follows BIP137 for the P2PKH, P2SH-P2WPKH and P2WPKH addresses.
+func SignMessage(prv *xcrypto.PrvKey, addr Address, msg string) (string, error) {
+	var class byte
+
+	switch addr.(type) {
+	case *PayToPubKeyHashAddress:
+	case *PayToScriptHashAddress:
+		class = messageClassP2SHP2WPKH
+	case *PayToWitnessV0PubKeyHashAddress:
+		class = messageClassP2WPKH
+	default:
+		return "", xerror.NewError(Errors, ER_MESSAGE_ADDRESS_TYPE_UNSUPPORTED, addr)
+	}
+
+	compressed, err := messageKeyCompressed(addr, prv.PubKey())
+	if err != nil {
+		return "", err
+	}
+	signature, err := xcrypto.EcdsaSignCompact(prv, messageHash(msg), compressed)
+	if err != nil {
+		return "", err
+	}
+
+	// The compact header is already the P2PKH one.
+	if class > messageClassCompressed {
+		signature[0] += (class - messageClassCompressed) * messageHeaderRecIDs
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// VerifyMessage -- verifies the base64 legacy signature of the message is signed by the key of the address.
+// The compressed P2PKH header(31-34) is also accepted for the segwit addresses as most wallets sign them so.
+func VerifyMessage(addr Address, msg string, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return xerror.NewError(Errors, ER_MESSAGE_SIGNATURE_MALFORMED, err)
+	}
+	if len(sig) != xcrypto.CompactSignatureSize {
+		return xerror.NewError(Errors, ER_MESSAGE_SIGNATURE_MALFORMED, len(sig))
+	}
+
+	header := sig[0]
+	if header < messageHeaderBase || header >= messageHeaderLimit {
+		return xerror.NewError(Errors, ER_MESSAGE_SIGNATURE_MALFORMED, header)
+	}
+	class := (header - messageHeaderBase) / messageHeaderRecIDs
+
+	var headerMatch bool
+	switch addr.(type) {
+	case *PayToPubKeyHashAddress:
+		headerMatch = class == messageClassUncompressed || class == messageClassCompressed
+	case *PayToScriptHashAddress:
+		headerMatch = class == messageClassCompressed || class == messageClassP2SHP2WPKH
+	case *PayToWitnessV0PubKeyHashAddress:
+		headerMatch = class == messageClassCompressed || class == messageClassP2WPKH
+	default:
+		return xerror.NewError(Errors, ER_MESSAGE_ADDRESS_TYPE_UNSUPPORTED, addr)
+	}
+	if !headerMatch {
+		return xerror.NewError(Errors, ER_MESSAGE_SIGNATURE_HEADER_MISMATCH, header, addr)
+	}
+
+	// Back to the compact header for the recovery.
+	compact := append([]byte{}, sig...)
+	if class > messageClassCompressed {
+		compact[0] -= (class - messageClassCompressed) * messageHeaderRecIDs
+	}
+	pub, compressed, err := xcrypto.RecoverPubKey(messageHash(msg), compact)
+	if err != nil {
+		return err
+	}
+	keyCompressed, err := messageKeyCompressed(addr, pub)
+	if err != nil || keyCompressed != compressed {
+		return xerror.NewError(Errors, ER_MESSAGE_VERIFY_FAILED)
+	}
+	return nil
+}
+
+// messageToSpend -- builds the BIP322 virtual to_spend transaction paying to the script.
+// https://github.com/bitcoin/bips/blob/master/bip-0322.mediawiki
+func messageToSpend(script []byte, msg string) (*Transaction, error) {
+	unlocking, err := xvm.NewScriptBuilder().
+		AddOp(xvm.OP_0).
+		AddData(xcrypto.TaggedHash(messageTag, []byte(msg))).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := NewTransaction()
+	tx.SetVersion(0)
+	tx.AddInput(&TxIn{
+		Hash:               make([]byte, hashSize),
+		Index:              0xffffffff,
+		Sequence:           0,
+		RawUnlockingScript: unlocking,
+	})
+	tx.AddOutput(NewTxOut(0, script))
+	return tx, nil
+}
+
+// messageToSign -- builds the BIP322 virtual to_sign transaction spending the to_spend output.
+func messageToSign(toSpend *Transaction, script []byte) (*Transaction, error) {
+	in, err := NewTxIn(toSpend.Hash(), 0, 0, script, nil)
+	if err != nil {
+		return nil, err
+	}
+	in.Sequence = 0
+
+	opReturn, err := xvm.NewScriptBuilder().AddOp(xvm.OP_RETURN).Script()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := NewTransaction()
+	tx.SetVersion(0)
+	tx.AddInput(in)
+	tx.AddOutput(NewTxOut(0, opReturn))
+	return tx, nil
+}
+
+// messageSignToSign -- builds and signs the to_sign transaction of the message.
+// Supports the P2PKH, P2WPKH and P2TR key path(the prv is the output key) addresses.
+func messageSignToSign(prv *xcrypto.PrvKey, addr Address, msg string) (*Transaction, error) {
+	hashType := SigHashAll
+	switch addr.(type) {
+	case *PayToPubKeyHashAddress, *PayToWitnessV0PubKeyHashAddress:
+	case *PayToTaprootAddress:
+		hashType = SigHashDefault
+	default:
+		return nil, xerror.NewError(Errors, ER_MESSAGE_ADDRESS_TYPE_UNSUPPORTED, addr)
+	}
+
+	compressed, err := messageKeyCompressed(addr, prv.PubKey())
+	if err != nil {
+		return nil, err
+	}
+	script, err := addr.LockingScript()
+	if err != nil {
+		return nil, err
+	}
+	toSpend, err := messageToSpend(script, msg)
+	if err != nil {
+		return nil, err
+	}
+	toSign, err := messageToSign(toSpend, script)
+	if err != nil {
+		return nil, err
+	}
+	if err := toSign.SignIndex(0, compressed, hashType, prv); err != nil {
+		return nil, err
+	}
+	return toSign, nil
+}
+
+// SignMessageBIP322Simple -- signs the message with the BIP322 simple proof, returns the base64 witness stack.
+// The simple proof is only for the segwit addresses.
+func SignMessageBIP322Simple(prv *xcrypto.PrvKey, addr Address, msg string) (string, error) {
+	if _, ok := addr.(*PayToPubKeyHashAddress); ok {
+		return "", xerror.NewError(Errors, ER_MESSAGE_ADDRESS_TYPE_UNSUPPORTED, addr)
+	}
+	toSign, err := messageSignToSign(prv, addr, msg)
+	if err != nil {
+		return "", err
+	}
+
+	witness := toSign.inputs[0].Witness
+	buffer := xbase.NewBuffer()
+	buffer.WriteVarInt(uint64(len(witness)))
+	for _, wit := range witness {
+		buffer.WriteVarBytes(wit)
+	}
+	return base64.StdEncoding.EncodeToString(buffer.Bytes()), nil
+}
+
+// SignMessageBIP322Full -- signs the message with the BIP322 full proof, returns the base64 to_sign transaction.
+func SignMessageBIP322Full(prv *xcrypto.PrvKey, addr Address, msg string) (string, error) {
+	toSign, err := messageSignToSign(prv, addr, msg)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(toSign.Serialize()), nil
+}
+
+// VerifyMessageBIP322Simple -- verifies the base64 BIP322 simple proof of the message for the address.
+func VerifyMessageBIP322Simple(addr Address, msg string, proof string) error {
+	data, err := base64.StdEncoding.DecodeString(proof)
+	if err != nil {
+		return xerror.NewError(Errors, ER_MESSAGE_SIGNATURE_MALFORMED, err)
+	}
+
+	buffer := xbase.NewBufferReader(data)
+	count, err := buffer.ReadVarInt()
+	if err != nil {
+		return xerror.NewError(Errors, ER_MESSAGE_SIGNATURE_MALFORMED, err)
+	}
+	var witness [][]byte
+	for i := uint64(0); i < count; i++ {
+		wit, err := buffer.ReadVarBytes()
+		if err != nil {
+			return xerror.NewError(Errors, ER_MESSAGE_SIGNATURE_MALFORMED, err)
+		}
+		witness = append(witness, wit)
+	}
+	if !buffer.End() {
+		return xerror.NewError(Errors, ER_MESSAGE_SIGNATURE_MALFORMED, len(buffer.Remaining()))
+	}
+
+	script, err := addr.LockingScript()
+	if err != nil {
+		return err
+	}
+	toSpend, err := messageToSpend(script, msg)
+	if err != nil {
+		return err
+	}
+	toSign, err := messageToSign(toSpend, script)
+	if err != nil {
+		return err
+	}
+	toSign.inputs[0].Witness = witness
+	return messageVerifyToSign(toSign, script)
+}
+
+// VerifyMessageBIP322Full -- verifies the base64 BIP322 full proof of the message for the address.
+// The to_sign must spend the to_spend output with one OP_RETURN output, the version, locktime and
+// sequence are chosen by the signer.
+func VerifyMessageBIP322Full(addr Address, msg string, proof string) error {
+	data, err := base64.StdEncoding.DecodeString(proof)
+	if err != nil {
+		return xerror.NewError(Errors, ER_MESSAGE_SIGNATURE_MALFORMED, err)
+	}
+
+	// The witness marker is at the position of the input count.
+	toSign := NewTransaction()
+	if len(data) > 4 && data[4] == witnessMarker {
+		err = toSign.Deserialize(data)
+	} else {
+		err = toSign.DeserializeNoWitness(data)
+	}
+	if err != nil {
+		return xerror.NewError(Errors, ER_MESSAGE_SIGNATURE_MALFORMED, err)
+	}
+
+	script, err := addr.LockingScript()
+	if err != nil {
+		return err
+	}
+	toSpend, err := messageToSpend(script, msg)
+	if err != nil {
+		return err
+	}
+	toSpendHash := toSpend.Hash()
+	if len(toSign.inputs) != 1 || len(toSign.outputs) != 1 ||
+		!bytes.Equal(toSign.inputs[0].Hash, toSpendHash) || toSign.inputs[0].Index != 0 ||
+		toSign.outputs[0].Value != 0 || !bytes.Equal(toSign.outputs[0].Script, []byte{xvm.OP_RETURN}) {
+		return xerror.NewError(Errors, ER_MESSAGE_PROOF_MISMATCH, toSpendHash)
+	}
+	return messageVerifyToSign(toSign, script)
+}
+
+// messageVerifyToSign -- verifies the to_sign input against the script through the transaction verifier.
+func messageVerifyToSign(toSign *Transaction, script []byte) error {
+	if err := toSign.SetTxIn(0, 0, script, nil); err != nil {
+		return err
+	}
+	return toSign.Verify()
+}
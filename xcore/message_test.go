@@ -0,0 +1,212 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageSignVerify(t *testing.T) {
+	msg := "tokucore.signed.message"
+	prv := xcrypto.PrvKeyFromBytes([]byte("this.is.alice.message.key"))
+	pub := prv.PubKey()
+	redeem, err := p2shP2WPKHRedeemScript(pub)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name    string
+		addr    Address
+		headers []byte
+	}{
+		{"p2pkh.uncompressed", NewPayToPubKeyHashAddress(xcrypto.Hash160(pub.SerializeUncompressed())), []byte{27, 28, 29, 30}},
+		{"p2pkh.compressed", NewPayToPubKeyHashAddress(pub.Hash160()), []byte{31, 32, 33, 34}},
+		{"p2sh-p2wpkh", NewPayToScriptHashAddress(xcrypto.Hash160(redeem)), []byte{35, 36, 37, 38}},
+		{"p2wpkh", NewPayToWitnessV0PubKeyHashAddress(pub.Hash160()), []byte{39, 40, 41, 42}},
+	}
+	for _, test := range tests {
+		signature, err := SignMessage(prv, test.addr, msg)
+		assert.Nil(t, err, test.name)
+		sig, err := base64.StdEncoding.DecodeString(signature)
+		assert.Nil(t, err)
+		assert.Contains(t, test.headers, sig[0], test.name)
+
+		err = VerifyMessage(test.addr, msg, signature)
+		assert.Nil(t, err, test.name)
+		err = VerifyMessage(test.addr, msg+".", signature)
+		assert.NotNil(t, err, test.name)
+	}
+
+	// The segwit addresses accept the compressed P2PKH header.
+	{
+		p2pkh := NewPayToPubKeyHashAddress(pub.Hash160())
+		signature, err := SignMessage(prv, p2pkh, msg)
+		assert.Nil(t, err)
+		assert.Nil(t, VerifyMessage(tests[2].addr, msg, signature))
+		assert.Nil(t, VerifyMessage(tests[3].addr, msg, signature))
+	}
+}
+
+func TestMessageErrors(t *testing.T) {
+	msg := "tokucore.signed.message"
+	prv := xcrypto.PrvKeyFromBytes([]byte("this.is.alice.message.key"))
+	bob := xcrypto.PrvKeyFromBytes([]byte("this.is.bob.message.key"))
+	p2pkh := NewPayToPubKeyHashAddress(prv.PubKey().Hash160())
+	p2wpkh := NewPayToWitnessV0PubKeyHashAddress(prv.PubKey().Hash160())
+	p2tr := NewPayToTaprootAddress(prv.PubKey().SerializeXOnly())
+
+	// Key mismatch.
+	{
+		_, err := SignMessage(bob, p2pkh, msg)
+		assert.Equal(t, "message.key.does.not.own.the.address["+hex.EncodeToString(p2pkh.Hash160())+"] (errno 5502) (state TMS00)", err.Error())
+	}
+
+	// Address unsupported.
+	{
+		_, err := SignMessage(prv, p2tr, msg)
+		assert.Equal(t, "message.address.type[*xcore.PayToTaprootAddress].unsupported (errno 5501) (state TMS00)", err.Error())
+		err = VerifyMessage(p2tr, msg, "")
+		assert.Equal(t, "message.signature.malformed[0] (errno 5503) (state TMS00)", err.Error())
+	}
+
+	// Signature malformed.
+	{
+		err := VerifyMessage(p2pkh, msg, "@@")
+		assert.NotNil(t, err)
+		err = VerifyMessage(p2pkh, msg, base64.StdEncoding.EncodeToString(make([]byte, 65)))
+		assert.Equal(t, "message.signature.malformed[0] (errno 5503) (state TMS00)", err.Error())
+	}
+
+	// Header mismatch.
+	{
+		signature, err := SignMessage(prv, p2wpkh, msg)
+		assert.Nil(t, err)
+		sig, err := base64.StdEncoding.DecodeString(signature)
+		assert.Nil(t, err)
+		err = VerifyMessage(p2pkh, msg, signature)
+		assert.Equal(t, fmt.Sprintf("message.signature.header[%v].mismatch.address.type[*xcore.PayToPubKeyHashAddress] (errno 5504) (state TMS00)", sig[0]), err.Error())
+	}
+
+	// Other key.
+	{
+		signature, err := SignMessage(bob, NewPayToPubKeyHashAddress(bob.PubKey().Hash160()), msg)
+		assert.Nil(t, err)
+		err = VerifyMessage(p2pkh, msg, signature)
+		assert.Equal(t, "message.verify.failed (errno 5505) (state TMS00)", err.Error())
+	}
+}
+
+// https://github.com/bitcoin/bips/blob/master/bip-0322.mediawiki#test-vectors
+func TestMessageBIP322Vectors(t *testing.T) {
+	net := network.MainNet
+	wif, _, err := xbase.Base58CheckDecode("L3VFeEujGtevx9w18HD1fhRbCH67Az2dpCymeRE1SoPK6XQtaN2k")
+	assert.Nil(t, err)
+	prv := xcrypto.PrvKeyFromBytes(wif[:32])
+
+	addr, err := DecodeAddress("bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l", net)
+	assert.Nil(t, err)
+	assert.Equal(t, prv.PubKey().Hash160(), addr.Hash160())
+	script, err := addr.LockingScript()
+	assert.Nil(t, err)
+
+	// Message hashes and the virtual transactions.
+	tests := []struct {
+		msg     string
+		hash    string
+		toSpend string
+		toSign  string
+	}{
+		{"", "c90c269c4f8fcbe6880f72a721ddfbf1914268a794cbb21cfafee13770ae19f1", "c5680aa69bb8d860bf82d4e9cd3504b55dde018de765a91bb566283c545a99a7", "1e9654e951a5ba44c8604c4de6c67fd78a27e81dcadcfe1edf638ba3aaebaed6"},
+		{"Hello World", "f0eb03b1a75ac6d9847f55c624a99169b5dccba2a31f5b23bea77ba270de0a7a", "b79d196740ad5217771c1098fc4a4b51e0535c32236c71f1ea4d61a2d603352b", "88737ae86f2077145f93cc4b153ae9a1cb8d56afa511988c149c5c8c9d93bddf"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.hash, hex.EncodeToString(xcrypto.TaggedHash(messageTag, []byte(test.msg))))
+		toSpend, err := messageToSpend(script, test.msg)
+		assert.Nil(t, err)
+		assert.Equal(t, test.toSpend, toSpend.ID())
+		toSign, err := messageToSign(toSpend, script)
+		assert.Nil(t, err)
+		assert.Equal(t, test.toSign, toSign.ID())
+	}
+
+	// Simple proofs.
+	{
+		err := VerifyMessageBIP322Simple(addr, "", "AkcwRAIgM2gBAQqvZX15ZiysmKmQpDrG83avLIT492QBzLnQIxYCIBaTpOaD20qRlEylyxFSeEA2ba9YOixpX8z46TSDtS40ASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=")
+		assert.Nil(t, err)
+		err = VerifyMessageBIP322Simple(addr, "Hello World", "AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=")
+		assert.Nil(t, err)
+		err = VerifyMessageBIP322Simple(addr, "Hello World", "AkgwRQIhAOzyynlqt93lOKJr+wmmxIens//zPzl9tqIOua93wO6MAiBi5n5EyAcPScOjf1lAqIUIQtr3zKNeavYabHyR8eGhowEhAsfxIAMZZEKUPYWI4BruhAQjzFT8FSFSajuFwrDL1Yhy")
+		assert.Nil(t, err)
+		err = VerifyMessageBIP322Simple(addr, "", "AkgwRQIhAOzyynlqt93lOKJr+wmmxIens//zPzl9tqIOua93wO6MAiBi5n5EyAcPScOjf1lAqIUIQtr3zKNeavYabHyR8eGhowEhAsfxIAMZZEKUPYWI4BruhAQjzFT8FSFSajuFwrDL1Yhy")
+		assert.NotNil(t, err)
+	}
+
+	// Taproot key path.
+	{
+		p2tr, err := DecodeAddress("bc1ppv609nr0vr25u07u95waq5lucwfm6tde4nydujnu8npg4q75mr5sxq8lt3", net)
+		assert.Nil(t, err)
+		err = VerifyMessageBIP322Simple(p2tr, "Hello World", "AUHd69PrJQEv+oKTfZ8l+WROBHuy9HKrbFCJu7U1iK2iiEy1vMU5EfMtjc+VSHM7aU0SDbak5IUZRVno2P5mjSafAQ==")
+		assert.Nil(t, err)
+	}
+}
+
+func TestMessageBIP322SignVerify(t *testing.T) {
+	msg := "tokucore.bip322.message"
+	prv := xcrypto.PrvKeyFromBytes([]byte("this.is.alice.message.key"))
+	bob := xcrypto.PrvKeyFromBytes([]byte("this.is.bob.message.key"))
+	pub := prv.PubKey()
+	p2pkh := NewPayToPubKeyHashAddress(pub.Hash160())
+	p2wpkh := NewPayToWitnessV0PubKeyHashAddress(pub.Hash160())
+	p2tr := NewPayToTaprootAddress(pub.SerializeXOnly())
+
+	for _, addr := range []Address{p2wpkh, p2tr} {
+		proof, err := SignMessageBIP322Simple(prv, addr, msg)
+		assert.Nil(t, err)
+		assert.Nil(t, VerifyMessageBIP322Simple(addr, msg, proof))
+		assert.NotNil(t, VerifyMessageBIP322Simple(addr, msg+".", proof))
+	}
+
+	for _, addr := range []Address{p2pkh, p2wpkh, p2tr} {
+		proof, err := SignMessageBIP322Full(prv, addr, msg)
+		assert.Nil(t, err)
+		assert.Nil(t, VerifyMessageBIP322Full(addr, msg, proof))
+
+		// The to_sign does not spend the to_spend of the message.
+		err = VerifyMessageBIP322Full(addr, msg+".", proof)
+		assert.Contains(t, err.Error(), "message.proof.does.not.spend.the.to_spend")
+	}
+
+	// Errors.
+	{
+		_, err := SignMessageBIP322Simple(prv, p2pkh, msg)
+		assert.Equal(t, "message.address.type[*xcore.PayToPubKeyHashAddress].unsupported (errno 5501) (state TMS00)", err.Error())
+
+		_, err = SignMessageBIP322Full(bob, p2wpkh, msg)
+		assert.NotNil(t, err)
+
+		_, err = SignMessageBIP322Full(prv, NewPayToScriptHashAddress(pub.Hash160()), msg)
+		assert.Equal(t, "message.address.type[*xcore.PayToScriptHashAddress].unsupported (errno 5501) (state TMS00)", err.Error())
+
+		err = VerifyMessageBIP322Simple(p2wpkh, msg, "AQ==")
+		assert.NotNil(t, err)
+
+		err = VerifyMessageBIP322Full(p2wpkh, msg, "AQ==")
+		assert.NotNil(t, err)
+
+		// Bob's proof for alice's address.
+		proof, err := SignMessageBIP322Simple(bob, NewPayToWitnessV0PubKeyHashAddress(bob.PubKey().Hash160()), msg)
+		assert.Nil(t, err)
+		err = VerifyMessageBIP322Simple(p2wpkh, msg, proof)
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,322 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/keyfuse/tokucore/xerror"
+	"github.com/keyfuse/tokucore/xvm"
+)
+
+const (
+	// SequenceFinal -- the sequence opting out of the replacement and the locktime.
+	SequenceFinal = defaultSequence
+
+	// SequenceReplaceable -- the highest sequence signaling the BIP125 replaceability,
+	// the locktime is still enforced.
+	SequenceReplaceable = 0xfffffffd
+
+	// IncrementalRelayFeeRate -- the fee rate(sat/vB) a replacement must pay over the original fees for its own size.
+	IncrementalRelayFeeRate = 1
+)
+
+// IsReplaceable -- returns whether the transaction signals the BIP125 replaceability,
+// any input sequence is less than 0xfffffffe.
+func (tx *Transaction) IsReplaceable() bool {
+	for _, in := range tx.inputs {
+		if in.Sequence <= SequenceReplaceable {
+			return true
+		}
+	}
+	return false
+}
+
+// replacementInput -- the input of the replacement with the keys signing it.
+type replacementInput struct {
+	txin       *TxIn
+	keys       []*xcrypto.PrvKey
+	compressed bool
+}
+
+// ReplacementBuilder -- builds the BIP125 replacement of a transaction paying a higher fee rate.
+// The fees are raised by reducing the change, inputs are added from the extra coins if the change is short.
+// The extra coins must be confirmed, a replacement can not spend new unconfirmed outputs.
+type ReplacementBuilder struct {
+	tx      *Transaction
+	coins   []*Coin
+	extras  []*Coin
+	keys    []*xcrypto.PrvKey
+	change  Address
+	feeRate uint64
+}
+
+// NewReplacementBuilder -- creates the builder of the replacement of the tx,
+// the coins are the outputs spent by the tx inputs in order.
+func NewReplacementBuilder(tx *Transaction, coins ...*Coin) *ReplacementBuilder {
+	return &ReplacementBuilder{
+		tx:    tx,
+		coins: coins,
+	}
+}
+
+// SetChange -- set the change address, its output of the tx is reduced and a new one is added for the extra inputs.
+func (b *ReplacementBuilder) SetChange(addr Address) *ReplacementBuilder {
+	b.change = addr
+	return b
+}
+
+// SetFeeRate -- set the fee rate in sat/vB of the replacement.
+func (b *ReplacementBuilder) SetFeeRate(satPerVByte uint64) *ReplacementBuilder {
+	b.feeRate = satPerVByte
+	return b
+}
+
+// AddCoins -- add the extra coins spent if the change can not pay the fees.
+func (b *ReplacementBuilder) AddCoins(coins ...*Coin) *ReplacementBuilder {
+	b.extras = append(b.extras, coins...)
+	return b
+}
+
+// AddKeys -- add the private keys, each input is signed by the keys of its locking script.
+func (b *ReplacementBuilder) AddKeys(keys ...*xcrypto.PrvKey) *ReplacementBuilder {
+	b.keys = append(b.keys, keys...)
+	return b
+}
+
+// BuildTransaction -- builds and signs the replacement.
+// The fees are the larger of the fee rate and the BIP125 rules 3 and 4:
+// at least the original fees plus the incremental relay fee of the replacement size.
+func (b *ReplacementBuilder) BuildTransaction() (*Transaction, error) {
+	if len(b.coins) != len(b.tx.inputs) {
+		return nil, xerror.NewError(Errors, ER_RBF_COINS_MISMATCH, len(b.coins), len(b.tx.inputs))
+	}
+
+	// Inputs of the original.
+	var inputs []*replacementInput
+	var totalIn int64
+	for i, in := range b.tx.inputs {
+		coin := b.coins[i]
+		if xbase.NewIDToString(in.Hash) != coin.txID || in.Index != coin.n {
+			return nil, xerror.NewError(Errors, ER_RBF_INPUT_COIN_MISMATCH, i, coin.txID, coin.n)
+		}
+		input, err := b.newInput(i, coin, in.RedeemScript)
+		if err != nil {
+			return nil, err
+		}
+		// A lower sequence already signals, and may carry a relative locktime.
+		input.txin.Sequence = in.Sequence
+		if input.txin.Sequence > SequenceReplaceable {
+			input.txin.Sequence = SequenceReplaceable
+		}
+		inputs = append(inputs, input)
+		totalIn += int64(coin.value)
+	}
+
+	// Outputs of the original, the change is taken out.
+	var changeScript []byte
+	if b.change != nil {
+		script, err := b.change.LockingScript()
+		if err != nil {
+			return nil, err
+		}
+		changeScript = script
+	}
+	var txouts []*TxOut
+	var totalOut int64
+	hasChange := false
+	originalFees := totalIn
+	for _, out := range b.tx.outputs {
+		originalFees -= int64(out.Value)
+		if changeScript != nil && !hasChange && bytes.Equal(out.Script, changeScript) {
+			hasChange = true
+			continue
+		}
+		txouts = append(txouts, NewTxOut(out.Value, out.Script))
+		totalOut += int64(out.Value)
+	}
+
+	extras := make([]*Coin, len(b.extras))
+	copy(extras, b.extras)
+	sort.SliceStable(extras, func(i, j int) bool { return extras[i].value > extras[j].value })
+
+	var changeTxOut *TxOut
+	var fees int64
+	for {
+		if hasChange {
+			changeTxOut = NewTxOut(0, changeScript)
+		} else {
+			changeTxOut = nil
+		}
+		weight, err := b.dryRunWeight(inputs, txouts, changeTxOut)
+		if err != nil {
+			return nil, err
+		}
+		fees = EstimateFeesForWeight(weight, b.feeRate)
+		if bip125 := originalFees + EstimateFeesForWeight(weight, IncrementalRelayFeeRate); fees < bip125 {
+			fees = bip125
+		}
+
+		available := totalIn - totalOut
+		if hasChange {
+			change := available - fees
+			if change >= dustThreshold(changeScript) {
+				changeTxOut.Value = uint64(change)
+				break
+			}
+			// The dust change goes to the fees.
+			hasChange = false
+			continue
+		}
+		if available >= fees {
+			fees = available
+			break
+		}
+
+		// More inputs, the excess goes to the change.
+		if len(extras) == 0 {
+			return nil, xerror.NewError(Errors, ER_RBF_FEE_NOT_ENOUGH, fees, available)
+		}
+		if changeScript == nil {
+			return nil, xerror.NewError(Errors, ER_RBF_CHANGE_EMPTY)
+		}
+		input, err := b.newInput(len(inputs), extras[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		input.txin.Sequence = SequenceReplaceable
+		inputs = append(inputs, input)
+		totalIn += int64(extras[0].value)
+		extras = extras[1:]
+		hasChange = true
+	}
+
+	// Build and sign.
+	transaction := NewTransaction()
+	transaction.SetVersion(b.tx.version)
+	transaction.SetLockTime(b.tx.lockTime)
+	for _, input := range inputs {
+		transaction.AddInput(input.txin)
+	}
+	for _, txout := range txouts {
+		transaction.AddOutput(txout)
+	}
+	if changeTxOut != nil {
+		transaction.AddOutput(changeTxOut)
+	}
+	for i, input := range inputs {
+		if err := transaction.SignIndex(i, input.compressed, SigHashAll, input.keys...); err != nil {
+			return nil, err
+		}
+	}
+	return transaction, nil
+}
+
+// newInput -- the input spending the coin with the keys of its locking script.
+func (b *ReplacementBuilder) newInput(idx int, coin *Coin, redeem []byte) (*replacementInput, error) {
+	txid, err := xbase.NewIDFromString(coin.txID)
+	if err != nil {
+		return nil, err
+	}
+	script, err := hex.DecodeString(coin.script)
+	if err != nil {
+		return nil, err
+	}
+	txin, err := NewTxIn(txid, coin.n, coin.value, script, redeem)
+	if err != nil {
+		return nil, err
+	}
+	keys, compressed := matchKeys(script, redeem, b.keys)
+	if len(keys) == 0 {
+		return nil, xerror.NewError(Errors, ER_RBF_SIGN_KEY_MISSING, idx)
+	}
+	return &replacementInput{txin: txin, keys: keys, compressed: compressed}, nil
+}
+
+// dryRunWeight -- the weight of the replacement with dummy signatures.
+func (b *ReplacementBuilder) dryRunWeight(inputs []*replacementInput, txouts []*TxOut, changeTxOut *TxOut) (int64, error) {
+	transaction := NewTransaction()
+	transaction.SetVersion(b.tx.version)
+	transaction.SetLockTime(b.tx.lockTime)
+	for _, input := range inputs {
+		dummy := *input.txin
+		transaction.AddInput(&dummy)
+	}
+	for _, txout := range txouts {
+		transaction.AddOutput(txout)
+	}
+	if changeTxOut != nil {
+		transaction.AddOutput(changeTxOut)
+	}
+	for i, input := range inputs {
+		if err := transaction.DummySignIndex(i, input.compressed, SigHashAll); err != nil {
+			return 0, err
+		}
+	}
+	return int64(transaction.Weight()), nil
+}
+
+// matchKeys -- the keys signing the locking script, in the public key order of the multisig redeem script.
+// The key of the BIP86 taproot output is returned tweaked.
+func matchKeys(script []byte, redeem []byte, keys []*xcrypto.PrvKey) ([]*xcrypto.PrvKey, bool) {
+	if m, _, ok := multiSigParams(redeem); ok {
+		instrs, _ := xvm.NewScriptReader(redeem).AllInstructions()
+		var signers []*xcrypto.PrvKey
+		for _, instr := range instrs[1 : len(instrs)-2] {
+			for _, key := range keys {
+				if len(signers) < m && bytes.Equal(instr.Data(), key.PubKey().SerializeCompressed()) {
+					signers = append(signers, key)
+				}
+			}
+		}
+		if len(signers) < m {
+			return nil, true
+		}
+		return signers, true
+	}
+
+	for _, key := range keys {
+		pub := key.PubKey()
+		candidates := []struct {
+			addr       Address
+			compressed bool
+		}{
+			{NewPayToPubKeyHashAddress(pub.Hash160()), true},
+			{NewPayToPubKeyHashAddress(xcrypto.Hash160(pub.SerializeUncompressed())), false},
+			{NewPayToWitnessV0PubKeyHashAddress(pub.Hash160()), true},
+			{NewPayToTaprootAddress(pub.SerializeXOnly()), true},
+		}
+		for _, candidate := range candidates {
+			if locking, err := candidate.addr.LockingScript(); err == nil && bytes.Equal(locking, script) {
+				return []*xcrypto.PrvKey{key}, candidate.compressed
+			}
+		}
+
+		// The BIP86 output key of the account, signed by the tweaked key.
+		if output, err := xcrypto.TaprootTweakPubKey(pub, nil); err == nil {
+			locking, err := NewPayToTaprootAddress(output.SerializeXOnly()).LockingScript()
+			if err == nil && bytes.Equal(locking, script) {
+				if tweaked, err := xcrypto.TaprootTweakPrvKey(key, nil); err == nil {
+					return []*xcrypto.PrvKey{tweaked}, true
+				}
+			}
+		}
+	}
+	return nil, true
+}
+
+// dustThreshold -- the value of the output below which spending it costs more than a third of it.
+func dustThreshold(script []byte) int64 {
+	spendWeight, err := scriptSpendWeight(script)
+	if err != nil {
+		spendWeight = SpendWeightP2PKH
+	}
+	return EstimateFeesForWeight(int64(outputSize(script)*witnessScaleFactor)+spendWeight, DustRelayFeeRate)
+}
@@ -0,0 +1,222 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/keyfuse/tokucore/xcore/bip32"
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplacementBuilder(t *testing.T) {
+	aliceKey := bip32.NewHDKey([]byte("this.is.alice.seed.at.2018"))
+	alicePrv := aliceKey.PrivateKey()
+	alice := NewPayToWitnessV0PubKeyHashAddress(aliceKey.PublicKey().Hash160())
+	aliceScript, _ := alice.LockingScript()
+	bob := NewPayToPubKeyHashAddress(bip32.NewHDKey([]byte("this.is.bob.seed.at.2018")).PublicKey().Hash160())
+
+	coin := NewCoinBuilder().AddOutput(fmt.Sprintf("%064x", 1), 0, 100000, fmt.Sprintf("%x", aliceScript)).ToCoins()[0]
+	original, err := NewTransactionBuilder().
+		AddCoin(coin).
+		AddKeys(alicePrv).
+		To(bob, 60000).
+		Then().
+		SetChange(alice).
+		SetFeeRate(2).
+		SetReplaceable().
+		Sign().
+		BuildTransaction()
+	assert.Nil(t, err)
+	assert.Nil(t, original.Verify())
+	assert.True(t, original.IsReplaceable())
+	assert.Equal(t, uint32(SequenceReplaceable), original.Inputs()[0].Sequence)
+	originalFees := original.Fees()
+
+	// Bump to 10 sat/vB by the change.
+	{
+		tx, err := NewReplacementBuilder(original, coin).
+			SetChange(alice).
+			SetFeeRate(10).
+			AddKeys(alicePrv).
+			BuildTransaction()
+		assert.Nil(t, err)
+		assert.Nil(t, tx.Verify())
+		assert.True(t, tx.IsReplaceable())
+		assert.Equal(t, 2, len(tx.Outputs()))
+		assert.Equal(t, uint64(60000), tx.Outputs()[0].Value)
+		assert.True(t, tx.Fees() >= tx.Vsize()*10)
+		assert.True(t, tx.Fees() >= originalFees+tx.Vsize()*IncrementalRelayFeeRate)
+		assert.NotEqual(t, original.ID(), tx.ID())
+	}
+
+	// BIP125 rule 4 over a lower fee rate.
+	{
+		tx, err := NewReplacementBuilder(original, coin).
+			SetChange(alice).
+			SetFeeRate(1).
+			AddKeys(alicePrv).
+			BuildTransaction()
+		assert.Nil(t, err)
+		assert.Nil(t, tx.Verify())
+		assert.True(t, tx.Fees() >= originalFees+tx.Vsize()*IncrementalRelayFeeRate)
+		assert.True(t, tx.Fees() < tx.Vsize()*10)
+	}
+
+	// The deserialized original.
+	{
+		decoded := NewTransaction()
+		assert.Nil(t, decoded.Deserialize(original.Serialize()))
+		tx, err := NewReplacementBuilder(decoded, coin).
+			SetChange(alice).
+			SetFeeRate(10).
+			AddKeys(alicePrv).
+			BuildTransaction()
+		assert.Nil(t, err)
+		assert.Nil(t, tx.Verify())
+	}
+}
+
+func TestReplacementBuilderExtraInputs(t *testing.T) {
+	aliceKey := bip32.NewHDKey([]byte("this.is.alice.seed.at.2018"))
+	alicePrv := aliceKey.PrivateKey()
+	alice := NewPayToWitnessV0PubKeyHashAddress(aliceKey.PublicKey().Hash160())
+	aliceScript, _ := alice.LockingScript()
+	carolKey := bip32.NewHDKey([]byte("this.is.carol.seed.at.2018"))
+	carolScript, _ := NewPayToPubKeyHashAddress(carolKey.PublicKey().Hash160()).LockingScript()
+	bob := NewPayToPubKeyHashAddress(bip32.NewHDKey([]byte("this.is.bob.seed.at.2018")).PublicKey().Hash160())
+
+	coin := NewCoinBuilder().AddOutput(fmt.Sprintf("%064x", 1), 0, 61000, fmt.Sprintf("%x", aliceScript)).ToCoins()[0]
+	extras := NewCoinBuilder().
+		AddOutput(fmt.Sprintf("%064x", 2), 1, 20000, fmt.Sprintf("%x", carolScript)).
+		AddOutput(fmt.Sprintf("%064x", 3), 0, 5000, fmt.Sprintf("%x", aliceScript)).
+		ToCoins()
+
+	original, err := NewTransactionBuilder().
+		AddCoin(coin).
+		AddKeys(alicePrv).
+		To(bob, 60000).
+		Then().
+		SetChange(alice).
+		SetFeeRate(2).
+		Sign().
+		BuildTransaction()
+	assert.Nil(t, err)
+	assert.False(t, original.IsReplaceable())
+
+	// The change is too small for 50 sat/vB, the largest extra coin is added.
+	tx, err := NewReplacementBuilder(original, coin).
+		SetChange(alice).
+		SetFeeRate(50).
+		AddCoins(extras...).
+		AddKeys(alicePrv, carolKey.PrivateKey()).
+		BuildTransaction()
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Verify())
+	assert.True(t, tx.IsReplaceable())
+	assert.Equal(t, 2, len(tx.Inputs()))
+	assert.Equal(t, uint64(20000), tx.Inputs()[1].Value)
+	assert.Equal(t, 2, len(tx.Outputs()))
+	assert.True(t, tx.Fees() >= tx.Vsize()*50)
+
+	// Errors.
+	{
+		_, err := NewReplacementBuilder(original, coin).SetChange(alice).SetFeeRate(50).AddKeys(alicePrv).BuildTransaction()
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "(errno 5804) (state TRBF0)")
+
+		_, err = NewReplacementBuilder(original, coin).SetFeeRate(50).AddCoins(extras...).AddKeys(alicePrv).BuildTransaction()
+		assert.EqualError(t, err, "rbf.change.is.empty.for.the.extra.inputs (errno 5805) (state TRBF0)")
+
+		_, err = NewReplacementBuilder(original, coin).SetChange(alice).SetFeeRate(50).AddCoins(extras...).AddKeys(alicePrv).BuildTransaction()
+		assert.EqualError(t, err, "rbf.input[1].sign.key.missing (errno 5803) (state TRBF0)")
+
+		_, err = NewReplacementBuilder(original).BuildTransaction()
+		assert.EqualError(t, err, "rbf.coins[0].mismatch.inputs[1] (errno 5801) (state TRBF0)")
+
+		_, err = NewReplacementBuilder(original, extras[0]).BuildTransaction()
+		assert.EqualError(t, err, fmt.Sprintf("rbf.input[0].does.not.spend.coin[%064x:1] (errno 5802) (state TRBF0)", 2))
+	}
+}
+
+func TestReplacementBuilderMultiSig(t *testing.T) {
+	var prvs []*xcrypto.PrvKey
+	var pubs [][]byte
+	for _, seed := range []string{"this.is.a.seed.", "this.is.b.seed.", "this.is.c.seed."} {
+		key := bip32.NewHDKey([]byte(seed))
+		prvs = append(prvs, key.PrivateKey())
+		pubs = append(pubs, key.PublicKey().Serialize())
+	}
+	redeem, err := NewPayToMultiSigScript(2, pubs...).GetLockingScriptBytes()
+	assert.Nil(t, err)
+	multi := NewPayToWitnessV0ScriptHashAddress(xcrypto.Sha256(redeem))
+	multiScript, _ := multi.LockingScript()
+	bob := NewPayToPubKeyHashAddress(bip32.NewHDKey([]byte("this.is.bob.seed.at.2018")).PublicKey().Hash160())
+
+	coin := NewCoinBuilder().AddOutput(fmt.Sprintf("%064x", 1), 0, 100000, fmt.Sprintf("%x", multiScript)).ToCoins()[0]
+	original, err := NewTransactionBuilder().
+		AddCoin(coin).
+		AddKeys(prvs[0], prvs[2]).
+		SetRedeemScript(redeem).
+		To(bob, 60000).
+		Then().
+		SetChange(multi).
+		SetFeeRate(1).
+		SetReplaceable().
+		Sign().
+		BuildTransaction()
+	assert.Nil(t, err)
+	assert.Nil(t, original.Verify())
+
+	// The keys are signed in the redeem script order.
+	tx, err := NewReplacementBuilder(original, coin).
+		SetChange(multi).
+		SetFeeRate(5).
+		AddKeys(prvs[2], prvs[1]).
+		BuildTransaction()
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Verify())
+	assert.True(t, tx.Fees() >= tx.Vsize()*5)
+}
+
+func TestReplacementBuilderTaproot(t *testing.T) {
+	aliceKey := bip32.NewHDKey([]byte("this.is.alice.seed.at.2018"))
+	alicePrv := aliceKey.PrivateKey()
+	// The BIP86 account address of the key.
+	alice, err := accountAddress(PurposeP2TR, aliceKey.PublicKey())
+	assert.Nil(t, err)
+	aliceScript, _ := alice.LockingScript()
+	bob := NewPayToPubKeyHashAddress(bip32.NewHDKey([]byte("this.is.bob.seed.at.2018")).PublicKey().Hash160())
+
+	tweaked, err := xcrypto.TaprootTweakPrvKey(alicePrv, nil)
+	assert.Nil(t, err)
+	coin := NewCoinBuilder().AddOutput(fmt.Sprintf("%064x", 1), 0, 100000, fmt.Sprintf("%x", aliceScript)).ToCoins()[0]
+	original, err := NewTransactionBuilder().
+		AddCoin(coin).
+		AddKeys(tweaked).
+		To(bob, 60000).
+		Then().
+		SetChange(alice).
+		SetFeeRate(2).
+		SetReplaceable().
+		Sign().
+		BuildTransaction()
+	assert.Nil(t, err)
+	assert.Nil(t, original.Verify())
+
+	// The wallet re-signs with the untweaked key of the account.
+	tx, err := NewReplacementBuilder(original, coin).
+		SetChange(alice).
+		SetFeeRate(10).
+		AddKeys(alicePrv).
+		BuildTransaction()
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Verify())
+	assert.True(t, tx.Fees() >= tx.Vsize()*10)
+	assert.True(t, tx.Fees() >= original.Fees()+tx.Vsize()*IncrementalRelayFeeRate)
+}
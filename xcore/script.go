@@ -73,6 +73,8 @@ func ParseLockingScript(script []byte) (Script, error) {
 		return NewPayToWitnessV0PubKeyHashScript(instrs[1].Data()), nil
 	case isWitnessV0ScriptHash(instrs):
 		return NewPayToWitnessV0ScriptHashScript(instrs[1].Data()), nil
+	case isWitnessV1Taproot(instrs):
+		return NewPayToTaprootScript(instrs[1].Data()), nil
 	}
 	return nil, xerror.NewError(Errors, ER_SCRIPT_TYPE_UNKNOWN, xvm.DisasmString(script))
 }
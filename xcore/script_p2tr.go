@@ -0,0 +1,159 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/keyfuse/tokucore/xvm"
+)
+
+const (
+	// TapLeafVersion -- the leaf version of the BIP342 tapscript.
+	TapLeafVersion = 0xc0
+)
+
+// PayToTaprootScript -- P2TR (version 1 pay-to-taproot), spent by the key path.
+type PayToTaprootScript struct {
+	outputKey []byte
+}
+
+// NewPayToTaprootScript -- creates new P2TR script.
+// outputKey = the 32-byte x-only taproot output key
+func NewPayToTaprootScript(outputKey []byte) Script {
+	return &PayToTaprootScript{
+		outputKey: outputKey,
+	}
+}
+
+// GetAddress -- returns the Address interface.
+func (s *PayToTaprootScript) GetAddress() Address {
+	return NewPayToTaprootAddress(s.outputKey)
+}
+
+// GetRawLockingScriptBytes -- used to get locking script bytes.
+//
+// 1 <32-byte-output-key>
+// Format:
+// - OP_1
+// - OP_DATA_32
+// - 32 bytes x-only output key
+func (s *PayToTaprootScript) GetRawLockingScriptBytes() ([]byte, error) {
+	return xvm.NewScriptBuilder().
+		AddOp(xvm.OP_1).
+		AddData(s.outputKey).
+		Script()
+}
+
+// GetFinalLockingScriptBytes -- used to get the re-written locking for witness.
+// The key path is verified out of the script engine, same as the raw locking.
+func (s *PayToTaprootScript) GetFinalLockingScriptBytes(redeem []byte) ([]byte, error) {
+	return s.GetRawLockingScriptBytes()
+}
+
+// GetRawUnlockingScriptBytes -- used to get raw unlocking script bytes.
+// The key path has only the signature.
+func (s *PayToTaprootScript) GetRawUnlockingScriptBytes(signs []PubKeySign, redeem []byte) ([]byte, error) {
+	builder := xvm.NewScriptBuilder()
+	return builder.AddData(signs[0].Signature).Script()
+}
+
+// GetWitnessUnlockingScriptBytes -- used to get witness script bytes.
+func (s *PayToTaprootScript) GetWitnessUnlockingScriptBytes(signs []PubKeySign, redeem []byte) ([][]byte, error) {
+	var witness [][]byte
+	witness = append(witness, signs[0].Signature)
+	return witness, nil
+}
+
+// GetWitnessScriptCode -- used to get the witness script for sighash.
+// The taproot sighash commits to the scriptPubKey of every input, returns the locking script.
+func (s *PayToTaprootScript) GetWitnessScriptCode(redeem []byte) ([]byte, error) {
+	return s.GetRawLockingScriptBytes()
+}
+
+// GetScriptVersion -- used to get the version of this script.
+func (s *PayToTaprootScript) GetScriptVersion() ScriptVersion {
+	return TAPROOT
+}
+
+// WitnessToUnlockingScriptBytes -- converts witness slice to unlocking script.
+// For txn deserialize from hex.
+func (s *PayToTaprootScript) WitnessToUnlockingScriptBytes(witness [][]byte) ([]byte, error) {
+	builder := xvm.NewScriptBuilder()
+	return builder.AddData(witness[0]).Script()
+}
+
+// isWitnessV1Taproot --
+// returns true if the passed script is a pay-to-taproot, and false otherwise.
+func isWitnessV1Taproot(instrs []xvm.Instruction) bool {
+	return len(instrs) == 2 &&
+		instrs[0].OpCode() == xvm.OP_1 &&
+		instrs[1].OpCode() == xvm.OP_DATA_32
+}
+
+// TapLeafHash -- returns the BIP341 leaf hash of the tapscript,
+// tagged_hash("TapLeaf", leaf version || compact size(script) || script).
+func TapLeafHash(script []byte) []byte {
+	buffer := xbase.NewBuffer()
+	buffer.WriteU8(TapLeafVersion)
+	buffer.WriteVarBytes(script)
+	return xcrypto.TaggedHash("TapLeaf", buffer.Bytes())
+}
+
+// TapBranchHash -- returns the BIP341 branch hash of the two child hashes in the lexicographic order.
+func TapBranchHash(a []byte, b []byte) []byte {
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	return xcrypto.TaggedHash("TapBranch", a, b)
+}
+
+// NewTaprootControlBlock -- returns the control block of the script path spend,
+// the leaf version with the parity of the output key, the x-only internal key and the merkle path of the leaf.
+func NewTaprootControlBlock(internal *xcrypto.PubKey, merkleRoot []byte, path ...[]byte) ([]byte, error) {
+	output, err := xcrypto.TaprootTweakPubKey(internal, merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+	control := []byte{TapLeafVersion | byte(output.Y.Bit(0))}
+	control = append(control, internal.SerializeXOnly()...)
+	for _, node := range path {
+		control = append(control, node...)
+	}
+	return control, nil
+}
+
+// verifyTaprootControlBlock -- verifies the script and the control block commit to the x-only output key.
+// Return the leaf hash of the script.
+func verifyTaprootControlBlock(outputKey []byte, script []byte, control []byte) ([]byte, error) {
+	if len(control) < taprootControlBlockBaseSize || (len(control)-taprootControlBlockBaseSize)%32 != 0 {
+		return nil, fmt.Errorf("taproot.control.block.size[%v].invalid", len(control))
+	}
+	if control[0]&0xfe != TapLeafVersion {
+		return nil, fmt.Errorf("taproot.leaf.version[%x].unsupported", control[0]&0xfe)
+	}
+	internal, err := xcrypto.PubKeyFromXOnly(control[1:taprootControlBlockBaseSize])
+	if err != nil {
+		return nil, err
+	}
+
+	leafHash := TapLeafHash(script)
+	root := leafHash
+	for i := taprootControlBlockBaseSize; i < len(control); i += 32 {
+		root = TapBranchHash(root, control[i:i+32])
+	}
+	output, err := xcrypto.TaprootTweakPubKey(internal, root)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(output.SerializeXOnly(), outputKey) || byte(output.Y.Bit(0)) != control[0]&0x01 {
+		return nil, fmt.Errorf("taproot.control.block.commitment.mismatch")
+	}
+	return leafHash, nil
+}
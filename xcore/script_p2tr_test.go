@@ -0,0 +1,31 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/keyfuse/tokucore/xvm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptP2TR(t *testing.T) {
+	outputScriptString := "OP_1 OP_DATA_32 79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+
+	key, _ := hex.DecodeString("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+	script := NewPayToTaprootScript(key)
+	locking, err := script.GetRawLockingScriptBytes()
+	assert.Nil(t, err)
+	assert.Equal(t, "5120"+hex.EncodeToString(key), hex.EncodeToString(locking))
+	assert.Equal(t, outputScriptString, xvm.DisasmString(locking))
+	assert.Equal(t, TAPROOT, script.GetScriptVersion())
+	assert.NotNil(t, script.GetAddress())
+
+	parsed, err := ParseLockingScript(locking)
+	assert.Nil(t, err)
+	assert.Equal(t, script, parsed)
+}
@@ -103,7 +103,7 @@ func (s *PayToWitnessV0ScriptHashScript) WitnessToUnlockingScriptBytes(witness [
 	if l > 1 {
 		redeem := witness[len(witness)-1]
 		instrs, _ := xvm.NewScriptReader(redeem).AllInstructions()
-		if isMultiSig(instrs) {
+		if isMultiSig(instrs) || isHTLC(instrs) {
 			builder := xvm.NewScriptBuilder()
 			for i := 0; i < l-1; i++ {
 				builder.AddData(witness[i])
@@ -0,0 +1,290 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/keyfuse/tokucore/xerror"
+)
+
+// The scriptless atomic swap exchanges the coins on two chains with the
+// BIP340 adaptor signatures, the chains only see the taproot key path spends.
+//
+// Alice knows the adaptor secret t of T = t*G. On each chain the sender
+// locks the coins to the P2TR of the joint key of the two parties, see
+// xcrypto.SchnorrAdaptorParty. Before the locks are broadcast:
+// 1. the two parties sign the refund of each lock back to its sender,
+//    which is valid after the locktime, Alice's locktime is the later one
+//    by ScriptlessSwapLockTimeMargin at least, so Alice cannot claim the
+//    second chain and refund the first one before Bob claims it
+// 2. the two parties pre-sign both claim transactions with the adaptor point T
+//
+// Alice claims the second chain by adapting its pre-signature with t, the
+// signature on the second chain reveals t to Bob, who adapts the pre-signature
+// of the first chain and claims it.
+
+const (
+	// swapRefundSequence -- enables the locktime of the refund.
+	swapRefundSequence = 0xfffffffe
+
+	// ScriptlessSwapLockTimeMargin -- the blocks Alice's refund locktime is later than Bob's at least,
+	// Bob has this time to claim the first chain once the secret is revealed on the second one.
+	ScriptlessSwapLockTimeMargin = 12
+
+	// ScriptlessSwapLockTimeMarginSeconds -- the margin of the unix timestamp locktimes.
+	ScriptlessSwapLockTimeMarginSeconds = ScriptlessSwapLockTimeMargin * 600
+)
+
+// ScriptlessSwapLeg -- the coins locked by the sender on one chain for the receiver.
+type ScriptlessSwapLeg struct {
+	joint  *xcrypto.PubKey
+	lock   *Transaction
+	claim  *Transaction
+	refund *Transaction
+}
+
+// NewScriptlessSwapLeg -- creates new ScriptlessSwapLeg with the joint key of the two parties.
+func NewScriptlessSwapLeg(joint *xcrypto.PubKey) *ScriptlessSwapLeg {
+	return &ScriptlessSwapLeg{joint: joint}
+}
+
+// LockAddress -- returns the P2TR address of the joint key.
+// The joint key is the output key as is, without the BIP86 tweak of the single key wallets:
+// the adaptor signature of xcrypto.SchnorrAdaptorParty is made for the joint key, and the BIP86
+// tweak guards a single key against a hidden script path, which the MuSig key aggregation
+// already does here, neither party can choose its key to commit the joint key to a script.
+func (leg *ScriptlessSwapLeg) LockAddress() Address {
+	return NewPayToTaprootAddress(leg.joint.SerializeXOnly())
+}
+
+// BuildLock -- builds the lock transaction of the sender, signed with the key of the coin.
+// The lock output is the first one.
+func (leg *ScriptlessSwapLeg) BuildLock(coin *Coin, key *xcrypto.PrvKey, amount uint64, change Address, fees uint64) (*Transaction, error) {
+	tx, err := NewTransactionBuilder().
+		AddCoin(coin).
+		AddKeys(key).
+		To(leg.LockAddress(), amount).
+		Then().
+		SetChange(change).
+		SendFees(fees).
+		Sign().
+		BuildTransaction()
+	if err != nil {
+		return nil, err
+	}
+	if err := leg.SetLock(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// SetLock -- sets the lock transaction built by the sender, the first output must pay to the joint key.
+func (leg *ScriptlessSwapLeg) SetLock(tx *Transaction) error {
+	script, err := leg.LockAddress().LockingScript()
+	if err != nil {
+		return err
+	}
+	if len(tx.outputs) == 0 || !bytes.Equal(tx.outputs[0].Script, script) {
+		return xerror.NewError(Errors, ER_SWAP_LOCK_OUTPUT_MISMATCH)
+	}
+	leg.lock = tx
+	return nil
+}
+
+// BuildClaim -- builds the claim transaction which spends the lock to the receiver.
+// Return the taproot signature hash to pre-sign with the adaptor point.
+func (leg *ScriptlessSwapLeg) BuildClaim(to Address, fees uint64) ([]byte, error) {
+	tx, err := leg.spendLock(to, fees, 0)
+	if err != nil {
+		return nil, err
+	}
+	leg.claim = tx
+	return tx.TaprootSignatureHash(0, SigHashDefault), nil
+}
+
+// BuildRefund -- builds the refund transaction which spends the lock back to the sender after the lockTime.
+// Return the taproot signature hash to sign without the adaptor point.
+// The legs of a swap build their refunds with ScriptlessSwap, which keeps the locktimes in order.
+func (leg *ScriptlessSwapLeg) BuildRefund(to Address, fees uint64, lockTime uint32) ([]byte, error) {
+	tx, err := leg.spendLock(to, fees, lockTime)
+	if err != nil {
+		return nil, err
+	}
+	leg.refund = tx
+	return tx.TaprootSignatureHash(0, SigHashDefault), nil
+}
+
+// Refund -- embeds the joint signature into the refund transaction.
+// Return the signed refund transaction.
+func (leg *ScriptlessSwapLeg) Refund(sig []byte) (*Transaction, error) {
+	if leg.refund == nil {
+		return nil, xerror.NewError(Errors, ER_SWAP_REFUND_MISSING)
+	}
+	if err := leg.refund.EmbedIdxSchnorrSignature(0, sig, SigHashDefault); err != nil {
+		return nil, err
+	}
+	if err := leg.refund.Verify(); err != nil {
+		return nil, err
+	}
+	return leg.refund, nil
+}
+
+// VerifyPresignature -- verifies the pre-signature of the claim transaction against the joint key and the adaptor point.
+func (leg *ScriptlessSwapLeg) VerifyPresignature(adaptor *xcrypto.PubKey, presig []byte) error {
+	if leg.claim == nil {
+		return xerror.NewError(Errors, ER_SWAP_CLAIM_MISSING)
+	}
+	sighash := leg.claim.TaprootSignatureHash(0, SigHashDefault)
+	return xcrypto.SchnorrAdaptorVerify(leg.joint, sighash, adaptor, presig)
+}
+
+// Claim -- adapts the pre-signature with the adaptor secret and embeds it into the claim transaction.
+// Return the signed claim transaction.
+func (leg *ScriptlessSwapLeg) Claim(presig []byte, secret *big.Int) (*Transaction, error) {
+	if leg.claim == nil {
+		return nil, xerror.NewError(Errors, ER_SWAP_CLAIM_MISSING)
+	}
+	sig, err := xcrypto.SchnorrAdaptorAdapt(presig, secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := leg.claim.EmbedIdxSchnorrSignature(0, sig, SigHashDefault); err != nil {
+		return nil, err
+	}
+	if err := leg.claim.Verify(); err != nil {
+		return nil, err
+	}
+	return leg.claim, nil
+}
+
+// ExtractSecret -- extracts the adaptor secret from the claim transaction seen on the chain.
+func (leg *ScriptlessSwapLeg) ExtractSecret(presig []byte, claimed *Transaction) (*big.Int, error) {
+	if leg.lock == nil {
+		return nil, xerror.NewError(Errors, ER_SWAP_LOCK_MISSING)
+	}
+	for _, in := range claimed.inputs {
+		if bytes.Equal(in.Hash, leg.lock.Hash()) && in.Index == 0 && len(in.Witness) == 1 {
+			return xcrypto.SchnorrAdaptorExtract(presig, in.Witness[0])
+		}
+	}
+	return nil, xerror.NewError(Errors, ER_SWAP_CLAIM_INPUT_MISMATCH, claimed.ID())
+}
+
+// spendLock -- builds the unsigned transaction which spends the lock output to the address.
+func (leg *ScriptlessSwapLeg) spendLock(to Address, fees uint64, lockTime uint32) (*Transaction, error) {
+	if leg.lock == nil {
+		return nil, xerror.NewError(Errors, ER_SWAP_LOCK_MISSING)
+	}
+	out := leg.lock.outputs[0]
+	if out.Value <= fees {
+		return nil, xerror.NewError(Errors, ER_SWAP_AMOUNT_NOT_ENOUGH, out.Value, fees)
+	}
+	coin := &Coin{
+		txID:   leg.lock.ID(),
+		n:      0,
+		value:  out.Value,
+		script: fmt.Sprintf("%x", out.Script),
+	}
+	tx, err := NewTransactionBuilder().
+		AddCoin(coin).
+		To(to, out.Value-fees).
+		Then().
+		SendFees(fees).
+		SetLockTime(lockTime).
+		BuildTransaction()
+	if err != nil {
+		return nil, err
+	}
+	if lockTime > 0 {
+		tx.inputs[0].Sequence = swapRefundSequence
+	}
+	return tx, nil
+}
+
+// ScriptlessSwap -- the coordinator of the scriptless atomic swap on two chains.
+// Alice sends on the first chain and Bob on the second one.
+type ScriptlessSwap struct {
+	adaptor        *xcrypto.PubKey
+	first          *ScriptlessSwapLeg
+	second         *ScriptlessSwapLeg
+	firstLockTime  uint32
+	secondLockTime uint32
+	firstPresig    []byte
+	secondPresig   []byte
+}
+
+// NewScriptlessSwap -- creates new ScriptlessSwap with the adaptor point of Alice and the refund locktimes of the two legs.
+// The first locktime must be later than the second one by the margin, both are block heights or both unix timestamps,
+// the heights of two chains are only comparable if they are kept in step, otherwise use the timestamps.
+func NewScriptlessSwap(adaptor *xcrypto.PubKey, first *ScriptlessSwapLeg, firstLockTime uint32, second *ScriptlessSwapLeg, secondLockTime uint32) (*ScriptlessSwap, error) {
+	margin := uint64(ScriptlessSwapLockTimeMargin)
+	if secondLockTime >= LockTimeThreshold {
+		margin = ScriptlessSwapLockTimeMarginSeconds
+	}
+	if secondLockTime == 0 || (firstLockTime < LockTimeThreshold) != (secondLockTime < LockTimeThreshold) ||
+		uint64(firstLockTime) < uint64(secondLockTime)+margin {
+		return nil, xerror.NewError(Errors, ER_SWAP_LOCKTIME_INVALID, firstLockTime, secondLockTime, margin)
+	}
+	return &ScriptlessSwap{
+		adaptor:        adaptor,
+		first:          first,
+		second:         second,
+		firstLockTime:  firstLockTime,
+		secondLockTime: secondLockTime,
+	}, nil
+}
+
+// BuildFirstRefund -- builds the refund of the first chain to Alice after the first locktime.
+// Return the taproot signature hash to sign without the adaptor point.
+func (swap *ScriptlessSwap) BuildFirstRefund(to Address, fees uint64) ([]byte, error) {
+	return swap.first.BuildRefund(to, fees, swap.firstLockTime)
+}
+
+// BuildSecondRefund -- builds the refund of the second chain to Bob after the second locktime.
+// Return the taproot signature hash to sign without the adaptor point.
+func (swap *ScriptlessSwap) BuildSecondRefund(to Address, fees uint64) ([]byte, error) {
+	return swap.second.BuildRefund(to, fees, swap.secondLockTime)
+}
+
+// SetPresignatures -- checks and keeps the pre-signatures of the claim transactions on the two chains.
+// The locks must not be broadcast before the pre-signatures and the refunds are done.
+func (swap *ScriptlessSwap) SetPresignatures(first []byte, second []byte) error {
+	if err := swap.first.VerifyPresignature(swap.adaptor, first); err != nil {
+		return err
+	}
+	if err := swap.second.VerifyPresignature(swap.adaptor, second); err != nil {
+		return err
+	}
+	swap.firstPresig = first
+	swap.secondPresig = second
+	return nil
+}
+
+// ClaimSecond -- Alice claims the coins on the second chain with the adaptor secret.
+// Return the signed claim transaction of the second chain, which reveals the secret.
+func (swap *ScriptlessSwap) ClaimSecond(secret *big.Int) (*Transaction, error) {
+	if swap.secondPresig == nil {
+		return nil, xerror.NewError(Errors, ER_SWAP_PRESIGNATURE_MISSING)
+	}
+	return swap.second.Claim(swap.secondPresig, secret)
+}
+
+// ClaimFirst -- Bob extracts the adaptor secret from the claim transaction seen on the second chain and claims the coins on the first chain.
+// Return the signed claim transaction of the first chain.
+func (swap *ScriptlessSwap) ClaimFirst(claimed *Transaction) (*Transaction, error) {
+	if swap.firstPresig == nil {
+		return nil, xerror.NewError(Errors, ER_SWAP_PRESIGNATURE_MISSING)
+	}
+	secret, err := swap.second.ExtractSecret(swap.secondPresig, claimed)
+	if err != nil {
+		return nil, err
+	}
+	return swap.first.Claim(swap.firstPresig, secret)
+}
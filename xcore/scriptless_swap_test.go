@@ -0,0 +1,245 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/keyfuse/tokucore/xcore/bip32"
+	"github.com/keyfuse/tokucore/xcrypto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockSwapSign -- the two parties sign the hash with the adaptor point T, nil for a plain signature.
+func mockSwapSign(t *testing.T, alice *xcrypto.SchnorrAdaptorParty, bob *xcrypto.SchnorrAdaptorParty, hash []byte, T *xcrypto.PubKey) []byte {
+	commit1, err := alice.Phase2(hash)
+	assert.Nil(t, err)
+	commit2, err := bob.Phase2(hash)
+	assert.Nil(t, err)
+	R1, err := alice.Phase3(commit2)
+	assert.Nil(t, err)
+	R2, err := bob.Phase3(commit1)
+	assert.Nil(t, err)
+	s1, err := alice.Phase4(R2, T)
+	assert.Nil(t, err)
+	s2, err := bob.Phase4(R1, T)
+	assert.Nil(t, err)
+	sig1, err := alice.Phase5(s2)
+	assert.Nil(t, err)
+	sig2, err := bob.Phase5(s1)
+	assert.Nil(t, err)
+	assert.Equal(t, sig1, sig2)
+	return sig1
+}
+
+func TestScriptlessSwap(t *testing.T) {
+	// Alice.
+	aliceHDKey := bip32.NewHDKey([]byte("this.is.alice.seed."))
+	alicePrv := aliceHDKey.PrivateKey()
+	alice := NewPayToPubKeyHashAddress(aliceHDKey.PublicKey().Hash160())
+
+	// Bob.
+	bobHDKey := bip32.NewHDKey([]byte("this.is.bob.seed."))
+	bobPrv := bobHDKey.PrivateKey()
+	bob := NewPayToPubKeyHashAddress(bobHDKey.PublicKey().Hash160())
+
+	// Adaptor secret of Alice.
+	secret := new(big.Int).SetInt64(20190501)
+	adaptor := xcrypto.PrvKeyFromBytes(secret.Bytes()).PubKey()
+
+	// Joint keys.
+	alice1 := xcrypto.NewSchnorrAdaptorParty(alicePrv)
+	bob1 := xcrypto.NewSchnorrAdaptorParty(bobPrv)
+	defer alice1.Close()
+	defer bob1.Close()
+	joint1, err := alice1.Phase1(bobPrv.PubKey())
+	assert.Nil(t, err)
+	_, err = bob1.Phase1(alicePrv.PubKey())
+	assert.Nil(t, err)
+
+	alice2 := xcrypto.NewSchnorrAdaptorParty(alicePrv)
+	bob2 := xcrypto.NewSchnorrAdaptorParty(bobPrv)
+	defer alice2.Close()
+	defer bob2.Close()
+	joint2, err := alice2.Phase1(bobPrv.PubKey())
+	assert.Nil(t, err)
+	_, err = bob2.Phase1(alicePrv.PubKey())
+	assert.Nil(t, err)
+
+	first := NewScriptlessSwapLeg(joint1)
+	second := NewScriptlessSwapLeg(joint2)
+
+	// Locks.
+	{
+		aliceCoin := NewCoinBuilder().AddOutput(
+			"f519a75190312039ddf885231205006b14f2e69f6e5b02314cb0e367b027fa86",
+			1,
+			127297408,
+			fmt.Sprintf("76a914%x88ac", alice.Hash160()),
+		).ToCoins()[0]
+		tx, err := first.BuildLock(aliceCoin, alicePrv, 100000, alice, 1000)
+		assert.Nil(t, err)
+		assert.Nil(t, tx.Verify())
+
+		bobCoin := NewCoinBuilder().AddOutput(
+			"d62d7e4a8b5b84ff1d6a4d6c3ec9ae7c5ee6bfcd1fa2e5db1f93a2bbcf0e27f6",
+			0,
+			50000000,
+			fmt.Sprintf("76a914%x88ac", bob.Hash160()),
+		).ToCoins()[0]
+		tx, err = second.BuildLock(bobCoin, bobPrv, 200000, bob, 1000)
+		assert.Nil(t, err)
+		assert.Nil(t, tx.Verify())
+	}
+
+	// The first locktime must be the later one by the margin.
+	{
+		_, err := NewScriptlessSwap(adaptor, first, 600050, second, 600100)
+		assert.Equal(t, "swap.first.locktime[600050].not.later.than.second.locktime[600100].by.margin[12] (errno 5408) (state TSW00)", err.Error())
+		_, err = NewScriptlessSwap(adaptor, first, 600061, second, 600050)
+		assert.NotNil(t, err)
+		_, err = NewScriptlessSwap(adaptor, first, 1556668800, second, 600050)
+		assert.NotNil(t, err)
+		_, err = NewScriptlessSwap(adaptor, first, 1556668800, second, 1556665200)
+		assert.Equal(t, "swap.first.locktime[1556668800].not.later.than.second.locktime[1556665200].by.margin[7200] (errno 5408) (state TSW00)", err.Error())
+		_, err = NewScriptlessSwap(adaptor, first, 1556672400, second, 1556665200)
+		assert.Nil(t, err)
+		_, err = NewScriptlessSwap(adaptor, first, 600100, second, 0)
+		assert.NotNil(t, err)
+	}
+	swap, err := NewScriptlessSwap(adaptor, first, 600100, second, 600050)
+	assert.Nil(t, err)
+
+	// Refunds, Alice's locktime is the later one.
+	{
+		hash, err := swap.BuildFirstRefund(alice, 1000)
+		assert.Nil(t, err)
+		sig := mockSwapSign(t, alice1, bob1, hash, nil)
+		tx, err := first.Refund(sig)
+		assert.Nil(t, err)
+		assert.Equal(t, uint32(600100), tx.lockTime)
+		assert.Equal(t, uint32(swapRefundSequence), tx.inputs[0].Sequence)
+
+		hash, err = swap.BuildSecondRefund(bob, 1000)
+		assert.Nil(t, err)
+		sig = mockSwapSign(t, alice2, bob2, hash, nil)
+		tx, err = second.Refund(sig)
+		assert.Nil(t, err)
+		assert.Equal(t, uint32(600050), tx.lockTime)
+	}
+
+	// Pre-signatures of the claims.
+	{
+		hash, err := first.BuildClaim(bob, 1000)
+		assert.Nil(t, err)
+		presig1 := mockSwapSign(t, alice1, bob1, hash, adaptor)
+		assert.Equal(t, xcrypto.SchnorrPresignatureSize, len(presig1))
+
+		hash, err = second.BuildClaim(alice, 1000)
+		assert.Nil(t, err)
+		presig2 := mockSwapSign(t, alice2, bob2, hash, adaptor)
+
+		// Swapped pre-signatures.
+		err = swap.SetPresignatures(presig2, presig1)
+		assert.NotNil(t, err)
+
+		err = swap.SetPresignatures(presig1, presig2)
+		assert.Nil(t, err)
+	}
+
+	// Alice claims the second chain.
+	claimed, err := swap.ClaimSecond(secret)
+	assert.Nil(t, err)
+	assert.Equal(t, 64, len(claimed.inputs[0].Witness[0]))
+
+	// Bob sees the claim on the second chain.
+	seen := NewTransaction()
+	err = seen.Deserialize(claimed.Serialize())
+	assert.Nil(t, err)
+	extracted, err := second.ExtractSecret(swap.secondPresig, seen)
+	assert.Nil(t, err)
+	assert.Equal(t, secret, extracted)
+
+	tx, err := swap.ClaimFirst(seen)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Verify())
+	assert.Equal(t, uint64(99000), tx.outputs[0].Value)
+}
+
+func TestScriptlessSwapErrors(t *testing.T) {
+	prv := bip32.NewHDKey([]byte("this.is.alice.seed.")).PrivateKey()
+	addr := NewPayToPubKeyHashAddress(prv.PubKey().Hash160())
+	leg := NewScriptlessSwapLeg(prv.PubKey())
+
+	// Missing lock.
+	{
+		_, err := leg.BuildClaim(addr, 1000)
+		assert.NotNil(t, err)
+		_, err = leg.BuildRefund(addr, 1000, 1)
+		assert.NotNil(t, err)
+		_, err = leg.ExtractSecret(nil, NewTransaction())
+		assert.NotNil(t, err)
+	}
+
+	// Missing claim and refund.
+	{
+		_, err := leg.Refund(make([]byte, 64))
+		assert.NotNil(t, err)
+		_, err = leg.Claim(make([]byte, 65), big.NewInt(1))
+		assert.NotNil(t, err)
+		err = leg.VerifyPresignature(prv.PubKey(), make([]byte, 65))
+		assert.NotNil(t, err)
+	}
+
+	// Lock output mismatch.
+	{
+		coin := NewCoinBuilder().AddOutput(
+			"f519a75190312039ddf885231205006b14f2e69f6e5b02314cb0e367b027fa86",
+			1,
+			127297408,
+			fmt.Sprintf("76a914%x88ac", addr.Hash160()),
+		).ToCoins()[0]
+		tx, err := NewTransactionBuilder().
+			AddCoin(coin).
+			AddKeys(prv).
+			To(addr, 100000).
+			Then().
+			SetChange(addr).
+			SendFees(1000).
+			Sign().
+			BuildTransaction()
+		assert.Nil(t, err)
+		err = leg.SetLock(tx)
+		assert.NotNil(t, err)
+	}
+
+	// Amount not enough.
+	{
+		coin := NewCoinBuilder().AddOutput(
+			"f519a75190312039ddf885231205006b14f2e69f6e5b02314cb0e367b027fa86",
+			1,
+			127297408,
+			fmt.Sprintf("76a914%x88ac", addr.Hash160()),
+		).ToCoins()[0]
+		_, err := leg.BuildLock(coin, prv, 1000, addr, 1000)
+		assert.Nil(t, err)
+		_, err = leg.BuildClaim(addr, 1000)
+		assert.NotNil(t, err)
+	}
+
+	// Missing pre-signatures.
+	{
+		swap, err := NewScriptlessSwap(prv.PubKey(), leg, 600100, leg, 600050)
+		assert.Nil(t, err)
+		_, err = swap.ClaimSecond(big.NewInt(1))
+		assert.NotNil(t, err)
+		_, err = swap.ClaimFirst(NewTransaction())
+		assert.NotNil(t, err)
+	}
+}
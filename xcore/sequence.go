@@ -0,0 +1,147 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"github.com/keyfuse/tokucore/xerror"
+)
+
+const (
+	// SequenceLockTimeDisabled -- the sequence flag disabling the BIP68 relative locktime of the input.
+	SequenceLockTimeDisabled = 1 << 31
+
+	// SequenceLockTimeIsSeconds -- the sequence flag of the relative locktime in units of 512 seconds, otherwise blocks.
+	SequenceLockTimeIsSeconds = 1 << 22
+
+	// SequenceLockTimeMask -- the sequence bits of the relative locktime value.
+	SequenceLockTimeMask = 0x0000ffff
+
+	// SequenceLockTimeGranularity -- the relative locktime in seconds is shifted by 9, units of 512 seconds.
+	SequenceLockTimeGranularity = 9
+
+	// LockTimeThreshold -- the locktime below is a block height, otherwise a unix timestamp.
+	LockTimeThreshold = 500000000
+
+	// RelativeLockTimeVersion -- the lowest transaction version enforcing the BIP68 relative locktimes.
+	RelativeLockTimeVersion = 2
+)
+
+// NewSequenceFromBlocks -- the sequence of the BIP68 relative locktime of blocks.
+func NewSequenceFromBlocks(blocks uint16) uint32 {
+	return uint32(blocks)
+}
+
+// NewSequenceFromSeconds -- the sequence of the BIP68 relative locktime of seconds,
+// rounded up to the 512 seconds units.
+func NewSequenceFromSeconds(seconds uint32) (uint32, error) {
+	units := (uint64(seconds) + (1 << SequenceLockTimeGranularity) - 1) >> SequenceLockTimeGranularity
+	if units > SequenceLockTimeMask {
+		return 0, xerror.NewError(Errors, ER_SEQUENCE_SECONDS_OUT_OF_RANGE, seconds, SequenceLockTimeMask<<SequenceLockTimeGranularity)
+	}
+	return SequenceLockTimeIsSeconds | uint32(units), nil
+}
+
+// SetRelativeLockBlocks -- set the sequence to the relative locktime of blocks.
+func (txin *TxIn) SetRelativeLockBlocks(blocks uint16) {
+	txin.Sequence = NewSequenceFromBlocks(blocks)
+}
+
+// SetRelativeLockSeconds -- set the sequence to the relative locktime of seconds, rounded up to the 512 seconds units.
+func (txin *TxIn) SetRelativeLockSeconds(seconds uint32) error {
+	sequence, err := NewSequenceFromSeconds(seconds)
+	if err != nil {
+		return err
+	}
+	txin.Sequence = sequence
+	return nil
+}
+
+// RelativeLockDisabled -- returns whether the sequence disables the relative locktime.
+func (txin *TxIn) RelativeLockDisabled() bool {
+	return txin.Sequence&SequenceLockTimeDisabled != 0
+}
+
+// RelativeLockBlocks -- returns the relative locktime in blocks, false if it is disabled or in seconds.
+func (txin *TxIn) RelativeLockBlocks() (uint16, bool) {
+	if txin.RelativeLockDisabled() || txin.Sequence&SequenceLockTimeIsSeconds != 0 {
+		return 0, false
+	}
+	return uint16(txin.Sequence & SequenceLockTimeMask), true
+}
+
+// RelativeLockSeconds -- returns the relative locktime in seconds, false if it is disabled or in blocks.
+func (txin *TxIn) RelativeLockSeconds() (uint32, bool) {
+	if txin.RelativeLockDisabled() || txin.Sequence&SequenceLockTimeIsSeconds == 0 {
+		return 0, false
+	}
+	return (txin.Sequence & SequenceLockTimeMask) << SequenceLockTimeGranularity, true
+}
+
+// IsFinal -- returns whether the tx locktime allows it in the block of the height and median time past,
+// the locktime below LockTimeThreshold is a height, otherwise a time. The locktime is ignored if all the
+// input sequences are final.
+func (tx *Transaction) IsFinal(height uint32, medianTimePast uint32) bool {
+	if tx.lockTime == 0 {
+		return true
+	}
+	limit := height
+	if tx.lockTime >= LockTimeThreshold {
+		limit = medianTimePast
+	}
+	if tx.lockTime < limit {
+		return true
+	}
+	for _, in := range tx.inputs {
+		if in.Sequence != SequenceFinal {
+			return false
+		}
+	}
+	return true
+}
+
+// IsRelativeLockFinal -- returns whether the BIP68 relative locktime of the input allows the tx in the block of
+// the height and median time past. The coin is confirmed at the coinHeight, the coinMedianTimePast is the median
+// time past of the block before it.
+func (tx *Transaction) IsRelativeLockFinal(idx int, coinHeight uint32, coinMedianTimePast uint32, height uint32, medianTimePast uint32) bool {
+	if tx.version < RelativeLockTimeVersion {
+		return true
+	}
+	txin := tx.inputs[idx]
+	if blocks, ok := txin.RelativeLockBlocks(); ok {
+		return int64(coinHeight)+int64(blocks)-1 < int64(height)
+	}
+	if seconds, ok := txin.RelativeLockSeconds(); ok {
+		return int64(coinMedianTimePast)+int64(seconds)-1 < int64(medianTimePast)
+	}
+	return true
+}
+
+// verifyLockTime -- the BIP65 OP_CHECKLOCKTIMEVERIFY check of the idx input against the tx locktime.
+func (tx *Transaction) verifyLockTime(idx int, lockTime int64) error {
+	// The locktime and the tx locktime must be the same type, height or time.
+	if (lockTime < LockTimeThreshold) != (tx.lockTime < LockTimeThreshold) || lockTime > int64(tx.lockTime) {
+		return xerror.NewError(Errors, ER_SEQUENCE_LOCKTIME_UNSATISFIED, lockTime, tx.lockTime)
+	}
+	// The final sequence disables the tx locktime.
+	if tx.inputs[idx].Sequence == SequenceFinal {
+		return xerror.NewError(Errors, ER_SEQUENCE_LOCKTIME_UNSATISFIED, lockTime, tx.lockTime)
+	}
+	return nil
+}
+
+// verifySequence -- the BIP112 OP_CHECKSEQUENCEVERIFY check of the idx input against its relative locktime.
+func (tx *Transaction) verifySequence(idx int, sequence int64) error {
+	txSequence := tx.inputs[idx].Sequence
+	if tx.version < RelativeLockTimeVersion || txSequence&SequenceLockTimeDisabled != 0 {
+		return xerror.NewError(Errors, ER_SEQUENCE_RELATIVE_LOCK_UNSATISFIED, sequence, idx, txSequence)
+	}
+	// The relative locktimes must be the same type, blocks or seconds.
+	if (sequence&SequenceLockTimeIsSeconds != 0) != (txSequence&SequenceLockTimeIsSeconds != 0) ||
+		sequence&SequenceLockTimeMask > int64(txSequence&SequenceLockTimeMask) {
+		return xerror.NewError(Errors, ER_SEQUENCE_RELATIVE_LOCK_UNSATISFIED, sequence, idx, txSequence)
+	}
+	return nil
+}
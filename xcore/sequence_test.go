@@ -0,0 +1,139 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/keyfuse/tokucore/xcore/bip32"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequence(t *testing.T) {
+	txin := &TxIn{Sequence: SequenceFinal}
+	assert.True(t, txin.RelativeLockDisabled())
+	_, ok := txin.RelativeLockBlocks()
+	assert.False(t, ok)
+	_, ok = txin.RelativeLockSeconds()
+	assert.False(t, ok)
+
+	// Blocks.
+	txin.SetRelativeLockBlocks(144)
+	assert.Equal(t, uint32(144), txin.Sequence)
+	assert.False(t, txin.RelativeLockDisabled())
+	blocks, ok := txin.RelativeLockBlocks()
+	assert.True(t, ok)
+	assert.Equal(t, uint16(144), blocks)
+	_, ok = txin.RelativeLockSeconds()
+	assert.False(t, ok)
+
+	// Seconds, rounded up to the 512 seconds units.
+	assert.Nil(t, txin.SetRelativeLockSeconds(1000))
+	assert.Equal(t, uint32(0x00400002), txin.Sequence)
+	seconds, ok := txin.RelativeLockSeconds()
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1024), seconds)
+	_, ok = txin.RelativeLockBlocks()
+	assert.False(t, ok)
+
+	sequence, err := NewSequenceFromSeconds(0xffff << SequenceLockTimeGranularity)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0x0040ffff), sequence)
+	_, err = NewSequenceFromSeconds(0xffff<<SequenceLockTimeGranularity + 1)
+	assert.EqualError(t, err, "sequence.seconds[33553921].out.of.range[33553920] (errno 5901) (state TSQ00)")
+
+	// The replaceable sequence disables the relative locktime.
+	txin.Sequence = SequenceReplaceable
+	assert.True(t, txin.RelativeLockDisabled())
+}
+
+func TestTransactionIsFinal(t *testing.T) {
+	tx := NewTransaction()
+	tx.AddInput(&TxIn{Sequence: SequenceFinal})
+	assert.True(t, tx.IsFinal(100, 1600000000))
+
+	// Height.
+	tx.SetLockTime(100)
+	assert.True(t, tx.IsFinal(100, 1600000000))
+	tx.inputs[0].Sequence = SequenceReplaceable
+	assert.False(t, tx.IsFinal(100, 1600000000))
+	assert.True(t, tx.IsFinal(101, 0))
+
+	// Time.
+	tx.SetLockTime(1600000000)
+	assert.False(t, tx.IsFinal(1000000, 1600000000))
+	assert.True(t, tx.IsFinal(0, 1600000001))
+}
+
+func TestTransactionIsRelativeLockFinal(t *testing.T) {
+	tx := NewTransaction()
+	tx.AddInput(&TxIn{Sequence: NewSequenceFromBlocks(10)})
+	tx.AddInput(&TxIn{})
+	assert.Nil(t, tx.inputs[1].SetRelativeLockSeconds(1024))
+
+	// Version 1 does not enforce.
+	assert.True(t, tx.IsRelativeLockFinal(0, 100, 0, 100, 0))
+
+	tx.SetVersion(2)
+	assert.False(t, tx.IsRelativeLockFinal(0, 100, 0, 109, 0))
+	assert.True(t, tx.IsRelativeLockFinal(0, 100, 0, 110, 0))
+	assert.False(t, tx.IsRelativeLockFinal(1, 100, 1600000000, 200, 1600001023))
+	assert.True(t, tx.IsRelativeLockFinal(1, 100, 1600000000, 200, 1600001024))
+
+	tx.inputs[0].Sequence = SequenceFinal
+	assert.True(t, tx.IsRelativeLockFinal(0, 100, 0, 100, 0))
+}
+
+func TestTransactionBuilderSequence(t *testing.T) {
+	key := bip32.NewHDKey([]byte("this.is.alice.seed.at.2018"))
+	addr := NewPayToWitnessV0PubKeyHashAddress(key.PublicKey().Hash160())
+	script, _ := addr.LockingScript()
+	coins := NewCoinBuilder().
+		AddOutput(fmt.Sprintf("%064x", 1), 0, 100000, fmt.Sprintf("%x", script)).
+		AddOutput(fmt.Sprintf("%064x", 2), 0, 100000, fmt.Sprintf("%x", script)).
+		ToCoins()
+
+	tx, err := NewTransactionBuilder().
+		AddCoin(coins[0]).
+		AddKeys(key.PrivateKey()).
+		SetRelativeLockBlocks(144).
+		To(addr, 150000).
+		Then().
+		AddCoin(coins[1]).
+		AddKeys(key.PrivateKey()).
+		Then().
+		SetChange(addr).
+		SetVersion(2).
+		SetReplaceable().
+		Sign().
+		BuildTransaction()
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Verify())
+	assert.Equal(t, uint32(2), tx.Version())
+	assert.Equal(t, uint32(144), tx.Inputs()[0].Sequence)
+	assert.Equal(t, uint32(SequenceReplaceable), tx.Inputs()[1].Sequence)
+	assert.True(t, tx.IsReplaceable())
+
+	decoded := NewTransaction()
+	assert.Nil(t, decoded.Deserialize(tx.Serialize()))
+	assert.Equal(t, uint32(2), decoded.Version())
+	blocks, ok := decoded.Inputs()[0].RelativeLockBlocks()
+	assert.True(t, ok)
+	assert.Equal(t, uint16(144), blocks)
+
+	// The relative locktime requires version 2.
+	_, err = NewTransactionBuilder().
+		AddCoin(coins[0]).
+		AddKeys(key.PrivateKey()).
+		SetRelativeLockBlocks(144).
+		To(addr, 90000).
+		Then().
+		SetChange(addr).
+		Sign().
+		BuildTransaction()
+	assert.EqualError(t, err, "transaction.builder.input[0].relative.lock.requires.version[2] (errno 5109) (state TTB00)")
+}
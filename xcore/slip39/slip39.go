@@ -0,0 +1,449 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package slip39
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/keyfuse/tokucore/xcore/slip39/words"
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/keyfuse/tokucore/xcrypto/pbkdf2"
+)
+
+// https://github.com/satoshilabs/slips/blob/master/slip-0039.md
+const (
+	radixBits           = 10
+	radix               = 1 << radixBits
+	idBits              = 15
+	checksumWords       = 3
+	headerWords         = 4
+	metadataWords       = headerWords + checksumWords
+	digestSize          = 4
+	minSecretSize       = 16
+	minMnemonicWords    = metadataWords + (minSecretSize*8+radixBits-1)/radixBits
+	maxShareCount       = 16
+	maxIterationExp     = 15
+	baseIterationCount  = 10000
+	roundCount          = 4
+	secretIndex         = 255
+	digestIndex         = 254
+	customization       = "shamir"
+	customizationExtend = "shamir_extendable"
+)
+
+var (
+	// wordMap is a reverse lookup map for words.English.
+	wordMap map[string]int
+
+	rs1024Gen = [10]uint32{0xE0E040, 0x1C1C080, 0x3838100, 0x7070200, 0xE0E0009, 0x1C0C2412, 0x38086C24, 0x3090FC48, 0x21B1F890, 0x3F3F120}
+)
+
+func init() {
+	wordMap = make(map[string]int)
+	for i, v := range words.English {
+		wordMap[v] = i
+	}
+}
+
+// Group -- the member threshold and member count of a group.
+type Group struct {
+	Threshold int
+	Count     int
+}
+
+// Share -- the fields of a share mnemonic.
+type Share struct {
+	Identifier        uint16
+	Extendable        bool
+	IterationExponent byte
+	GroupIndex        byte
+	GroupThreshold    byte
+	GroupCount        byte
+	MemberIndex       byte
+	MemberThreshold   byte
+	Value             []byte
+}
+
+// SplitMasterSecret -- encrypts the master secret with the passphrase and splits it into the
+// groups, any groupThreshold groups with their member threshold shares recover the secret.
+// Returns the share mnemonics of every group.
+func SplitMasterSecret(secret []byte, passphrase string, groupThreshold int, groups []Group, extendable bool, iterationExponent byte) ([][]string, error) {
+	if len(secret) < minSecretSize || len(secret)%2 != 0 {
+		return nil, fmt.Errorf("slip39.master.secret.size[%v].invalid", len(secret))
+	}
+	if err := checkPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+	if groupThreshold < 1 || groupThreshold > len(groups) || len(groups) > maxShareCount {
+		return nil, fmt.Errorf("slip39.group.threshold[%v].count[%v].invalid", groupThreshold, len(groups))
+	}
+	for i, group := range groups {
+		if group.Threshold < 1 || group.Threshold > group.Count || group.Count > maxShareCount {
+			return nil, fmt.Errorf("slip39.group[%v].member.threshold[%v].count[%v].invalid", i, group.Threshold, group.Count)
+		}
+		// The 1-of-n members are the copies of the same share.
+		if group.Threshold == 1 && group.Count > 1 {
+			return nil, fmt.Errorf("slip39.group[%v].member.threshold[%v].count[%v].invalid", i, group.Threshold, group.Count)
+		}
+	}
+	if iterationExponent > maxIterationExp {
+		return nil, fmt.Errorf("slip39.iteration.exponent[%v].invalid", iterationExponent)
+	}
+
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	identifier := binary.BigEndian.Uint16(id[:]) & (1<<idBits - 1)
+	ems := cryptMasterSecret(secret, passphrase, identifier, extendable, iterationExponent, true)
+
+	groupShares, err := splitSecret(groupThreshold, len(groups), ems)
+	if err != nil {
+		return nil, err
+	}
+	mnemonics := make([][]string, len(groups))
+	for i, groupShare := range groupShares {
+		group := groups[i]
+		memberShares, err := splitSecret(group.Threshold, group.Count, groupShare.Value)
+		if err != nil {
+			return nil, err
+		}
+		for _, memberShare := range memberShares {
+			share := &Share{
+				Identifier:        identifier,
+				Extendable:        extendable,
+				IterationExponent: iterationExponent,
+				GroupIndex:        groupShare.Index,
+				GroupThreshold:    byte(groupThreshold),
+				GroupCount:        byte(len(groups)),
+				MemberIndex:       memberShare.Index,
+				MemberThreshold:   byte(group.Threshold),
+				Value:             memberShare.Value,
+			}
+			mnemonic, err := share.Mnemonic()
+			if err != nil {
+				return nil, err
+			}
+			mnemonics[i] = append(mnemonics[i], mnemonic)
+		}
+	}
+	return mnemonics, nil
+}
+
+// CombineMnemonics -- recovers the master secret from the group threshold groups,
+// each with the member threshold shares, and decrypts it with the passphrase.
+// The secret is the seed of bip32.NewHDKey.
+func CombineMnemonics(mnemonics []string, passphrase string) ([]byte, error) {
+	if len(mnemonics) == 0 {
+		return nil, fmt.Errorf("slip39.mnemonics.empty")
+	}
+	if err := checkPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+
+	var first *Share
+	groups := make(map[byte][]*Share)
+	for _, mnemonic := range mnemonics {
+		share, err := ParseShare(mnemonic)
+		if err != nil {
+			return nil, err
+		}
+		if first == nil {
+			first = share
+		}
+		if share.Identifier != first.Identifier || share.Extendable != first.Extendable || share.IterationExponent != first.IterationExponent ||
+			share.GroupThreshold != first.GroupThreshold || share.GroupCount != first.GroupCount || len(share.Value) != len(first.Value) {
+			return nil, fmt.Errorf("slip39.share.parameters.mismatch")
+		}
+		members := groups[share.GroupIndex]
+		for _, member := range members {
+			if member.MemberThreshold != share.MemberThreshold {
+				return nil, fmt.Errorf("slip39.group[%v].member.threshold.mismatch", share.GroupIndex)
+			}
+			if member.MemberIndex == share.MemberIndex {
+				return nil, fmt.Errorf("slip39.group[%v].member[%v].duplicate", share.GroupIndex, share.MemberIndex)
+			}
+		}
+		groups[share.GroupIndex] = append(members, share)
+	}
+	if len(groups) != int(first.GroupThreshold) {
+		return nil, fmt.Errorf("slip39.groups[%v].want[%v]", len(groups), first.GroupThreshold)
+	}
+
+	var indexes []int
+	for index := range groups {
+		indexes = append(indexes, int(index))
+	}
+	sort.Ints(indexes)
+
+	var groupShares []*xcrypto.ShamirShare
+	for _, index := range indexes {
+		members := groups[byte(index)]
+		threshold := int(members[0].MemberThreshold)
+		if len(members) != threshold {
+			return nil, fmt.Errorf("slip39.group[%v].shares[%v].want[%v]", index, len(members), threshold)
+		}
+		var memberShares []*xcrypto.ShamirShare
+		for _, member := range members {
+			memberShares = append(memberShares, &xcrypto.ShamirShare{Index: member.MemberIndex, Value: member.Value})
+		}
+		value, err := recoverSecret(threshold, memberShares)
+		if err != nil {
+			return nil, err
+		}
+		groupShares = append(groupShares, &xcrypto.ShamirShare{Index: byte(index), Value: value})
+	}
+	ems, err := recoverSecret(int(first.GroupThreshold), groupShares)
+	if err != nil {
+		return nil, err
+	}
+	return cryptMasterSecret(ems, passphrase, first.Identifier, first.Extendable, first.IterationExponent, false), nil
+}
+
+// IsMnemonicValid -- checks the words, checksum and padding of the share mnemonic.
+func IsMnemonicValid(mnemonic string) bool {
+	_, err := ParseShare(mnemonic)
+	return err == nil
+}
+
+// ParseShare -- decodes the share mnemonic.
+func ParseShare(mnemonic string) (*Share, error) {
+	fields := strings.Fields(strings.ToLower(mnemonic))
+	if len(fields) < minMnemonicWords {
+		return nil, fmt.Errorf("slip39.mnemonic.words[%v].invalid", len(fields))
+	}
+	indexes := make([]int, len(fields))
+	for i, field := range fields {
+		index, ok := wordMap[field]
+		if !ok {
+			return nil, fmt.Errorf("slip39.word[%v].invalid", field)
+		}
+		indexes[i] = index
+	}
+
+	var header uint64
+	for _, index := range indexes[:headerWords] {
+		header = header<<radixBits | uint64(index)
+	}
+	share := &Share{
+		Identifier:        uint16(header >> 25),
+		Extendable:        (header>>24)&1 == 1,
+		IterationExponent: byte(header>>20) & 0xf,
+		GroupIndex:        byte(header>>16) & 0xf,
+		GroupThreshold:    byte(header>>12)&0xf + 1,
+		GroupCount:        byte(header>>8)&0xf + 1,
+		MemberIndex:       byte(header>>4) & 0xf,
+		MemberThreshold:   byte(header)&0xf + 1,
+	}
+	if rs1024Polymod(share.customization(), indexes) != 1 {
+		return nil, fmt.Errorf("slip39.mnemonic.checksum.invalid")
+	}
+	if share.GroupThreshold > share.GroupCount {
+		return nil, fmt.Errorf("slip39.group.threshold[%v].count[%v].invalid", share.GroupThreshold, share.GroupCount)
+	}
+
+	// The value is left padded with at most 8 zero bits.
+	valueWords := indexes[headerWords : len(indexes)-checksumWords]
+	padding := len(valueWords) * radixBits % 16
+	if padding > 8 {
+		return nil, fmt.Errorf("slip39.mnemonic.padding[%v].invalid", padding)
+	}
+	value := new(big.Int)
+	for _, index := range valueWords {
+		value.Lsh(value, radixBits)
+		value.Or(value, big.NewInt(int64(index)))
+	}
+	size := (len(valueWords)*radixBits - padding) / 8
+	if value.BitLen() > size*8 {
+		return nil, fmt.Errorf("slip39.mnemonic.padding.not.zero")
+	}
+	share.Value = make([]byte, size)
+	value.FillBytes(share.Value)
+	return share, nil
+}
+
+// Mnemonic -- encodes the share to the mnemonic words.
+func (s *Share) Mnemonic() (string, error) {
+	if s.Identifier >= 1<<idBits || s.IterationExponent > maxIterationExp {
+		return "", fmt.Errorf("slip39.share.identifier[%v].exponent[%v].invalid", s.Identifier, s.IterationExponent)
+	}
+	if s.GroupThreshold < 1 || s.GroupThreshold > s.GroupCount || s.GroupCount > maxShareCount || s.GroupIndex >= maxShareCount {
+		return "", fmt.Errorf("slip39.share.group[%v].threshold[%v].count[%v].invalid", s.GroupIndex, s.GroupThreshold, s.GroupCount)
+	}
+	if s.MemberThreshold < 1 || s.MemberThreshold > maxShareCount || s.MemberIndex >= maxShareCount {
+		return "", fmt.Errorf("slip39.share.member[%v].threshold[%v].invalid", s.MemberIndex, s.MemberThreshold)
+	}
+	if len(s.Value) < minSecretSize || len(s.Value)%2 != 0 {
+		return "", fmt.Errorf("slip39.share.value.size[%v].invalid", len(s.Value))
+	}
+
+	var extendable uint64
+	if s.Extendable {
+		extendable = 1
+	}
+	header := uint64(s.Identifier)<<25 | extendable<<24 | uint64(s.IterationExponent)<<20 |
+		uint64(s.GroupIndex)<<16 | uint64(s.GroupThreshold-1)<<12 | uint64(s.GroupCount-1)<<8 |
+		uint64(s.MemberIndex)<<4 | uint64(s.MemberThreshold-1)
+
+	valueWords := (len(s.Value)*8 + radixBits - 1) / radixBits
+	indexes := make([]int, headerWords+valueWords, headerWords+valueWords+checksumWords)
+	for i := headerWords - 1; i >= 0; i-- {
+		indexes[i] = int(header % radix)
+		header /= radix
+	}
+	value := new(big.Int).SetBytes(s.Value)
+	mask := big.NewInt(radix - 1)
+	for i := len(indexes) - 1; i >= headerWords; i-- {
+		indexes[i] = int(new(big.Int).And(value, mask).Int64())
+		value.Rsh(value, radixBits)
+	}
+
+	checksum := rs1024Polymod(s.customization(), append(indexes, 0, 0, 0)) ^ 1
+	for i := checksumWords - 1; i >= 0; i-- {
+		indexes = append(indexes, int(checksum>>(uint(i)*radixBits))&(radix-1))
+	}
+
+	mnemonic := make([]string, len(indexes))
+	for i, index := range indexes {
+		mnemonic[i] = words.English[index]
+	}
+	return strings.Join(mnemonic, " "), nil
+}
+
+func (s *Share) customization() string {
+	if s.Extendable {
+		return customizationExtend
+	}
+	return customization
+}
+
+// rs1024Polymod -- the Reed-Solomon code over GF(1024) of the customization and the word indexes.
+func rs1024Polymod(custom string, indexes []int) uint32 {
+	chk := uint32(1)
+	step := func(v uint32) {
+		b := chk >> 20
+		chk = (chk&0xfffff)<<10 ^ v
+		for i := uint(0); i < 10; i++ {
+			if (b>>i)&1 == 1 {
+				chk ^= rs1024Gen[i]
+			}
+		}
+	}
+	for _, c := range []byte(custom) {
+		step(uint32(c))
+	}
+	for _, index := range indexes {
+		step(uint32(index))
+	}
+	return chk
+}
+
+// splitSecret -- splits the secret into count shares with the indexes 0..count-1, the secret is at
+// the index 255 and the digest share at the index 254 lets the recovery detect the invalid shares.
+func splitSecret(threshold int, count int, secret []byte) ([]*xcrypto.ShamirShare, error) {
+	shares := make([]*xcrypto.ShamirShare, 0, count)
+	if threshold == 1 {
+		for i := 0; i < count; i++ {
+			shares = append(shares, &xcrypto.ShamirShare{Index: byte(i), Value: secret})
+		}
+		return shares, nil
+	}
+
+	var base []*xcrypto.ShamirShare
+	for i := 0; i < threshold-2; i++ {
+		value := make([]byte, len(secret))
+		if _, err := rand.Read(value); err != nil {
+			return nil, err
+		}
+		share := &xcrypto.ShamirShare{Index: byte(i), Value: value}
+		shares = append(shares, share)
+		base = append(base, share)
+	}
+	random := make([]byte, len(secret)-digestSize)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	digest := append(createDigest(random, secret), random...)
+	base = append(base, &xcrypto.ShamirShare{Index: digestIndex, Value: digest}, &xcrypto.ShamirShare{Index: secretIndex, Value: secret})
+	for i := threshold - 2; i < count; i++ {
+		value, err := xcrypto.ShamirInterpolate(base, byte(i))
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, &xcrypto.ShamirShare{Index: byte(i), Value: value})
+	}
+	return shares, nil
+}
+
+// recoverSecret -- recovers the secret of splitSecret and checks the digest.
+func recoverSecret(threshold int, shares []*xcrypto.ShamirShare) ([]byte, error) {
+	if threshold == 1 {
+		return shares[0].Value, nil
+	}
+	secret, err := xcrypto.ShamirInterpolate(shares, secretIndex)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := xcrypto.ShamirInterpolate(shares, digestIndex)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(digest[:digestSize], createDigest(digest[digestSize:], secret)) {
+		return nil, fmt.Errorf("slip39.shares.digest.invalid")
+	}
+	return secret, nil
+}
+
+// createDigest -- returns the first 4 bytes of HMAC-SHA256(random, secret).
+func createDigest(random []byte, secret []byte) []byte {
+	mac := hmac.New(sha256.New, random)
+	mac.Write(secret)
+	return mac.Sum(nil)[:digestSize]
+}
+
+// cryptMasterSecret -- the 4-round Feistel cipher with the PBKDF2-HMAC-SHA256 round function,
+// the rounds are reversed for the decryption.
+func cryptMasterSecret(data []byte, passphrase string, identifier uint16, extendable bool, iterationExponent byte, encrypt bool) []byte {
+	var salt []byte
+	if !extendable {
+		salt = append([]byte(customization), byte(identifier>>8), byte(identifier))
+	}
+	iterations := (baseIterationCount << iterationExponent) / roundCount
+
+	half := len(data) / 2
+	l := append([]byte{}, data[:half]...)
+	r := append([]byte{}, data[half:]...)
+	for i := 0; i < roundCount; i++ {
+		round := byte(i)
+		if !encrypt {
+			round = byte(roundCount - 1 - i)
+		}
+		password := append([]byte{round}, passphrase...)
+		f := pbkdf2.Key(password, append(append([]byte{}, salt...), r...), iterations, len(r), sha256.New)
+		for k := range l {
+			l[k] ^= f[k]
+		}
+		l, r = r, l
+	}
+	return append(r, l...)
+}
+
+// checkPassphrase -- the passphrase is the printable ASCII.
+func checkPassphrase(passphrase string) error {
+	for _, c := range []byte(passphrase) {
+		if c < 32 || c > 126 {
+			return fmt.Errorf("slip39.passphrase.invalid")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package slip39
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xcore/bip32"
+	"github.com/keyfuse/tokucore/xcore/slip39/words"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWordList(t *testing.T) {
+	assert.Equal(t, radix, len(words.English))
+	prefixes := make(map[string]bool)
+	for i, word := range words.English {
+		if i > 0 {
+			assert.True(t, words.English[i-1] < word)
+		}
+		prefixes[word[:4]] = true
+	}
+	assert.Equal(t, radix, len(prefixes))
+}
+
+// https://github.com/trezor/python-shamir-mnemonic/blob/master/vectors.json
+func TestSlip39Vectors(t *testing.T) {
+	tests := []struct {
+		name      string
+		mnemonics []string
+		secret    string
+	}{
+		{
+			"valid.mnemonic.without.sharing.128.bits",
+			[]string{"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision keyboard"},
+			"bb54aac4b89dc868ba37d9cc21b2cece",
+		},
+		{
+			"basic.sharing.2-of-3.128.bits",
+			[]string{
+				"shadow pistol academic always adequate wildlife fancy gross oasis cylinder mustang wrist rescue view short owner flip making coding armed",
+				"shadow pistol academic acid actress prayer class unknown daughter sweater depict flip twice unkind craft early superior advocate guest smoking",
+			},
+			"b43ceb7e57a0ea8766221624d01b0864",
+		},
+		{
+			"valid.mnemonic.without.sharing.256.bits",
+			[]string{"theory painting academic academic armed sweater year military elder discuss acne wildlife boring employer fused large satoshi bundle carbon diagnose anatomy hamster leaves tracks paces beyond phantom capital marvel lips brave detect luck"},
+			"989baf9dcaad5b10ca33dfd8cc75e42477025dce88ae83e75a230086a0e00e92",
+		},
+		{
+			"valid.extendable.mnemonic.without.sharing.128.bits",
+			[]string{"testify swimming academic academic column loyalty smear include exotic bedroom exotic wrist lobe cover grief golden smart junior estimate learn"},
+			"1679b4516e0ee5954351d288a838f45e",
+		},
+	}
+	for _, test := range tests {
+		secret, err := CombineMnemonics(test.mnemonics, "TREZOR")
+		assert.Nil(t, err, test.name)
+		assert.Equal(t, test.secret, hex.EncodeToString(secret), test.name)
+
+		for _, mnemonic := range test.mnemonics {
+			assert.True(t, IsMnemonicValid(mnemonic))
+			share, err := ParseShare(mnemonic)
+			assert.Nil(t, err)
+			got, err := share.Mnemonic()
+			assert.Nil(t, err)
+			assert.Equal(t, mnemonic, got)
+		}
+	}
+
+	// The share order does not matter.
+	{
+		secret, err := CombineMnemonics([]string{tests[1].mnemonics[1], tests[1].mnemonics[0]}, "TREZOR")
+		assert.Nil(t, err)
+		assert.Equal(t, tests[1].secret, hex.EncodeToString(secret))
+	}
+
+	// The passphrase is not checked, another one decrypts another secret.
+	{
+		secret, err := CombineMnemonics(tests[0].mnemonics, "")
+		assert.Nil(t, err)
+		assert.NotEqual(t, tests[0].secret, hex.EncodeToString(secret))
+	}
+}
+
+func TestSlip39SplitCombine(t *testing.T) {
+	secret, _ := hex.DecodeString("0c94991e4a3d2a8c5bc8d5a8bd2c7e2a9c53c6bd0a8f8e3e9b7b0f3b6a5d4c3b")
+
+	tests := []struct {
+		name           string
+		secret         []byte
+		groupThreshold int
+		groups         []Group
+		extendable     bool
+	}{
+		{"1-of-1", secret[:16], 1, []Group{{1, 1}}, false},
+		{"2-of-3", secret[:16], 1, []Group{{2, 3}}, true},
+		{"5-of-5", secret, 1, []Group{{5, 5}}, false},
+		{"groups.2-of-4", secret, 2, []Group{{1, 1}, {1, 1}, {2, 5}, {3, 6}}, true},
+		{"groups.16", secret[:16], 16, []Group{{1, 1}, {1, 1}, {1, 1}, {1, 1}, {1, 1}, {1, 1}, {1, 1}, {1, 1}, {1, 1}, {1, 1}, {1, 1}, {1, 1}, {1, 1}, {1, 1}, {1, 1}, {2, 16}}, false},
+	}
+	for _, test := range tests {
+		mnemonics, err := SplitMasterSecret(test.secret, "TREZOR", test.groupThreshold, test.groups, test.extendable, 0)
+		assert.Nil(t, err, test.name)
+		assert.Equal(t, len(test.groups), len(mnemonics))
+
+		// The last groups with their last threshold members.
+		var shares []string
+		for i := len(test.groups) - test.groupThreshold; i < len(test.groups); i++ {
+			group := test.groups[i]
+			assert.Equal(t, group.Count, len(mnemonics[i]))
+			shares = append(shares, mnemonics[i][group.Count-group.Threshold:]...)
+		}
+		got, err := CombineMnemonics(shares, "TREZOR")
+		assert.Nil(t, err, test.name)
+		assert.Equal(t, test.secret, got, test.name)
+
+		share, err := ParseShare(shares[0])
+		assert.Nil(t, err)
+		assert.Equal(t, test.extendable, share.Extendable)
+		assert.Equal(t, byte(test.groupThreshold), share.GroupThreshold)
+		assert.Equal(t, byte(len(test.groups)), share.GroupCount)
+	}
+}
+
+func TestSlip39HDKey(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	mnemonics, err := SplitMasterSecret(seed, "", 1, []Group{{2, 3}}, true, 1)
+	assert.Nil(t, err)
+
+	secret, err := CombineMnemonics(mnemonics[0][1:], "")
+	assert.Nil(t, err)
+	hdkey := bip32.NewHDKey(secret)
+	// BIP32 test vector 1.
+	assert.Equal(t, "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi", hdkey.ToString(network.MainNet))
+}
+
+func TestSlip39Errors(t *testing.T) {
+	secret := make([]byte, 16)
+	vector := "duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision keyboard"
+	share1 := "shadow pistol academic always adequate wildlife fancy gross oasis cylinder mustang wrist rescue view short owner flip making coding armed"
+	share2 := "shadow pistol academic acid actress prayer class unknown daughter sweater depict flip twice unkind craft early superior advocate guest smoking"
+
+	// Split.
+	{
+		_, err := SplitMasterSecret(secret[:15], "", 1, []Group{{1, 1}}, false, 0)
+		assert.Equal(t, "slip39.master.secret.size[15].invalid", err.Error())
+		_, err = SplitMasterSecret(append(secret, 0x00), "", 1, []Group{{1, 1}}, false, 0)
+		assert.Equal(t, "slip39.master.secret.size[17].invalid", err.Error())
+		_, err = SplitMasterSecret(secret, "\x01", 1, []Group{{1, 1}}, false, 0)
+		assert.Equal(t, "slip39.passphrase.invalid", err.Error())
+		_, err = SplitMasterSecret(secret, "", 2, []Group{{1, 1}}, false, 0)
+		assert.Equal(t, "slip39.group.threshold[2].count[1].invalid", err.Error())
+		_, err = SplitMasterSecret(secret, "", 1, []Group{{3, 2}}, false, 0)
+		assert.Equal(t, "slip39.group[0].member.threshold[3].count[2].invalid", err.Error())
+		_, err = SplitMasterSecret(secret, "", 1, []Group{{1, 2}}, false, 0)
+		assert.Equal(t, "slip39.group[0].member.threshold[1].count[2].invalid", err.Error())
+		_, err = SplitMasterSecret(secret, "", 1, []Group{{2, 17}}, false, 0)
+		assert.Equal(t, "slip39.group[0].member.threshold[2].count[17].invalid", err.Error())
+		_, err = SplitMasterSecret(secret, "", 1, []Group{{1, 1}}, false, 16)
+		assert.Equal(t, "slip39.iteration.exponent[16].invalid", err.Error())
+	}
+
+	// Parse.
+	{
+		words := strings.Fields(vector)
+		_, err := ParseShare(strings.Join(words[:19], " "))
+		assert.Equal(t, "slip39.mnemonic.words[19].invalid", err.Error())
+
+		bad := append([]string{}, words...)
+		bad[5] = "bitcoin"
+		_, err = ParseShare(strings.Join(bad, " "))
+		assert.Equal(t, "slip39.word[bitcoin].invalid", err.Error())
+
+		bad[5] = "satoshi"
+		_, err = ParseShare(strings.Join(bad, " "))
+		assert.Equal(t, "slip39.mnemonic.checksum.invalid", err.Error())
+		assert.False(t, IsMnemonicValid(strings.Join(bad, " ")))
+
+		// The upper case words.
+		assert.True(t, IsMnemonicValid(strings.ToUpper(vector)))
+	}
+
+	// Padding, a valid checksum with the non-zero padding bits.
+	{
+		share, err := ParseShare(vector)
+		assert.Nil(t, err)
+		mnemonic, err := share.Mnemonic()
+		assert.Nil(t, err)
+		indexes := make([]int, 0, 20)
+		for _, word := range strings.Fields(mnemonic) {
+			indexes = append(indexes, wordMap[word])
+		}
+		indexes[headerWords] |= 0x200
+		indexes = indexes[:len(indexes)-checksumWords]
+		checksum := rs1024Polymod(customization, append(indexes, 0, 0, 0)) ^ 1
+		indexes = append(indexes, int(checksum>>20)&0x3ff, int(checksum>>10)&0x3ff, int(checksum)&0x3ff)
+		var bad []string
+		for _, index := range indexes {
+			bad = append(bad, words.English[index])
+		}
+		_, err = ParseShare(strings.Join(bad, " "))
+		assert.Equal(t, "slip39.mnemonic.padding.not.zero", err.Error())
+	}
+
+	// Combine.
+	{
+		_, err := CombineMnemonics(nil, "")
+		assert.Equal(t, "slip39.mnemonics.empty", err.Error())
+		_, err = CombineMnemonics([]string{share1}, "")
+		assert.Equal(t, "slip39.group[0].shares[1].want[2]", err.Error())
+		_, err = CombineMnemonics([]string{share1, share1}, "")
+		assert.Equal(t, "slip39.group[0].member[2].duplicate", err.Error())
+		_, err = CombineMnemonics([]string{share1, vector}, "")
+		assert.Equal(t, "slip39.share.parameters.mismatch", err.Error())
+		_, err = CombineMnemonics([]string{share1, share2}, "\x7f")
+		assert.Equal(t, "slip39.passphrase.invalid", err.Error())
+	}
+
+	// Digest, the shares of two splits with the same parameters.
+	{
+		a, err := ParseShare(share1)
+		assert.Nil(t, err)
+		b, err := ParseShare(share2)
+		assert.Nil(t, err)
+		b.Value[0] ^= 0x01
+		tampered, err := b.Mnemonic()
+		assert.Nil(t, err)
+		_, err = CombineMnemonics([]string{share1, tampered}, "")
+		assert.Equal(t, "slip39.shares.digest.invalid", err.Error())
+
+		a.MemberThreshold = 3
+		other, err := a.Mnemonic()
+		assert.Nil(t, err)
+		_, err = CombineMnemonics([]string{other, share2}, "")
+		assert.Equal(t, "slip39.group[0].member.threshold.mismatch", err.Error())
+	}
+
+	// Groups.
+	{
+		mnemonics, err := SplitMasterSecret(secret, "", 2, []Group{{1, 1}, {2, 2}, {1, 1}}, false, 0)
+		assert.Nil(t, err)
+		_, err = CombineMnemonics(mnemonics[0], "")
+		assert.Equal(t, "slip39.groups[1].want[2]", err.Error())
+		_, err = CombineMnemonics([]string{mnemonics[0][0], mnemonics[1][0], mnemonics[2][0]}, "")
+		assert.Equal(t, "slip39.groups[3].want[2]", err.Error())
+		_, err = CombineMnemonics([]string{mnemonics[0][0], mnemonics[1][0]}, "")
+		assert.Equal(t, "slip39.group[1].shares[1].want[2]", err.Error())
+		got, err := CombineMnemonics([]string{mnemonics[2][0], mnemonics[0][0]}, "")
+		assert.Nil(t, err)
+		assert.Equal(t, secret, got)
+	}
+
+	// Mnemonic.
+	{
+		share := &Share{GroupThreshold: 1, GroupCount: 1, MemberThreshold: 1, Value: secret}
+		_, err := share.Mnemonic()
+		assert.Nil(t, err)
+		share.Identifier = 1 << 15
+		_, err = share.Mnemonic()
+		assert.Equal(t, "slip39.share.identifier[32768].exponent[0].invalid", err.Error())
+		share.Identifier = 0
+		share.GroupThreshold = 2
+		_, err = share.Mnemonic()
+		assert.Equal(t, "slip39.share.group[0].threshold[2].count[1].invalid", err.Error())
+		share.GroupThreshold = 1
+		share.MemberIndex = 16
+		_, err = share.Mnemonic()
+		assert.Equal(t, "slip39.share.member[16].threshold[1].invalid", err.Error())
+		share.MemberIndex = 0
+		share.Value = secret[:14]
+		_, err = share.Mnemonic()
+		assert.Equal(t, "slip39.share.value.size[14].invalid", err.Error())
+	}
+}
@@ -33,6 +33,7 @@ type SigHashType uint32
 // Hash type bits from the end of a signature.
 const (
 	SigHashOld          SigHashType = 0x0
+	SigHashDefault      SigHashType = 0x0 // Taproot only, same as SigHashAll with a 64-byte signature.
 	SigHashAll          SigHashType = 0x1
 	SigHashNone         SigHashType = 0x2
 	SigHashSingle       SigHashType = 0x3
@@ -185,6 +186,26 @@ func (tx *Transaction) AddOutput(out *TxOut) {
 	tx.outputs = append(tx.outputs, out)
 }
 
+// Version -- returns the tx version.
+func (tx *Transaction) Version() uint32 {
+	return tx.version
+}
+
+// LockTime -- returns the tx lock time.
+func (tx *Transaction) LockTime() uint32 {
+	return tx.lockTime
+}
+
+// Inputs -- returns the inputs of the tx.
+func (tx *Transaction) Inputs() []*TxIn {
+	return tx.inputs
+}
+
+// Outputs -- returns the outputs of the tx.
+func (tx *Transaction) Outputs() []*TxOut {
+	return tx.outputs
+}
+
 // Hash -- returns the tx hash.
 func (tx *Transaction) Hash() []byte {
 	return xcrypto.DoubleSha256(tx.SerializeNoWitness())
@@ -277,6 +298,18 @@ func (tx *Transaction) EmbedIdxEcdsaSignature(idx int, pubkey *xcrypto.PubKey, e
 	return tx.EmbedIdxSignature(idx, signs)
 }
 
+// EmbedIdxSchnorrSignature -- used to embed the raw 64-byte BIP340 signature for the taproot key path.
+func (tx *Transaction) EmbedIdxSchnorrSignature(idx int, schnorrSig []byte, hashType SigHashType) error {
+	var signs []PubKeySign
+
+	finalsig := schnorrSig
+	if hashType != SigHashDefault {
+		finalsig = append(schnorrSig[:len(schnorrSig):len(schnorrSig)], byte(hashType))
+	}
+	signs = append(signs, PubKeySign{Signature: finalsig})
+	return tx.EmbedIdxSignature(idx, signs)
+}
+
 // RawSignatureHash -- returns transaction hash used to get signed/verified.
 func (tx *Transaction) RawSignatureHash(idx int, hashType SigHashType) []byte {
 	buffer := xbase.NewBuffer()
@@ -383,6 +416,121 @@ func (tx *Transaction) WitnessV0SignatureHash(idx int, hashType SigHashType) []b
 	return xcrypto.DoubleSha256(buffer.Bytes())
 }
 
+// TaprootSignatureHash -- returns transaction taproot key path signature hash.
+// Invalid hash types and the SIGHASH_SINGLE input without the output of the same index have no valid hash, see checkTaprootSigHash.
+// https://github.com/bitcoin/bips/blob/master/bip-0341.mediawiki#common-signature-message
+func (tx *Transaction) TaprootSignatureHash(idx int, hashType SigHashType) []byte {
+	return tx.taprootSignatureHash(idx, hashType, nil)
+}
+
+// TapscriptSignatureHash -- returns transaction taproot script path signature hash of the leaf.
+// https://github.com/bitcoin/bips/blob/master/bip-0342.mediawiki#signature-validation
+func (tx *Transaction) TapscriptSignatureHash(idx int, hashType SigHashType, leafHash []byte) []byte {
+	return tx.taprootSignatureHash(idx, hashType, leafHash)
+}
+
+// checkTaprootSigHash -- BIP341 accepts only the hash types 0x00-0x03 and 0x81-0x83,
+// and fails the SIGHASH_SINGLE signature of the input without the output of the same index.
+func (tx *Transaction) checkTaprootSigHash(idx int, hashType SigHashType) error {
+	switch hashType {
+	case SigHashDefault, SigHashAll, SigHashNone, SigHashSingle:
+	case SigHashAnyOneCanPay | SigHashAll, SigHashAnyOneCanPay | SigHashNone, SigHashAnyOneCanPay | SigHashSingle:
+	default:
+		return xerror.NewError(Errors, ER_TRANSACTION_SIGHASH_TYPE_INVALID, hashType)
+	}
+	if hashType&0x03 == SigHashSingle && idx >= len(tx.outputs) {
+		return xerror.NewError(Errors, ER_TRANSACTION_SIGHASH_SINGLE_NO_OUTPUT, idx, len(tx.outputs))
+	}
+	return nil
+}
+
+// taprootSignatureHash -- the key path signature hash, or the script path one with the leaf hash.
+func (tx *Transaction) taprootSignatureHash(idx int, hashType SigHashType, leafHash []byte) []byte {
+	txIn := tx.inputs[idx]
+	outputType := hashType & 0x03
+	anyoneCanPay := (hashType & SigHashAnyOneCanPay) != 0
+
+	buffer := xbase.NewBuffer()
+	// Epoch.
+	buffer.WriteU8(0x00)
+	buffer.WriteU8(byte(hashType))
+	buffer.WriteU32(tx.version)
+	buffer.WriteU32(tx.lockTime)
+
+	// sha_prevouts, sha_amounts, sha_scriptpubkeys and sha_sequences.
+	if !anyoneCanPay {
+		prevouts := xbase.NewBuffer()
+		amounts := xbase.NewBuffer()
+		scripts := xbase.NewBuffer()
+		sequences := xbase.NewBuffer()
+		for _, in := range tx.inputs {
+			prevouts.WriteBytes(in.Hash)
+			prevouts.WriteU32(in.Index)
+			amounts.WriteU64(in.Value)
+			scripts.WriteVarBytes(in.RawLockingScript)
+			sequences.WriteU32(in.Sequence)
+		}
+		buffer.WriteBytes(xcrypto.Sha256(prevouts.Bytes()))
+		buffer.WriteBytes(xcrypto.Sha256(amounts.Bytes()))
+		buffer.WriteBytes(xcrypto.Sha256(scripts.Bytes()))
+		buffer.WriteBytes(xcrypto.Sha256(sequences.Bytes()))
+	}
+
+	// sha_outputs.
+	if outputType != SigHashNone && outputType != SigHashSingle {
+		outputs := xbase.NewBuffer()
+		for _, out := range tx.outputs {
+			outputs.WriteU64(out.Value)
+			outputs.WriteVarBytes(out.Script)
+		}
+		buffer.WriteBytes(xcrypto.Sha256(outputs.Bytes()))
+	}
+
+	// spend_type: ext_flag 1 for the script path, plus 1 with the annex.
+	_, annex := taprootWitness(txIn.Witness)
+	spendType := byte(0x00)
+	if leafHash != nil {
+		spendType = 0x02
+	}
+	if annex != nil {
+		spendType |= 0x01
+	}
+	buffer.WriteU8(spendType)
+	if anyoneCanPay {
+		buffer.WriteBytes(txIn.Hash)
+		buffer.WriteU32(txIn.Index)
+		buffer.WriteU64(txIn.Value)
+		buffer.WriteVarBytes(txIn.RawLockingScript)
+		buffer.WriteU32(txIn.Sequence)
+	} else {
+		buffer.WriteU32(uint32(idx))
+	}
+
+	// sha_annex.
+	if annex != nil {
+		annexes := xbase.NewBuffer()
+		annexes.WriteVarBytes(annex)
+		buffer.WriteBytes(xcrypto.Sha256(annexes.Bytes()))
+	}
+
+	// sha_single_output.
+	if outputType == SigHashSingle && idx < len(tx.outputs) {
+		out := tx.outputs[idx]
+		output := xbase.NewBuffer()
+		output.WriteU64(out.Value)
+		output.WriteVarBytes(out.Script)
+		buffer.WriteBytes(xcrypto.Sha256(output.Bytes()))
+	}
+
+	// The tapscript extension: tapleaf_hash, key_version 0 and no OP_CODESEPARATOR executed.
+	if leafHash != nil {
+		buffer.WriteBytes(leafHash)
+		buffer.WriteU8(0x00)
+		buffer.WriteU32(0xffffffff)
+	}
+	return xcrypto.TaggedHash("TapSighash", buffer.Bytes())
+}
+
 // RawSignature -- sign the idx input and return the signature.
 func (tx *Transaction) RawSignature(idx int, hashType SigHashType, prv *xcrypto.PrvKey) ([]byte, error) {
 	// Sanity Check
@@ -424,6 +572,20 @@ func (tx *Transaction) WitnessSignature(idx int, hashType SigHashType, prv *xcry
 		if err != nil {
 			return nil, err
 		}
+	case TAPROOT:
+		// The prv must be the key of the x-only output key.
+		if err := tx.checkTaprootSigHash(idx, hashType); err != nil {
+			return nil, err
+		}
+		sighash = tx.TaprootSignatureHash(idx, hashType)
+		signature, err = xcrypto.SchnorrBIP340Sign(prv, sighash)
+		if err != nil {
+			return nil, err
+		}
+		if hashType == SigHashDefault {
+			in.SignatureHash = sighash
+			return signature, nil
+		}
 	default:
 		return nil, xerror.NewError(Errors, ER_SCRIPT_SIGNATURE_TYPE_UNKNOW, scriptVersion)
 	}
@@ -431,6 +593,29 @@ func (tx *Transaction) WitnessSignature(idx int, hashType SigHashType, prv *xcry
 	return append(signature, byte(hashType)), nil
 }
 
+// TapscriptSignature -- sign the idx input by the taproot script path of the leaf and return the BIP340 signature.
+func (tx *Transaction) TapscriptSignature(idx int, hashType SigHashType, leafHash []byte, prv *xcrypto.PrvKey) ([]byte, error) {
+	// Sanity Check
+	inputs := len(tx.inputs)
+	if idx >= inputs {
+		return nil, xerror.NewError(Errors, ER_TRANSACTION_SIGN_OUT_INDEX, idx, inputs)
+	}
+
+	if err := tx.checkTaprootSigHash(idx, hashType); err != nil {
+		return nil, err
+	}
+	in := tx.inputs[idx]
+	in.SignatureHash = tx.TapscriptSignatureHash(idx, hashType, leafHash)
+	signature, err := xcrypto.SchnorrBIP340Sign(prv, in.SignatureHash)
+	if err != nil {
+		return nil, err
+	}
+	if hashType == SigHashDefault {
+		return signature, nil
+	}
+	return append(signature, byte(hashType)), nil
+}
+
 // HasWitness -- returns whether the inputs contain witness datas.
 func (tx *Transaction) HasWitness() bool {
 	for _, in := range tx.inputs {
@@ -690,6 +875,19 @@ func (tx *Transaction) Verify() error {
 			return err
 		}
 		scriptVersion := script.GetScriptVersion()
+
+		// The taproot key path is verified without the script engine.
+		if scriptVersion == TAPROOT {
+			verify := tx.verifyTaprootKeyPath
+			if witness, _ := taprootWitness(in.Witness); len(witness) > 1 {
+				verify = tx.verifyTaprootScriptPath
+			}
+			if err := verify(i); err != nil {
+				return xerror.NewError(Errors, ER_TRANSACTION_VERIFY_FAILED, i, xbase.NewIDToString(in.Hash), in.Index)
+			}
+			continue
+		}
+
 		// Set engine handler.
 		{
 			// Signature hash function.
@@ -721,6 +919,10 @@ func (tx *Transaction) Verify() error {
 				}
 			}
 			engine.SetSigVerifyFn(sigVerifyFn)
+
+			// Locktime verifier functions.
+			engine.SetLockTimeVerifyFn(func(lockTime int64) error { return tx.verifyLockTime(i, lockTime) })
+			engine.SetSequenceVerifyFn(func(sequence int64) error { return tx.verifySequence(i, sequence) })
 		}
 
 		// Verify.
@@ -733,6 +935,90 @@ func (tx *Transaction) Verify() error {
 	return nil
 }
 
+// taprootWitness -- splits the annex off the taproot witness.
+// BIP341: with at least two items, the last one starting with 0x50 is the annex.
+func taprootWitness(witness [][]byte) ([][]byte, []byte) {
+	if n := len(witness); n >= 2 && len(witness[n-1]) > 0 && witness[n-1][0] == 0x50 {
+		return witness[:n-1], witness[n-1]
+	}
+	return witness, nil
+}
+
+// verifyTaprootKeyPath -- verifies the BIP340 signature of the idx input against the output key.
+func (tx *Transaction) verifyTaprootKeyPath(idx int) error {
+	in := tx.inputs[idx]
+	witness, _ := taprootWitness(in.Witness)
+	if len(witness) != 1 {
+		return fmt.Errorf("taproot.witness.size[%v].unsupported", len(witness))
+	}
+
+	hashType := SigHashDefault
+	signature := witness[0]
+	switch len(signature) {
+	case 64:
+	case 65:
+		hashType = SigHashType(signature[64])
+		if hashType == SigHashDefault {
+			return fmt.Errorf("taproot.sighash.type[%v].invalid", hashType)
+		}
+		signature = signature[:64]
+	default:
+		return fmt.Errorf("taproot.signature.size[%v].invalid", len(signature))
+	}
+
+	if err := tx.checkTaprootSigHash(idx, hashType); err != nil {
+		return err
+	}
+	pub, err := xcrypto.PubKeyFromXOnly(in.RawLockingScript[2:])
+	if err != nil {
+		return err
+	}
+	sighash := tx.TaprootSignatureHash(idx, hashType)
+	return xcrypto.SchnorrBIP340Verify(pub, sighash, signature)
+}
+
+// verifyTaprootScriptPath -- verifies the script path spend of the idx input,
+// witness <stack items>... <script> <control block> [annex], the leaf must commit to the output key.
+func (tx *Transaction) verifyTaprootScriptPath(idx int) error {
+	in := tx.inputs[idx]
+	witness, _ := taprootWitness(in.Witness)
+	script := witness[len(witness)-2]
+	control := witness[len(witness)-1]
+
+	leafHash, err := verifyTaprootControlBlock(in.RawLockingScript[2:], script, control)
+	if err != nil {
+		return err
+	}
+
+	engine := xvm.NewEngine()
+	engine.EnableTapscript()
+	engine.SetSigHashFn(func(hashType byte) ([]byte, error) {
+		if err := tx.checkTaprootSigHash(idx, SigHashType(hashType)); err != nil {
+			return nil, err
+		}
+		return tx.TapscriptSignatureHash(idx, SigHashType(hashType), leafHash), nil
+	})
+	engine.SetSigVerifyFn(func(hash []byte, signature []byte, pubkey []byte) error {
+		pub, err := xcrypto.PubKeyFromXOnly(pubkey)
+		if err != nil {
+			return err
+		}
+		return xcrypto.SchnorrBIP340Verify(pub, hash, signature)
+	})
+	engine.SetLockTimeVerifyFn(func(lockTime int64) error { return tx.verifyLockTime(idx, lockTime) })
+	engine.SetSequenceVerifyFn(func(sequence int64) error { return tx.verifySequence(idx, sequence) })
+
+	builder := xvm.NewScriptBuilder()
+	for _, item := range witness[:len(witness)-2] {
+		builder.AddData(item)
+	}
+	stack, err := builder.Script()
+	if err != nil {
+		return err
+	}
+	return engine.Execute(append(stack, script...))
+}
+
 // BaseSize -- the size of the transaction serialised with the witness data stripped.
 // https://github.com/bitcoin/bips/blob/master/bip-0141.mediawiki
 func (tx *Transaction) BaseSize() int {
@@ -746,8 +1032,13 @@ func (tx *Transaction) BaseSize() int {
 	for _, in := range tx.inputs {
 		size += len(in.Hash)
 		size += 4
-		size += xbase.VarIntSerializeSize(uint64(len(in.RawUnlockingScript)))
-		size += len(in.RawUnlockingScript)
+		// The unlocking of a witness input is in the witness.
+		if !in.HasWitness() {
+			size += xbase.VarIntSerializeSize(uint64(len(in.RawUnlockingScript)))
+			size += len(in.RawUnlockingScript)
+		} else {
+			size += xbase.VarIntSerializeSize(0)
+		}
 		size += 4
 	}
 
@@ -764,11 +1055,13 @@ func (tx *Transaction) BaseSize() int {
 	return size
 }
 
-// WitnessSize -- the witness datas serialised size.
+// WitnessSize -- the witness datas serialised size, including the marker and flag.
 func (tx *Transaction) WitnessSize() int {
 	size := 0
 
 	if tx.HasWitness() {
+		// Marker and flag.
+		size += 2
 		for _, in := range tx.inputs {
 			wits := in.Witness
 			size += xbase.VarIntSerializeSize(uint64(len(wits)))
@@ -790,7 +1083,7 @@ func (tx *Transaction) Weight() int {
 
 // Vsize -- defined as Transaction weight / 4 (rounded up to the next integer).
 func (tx *Transaction) Vsize() int {
-	return tx.Weight() / witnessScaleFactor
+	return (tx.Weight() + witnessScaleFactor - 1) / witnessScaleFactor
 }
 
 // Size -- size in bytes serialized as described in BIP144, including base data and witness data.
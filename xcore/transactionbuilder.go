@@ -43,15 +43,22 @@ type Group struct {
 	compressed   bool
 	redeemScript []byte
 	sigHashType  SigHashType
+	sequence     uint32
+	hasSequence  bool
 }
 
 // TransactionBuilder --
 type TransactionBuilder struct {
 	idx           int
 	sign          bool
+	dryRun        bool
+	replaceable   bool
+	feeRate       uint64
+	parents       *Package
 	maxFees       int64
 	sendFees      int64
 	relayFeePerKb int64
+	version       uint32
 	lockTime      uint32
 	change        *change
 	groups        []Group
@@ -64,6 +71,7 @@ func NewTransactionBuilder() *TransactionBuilder {
 	return &TransactionBuilder{
 		sendFees: 1000,
 		maxFees:  Unit * 1,
+		version:  1,
 		groups:   make([]Group, 1),
 	}
 }
@@ -77,6 +85,33 @@ func (b *TransactionBuilder) AddCoin(coin *Coin) *TransactionBuilder {
 	return b
 }
 
+// AddCoinSelection -- adds the selected coins as inputs signed by the keys, and sends the fees of the selection.
+// Each coin is signed by the key of its locking script, the coin matching none of the keys gets all of them.
+// The change of the selection goes to the SetChange address.
+func (b *TransactionBuilder) AddCoinSelection(selection *CoinSelection, keys ...*xcrypto.PrvKey) *TransactionBuilder {
+	for _, coin := range selection.Coins {
+		if b.groups[b.idx].coin != nil {
+			b.Then()
+		}
+		b.AddCoin(coin)
+		script, err := hex.DecodeString(coin.script)
+		if err != nil {
+			b.AddKeys(keys...)
+			continue
+		}
+		signers, compressed := matchKeys(script, nil, keys)
+		if len(signers) == 0 {
+			b.AddKeys(keys...)
+			continue
+		}
+		b.AddKeys(signers...)
+		if !compressed {
+			b.SetPubKeyUncompressed()
+		}
+	}
+	return b.SendFees(selection.Fees)
+}
+
 // AddKeys -- set the private keys for signing.
 func (b *TransactionBuilder) AddKeys(keys ...*xcrypto.PrvKey) *TransactionBuilder {
 	b.groups[b.idx].keys = keys
@@ -110,6 +145,19 @@ func (b *TransactionBuilder) SetSigHashType(typ SigHashType) *TransactionBuilder
 	return b
 }
 
+// SetSequence -- set the sequence of this input, such as a BIP68 relative locktime.
+// It overrides the sequence of SetReplaceable.
+func (b *TransactionBuilder) SetSequence(sequence uint32) *TransactionBuilder {
+	b.groups[b.idx].sequence = sequence
+	b.groups[b.idx].hasSequence = true
+	return b
+}
+
+// SetRelativeLockBlocks -- set the sequence of this input to the BIP68 relative locktime of blocks.
+func (b *TransactionBuilder) SetRelativeLockBlocks(blocks uint16) *TransactionBuilder {
+	return b.SetSequence(NewSequenceFromBlocks(blocks))
+}
+
 // SetChange -- set the change address.
 func (b *TransactionBuilder) SetChange(addr Address) *TransactionBuilder {
 	b.change = &change{addr: addr}
@@ -128,6 +176,20 @@ func (b *TransactionBuilder) SetRelayFeePerKb(relayFeePerKb int64) *TransactionB
 	return b
 }
 
+// SetFeeRate -- set the fee rate in sat/vB, the fees are computed from the worst-case size
+// of the transaction dummy signed with the change output.
+func (b *TransactionBuilder) SetFeeRate(satPerVByte uint64) *TransactionBuilder {
+	b.feeRate = satPerVByte
+	return b
+}
+
+// SetParents -- set the unconfirmed parents the transaction pays for as a child (CPFP),
+// the fees bring the package with the child to the SetFeeRate fee rate.
+func (b *TransactionBuilder) SetParents(parents *Package) *TransactionBuilder {
+	b.parents = parents
+	return b
+}
+
 // SetMaxFees -- set the max fee, the maxFees is non-zero after setting.
 // If the tx fees larger than the max, it returns error after the building.
 func (b *TransactionBuilder) SetMaxFees(max int64) *TransactionBuilder {
@@ -135,6 +197,18 @@ func (b *TransactionBuilder) SetMaxFees(max int64) *TransactionBuilder {
 	return b
 }
 
+// SetReplaceable -- signals the BIP125 replaceability by the input sequences.
+func (b *TransactionBuilder) SetReplaceable() *TransactionBuilder {
+	b.replaceable = true
+	return b
+}
+
+// SetVersion -- set the transaction version(default 1), the relative locktimes require version 2.
+func (b *TransactionBuilder) SetVersion(version uint32) *TransactionBuilder {
+	b.version = version
+	return b
+}
+
 // SetLockTime -- set the locktime.
 func (b *TransactionBuilder) SetLockTime(lockTime uint32) *TransactionBuilder {
 	b.lockTime = lockTime
@@ -153,6 +227,13 @@ func (b *TransactionBuilder) Sign() *TransactionBuilder {
 	return b
 }
 
+// DryRun -- signs the inputs with worst case sized dummy signatures instead of the keys,
+// the transaction has the worst-case size of the signed one but can not be broadcast.
+func (b *TransactionBuilder) DryRun() *TransactionBuilder {
+	b.dryRun = true
+	return b
+}
+
 // Then --
 // say that one group is end we will start a new one.
 func (b *TransactionBuilder) Then() *TransactionBuilder {
@@ -194,6 +275,15 @@ func (b *TransactionBuilder) BuildTransaction() (*Transaction, error) {
 				if err != nil {
 					return nil, err
 				}
+				if b.replaceable {
+					txin.Sequence = SequenceReplaceable
+				}
+				if group.hasSequence {
+					txin.Sequence = group.sequence
+					if !txin.RelativeLockDisabled() && txin.Sequence&SequenceLockTimeMask != 0 && b.version < RelativeLockTimeVersion {
+						return nil, xerror.NewError(Errors, ER_TRANSACTION_BUILDER_RELATIVE_LOCK_VERSION, len(txins), RelativeLockTimeVersion)
+					}
+				}
 				txins = append(txins, txin)
 				totalIn += int64(grpinput.value)
 
@@ -257,6 +347,18 @@ func (b *TransactionBuilder) BuildTransaction() (*Transaction, error) {
 		estimateFees = EstimateFees(estimateSize, b.relayFeePerKb)
 		fees = estimateFees
 	}
+	if b.feeRate > 0 {
+		weight, err := b.dryRunWeight(txins, inputs, txouts)
+		if err != nil {
+			return nil, err
+		}
+		fees = EstimateFeesForWeight(weight, b.feeRate)
+		if b.parents != nil {
+			fees = b.parents.ChildFees(weight, b.feeRate)
+		}
+	} else if b.parents != nil {
+		return nil, xerror.NewError(Errors, ER_TRANSACTION_BUILDER_PACKAGE_FEE_RATE_EMPTY)
+	}
 	if fees > b.maxFees {
 		return nil, xerror.NewError(Errors, ER_TRANSACTION_BUILDER_FEE_TOO_HIGH, fees, b.maxFees)
 	}
@@ -285,38 +387,19 @@ func (b *TransactionBuilder) BuildTransaction() (*Transaction, error) {
 	}
 
 	// Build tx.
-	transaction := NewTransaction()
-	{
-		// LockTime.
-		transaction.SetLockTime(b.lockTime)
-
-		// Txin.
-		for _, txin := range txins {
-			transaction.AddInput(txin)
-		}
-
-		// Txout.
-		for _, txout := range txouts {
-			transaction.AddOutput(txout)
-		}
-
-		// Change Txout.
-		if changeTxOut != nil {
-			transaction.AddOutput(changeTxOut)
-		}
+	transaction, err := b.assemble(txins, txouts, changeTxOut)
+	if err != nil {
+		return nil, err
+	}
 
-		// Push datas.
-		for _, data := range b.pushDatas {
-			pushData, err := xvm.NewScriptBuilder().AddOp(xvm.OP_RETURN).AddData(data).Script()
-			if err != nil {
+	// Sign.
+	if b.dryRun {
+		for i, input := range inputs {
+			if err := transaction.DummySignIndex(i, input.compressed, input.sigHashType); err != nil {
 				return nil, err
 			}
-			transaction.AddOutput(NewTxOut(0, pushData))
 		}
-	}
-
-	// Sign.
-	if b.sign {
+	} else if b.sign {
 		for i, input := range inputs {
 			if input.keys == nil {
 				return nil, xerror.NewError(Errors, ER_TRANSACTION_BUILDER_SIGN_KEY_EMPTY, i)
@@ -328,3 +411,68 @@ func (b *TransactionBuilder) BuildTransaction() (*Transaction, error) {
 	}
 	return transaction, nil
 }
+
+// assemble -- the transaction of the inputs, the outputs, the change and the push datas.
+func (b *TransactionBuilder) assemble(txins []*TxIn, txouts []*TxOut, changeTxOut *TxOut) (*Transaction, error) {
+	transaction := NewTransaction()
+
+	// Version.
+	transaction.SetVersion(b.version)
+
+	// LockTime.
+	transaction.SetLockTime(b.lockTime)
+
+	// Txin.
+	for _, txin := range txins {
+		transaction.AddInput(txin)
+	}
+
+	// Txout.
+	for _, txout := range txouts {
+		transaction.AddOutput(txout)
+	}
+
+	// Change Txout.
+	if changeTxOut != nil {
+		transaction.AddOutput(changeTxOut)
+	}
+
+	// Push datas.
+	for _, data := range b.pushDatas {
+		pushData, err := xvm.NewScriptBuilder().AddOp(xvm.OP_RETURN).AddData(data).Script()
+		if err != nil {
+			return nil, err
+		}
+		transaction.AddOutput(NewTxOut(0, pushData))
+	}
+	return transaction, nil
+}
+
+// dryRunWeight -- the weight of the transaction with the change output and dummy signatures.
+func (b *TransactionBuilder) dryRunWeight(txins []*TxIn, inputs []*input, txouts []*TxOut) (int64, error) {
+	var changeTxOut *TxOut
+	if b.change != nil {
+		script, err := b.change.addr.LockingScript()
+		if err != nil {
+			return 0, err
+		}
+		changeTxOut = NewTxOut(0, script)
+	}
+
+	// Copies, the dummy signatures must not leak into the inputs.
+	dummyTxins := make([]*TxIn, len(txins))
+	for i, txin := range txins {
+		dummy := *txin
+		dummyTxins[i] = &dummy
+	}
+	transaction, err := b.assemble(dummyTxins, txouts, changeTxOut)
+	if err != nil {
+		return 0, err
+	}
+	for i, input := range inputs {
+		if err := transaction.DummySignIndex(i, input.compressed, input.sigHashType); err != nil {
+			return 0, err
+		}
+	}
+	return int64(transaction.Weight()), nil
+}
@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xbase"
 	"github.com/keyfuse/tokucore/xcore/bip32"
 	"github.com/keyfuse/tokucore/xcrypto"
 	"github.com/keyfuse/tokucore/xerror"
@@ -417,6 +418,212 @@ func TestTransactionBuilderP2WSH(t *testing.T) {
 	}
 }
 
+func TestTransactionBuilderP2TR(t *testing.T) {
+	seed := []byte("this.is.bohu.seed.")
+	bohuHDKey := bip32.NewHDKey(seed)
+	bohuPrv := bohuHDKey.PrivateKey()
+	bohuPub := bohuHDKey.PublicKey()
+	bohu := NewPayToPubKeyHashAddress(bohuPub.Hash160())
+
+	// Satoshi.
+	seed = []byte("this.is.satoshi.seed.")
+	satoshiHDKey := bip32.NewHDKey(seed)
+	satoshiPrv := satoshiHDKey.PrivateKey()
+	satoshiPubKey := satoshiHDKey.PublicKey()
+	satoshi := NewPayToTaprootAddress(satoshiPubKey.SerializeXOnly())
+	t.Logf("satoshi.p2tr.addr:%v", satoshi.ToString(network.TestNet))
+
+	// Funding.
+	var fundID string
+	var fundScript []byte
+	{
+		bohuCoin := NewCoinBuilder().AddOutput(
+			"f519a75190312039ddf885231205006b14f2e69f6e5b02314cb0e367b027fa86",
+			1,
+			127297408,
+			"76a9145a927ddadc0ef3ae4501d0d9872b57c9584b9d8888ac",
+		).ToCoins()[0]
+
+		tx, err := NewTransactionBuilder().
+			AddCoin(bohuCoin).
+			AddKeys(bohuPrv).
+			To(satoshi, 666666).
+			Then().
+			SetChange(bohu).
+			SetRelayFeePerKb(20000).
+			Then().
+			Sign().
+			BuildTransaction()
+		assert.Nil(t, err)
+
+		// Verify.
+		err = tx.Verify()
+		assert.Nil(t, err)
+		fundID = tx.ID()
+		fundScript = tx.outputs[0].Script
+		t.Logf("fund.txid:%v", tx.ID())
+	}
+
+	// Spending.
+	{
+		satoshiCoin := NewCoinBuilder().AddOutput(
+			fundID,
+			0,
+			666666,
+			fmt.Sprintf("%x", fundScript),
+		).ToCoins()[0]
+
+		tx, err := NewTransactionBuilder().
+			AddCoin(satoshiCoin).
+			AddKeys(satoshiPrv).
+			To(bohu, 66666).
+			Then().
+			SetChange(satoshi).
+			SetRelayFeePerKb(20000).
+			Then().
+			Sign().
+			BuildTransaction()
+		assert.Nil(t, err)
+
+		// Verify.
+		err = tx.Verify()
+		assert.Nil(t, err)
+		assert.Equal(t, 65, len(tx.inputs[0].Witness[0]))
+
+		// Deserialize and verify.
+		signedTx := tx.Serialize()
+		t.Logf("spending.signed.tx:%x", signedTx)
+		tx2 := NewTransaction()
+		err = tx2.Deserialize(signedTx)
+		assert.Nil(t, err)
+		err = tx2.SetTxIn(0, 666666, fundScript, nil)
+		assert.Nil(t, err)
+		err = tx2.Verify()
+		assert.Nil(t, err)
+		assert.Equal(t, tx.ID(), tx2.ID())
+
+		// The amount is committed.
+		err = tx2.SetTxIn(0, 666667, fundScript, nil)
+		assert.Nil(t, err)
+		err = tx2.Verify()
+		assert.NotNil(t, err)
+	}
+
+	// SigHashDefault.
+	{
+		satoshiCoin := NewCoinBuilder().AddOutput(
+			fundID,
+			0,
+			666666,
+			fmt.Sprintf("%x", fundScript),
+		).ToCoins()[0]
+
+		tx, err := NewTransactionBuilder().
+			AddCoin(satoshiCoin).
+			AddKeys(satoshiPrv).
+			SetSigHashType(SigHashDefault).
+			To(bohu, 66666).
+			Then().
+			SetChange(satoshi).
+			Then().
+			Sign().
+			BuildTransaction()
+		assert.Nil(t, err)
+		err = tx.Verify()
+		assert.Nil(t, err)
+		assert.Equal(t, 64, len(tx.inputs[0].Witness[0]))
+
+		// Embed the raw signature.
+		sighash := tx.TaprootSignatureHash(0, SigHashDefault)
+		sig, err := xcrypto.SchnorrBIP340Sign(satoshiPrv, sighash)
+		assert.Nil(t, err)
+		err = tx.EmbedIdxSchnorrSignature(0, sig, SigHashDefault)
+		assert.Nil(t, err)
+		err = tx.Verify()
+		assert.Nil(t, err)
+
+		// The wrong key.
+		sig, err = xcrypto.SchnorrBIP340Sign(bohuPrv, sighash)
+		assert.Nil(t, err)
+		err = tx.EmbedIdxSchnorrSignature(0, sig, SigHashDefault)
+		assert.Nil(t, err)
+		err = tx.Verify()
+		assert.NotNil(t, err)
+
+		// The key path with the annex, which is committed.
+		annex := []byte{0x50, 0x01, 0x02}
+		tx.inputs[0].Witness = [][]byte{nil, annex}
+		sig, err = xcrypto.SchnorrBIP340Sign(satoshiPrv, tx.TaprootSignatureHash(0, SigHashDefault))
+		assert.Nil(t, err)
+		tx.inputs[0].Witness = [][]byte{sig, annex}
+		err = tx.Verify()
+		assert.Nil(t, err)
+		tx.inputs[0].Witness = [][]byte{sig, {0x50, 0x01, 0x03}}
+		err = tx.Verify()
+		assert.NotNil(t, err)
+		tx.inputs[0].Witness = [][]byte{sig}
+		err = tx.Verify()
+		assert.NotNil(t, err)
+	}
+
+	// SigHashSingle without the output of the same index fails.
+	{
+		fundHash, err := xbase.NewIDFromString(fundID)
+		assert.Nil(t, err)
+		txin, err := NewTxIn(fundHash, 0, 666666, fundScript, nil)
+		assert.Nil(t, err)
+		tx := NewTransaction()
+		tx.AddInput(txin)
+
+		_, err = tx.WitnessSignature(0, SigHashSingle, satoshiPrv)
+		assert.EqualError(t, err, "transaction.sighash.single.input[0].has.no.output.outputs[0] (errno 5003) (state TTX00)")
+		_, err = tx.TapscriptSignature(0, SigHashSingle|SigHashAnyOneCanPay, TapLeafHash([]byte{0x51}), satoshiPrv)
+		assert.EqualError(t, err, "transaction.sighash.single.input[0].has.no.output.outputs[0] (errno 5003) (state TTX00)")
+
+		// The verifier rejects it.
+		sig, err := xcrypto.SchnorrBIP340Sign(satoshiPrv, tx.TaprootSignatureHash(0, SigHashSingle))
+		assert.Nil(t, err)
+		err = tx.EmbedIdxSchnorrSignature(0, sig, SigHashSingle)
+		assert.Nil(t, err)
+		err = tx.verifyTaprootKeyPath(0)
+		assert.EqualError(t, err, "transaction.sighash.single.input[0].has.no.output.outputs[0] (errno 5003) (state TTX00)")
+		assert.NotNil(t, tx.Verify())
+	}
+
+	// Only the BIP341 hash types are signed and verified.
+	{
+		fundHash, err := xbase.NewIDFromString(fundID)
+		assert.Nil(t, err)
+		txin, err := NewTxIn(fundHash, 0, 666666, fundScript, nil)
+		assert.Nil(t, err)
+		tx := NewTransaction()
+		tx.AddInput(txin)
+		tx.AddOutput(NewTxOut(66666, fundScript))
+
+		for _, hashType := range []SigHashType{0x04, 0x80, 0x84, 0x101} {
+			_, err = tx.WitnessSignature(0, hashType, satoshiPrv)
+			assert.EqualError(t, err, fmt.Sprintf("transaction.sighash.type[%v].invalid (errno 5004) (state TTX00)", hashType))
+			_, err = tx.TapscriptSignature(0, hashType, TapLeafHash([]byte{0x51}), satoshiPrv)
+			assert.EqualError(t, err, fmt.Sprintf("transaction.sighash.type[%v].invalid (errno 5004) (state TTX00)", hashType))
+		}
+		for _, hashType := range []SigHashType{0x04, 0x80, 0x84} {
+			sig, err := xcrypto.SchnorrBIP340Sign(satoshiPrv, tx.TaprootSignatureHash(0, hashType))
+			assert.Nil(t, err)
+			err = tx.EmbedIdxSchnorrSignature(0, sig, hashType)
+			assert.Nil(t, err)
+			err = tx.verifyTaprootKeyPath(0)
+			assert.EqualError(t, err, fmt.Sprintf("transaction.sighash.type[%v].invalid (errno 5004) (state TTX00)", hashType))
+			assert.NotNil(t, tx.Verify())
+		}
+		for _, hashType := range []SigHashType{SigHashAll, SigHashNone, SigHashSingle, SigHashAll | SigHashAnyOneCanPay, SigHashNone | SigHashAnyOneCanPay, SigHashSingle | SigHashAnyOneCanPay} {
+			sig, err := tx.WitnessSignature(0, hashType, satoshiPrv)
+			assert.Nil(t, err)
+			tx.inputs[0].Witness = [][]byte{sig}
+			assert.Nil(t, tx.Verify())
+		}
+	}
+}
+
 func TestTransactionBuilderWithUncompressedPubKey(t *testing.T) {
 	seed := []byte("this.is.bohu.seed.")
 	bohuHDKey := bip32.NewHDKey(seed)
@@ -687,9 +894,9 @@ func TestTransactionBuilderTSSP2PKH(t *testing.T) {
 		t.Logf("tx:%v", tx.ToString())
 
 		// Verify.
+		// The MPC nonce is fresh on every signing, so the txid is not fixed.
 		err = tx.Verify()
 		assert.Nil(t, err)
-		assert.Equal(t, "5784c155eccfbf0ec636698b99b65c5d32417134443d789edb82bbf877f33f90", tx.ID())
 
 		t.Logf("txid:%v", tx.ID())
 		signedTx := tx.Serialize()
@@ -0,0 +1,103 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/keyfuse/tokucore/xerror"
+)
+
+const (
+	// wifCompressFlag -- the suffix byte of a key whose public key is serialized compressed.
+	wifCompressFlag byte = 0x01
+)
+
+// WIF -- a private key in the Wallet Import Format:
+// base58check(PrivateKeyID || key32 [|| 0x01 if compressed]).
+type WIF struct {
+	PrvKey     *xcrypto.PrvKey
+	Compressed bool
+}
+
+// NewWIF -- creates a new WIF.
+func NewWIF(prv *xcrypto.PrvKey, compressed bool) *WIF {
+	return &WIF{
+		PrvKey:     prv,
+		Compressed: compressed,
+	}
+}
+
+// ToString -- the WIF string of the network.
+func (w *WIF) ToString(net *network.Network) string {
+	payload := w.PrvKey.Serialize()
+	if w.Compressed {
+		payload = append(payload, wifCompressFlag)
+	}
+	return xbase.Base58CheckEncode(payload, net.PrivateKeyID)
+}
+
+// PubKeyBytes -- the serialized public key the key's addresses are derived from.
+func (w *WIF) PubKeyBytes() []byte {
+	if w.Compressed {
+		return w.PrvKey.PubKey().SerializeCompressed()
+	}
+	return w.PrvKey.PubKey().SerializeUncompressed()
+}
+
+// DecodeWIF -- decodes the WIF string, the key must belong to the network.
+func DecodeWIF(wif string, net *network.Network) (*WIF, error) {
+	w, netID, err := decodeWIF(wif)
+	if err != nil {
+		return nil, err
+	}
+	if netID != net.PrivateKeyID {
+		return nil, xerror.NewError(Errors, ER_WIF_NETWORK_MISMATCH, netID, net.PrivateKeyID)
+	}
+	return w, nil
+}
+
+// DetectWIFNetwork -- returns the first of the networks whose PrivateKeyID matches the WIF.
+// Networks sharing the same PrivateKeyID (e.g. testnet and regtest) can not be told apart.
+func DetectWIFNetwork(wif string, nets ...*network.Network) (*network.Network, error) {
+	_, netID, err := decodeWIF(wif)
+	if err != nil {
+		return nil, err
+	}
+	for _, net := range nets {
+		if net.PrivateKeyID == netID {
+			return net, nil
+		}
+	}
+	return nil, xerror.NewError(Errors, ER_WIF_NETWORK_UNKNOWN, netID)
+}
+
+// decodeWIF -- decodes the WIF string and returns the network id.
+func decodeWIF(wif string) (*WIF, byte, error) {
+	decoded, netID, err := xbase.Base58CheckDecode(wif)
+	if err != nil {
+		return nil, 0, xerror.NewError(Errors, ER_WIF_FORMAT_MALFORMED, err)
+	}
+
+	compressed := false
+	switch len(decoded) {
+	case xcrypto.PrvKeyBytesLen:
+	case xcrypto.PrvKeyBytesLen + 1:
+		if decoded[xcrypto.PrvKeyBytesLen] != wifCompressFlag {
+			return nil, 0, xerror.NewError(Errors, ER_WIF_COMPRESS_FLAG_INVALID, decoded[xcrypto.PrvKeyBytesLen])
+		}
+		compressed = true
+	default:
+		return nil, 0, xerror.NewError(Errors, ER_WIF_SIZE_MALFORMED, len(decoded))
+	}
+
+	prv := xcrypto.PrvKeyFromBytes(decoded[:xcrypto.PrvKeyBytesLen])
+	if prv.D.Sign() == 0 || prv.D.Cmp(prv.Curve.Params().N) >= 0 {
+		return nil, 0, xerror.NewError(Errors, ER_WIF_PRVKEY_INVALID)
+	}
+	return NewWIF(prv, compressed), netID, nil
+}
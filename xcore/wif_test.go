@@ -0,0 +1,117 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcore
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWIF(t *testing.T) {
+	tests := []struct {
+		key        string
+		net        *network.Network
+		compressed bool
+		wif        string
+	}{
+		{
+			key: "0c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d",
+			net: network.MainNet,
+			wif: "5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ",
+		},
+		{
+			key:        "0c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d",
+			net:        network.MainNet,
+			compressed: true,
+			wif:        "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617",
+		},
+		{
+			key: "0000000000000000000000000000000000000000000000000000000000000001",
+			net: network.MainNet,
+			wif: "5HpHagT65TZzG1PH3CSu63k8DbpvD8s5ip4nEB3kEsreAnchuDf",
+		},
+		{
+			key:        "0000000000000000000000000000000000000000000000000000000000000001",
+			net:        network.MainNet,
+			compressed: true,
+			wif:        "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn",
+		},
+		{
+			key:        "0000000000000000000000000000000000000000000000000000000000000001",
+			net:        network.TestNet,
+			compressed: true,
+			wif:        "cMahea7zqjxrtgAbB7LSGbcQUr1uX1ojuat9jZodMN87JcbXMTcA",
+		},
+	}
+
+	for _, test := range tests {
+		key, _ := hex.DecodeString(test.key)
+		wif := NewWIF(xcrypto.PrvKeyFromBytes(key), test.compressed)
+		assert.Equal(t, test.wif, wif.ToString(test.net))
+
+		decoded, err := DecodeWIF(test.wif, test.net)
+		assert.Nil(t, err)
+		assert.Equal(t, test.key, hex.EncodeToString(decoded.PrvKey.Serialize()))
+		assert.Equal(t, test.compressed, decoded.Compressed)
+		assert.Equal(t, wif.PubKeyBytes(), decoded.PubKeyBytes())
+
+		net, err := DetectWIFNetwork(test.wif, network.MainNet, network.TestNet)
+		assert.Nil(t, err)
+		assert.Equal(t, test.net, net)
+	}
+
+	// Pubkey serialization follows the flag.
+	{
+		wif, err := DecodeWIF("KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn", network.MainNet)
+		assert.Nil(t, err)
+		assert.Equal(t, 33, len(wif.PubKeyBytes()))
+		wif, err = DecodeWIF("5HpHagT65TZzG1PH3CSu63k8DbpvD8s5ip4nEB3kEsreAnchuDf", network.MainNet)
+		assert.Nil(t, err)
+		assert.Equal(t, 65, len(wif.PubKeyBytes()))
+	}
+}
+
+func TestWIFError(t *testing.T) {
+	// Testnet key on mainnet.
+	{
+		_, err := DecodeWIF("cMahea7zqjxrtgAbB7LSGbcQUr1uX1ojuat9jZodMN87JcbXMTcA", network.MainNet)
+		assert.EqualError(t, err, "wif.network.id[ef].mismatch.want[80] (errno 2205) (state TWIF0)")
+		_, err = DetectWIFNetwork("cMahea7zqjxrtgAbB7LSGbcQUr1uX1ojuat9jZodMN87JcbXMTcA", network.MainNet)
+		assert.EqualError(t, err, "wif.network.id[ef].unknown (errno 2206) (state TWIF0)")
+	}
+
+	// Checksum.
+	{
+		_, err := DecodeWIF("KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWo", network.MainNet)
+		assert.EqualError(t, err, "wif.format.malformed[checksum error] (errno 2201) (state TWIF0)")
+		_, err = DetectWIFNetwork("", network.MainNet)
+		assert.NotNil(t, err)
+	}
+
+	// Size and flag.
+	{
+		key := make([]byte, 34)
+		key[31] = 0x01
+		_, err := DecodeWIF(xbase.Base58CheckEncode(key, 0x80), network.MainNet)
+		assert.EqualError(t, err, "wif.size[34].invalid (errno 2202) (state TWIF0)")
+		_, err = DecodeWIF(xbase.Base58CheckEncode(key[:33], 0x80), network.MainNet)
+		assert.EqualError(t, err, "wif.compress.flag[00].invalid (errno 2203) (state TWIF0)")
+	}
+
+	// Out of range.
+	{
+		_, err := DecodeWIF(xbase.Base58CheckEncode(make([]byte, 32), 0x80), network.MainNet)
+		assert.EqualError(t, err, "wif.prvkey.out.of.range (errno 2204) (state TWIF0)")
+		n := xcrypto.PrvKeyFromBytes([]byte{0x01}).Curve.Params().N.Bytes()
+		_, err = DecodeWIF(xbase.Base58CheckEncode(n, 0x80), network.MainNet)
+		assert.EqualError(t, err, "wif.prvkey.out.of.range (errno 2204) (state TWIF0)")
+	}
+}
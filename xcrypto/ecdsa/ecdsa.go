@@ -15,6 +15,13 @@ import (
 
 // Sign -- generates a deterministic ECDSA signature according to RFC 6979 and BIP62.
 func Sign(priv *ecdsa.PrivateKey, hash []byte) (*big.Int, *big.Int, error) {
+	r, s, _, err := SignRecoverable(priv, hash)
+	return r, s, err
+}
+
+// SignRecoverable -- generates the signature as Sign, with the recovery id of the public key.
+// The bit 0 of the recovery id is the parity of R.y, the bit 1 is set if R.x >= N.
+func SignRecoverable(priv *ecdsa.PrivateKey, hash []byte) (*big.Int, *big.Int, byte, error) {
 	c := priv.PublicKey.Curve
 	D := priv.D
 	N := c.Params().N
@@ -24,10 +31,14 @@ func Sign(priv *ecdsa.PrivateKey, hash []byte) (*big.Int, *big.Int, error) {
 
 	// point (x1,y1) = k*G
 	// r = x1
-	r, _ := priv.Curve.ScalarBaseMult(k.Bytes())
+	r, y := priv.Curve.ScalarBaseMult(k.Bytes())
+	recid := byte(y.Bit(0))
+	if r.Cmp(N) >= 0 {
+		recid |= 0x02
+	}
 	r.Mod(r, N)
 	if r.Sign() == 0 {
-		return nil, nil, errors.New("calculated R is zero")
+		return nil, nil, 0, errors.New("calculated R is zero")
 	}
 
 	// s = (hash+D*r)/k mod N
@@ -39,23 +50,116 @@ func Sign(priv *ecdsa.PrivateKey, hash []byte) (*big.Int, *big.Int, error) {
 	// The signature is composed of two values, the r value and the s value.
 	// If the s value is greater than N/2, which is not allowed.
 	// Just add in some code that if s is greater than N/2, then s = N - s.
+	// The signature of -k flips the parity of R.y.
 	halfOrder := new(big.Int).Rsh(N, 1)
 	if s.Cmp(halfOrder) == 1 {
 		s.Sub(N, s)
+		recid ^= 0x01
 	}
 	if s.Sign() == 0 {
-		return nil, nil, errors.New("calculated S is zero")
+		return nil, nil, 0, errors.New("calculated S is zero")
 	}
 
 	// Clean.
 	k.SetInt64(0)
-	return r, s, nil
+	return r, s, recid, nil
+}
+
+// RecoverPubKey -- recovers the public key from the signature of hash and the recovery id,
+// the curve must be y² = x³ + b such as secp256k1.
+//
+//	R = (r + (recid>>1)*N, y) with the parity of y is recid&1
+//	Q = r^-1 * (s*R - e*G)
+func RecoverPubKey(c elliptic.Curve, hash []byte, r *big.Int, s *big.Int, recid byte) (*big.Int, *big.Int, error) {
+	P := c.Params().P
+	N := c.Params().N
+
+	if recid > 3 {
+		return nil, nil, errors.New("recovery id is invalid")
+	}
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(N) >= 0 || s.Cmp(N) >= 0 {
+		return nil, nil, errors.New("signature is out of range")
+	}
+
+	// R.x = r + j*N
+	Rx := new(big.Int).Set(r)
+	if recid&0x02 != 0 {
+		Rx.Add(Rx, N)
+		if Rx.Cmp(P) >= 0 {
+			return nil, nil, errors.New("calculated R.x is out of range")
+		}
+	}
+
+	// R.y = sqrt(x^3 + b)
+	y2 := new(big.Int).Exp(Rx, big.NewInt(3), P)
+	y2.Add(y2, c.Params().B)
+	y2.Mod(y2, P)
+	Ry := new(big.Int).ModSqrt(y2, P)
+	if Ry == nil {
+		return nil, nil, errors.New("calculated R is not on the curve")
+	}
+	if Ry.Bit(0) != uint(recid&0x01) {
+		Ry.Sub(P, Ry)
+	}
+
+	// u1 = -e/r, u2 = s/r
+	e := HashToInt(c, hash)
+	rinv := new(big.Int).ModInverse(r, N)
+	u1 := e.Mul(e, rinv)
+	u1.Neg(u1)
+	u1.Mod(u1, N)
+	u2 := new(big.Int).Mul(s, rinv)
+	u2.Mod(u2, N)
+
+	var Qx, Qy *big.Int
+	if cm, ok := c.(combinedMult); ok {
+		Qx, Qy = cm.CombinedMult(Rx, Ry, u1.Bytes(), u2.Bytes())
+	} else {
+		x1, y1 := c.ScalarBaseMult(u1.Bytes())
+		x2, y2 := c.ScalarMult(Rx, Ry, u2.Bytes())
+		Qx, Qy = c.Add(x1, y1, x2, y2)
+	}
+	if Qx.Sign() == 0 && Qy.Sign() == 0 {
+		return nil, nil, errors.New("calculated Q is the infinity")
+	}
+	return Qx, Qy, nil
+}
+
+// combinedMult -- the curve which computes baseScalar*G + scalar*P in one pass, such as secp256k1.
+type combinedMult interface {
+	CombinedMult(Px, Py *big.Int, baseScalar, scalar []byte) (x, y *big.Int)
 }
 
-// Verify -- calls ecdsa.Verify to verify the signature of hash using the public key.
+// Verify -- verifies the signature of hash using the public key.
+// The curve with CombinedMult computes u1*G + u2*P in one pass, otherwise calls ecdsa.Verify.
 // Returns true if the signature is valid, false otherwise.
 func Verify(pub *ecdsa.PublicKey, hash []byte, r *big.Int, s *big.Int) bool {
-	return ecdsa.Verify(pub, hash, r, s)
+	c := pub.Curve
+	cm, ok := c.(combinedMult)
+	if !ok {
+		return ecdsa.Verify(pub, hash, r, s)
+	}
+
+	N := c.Params().N
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(N) >= 0 || s.Cmp(N) >= 0 {
+		return false
+	}
+
+	// u1 = e/s, u2 = r/s
+	e := HashToInt(c, hash)
+	w := new(big.Int).ModInverse(s, N)
+	u1 := e.Mul(e, w)
+	u1.Mod(u1, N)
+	u2 := w.Mul(r, w)
+	u2.Mod(u2, N)
+
+	// (x, y) = u1*G + u2*P
+	x, y := cm.CombinedMult(pub.X, pub.Y, u1.Bytes(), u2.Bytes())
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return false
+	}
+	x.Mod(x, N)
+	return x.Cmp(r) == 0
 }
 
 // HashToInt -- converts a hash value to an integer. There is some disagreement
@@ -13,6 +13,8 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/sha256"
+
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
 )
 
 type ecdsaFixture struct {
@@ -239,3 +241,67 @@ func BenchmarkVerifyP256(b *testing.B) {
 		}
 	}
 }
+
+func TestVerifySecp256k1(t *testing.T) {
+	curve := secp256k1.SECP256K1()
+	d := ecdsaLoadInt("15bafcb56279dbfd985d4d17cdaf9bbfc6701b628f9fb00d6d1e0d2cb503ede3")
+	x, y := curve.ScalarBaseMult(d.Bytes())
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y},
+		D:         d,
+	}
+	digest := sha256.Sum256([]byte("sample"))
+
+	r, s, err := Sign(key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Verify(&key.PublicKey, digest[:], r, s) {
+		t.Fatal("verify.failed")
+	}
+
+	// Same as the generic verification.
+	if !ecdsa.Verify(&key.PublicKey, digest[:], r, s) {
+		t.Fatal("generic.verify.failed")
+	}
+
+	// Tampered.
+	digest[0] ^= 0x01
+	if Verify(&key.PublicKey, digest[:], r, s) {
+		t.Fatal("tampered.digest.verified")
+	}
+	digest[0] ^= 0x01
+	if Verify(&key.PublicKey, digest[:], s, r) {
+		t.Fatal("swapped.rs.verified")
+	}
+	if Verify(&key.PublicKey, digest[:], r, curve.Params().N) {
+		t.Fatal("s.out.of.range.verified")
+	}
+}
+
+func TestRecoverPubKey(t *testing.T) {
+	curve := secp256k1.SECP256K1()
+	digest := sha256.Sum256([]byte("sample"))
+	for i := 1; i < 16; i++ {
+		d := big.NewInt(int64(i * 2019))
+		x, y := curve.ScalarBaseMult(d.Bytes())
+		key := &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y},
+			D:         d,
+		}
+		r, s, recid, err := SignRecoverable(key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		rx, ry, err := RecoverPubKey(curve, digest[:], r, s, recid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rx.Cmp(x) != 0 || ry.Cmp(y) != 0 {
+			t.Fatalf("%d: recovered.pubkey.mismatch", i)
+		}
+		if _, _, err := RecoverPubKey(curve, digest[:], r, s, 4); err == nil {
+			t.Fatal("recid.4.recovered")
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/keyfuse/tokucore/xcrypto/hkdf"
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+)
+
+const (
+	// EciesVersion -- the wire format version of the ECIES ciphertext.
+	EciesVersion byte = 0x01
+
+	// EciesOverhead -- the ciphertext is EciesOverhead bytes longer than the plaintext.
+	EciesOverhead = 1 + pubKeyBytesLenCompressed + eciesNonceLen + eciesTagLen
+
+	eciesNonceLen = 12
+	eciesTagLen   = 16
+	eciesKeyLen   = 32
+	eciesInfo     = "tokucore.ecies.v1"
+)
+
+// The ECIES ciphertext layout is
+// version(1) || ephemeral pubkey(33) || nonce(12) || AES-256-GCM(plain) || tag(16).
+// The key is HKDF-SHA256(ECDH(ephemeral, recipient), ephemeral pubkey || recipient pubkey, "tokucore.ecies.v1")
+// with the compressed pubkeys, and the header is authenticated as additional data.
+
+// ECDH -- returns the shared secret sha256(compressed(d*P)) with the pub, the same as libsecp256k1.
+func (p *PrvKey) ECDH(pub *PubKey) ([]byte, error) {
+	curve := secp256k1.SECP256K1()
+	if pub == nil || pub.X == nil || pub.Y == nil || !curve.IsOnCurve(pub.X, pub.Y) {
+		return nil, fmt.Errorf("ecdh.pubkey.is.not.on.the.curve")
+	}
+	d := new(big.Int).Mod(p.D, curve.Params().N)
+	if d.Sign() == 0 {
+		return nil, fmt.Errorf("ecdh.prvkey.invalid")
+	}
+	x, y := curve.ScalarMult(pub.X, pub.Y, d.Bytes())
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, fmt.Errorf("ecdh.shared.point.is.infinity")
+	}
+	shared := &PubKey{X: x, Y: y, Curve: curve}
+	return Sha256(shared.SerializeCompressed()), nil
+}
+
+// EciesEncrypt -- encrypts the plain to the pub with a fresh ephemeral key.
+func EciesEncrypt(pub *PubKey, plain []byte) ([]byte, error) {
+	N := secp256k1.SECP256K1().Params().N
+	d, err := rand.Int(rand.Reader, new(big.Int).Sub(N, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+	d.Add(d, big.NewInt(1))
+	nonce := make([]byte, eciesNonceLen)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return eciesEncrypt(pub, plain, PrvKeyFromBytes(d.Bytes()), nonce)
+}
+
+// EciesDecrypt -- decrypts the EciesEncrypt ciphertext with the recipient prv.
+func EciesDecrypt(prv *PrvKey, data []byte) ([]byte, error) {
+	var err error
+	var version byte
+	var ephemeral, nonce []byte
+
+	if len(data) < EciesOverhead {
+		return nil, fmt.Errorf("ecies.ciphertext.size[%v].invalid", len(data))
+	}
+	buffer := xbase.NewBufferReader(data)
+	if version, err = buffer.ReadU8(); err != nil {
+		return nil, err
+	}
+	if version != EciesVersion {
+		return nil, fmt.Errorf("ecies.version[%v].unsupported", version)
+	}
+	if ephemeral, err = buffer.ReadBytes(pubKeyBytesLenCompressed); err != nil {
+		return nil, err
+	}
+	if nonce, err = buffer.ReadBytes(eciesNonceLen); err != nil {
+		return nil, err
+	}
+	pub, err := PubKeyFromBytes(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := eciesAEAD(prv, pub, ephemeral, prv.PubKey().SerializeCompressed())
+	if err != nil {
+		return nil, err
+	}
+	header := data[:buffer.Seek()]
+	plain, err := aead.Open(nil, nonce, buffer.Remaining(), header)
+	if err != nil {
+		return nil, fmt.Errorf("ecies.decrypt.failed")
+	}
+	return plain, nil
+}
+
+// eciesEncrypt -- encrypts the plain with the ephemeral key and nonce.
+func eciesEncrypt(pub *PubKey, plain []byte, ephemeral *PrvKey, nonce []byte) ([]byte, error) {
+	epub := ephemeral.PubKey().SerializeCompressed()
+	aead, err := eciesAEAD(ephemeral, pub, epub, pub.SerializeCompressed())
+	if err != nil {
+		return nil, err
+	}
+
+	header := xbase.NewBuffer()
+	header.WriteU8(EciesVersion)
+	header.WriteBytes(epub)
+	header.WriteBytes(nonce)
+	return aead.Seal(header.Bytes(), nonce, plain, header.Bytes()), nil
+}
+
+// eciesAEAD -- returns the AES-256-GCM with the key derived from the ECDH secret.
+func eciesAEAD(prv *PrvKey, pub *PubKey, ephemeral []byte, recipient []byte) (cipher.AEAD, error) {
+	secret, err := prv.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	key, err := hkdf.Key(sha256.New, secret, salt, []byte(eciesInfo), eciesKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,107 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEcdh(t *testing.T) {
+	alice := PrvKeyFromBytes(Sha256([]byte("tokucore.ecies.recipient")))
+	bob := PrvKeyFromBytes(Sha256([]byte("tokucore.ecies.ephemeral")))
+
+	secret1, err := alice.ECDH(bob.PubKey())
+	assert.Nil(t, err)
+	secret2, err := bob.ECDH(alice.PubKey())
+	assert.Nil(t, err)
+	assert.Equal(t, secret1, secret2)
+	assert.Equal(t, "f42771ec7e2412f5863fa7dc7a3809abc3952c4bcb7f5e1a7e2fc9ea115f8f1c", hex.EncodeToString(secret1))
+
+	// Errors.
+	{
+		pub := &PubKey{X: bob.PubKey().X, Y: alice.PubKey().Y, Curve: alice.Curve}
+		_, err := alice.ECDH(pub)
+		assert.Equal(t, "ecdh.pubkey.is.not.on.the.curve", err.Error())
+
+		_, err = alice.ECDH(nil)
+		assert.Equal(t, "ecdh.pubkey.is.not.on.the.curve", err.Error())
+
+		zero := PrvKeyFromBytes(alice.Curve.Params().N.Bytes())
+		_, err = zero.ECDH(bob.PubKey())
+		assert.Equal(t, "ecdh.prvkey.invalid", err.Error())
+	}
+}
+
+func TestEciesVector(t *testing.T) {
+	recipient := PrvKeyFromBytes(Sha256([]byte("tokucore.ecies.recipient")))
+	ephemeral := PrvKeyFromBytes(Sha256([]byte("tokucore.ecies.ephemeral")))
+	nonce, _ := hex.DecodeString("000102030405060708090a0b")
+	plain := []byte("tokucore.ecies.message")
+	assert.Equal(t, "03f2a57cb129fcc561303a7fef2ddae3198867f1d79373afc575dc5e008204674b", hex.EncodeToString(recipient.PubKey().SerializeCompressed()))
+
+	want := "01" +
+		"02aca864c67c909dd6dbea8dc1cfd87c7669e55ad4bcdec50eb5e0901038dff46b" +
+		"000102030405060708090a0b" +
+		"f317bba9d9aa9cf749aa6cd995c27c0a758d6c04b3eb0a781e794173211d828912ec9b2cfb45"
+	data, err := eciesEncrypt(recipient.PubKey(), plain, ephemeral, nonce)
+	assert.Nil(t, err)
+	assert.Equal(t, want, hex.EncodeToString(data))
+	assert.Equal(t, len(plain)+EciesOverhead, len(data))
+
+	got, err := EciesDecrypt(recipient, data)
+	assert.Nil(t, err)
+	assert.Equal(t, plain, got)
+}
+
+func TestEcies(t *testing.T) {
+	alice := PrvKeyFromBytes([]byte("this.is.alice.ecies.key"))
+	bob := PrvKeyFromBytes([]byte("this.is.bob.ecies.key"))
+
+	for _, plain := range [][]byte{{}, []byte("tokucore"), make([]byte, 1024)} {
+		data, err := EciesEncrypt(alice.PubKey(), plain)
+		assert.Nil(t, err)
+		got, err := EciesDecrypt(alice, data)
+		assert.Nil(t, err)
+		assert.True(t, bytes.Equal(plain, got))
+
+		// Fresh ephemeral key and nonce.
+		data2, err := EciesEncrypt(alice.PubKey(), plain)
+		assert.Nil(t, err)
+		assert.NotEqual(t, data, data2)
+
+		_, err = EciesDecrypt(bob, data)
+		assert.Equal(t, "ecies.decrypt.failed", err.Error())
+	}
+
+	data, err := EciesEncrypt(alice.PubKey(), []byte("tokucore"))
+	assert.Nil(t, err)
+
+	// Every byte is authenticated.
+	for i := range data {
+		bad := append([]byte{}, data...)
+		bad[i] ^= 0x01
+		_, err := EciesDecrypt(alice, bad)
+		assert.NotNil(t, err, i)
+	}
+
+	// Version.
+	{
+		bad := append([]byte{}, data...)
+		bad[0] = 0x02
+		_, err := EciesDecrypt(alice, bad)
+		assert.Equal(t, "ecies.version[2].unsupported", err.Error())
+	}
+
+	// Size.
+	{
+		_, err := EciesDecrypt(alice, data[:EciesOverhead-1])
+		assert.Equal(t, "ecies.ciphertext.size[61].invalid", err.Error())
+	}
+}
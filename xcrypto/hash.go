@@ -11,6 +11,7 @@ import (
 	"math/big"
 
 	"github.com/keyfuse/tokucore/xcrypto/ripemd160"
+	"github.com/keyfuse/tokucore/xcrypto/schnorr"
 )
 
 func calcHash(buf []byte, hasher hash.Hash) []byte {
@@ -46,6 +47,11 @@ func DoubleSha256(data []byte) []byte {
 	return calcHash(hash, sha256.New())
 }
 
+// TaggedHash -- returns the BIP340 tagged hash sha256(sha256(tag) || sha256(tag) || msgs).
+func TaggedHash(tag string, msgs ...[]byte) []byte {
+	return schnorr.TaggedHash(tag, msgs...)
+}
+
 // BytesToBigInt -- returns big int for the b bytes represents.
 func BytesToBigInt(b []byte) *big.Int {
 	return new(big.Int).SetBytes(b)
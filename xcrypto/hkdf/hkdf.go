@@ -0,0 +1,51 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package hkdf
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"hash"
+)
+
+// Extract -- the HKDF-Extract of RFC 5869, returns the pseudorandom key HMAC(salt, secret).
+// A nil salt is the zero-filled string of the hash size.
+func Extract(h func() hash.Hash, secret, salt []byte) []byte {
+	if salt == nil {
+		salt = make([]byte, h().Size())
+	}
+	extractor := hmac.New(h, salt)
+	extractor.Write(secret)
+	return extractor.Sum(nil)
+}
+
+// Expand -- the HKDF-Expand of RFC 5869, returns keyLen bytes of the output keying material:
+//
+//	T(i) = HMAC(prk, T(i-1) || info || i)
+func Expand(h func() hash.Hash, prk, info []byte, keyLen int) ([]byte, error) {
+	expander := hmac.New(h, prk)
+	size := expander.Size()
+	if keyLen < 0 || keyLen > 255*size {
+		return nil, fmt.Errorf("hkdf.key.length[%v].invalid", keyLen)
+	}
+
+	var prev []byte
+	okm := make([]byte, 0, keyLen+size)
+	for counter := byte(1); len(okm) < keyLen; counter++ {
+		expander.Reset()
+		expander.Write(prev)
+		expander.Write(info)
+		expander.Write([]byte{counter})
+		prev = expander.Sum(prev[:0])
+		okm = append(okm, prev...)
+	}
+	return okm[:keyLen], nil
+}
+
+// Key -- derives keyLen bytes from the secret, salt and info with Extract-then-Expand.
+func Key(h func() hash.Hash, secret, salt, info []byte, keyLen int) ([]byte, error) {
+	return Expand(h, Extract(h, secret, salt), info, keyLen)
+}
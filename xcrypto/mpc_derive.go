@@ -0,0 +1,301 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The MPC joint key can be used as a BIP32 extended public key: the parties
+// agree a chain code and derive the non-hardened children locally, so the
+// child PubKey is the same as bip32.HDKey.Derive of the joint xpub.
+//
+// The chain code is the hash of a random share from every party. Each party
+// commits to its share before any share is revealed, so the last one to
+// reveal can not choose its share to bias the chain code.
+//
+// A BIP32 child is X' = X + t*G with t from HMAC-SHA512(chain, X || i).
+// The 2P-ECDSA shares are multiplicative, so the child party keeps its share
+// and signs with z + r*t in place of z, since s = k^-1 * (z + r*(x + t)).
+// The Schnorr shares are additive, so the party with the smallest public key
+// adds t to its share.
+
+const (
+	// MpcChainCodeSize -- the size of the chain code and its shares.
+	MpcChainCodeSize = 32
+)
+
+// ChainCodePhase1 -- draws the random share of the chain code.
+// Return the commitment to the share to send to the other party.
+func (party *EcdsaParty) ChainCodePhase1() ([]byte, error) {
+	share, err := mpcChainCodeShare()
+	if err != nil {
+		return nil, err
+	}
+	party.chainShare = share
+	party.chainCommits = nil
+	return mpcChainCodeCommit(share), nil
+}
+
+// ChainCodePhase2 -- keeps the commitment of the other party.
+// Return the share to reveal to the other party.
+func (party *EcdsaParty) ChainCodePhase2(commit2 []byte) ([]byte, error) {
+	commits, err := mpcChainCodeCommits(party.chainShare, commit2)
+	if err != nil {
+		return nil, err
+	}
+	party.chainCommits = commits
+	return party.chainShare, nil
+}
+
+// ChainCodePhase3 -- agrees the chain code with the share of the other party,
+// which must open its commitment.
+// Return the chain code of the joint extended public key.
+func (party *EcdsaParty) ChainCodePhase3(share2 []byte) ([]byte, error) {
+	chain, err := mpcChainCode(party.chainShare, party.chainCommits, share2)
+	if err != nil {
+		return nil, err
+	}
+	party.chain = chain
+	party.chainShare = nil
+	party.chainCommits = nil
+	return chain, nil
+}
+
+// ChainCode -- returns the chain code of the joint extended public key.
+func (party *EcdsaParty) ChainCode() []byte {
+	return party.chain
+}
+
+// Derive -- derives the non-hardened child party at path (m/0/1) from the public key of the other party.
+// Return the child party and the child shared PubKey.
+func (party *EcdsaParty) Derive(pub2 *PubKey, path string) (*EcdsaParty, *PubKey, error) {
+	if party.prv == nil {
+		return nil, nil, fmt.Errorf("mpc.party.closed")
+	}
+	if party.chain == nil {
+		return nil, nil, fmt.Errorf("mpc.derive.chain.code.missing")
+	}
+	t, pub, chain, err := mpcDerivePath(party.Phase1(pub2), party.chain, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if party.tweak != nil {
+		t.Add(t, party.tweak)
+		t.Mod(t, party.N)
+	}
+
+	child := NewEcdsaParty(party.prv)
+	child.chain = chain
+	child.tweak = t
+	return child, pub, nil
+}
+
+// ChainCodePhase1 -- draws the random share of the chain code.
+// Return the commitment to the share to broadcast to the other parties.
+func (party *SchnorrParty) ChainCodePhase1() ([]byte, error) {
+	share, err := mpcChainCodeShare()
+	if err != nil {
+		return nil, err
+	}
+	party.chainShare = share
+	party.chainCommits = nil
+	return mpcChainCodeCommit(share), nil
+}
+
+// ChainCodePhase2 -- keeps the commitments of the other parties.
+// Return the share to broadcast to the other parties.
+func (party *SchnorrParty) ChainCodePhase2(commits ...[]byte) ([]byte, error) {
+	kept, err := mpcChainCodeCommits(party.chainShare, commits...)
+	if err != nil {
+		return nil, err
+	}
+	party.chainCommits = kept
+	return party.chainShare, nil
+}
+
+// ChainCodePhase3 -- agrees the chain code with the shares of the other parties,
+// in the order of their commitments.
+// Return the chain code of the joint extended public key.
+func (party *SchnorrParty) ChainCodePhase3(shares ...[]byte) ([]byte, error) {
+	chain, err := mpcChainCode(party.chainShare, party.chainCommits, shares...)
+	if err != nil {
+		return nil, err
+	}
+	party.chain = chain
+	party.chainShare = nil
+	party.chainCommits = nil
+	return chain, nil
+}
+
+// ChainCode -- returns the chain code of the joint extended public key.
+func (party *SchnorrParty) ChainCode() []byte {
+	return party.chain
+}
+
+// Derive -- derives the non-hardened child party at path (m/0/1) from the public keys of the other parties.
+// Return the child party and the child shared PubKey.
+func (party *SchnorrParty) Derive(pubs []*PubKey, path string) (*SchnorrParty, *PubKey, error) {
+	if party.prv == nil {
+		return nil, nil, fmt.Errorf("mpc.party.closed")
+	}
+	if party.chain == nil {
+		return nil, nil, fmt.Errorf("mpc.derive.chain.code.missing")
+	}
+	if len(pubs) == 0 {
+		return nil, nil, fmt.Errorf("mpc.derive.pubkeys.empty")
+	}
+
+	shared := party.pub
+	lowest := true
+	me := party.pub.SerializeCompressed()
+	for _, pub := range pubs {
+		order := bytes.Compare(me, pub.SerializeCompressed())
+		if order == 0 {
+			return nil, nil, fmt.Errorf("mpc.derive.pubkey.duplicate")
+		}
+		if order > 0 {
+			lowest = false
+		}
+		shared = shared.Add(pub)
+	}
+	t, pub, chain, err := mpcDerivePath(shared, party.chain, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prv := party.prv
+	if lowest {
+		d := new(big.Int).Add(prv.D, t)
+		d.Mod(d, party.N)
+		if d.Sign() == 0 {
+			return nil, nil, fmt.Errorf("mpc.derive.child.invalid")
+		}
+		prv = PrvKeyFromBytes(d.Bytes())
+	}
+	child, err := NewSchnorrParty(prv)
+	if err != nil {
+		return nil, nil, err
+	}
+	child.chain = chain
+	return child, pub, nil
+}
+
+func mpcChainCodeShare() ([]byte, error) {
+	share := make([]byte, MpcChainCodeSize)
+	if _, err := rand.Read(share); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// mpcChainCodeCommit -- returns the commitment sha256("mpc.chaincode.commit" || share).
+func mpcChainCodeCommit(share []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte("mpc.chaincode.commit"))
+	h.Write(share)
+	return h.Sum(nil)
+}
+
+// mpcChainCodeCommits -- checks and copies the commitments of the other parties.
+func mpcChainCodeCommits(share []byte, commits ...[]byte) ([][]byte, error) {
+	if share == nil {
+		return nil, fmt.Errorf("mpc.chain.code.phase1.missing")
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("mpc.chain.code.commitments.empty")
+	}
+	kept := make([][]byte, len(commits))
+	for i, c := range commits {
+		if len(c) != MpcChainCodeSize {
+			return nil, fmt.Errorf("mpc.chain.code.commitment.size[%v].invalid", len(c))
+		}
+		kept[i] = append([]byte{}, c...)
+	}
+	return kept, nil
+}
+
+// mpcChainCode -- checks the shares against the commitments,
+// returns sha256(sorted shares), the same for every party.
+func mpcChainCode(share []byte, commits [][]byte, shares ...[]byte) ([]byte, error) {
+	if share == nil {
+		return nil, fmt.Errorf("mpc.chain.code.phase1.missing")
+	}
+	if commits == nil {
+		return nil, fmt.Errorf("mpc.chain.code.phase2.missing")
+	}
+	if len(shares) != len(commits) {
+		return nil, fmt.Errorf("mpc.chain.code.shares[%v].commitments[%v].mismatch", len(shares), len(commits))
+	}
+	all := [][]byte{share}
+	for i, s := range shares {
+		if len(s) != MpcChainCodeSize {
+			return nil, fmt.Errorf("mpc.chain.code.share.size[%v].invalid", len(s))
+		}
+		if !hmac.Equal(mpcChainCodeCommit(s), commits[i]) {
+			return nil, fmt.Errorf("mpc.chain.code.share[%v].commitment.mismatch", i)
+		}
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return bytes.Compare(all[i], all[j]) < 0
+	})
+	h := sha256.New()
+	h.Write([]byte("mpc.chaincode"))
+	for _, s := range all {
+		h.Write(s)
+	}
+	return h.Sum(nil), nil
+}
+
+// mpcDerivePath -- runs the BIP32 public derivation along the non-hardened path.
+// Return the sum of the tweaks, the child PubKey and the child chain code.
+func mpcDerivePath(pub *PubKey, chain []byte, path string) (*big.Int, *PubKey, []byte, error) {
+	N := pub.Curve.Params().N
+	steps := strings.Split(path, "/")
+	if steps[0] != "m" {
+		return nil, nil, nil, fmt.Errorf("mpc.derive.path.invalid[%v]", path)
+	}
+
+	t := new(big.Int)
+	for _, step := range steps[1:] {
+		idx, err := strconv.ParseUint(step, 10, 31)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("mpc.derive.path.invalid[%v]", path)
+		}
+		var index [4]byte
+		binary.BigEndian.PutUint32(index[:], uint32(idx))
+
+		// I = HMAC-SHA512(chain, serP(K) || ser32(i)).
+		mac := hmac.New(sha512.New, chain)
+		mac.Write(pub.SerializeCompressed())
+		mac.Write(index[:])
+		intermediary := mac.Sum(nil)
+
+		il := new(big.Int).SetBytes(intermediary[:32])
+		if il.Cmp(N) >= 0 {
+			return nil, nil, nil, fmt.Errorf("mpc.derive.child[%v].invalid", idx)
+		}
+		pub = pub.Add(pointBaseMult(il))
+		if pub.X.Sign() == 0 && pub.Y.Sign() == 0 {
+			return nil, nil, nil, fmt.Errorf("mpc.derive.child[%v].invalid", idx)
+		}
+		t.Add(t, il)
+		t.Mod(t, N)
+		chain = intermediary[32:]
+	}
+	return t, pub, chain, nil
+}
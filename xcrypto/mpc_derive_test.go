@@ -0,0 +1,298 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMpcEcdsaDerive(t *testing.T) {
+	hash := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+	p1, _ := new(big.Int).SetString("15bafcb56279dbfd985d4d17cdaf9bbfc6701b628f9fb00d6d1e0d2cb503ede3", 16)
+	p2, _ := new(big.Int).SetString("76818c328b8aa1e8f17bd599016fef8134b7d5ec315e0b6373953da7e8b5c0c9", 16)
+	party1 := NewEcdsaParty(PrvKeyFromBytes(p1.Bytes()))
+	defer party1.Close()
+	party2 := NewEcdsaParty(PrvKeyFromBytes(p2.Bytes()))
+	defer party2.Close()
+	pub1 := party1.PubKey()
+	pub2 := party2.PubKey()
+
+	// Chain code.
+	_, _, err := party1.Derive(pub2, "m/0/1")
+	assert.NotNil(t, err)
+	c1, err := party1.ChainCodePhase1()
+	assert.Nil(t, err)
+	c2, err := party2.ChainCodePhase1()
+	assert.Nil(t, err)
+	s1, err := party1.ChainCodePhase2(c2)
+	assert.Nil(t, err)
+	s2, err := party2.ChainCodePhase2(c1)
+	assert.Nil(t, err)
+	chain1, err := party1.ChainCodePhase3(s2)
+	assert.Nil(t, err)
+	chain2, err := party2.ChainCodePhase3(s1)
+	assert.Nil(t, err)
+	assert.Equal(t, chain1, chain2)
+	assert.Equal(t, MpcChainCodeSize, len(party1.ChainCode()))
+
+	// Derive.
+	child1, childpub1, err := party1.Derive(pub2, "m/0/1")
+	assert.Nil(t, err)
+	child2, childpub2, err := party2.Derive(pub1, "m/0/1")
+	assert.Nil(t, err)
+	assert.Equal(t, childpub1, childpub2)
+	assert.Equal(t, childpub1, child1.Phase1(pub2))
+	assert.NotEqual(t, party1.Phase1(pub2), childpub1)
+
+	// Step by step is the same.
+	step, _, err := party1.Derive(pub2, "m/0")
+	assert.Nil(t, err)
+	_, steppub, err := step.Derive(pub2, "m/1")
+	assert.Nil(t, err)
+	assert.Equal(t, childpub1, steppub)
+
+	// Sign with the child.
+	sig := mpcEcdsaSign(t, child1, child2, hash)
+	assert.Nil(t, EcdsaVerify(childpub1, hash, sig))
+	assert.NotNil(t, EcdsaVerify(party1.Phase1(pub2), hash, sig))
+
+	// The parent signs the same hash under another nonce.
+	childSig, parentSig := NewSignatureEcdsa(), NewSignatureEcdsa()
+	assert.Nil(t, childSig.Deserialize(sig))
+	assert.Nil(t, parentSig.Deserialize(mpcEcdsaSign(t, party1, party2, hash)))
+	assert.NotEqual(t, childSig.R, parentSig.R)
+
+	// The nonce is bound to the tweak, even if the randomness repeats.
+	reader := rand.Reader
+	rand.Reader = bytes.NewReader(make([]byte, 64))
+	parentData, err := mpcNonceData(hash, party1.tweak)
+	assert.Nil(t, err)
+	childData, err := mpcNonceData(hash, child1.tweak)
+	assert.Nil(t, err)
+	rand.Reader = reader
+	assert.NotEqual(t, parentData, childData)
+
+	// Path.
+	for _, path := range []string{"", "0/1", "m/0'/1", "m/a", "m/2147483648"} {
+		_, _, err := party1.Derive(pub2, path)
+		assert.NotNil(t, err, path)
+	}
+
+	// The chain code agreement fails.
+	_, err = party1.ChainCodePhase2(c2)
+	assert.EqualError(t, err, "mpc.chain.code.phase1.missing")
+	c1, _ = party1.ChainCodePhase1()
+	_, err = party1.ChainCodePhase3(s2)
+	assert.EqualError(t, err, "mpc.chain.code.phase2.missing")
+	_, err = party1.ChainCodePhase2([]byte{0x01})
+	assert.EqualError(t, err, "mpc.chain.code.commitment.size[1].invalid")
+	c2, _ = party2.ChainCodePhase1()
+	_, err = party1.ChainCodePhase2(c2)
+	assert.Nil(t, err)
+
+	// The share chosen after seeing the other one does not open the commitment.
+	_, err = party1.ChainCodePhase3(Sha256(c1))
+	assert.EqualError(t, err, "mpc.chain.code.share[0].commitment.mismatch")
+	_, err = party1.ChainCodePhase3([]byte{0x01})
+	assert.EqualError(t, err, "mpc.chain.code.share.size[1].invalid")
+	assert.Equal(t, chain1, party1.ChainCode())
+}
+
+// mpcEcdsaChainCode -- runs the chain code agreement of the two parties.
+func mpcEcdsaChainCode(t *testing.T, party1 *EcdsaParty, party2 *EcdsaParty) []byte {
+	c1, err := party1.ChainCodePhase1()
+	assert.Nil(t, err)
+	c2, err := party2.ChainCodePhase1()
+	assert.Nil(t, err)
+	s1, err := party1.ChainCodePhase2(c2)
+	assert.Nil(t, err)
+	s2, err := party2.ChainCodePhase2(c1)
+	assert.Nil(t, err)
+	chain1, err := party1.ChainCodePhase3(s2)
+	assert.Nil(t, err)
+	chain2, err := party2.ChainCodePhase3(s1)
+	assert.Nil(t, err)
+	assert.Equal(t, chain1, chain2)
+	return chain1
+}
+
+// mpcSchnorrChainCode -- runs the chain code agreement of the two parties.
+func mpcSchnorrChainCode(t *testing.T, party1 *SchnorrParty, party2 *SchnorrParty) []byte {
+	c1, err := party1.ChainCodePhase1()
+	assert.Nil(t, err)
+	c2, err := party2.ChainCodePhase1()
+	assert.Nil(t, err)
+	s1, err := party1.ChainCodePhase2(c2)
+	assert.Nil(t, err)
+	s2, err := party2.ChainCodePhase2(c1)
+	assert.Nil(t, err)
+	chain1, err := party1.ChainCodePhase3(s2)
+	assert.Nil(t, err)
+	chain2, err := party2.ChainCodePhase3(s1)
+	assert.Nil(t, err)
+	assert.Equal(t, chain1, chain2)
+	return chain1
+}
+
+func TestMpcSchnorrDerive(t *testing.T) {
+	hash := Sha256([]byte{0x01, 0x02, 0x03, 0x04})
+	p1, _ := new(big.Int).SetString("15bafcb56279dbfd985d4d17cdaf9bbfc6701b628f9fb00d6d1e0d2cb503ede3", 16)
+	p2, _ := new(big.Int).SetString("76818c328b8aa1e8f17bd599016fef8134b7d5ec315e0b6373953da7e8b5c0c9", 16)
+	party1, _ := NewSchnorrParty(PrvKeyFromBytes(p1.Bytes()))
+	defer party1.Close()
+	party2, _ := NewSchnorrParty(PrvKeyFromBytes(p2.Bytes()))
+	defer party2.Close()
+	pub1 := party1.PubKey()
+	pub2 := party2.PubKey()
+
+	// Chain code.
+	chain1 := mpcSchnorrChainCode(t, party1, party2)
+
+	// Derive.
+	child1, childpub1, err := party1.Derive([]*PubKey{pub2}, "m/0/7")
+	assert.Nil(t, err)
+	child2, childpub2, err := party2.Derive([]*PubKey{pub1}, "m/0/7")
+	assert.Nil(t, err)
+	assert.Equal(t, childpub1, childpub2)
+	assert.Equal(t, childpub1, child1.Phase1(child2.PubKey()))
+	assert.Equal(t, chain1, party1.ChainCode())
+	assert.NotEqual(t, chain1, child1.ChainCode())
+	assert.Equal(t, child1.ChainCode(), child2.ChainCode())
+
+	// Sign with the child.
+	r1 := child1.Phase2(hash)
+	r2 := child2.Phase2(hash)
+	shareR1 := child1.Phase3(r2)
+	shareR2 := child2.Phase3(r1)
+	sig1, err := child1.Phase4(childpub1, shareR1)
+	assert.Nil(t, err)
+	sig2, err := child2.Phase4(childpub2, shareR2)
+	assert.Nil(t, err)
+	sig, err := child1.Phase5(shareR1, sig1, sig2)
+	assert.Nil(t, err)
+	assert.Nil(t, SchnorrVerify(childpub1, hash, sig))
+
+	_, _, err = party1.Derive(nil, "m/0/7")
+	assert.NotNil(t, err)
+	_, _, err = party1.Derive([]*PubKey{pub1}, "m/0/7")
+	assert.NotNil(t, err)
+}
+
+func TestMpcDeriveSeal(t *testing.T) {
+	passphrase := []byte("passphrase")
+	p1, _ := new(big.Int).SetString("15bafcb56279dbfd985d4d17cdaf9bbfc6701b628f9fb00d6d1e0d2cb503ede3", 16)
+	p2, _ := new(big.Int).SetString("76818c328b8aa1e8f17bd599016fef8134b7d5ec315e0b6373953da7e8b5c0c9", 16)
+
+	// ECDSA.
+	{
+		hash := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+		party1 := NewEcdsaParty(PrvKeyFromBytes(p1.Bytes()))
+		defer party1.Close()
+		party2 := NewEcdsaParty(PrvKeyFromBytes(p2.Bytes()))
+		defer party2.Close()
+		pub1 := party1.PubKey()
+		pub2 := party2.PubKey()
+		mpcEcdsaChainCode(t, party1, party2)
+
+		child1, childpub, err := party1.Derive(pub2, "m/0")
+		assert.Nil(t, err)
+		child2, _, err := party2.Derive(pub1, "m/0")
+		assert.Nil(t, err)
+
+		sealed1, err := child1.Seal(passphrase)
+		assert.Nil(t, err)
+		sealed2, err := child2.Seal(passphrase)
+		assert.Nil(t, err)
+		// The derivation state is part of the one state format.
+		assert.Equal(t, byte(0x01), sealed1[0])
+		unsealed1, err := UnsealEcdsaParty(sealed1, passphrase)
+		assert.Nil(t, err)
+		unsealed2, err := UnsealEcdsaParty(sealed2, passphrase)
+		assert.Nil(t, err)
+		assert.Equal(t, child1.ChainCode(), unsealed1.ChainCode())
+		assert.Equal(t, childpub, unsealed1.Phase1(pub2))
+
+		sig := mpcEcdsaSign(t, unsealed1, unsealed2, hash)
+		assert.Nil(t, EcdsaVerify(childpub, hash, sig))
+
+		// The unsealed child derives further.
+		_, want, err := party1.Derive(pub2, "m/0/1")
+		assert.Nil(t, err)
+		_, got, err := unsealed1.Derive(pub2, "m/1")
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+
+		// The chain code share and commitment of the unfinished agreement.
+		c1, _ := party1.ChainCodePhase1()
+		c2, _ := party2.ChainCodePhase1()
+		s1, err := party1.ChainCodePhase2(c2)
+		assert.Nil(t, err)
+		sealed1, err = party1.Seal(passphrase)
+		assert.Nil(t, err)
+		unsealed1, err = UnsealEcdsaParty(sealed1, passphrase)
+		assert.Nil(t, err)
+		s2, err := party2.ChainCodePhase2(c1)
+		assert.Nil(t, err)
+		_, err = unsealed1.ChainCodePhase3(Sha256(s2))
+		assert.EqualError(t, err, "mpc.chain.code.share[0].commitment.mismatch")
+		chain1, err := unsealed1.ChainCodePhase3(s2)
+		assert.Nil(t, err)
+		chain2, err := party2.ChainCodePhase3(s1)
+		assert.Nil(t, err)
+		assert.Equal(t, chain1, chain2)
+	}
+
+	// Schnorr.
+	{
+		hash := Sha256([]byte{0x01, 0x02, 0x03, 0x04})
+		party1, _ := NewSchnorrParty(PrvKeyFromBytes(p1.Bytes()))
+		defer party1.Close()
+		party2, _ := NewSchnorrParty(PrvKeyFromBytes(p2.Bytes()))
+		defer party2.Close()
+		pub1 := party1.PubKey()
+		pub2 := party2.PubKey()
+		mpcSchnorrChainCode(t, party1, party2)
+
+		child1, childpub, err := party1.Derive([]*PubKey{pub2}, "m/0")
+		assert.Nil(t, err)
+		child2, _, err := party2.Derive([]*PubKey{pub1}, "m/0")
+		assert.Nil(t, err)
+
+		sealed1, err := child1.Seal(passphrase)
+		assert.Nil(t, err)
+		sealed2, err := child2.Seal(passphrase)
+		assert.Nil(t, err)
+		unsealed1, err := UnsealSchnorrParty(sealed1, passphrase)
+		assert.Nil(t, err)
+		unsealed2, err := UnsealSchnorrParty(sealed2, passphrase)
+		assert.Nil(t, err)
+		assert.Equal(t, child1.ChainCode(), unsealed1.ChainCode())
+
+		r1 := unsealed1.Phase2(hash)
+		r2 := unsealed2.Phase2(hash)
+		shareR1 := unsealed1.Phase3(r2)
+		shareR2 := unsealed2.Phase3(r1)
+		sig1, err := unsealed1.Phase4(childpub, shareR1)
+		assert.Nil(t, err)
+		sig2, err := unsealed2.Phase4(childpub, shareR2)
+		assert.Nil(t, err)
+		sig, err := unsealed1.Phase5(shareR1, sig1, sig2)
+		assert.Nil(t, err)
+		assert.Nil(t, SchnorrVerify(childpub, hash, sig))
+
+		// The unsealed children derive further.
+		_, want, err := party1.Derive([]*PubKey{pub2}, "m/0/1")
+		assert.Nil(t, err)
+		_, got, err := unsealed1.Derive([]*PubKey{unsealed2.PubKey()}, "m/1")
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+	}
+}
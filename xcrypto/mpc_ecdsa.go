@@ -6,7 +6,9 @@
 package xcrypto
 
 import (
+	"crypto/rand"
 	"errors"
+	"io"
 	"math/big"
 
 	"crypto/elliptic"
@@ -22,15 +24,21 @@ const (
 
 // EcdsaParty -- ECDSA party struct.
 type EcdsaParty struct {
-	k      *big.Int
-	kinv   *big.Int
-	N      *big.Int
-	prv    *PrvKey
-	pub    *PubKey
-	hash   []byte
-	curve  elliptic.Curve
-	encpk  *big.Int
-	encprv *paillier.PrvKey
+	k       *big.Int
+	kinv    *big.Int
+	N       *big.Int
+	prv     *PrvKey
+	pub     *PubKey
+	hash    []byte
+	curve   elliptic.Curve
+	encpk   *big.Int
+	encprv  *paillier.PrvKey
+	refresh *mpcRefresh
+
+	chain        []byte
+	chainShare   []byte
+	chainCommits [][]byte
+	tweak        *big.Int
 }
 
 // NewEcdsaParty -- creates new EcdsaParty.
@@ -47,6 +55,11 @@ func NewEcdsaParty(prv *PrvKey) *EcdsaParty {
 	}
 }
 
+// PubKey -- returns the public key of this party.
+func (party *EcdsaParty) PubKey() *PubKey {
+	return party.pub
+}
+
 // Phase1 -- used to generate final pubkey of parties.
 // Return the shared PubKey.
 func (party *EcdsaParty) Phase1(pub2 *PubKey) *PubKey {
@@ -55,10 +68,17 @@ func (party *EcdsaParty) Phase1(pub2 *PubKey) *PubKey {
 	curve := pub.Curve
 
 	px, py := curve.ScalarMult(pub2.X, pub2.Y, prv.D.Bytes())
-	return &PubKey{X: px, Y: py, Curve: curve}
+	shared := &PubKey{X: px, Y: py, Curve: curve}
+
+	// Derived child: X + t*G.
+	if party.tweak != nil {
+		shared = shared.Add(pointBaseMult(party.tweak))
+	}
+	return shared
 }
 
 // Phase2 -- used to generate k, kinv, scalarR.
+// The nonce is fresh on every call, even for the same hash.
 // Return the party scalar R.
 func (party *EcdsaParty) Phase2(hash []byte) (*big.Int, *paillier.PubKey, *secp256k1.Scalar) {
 	N := party.N
@@ -81,8 +101,12 @@ func (party *EcdsaParty) Phase2(hash []byte) (*big.Int, *paillier.PubKey, *secp2
 	}
 	party.encpk = encpk
 
-	// RFC6979 K nonce.
-	k := xecdsa.NonceRFC6979(N, prv.D, hash)
+	// RFC6979 K nonce, hedged with fresh randomness.
+	data, err := mpcNonceData(hash, party.tweak)
+	if err != nil {
+		return nil, nil, nil
+	}
+	k := xecdsa.NonceRFC6979(N, prv.D, data)
 	kinv := new(big.Int).ModInverse(k, N)
 	party.k = k
 	party.kinv = kinv
@@ -91,6 +115,23 @@ func (party *EcdsaParty) Phase2(hash []byte) (*big.Int, *paillier.PubKey, *secp2
 	return encpk, encpub, secp256k1.NewScalar(rx, ry)
 }
 
+// mpcNonceData -- returns sha256(hash || aux || tweak) with 32 fresh random bytes as aux.
+// The nonce is derived from it instead of the hash alone: a party signing
+// the same hash twice against a different peer nonce, or as a derived child
+// sharing the key share of its parent, would otherwise reuse its own nonce
+// and leak the key share.
+func mpcNonceData(hash []byte, tweak *big.Int) ([]byte, error) {
+	aux := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, aux); err != nil {
+		return nil, err
+	}
+	data := append(append([]byte{}, hash...), aux...)
+	if tweak != nil {
+		data = append(data, mpcIntToBytes(tweak)...)
+	}
+	return Sha256(data), nil
+}
+
 // Phase3 -- set party2's r2 to this party.
 // Return the shared R.
 func (party *EcdsaParty) Phase3(r2 *secp256k1.Scalar) *secp256k1.Scalar {
@@ -116,6 +157,13 @@ func (party *EcdsaParty) Phase4(encpk2 *big.Int, encpub2 *paillier.PubKey, share
 	// s’=(z+r⋅e(pk2)⋅pk1)/k1
 	z := xecdsa.HashToInt(curve, hash)
 
+	// Derived child: z+r⋅t.
+	if party.tweak != nil {
+		rt := new(big.Int).Mul(shareR.X, party.tweak)
+		z.Add(z, rt)
+		z.Mod(z, party.N)
+	}
+
 	// e(pk2)⋅pk1
 	if ct, err = encpub2.MultPlaintext(encpk2, pk1); err != nil {
 		return nil, err
@@ -145,21 +193,21 @@ func (party *EcdsaParty) Phase5(shareR *secp256k1.Scalar, sign2 *big.Int) ([]byt
 	kinv := party.kinv
 	encprv := party.encprv
 
+	if err := encprv.PubKey().ValidCiphertext(sign2); err != nil {
+		return nil, err
+	}
 	sig, err := encprv.Decrypt(sign2)
 	if err != nil {
 		return nil, err
 	}
 	s := sig.Mul(sig, kinv).Mod(sig, N)
-	halfOrder := new(big.Int).Rsh(N, 1)
-	if s.Cmp(halfOrder) == 1 {
-		s.Sub(N, s)
-	}
 	if s.Sign() == 0 {
 		return nil, errors.New("calculated S is zero")
 	}
 	esig := NewSignatureEcdsa()
 	esig.R = shareR.X
 	esig.S = s
+	esig.NormalizeS()
 	return esig.Serialize()
 }
 
@@ -167,6 +215,8 @@ func (party *EcdsaParty) Phase5(shareR *secp256k1.Scalar, sign2 *big.Int) ([]byt
 func (party *EcdsaParty) Close() {
 	party.prv = nil
 	party.encprv = nil
+	party.refresh.close()
+	party.refresh = nil
 	if party.k != nil {
 		party.k.SetInt64(0)
 		party.kinv.SetInt64(0)
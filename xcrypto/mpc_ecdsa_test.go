@@ -65,6 +65,10 @@ func TestMpcEcdsa(t *testing.T) {
 		sharepub1.SerializeCompressed(),
 		fs1,
 		err == nil)
+
+	// The nonce is fresh for the same hash.
+	_, _, again := party1.Phase2(hash)
+	assert.NotEqual(t, scalarR1, again)
 }
 
 func BenchmarkMpcEcdsaKeyGen(b *testing.B) {
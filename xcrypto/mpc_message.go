@@ -0,0 +1,463 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/keyfuse/tokucore/xcrypto/paillier"
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+)
+
+const (
+	// MpcMessageVersion -- the wire format version of the mpc round messages.
+	MpcMessageVersion byte = 0x01
+)
+
+// MpcMessageType -- the type of the mpc round message.
+type MpcMessageType byte
+
+// Mpc round message types.
+const (
+	// MpcMsgPubKey -- the Phase1 input of all the parties.
+	MpcMsgPubKey MpcMessageType = 0x01
+	// MpcMsgEcdsaPhase2 -- the Phase2 output of EcdsaParty, EcdsaAlice and EcdsaBob.
+	MpcMsgEcdsaPhase2 MpcMessageType = 0x02
+	// MpcMsgEcdsaPhase4 -- the Phase4 output of EcdsaParty, EcdsaAlice and EcdsaBob.
+	MpcMsgEcdsaPhase4 MpcMessageType = 0x03
+	// MpcMsgSchnorrPhase2 -- the Phase2 output of SchnorrParty.
+	MpcMsgSchnorrPhase2 MpcMessageType = 0x04
+	// MpcMsgSchnorrPhase4 -- the Phase4 output of SchnorrParty.
+	MpcMsgSchnorrPhase4 MpcMessageType = 0x05
+	// MpcMsgScriptlessPhase5 -- the ScriptlessPhase5 output of EcdsaAlice and EcdsaBob.
+	MpcMsgScriptlessPhase5 MpcMessageType = 0x06
+	// MpcMsgCommit -- the commitment to a round message, sent before revealing it.
+	MpcMsgCommit MpcMessageType = 0x07
+)
+
+// MpcMessage -- the round message exchanged between the mpc parties.
+// The wire format is version(1 byte) || type(1 byte) || payload.
+// Decode is strict: unknown versions, type mismatches, non-canonical
+// integers, points not on the curve and trailing bytes are all rejected.
+type MpcMessage interface {
+	Type() MpcMessageType
+	Encode() []byte
+	Decode(data []byte) error
+}
+
+// DecodeMpcMessage -- decodes the data to the message of the type in header.
+func DecodeMpcMessage(data []byte) (MpcMessage, error) {
+	var msg MpcMessage
+
+	if len(data) < 2 {
+		return nil, fmt.Errorf("mpc.message.size[%v].too.short", len(data))
+	}
+	switch MpcMessageType(data[1]) {
+	case MpcMsgPubKey:
+		msg = &MpcPubKeyMsg{}
+	case MpcMsgEcdsaPhase2:
+		msg = &MpcEcdsaPhase2Msg{}
+	case MpcMsgEcdsaPhase4:
+		msg = &MpcEcdsaPhase4Msg{}
+	case MpcMsgSchnorrPhase2:
+		msg = &MpcSchnorrPhase2Msg{}
+	case MpcMsgSchnorrPhase4:
+		msg = &MpcSchnorrPhase4Msg{}
+	case MpcMsgScriptlessPhase5:
+		msg = &MpcScriptlessPhase5Msg{}
+	case MpcMsgCommit:
+		msg = &MpcCommitMsg{}
+	default:
+		return nil, fmt.Errorf("mpc.message.type[%v].unknown", data[1])
+	}
+	if err := msg.Decode(data); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// MpcPubKeyMsg -- the party public key sent for Phase1.
+type MpcPubKeyMsg struct {
+	PubKey *PubKey
+}
+
+// NewMpcPubKeyMsg -- creates new MpcPubKeyMsg.
+func NewMpcPubKeyMsg(pub *PubKey) *MpcPubKeyMsg {
+	return &MpcPubKeyMsg{PubKey: pub}
+}
+
+// Type -- returns the message type.
+func (m *MpcPubKeyMsg) Type() MpcMessageType {
+	return MpcMsgPubKey
+}
+
+// Encode -- encodes the message to the wire format.
+func (m *MpcPubKeyMsg) Encode() []byte {
+	buffer := mpcMessageHeader(m.Type())
+	buffer.WriteBytes(m.PubKey.SerializeCompressed())
+	return buffer.Bytes()
+}
+
+// Decode -- decodes the message from the wire format.
+func (m *MpcPubKeyMsg) Decode(data []byte) error {
+	buffer, err := mpcMessageReader(data, m.Type())
+	if err != nil {
+		return err
+	}
+	pub, err := mpcReadPoint(buffer)
+	if err != nil {
+		return err
+	}
+	if err := mpcReadEnd(buffer); err != nil {
+		return err
+	}
+	m.PubKey = pub
+	return nil
+}
+
+// MpcEcdsaPhase2Msg -- the ECDSA Phase2 output.
+type MpcEcdsaPhase2Msg struct {
+	EncPK  *big.Int
+	EncPub *paillier.PubKey
+	R      *secp256k1.Scalar
+}
+
+// NewMpcEcdsaPhase2Msg -- creates new MpcEcdsaPhase2Msg.
+func NewMpcEcdsaPhase2Msg(encpk *big.Int, encpub *paillier.PubKey, r *secp256k1.Scalar) *MpcEcdsaPhase2Msg {
+	return &MpcEcdsaPhase2Msg{
+		EncPK:  encpk,
+		EncPub: encpub,
+		R:      r,
+	}
+}
+
+// Type -- returns the message type.
+func (m *MpcEcdsaPhase2Msg) Type() MpcMessageType {
+	return MpcMsgEcdsaPhase2
+}
+
+// Encode -- encodes the message to the wire format.
+func (m *MpcEcdsaPhase2Msg) Encode() []byte {
+	buffer := mpcMessageHeader(m.Type())
+	buffer.WriteVarBytes(m.EncPub.Serialize())
+	buffer.WriteVarBytes(m.EncPK.Bytes())
+	buffer.WriteBytes(mpcScalarToBytes(m.R))
+	return buffer.Bytes()
+}
+
+// Decode -- decodes the message from the wire format.
+func (m *MpcEcdsaPhase2Msg) Decode(data []byte) error {
+	buffer, err := mpcMessageReader(data, m.Type())
+	if err != nil {
+		return err
+	}
+	nbytes, err := buffer.ReadVarBytes()
+	if err != nil {
+		return err
+	}
+	encpub, err := paillier.PubKeyFromBytes(nbytes)
+	if err != nil {
+		return err
+	}
+	encpk, err := mpcReadInt(buffer)
+	if err != nil {
+		return err
+	}
+	if err := encpub.ValidCiphertext(encpk); err != nil {
+		return err
+	}
+	r, err := mpcReadPoint(buffer)
+	if err != nil {
+		return err
+	}
+	if err := mpcReadEnd(buffer); err != nil {
+		return err
+	}
+	m.EncPK = encpk
+	m.EncPub = encpub
+	m.R = secp256k1.NewScalar(r.X, r.Y)
+	return nil
+}
+
+// MpcEcdsaPhase4Msg -- the ECDSA Phase4 homomorphic ciphertext.
+type MpcEcdsaPhase4Msg struct {
+	Cipher *big.Int
+}
+
+// NewMpcEcdsaPhase4Msg -- creates new MpcEcdsaPhase4Msg.
+func NewMpcEcdsaPhase4Msg(cipher *big.Int) *MpcEcdsaPhase4Msg {
+	return &MpcEcdsaPhase4Msg{Cipher: cipher}
+}
+
+// Type -- returns the message type.
+func (m *MpcEcdsaPhase4Msg) Type() MpcMessageType {
+	return MpcMsgEcdsaPhase4
+}
+
+// Encode -- encodes the message to the wire format.
+func (m *MpcEcdsaPhase4Msg) Encode() []byte {
+	buffer := mpcMessageHeader(m.Type())
+	buffer.WriteVarBytes(m.Cipher.Bytes())
+	return buffer.Bytes()
+}
+
+// Decode -- decodes the message from the wire format.
+// The ciphertext range is checked by the receiver against its own paillier key in Phase5.
+func (m *MpcEcdsaPhase4Msg) Decode(data []byte) error {
+	buffer, err := mpcMessageReader(data, m.Type())
+	if err != nil {
+		return err
+	}
+	cipher, err := mpcReadInt(buffer)
+	if err != nil {
+		return err
+	}
+	if err := mpcReadEnd(buffer); err != nil {
+		return err
+	}
+	m.Cipher = cipher
+	return nil
+}
+
+// MpcSchnorrPhase2Msg -- the Schnorr Phase2 output.
+type MpcSchnorrPhase2Msg struct {
+	R *secp256k1.Scalar
+}
+
+// NewMpcSchnorrPhase2Msg -- creates new MpcSchnorrPhase2Msg.
+func NewMpcSchnorrPhase2Msg(r *secp256k1.Scalar) *MpcSchnorrPhase2Msg {
+	return &MpcSchnorrPhase2Msg{R: r}
+}
+
+// Type -- returns the message type.
+func (m *MpcSchnorrPhase2Msg) Type() MpcMessageType {
+	return MpcMsgSchnorrPhase2
+}
+
+// Encode -- encodes the message to the wire format.
+func (m *MpcSchnorrPhase2Msg) Encode() []byte {
+	buffer := mpcMessageHeader(m.Type())
+	buffer.WriteBytes(mpcScalarToBytes(m.R))
+	return buffer.Bytes()
+}
+
+// Decode -- decodes the message from the wire format.
+func (m *MpcSchnorrPhase2Msg) Decode(data []byte) error {
+	buffer, err := mpcMessageReader(data, m.Type())
+	if err != nil {
+		return err
+	}
+	r, err := mpcReadPoint(buffer)
+	if err != nil {
+		return err
+	}
+	if err := mpcReadEnd(buffer); err != nil {
+		return err
+	}
+	m.R = secp256k1.NewScalar(r.X, r.Y)
+	return nil
+}
+
+// MpcSchnorrPhase4Msg -- the Schnorr Phase4 partial signature.
+type MpcSchnorrPhase4Msg struct {
+	Sig []byte
+}
+
+// NewMpcSchnorrPhase4Msg -- creates new MpcSchnorrPhase4Msg.
+func NewMpcSchnorrPhase4Msg(sig []byte) *MpcSchnorrPhase4Msg {
+	return &MpcSchnorrPhase4Msg{Sig: sig}
+}
+
+// Type -- returns the message type.
+func (m *MpcSchnorrPhase4Msg) Type() MpcMessageType {
+	return MpcMsgSchnorrPhase4
+}
+
+// Encode -- encodes the message to the wire format.
+func (m *MpcSchnorrPhase4Msg) Encode() []byte {
+	buffer := mpcMessageHeader(m.Type())
+	buffer.WriteBytes(m.Sig)
+	return buffer.Bytes()
+}
+
+// Decode -- decodes the message from the wire format.
+func (m *MpcSchnorrPhase4Msg) Decode(data []byte) error {
+	buffer, err := mpcMessageReader(data, m.Type())
+	if err != nil {
+		return err
+	}
+	s, err := mpcReadModN(buffer)
+	if err != nil {
+		return err
+	}
+	if err := mpcReadEnd(buffer); err != nil {
+		return err
+	}
+	m.Sig = mpcIntToBytes(s)
+	return nil
+}
+
+// MpcScriptlessPhase5Msg -- the scriptless ECDSA partial signature.
+type MpcScriptlessPhase5Msg struct {
+	Sig *big.Int
+}
+
+// NewMpcScriptlessPhase5Msg -- creates new MpcScriptlessPhase5Msg.
+func NewMpcScriptlessPhase5Msg(sig *big.Int) *MpcScriptlessPhase5Msg {
+	return &MpcScriptlessPhase5Msg{Sig: sig}
+}
+
+// Type -- returns the message type.
+func (m *MpcScriptlessPhase5Msg) Type() MpcMessageType {
+	return MpcMsgScriptlessPhase5
+}
+
+// Encode -- encodes the message to the wire format.
+func (m *MpcScriptlessPhase5Msg) Encode() []byte {
+	buffer := mpcMessageHeader(m.Type())
+	buffer.WriteBytes(mpcIntToBytes(m.Sig))
+	return buffer.Bytes()
+}
+
+// Decode -- decodes the message from the wire format.
+func (m *MpcScriptlessPhase5Msg) Decode(data []byte) error {
+	buffer, err := mpcMessageReader(data, m.Type())
+	if err != nil {
+		return err
+	}
+	s, err := mpcReadModN(buffer)
+	if err != nil {
+		return err
+	}
+	if err := mpcReadEnd(buffer); err != nil {
+		return err
+	}
+	m.Sig = s
+	return nil
+}
+
+// MpcCommitMsg -- the 32-byte commitment to a round message.
+type MpcCommitMsg struct {
+	Commitment []byte
+}
+
+// NewMpcCommitMsg -- creates new MpcCommitMsg.
+func NewMpcCommitMsg(commitment []byte) *MpcCommitMsg {
+	return &MpcCommitMsg{Commitment: commitment}
+}
+
+// Type -- returns the message type.
+func (m *MpcCommitMsg) Type() MpcMessageType {
+	return MpcMsgCommit
+}
+
+// Encode -- encodes the message to the wire format.
+func (m *MpcCommitMsg) Encode() []byte {
+	buffer := mpcMessageHeader(m.Type())
+	buffer.WriteBytes(m.Commitment)
+	return buffer.Bytes()
+}
+
+// Decode -- decodes the message from the wire format.
+func (m *MpcCommitMsg) Decode(data []byte) error {
+	buffer, err := mpcMessageReader(data, m.Type())
+	if err != nil {
+		return err
+	}
+	commitment, err := buffer.ReadBytes(32)
+	if err != nil {
+		return err
+	}
+	if err := mpcReadEnd(buffer); err != nil {
+		return err
+	}
+	m.Commitment = commitment
+	return nil
+}
+
+// mpcMessageHeader -- returns a buffer with the version and type written.
+func mpcMessageHeader(typ MpcMessageType) *xbase.Buffer {
+	buffer := xbase.NewBuffer()
+	buffer.WriteU8(MpcMessageVersion)
+	buffer.WriteU8(byte(typ))
+	return buffer
+}
+
+// mpcMessageReader -- checks the header and returns the payload reader.
+func mpcMessageReader(data []byte, typ MpcMessageType) (*xbase.Buffer, error) {
+	buffer := xbase.NewBufferReader(data)
+	version, err := buffer.ReadU8()
+	if err != nil {
+		return nil, err
+	}
+	if version != MpcMessageVersion {
+		return nil, fmt.Errorf("mpc.message.version[%v].unsupported", version)
+	}
+	got, err := buffer.ReadU8()
+	if err != nil {
+		return nil, err
+	}
+	if MpcMessageType(got) != typ {
+		return nil, fmt.Errorf("mpc.message.type.mismatch.want[%v].got[%v]", typ, got)
+	}
+	return buffer, nil
+}
+
+// mpcReadEnd -- returns error if there are trailing bytes.
+func mpcReadEnd(buffer *xbase.Buffer) error {
+	if !buffer.End() {
+		return fmt.Errorf("mpc.message.trailing.bytes[%v]", len(buffer.Remaining()))
+	}
+	return nil
+}
+
+// mpcReadPoint -- reads a 33-byte compressed point, checking it is on the curve.
+func mpcReadPoint(buffer *xbase.Buffer) (*PubKey, error) {
+	b, err := buffer.ReadBytes(pubKeyBytesLenCompressed)
+	if err != nil {
+		return nil, err
+	}
+	return PubKeyFromBytes(b)
+}
+
+// mpcReadInt -- reads a positive var-length integer without leading zero bytes.
+func mpcReadInt(buffer *xbase.Buffer) (*big.Int, error) {
+	b, err := buffer.ReadVarBytes()
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 || b[0] == 0x00 {
+		return nil, fmt.Errorf("mpc.message.integer.not.canonical")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// mpcReadModN -- reads a 32-byte integer in the range [1, N-1].
+func mpcReadModN(buffer *xbase.Buffer) (*big.Int, error) {
+	b, err := buffer.ReadBytes(32)
+	if err != nil {
+		return nil, err
+	}
+	v := new(big.Int).SetBytes(b)
+	if v.Sign() == 0 || v.Cmp(secp256k1.SECP256K1().Params().N) >= 0 {
+		return nil, fmt.Errorf("mpc.message.scalar.out.of.range")
+	}
+	return v, nil
+}
+
+// mpcScalarToBytes -- returns the compressed encoding of the point.
+func mpcScalarToBytes(s *secp256k1.Scalar) []byte {
+	return secp256k1.SecMarshal(secp256k1.SECP256K1(), s.X, s.Y)
+}
+
+// mpcIntToBytes -- returns the 32-byte big-endian encoding.
+func mpcIntToBytes(v *big.Int) []byte {
+	b1, b2 := make([]byte, 32), v.Bytes()
+	copy(b1[32-len(b2):], b2)
+	return b1
+}
@@ -0,0 +1,160 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMpcMessageEcdsa(t *testing.T) {
+	hash := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+
+	// Party 1.
+	p1, _ := new(big.Int).SetString("15bafcb56279dbfd985d4d17cdaf9bbfc6701b628f9fb00d6d1e0d2cb503ede3", 16)
+	prv1 := PrvKeyFromBytes(p1.Bytes())
+	party1 := NewEcdsaParty(prv1)
+	defer party1.Close()
+
+	// Party 2.
+	p2, _ := new(big.Int).SetString("76818c328b8aa1e8f17bd599016fef8134b7d5ec315e0b6373953da7e8b5c0c9", 16)
+	prv2 := PrvKeyFromBytes(p2.Bytes())
+	party2 := NewEcdsaParty(prv2)
+	defer party2.Close()
+
+	// Phase 1.
+	m11, err := DecodeMpcMessage(NewMpcPubKeyMsg(prv1.PubKey()).Encode())
+	assert.Nil(t, err)
+	m12, err := DecodeMpcMessage(NewMpcPubKeyMsg(prv2.PubKey()).Encode())
+	assert.Nil(t, err)
+	sharepub1 := party1.Phase1(m12.(*MpcPubKeyMsg).PubKey)
+	sharepub2 := party2.Phase1(m11.(*MpcPubKeyMsg).PubKey)
+	assert.Equal(t, sharepub1, sharepub2)
+
+	// Phase 2.
+	encpk1, encpub1, scalarR1 := party1.Phase2(hash)
+	encpk2, encpub2, scalarR2 := party2.Phase2(hash)
+	m21 := &MpcEcdsaPhase2Msg{}
+	assert.Nil(t, m21.Decode(NewMpcEcdsaPhase2Msg(encpk1, encpub1, scalarR1).Encode()))
+	m22 := &MpcEcdsaPhase2Msg{}
+	assert.Nil(t, m22.Decode(NewMpcEcdsaPhase2Msg(encpk2, encpub2, scalarR2).Encode()))
+	assert.Equal(t, encpub1, m21.EncPub)
+	assert.Equal(t, scalarR2, m22.R)
+
+	// Phase 3.
+	shareR1 := party1.Phase3(m22.R)
+	shareR2 := party2.Phase3(m21.R)
+	assert.Equal(t, shareR1, shareR2)
+
+	// Phase 4.
+	sig1, err := party1.Phase4(m22.EncPK, m22.EncPub, shareR1)
+	assert.Nil(t, err)
+	sig2, err := party2.Phase4(m21.EncPK, m21.EncPub, shareR2)
+	assert.Nil(t, err)
+	m41 := &MpcEcdsaPhase4Msg{}
+	assert.Nil(t, m41.Decode(NewMpcEcdsaPhase4Msg(sig1).Encode()))
+	m42 := &MpcEcdsaPhase4Msg{}
+	assert.Nil(t, m42.Decode(NewMpcEcdsaPhase4Msg(sig2).Encode()))
+
+	// Phase 5.
+	fs1, err := party1.Phase5(shareR1, m42.Cipher)
+	assert.Nil(t, err)
+	fs2, err := party2.Phase5(shareR2, m41.Cipher)
+	assert.Nil(t, err)
+	assert.Equal(t, fs1, fs2)
+	assert.Nil(t, EcdsaVerify(sharepub1, hash, fs1))
+
+	// Cipher out of the paillier range.
+	_, err = party1.Phase5(shareR1, encpub1.NN)
+	assert.NotNil(t, err)
+}
+
+func TestMpcMessageSchnorr(t *testing.T) {
+	hash := Sha256([]byte{0x01, 0x02, 0x03, 0x04})
+
+	p1, _ := new(big.Int).SetString("15bafcb56279dbfd985d4d17cdaf9bbfc6701b628f9fb00d6d1e0d2cb503ede3", 16)
+	party1, _ := NewSchnorrParty(PrvKeyFromBytes(p1.Bytes()))
+	p2, _ := new(big.Int).SetString("76818c328b8aa1e8f17bd599016fef8134b7d5ec315e0b6373953da7e8b5c0c9", 16)
+	party2, _ := NewSchnorrParty(PrvKeyFromBytes(p2.Bytes()))
+
+	sharepub := party1.Phase1(party2.pub)
+	r1 := party1.Phase2(hash)
+	r2 := party2.Phase2(hash)
+
+	m21, err := DecodeMpcMessage(NewMpcSchnorrPhase2Msg(r1).Encode())
+	assert.Nil(t, err)
+	m22, err := DecodeMpcMessage(NewMpcSchnorrPhase2Msg(r2).Encode())
+	assert.Nil(t, err)
+	shareR1 := party1.Phase3(m22.(*MpcSchnorrPhase2Msg).R)
+	shareR2 := party2.Phase3(m21.(*MpcSchnorrPhase2Msg).R)
+	assert.Equal(t, shareR1, shareR2)
+
+	sig1, err := party1.Phase4(sharepub, shareR1)
+	assert.Nil(t, err)
+	sig2, err := party2.Phase4(sharepub, shareR2)
+	assert.Nil(t, err)
+	m41, err := DecodeMpcMessage(NewMpcSchnorrPhase4Msg(sig1).Encode())
+	assert.Nil(t, err)
+	m42, err := DecodeMpcMessage(NewMpcSchnorrPhase4Msg(sig2).Encode())
+	assert.Nil(t, err)
+
+	fs, err := party1.Phase5(shareR1, m41.(*MpcSchnorrPhase4Msg).Sig, m42.(*MpcSchnorrPhase4Msg).Sig)
+	assert.Nil(t, err)
+	assert.Nil(t, SchnorrVerify(sharepub, hash, fs))
+
+	// Scriptless partial signature.
+	m5, err := DecodeMpcMessage(NewMpcScriptlessPhase5Msg(big.NewInt(2019)).Encode())
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(2019), m5.(*MpcScriptlessPhase5Msg).Sig)
+
+	// Commitment.
+	m6, err := DecodeMpcMessage(NewMpcCommitMsg(hash).Encode())
+	assert.Nil(t, err)
+	assert.Equal(t, hash, m6.(*MpcCommitMsg).Commitment)
+}
+
+func TestMpcMessageError(t *testing.T) {
+	prv := PrvKeyFromBytes([]byte{0x01})
+	good := NewMpcPubKeyMsg(prv.PubKey()).Encode()
+	N := prv.Curve.Params().N
+
+	// Point not on the curve: x=5 has no y on secp256k1.
+	offCurve := make([]byte, len(good))
+	copy(offCurve, good)
+	for i := 3; i < len(offCurve); i++ {
+		offCurve[i] = 0
+	}
+	offCurve[len(offCurve)-1] = 0x05
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"short", []byte{MpcMessageVersion}},
+		{"version", append([]byte{0x02}, good[1:]...)},
+		{"type.unknown", []byte{MpcMessageVersion, 0xff}},
+		{"truncated", good[:len(good)-1]},
+		{"trailing", append(append([]byte{}, good...), 0x00)},
+		{"offcurve", offCurve},
+		{"cipher.zero", []byte{MpcMessageVersion, byte(MpcMsgEcdsaPhase4), 0x00}},
+		{"cipher.padded", []byte{MpcMessageVersion, byte(MpcMsgEcdsaPhase4), 0x02, 0x00, 0x01}},
+		{"scalar.zero", append([]byte{MpcMessageVersion, byte(MpcMsgSchnorrPhase4)}, make([]byte, 32)...)},
+		{"scalar.order", append([]byte{MpcMessageVersion, byte(MpcMsgScriptlessPhase5)}, mpcIntToBytes(N)...)},
+		{"paillier.short", []byte{MpcMessageVersion, byte(MpcMsgEcdsaPhase2), 0x01, 0x03}},
+		{"commit.short", append([]byte{MpcMessageVersion, byte(MpcMsgCommit)}, make([]byte, 31)...)},
+	}
+	for _, test := range tests {
+		_, err := DecodeMpcMessage(test.data)
+		assert.NotNil(t, err, test.name)
+	}
+
+	// Type mismatch.
+	msg := &MpcSchnorrPhase2Msg{}
+	assert.NotNil(t, msg.Decode(good))
+}
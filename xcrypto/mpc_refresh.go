@@ -0,0 +1,218 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// The refresh rotates the key shares of the EcdsaParty and SchnorrParty
+// without changing the shared PubKey.
+//
+// Every party sends an ephemeral point Ai = ai*G, each pair derives the
+// mask rij = sha256(ai*Aj) mod N which is unknown to anyone else, the party
+// with the smaller compressed public key takes rij and the other its opposite:
+// the ECDSA shares are multiplied by rij and rij^-1, the Schnorr shares add
+// rij and -rij. The new public shares are exchanged and checked against the
+// shared PubKey, after that the old shares do not match the new ones.
+
+// mpcRefresh -- the in-flight refresh state.
+type mpcRefresh struct {
+	a     *big.Int
+	A     *PubKey
+	prv   *PrvKey
+	peers []*PubKey
+	want  []*PubKey
+}
+
+func (refresh *mpcRefresh) close() {
+	if refresh == nil {
+		return
+	}
+	refresh.a.SetInt64(0)
+	refresh.prv = nil
+}
+
+// newMpcRefresh -- returns the refresh state with a random ephemeral key.
+func newMpcRefresh() (*mpcRefresh, error) {
+	a, err := randModN()
+	if err != nil {
+		return nil, err
+	}
+	return &mpcRefresh{a: a, A: pointBaseMult(a)}, nil
+}
+
+// mask -- returns the pairwise mask and whether this party takes it as is.
+func (refresh *mpcRefresh) mask(pub *PubKey, pub2 *PubKey, a2 *PubKey) (*big.Int, bool, error) {
+	N := pub.Curve.Params().N
+	if a2 == nil || !pub.Curve.IsOnCurve(a2.X, a2.Y) || pointEqual(a2, refresh.A) {
+		return nil, false, fmt.Errorf("mpc.refresh.point.invalid")
+	}
+	order := bytes.Compare(pub.SerializeCompressed(), pub2.SerializeCompressed())
+	if order == 0 {
+		return nil, false, fmt.Errorf("mpc.refresh.pubkey.duplicate")
+	}
+
+	shared := pointMult(a2, refresh.a)
+	h := sha256.New()
+	h.Write([]byte("mpc.refresh"))
+	h.Write(shared.SerializeCompressed())
+	r := new(big.Int).SetBytes(h.Sum(nil))
+	r.Mod(r, N)
+	if r.Sign() == 0 {
+		return nil, false, fmt.Errorf("mpc.refresh.mask.zero")
+	}
+	return r, order < 0, nil
+}
+
+// RefreshPhase1 -- starts the refresh of the key share.
+// Return the ephemeral point to send to the other party.
+func (party *EcdsaParty) RefreshPhase1() (*PubKey, error) {
+	if party.prv == nil {
+		return nil, fmt.Errorf("mpc.party.closed")
+	}
+	refresh, err := newMpcRefresh()
+	if err != nil {
+		return nil, err
+	}
+	party.refresh.close()
+	party.refresh = refresh
+	return refresh.A, nil
+}
+
+// RefreshPhase2 -- derives the new key share from the other party public key and ephemeral point.
+// Return the new public key of this party.
+func (party *EcdsaParty) RefreshPhase2(pub2 *PubKey, a2 *PubKey) (*PubKey, error) {
+	N := party.N
+	refresh := party.refresh
+	if refresh == nil {
+		return nil, fmt.Errorf("mpc.refresh.phase1.missing")
+	}
+	r, lower, err := refresh.mask(party.pub, pub2, a2)
+	if err != nil {
+		return nil, err
+	}
+	r2 := new(big.Int).ModInverse(r, N)
+	if !lower {
+		r, r2 = r2, r
+	}
+
+	// x1' = x1*r and x2' = x2*r^-1.
+	d := new(big.Int).Mul(party.prv.D, r)
+	d.Mod(d, N)
+	refresh.prv = PrvKeyFromBytes(d.Bytes())
+	refresh.peers = []*PubKey{pub2}
+	refresh.want = []*PubKey{pointMult(pub2, r2)}
+	return refresh.prv.PubKey(), nil
+}
+
+// RefreshPhase3 -- checks the new public key of the other party and switches to the new key share.
+// Return the shared PubKey, which is not changed.
+func (party *EcdsaParty) RefreshPhase3(newpub2 *PubKey) (*PubKey, error) {
+	refresh := party.refresh
+	if refresh == nil || refresh.prv == nil {
+		return nil, fmt.Errorf("mpc.refresh.phase2.missing")
+	}
+	if newpub2 == nil || !pointEqual(newpub2, refresh.want[0]) {
+		return nil, fmt.Errorf("mpc.refresh.pubkey.mismatch")
+	}
+	old := party.Phase1(refresh.peers[0])
+
+	party.prv = refresh.prv
+	party.pub = refresh.prv.PubKey()
+	refresh.prv = nil
+	refresh.close()
+	party.refresh = nil
+
+	shared := party.Phase1(newpub2)
+	if !pointEqual(old, shared) {
+		return nil, fmt.Errorf("mpc.refresh.shared.pubkey.mismatch")
+	}
+	return shared, nil
+}
+
+// RefreshPhase1 -- starts the refresh of the key share.
+// Return the ephemeral point to broadcast to the other parties.
+func (party *SchnorrParty) RefreshPhase1() (*PubKey, error) {
+	if party.prv == nil {
+		return nil, fmt.Errorf("mpc.party.closed")
+	}
+	refresh, err := newMpcRefresh()
+	if err != nil {
+		return nil, err
+	}
+	party.refresh.close()
+	party.refresh = refresh
+	return refresh.A, nil
+}
+
+// RefreshPhase2 -- derives the new key share from the public keys and ephemeral points of the others, in the same order.
+// Return the new public key of this party.
+func (party *SchnorrParty) RefreshPhase2(pubs []*PubKey, as []*PubKey) (*PubKey, error) {
+	N := party.N
+	refresh := party.refresh
+	if refresh == nil {
+		return nil, fmt.Errorf("mpc.refresh.phase1.missing")
+	}
+	if len(pubs) == 0 || len(pubs) != len(as) {
+		return nil, fmt.Errorf("mpc.refresh.peers.size.mismatch")
+	}
+
+	// xi' = xi + sum(±rij), the masks cancel out in the sum of the shares.
+	d := new(big.Int).Set(party.prv.D)
+	for i := range pubs {
+		r, lower, err := refresh.mask(party.pub, pubs[i], as[i])
+		if err != nil {
+			return nil, err
+		}
+		if lower {
+			d.Add(d, r)
+		} else {
+			d.Sub(d, r)
+		}
+	}
+	d.Mod(d, N)
+	if d.Sign() == 0 {
+		return nil, fmt.Errorf("mpc.refresh.share.zero")
+	}
+	refresh.prv = PrvKeyFromBytes(d.Bytes())
+	refresh.peers = pubs
+	return refresh.prv.PubKey(), nil
+}
+
+// RefreshPhase3 -- checks the new public keys of the others sum to the shared PubKey and switches to the new key share.
+// Return the shared PubKey, which is not changed.
+func (party *SchnorrParty) RefreshPhase3(newpubs []*PubKey) (*PubKey, error) {
+	refresh := party.refresh
+	if refresh == nil || refresh.prv == nil {
+		return nil, fmt.Errorf("mpc.refresh.phase2.missing")
+	}
+	if len(newpubs) != len(refresh.peers) {
+		return nil, fmt.Errorf("mpc.refresh.peers.size.mismatch")
+	}
+	old := party.pub
+	shared := refresh.prv.PubKey()
+	for i := range newpubs {
+		if newpubs[i] == nil || !party.curve.IsOnCurve(newpubs[i].X, newpubs[i].Y) {
+			return nil, fmt.Errorf("mpc.refresh.point.invalid")
+		}
+		old = old.Add(refresh.peers[i])
+		shared = shared.Add(newpubs[i])
+	}
+	if !pointEqual(old, shared) {
+		return nil, fmt.Errorf("mpc.refresh.pubkey.mismatch")
+	}
+
+	party.prv = refresh.prv
+	party.pub = refresh.prv.PubKey()
+	refresh.prv = nil
+	refresh.close()
+	party.refresh = nil
+	return shared, nil
+}
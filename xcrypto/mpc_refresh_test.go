@@ -0,0 +1,159 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mpcEcdsaSign(t *testing.T, party1 *EcdsaParty, party2 *EcdsaParty, hash []byte) []byte {
+	encpk1, encpub1, scalarR1 := party1.Phase2(hash)
+	encpk2, encpub2, scalarR2 := party2.Phase2(hash)
+	shareR1 := party1.Phase3(scalarR2)
+	shareR2 := party2.Phase3(scalarR1)
+	sig1, err := party1.Phase4(encpk2, encpub2, shareR1)
+	assert.Nil(t, err)
+	sig2, err := party2.Phase4(encpk1, encpub1, shareR2)
+	assert.Nil(t, err)
+	fs1, err := party1.Phase5(shareR1, sig2)
+	assert.Nil(t, err)
+	fs2, err := party2.Phase5(shareR2, sig1)
+	assert.Nil(t, err)
+	assert.Equal(t, fs1, fs2)
+	return fs1
+}
+
+func TestMpcEcdsaRefresh(t *testing.T) {
+	hash := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+	p1, _ := new(big.Int).SetString("15bafcb56279dbfd985d4d17cdaf9bbfc6701b628f9fb00d6d1e0d2cb503ede3", 16)
+	p2, _ := new(big.Int).SetString("76818c328b8aa1e8f17bd599016fef8134b7d5ec315e0b6373953da7e8b5c0c9", 16)
+	party1 := NewEcdsaParty(PrvKeyFromBytes(p1.Bytes()))
+	defer party1.Close()
+	party2 := NewEcdsaParty(PrvKeyFromBytes(p2.Bytes()))
+	defer party2.Close()
+	pub1 := party1.PubKey()
+	pub2 := party2.PubKey()
+	sharepub := party1.Phase1(pub2)
+
+	// Phase 1.
+	a1, err := party1.RefreshPhase1()
+	assert.Nil(t, err)
+	a2, err := party2.RefreshPhase1()
+	assert.Nil(t, err)
+
+	// Phase 2.
+	newpub1, err := party1.RefreshPhase2(pub2, a2)
+	assert.Nil(t, err)
+	newpub2, err := party2.RefreshPhase2(pub1, a1)
+	assert.Nil(t, err)
+	assert.NotEqual(t, pub1, newpub1)
+	assert.NotEqual(t, pub2, newpub2)
+
+	// Phase 3.
+	_, err = party1.RefreshPhase3(pub2)
+	assert.EqualError(t, err, "mpc.refresh.pubkey.mismatch")
+	shared1, err := party1.RefreshPhase3(newpub2)
+	assert.Nil(t, err)
+	shared2, err := party2.RefreshPhase3(newpub1)
+	assert.Nil(t, err)
+	assert.Equal(t, sharepub, shared1)
+	assert.Equal(t, sharepub, shared2)
+	assert.Equal(t, newpub1, party1.PubKey())
+
+	// Sign with the new shares.
+	sig := mpcEcdsaSign(t, party1, party2, hash)
+	assert.Nil(t, EcdsaVerify(sharepub, hash, sig))
+
+	// The old share does not work with the new one.
+	old1 := NewEcdsaParty(PrvKeyFromBytes(p1.Bytes()))
+	defer old1.Close()
+	assert.NotEqual(t, sharepub, old1.Phase1(newpub2))
+
+	// Phase order.
+	_, err = party1.RefreshPhase2(pub2, a2)
+	assert.NotNil(t, err)
+	_, err = party1.RefreshPhase3(newpub2)
+	assert.NotNil(t, err)
+	_, err = party1.RefreshPhase1()
+	assert.Nil(t, err)
+	_, err = party1.RefreshPhase2(pub2, nil)
+	assert.NotNil(t, err)
+	_, err = party1.RefreshPhase2(party1.PubKey(), a2)
+	assert.NotNil(t, err)
+}
+
+func TestMpcSchnorrRefresh(t *testing.T) {
+	hash := Sha256([]byte{0x01, 0x02, 0x03, 0x04})
+	keys := []string{
+		"15bafcb56279dbfd985d4d17cdaf9bbfc6701b628f9fb00d6d1e0d2cb503ede3",
+		"76818c328b8aa1e8f17bd599016fef8134b7d5ec315e0b6373953da7e8b5c0c9",
+		"3b5aa1fe9b6b5a1c5e51b7c2a6bfcad72b8fd3e0b2e4f5cdbf5ab44c1d8dd7e5",
+	}
+	var parties []*SchnorrParty
+	var pubs []*PubKey
+	for _, key := range keys {
+		d, _ := new(big.Int).SetString(key, 16)
+		party, err := NewSchnorrParty(PrvKeyFromBytes(d.Bytes()))
+		assert.Nil(t, err)
+		defer party.Close()
+		parties = append(parties, party)
+		pubs = append(pubs, party.PubKey())
+	}
+	sharepub := pubs[0].Add(pubs[1]).Add(pubs[2])
+	others := func(vs []*PubKey, i int) []*PubKey {
+		var out []*PubKey
+		for j, v := range vs {
+			if j != i {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+
+	// Phase 1.
+	var as []*PubKey
+	for _, party := range parties {
+		a, err := party.RefreshPhase1()
+		assert.Nil(t, err)
+		as = append(as, a)
+	}
+
+	// Phase 2.
+	var newpubs []*PubKey
+	for i, party := range parties {
+		newpub, err := party.RefreshPhase2(others(pubs, i), others(as, i))
+		assert.Nil(t, err)
+		newpubs = append(newpubs, newpub)
+	}
+
+	// Phase 3.
+	_, err := parties[0].RefreshPhase3(others(pubs, 0))
+	assert.EqualError(t, err, "mpc.refresh.pubkey.mismatch")
+	for i, party := range parties {
+		shared, err := party.RefreshPhase3(others(newpubs, i))
+		assert.Nil(t, err)
+		assert.Equal(t, sharepub, shared)
+		assert.NotEqual(t, pubs[i], party.PubKey())
+	}
+
+	// Sign with the new shares.
+	parties[0].Phase2(hash)
+	r1 := parties[1].Phase2(hash)
+	r2 := parties[2].Phase2(hash)
+	shareR := parties[0].Phase3(r1).Add(sharepub.Curve, r2)
+	var sigs [][]byte
+	for _, party := range parties {
+		sig, err := party.Phase4(sharepub, shareR)
+		assert.Nil(t, err)
+		sigs = append(sigs, sig)
+	}
+	sig, err := parties[0].Phase5(shareR, sigs...)
+	assert.Nil(t, err)
+	assert.Nil(t, SchnorrVerify(sharepub, hash, sig))
+}
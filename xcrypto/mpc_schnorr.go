@@ -16,13 +16,18 @@ import (
 
 // SchnorrParty -- Schnorr party struct.
 type SchnorrParty struct {
-	k0    *big.Int
-	N     *big.Int
-	prv   *PrvKey
-	pub   *PubKey
-	hash  []byte
-	curve elliptic.Curve
-	r     *secp256k1.Scalar
+	k0      *big.Int
+	N       *big.Int
+	prv     *PrvKey
+	pub     *PubKey
+	hash    []byte
+	curve   elliptic.Curve
+	r       *secp256k1.Scalar
+	refresh *mpcRefresh
+
+	chain        []byte
+	chainShare   []byte
+	chainCommits [][]byte
 }
 
 // NewSchnorrParty -- creates new SchnorrParty.
@@ -38,6 +43,11 @@ func NewSchnorrParty(prv *PrvKey) (*SchnorrParty, error) {
 	}, nil
 }
 
+// PubKey -- returns the public key of this party.
+func (party *SchnorrParty) PubKey() *PubKey {
+	return party.pub
+}
+
 // Phase1 -- used to generate final pubkey of parties.
 // Return the shared PubKey.
 func (party *SchnorrParty) Phase1(pub2 *PubKey) *PubKey {
@@ -46,6 +56,7 @@ func (party *SchnorrParty) Phase1(pub2 *PubKey) *PubKey {
 }
 
 // Phase2 -- used to generate k, kinv, scalarR.
+// The nonce is fresh on every call, even for the same hash.
 // Return the party scalar R.
 func (party *SchnorrParty) Phase2(hash []byte) *secp256k1.Scalar {
 	N := party.N
@@ -56,8 +67,12 @@ func (party *SchnorrParty) Phase2(hash []byte) *secp256k1.Scalar {
 
 	party.hash = hash
 	// Scalar R.
-	// k' = int(hash(bytes(d) || m)) mod n
-	k0, err := schnorr.GetK0(hash, d, N)
+	// k' = int(hash(bytes(d) || sha256(m || aux))) mod n
+	data, err := mpcNonceData(hash, nil)
+	if err != nil {
+		return nil
+	}
+	k0, err := schnorr.GetK0(data, d, N)
 	if err != nil {
 		return nil
 	}
@@ -85,18 +100,17 @@ func (party *SchnorrParty) Phase4(sharePub *PubKey, shareR *secp256k1.Scalar) ([
 	prv := party.prv
 	pub := sharePub
 	curve := party.curve
-	scalarR := party.r
 	shareScalarR := shareR
 
 	// e = int(hash(bytes(x(R)) || bytes(dG) || m)) mod n
-	e := schnorr.GetE(curve, m, pub.X, pub.Y, schnorr.IntToByte(scalarR.X))
+	e := schnorr.GetE(curve, m, pub.X, pub.Y, schnorr.IntToByte(shareScalarR.X))
 
 	// ed
 	ed := new(big.Int)
 	ed.Mul(e, prv.D)
 
 	// s = k + ed
-	k := schnorr.GetK(curve, shareScalarR.Y, k0)
+	k := schnorr.GetK(curve, shareScalarR.Y, new(big.Int).Set(k0))
 	s := new(big.Int)
 	s.Add(k, ed)
 	s.Mod(s, N)
@@ -126,6 +140,8 @@ func (party *SchnorrParty) Phase5(shareR *secp256k1.Scalar, sigs ...[]byte) ([]b
 // Close -- close the party.
 func (party *SchnorrParty) Close() {
 	party.prv = nil
+	party.refresh.close()
+	party.refresh = nil
 	if party.k0 != nil {
 		party.k0.SetInt64(0)
 	}
@@ -62,6 +62,13 @@ func TestMpcSchnorr(t *testing.T) {
 	fs2, err := party2.Phase5(sharer2, s1, s2)
 	assert.Nil(t, err)
 	assert.Equal(t, fs1, fs2)
+
+	// Verify.
+	err = SchnorrVerify(sharepub1, hash, fs1)
+	assert.Nil(t, err)
+
+	// The nonce is fresh for the same hash.
+	assert.NotEqual(t, r1, party1.Phase2(hash))
 }
 
 func BenchmarkMpcSchnorrKeyGen(b *testing.B) {
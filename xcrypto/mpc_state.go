@@ -0,0 +1,399 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/keyfuse/tokucore/xcrypto/paillier"
+	"github.com/keyfuse/tokucore/xcrypto/pbkdf2"
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+)
+
+const (
+	// MpcStateVersion -- the at-rest format version of the sealed party state.
+	MpcStateVersion byte = 0x01
+
+	mpcStateKindEcdsa   byte = 0x01
+	mpcStateKindSchnorr byte = 0x02
+	mpcStateKindBob     byte = 0x03
+
+	mpcStateSaltLen  = 16
+	mpcStateNonceLen = 12
+	mpcStateIter     = 100000
+	mpcStateMaxIter  = 10000000
+)
+
+// The sealed state layout is
+// version(1) || kind(1) || salt(16) || iter(4) || nonce(12) || AES-256-GCM(state).
+// The key is PBKDF2-HMAC-SHA256(passphrase, salt, iter) and the header is
+// authenticated as additional data, so changing any byte fails the open.
+
+// Seal -- returns the party state (key share and the in-flight signing state)
+// encrypted with the passphrase, so the session can be resumed by UnsealEcdsaParty.
+func (party *EcdsaParty) Seal(passphrase []byte) ([]byte, error) {
+	if party.prv == nil {
+		return nil, fmt.Errorf("mpc.party.closed")
+	}
+	return mpcSeal(mpcStateKindEcdsa, party.encodeState().Bytes(), passphrase)
+}
+
+// UnsealEcdsaParty -- restores the EcdsaParty from the Seal output.
+func UnsealEcdsaParty(data []byte, passphrase []byte) (*EcdsaParty, error) {
+	plain, err := mpcUnseal(mpcStateKindEcdsa, data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	buffer := xbase.NewBufferReader(plain)
+	party, err := decodeEcdsaState(buffer)
+	if err != nil {
+		return nil, err
+	}
+	if err := mpcReadEnd(buffer); err != nil {
+		return nil, err
+	}
+	return party, nil
+}
+
+// UnsealEcdsaAlice -- restores the EcdsaAlice from the Seal output.
+func UnsealEcdsaAlice(data []byte, passphrase []byte) (*EcdsaAlice, error) {
+	party, err := UnsealEcdsaParty(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &EcdsaAlice{party}, nil
+}
+
+// Seal -- returns the bob state including the secret encrypted with the passphrase.
+func (bob *EcdsaBob) Seal(passphrase []byte) ([]byte, error) {
+	if bob.prv == nil {
+		return nil, fmt.Errorf("mpc.party.closed")
+	}
+	buffer := bob.encodeState()
+	buffer.WriteBytes(mpcIntToBytes(bob.secret))
+	return mpcSeal(mpcStateKindBob, buffer.Bytes(), passphrase)
+}
+
+// UnsealEcdsaBob -- restores the EcdsaBob from the Seal output.
+func UnsealEcdsaBob(data []byte, passphrase []byte) (*EcdsaBob, error) {
+	plain, err := mpcUnseal(mpcStateKindBob, data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	buffer := xbase.NewBufferReader(plain)
+	party, err := decodeEcdsaState(buffer)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := mpcReadModN(buffer)
+	if err != nil {
+		return nil, err
+	}
+	if err := mpcReadEnd(buffer); err != nil {
+		return nil, err
+	}
+	return &EcdsaBob{secret, party}, nil
+}
+
+// Seal -- returns the party state encrypted with the passphrase.
+func (party *SchnorrParty) Seal(passphrase []byte) ([]byte, error) {
+	if party.prv == nil {
+		return nil, fmt.Errorf("mpc.party.closed")
+	}
+	buffer := xbase.NewBuffer()
+	buffer.WriteBytes(party.prv.Serialize())
+	mpcWriteOptInt(buffer, party.k0)
+	buffer.WriteVarBytes(party.hash)
+	if party.r != nil {
+		buffer.WriteU8(1)
+		buffer.WriteBytes(mpcScalarToBytes(party.r))
+	} else {
+		buffer.WriteU8(0)
+	}
+	mpcWriteChainCode(buffer, party.chain, party.chainShare, party.chainCommits)
+	return mpcSeal(mpcStateKindSchnorr, buffer.Bytes(), passphrase)
+}
+
+// UnsealSchnorrParty -- restores the SchnorrParty from the Seal output.
+func UnsealSchnorrParty(data []byte, passphrase []byte) (*SchnorrParty, error) {
+	plain, err := mpcUnseal(mpcStateKindSchnorr, data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	buffer := xbase.NewBufferReader(plain)
+	prv, err := mpcReadPrvKey(buffer)
+	if err != nil {
+		return nil, err
+	}
+	party, _ := NewSchnorrParty(prv)
+	if party.k0, err = mpcReadOptInt(buffer); err != nil {
+		return nil, err
+	}
+	if party.hash, err = mpcReadOptBytes(buffer); err != nil {
+		return nil, err
+	}
+	flag, err := buffer.ReadU8()
+	if err != nil {
+		return nil, err
+	}
+	switch flag {
+	case 0:
+	case 1:
+		r, err := mpcReadPoint(buffer)
+		if err != nil {
+			return nil, err
+		}
+		party.r = secp256k1.NewScalar(r.X, r.Y)
+	default:
+		return nil, fmt.Errorf("mpc.state.flag[%v].invalid", flag)
+	}
+	if party.chain, party.chainShare, party.chainCommits, err = mpcReadChainCode(buffer); err != nil {
+		return nil, err
+	}
+	if err := mpcReadEnd(buffer); err != nil {
+		return nil, err
+	}
+	return party, nil
+}
+
+// encodeState -- writes prv || opt(k) || varbytes(hash) || opt(encpk) || varbytes(encprv) ||
+// opt(tweak) || varbytes(chain) || varbytes(chainShare) || varbytes(chainCommits).
+func (party *EcdsaParty) encodeState() *xbase.Buffer {
+	buffer := xbase.NewBuffer()
+	buffer.WriteBytes(party.prv.Serialize())
+	mpcWriteOptInt(buffer, party.k)
+	buffer.WriteVarBytes(party.hash)
+	if party.encpk != nil {
+		buffer.WriteVarBytes(party.encpk.Bytes())
+	} else {
+		buffer.WriteVarBytes(nil)
+	}
+	if party.encprv != nil {
+		buffer.WriteVarBytes(party.encprv.Serialize())
+	} else {
+		buffer.WriteVarBytes(nil)
+	}
+	mpcWriteOptInt(buffer, party.tweak)
+	mpcWriteChainCode(buffer, party.chain, party.chainShare, party.chainCommits)
+	return buffer
+}
+
+func decodeEcdsaState(buffer *xbase.Buffer) (*EcdsaParty, error) {
+	prv, err := mpcReadPrvKey(buffer)
+	if err != nil {
+		return nil, err
+	}
+	party := NewEcdsaParty(prv)
+	if party.k, err = mpcReadOptInt(buffer); err != nil {
+		return nil, err
+	}
+	if party.k != nil {
+		party.kinv = new(big.Int).ModInverse(party.k, party.N)
+	}
+	if party.hash, err = mpcReadOptBytes(buffer); err != nil {
+		return nil, err
+	}
+	encpk, err := mpcReadOptBytes(buffer)
+	if err != nil {
+		return nil, err
+	}
+	encprv, err := mpcReadOptBytes(buffer)
+	if err != nil {
+		return nil, err
+	}
+	if (encpk == nil) != (encprv == nil) {
+		return nil, fmt.Errorf("mpc.state.paillier.incomplete")
+	}
+	if encprv != nil {
+		if encpk[0] == 0x00 {
+			return nil, fmt.Errorf("mpc.message.integer.not.canonical")
+		}
+		if party.encprv, err = paillier.PrvKeyFromBytes(encprv); err != nil {
+			return nil, err
+		}
+		party.encpk = new(big.Int).SetBytes(encpk)
+		if err := party.encprv.PubKey().ValidCiphertext(party.encpk); err != nil {
+			return nil, err
+		}
+	}
+	if party.tweak, err = mpcReadOptInt(buffer); err != nil {
+		return nil, err
+	}
+	if party.chain, party.chainShare, party.chainCommits, err = mpcReadChainCode(buffer); err != nil {
+		return nil, err
+	}
+	return party, nil
+}
+
+// mpcWriteChainCode -- writes the chain code, and the share and the commitments
+// of the other parties of the unfinished agreement.
+func mpcWriteChainCode(buffer *xbase.Buffer, chain []byte, share []byte, commits [][]byte) {
+	buffer.WriteVarBytes(chain)
+	buffer.WriteVarBytes(share)
+	buffer.WriteVarBytes(bytes.Join(commits, nil))
+}
+
+// mpcReadChainCode -- reads the mpcWriteChainCode encoding.
+func mpcReadChainCode(buffer *xbase.Buffer) ([]byte, []byte, [][]byte, error) {
+	chain, err := mpcReadOptBytes(buffer)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	share, err := mpcReadOptBytes(buffer)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	joined, err := mpcReadOptBytes(buffer)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, b := range [][]byte{chain, share} {
+		if b != nil && len(b) != MpcChainCodeSize {
+			return nil, nil, nil, fmt.Errorf("mpc.state.chain.code.size[%v].invalid", len(b))
+		}
+	}
+	if len(joined)%MpcChainCodeSize != 0 || (joined != nil && share == nil) {
+		return nil, nil, nil, fmt.Errorf("mpc.state.chain.code.commitments.size[%v].invalid", len(joined))
+	}
+	var commits [][]byte
+	for i := 0; i < len(joined); i += MpcChainCodeSize {
+		commits = append(commits, joined[i:i+MpcChainCodeSize])
+	}
+	return chain, share, commits, nil
+}
+
+// mpcReadPrvKey -- reads a 32-byte private key in the range [1, N-1].
+func mpcReadPrvKey(buffer *xbase.Buffer) (*PrvKey, error) {
+	d, err := mpcReadModN(buffer)
+	if err != nil {
+		return nil, err
+	}
+	return PrvKeyFromBytes(d.Bytes()), nil
+}
+
+// mpcWriteOptInt -- writes flag(1) || 32-byte integer if v is not nil.
+func mpcWriteOptInt(buffer *xbase.Buffer, v *big.Int) {
+	if v == nil || v.Sign() == 0 {
+		buffer.WriteU8(0)
+		return
+	}
+	buffer.WriteU8(1)
+	buffer.WriteBytes(mpcIntToBytes(v))
+}
+
+// mpcReadOptInt -- reads the mpcWriteOptInt encoding.
+func mpcReadOptInt(buffer *xbase.Buffer) (*big.Int, error) {
+	flag, err := buffer.ReadU8()
+	if err != nil {
+		return nil, err
+	}
+	switch flag {
+	case 0:
+		return nil, nil
+	case 1:
+		return mpcReadModN(buffer)
+	}
+	return nil, fmt.Errorf("mpc.state.flag[%v].invalid", flag)
+}
+
+// mpcReadOptBytes -- reads var-length bytes, returns nil if empty.
+func mpcReadOptBytes(buffer *xbase.Buffer) ([]byte, error) {
+	b, err := buffer.ReadVarBytes()
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, nil
+	}
+	return b, nil
+}
+
+// mpcSeal -- encrypts the plain state with the passphrase.
+func mpcSeal(kind byte, plain []byte, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, mpcStateSaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, mpcStateNonceLen)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	header := xbase.NewBuffer()
+	header.WriteU8(MpcStateVersion)
+	header.WriteU8(kind)
+	header.WriteBytes(salt)
+	header.WriteU32(mpcStateIter)
+	header.WriteBytes(nonce)
+
+	aead, err := mpcStateAEAD(passphrase, salt, mpcStateIter)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(header.Bytes(), nonce, plain, header.Bytes()), nil
+}
+
+// mpcUnseal -- checks the header and decrypts the state.
+func mpcUnseal(kind byte, data []byte, passphrase []byte) ([]byte, error) {
+	var err error
+	var version, got byte
+	var salt, nonce []byte
+	var iter uint32
+
+	buffer := xbase.NewBufferReader(data)
+	if version, err = buffer.ReadU8(); err != nil {
+		return nil, err
+	}
+	if version != MpcStateVersion {
+		return nil, fmt.Errorf("mpc.state.version[%v].unsupported", version)
+	}
+	if got, err = buffer.ReadU8(); err != nil {
+		return nil, err
+	}
+	if got != kind {
+		return nil, fmt.Errorf("mpc.state.kind.mismatch.want[%v].got[%v]", kind, got)
+	}
+	if salt, err = buffer.ReadBytes(mpcStateSaltLen); err != nil {
+		return nil, err
+	}
+	if iter, err = buffer.ReadU32(); err != nil {
+		return nil, err
+	}
+	if iter == 0 || iter > mpcStateMaxIter {
+		return nil, fmt.Errorf("mpc.state.iter.invalid")
+	}
+	if nonce, err = buffer.ReadBytes(mpcStateNonceLen); err != nil {
+		return nil, err
+	}
+
+	aead, err := mpcStateAEAD(passphrase, salt, int(iter))
+	if err != nil {
+		return nil, err
+	}
+	header := data[:buffer.Seek()]
+	plain, err := aead.Open(nil, nonce, buffer.Remaining(), header)
+	if err != nil {
+		return nil, fmt.Errorf("mpc.state.decrypt.failed")
+	}
+	return plain, nil
+}
+
+func mpcStateAEAD(passphrase []byte, salt []byte, iter int) (cipher.AEAD, error) {
+	key := pbkdf2.Key(passphrase, salt, iter, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
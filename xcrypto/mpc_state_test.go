@@ -0,0 +1,143 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMpcStateEcdsa(t *testing.T) {
+	hash := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+	passphrase := []byte("tokucore")
+
+	p1, _ := new(big.Int).SetString("15bafcb56279dbfd985d4d17cdaf9bbfc6701b628f9fb00d6d1e0d2cb503ede3", 16)
+	prv1 := PrvKeyFromBytes(p1.Bytes())
+	party1 := NewEcdsaParty(prv1)
+	p2, _ := new(big.Int).SetString("76818c328b8aa1e8f17bd599016fef8134b7d5ec315e0b6373953da7e8b5c0c9", 16)
+	prv2 := PrvKeyFromBytes(p2.Bytes())
+	party2 := NewEcdsaParty(prv2)
+	defer party2.Close()
+
+	// Seal before any phase.
+	{
+		sealed, err := party1.Seal(passphrase)
+		assert.Nil(t, err)
+		got, err := UnsealEcdsaParty(sealed, passphrase)
+		assert.Nil(t, err)
+		assert.Equal(t, party1.prv.D, got.prv.D)
+		assert.Nil(t, got.k)
+	}
+
+	sharepub := party1.Phase1(prv2.PubKey())
+	encpk1, encpub1, scalarR1 := party1.Phase2(hash)
+	_, _, scalarR2 := party2.Phase2(hash)
+
+	// Pause party1 after Phase2, resume from the sealed state.
+	sealed, err := party1.Seal(passphrase)
+	assert.Nil(t, err)
+	party1.Close()
+	party1, err = UnsealEcdsaParty(sealed, passphrase)
+	assert.Nil(t, err)
+	defer party1.Close()
+
+	shareR1 := party1.Phase3(scalarR2)
+	shareR2 := party2.Phase3(scalarR1)
+	sig2, err := party2.Phase4(encpk1, encpub1, shareR2)
+	assert.Nil(t, err)
+	fs1, err := party1.Phase5(shareR1, sig2)
+	assert.Nil(t, err)
+	assert.Nil(t, EcdsaVerify(sharepub, hash, fs1))
+
+	// Closed.
+	_, err = party2.Seal(passphrase)
+	assert.Nil(t, err)
+	party2.Close()
+	_, err = party2.Seal(passphrase)
+	assert.NotNil(t, err)
+}
+
+func TestMpcStateScriptless(t *testing.T) {
+	passphrase := []byte("tokucore")
+	p1, _ := new(big.Int).SetString("15bafcb56279dbfd985d4d17cdaf9bbfc6701b628f9fb00d6d1e0d2cb503ede3", 16)
+	alice := NewEcdsaAlice(PrvKeyFromBytes(p1.Bytes()))
+	p2, _ := new(big.Int).SetString("76818c328b8aa1e8f17bd599016fef8134b7d5ec315e0b6373953da7e8b5c0c9", 16)
+	bob := NewEcdsaBob(PrvKeyFromBytes(p2.Bytes()), big.NewInt(2019))
+
+	sealed, err := alice.Seal(passphrase)
+	assert.Nil(t, err)
+	alice1, err := UnsealEcdsaAlice(sealed, passphrase)
+	assert.Nil(t, err)
+	assert.Equal(t, alice.prv.D, alice1.prv.D)
+
+	sealed, err = bob.Seal(passphrase)
+	assert.Nil(t, err)
+	bob1, err := UnsealEcdsaBob(sealed, passphrase)
+	assert.Nil(t, err)
+	assert.Equal(t, bob.secret, bob1.secret)
+	assert.Equal(t, bob.prv.D, bob1.prv.D)
+
+	// Kind mismatch.
+	_, err = UnsealEcdsaParty(sealed, passphrase)
+	assert.NotNil(t, err)
+}
+
+func TestMpcStateSchnorr(t *testing.T) {
+	hash := Sha256([]byte{0x01, 0x02, 0x03, 0x04})
+	passphrase := []byte("tokucore")
+
+	p1, _ := new(big.Int).SetString("15bafcb56279dbfd985d4d17cdaf9bbfc6701b628f9fb00d6d1e0d2cb503ede3", 16)
+	party1, _ := NewSchnorrParty(PrvKeyFromBytes(p1.Bytes()))
+	p2, _ := new(big.Int).SetString("76818c328b8aa1e8f17bd599016fef8134b7d5ec315e0b6373953da7e8b5c0c9", 16)
+	party2, _ := NewSchnorrParty(PrvKeyFromBytes(p2.Bytes()))
+
+	sharepub := party1.Phase1(party2.pub)
+	r1 := party1.Phase2(hash)
+	r2 := party2.Phase2(hash)
+
+	sealed, err := party1.Seal(passphrase)
+	assert.Nil(t, err)
+	party1, err = UnsealSchnorrParty(sealed, passphrase)
+	assert.Nil(t, err)
+	assert.Equal(t, r1, party1.r)
+
+	shareR := party1.Phase3(r2)
+	sig1, err := party1.Phase4(sharepub, shareR)
+	assert.Nil(t, err)
+	sig2, err := party2.Phase4(sharepub, party2.Phase3(r1))
+	assert.Nil(t, err)
+	fs, err := party1.Phase5(shareR, sig1, sig2)
+	assert.Nil(t, err)
+	assert.Nil(t, SchnorrVerify(sharepub, hash, fs))
+}
+
+func TestMpcStateError(t *testing.T) {
+	passphrase := []byte("tokucore")
+	party, _ := NewSchnorrParty(PrvKeyFromBytes([]byte{0x01}))
+	sealed, err := party.Seal(passphrase)
+	assert.Nil(t, err)
+
+	// Wrong passphrase.
+	_, err = UnsealSchnorrParty(sealed, []byte("xx"))
+	assert.NotNil(t, err)
+
+	// Tampered header and body.
+	for _, i := range []int{0, 1, 2, 20, len(sealed) - 1} {
+		bad := make([]byte, len(sealed))
+		copy(bad, sealed)
+		bad[i] ^= 0x01
+		_, err = UnsealSchnorrParty(bad, passphrase)
+		assert.NotNil(t, err)
+	}
+
+	// Truncated.
+	for i := 0; i < 34; i++ {
+		_, err = UnsealSchnorrParty(sealed[:i], passphrase)
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,919 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	xecdsa "github.com/keyfuse/tokucore/xcrypto/ecdsa"
+	"github.com/keyfuse/tokucore/xcrypto/paillier"
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+)
+
+// The t-of-n threshold ECDSA follows GG18/GG20:
+//
+// KeyGen: every party deals a Feldman VSS of a random secret ui, the shared
+// key is X = sum(ui*G) and the key share of party i is xi = sum(fj(i)).
+// Every party also publishes a Paillier key with the Paillier-Blum modulus proof
+// and ring-Pedersen parameters with the well-formed proof, used by the MtA
+// range proofs of the presigning.
+//
+// Presign: a signer set S of at least t parties converts k = sum(ki) and
+// x = sum(λi*xi) to the additive shares of kγ and kx with MtA/MtAwc, reveals
+// Γ = sum(γi*G) and gets R = (kγ)^-1 * Γ = k^-1 * G. Every party publishes
+// R̄i = ki*R and Si = σi*R which must sum to G and X.
+//
+// Sign: each signer sends si = m*ki + r*σi, checked by si*R == m*R̄i + r*Si,
+// and s = sum(si) is the standard ECDSA signature (r, s) of X.
+//
+// The messages returned as maps keyed by the party id must be sent over an
+// authenticated and encrypted channel, the others are broadcast.
+
+const (
+	thresholdBlindSize = 32
+)
+
+// EcdsaThresholdKeyGenMsg1 -- the KeyGenPhase1 broadcast.
+type EcdsaThresholdKeyGenMsg1 struct {
+	From              uint32
+	Commit            []byte
+	EncPub            *paillier.PubKey
+	EncPubProof       *paillier.ModulusProof
+	RingPedersen      *RingPedersen
+	RingPedersenProof *RingPedersenProof
+}
+
+// EcdsaThresholdKeyGenMsg2 -- the KeyGenPhase2 broadcast, opening the commitment.
+type EcdsaThresholdKeyGenMsg2 struct {
+	From        uint32
+	Commitments []*PubKey
+	Blind       []byte
+	Proof       *SchnorrProof
+}
+
+// EcdsaThresholdPresignMsg1 -- the PresignPhase1 broadcast.
+// RangeProofs is keyed by the verifier id.
+type EcdsaThresholdPresignMsg1 struct {
+	From        uint32
+	Commit      []byte
+	EncK        *big.Int
+	RangeProofs map[uint32]*MtaRangeProof
+}
+
+// EcdsaThresholdPresignMsg2 -- the PresignPhase2 MtA response, peer to peer.
+type EcdsaThresholdPresignMsg2 struct {
+	From       uint32
+	To         uint32
+	CGamma     *big.Int
+	ProofGamma *MtaRespProof
+	CW         *big.Int
+	ProofW     *MtaRespProof
+}
+
+// EcdsaThresholdPresignMsg3 -- the PresignPhase3 broadcast of δi and the Γi opening.
+type EcdsaThresholdPresignMsg3 struct {
+	From  uint32
+	Delta *big.Int
+	Gamma *PubKey
+	Blind []byte
+	Proof *SchnorrProof
+}
+
+// EcdsaThresholdPresignMsg4 -- the PresignPhase4 broadcast of R̄i and Si.
+type EcdsaThresholdPresignMsg4 struct {
+	From uint32
+	RBar *PubKey
+	S    *PubKey
+}
+
+// ecdsaThresholdPeer -- the public material of a party.
+type ecdsaThresholdPeer struct {
+	encpub *paillier.PubKey
+	rp     *RingPedersen
+	share  *PubKey
+	commit []byte
+}
+
+// ecdsaThresholdPresign -- the in-flight presigning state.
+type ecdsaThresholdPresign struct {
+	signers []uint32
+	w       *big.Int
+	k       *big.Int
+	gamma   *big.Int
+	blind   []byte
+	encK    *big.Int
+	peerEnc map[uint32]*big.Int
+	commits map[uint32][]byte
+	betas   map[uint32]*big.Int
+	nus     map[uint32]*big.Int
+	delta   *big.Int
+	sigma   *big.Int
+	R       *PubKey
+	rbars   map[uint32]*PubKey
+	ss      map[uint32]*PubKey
+}
+
+// EcdsaThresholdParty -- the t-of-n threshold ECDSA party.
+type EcdsaThresholdParty struct {
+	id        uint32
+	ids       []uint32
+	threshold int
+	N         *big.Int
+	curve     elliptic.Curve
+
+	poly   *VssPolynomial
+	blind  []byte
+	encprv *paillier.PrvKey
+	rp     *RingPedersen
+
+	share    *big.Int
+	pub      *PubKey
+	peers    map[uint32]*ecdsaThresholdPeer
+	presign  *ecdsaThresholdPresign
+	reshared bool
+}
+
+// NewEcdsaThresholdParty -- creates the party id of the parties ids,
+// any threshold of them can sign.
+func NewEcdsaThresholdParty(id uint32, ids []uint32, threshold int) (*EcdsaThresholdParty, error) {
+	if threshold < 2 || threshold > len(ids) {
+		return nil, fmt.Errorf("mpc.threshold[%v].of[%v].invalid", threshold, len(ids))
+	}
+	if err := thresholdCheckIDs(ids); err != nil {
+		return nil, err
+	}
+	if !thresholdContains(ids, id) {
+		return nil, fmt.Errorf("mpc.threshold.id[%v].not.in.ids[%v]", id, ids)
+	}
+	curve := secp256k1.SECP256K1()
+	return &EcdsaThresholdParty{
+		id:        id,
+		ids:       append([]uint32(nil), ids...),
+		threshold: threshold,
+		N:         curve.Params().N,
+		curve:     curve,
+		peers:     make(map[uint32]*ecdsaThresholdPeer),
+	}, nil
+}
+
+// ID -- returns the party id.
+func (party *EcdsaThresholdParty) ID() uint32 {
+	return party.id
+}
+
+// Threshold -- returns the number of parties required to sign.
+func (party *EcdsaThresholdParty) Threshold() int {
+	return party.threshold
+}
+
+// PubKey -- returns the shared public key, nil before the KeyGen finished.
+func (party *EcdsaThresholdParty) PubKey() *PubKey {
+	return party.pub
+}
+
+// KeyGenPhase1 -- generates the VSS polynomial, Paillier key and ring-Pedersen parameters.
+// Return the broadcast message committing to the VSS commitments.
+func (party *EcdsaThresholdParty) KeyGenPhase1() (*EcdsaThresholdKeyGenMsg1, error) {
+	u, err := randModN()
+	if err != nil {
+		return nil, err
+	}
+	poly, err := NewVssPolynomial(u, party.threshold)
+	if err != nil {
+		return nil, err
+	}
+	blind, err := thresholdBlind()
+	if err != nil {
+		return nil, err
+	}
+
+	encProof, rpProof, err := party.generateKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	party.poly = poly
+	party.blind = blind
+	return &EcdsaThresholdKeyGenMsg1{
+		From:              party.id,
+		Commit:            thresholdCommit(blind, poly.Commitments()...),
+		EncPub:            party.encprv.PubKey(),
+		EncPubProof:       encProof,
+		RingPedersen:      party.rp,
+		RingPedersenProof: rpProof,
+	}, nil
+}
+
+// KeyGenPhase2 -- checks the Paillier keys and ring-Pedersen proofs of the others.
+// Return the broadcast opening and the VSS shares to send to each party.
+func (party *EcdsaThresholdParty) KeyGenPhase2(msgs []*EcdsaThresholdKeyGenMsg1) (*EcdsaThresholdKeyGenMsg2, map[uint32]*big.Int, error) {
+	if party.poly == nil {
+		return nil, nil, fmt.Errorf("mpc.threshold.keygen.phase1.missing")
+	}
+	froms := make([]uint32, len(msgs))
+	for i, msg := range msgs {
+		froms[i] = msg.From
+	}
+	if err := party.checkFrom(party.ids, froms); err != nil {
+		return nil, nil, err
+	}
+
+	for _, msg := range msgs {
+		if msg.From == party.id {
+			continue
+		}
+		if len(msg.Commit) != sha256.Size {
+			return nil, nil, fmt.Errorf("mpc.threshold.party[%v].commit.invalid", msg.From)
+		}
+		if err := party.addPeer(msg.From, msg.EncPub, msg.EncPubProof, msg.RingPedersen, msg.RingPedersenProof); err != nil {
+			return nil, nil, err
+		}
+		party.peers[msg.From].commit = msg.Commit
+	}
+
+	proof, err := newSchnorrProof("mpc.threshold.keygen", party.id, party.poly.Secret())
+	if err != nil {
+		return nil, nil, err
+	}
+	shares := make(map[uint32]*big.Int)
+	for _, id := range party.ids {
+		if id != party.id {
+			shares[id] = party.poly.Share(id)
+		}
+	}
+	return &EcdsaThresholdKeyGenMsg2{
+		From:        party.id,
+		Commitments: party.poly.Commitments(),
+		Blind:       party.blind,
+		Proof:       proof,
+	}, shares, nil
+}
+
+// KeyGenPhase3 -- checks the openings and the VSS shares sent to this party,
+// shares is keyed by the sender id.
+// Return the shared PubKey.
+func (party *EcdsaThresholdParty) KeyGenPhase3(msgs []*EcdsaThresholdKeyGenMsg2, shares map[uint32]*big.Int) (*PubKey, error) {
+	if party.poly == nil || len(party.peers) != len(party.ids)-1 {
+		return nil, fmt.Errorf("mpc.threshold.keygen.phase2.missing")
+	}
+	froms := make([]uint32, len(msgs))
+	for i, msg := range msgs {
+		froms[i] = msg.From
+	}
+	if err := party.checkFrom(party.ids, froms); err != nil {
+		return nil, err
+	}
+
+	all := map[uint32][]*PubKey{party.id: party.poly.Commitments()}
+	x := party.poly.Share(party.id)
+	for _, msg := range msgs {
+		if msg.From == party.id {
+			continue
+		}
+		peer := party.peers[msg.From]
+		if len(msg.Commitments) != party.threshold || !party.onCurve(msg.Commitments...) || !bytes.Equal(thresholdCommit(msg.Blind, msg.Commitments...), peer.commit) {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].decommit.invalid", msg.From)
+		}
+		if !msg.Proof.Verify("mpc.threshold.keygen", msg.From, msg.Commitments[0]) {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].schnorr.proof.invalid", msg.From)
+		}
+		share, ok := shares[msg.From]
+		if !ok || !VssVerify(msg.Commitments, party.id, share) {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].share.invalid", msg.From)
+		}
+		x.Add(x, share)
+		x.Mod(x, party.N)
+		all[msg.From] = msg.Commitments
+	}
+	pub, err := party.finishKeyGen(x, all)
+	if err != nil {
+		return nil, err
+	}
+	party.poly.Close()
+	party.poly = nil
+	party.blind = nil
+	return pub, nil
+}
+
+// PresignPhase1 -- starts a presigning with the signers, this party must be one of them.
+// Return the broadcast commitment of Γi and the Paillier encryption of ki with the range proofs.
+func (party *EcdsaThresholdParty) PresignPhase1(signers []uint32) (*EcdsaThresholdPresignMsg1, error) {
+	if party.share == nil {
+		return nil, fmt.Errorf("mpc.threshold.keygen.unfinished")
+	}
+	if party.reshared {
+		return nil, fmt.Errorf("mpc.threshold.party.reshared")
+	}
+	if len(signers) < party.threshold {
+		return nil, fmt.Errorf("mpc.threshold.signers[%v].less.than.threshold[%v]", len(signers), party.threshold)
+	}
+	if err := thresholdCheckIDs(signers); err != nil {
+		return nil, err
+	}
+	for _, id := range signers {
+		if !thresholdContains(party.ids, id) {
+			return nil, fmt.Errorf("mpc.threshold.signer[%v].unknown", id)
+		}
+	}
+	if !thresholdContains(signers, party.id) {
+		return nil, fmt.Errorf("mpc.threshold.id[%v].not.in.signers[%v]", party.id, signers)
+	}
+
+	lambda, err := LagrangeCoefficient(party.id, signers)
+	if err != nil {
+		return nil, err
+	}
+	w := lambda.Mul(lambda, party.share)
+	w.Mod(w, party.N)
+	k, err := randModN()
+	if err != nil {
+		return nil, err
+	}
+	gamma, err := randModN()
+	if err != nil {
+		return nil, err
+	}
+	blind, err := thresholdBlind()
+	if err != nil {
+		return nil, err
+	}
+
+	encpub := party.encprv.PubKey()
+	nonce, err := encpub.RandomNonce()
+	if err != nil {
+		return nil, err
+	}
+	encK, err := encpub.EncryptWithNonce(k, nonce)
+	if err != nil {
+		return nil, err
+	}
+	proofs := make(map[uint32]*MtaRangeProof)
+	for _, id := range signers {
+		if id == party.id {
+			continue
+		}
+		proof, err := newMtaRangeProof(encpub, encK, k, nonce, party.peers[id].rp)
+		if err != nil {
+			return nil, err
+		}
+		proofs[id] = proof
+	}
+
+	party.presign = &ecdsaThresholdPresign{
+		signers: append([]uint32(nil), signers...),
+		w:       w,
+		k:       k,
+		gamma:   gamma,
+		blind:   blind,
+		encK:    encK,
+	}
+	return &EcdsaThresholdPresignMsg1{
+		From:        party.id,
+		Commit:      thresholdCommit(blind, pointBaseMult(gamma)),
+		EncK:        encK,
+		RangeProofs: proofs,
+	}, nil
+}
+
+// PresignPhase2 -- checks the range proofs of kj and runs the MtA of kj*γi and MtAwc of kj*wi as the respondent.
+// Return the responses keyed by the receiver id.
+func (party *EcdsaThresholdParty) PresignPhase2(msgs []*EcdsaThresholdPresignMsg1) (map[uint32]*EcdsaThresholdPresignMsg2, error) {
+	ps := party.presign
+	if ps == nil || ps.encK == nil {
+		return nil, fmt.Errorf("mpc.threshold.presign.phase1.missing")
+	}
+	froms := make([]uint32, len(msgs))
+	for i, msg := range msgs {
+		froms[i] = msg.From
+	}
+	if err := party.checkFrom(ps.signers, froms); err != nil {
+		return nil, err
+	}
+
+	ps.peerEnc = make(map[uint32]*big.Int)
+	ps.commits = make(map[uint32][]byte)
+	for _, msg := range msgs {
+		if msg.From == party.id {
+			continue
+		}
+		peer := party.peers[msg.From]
+		if len(msg.Commit) != sha256.Size {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].commit.invalid", msg.From)
+		}
+		if !msg.RangeProofs[party.id].Verify(peer.encpub, msg.EncK, party.rp) {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].range.proof.invalid", msg.From)
+		}
+		ps.peerEnc[msg.From] = msg.EncK
+		ps.commits[msg.From] = msg.Commit
+	}
+
+	q5 := new(big.Int).Exp(party.N, big.NewInt(5), nil)
+	ps.betas = make(map[uint32]*big.Int)
+	ps.nus = make(map[uint32]*big.Int)
+	out := make(map[uint32]*EcdsaThresholdPresignMsg2)
+	for id, encK := range ps.peerEnc {
+		peer := party.peers[id]
+		mta := func(x *big.Int, withCheck bool) (*big.Int, *MtaRespProof, *big.Int, error) {
+			y, err := rand.Int(rand.Reader, q5)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			r, err := peer.encpub.RandomNonce()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			// c = Enc(kj)^x * Enc(y).
+			c, err := peer.encpub.EncryptWithNonce(y, r)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			cx, err := peer.encpub.MultPlaintext(encK, x)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if c, err = peer.encpub.Add(cx, c); err != nil {
+				return nil, nil, nil, err
+			}
+			proof, err := newMtaRespProof(peer.encpub, encK, c, x, y, r, peer.rp, withCheck)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			// β = -y mod q.
+			beta := new(big.Int).Neg(y)
+			return c, proof, beta.Mod(beta, party.N), nil
+		}
+
+		cGamma, proofGamma, beta, err := mta(ps.gamma, false)
+		if err != nil {
+			return nil, err
+		}
+		cW, proofW, nu, err := mta(ps.w, true)
+		if err != nil {
+			return nil, err
+		}
+		ps.betas[id] = beta
+		ps.nus[id] = nu
+		out[id] = &EcdsaThresholdPresignMsg2{
+			From:       party.id,
+			To:         id,
+			CGamma:     cGamma,
+			ProofGamma: proofGamma,
+			CW:         cW,
+			ProofW:     proofW,
+		}
+	}
+	return out, nil
+}
+
+// PresignPhase3 -- checks the MtA responses sent to this party and computes δi and σi.
+// Return the broadcast of δi and the opening of Γi.
+func (party *EcdsaThresholdParty) PresignPhase3(msgs []*EcdsaThresholdPresignMsg2) (*EcdsaThresholdPresignMsg3, error) {
+	ps := party.presign
+	if ps == nil || ps.betas == nil {
+		return nil, fmt.Errorf("mpc.threshold.presign.phase2.missing")
+	}
+	froms := make([]uint32, len(msgs))
+	for i, msg := range msgs {
+		froms[i] = msg.From
+	}
+	if err := party.checkFrom(ps.signers, froms); err != nil {
+		return nil, err
+	}
+
+	N := party.N
+	encpub := party.encprv.PubKey()
+	delta := new(big.Int).Mul(ps.k, ps.gamma)
+	sigma := new(big.Int).Mul(ps.k, ps.w)
+	for _, msg := range msgs {
+		if msg.From == party.id {
+			continue
+		}
+		if msg.To != party.id {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].receiver[%v].invalid", msg.From, msg.To)
+		}
+		if !msg.ProofGamma.Verify(encpub, ps.encK, msg.CGamma, party.rp, nil) {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].mta.proof.invalid", msg.From)
+		}
+		W, err := party.weightedShare(msg.From, ps.signers)
+		if err != nil {
+			return nil, err
+		}
+		if !msg.ProofW.Verify(encpub, ps.encK, msg.CW, party.rp, W) {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].mtawc.proof.invalid", msg.From)
+		}
+		alpha, err := party.encprv.Decrypt(msg.CGamma)
+		if err != nil {
+			return nil, err
+		}
+		mu, err := party.encprv.Decrypt(msg.CW)
+		if err != nil {
+			return nil, err
+		}
+		delta.Add(delta, alpha)
+		delta.Add(delta, ps.betas[msg.From])
+		sigma.Add(sigma, mu)
+		sigma.Add(sigma, ps.nus[msg.From])
+	}
+	ps.delta = delta.Mod(delta, N)
+	ps.sigma = sigma.Mod(sigma, N)
+
+	proof, err := newSchnorrProof("mpc.threshold.gamma", party.id, ps.gamma)
+	if err != nil {
+		return nil, err
+	}
+	return &EcdsaThresholdPresignMsg3{
+		From:  party.id,
+		Delta: new(big.Int).Set(ps.delta),
+		Gamma: pointBaseMult(ps.gamma),
+		Blind: ps.blind,
+		Proof: proof,
+	}, nil
+}
+
+// PresignPhase4 -- checks the Γj openings and computes R = δ^-1 * sum(Γj).
+// Return the broadcast of R̄i = ki*R and Si = σi*R.
+func (party *EcdsaThresholdParty) PresignPhase4(msgs []*EcdsaThresholdPresignMsg3) (*EcdsaThresholdPresignMsg4, error) {
+	ps := party.presign
+	if ps == nil || ps.sigma == nil {
+		return nil, fmt.Errorf("mpc.threshold.presign.phase3.missing")
+	}
+	froms := make([]uint32, len(msgs))
+	for i, msg := range msgs {
+		froms[i] = msg.From
+	}
+	if err := party.checkFrom(ps.signers, froms); err != nil {
+		return nil, err
+	}
+
+	N := party.N
+	delta := new(big.Int).Set(ps.delta)
+	gamma := pointBaseMult(ps.gamma)
+	for _, msg := range msgs {
+		if msg.From == party.id {
+			continue
+		}
+		if !party.onCurve(msg.Gamma) || !bytes.Equal(thresholdCommit(msg.Blind, msg.Gamma), ps.commits[msg.From]) {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].decommit.invalid", msg.From)
+		}
+		if !msg.Proof.Verify("mpc.threshold.gamma", msg.From, msg.Gamma) {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].schnorr.proof.invalid", msg.From)
+		}
+		if msg.Delta == nil || msg.Delta.Sign() < 0 || msg.Delta.Cmp(N) >= 0 {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].delta.invalid", msg.From)
+		}
+		delta.Add(delta, msg.Delta)
+		gamma = gamma.Add(msg.Gamma)
+	}
+	delta.Mod(delta, N)
+	if delta.Sign() == 0 {
+		return nil, fmt.Errorf("mpc.threshold.presign.delta.zero")
+	}
+	R := pointMult(gamma, delta.ModInverse(delta, N))
+	if new(big.Int).Mod(R.X, N).Sign() == 0 {
+		return nil, fmt.Errorf("mpc.threshold.presign.r.zero")
+	}
+	ps.R = R
+	return &EcdsaThresholdPresignMsg4{
+		From: party.id,
+		RBar: pointMult(R, ps.k),
+		S:    pointMult(R, ps.sigma),
+	}, nil
+}
+
+// PresignPhase5 -- checks sum(R̄j) == G and sum(Sj) == X.
+// Return the presignature, which can sign one message.
+func (party *EcdsaThresholdParty) PresignPhase5(msgs []*EcdsaThresholdPresignMsg4) (*EcdsaThresholdPresignature, error) {
+	ps := party.presign
+	if ps == nil || ps.R == nil {
+		return nil, fmt.Errorf("mpc.threshold.presign.phase4.missing")
+	}
+	froms := make([]uint32, len(msgs))
+	for i, msg := range msgs {
+		froms[i] = msg.From
+	}
+	if err := party.checkFrom(ps.signers, froms); err != nil {
+		return nil, err
+	}
+
+	rbars := map[uint32]*PubKey{party.id: pointMult(ps.R, ps.k)}
+	ss := map[uint32]*PubKey{party.id: pointMult(ps.R, ps.sigma)}
+	sumR := rbars[party.id]
+	sumS := ss[party.id]
+	for _, msg := range msgs {
+		if msg.From == party.id {
+			continue
+		}
+		if !party.onCurve(msg.RBar, msg.S) {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].point.invalid", msg.From)
+		}
+		rbars[msg.From] = msg.RBar
+		ss[msg.From] = msg.S
+		sumR = sumR.Add(msg.RBar)
+		sumS = sumS.Add(msg.S)
+	}
+	if !pointEqual(sumR, pointBaseMult(big.NewInt(1))) {
+		return nil, fmt.Errorf("mpc.threshold.presign.rbar.inconsistent")
+	}
+	if !pointEqual(sumS, party.pub) {
+		return nil, fmt.Errorf("mpc.threshold.presign.s.inconsistent")
+	}
+
+	presig := &EcdsaThresholdPresignature{
+		party: party,
+		id:    party.id,
+		N:     party.N,
+		pub:   party.pub,
+		R:     ps.R,
+		k:     ps.k,
+		sigma: ps.sigma,
+		rbars: rbars,
+		ss:    ss,
+	}
+	ps.k = nil
+	ps.sigma = nil
+	party.presign.close()
+	party.presign = nil
+	return presig, nil
+}
+
+// Close -- used to cleanup the secret.
+func (party *EcdsaThresholdParty) Close() {
+	if party.share != nil {
+		party.share.SetInt64(0)
+	}
+	if party.poly != nil {
+		party.poly.Close()
+	}
+	if party.presign != nil {
+		party.presign.close()
+	}
+	party.share = nil
+	party.poly = nil
+	party.presign = nil
+	party.encprv = nil
+}
+
+// generateKeys -- generates the Paillier key and ring-Pedersen parameters of this party.
+// Return the Paillier modulus proof and the ring-Pedersen proof.
+func (party *EcdsaThresholdParty) generateKeys() (*paillier.ModulusProof, *RingPedersenProof, error) {
+	_, encprv, err := paillier.GenerateKeyPair(bitlen)
+	if err != nil {
+		return nil, nil, err
+	}
+	encProof, err := paillier.ProveModulus(encprv)
+	if err != nil {
+		return nil, nil, err
+	}
+	rp, rpSecret, err := generateRingPedersen(bitlen)
+	if err != nil {
+		return nil, nil, err
+	}
+	rpProof, err := proveRingPedersen(rp, rpSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+	party.encprv = encprv
+	party.rp = rp
+	return encProof, rpProof, nil
+}
+
+// addPeer -- checks the Paillier key and ring-Pedersen parameters of the party.
+func (party *EcdsaThresholdParty) addPeer(id uint32, encpub *paillier.PubKey, encProof *paillier.ModulusProof, rp *RingPedersen, proof *RingPedersenProof) error {
+	if encpub == nil || encpub.N == nil || encpub.N.BitLen() < bitlen {
+		return fmt.Errorf("mpc.threshold.party[%v].paillier.key.invalid", id)
+	}
+	if !encProof.Verify(encpub) {
+		return fmt.Errorf("mpc.threshold.party[%v].paillier.proof.invalid", id)
+	}
+	if rp.validate() != nil || rp.N.BitLen() < bitlen || !proof.Verify(rp) {
+		return fmt.Errorf("mpc.threshold.party[%v].ring.pedersen.proof.invalid", id)
+	}
+	party.peers[id] = &ecdsaThresholdPeer{
+		encpub: encpub,
+		rp:     rp,
+	}
+	return nil
+}
+
+// finishKeyGen -- sets the key share x and the public shares from the VSS commitments of all the dealers.
+// Return the shared PubKey sum(Cj0).
+func (party *EcdsaThresholdParty) finishKeyGen(x *big.Int, all map[uint32][]*PubKey) (*PubKey, error) {
+	if x.Sign() == 0 {
+		return nil, fmt.Errorf("mpc.threshold.share.zero")
+	}
+
+	// X = sum(Cj0) and Xi = sum(fj(i)*G).
+	var pub *PubKey
+	for _, c := range all {
+		if pub == nil {
+			pub = c[0]
+		} else {
+			pub = pub.Add(c[0])
+		}
+	}
+	for _, id := range party.ids {
+		var xi *PubKey
+		for _, c := range all {
+			if xi == nil {
+				xi = VssCommitmentAt(c, id)
+			} else {
+				xi = xi.Add(VssCommitmentAt(c, id))
+			}
+		}
+		if id == party.id {
+			if !pointEqual(xi, pointBaseMult(x)) {
+				return nil, fmt.Errorf("mpc.threshold.share.mismatch")
+			}
+			continue
+		}
+		party.peers[id].share = xi
+	}
+	party.share = x
+	party.pub = pub
+	return pub, nil
+}
+
+// weightedShare -- returns λj*Xj of the signer j.
+func (party *EcdsaThresholdParty) weightedShare(id uint32, signers []uint32) (*PubKey, error) {
+	lambda, err := LagrangeCoefficient(id, signers)
+	if err != nil {
+		return nil, err
+	}
+	return pointMult(party.peers[id].share, lambda), nil
+}
+
+// onCurve -- returns whether all the points are on the curve.
+func (party *EcdsaThresholdParty) onCurve(points ...*PubKey) bool {
+	for _, p := range points {
+		if p == nil || p.X == nil || p.Y == nil || !party.curve.IsOnCurve(p.X, p.Y) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkFrom -- checks the messages are from every party of expected except this party,
+// the message of this party is allowed to make broadcasting easy.
+func (party *EcdsaThresholdParty) checkFrom(expected []uint32, froms []uint32) error {
+	seen := make(map[uint32]bool)
+	for _, from := range froms {
+		if !thresholdContains(expected, from) {
+			return fmt.Errorf("mpc.threshold.party[%v].unexpected", from)
+		}
+		if seen[from] {
+			return fmt.Errorf("mpc.threshold.party[%v].duplicate", from)
+		}
+		seen[from] = true
+	}
+	for _, id := range expected {
+		if id != party.id && !seen[id] {
+			return fmt.Errorf("mpc.threshold.party[%v].missing", id)
+		}
+	}
+	return nil
+}
+
+func (ps *ecdsaThresholdPresign) close() {
+	for _, v := range []*big.Int{ps.w, ps.k, ps.gamma, ps.delta, ps.sigma} {
+		if v != nil {
+			v.SetInt64(0)
+		}
+	}
+	for _, m := range []map[uint32]*big.Int{ps.betas, ps.nus} {
+		for _, v := range m {
+			v.SetInt64(0)
+		}
+	}
+}
+
+// EcdsaThresholdPresignature -- the result of the presigning, one per message.
+type EcdsaThresholdPresignature struct {
+	party *EcdsaThresholdParty
+	id    uint32
+	N     *big.Int
+	pub   *PubKey
+	R     *PubKey
+	k     *big.Int
+	sigma *big.Int
+	rbars map[uint32]*PubKey
+	ss    map[uint32]*PubKey
+}
+
+// Sign -- returns the partial signature si = m*ki + r*σi of the hash.
+// The presignature is consumed, signing two messages with it would leak the key share.
+// The presignature of the party which dealt a reshare or is closed can not sign.
+func (presig *EcdsaThresholdPresignature) Sign(hash []byte) (*big.Int, error) {
+	if presig.k == nil {
+		return nil, fmt.Errorf("mpc.threshold.presignature.used")
+	}
+	if presig.party.share == nil || presig.party.reshared {
+		return nil, fmt.Errorf("mpc.threshold.party.reshared")
+	}
+	N := presig.N
+	m := xecdsa.HashToInt(presig.pub.Curve, hash)
+	r := new(big.Int).Mod(presig.R.X, N)
+
+	s := new(big.Int).Mul(m, presig.k)
+	s.Add(s, new(big.Int).Mul(r, presig.sigma))
+	s.Mod(s, N)
+
+	presig.k.SetInt64(0)
+	presig.sigma.SetInt64(0)
+	presig.k = nil
+	presig.sigma = nil
+	return s, nil
+}
+
+// Combine -- checks the partial signatures keyed by the signer id and combines them.
+// Return the low-S DER signature of the shared PubKey.
+func (presig *EcdsaThresholdPresignature) Combine(hash []byte, partials map[uint32]*big.Int) ([]byte, error) {
+	N := presig.N
+	m := xecdsa.HashToInt(presig.pub.Curve, hash)
+	r := new(big.Int).Mod(presig.R.X, N)
+
+	for id := range partials {
+		if _, ok := presig.rbars[id]; !ok {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].unexpected", id)
+		}
+	}
+	s := new(big.Int)
+	for id, rbar := range presig.rbars {
+		si, ok := partials[id]
+		if !ok {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].missing", id)
+		}
+		if si == nil || si.Sign() < 0 || si.Cmp(N) >= 0 {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].partial.invalid", id)
+		}
+		// si*R == m*R̄i + r*Si
+		left := pointMult(presig.R, si)
+		right := pointMult(rbar, m).Add(pointMult(presig.ss[id], r))
+		if !pointEqual(left, right) {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].partial.invalid", id)
+		}
+		s.Add(s, si)
+	}
+	s.Mod(s, N)
+	if s.Sign() == 0 {
+		return nil, fmt.Errorf("mpc.threshold.signature.s.zero")
+	}
+
+	esig := NewSignatureEcdsa()
+	esig.R = r
+	esig.S = s
+	esig.NormalizeS()
+	sig, err := esig.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	if err := EcdsaVerify(presig.pub, hash, sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// thresholdCommit -- returns sha256(blind || compressed points).
+func thresholdCommit(blind []byte, points ...*PubKey) []byte {
+	h := sha256.New()
+	h.Write(blind)
+	for _, p := range points {
+		h.Write(p.SerializeCompressed())
+	}
+	return h.Sum(nil)
+}
+
+func thresholdBlind() ([]byte, error) {
+	blind := make([]byte, thresholdBlindSize)
+	if _, err := rand.Read(blind); err != nil {
+		return nil, err
+	}
+	return blind, nil
+}
+
+func thresholdCheckIDs(ids []uint32) error {
+	seen := make(map[uint32]bool)
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			return fmt.Errorf("mpc.threshold.ids[%v].invalid", ids)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+func thresholdContains(ids []uint32, id uint32) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
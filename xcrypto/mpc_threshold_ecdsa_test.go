@@ -0,0 +1,300 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// thresholdParties -- the 2-of-3 parties shared by the tests, the keygen proofs are slow.
+var thresholdParties []*EcdsaThresholdParty
+
+func thresholdKeyGenPhase12(t *testing.T, ids []uint32, threshold int) ([]*EcdsaThresholdParty, []*EcdsaThresholdKeyGenMsg2, map[uint32]map[uint32]*big.Int) {
+	parties := make([]*EcdsaThresholdParty, len(ids))
+	for i, id := range ids {
+		party, err := NewEcdsaThresholdParty(id, ids, threshold)
+		assert.Nil(t, err)
+		parties[i] = party
+	}
+
+	// Phase 1, the Paillier keys and proofs are slow so run in parallel.
+	var wg sync.WaitGroup
+	msgs1 := make([]*EcdsaThresholdKeyGenMsg1, len(parties))
+	for i := range parties {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			msg, err := parties[i].KeyGenPhase1()
+			assert.Nil(t, err)
+			msgs1[i] = msg
+		}(i)
+	}
+	wg.Wait()
+
+	// Phase 2.
+	msgs2 := make([]*EcdsaThresholdKeyGenMsg2, len(parties))
+	shares := make(map[uint32]map[uint32]*big.Int)
+	for i, party := range parties {
+		msg, out, err := party.KeyGenPhase2(msgs1)
+		assert.Nil(t, err)
+		msgs2[i] = msg
+		for to, share := range out {
+			if shares[to] == nil {
+				shares[to] = make(map[uint32]*big.Int)
+			}
+			shares[to][party.ID()] = share
+		}
+	}
+	return parties, msgs2, shares
+}
+
+func thresholdKeyGenPhase3(t *testing.T, parties []*EcdsaThresholdParty, msgs2 []*EcdsaThresholdKeyGenMsg2, shares map[uint32]map[uint32]*big.Int) {
+	var pub *PubKey
+	for _, party := range parties {
+		got, err := party.KeyGenPhase3(msgs2, shares[party.ID()])
+		assert.Nil(t, err)
+		if pub != nil {
+			assert.Equal(t, pub, got)
+		}
+		pub = got
+	}
+}
+
+func mockThresholdParties(t *testing.T) []*EcdsaThresholdParty {
+	if thresholdParties == nil {
+		parties, msgs2, shares := thresholdKeyGenPhase12(t, []uint32{1, 2, 3}, 2)
+		thresholdKeyGenPhase3(t, parties, msgs2, shares)
+		thresholdParties = parties
+	}
+	return thresholdParties
+}
+
+func thresholdPresign(t *testing.T, signers []*EcdsaThresholdParty) ([]*EcdsaThresholdPresignature, error) {
+	ids := make([]uint32, len(signers))
+	for i, party := range signers {
+		ids[i] = party.ID()
+	}
+
+	var msgs1 []*EcdsaThresholdPresignMsg1
+	for _, party := range signers {
+		msg, err := party.PresignPhase1(ids)
+		if err != nil {
+			return nil, err
+		}
+		msgs1 = append(msgs1, msg)
+	}
+	msgs2 := make(map[uint32][]*EcdsaThresholdPresignMsg2)
+	for _, party := range signers {
+		out, err := party.PresignPhase2(msgs1)
+		if err != nil {
+			return nil, err
+		}
+		for to, msg := range out {
+			msgs2[to] = append(msgs2[to], msg)
+		}
+	}
+	var msgs3 []*EcdsaThresholdPresignMsg3
+	for _, party := range signers {
+		msg, err := party.PresignPhase3(msgs2[party.ID()])
+		if err != nil {
+			return nil, err
+		}
+		msgs3 = append(msgs3, msg)
+	}
+	var msgs4 []*EcdsaThresholdPresignMsg4
+	for _, party := range signers {
+		msg, err := party.PresignPhase4(msgs3)
+		if err != nil {
+			return nil, err
+		}
+		msgs4 = append(msgs4, msg)
+	}
+	var presigs []*EcdsaThresholdPresignature
+	for _, party := range signers {
+		presig, err := party.PresignPhase5(msgs4)
+		if err != nil {
+			return nil, err
+		}
+		presigs = append(presigs, presig)
+	}
+	return presigs, nil
+}
+
+func thresholdSign(t *testing.T, presigs []*EcdsaThresholdPresignature, hash []byte) map[uint32]*big.Int {
+	partials := make(map[uint32]*big.Int)
+	for _, presig := range presigs {
+		s, err := presig.Sign(hash)
+		assert.Nil(t, err)
+		partials[presig.id] = s
+	}
+	return partials
+}
+
+func TestMpcThresholdEcdsaKeyGen(t *testing.T) {
+	parties, msgs2, shares := thresholdKeyGenPhase12(t, []uint32{1, 2, 3}, 2)
+
+	// Party 3 deals a bad share to party 2.
+	good := shares[2][3]
+	shares[2][3] = new(big.Int).Add(good, big.NewInt(1))
+	_, err := parties[1].KeyGenPhase3(msgs2, shares[2])
+	assert.EqualError(t, err, "mpc.threshold.party[3].share.invalid")
+	shares[2][3] = good
+
+	// Party 1 opens a different commitment.
+	blind := msgs2[0].Blind
+	msgs2[0].Blind = make([]byte, thresholdBlindSize)
+	_, err = parties[1].KeyGenPhase3(msgs2, shares[2])
+	assert.EqualError(t, err, "mpc.threshold.party[1].decommit.invalid")
+	msgs2[0].Blind = blind
+
+	// Missing message.
+	_, err = parties[1].KeyGenPhase3(msgs2[:2], shares[2])
+	assert.EqualError(t, err, "mpc.threshold.party[3].missing")
+
+	thresholdKeyGenPhase3(t, parties, msgs2, shares)
+	if thresholdParties == nil {
+		thresholdParties = parties
+	}
+}
+
+func TestMpcThresholdEcdsa(t *testing.T) {
+	hash := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+	parties := mockThresholdParties(t)
+	pub := parties[0].PubKey()
+
+	signerSets := [][]*EcdsaThresholdParty{
+		{parties[0], parties[1]},
+		{parties[0], parties[2]},
+		{parties[1], parties[2]},
+		{parties[0], parties[1], parties[2]},
+	}
+	for _, signers := range signerSets {
+		presigs, err := thresholdPresign(t, signers)
+		assert.Nil(t, err)
+
+		partials := thresholdSign(t, presigs, hash)
+		var sig []byte
+		for _, presig := range presigs {
+			got, err := presig.Combine(hash, partials)
+			assert.Nil(t, err)
+			if sig != nil {
+				assert.Equal(t, sig, got)
+			}
+			sig = got
+		}
+		assert.Nil(t, EcdsaVerify(pub, hash, sig))
+
+		// Low-S.
+		esig := NewSignatureEcdsa()
+		assert.Nil(t, esig.Deserialize(sig))
+		assert.True(t, esig.S.Cmp(new(big.Int).Rsh(pub.Curve.Params().N, 1)) <= 0)
+
+		// Presignature is single-use.
+		_, err = presigs[0].Sign(hash)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestMpcThresholdEcdsaError(t *testing.T) {
+	hash := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+
+	// Params.
+	{
+		_, err := NewEcdsaThresholdParty(1, []uint32{1, 2, 3}, 1)
+		assert.NotNil(t, err)
+		_, err = NewEcdsaThresholdParty(1, []uint32{1, 2, 3}, 4)
+		assert.NotNil(t, err)
+		_, err = NewEcdsaThresholdParty(1, []uint32{1, 2, 2}, 2)
+		assert.NotNil(t, err)
+		_, err = NewEcdsaThresholdParty(4, []uint32{1, 2, 3}, 2)
+		assert.NotNil(t, err)
+		party, err := NewEcdsaThresholdParty(1, []uint32{1, 2, 3}, 2)
+		assert.Nil(t, err)
+		_, err = party.PresignPhase1([]uint32{1, 2})
+		assert.NotNil(t, err)
+	}
+
+	// Bad Paillier modulus proof names the party.
+	{
+		ids := []uint32{1, 2}
+		party1, err := NewEcdsaThresholdParty(1, ids, 2)
+		assert.Nil(t, err)
+		party2, err := NewEcdsaThresholdParty(2, ids, 2)
+		assert.Nil(t, err)
+		msg1, err := party1.KeyGenPhase1()
+		assert.Nil(t, err)
+		msg2, err := party2.KeyGenPhase1()
+		assert.Nil(t, err)
+		msg2.EncPubProof = msg1.EncPubProof
+		_, _, err = party1.KeyGenPhase2([]*EcdsaThresholdKeyGenMsg1{msg1, msg2})
+		assert.EqualError(t, err, "mpc.threshold.party[2].paillier.proof.invalid")
+	}
+
+	parties := mockThresholdParties(t)
+
+	// Signers.
+	{
+		_, err := parties[0].PresignPhase1([]uint32{1})
+		assert.NotNil(t, err)
+		_, err = parties[0].PresignPhase1([]uint32{2, 3})
+		assert.EqualError(t, err, "mpc.threshold.id[1].not.in.signers[[2 3]]")
+		_, err = parties[0].PresignPhase1([]uint32{1, 4})
+		assert.NotNil(t, err)
+	}
+
+	// Bad partial names the party.
+	{
+		presigs, err := thresholdPresign(t, parties[:2])
+		assert.Nil(t, err)
+		partials := thresholdSign(t, presigs, hash)
+		partials[2].Add(partials[2], big.NewInt(1))
+		_, err = presigs[0].Combine(hash, partials)
+		assert.EqualError(t, err, "mpc.threshold.party[2].partial.invalid")
+		delete(partials, 2)
+		_, err = presigs[0].Combine(hash, partials)
+		assert.EqualError(t, err, "mpc.threshold.party[2].missing")
+		partials[3] = big.NewInt(1)
+		_, err = presigs[0].Combine(hash, partials)
+		assert.EqualError(t, err, "mpc.threshold.party[3].unexpected")
+	}
+
+	// Bad MtA response names the party.
+	{
+		ids := []uint32{1, 2}
+		msg1, err := parties[0].PresignPhase1(ids)
+		assert.Nil(t, err)
+		msg2, err := parties[1].PresignPhase1(ids)
+		assert.Nil(t, err)
+		msgs1 := []*EcdsaThresholdPresignMsg1{msg1, msg2}
+		_, err = parties[0].PresignPhase2(msgs1)
+		assert.Nil(t, err)
+		out, err := parties[1].PresignPhase2(msgs1)
+		assert.Nil(t, err)
+		bad := *out[1]
+		bad.CW = bad.CGamma
+		_, err = parties[0].PresignPhase3([]*EcdsaThresholdPresignMsg2{&bad})
+		assert.EqualError(t, err, "mpc.threshold.party[2].mtawc.proof.invalid")
+	}
+
+	// Bad range proof names the party.
+	{
+		ids := []uint32{1, 2}
+		msg1, err := parties[0].PresignPhase1(ids)
+		assert.Nil(t, err)
+		msg2, err := parties[1].PresignPhase1(ids)
+		assert.Nil(t, err)
+		msg2.EncK, err = parties[1].encprv.PubKey().Encrypt(big.NewInt(1))
+		assert.Nil(t, err)
+		_, err = parties[0].PresignPhase2([]*EcdsaThresholdPresignMsg1{msg1, msg2})
+		assert.EqualError(t, err, "mpc.threshold.party[2].range.proof.invalid")
+		_, err = parties[0].PresignPhase2([]*EcdsaThresholdPresignMsg1{msg1})
+		assert.EqualError(t, err, "mpc.threshold.party[2].missing")
+	}
+}
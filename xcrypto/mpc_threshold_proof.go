@@ -0,0 +1,503 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/keyfuse/tokucore/xcrypto/paillier"
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+)
+
+// The zero-knowledge proofs used by the threshold ECDSA protocol, following
+// Gennaro-Goldfeder "Fast Multiparty Threshold ECDSA with Fast Trustless Setup"
+// (GG18) Appendix A. The range proofs are made against the verifier's
+// ring-Pedersen parameters (Ñ, h1, h2), where h2 = h1^α mod Ñ.
+
+const (
+	dlnProofIterations = 128
+)
+
+var (
+	bigOne = big.NewInt(1)
+)
+
+// RingPedersen -- the verifier's commitment parameters used by the range proofs.
+type RingPedersen struct {
+	N  *big.Int
+	H1 *big.Int
+	H2 *big.Int
+}
+
+// ringPedersenSecret -- the trapdoor of the RingPedersen, only used to prove
+// the parameters are well-formed.
+type ringPedersenSecret struct {
+	alpha *big.Int
+	beta  *big.Int
+	phi   *big.Int
+}
+
+// generateRingPedersen -- generates the parameters with a bits modulus.
+func generateRingPedersen(bits int) (*RingPedersen, *ringPedersenSecret, error) {
+	for {
+		p, err := rand.Prime(rand.Reader, bits/2)
+		if err != nil {
+			return nil, nil, err
+		}
+		q, err := rand.Prime(rand.Reader, bits/2)
+		if err != nil {
+			return nil, nil, err
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+		n := new(big.Int).Mul(p, q)
+		phi := new(big.Int).Mul(new(big.Int).Sub(p, bigOne), new(big.Int).Sub(q, bigOne))
+
+		f, err := randomUnit(n)
+		if err != nil {
+			return nil, nil, err
+		}
+		h1 := new(big.Int).Exp(f, big.NewInt(2), n)
+		alpha, err := rand.Int(rand.Reader, phi)
+		if err != nil {
+			return nil, nil, err
+		}
+		beta := new(big.Int).ModInverse(alpha, phi)
+		if beta == nil {
+			continue
+		}
+		h2 := new(big.Int).Exp(h1, alpha, n)
+		return &RingPedersen{N: n, H1: h1, H2: h2}, &ringPedersenSecret{alpha: alpha, beta: beta, phi: phi}, nil
+	}
+}
+
+// commit -- returns h1^x * h2^r mod Ñ.
+func (rp *RingPedersen) commit(x *big.Int, r *big.Int) *big.Int {
+	a := new(big.Int).Exp(rp.H1, x, rp.N)
+	b := new(big.Int).Exp(rp.H2, r, rp.N)
+	return a.Mul(a, b).Mod(a, rp.N)
+}
+
+// validate -- checks the parameters are in range and distinct.
+func (rp *RingPedersen) validate() error {
+	if rp == nil || rp.N == nil || rp.H1 == nil || rp.H2 == nil {
+		return fmt.Errorf("ring.pedersen.empty")
+	}
+	if rp.N.BitLen() < paillier.MinKeyBits || rp.N.Bit(0) == 0 {
+		return fmt.Errorf("ring.pedersen.modulus.invalid")
+	}
+	if !isUnit(rp.H1, rp.N) || !isUnit(rp.H2, rp.N) || rp.H1.Cmp(bigOne) == 0 || rp.H1.Cmp(rp.H2) == 0 {
+		return fmt.Errorf("ring.pedersen.generator.invalid")
+	}
+	return nil
+}
+
+// DlnProof -- proves knowledge of x such that h2 = h1^x mod Ñ, with binary challenges.
+type DlnProof struct {
+	Alpha []*big.Int
+	T     []*big.Int
+}
+
+// newDlnProof -- proves h2 = h1^x mod n.
+func newDlnProof(h1, h2, x, phi, n *big.Int) (*DlnProof, error) {
+	proof := &DlnProof{
+		Alpha: make([]*big.Int, dlnProofIterations),
+		T:     make([]*big.Int, dlnProofIterations),
+	}
+	a := make([]*big.Int, dlnProofIterations)
+	for i := range a {
+		ai, err := rand.Int(rand.Reader, phi)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = ai
+		proof.Alpha[i] = new(big.Int).Exp(h1, ai, n)
+	}
+	c := dlnChallenge(h1, h2, n, proof.Alpha)
+	for i := range a {
+		t := new(big.Int).Set(a[i])
+		if c.Bit(i) == 1 {
+			t.Add(t, x)
+		}
+		proof.T[i] = t.Mod(t, phi)
+	}
+	return proof, nil
+}
+
+// verify -- checks h1^t == alpha * h2^c mod n for every iteration.
+func (proof *DlnProof) verify(h1, h2, n *big.Int) bool {
+	if proof == nil || len(proof.Alpha) != dlnProofIterations || len(proof.T) != dlnProofIterations {
+		return false
+	}
+	for i := 0; i < dlnProofIterations; i++ {
+		if !isUnit(proof.Alpha[i], n) || proof.T[i] == nil || proof.T[i].Sign() < 0 || proof.T[i].Cmp(n) >= 0 {
+			return false
+		}
+	}
+	c := dlnChallenge(h1, h2, n, proof.Alpha)
+	for i := 0; i < dlnProofIterations; i++ {
+		left := new(big.Int).Exp(h1, proof.T[i], n)
+		right := new(big.Int).Set(proof.Alpha[i])
+		if c.Bit(i) == 1 {
+			right.Mul(right, h2).Mod(right, n)
+		}
+		if left.Cmp(right) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func dlnChallenge(h1, h2, n *big.Int, alpha []*big.Int) *big.Int {
+	values := append([]*big.Int{h1, h2, n}, alpha...)
+	return new(big.Int).SetBytes(hashInts("dln", values...))
+}
+
+// RingPedersenProof -- proves both h2 = h1^α and h1 = h2^β, so h1 and h2 generate the same group.
+type RingPedersenProof struct {
+	Proof1 *DlnProof
+	Proof2 *DlnProof
+}
+
+// proveRingPedersen -- creates the RingPedersenProof.
+func proveRingPedersen(rp *RingPedersen, secret *ringPedersenSecret) (*RingPedersenProof, error) {
+	p1, err := newDlnProof(rp.H1, rp.H2, secret.alpha, secret.phi, rp.N)
+	if err != nil {
+		return nil, err
+	}
+	p2, err := newDlnProof(rp.H2, rp.H1, secret.beta, secret.phi, rp.N)
+	if err != nil {
+		return nil, err
+	}
+	return &RingPedersenProof{Proof1: p1, Proof2: p2}, nil
+}
+
+// Verify -- checks the parameters and the proof.
+func (proof *RingPedersenProof) Verify(rp *RingPedersen) bool {
+	if proof == nil || rp.validate() != nil {
+		return false
+	}
+	return proof.Proof1.verify(rp.H1, rp.H2, rp.N) && proof.Proof2.verify(rp.H2, rp.H1, rp.N)
+}
+
+// SchnorrProof -- proves knowledge of x such that X = x*G, bound to the party id and a tag.
+type SchnorrProof struct {
+	A *PubKey
+	Z *big.Int
+}
+
+// newSchnorrProof -- proves the knowledge of x.
+func newSchnorrProof(tag string, id uint32, x *big.Int) (*SchnorrProof, error) {
+	N := secp256k1.SECP256K1().Params().N
+	k, err := randModN()
+	if err != nil {
+		return nil, err
+	}
+	X := pointBaseMult(x)
+	A := pointBaseMult(k)
+	e := schnorrProofChallenge(tag, id, X, A)
+	z := new(big.Int).Mul(e, x)
+	z.Add(z, k).Mod(z, N)
+	k.SetInt64(0)
+	return &SchnorrProof{A: A, Z: z}, nil
+}
+
+// Verify -- checks z*G == A + e*X.
+func (proof *SchnorrProof) Verify(tag string, id uint32, X *PubKey) bool {
+	N := secp256k1.SECP256K1().Params().N
+	if proof == nil || proof.A == nil || proof.Z == nil || proof.Z.Sign() < 0 || proof.Z.Cmp(N) >= 0 {
+		return false
+	}
+	e := schnorrProofChallenge(tag, id, X, proof.A)
+	return pointEqual(pointBaseMult(proof.Z), proof.A.Add(pointMult(X, e)))
+}
+
+func schnorrProofChallenge(tag string, id uint32, X *PubKey, A *PubKey) *big.Int {
+	N := secp256k1.SECP256K1().Params().N
+	h := hashInts(tag, big.NewInt(int64(id)), X.X, X.Y, A.X, A.Y)
+	return new(big.Int).Mod(new(big.Int).SetBytes(h), N)
+}
+
+// MtaRangeProof -- the initiator (Alice) proof that the plaintext m of
+// c = Γ^m * r^N mod N² is in the range [0, q³], GG18 A.1.
+type MtaRangeProof struct {
+	Z  *big.Int
+	U  *big.Int
+	W  *big.Int
+	S  *big.Int
+	S1 *big.Int
+	S2 *big.Int
+}
+
+// newMtaRangeProof -- creates the proof for the verifier's ring-Pedersen.
+func newMtaRangeProof(pk *paillier.PubKey, c *big.Int, m *big.Int, r *big.Int, rp *RingPedersen) (*MtaRangeProof, error) {
+	q := secp256k1.SECP256K1().Params().N
+	q3 := new(big.Int).Exp(q, big.NewInt(3), nil)
+	qN := new(big.Int).Mul(q, rp.N)
+	q3N := new(big.Int).Mul(q3, rp.N)
+
+	alpha, err := rand.Int(rand.Reader, q3)
+	if err != nil {
+		return nil, err
+	}
+	beta, err := randomUnit(pk.N)
+	if err != nil {
+		return nil, err
+	}
+	gamma, err := rand.Int(rand.Reader, q3N)
+	if err != nil {
+		return nil, err
+	}
+	rho, err := rand.Int(rand.Reader, qN)
+	if err != nil {
+		return nil, err
+	}
+
+	z := rp.commit(m, rho)
+	u, err := pk.EncryptWithNonce(alpha, beta)
+	if err != nil {
+		return nil, err
+	}
+	w := rp.commit(alpha, gamma)
+	e := mtaChallenge("mta.range", q, pk.N, c, z, u, w)
+
+	s := new(big.Int).Exp(r, e, pk.N)
+	s.Mul(s, beta).Mod(s, pk.N)
+	s1 := new(big.Int).Mul(e, m)
+	s1.Add(s1, alpha)
+	s2 := new(big.Int).Mul(e, rho)
+	s2.Add(s2, gamma)
+	return &MtaRangeProof{Z: z, U: u, W: w, S: s, S1: s1, S2: s2}, nil
+}
+
+// Verify -- checks the proof against the ciphertext c.
+func (proof *MtaRangeProof) Verify(pk *paillier.PubKey, c *big.Int, rp *RingPedersen) bool {
+	q := secp256k1.SECP256K1().Params().N
+	q3 := new(big.Int).Exp(q, big.NewInt(3), nil)
+
+	if proof == nil || proof.S1 == nil || proof.S2 == nil || proof.S1.Sign() < 0 || proof.S2.Sign() < 0 {
+		return false
+	}
+	if proof.S1.Cmp(q3) > 0 {
+		return false
+	}
+	if !isUnit(proof.Z, rp.N) || !isUnit(proof.W, rp.N) || !isUnit(proof.S, pk.N) || pk.ValidCiphertext(proof.U) != nil || pk.ValidCiphertext(c) != nil {
+		return false
+	}
+	e := mtaChallenge("mta.range", q, pk.N, c, proof.Z, proof.U, proof.W)
+
+	// u == Γ^s1 * s^N * c^-e mod N²
+	left, err := pk.EncryptWithNonce(new(big.Int).Mod(proof.S1, pk.N), proof.S)
+	if err != nil {
+		return false
+	}
+	ce := new(big.Int).Exp(c, e, pk.NN)
+	right := new(big.Int).Mul(proof.U, ce)
+	right.Mod(right, pk.NN)
+	if left.Cmp(right) != 0 {
+		return false
+	}
+
+	// h1^s1 * h2^s2 == w * z^e mod Ñ
+	left = rp.commit(proof.S1, proof.S2)
+	right = new(big.Int).Exp(proof.Z, e, rp.N)
+	right.Mul(right, proof.W).Mod(right, rp.N)
+	return left.Cmp(right) == 0
+}
+
+// MtaRespProof -- the respondent (Bob) proof that c2 = c1^x * Γ^y * r^N mod N²
+// with x in [0, q³] and y in [0, q⁷], GG18 A.2. With X set it also proves
+// X = x*G, GG18 A.3.
+type MtaRespProof struct {
+	Z      *big.Int
+	ZPrime *big.Int
+	T      *big.Int
+	V      *big.Int
+	W      *big.Int
+	U      *PubKey
+	S      *big.Int
+	S1     *big.Int
+	S2     *big.Int
+	T1     *big.Int
+	T2     *big.Int
+}
+
+// newMtaRespProof -- creates the proof for the verifier's (Alice) ring-Pedersen.
+func newMtaRespProof(pk *paillier.PubKey, c1, c2, x, y, r *big.Int, rp *RingPedersen, withCheck bool) (*MtaRespProof, error) {
+	q := secp256k1.SECP256K1().Params().N
+	q3 := new(big.Int).Exp(q, big.NewInt(3), nil)
+	q7 := new(big.Int).Exp(q, big.NewInt(7), nil)
+	qN := new(big.Int).Mul(q, rp.N)
+	q3N := new(big.Int).Mul(q3, rp.N)
+
+	alpha, err := rand.Int(rand.Reader, q3)
+	if err != nil {
+		return nil, err
+	}
+	rho, err := rand.Int(rand.Reader, qN)
+	if err != nil {
+		return nil, err
+	}
+	rhoPrime, err := rand.Int(rand.Reader, q3N)
+	if err != nil {
+		return nil, err
+	}
+	sigma, err := rand.Int(rand.Reader, qN)
+	if err != nil {
+		return nil, err
+	}
+	beta, err := randomUnit(pk.N)
+	if err != nil {
+		return nil, err
+	}
+	gamma, err := rand.Int(rand.Reader, q7)
+	if err != nil {
+		return nil, err
+	}
+	tau, err := rand.Int(rand.Reader, q3N)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &MtaRespProof{}
+	proof.Z = rp.commit(x, rho)
+	proof.ZPrime = rp.commit(alpha, rhoPrime)
+	proof.T = rp.commit(y, sigma)
+	// v = c1^α * Γ^γ * β^N mod N²
+	v, err := pk.EncryptWithNonce(gamma, beta)
+	if err != nil {
+		return nil, err
+	}
+	c1a := new(big.Int).Exp(c1, alpha, pk.NN)
+	proof.V = v.Mul(v, c1a).Mod(v, pk.NN)
+	proof.W = rp.commit(gamma, tau)
+	if withCheck {
+		proof.U = pointBaseMult(alpha)
+	}
+	e := proof.challenge(q, pk.N, c1, c2)
+
+	s := new(big.Int).Exp(r, e, pk.N)
+	proof.S = s.Mul(s, beta).Mod(s, pk.N)
+	proof.S1 = new(big.Int).Add(new(big.Int).Mul(e, x), alpha)
+	proof.S2 = new(big.Int).Add(new(big.Int).Mul(e, rho), rhoPrime)
+	proof.T1 = new(big.Int).Add(new(big.Int).Mul(e, y), gamma)
+	proof.T2 = new(big.Int).Add(new(big.Int).Mul(e, sigma), tau)
+	return proof, nil
+}
+
+// Verify -- checks the proof, X must be set if the proof was created with check.
+func (proof *MtaRespProof) Verify(pk *paillier.PubKey, c1, c2 *big.Int, rp *RingPedersen, X *PubKey) bool {
+	q := secp256k1.SECP256K1().Params().N
+	q3 := new(big.Int).Exp(q, big.NewInt(3), nil)
+	q7 := new(big.Int).Exp(q, big.NewInt(7), nil)
+
+	if proof == nil || proof.S1 == nil || proof.S2 == nil || proof.T1 == nil || proof.T2 == nil {
+		return false
+	}
+	if proof.S1.Sign() < 0 || proof.S2.Sign() < 0 || proof.T1.Sign() < 0 || proof.T2.Sign() < 0 {
+		return false
+	}
+	if proof.S1.Cmp(q3) > 0 || proof.T1.Cmp(q7) > 0 {
+		return false
+	}
+	if (X == nil) != (proof.U == nil) {
+		return false
+	}
+	for _, v := range []*big.Int{proof.Z, proof.ZPrime, proof.T, proof.W} {
+		if !isUnit(v, rp.N) {
+			return false
+		}
+	}
+	if !isUnit(proof.S, pk.N) || pk.ValidCiphertext(proof.V) != nil || pk.ValidCiphertext(c1) != nil || pk.ValidCiphertext(c2) != nil {
+		return false
+	}
+	e := proof.challenge(q, pk.N, c1, c2)
+
+	// s1*G == e*X + u
+	if X != nil {
+		if !pointEqual(pointBaseMult(new(big.Int).Mod(proof.S1, q)), pointMult(X, e).Add(proof.U)) {
+			return false
+		}
+	}
+
+	// h1^s1 * h2^s2 == z^e * z' mod Ñ
+	left := rp.commit(proof.S1, proof.S2)
+	right := new(big.Int).Exp(proof.Z, e, rp.N)
+	right.Mul(right, proof.ZPrime).Mod(right, rp.N)
+	if left.Cmp(right) != 0 {
+		return false
+	}
+
+	// h1^t1 * h2^t2 == t^e * w mod Ñ
+	left = rp.commit(proof.T1, proof.T2)
+	right = new(big.Int).Exp(proof.T, e, rp.N)
+	right.Mul(right, proof.W).Mod(right, rp.N)
+	if left.Cmp(right) != 0 {
+		return false
+	}
+
+	// c1^s1 * s^N * Γ^t1 == c2^e * v mod N²
+	left, err := pk.EncryptWithNonce(new(big.Int).Mod(proof.T1, pk.N), proof.S)
+	if err != nil {
+		return false
+	}
+	left.Mul(left, new(big.Int).Exp(c1, proof.S1, pk.NN)).Mod(left, pk.NN)
+	right = new(big.Int).Exp(c2, e, pk.NN)
+	right.Mul(right, proof.V).Mod(right, pk.NN)
+	return left.Cmp(right) == 0
+}
+
+func (proof *MtaRespProof) challenge(q, n, c1, c2 *big.Int) *big.Int {
+	values := []*big.Int{n, c1, c2, proof.Z, proof.ZPrime, proof.T, proof.V, proof.W}
+	if proof.U != nil {
+		values = append(values, proof.U.X, proof.U.Y)
+	}
+	return mtaChallenge("mta.resp", q, values...)
+}
+
+func mtaChallenge(tag string, q *big.Int, values ...*big.Int) *big.Int {
+	h := hashInts(tag, values...)
+	return new(big.Int).Mod(new(big.Int).SetBytes(h), q)
+}
+
+// hashInts -- returns sha256(tag || len(v1) || v1 || ...).
+func hashInts(tag string, values ...*big.Int) []byte {
+	h := sha256.New()
+	h.Write([]byte(tag))
+	for _, v := range values {
+		b := v.Bytes()
+		var l [4]byte
+		l[0], l[1], l[2], l[3] = byte(len(b)>>24), byte(len(b)>>16), byte(len(b)>>8), byte(len(b))
+		h.Write(l[:])
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+// randomUnit -- returns a random element of Z*n.
+func randomUnit(n *big.Int) (*big.Int, error) {
+	for {
+		r, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return nil, err
+		}
+		if isUnit(r, n) {
+			return r, nil
+		}
+	}
+}
+
+// isUnit -- returns whether v is in [1, n) and co-prime with n.
+func isUnit(v *big.Int, n *big.Int) bool {
+	if v == nil || v.Sign() <= 0 || v.Cmp(n) >= 0 {
+		return false
+	}
+	return new(big.Int).GCD(nil, nil, v, n).Cmp(bigOne) == 0
+}
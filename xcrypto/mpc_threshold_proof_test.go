@@ -0,0 +1,94 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/keyfuse/tokucore/xcrypto/paillier"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingPedersenProof(t *testing.T) {
+	rp, secret, err := generateRingPedersen(bitlen)
+	assert.Nil(t, err)
+	proof, err := proveRingPedersen(rp, secret)
+	assert.Nil(t, err)
+	assert.True(t, proof.Verify(rp))
+
+	// Wrong h2.
+	bad := &RingPedersen{N: rp.N, H1: rp.H1, H2: new(big.Int).Exp(rp.H2, big.NewInt(2), rp.N)}
+	assert.False(t, proof.Verify(bad))
+	assert.False(t, (*RingPedersenProof)(nil).Verify(rp))
+	proof.Proof1.T[0].Add(proof.Proof1.T[0], big.NewInt(1))
+	assert.False(t, proof.Verify(rp))
+}
+
+func TestSchnorrProof(t *testing.T) {
+	x, err := randModN()
+	assert.Nil(t, err)
+	X := pointBaseMult(x)
+	proof, err := newSchnorrProof("test", 1, x)
+	assert.Nil(t, err)
+	assert.True(t, proof.Verify("test", 1, X))
+	assert.False(t, proof.Verify("test", 2, X))
+	assert.False(t, proof.Verify("tset", 1, X))
+	assert.False(t, proof.Verify("test", 1, pointBaseMult(big.NewInt(2))))
+	assert.False(t, (*SchnorrProof)(nil).Verify("test", 1, X))
+}
+
+func TestMtaProof(t *testing.T) {
+	N := PrvKeyFromBytes([]byte{0x01}).PubKey().Curve.Params().N
+	pk, sk, err := paillier.GenerateKeyPair(bitlen)
+	assert.Nil(t, err)
+	rp, _, err := generateRingPedersen(bitlen)
+	assert.Nil(t, err)
+
+	// Alice: c1 = Enc(a).
+	a, _ := randModN()
+	r1, err := pk.RandomNonce()
+	assert.Nil(t, err)
+	c1, err := pk.EncryptWithNonce(a, r1)
+	assert.Nil(t, err)
+	rangeProof, err := newMtaRangeProof(pk, c1, a, r1, rp)
+	assert.Nil(t, err)
+	assert.True(t, rangeProof.Verify(pk, c1, rp))
+	c1Bad, _ := pk.Encrypt(a)
+	assert.False(t, rangeProof.Verify(pk, c1Bad, rp))
+
+	// Bob: c2 = c1^b * Enc(y).
+	b, _ := randModN()
+	y := new(big.Int).Exp(N, big.NewInt(5), nil)
+	y.Sub(y, big.NewInt(1))
+	r2, err := pk.RandomNonce()
+	assert.Nil(t, err)
+	c2, err := pk.EncryptWithNonce(y, r2)
+	assert.Nil(t, err)
+	c1b, err := pk.MultPlaintext(c1, b)
+	assert.Nil(t, err)
+	c2, err = pk.Add(c1b, c2)
+	assert.Nil(t, err)
+
+	respProof, err := newMtaRespProof(pk, c1, c2, b, y, r2, rp, false)
+	assert.Nil(t, err)
+	assert.True(t, respProof.Verify(pk, c1, c2, rp, nil))
+	assert.False(t, respProof.Verify(pk, c1, c1b, rp, nil))
+
+	B := pointBaseMult(b)
+	checkProof, err := newMtaRespProof(pk, c1, c2, b, y, r2, rp, true)
+	assert.Nil(t, err)
+	assert.True(t, checkProof.Verify(pk, c1, c2, rp, B))
+	assert.False(t, checkProof.Verify(pk, c1, c2, rp, pointBaseMult(a)))
+	assert.False(t, checkProof.Verify(pk, c1, c2, rp, nil))
+
+	// Alice gets a*b + y.
+	alpha, err := sk.Decrypt(c2)
+	assert.Nil(t, err)
+	want := new(big.Int).Mul(a, b)
+	want.Add(want, y)
+	assert.Equal(t, want, alpha)
+}
@@ -0,0 +1,212 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/keyfuse/tokucore/xcrypto/paillier"
+)
+
+// The reshare moves the shared key of a t-of-n EcdsaThresholdParty set to a
+// new t'-of-n' set, the shared PubKey is not changed.
+//
+// A quorum of at least t old parties deals a Feldman VSS of wi = λi*xi with
+// threshold t' to the new parties, and the new share is x'j = sum(fi(j)).
+// The first commitment of the dealer i must be λi*Xi, so every dealing is
+// checked against the old public shares and sum(λi*Xi) = X.
+// The new parties generate fresh Paillier keys and ring-Pedersen parameters.
+//
+// The refresh is the reshare to the same ids and threshold: the new shares
+// lie on a new random polynomial, so the old shares can not be mixed with them.
+//
+// The dealer stops presigning and signing with the old share once it deals.
+// After the new parties confirm the shared PubKey, every old party must run
+// ReshareRetire to wipe the old share; ReshareAbort resumes the old set if the
+// reshare fails.
+
+// EcdsaThresholdReshareMsg1 -- the ResharePhase1 broadcast of the new party.
+type EcdsaThresholdReshareMsg1 struct {
+	From              uint32
+	EncPub            *paillier.PubKey
+	EncPubProof       *paillier.ModulusProof
+	RingPedersen      *RingPedersen
+	RingPedersenProof *RingPedersenProof
+}
+
+// EcdsaThresholdReshareDeal -- the ReshareDeal broadcast of the old party.
+type EcdsaThresholdReshareDeal struct {
+	From        uint32
+	Commitments []*PubKey
+}
+
+// PubShares -- returns the public key shares Xi = xi*G of all the parties, keyed by the party id.
+func (party *EcdsaThresholdParty) PubShares() map[uint32]*PubKey {
+	if party.share == nil {
+		return nil
+	}
+	shares := map[uint32]*PubKey{party.id: pointBaseMult(party.share)}
+	for id, peer := range party.peers {
+		shares[id] = peer.share
+	}
+	return shares
+}
+
+// ReshareDeal -- deals the weighted key share of this old party to the new ids with the new threshold,
+// dealers is the quorum of the old parties which deal.
+// Return the broadcast commitments and the shares to send to each new party.
+// The party can not presign or sign from now on, see ReshareRetire and ReshareAbort.
+func (party *EcdsaThresholdParty) ReshareDeal(dealers []uint32, ids []uint32, threshold int) (*EcdsaThresholdReshareDeal, map[uint32]*big.Int, error) {
+	if party.share == nil {
+		return nil, nil, fmt.Errorf("mpc.threshold.keygen.unfinished")
+	}
+	if len(dealers) < party.threshold {
+		return nil, nil, fmt.Errorf("mpc.threshold.dealers[%v].less.than.threshold[%v]", len(dealers), party.threshold)
+	}
+	for _, id := range dealers {
+		if !thresholdContains(party.ids, id) {
+			return nil, nil, fmt.Errorf("mpc.threshold.dealer[%v].unknown", id)
+		}
+	}
+	if threshold < 2 || threshold > len(ids) {
+		return nil, nil, fmt.Errorf("mpc.threshold[%v].of[%v].invalid", threshold, len(ids))
+	}
+	if err := thresholdCheckIDs(ids); err != nil {
+		return nil, nil, err
+	}
+	lambda, err := LagrangeCoefficient(party.id, dealers)
+	if err != nil {
+		return nil, nil, err
+	}
+	w := lambda.Mul(lambda, party.share)
+	w.Mod(w, party.N)
+
+	poly, err := NewVssPolynomial(w, threshold)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer poly.Close()
+	shares := make(map[uint32]*big.Int)
+	for _, id := range ids {
+		shares[id] = poly.Share(id)
+	}
+	if party.presign != nil {
+		party.presign.close()
+		party.presign = nil
+	}
+	party.reshared = true
+	return &EcdsaThresholdReshareDeal{
+		From:        party.id,
+		Commitments: poly.Commitments(),
+	}, shares, nil
+}
+
+// ReshareRetire -- wipes the old key share after the new parties confirm the reshare,
+// the old party and its presignatures are useless from now on.
+func (party *EcdsaThresholdParty) ReshareRetire() {
+	party.Close()
+	party.reshared = true
+}
+
+// ReshareAbort -- resumes the old party after the reshare failed, the new parties must discard the dealing.
+func (party *EcdsaThresholdParty) ReshareAbort() {
+	party.reshared = false
+}
+
+// ResharePhase1 -- generates the Paillier key and ring-Pedersen parameters of this new party.
+// Return the broadcast message.
+func (party *EcdsaThresholdParty) ResharePhase1() (*EcdsaThresholdReshareMsg1, error) {
+	if party.share != nil || party.poly != nil {
+		return nil, fmt.Errorf("mpc.threshold.keygen.started")
+	}
+	encProof, rpProof, err := party.generateKeys()
+	if err != nil {
+		return nil, err
+	}
+	return &EcdsaThresholdReshareMsg1{
+		From:              party.id,
+		EncPub:            party.encprv.PubKey(),
+		EncPubProof:       encProof,
+		RingPedersen:      party.rp,
+		RingPedersenProof: rpProof,
+	}, nil
+}
+
+// ResharePhase2 -- checks the messages of the other new parties and the dealings of the old parties,
+// pub and pubShares are the shared PubKey and the PubShares of the old parties,
+// shares is keyed by the dealer id.
+// Return the shared PubKey.
+func (party *EcdsaThresholdParty) ResharePhase2(pub *PubKey, pubShares map[uint32]*PubKey, msgs []*EcdsaThresholdReshareMsg1, deals []*EcdsaThresholdReshareDeal, shares map[uint32]*big.Int) (*PubKey, error) {
+	if party.encprv == nil || party.share != nil {
+		return nil, fmt.Errorf("mpc.threshold.reshare.phase1.missing")
+	}
+	if pub == nil || len(deals) == 0 {
+		return nil, fmt.Errorf("mpc.threshold.reshare.input.invalid")
+	}
+	dealers := make([]uint32, len(deals))
+	for i, deal := range deals {
+		dealers[i] = deal.From
+	}
+	if err := thresholdCheckIDs(dealers); err != nil {
+		return nil, err
+	}
+	all := make(map[uint32][]*PubKey)
+	x := new(big.Int)
+	for _, deal := range deals {
+		old, ok := pubShares[deal.From]
+		if !ok {
+			return nil, fmt.Errorf("mpc.threshold.dealer[%v].unknown", deal.From)
+		}
+		if len(deal.Commitments) != party.threshold || !party.onCurve(deal.Commitments...) {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].commitments.invalid", deal.From)
+		}
+		lambda, err := LagrangeCoefficient(deal.From, dealers)
+		if err != nil {
+			return nil, err
+		}
+		if !pointEqual(deal.Commitments[0], pointMult(old, lambda)) {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].commitments.inconsistent", deal.From)
+		}
+		share, ok := shares[deal.From]
+		if !ok || !VssVerify(deal.Commitments, party.id, share) {
+			return nil, fmt.Errorf("mpc.threshold.party[%v].share.invalid", deal.From)
+		}
+		x.Add(x, share)
+		x.Mod(x, party.N)
+		all[deal.From] = deal.Commitments
+	}
+	var sum *PubKey
+	for _, c := range all {
+		if sum == nil {
+			sum = c[0]
+		} else {
+			sum = sum.Add(c[0])
+		}
+	}
+	if !pointEqual(sum, pub) {
+		return nil, fmt.Errorf("mpc.threshold.reshare.pubkey.mismatch")
+	}
+
+	// The ring-Pedersen proofs are slow, check them after the dealings.
+	froms := make([]uint32, len(msgs))
+	for i, msg := range msgs {
+		froms[i] = msg.From
+	}
+	if err := party.checkFrom(party.ids, froms); err != nil {
+		return nil, err
+	}
+	for _, msg := range msgs {
+		if msg.From == party.id {
+			continue
+		}
+		if err := party.addPeer(msg.From, msg.EncPub, msg.EncPubProof, msg.RingPedersen, msg.RingPedersenProof); err != nil {
+			return nil, err
+		}
+	}
+
+	return party.finishKeyGen(x, all)
+}
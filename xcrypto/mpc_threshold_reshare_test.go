@@ -0,0 +1,145 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMpcThresholdEcdsaReshare(t *testing.T) {
+	hash := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+	olds := mockThresholdParties(t)
+	pub := olds[0].PubKey()
+	pubShares := olds[0].PubShares()
+	assert.Equal(t, pubShares, olds[1].PubShares())
+
+	// 2-of-3 {1, 2, 3} to 3-of-3 {2, 3, 4}.
+	ids := []uint32{2, 3, 4}
+	parties := make([]*EcdsaThresholdParty, len(ids))
+	msgs1 := make([]*EcdsaThresholdReshareMsg1, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		party, err := NewEcdsaThresholdParty(id, ids, 3)
+		assert.Nil(t, err)
+		defer party.Close()
+		parties[i] = party
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			msg, err := parties[i].ResharePhase1()
+			assert.Nil(t, err)
+			msgs1[i] = msg
+		}(i)
+	}
+	wg.Wait()
+
+	// The old presignature of 1 and 3 made before the reshare.
+	oldPresigs, err := thresholdPresign(t, []*EcdsaThresholdParty{olds[0], olds[2]})
+	assert.Nil(t, err)
+
+	// Deal by the old parties 1 and 3.
+	dealers := []uint32{1, 3}
+	var deals []*EcdsaThresholdReshareDeal
+	shares := make(map[uint32]map[uint32]*big.Int)
+	for _, old := range []*EcdsaThresholdParty{olds[0], olds[2]} {
+		deal, out, err := old.ReshareDeal(dealers, ids, 3)
+		assert.Nil(t, err)
+		deals = append(deals, deal)
+		for to, share := range out {
+			if shares[to] == nil {
+				shares[to] = make(map[uint32]*big.Int)
+			}
+			shares[to][old.ID()] = share
+		}
+	}
+
+	// Bad share and inconsistent dealing name the dealer.
+	{
+		party := parties[0]
+		good := shares[2][3]
+		shares[2][3] = new(big.Int).Add(good, big.NewInt(1))
+		_, err := party.ResharePhase2(pub, pubShares, msgs1, deals, shares[2])
+		assert.EqualError(t, err, "mpc.threshold.party[3].share.invalid")
+		shares[2][3] = good
+
+		bad := *deals[0]
+		bad.Commitments = append([]*PubKey{pubShares[1]}, deals[0].Commitments[1:]...)
+		_, err = party.ResharePhase2(pub, pubShares, msgs1, []*EcdsaThresholdReshareDeal{&bad, deals[1]}, shares[2])
+		assert.EqualError(t, err, "mpc.threshold.party[1].commitments.inconsistent")
+
+		// One dealer is not enough.
+		_, err = party.ResharePhase2(pub, pubShares, msgs1, deals[:1], shares[2])
+		assert.EqualError(t, err, "mpc.threshold.party[1].commitments.inconsistent")
+		_, _, err = olds[0].ReshareDeal([]uint32{1}, ids, 3)
+		assert.NotNil(t, err)
+	}
+
+	for _, party := range parties {
+		got, err := party.ResharePhase2(pub, pubShares, msgs1, deals, shares[party.ID()])
+		assert.Nil(t, err)
+		assert.Equal(t, pub, got)
+	}
+	assert.NotEqual(t, pubShares[2], parties[0].PubShares()[2])
+
+	// The old share of 1 does not work with the new share of 2.
+	mixed, err := VssCombine(map[uint32]*big.Int{1: olds[0].share, 2: parties[0].share})
+	assert.Nil(t, err)
+	assert.False(t, pointEqual(pub, pointBaseMult(mixed)))
+
+	// The dealers stop signing with the old shares.
+	_, err = olds[0].PresignPhase1([]uint32{1, 3})
+	assert.EqualError(t, err, "mpc.threshold.party.reshared")
+	_, err = oldPresigs[0].Sign(hash)
+	assert.EqualError(t, err, "mpc.threshold.party.reshared")
+
+	// The new set confirms, the old parties retire and can not sign any more.
+	for _, old := range olds {
+		old.ReshareRetire()
+		_, err = old.PresignPhase1([]uint32{1, 2})
+		assert.EqualError(t, err, "mpc.threshold.keygen.unfinished")
+		old.ReshareAbort()
+		_, err = old.PresignPhase1([]uint32{1, 2})
+		assert.EqualError(t, err, "mpc.threshold.keygen.unfinished")
+	}
+	_, err = oldPresigs[1].Sign(hash)
+	assert.EqualError(t, err, "mpc.threshold.party.reshared")
+	// The shared old parties are retired, the next test runs a new keygen.
+	thresholdParties = nil
+
+	// 3-of-3 sign.
+	_, err = parties[0].PresignPhase1([]uint32{2, 3})
+	assert.NotNil(t, err)
+	presigs, err := thresholdPresign(t, parties)
+	assert.Nil(t, err)
+	partials := thresholdSign(t, presigs, hash)
+	sig, err := presigs[2].Combine(hash, partials)
+	assert.Nil(t, err)
+	assert.Nil(t, EcdsaVerify(pub, hash, sig))
+}
+
+func TestMpcThresholdEcdsaReshareAbort(t *testing.T) {
+	hash := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+	olds := mockThresholdParties(t)
+	pub := olds[0].PubKey()
+
+	// The dealing of 1 is aborted, the old set signs again.
+	_, _, err := olds[0].ReshareDeal([]uint32{1, 2}, []uint32{1, 2, 3}, 2)
+	assert.Nil(t, err)
+	_, err = thresholdPresign(t, olds[:2])
+	assert.EqualError(t, err, "mpc.threshold.party.reshared")
+	olds[0].ReshareAbort()
+
+	presigs, err := thresholdPresign(t, olds[:2])
+	assert.Nil(t, err)
+	partials := thresholdSign(t, presigs, hash)
+	sig, err := presigs[0].Combine(hash, partials)
+	assert.Nil(t, err)
+	assert.Nil(t, EcdsaVerify(pub, hash, sig))
+}
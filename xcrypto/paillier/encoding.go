@@ -0,0 +1,134 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package paillier
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/keyfuse/tokucore/xbase"
+)
+
+const (
+	// MinKeyBits -- the minimum modulus bit length accepted by the decoders.
+	MinKeyBits = 1024
+)
+
+// NewPubKey -- creates the public key from the modulus n, g is fixed to n+1.
+func NewPubKey(n *big.Int) (*PubKey, error) {
+	if n == nil || n.Bit(0) == 0 || n.BitLen() < MinKeyBits {
+		return nil, fmt.Errorf("paillier.pubkey.modulus.invalid")
+	}
+	return &PubKey{
+		G:  new(big.Int).Add(n, one),
+		N:  new(big.Int).Set(n),
+		NN: new(big.Int).Mul(n, n),
+	}, nil
+}
+
+// Serialize -- returns the big-endian bytes of the modulus n.
+func (pk *PubKey) Serialize() []byte {
+	return pk.N.Bytes()
+}
+
+// PubKeyFromBytes -- parses the modulus bytes to the public key.
+// Leading zero bytes are rejected so the encoding is canonical.
+func PubKeyFromBytes(data []byte) (*PubKey, error) {
+	if len(data) == 0 || data[0] == 0x00 {
+		return nil, fmt.Errorf("paillier.pubkey.bytes.invalid")
+	}
+	return NewPubKey(new(big.Int).SetBytes(data))
+}
+
+// PubKey -- returns the public key of the private key.
+func (sk *PrvKey) PubKey() *PubKey {
+	return sk.pk
+}
+
+// Serialize -- returns the encoding of the private key:
+// varbytes(n) || varbytes(lambda) || varbytes(mu).
+func (sk *PrvKey) Serialize() []byte {
+	buffer := xbase.NewBuffer()
+	buffer.WriteVarBytes(sk.pk.N.Bytes())
+	buffer.WriteVarBytes(sk.lambda.Bytes())
+	buffer.WriteVarBytes(sk.mu.Bytes())
+	return buffer.Bytes()
+}
+
+// PrvKeyFromBytes -- parses the private key from the Serialize encoding.
+// It checks that lambda*mu = 1 (mod n), so a corrupted key is never returned.
+func PrvKeyFromBytes(data []byte) (*PrvKey, error) {
+	var err error
+	var ints [3]*big.Int
+
+	buffer := xbase.NewBufferReader(data)
+	for i := range ints {
+		var b []byte
+		if b, err = buffer.ReadVarBytes(); err != nil {
+			return nil, err
+		}
+		if len(b) == 0 || b[0] == 0x00 {
+			return nil, fmt.Errorf("paillier.prvkey.bytes.invalid")
+		}
+		ints[i] = new(big.Int).SetBytes(b)
+	}
+	if !buffer.End() {
+		return nil, fmt.Errorf("paillier.prvkey.trailing.bytes[%v]", len(buffer.Remaining()))
+	}
+
+	pk, err := NewPubKey(ints[0])
+	if err != nil {
+		return nil, err
+	}
+	lambda, mu := ints[1], ints[2]
+	if lambda.Cmp(pk.N) >= 0 || mu.Cmp(pk.N) >= 0 {
+		return nil, fmt.Errorf("paillier.prvkey.out.of.range")
+	}
+	check := new(big.Int).Mul(lambda, mu)
+	if check.Mod(check, pk.N).Cmp(one) != 0 {
+		return nil, fmt.Errorf("paillier.prvkey.mu.mismatch")
+	}
+	p, q, err := factorFromPhi(pk.N, lambda)
+	if err != nil {
+		return nil, err
+	}
+	return newPrvKey(pk, p, q), nil
+}
+
+// factorFromPhi -- recovers the p > q from n = p*q and lambda = φ(n) = n-(p+q)+1,
+// p and q are the roots of x^2 - (p+q)x + n.
+func factorFromPhi(n, lambda *big.Int) (*big.Int, *big.Int, error) {
+	sum := new(big.Int).Sub(n, lambda)
+	sum.Add(sum, one)
+	d := new(big.Int).Mul(sum, sum)
+	d.Sub(d, new(big.Int).Lsh(n, 2))
+	if d.Sign() <= 0 {
+		return nil, nil, fmt.Errorf("paillier.prvkey.lambda.invalid")
+	}
+	root := new(big.Int).Sqrt(d)
+	if new(big.Int).Mul(root, root).Cmp(d) != 0 {
+		return nil, nil, fmt.Errorf("paillier.prvkey.lambda.invalid")
+	}
+	p := new(big.Int).Add(sum, root)
+	p.Rsh(p, 1)
+	q := new(big.Int).Sub(sum, root)
+	q.Rsh(q, 1)
+	if q.Cmp(one) <= 0 || new(big.Int).Mul(p, q).Cmp(n) != 0 {
+		return nil, nil, fmt.Errorf("paillier.prvkey.lambda.invalid")
+	}
+	return p, q, nil
+}
+
+// ValidCiphertext -- returns nil if the ct is in the range (0, n^2) and co-prime with n.
+func (pk *PubKey) ValidCiphertext(ct *big.Int) error {
+	if ct == nil || ct.Sign() <= 0 || ct.Cmp(pk.NN) >= 0 {
+		return fmt.Errorf("paillier.ciphertext.out.of.range")
+	}
+	if new(big.Int).GCD(nil, nil, ct, pk.N).Cmp(one) != 0 {
+		return fmt.Errorf("paillier.ciphertext.not.coprime")
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package paillier
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaillierEncoding(t *testing.T) {
+	pk, sk, err := GenerateKeyPair(1024)
+	assert.Nil(t, err)
+
+	// PubKey.
+	pk1, err := PubKeyFromBytes(pk.Serialize())
+	assert.Nil(t, err)
+	assert.Equal(t, pk, pk1)
+
+	// PrvKey.
+	sk1, err := PrvKeyFromBytes(sk.Serialize())
+	assert.Nil(t, err)
+	assert.Equal(t, sk, sk1)
+	assert.Equal(t, pk, sk1.PubKey())
+
+	ct, err := pk1.Encrypt(big.NewInt(2019))
+	assert.Nil(t, err)
+	assert.Nil(t, pk1.ValidCiphertext(ct))
+	got, err := sk1.Decrypt(ct)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(2019), got)
+}
+
+func TestPaillierEncodingError(t *testing.T) {
+	pk, sk, err := GenerateKeyPair(1024)
+	assert.Nil(t, err)
+
+	// PubKey.
+	{
+		_, err := PubKeyFromBytes(nil)
+		assert.NotNil(t, err)
+		_, err = PubKeyFromBytes(append([]byte{0x00}, pk.Serialize()...))
+		assert.NotNil(t, err)
+		_, err = PubKeyFromBytes([]byte{0x01, 0x03})
+		assert.NotNil(t, err)
+		even := new(big.Int).Add(pk.N, one)
+		_, err = PubKeyFromBytes(even.Bytes())
+		assert.NotNil(t, err)
+	}
+
+	// PrvKey.
+	{
+		data := sk.Serialize()
+		_, err := PrvKeyFromBytes(data[:len(data)-1])
+		assert.NotNil(t, err)
+		_, err = PrvKeyFromBytes(append(data, 0x00))
+		assert.NotNil(t, err)
+
+		// Corrupt the last byte of mu.
+		bad := make([]byte, len(data))
+		copy(bad, data)
+		bad[len(bad)-1] ^= 0x01
+		_, err = PrvKeyFromBytes(bad)
+		assert.NotNil(t, err)
+
+		// The lambda is not φ(n).
+		lambda := new(big.Int).Rsh(sk.lambda, 1)
+		buffer := xbase.NewBuffer()
+		buffer.WriteVarBytes(pk.N.Bytes())
+		buffer.WriteVarBytes(lambda.Bytes())
+		buffer.WriteVarBytes(new(big.Int).ModInverse(lambda, pk.N).Bytes())
+		_, err = PrvKeyFromBytes(buffer.Bytes())
+		assert.Equal(t, "paillier.prvkey.lambda.invalid", err.Error())
+	}
+
+	// Ciphertext.
+	{
+		assert.NotNil(t, pk.ValidCiphertext(nil))
+		assert.NotNil(t, pk.ValidCiphertext(big.NewInt(0)))
+		assert.NotNil(t, pk.ValidCiphertext(pk.NN))
+		assert.NotNil(t, pk.ValidCiphertext(pk.N))
+	}
+}
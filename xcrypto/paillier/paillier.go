@@ -26,60 +26,150 @@ type PubKey struct {
 }
 
 // PrvKey -- used to perform decryption.
+// The p > q factors and the CRT values are precomputed for the decryption and encryption.
 type PrvKey struct {
 	mu     *big.Int
 	pk     *PubKey
 	lambda *big.Int
+
+	p    *big.Int
+	q    *big.Int
+	pp   *big.Int // p^2
+	qq   *big.Int // q^2
+	hp   *big.Int // L_p(g^(p-1) mod p^2)^-1 mod p
+	hq   *big.Int // L_q(g^(q-1) mod q^2)^-1 mod q
+	qinv *big.Int // q^-1 mod p
+	np   *big.Int // n mod φ(p^2)
+	nq   *big.Int // n mod φ(q^2)
+	ppq  *big.Int // (p^2)^-1 mod q^2
 }
 
-// GenerateKeyPair -- returns a Paillier key pair.
+// GenerateKeyPair -- returns a Paillier key pair, the n is exactly bitlen bits
+// and the two equal-size primes are Blum primes (p = q = 3 mod 4).
 func GenerateKeyPair(bitlen int) (*PubKey, *PrvKey, error) {
-	p, err := getPrimer(bitlen / 2)
-	if err != nil {
-		return nil, nil, err
-	}
-	q, err := getPrimer(bitlen / 2)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	n := new(big.Int).Mul(p, q)
-	nn := new(big.Int).Mul(n, n)
-	g := new(big.Int).Add(n, one)
+	return generateKeyPair(bitlen, getBlumPrime)
+}
 
-	lambda := phi(p, q)
-	mu := new(big.Int).ModInverse(lambda, n)
+// GenerateSafeKeyPair -- returns a Paillier key pair with the safe primes p = 2p'+1 and q = 2q'+1,
+// the n is exactly bitlen bits. It is much slower than GenerateKeyPair.
+func GenerateSafeKeyPair(bitlen int) (*PubKey, *PrvKey, error) {
+	return generateKeyPair(bitlen, getSafePrime)
+}
 
-	pk := &PubKey{
-		G:  g,
-		N:  n,
-		NN: nn,
+func generateKeyPair(bitlen int, prime func(bits int) (*big.Int, error)) (*PubKey, *PrvKey, error) {
+	if bitlen < minGenerateBits || bitlen%2 != 0 {
+		return nil, nil, fmt.Errorf("paillier.key.bits[%v].invalid", bitlen)
 	}
+	for {
+		p, err := prime(bitlen / 2)
+		if err != nil {
+			return nil, nil, err
+		}
+		q, err := prime(bitlen / 2)
+		if err != nil {
+			return nil, nil, err
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+
+		n := new(big.Int).Mul(p, q)
+		if n.BitLen() != bitlen {
+			continue
+		}
+		pk := &PubKey{
+			G:  new(big.Int).Add(n, one),
+			N:  n,
+			NN: new(big.Int).Mul(n, n),
+		}
+		return pk, newPrvKey(pk, p, q), nil
+	}
+}
 
+// newPrvKey -- returns the private key with the precomputed values of the factors.
+func newPrvKey(pk *PubKey, p, q *big.Int) *PrvKey {
+	if p.Cmp(q) < 0 {
+		p, q = q, p
+	}
+	lambda := phi(p, q)
 	sk := &PrvKey{
-		mu:     mu,
+		mu:     new(big.Int).ModInverse(lambda, pk.N),
 		pk:     pk,
 		lambda: lambda,
+		p:      p,
+		q:      q,
+		pp:     new(big.Int).Mul(p, p),
+		qq:     new(big.Int).Mul(q, q),
+		qinv:   new(big.Int).ModInverse(q, p),
 	}
-	return pk, sk, nil
+	sk.hp = crtH(pk.G, p, sk.pp)
+	sk.hq = crtH(pk.G, q, sk.qq)
+	sk.np = new(big.Int).Mod(pk.N, new(big.Int).Mul(p, new(big.Int).Sub(p, one)))
+	sk.nq = new(big.Int).Mod(pk.N, new(big.Int).Mul(q, new(big.Int).Sub(q, one)))
+	sk.ppq = new(big.Int).ModInverse(sk.pp, sk.qq)
+	return sk
 }
 
 // Encrypt -- returns a IND-CPA secure ciphertext for the message `msg`.
 func (pk *PubKey) Encrypt(msg *big.Int) (*big.Int, error) {
-	m := new(big.Int).Set(msg)
-	if m.Cmp(zero) == -1 || m.Cmp(pk.N) != -1 {
+	r, err := pk.RandomNonce()
+	if err != nil {
+		return nil, err
+	}
+	return pk.EncryptWithNonce(msg, r)
+}
+
+// EncryptWithNonce -- returns the ciphertext g^msg*r^n (mod n^2) for the given nonce r.
+// The proofs of the mpc protocols need to know the nonce.
+func (pk *PubKey) EncryptWithNonce(msg *big.Int, r *big.Int) (*big.Int, error) {
+	if msg.Cmp(zero) == -1 || msg.Cmp(pk.N) != -1 {
 		return nil, fmt.Errorf("plaintext.invalid")
 	}
 
-	r, err := getRandom(pk.N)
+	// c=g^m*r^n (mod n^2)
+	rn := new(big.Int).Exp(r, pk.N, pk.NN)
+	c := pk.gm(msg)
+	c.Mul(c, rn)
+	return c.Mod(c, pk.NN), nil
+}
+
+// gm -- returns g^m mod n^2, which is 1+m*n for the g = n+1.
+func (pk *PubKey) gm(msg *big.Int) *big.Int {
+	if new(big.Int).Sub(pk.G, pk.N).Cmp(one) != 0 {
+		return new(big.Int).Exp(pk.G, msg, pk.NN)
+	}
+	m := new(big.Int).Mul(msg, pk.N)
+	m.Add(m, one)
+	return m.Mod(m, pk.NN)
+}
+
+// RandomNonce -- returns a random r in Z*n.
+func (pk *PubKey) RandomNonce() (*big.Int, error) {
+	return getRandom(pk.N)
+}
+
+// Encrypt -- the same as the PubKey.Encrypt, the r^n is computed by CRT.
+func (sk *PrvKey) Encrypt(msg *big.Int) (*big.Int, error) {
+	r, err := sk.pk.RandomNonce()
 	if err != nil {
 		return nil, err
 	}
-	// c=g^m*r^n (mod n^2)
-	r.Exp(r, pk.N, pk.NN)
-	m.Exp(pk.G, m, pk.NN)
+	return sk.EncryptWithNonce(msg, r)
+}
+
+// EncryptWithNonce -- the same as the PubKey.EncryptWithNonce, the r^n is computed by CRT.
+func (sk *PrvKey) EncryptWithNonce(msg *big.Int, r *big.Int) (*big.Int, error) {
+	pk := sk.pk
+	if msg.Cmp(zero) == -1 || msg.Cmp(pk.N) != -1 {
+		return nil, fmt.Errorf("plaintext.invalid")
+	}
 
-	c := new(big.Int).Mul(m, r)
+	// r^n = CRT(r^(n mod φ(p^2)) mod p^2, r^(n mod φ(q^2)) mod q^2)
+	rp := new(big.Int).Exp(r, sk.np, sk.pp)
+	rq := new(big.Int).Exp(r, sk.nq, sk.qq)
+	rn := crt(rp, rq, sk.pp, sk.qq, sk.ppq)
+	c := pk.gm(msg)
+	c.Mul(c, rn)
 	return c.Mod(c, pk.NN), nil
 }
 
@@ -89,6 +179,18 @@ func (sk *PrvKey) Decrypt(ct *big.Int) (*big.Int, error) {
 		return nil, fmt.Errorf("ciphertext.invalid")
 	}
 
+	// mp = L_p(c^(p-1) mod p^2)*hp mod p, mq likewise and m = CRT(mp, mq).
+	mp := crtL(ct, sk.p, sk.pp, sk.hp)
+	mq := crtL(ct, sk.q, sk.qq, sk.hq)
+	return crt(mq, mp, sk.q, sk.p, sk.qinv), nil
+}
+
+// decryptLambda -- the decryption without CRT, m = L(c^lambda mod n^2)*mu mod n.
+func (sk *PrvKey) decryptLambda(ct *big.Int) (*big.Int, error) {
+	if ct == nil || ct.Cmp(zero) != 1 {
+		return nil, fmt.Errorf("ciphertext.invalid")
+	}
+
 	// m = l(c^lambda mod n^2)*mu mod n where L(x) = (x-1)/n
 	clambda := ctlambda(ct, sk.lambda, sk.pk.NN)
 	m := l(clambda, sk.pk.N)
@@ -97,6 +199,29 @@ func (sk *PrvKey) Decrypt(ct *big.Int) (*big.Int, error) {
 	return m, nil
 }
 
+// crtH -- returns L_p(g^(p-1) mod p^2)^-1 mod p.
+func crtH(g, p, pp *big.Int) *big.Int {
+	x := new(big.Int).Exp(g, new(big.Int).Sub(p, one), pp)
+	return new(big.Int).ModInverse(l(x, p), p)
+}
+
+// crtL -- returns L_p(c^(p-1) mod p^2)*h mod p.
+func crtL(ct, p, pp, h *big.Int) *big.Int {
+	x := new(big.Int).Exp(ct, new(big.Int).Sub(p, one), pp)
+	m := l(x, p)
+	m.Mul(m, h)
+	return m.Mod(m, p)
+}
+
+// crt -- returns x mod a*b with x = xa mod a and x = xb mod b, ainv = a^-1 mod b.
+func crt(xa, xb, a, b, ainv *big.Int) *big.Int {
+	h := new(big.Int).Sub(xb, xa)
+	h.Mul(h, ainv)
+	h.Mod(h, b)
+	h.Mul(h, a)
+	return h.Add(h, xa)
+}
+
 // phi -- computes Euler's totient function `φ(p,q) = (p-1)*(q-1)`.
 func phi(x, y *big.Int) *big.Int {
 	p1 := new(big.Int).Sub(x, one)
@@ -114,12 +239,17 @@ func ctlambda(ct, lambda *big.Int, nn *big.Int) *big.Int {
 	return new(big.Int).Exp(ct, lambda, nn)
 }
 
-func getPrimer(bits int) (*big.Int, error) {
-	return rand.Prime(rand.Reader, bits)
-}
-
-// TODO(BohuTANG): ensure {gcd(r,n)=1}
+// getRandom -- returns a random r in [1, n) with gcd(r,n)=1.
 // https://en.wikipedia.org/wiki/Paillier_cryptosystem#Encryption
 func getRandom(n *big.Int) (*big.Int, error) {
-	return rand.Int(rand.Reader, n)
+	gcd := new(big.Int)
+	for {
+		r, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return nil, err
+		}
+		if r.Sign() != 0 && gcd.GCD(nil, nil, r, n).Cmp(one) == 0 {
+			return r, nil
+		}
+	}
 }
@@ -41,6 +41,81 @@ func TestPaillier(t *testing.T) {
 	}
 }
 
+func TestPaillierKeyGen(t *testing.T) {
+	for _, generate := range []func(int) (*PubKey, *PrvKey, error){GenerateKeyPair, GenerateSafeKeyPair} {
+		pk, sk, err := generate(512)
+		assert.Nil(t, err)
+		assert.Equal(t, 512, pk.N.BitLen())
+		assert.Equal(t, 256, sk.p.BitLen())
+		assert.Equal(t, 256, sk.q.BitLen())
+		assert.Equal(t, 1, sk.p.Cmp(sk.q))
+		assert.Equal(t, uint(1), sk.p.Bit(1))
+		assert.Equal(t, uint(1), sk.q.Bit(1))
+		assert.Equal(t, pk.N, new(big.Int).Mul(sk.p, sk.q))
+	}
+
+	// Safe primes.
+	{
+		_, sk, err := GenerateSafeKeyPair(512)
+		assert.Nil(t, err)
+		for _, p := range []*big.Int{sk.p, sk.q} {
+			assert.True(t, p.ProbablyPrime(20))
+			assert.True(t, new(big.Int).Rsh(p, 1).ProbablyPrime(20))
+		}
+	}
+
+	// Bits.
+	{
+		_, _, err := GenerateKeyPair(1025)
+		assert.Equal(t, "paillier.key.bits[1025].invalid", err.Error())
+		_, _, err = GenerateSafeKeyPair(128)
+		assert.Equal(t, "paillier.key.bits[128].invalid", err.Error())
+		_, err = getSafePrime(8)
+		assert.Equal(t, "paillier.safe.prime.bits[8].invalid", err.Error())
+	}
+}
+
+func TestPaillierCRT(t *testing.T) {
+	pk, sk, err := GenerateKeyPair(1024)
+	assert.Nil(t, err)
+
+	for i := 0; i < 16; i++ {
+		msg, err := getRandom(pk.N)
+		assert.Nil(t, err)
+		r, err := pk.RandomNonce()
+		assert.Nil(t, err)
+
+		// The CRT encryption is the same ciphertext.
+		ct, err := pk.EncryptWithNonce(msg, r)
+		assert.Nil(t, err)
+		ct1, err := sk.EncryptWithNonce(msg, r)
+		assert.Nil(t, err)
+		assert.Equal(t, ct, ct1)
+		assert.Equal(t, new(big.Int).Mod(new(big.Int).Mul(new(big.Int).Exp(pk.G, msg, pk.NN), new(big.Int).Exp(r, pk.N, pk.NN)), pk.NN), ct)
+
+		// The CRT decryption is the same plaintext.
+		got, err := sk.Decrypt(ct)
+		assert.Nil(t, err)
+		assert.Equal(t, msg, got)
+		got, err = sk.decryptLambda(ct)
+		assert.Nil(t, err)
+		assert.Equal(t, msg, got)
+	}
+
+	// Edges.
+	for _, msg := range []*big.Int{big.NewInt(0), new(big.Int).Sub(pk.N, one)} {
+		ct, err := sk.Encrypt(msg)
+		assert.Nil(t, err)
+		got, err := sk.Decrypt(ct)
+		assert.Nil(t, err)
+		assert.Equal(t, msg, got)
+	}
+	_, err = sk.Encrypt(pk.N)
+	assert.Equal(t, "plaintext.invalid", err.Error())
+	_, err = sk.Decrypt(big.NewInt(0))
+	assert.Equal(t, "ciphertext.invalid", err.Error())
+}
+
 func benchmarkKey(size int, b *testing.B) {
 	b.ReportAllocs()
 	for n := 0; n < b.N; n++ {
@@ -55,3 +130,66 @@ func BenchmarkKey1024(b *testing.B) { benchmarkKey(1024, b) }
 func BenchmarkKey2048(b *testing.B) { benchmarkKey(2048, b) }
 func BenchmarkKey3072(b *testing.B) { benchmarkKey(3072, b) }
 func BenchmarkKey4096(b *testing.B) { benchmarkKey(4096, b) }
+
+func benchmarkSafeKey(size int, b *testing.B) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		if _, _, err := GenerateSafeKeyPair(size); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func BenchmarkSafeKey1024(b *testing.B) { benchmarkSafeKey(1024, b) }
+func BenchmarkSafeKey2048(b *testing.B) { benchmarkSafeKey(2048, b) }
+
+func benchmarkCrypt(b *testing.B, crypt func(pk *PubKey, sk *PrvKey, msg, r, ct *big.Int) error) {
+	pk, sk, err := GenerateKeyPair(2048)
+	if err != nil {
+		panic(err)
+	}
+	msg := big.NewInt(2019)
+	r, err := pk.RandomNonce()
+	if err != nil {
+		panic(err)
+	}
+	ct, err := pk.EncryptWithNonce(msg, r)
+	if err != nil {
+		panic(err)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := crypt(pk, sk, msg, r, ct); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func BenchmarkEncrypt2048(b *testing.B) {
+	benchmarkCrypt(b, func(pk *PubKey, sk *PrvKey, msg, r, ct *big.Int) error {
+		_, err := pk.EncryptWithNonce(msg, r)
+		return err
+	})
+}
+
+func BenchmarkEncryptCRT2048(b *testing.B) {
+	benchmarkCrypt(b, func(pk *PubKey, sk *PrvKey, msg, r, ct *big.Int) error {
+		_, err := sk.EncryptWithNonce(msg, r)
+		return err
+	})
+}
+
+func BenchmarkDecryptLambda2048(b *testing.B) {
+	benchmarkCrypt(b, func(pk *PubKey, sk *PrvKey, msg, r, ct *big.Int) error {
+		_, err := sk.decryptLambda(ct)
+		return err
+	})
+}
+
+func BenchmarkDecryptCRT2048(b *testing.B) {
+	benchmarkCrypt(b, func(pk *PubKey, sk *PrvKey, msg, r, ct *big.Int) error {
+		_, err := sk.Decrypt(ct)
+		return err
+	})
+}
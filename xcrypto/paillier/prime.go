@@ -0,0 +1,103 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package paillier
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+const (
+	// minGenerateBits -- the minimum modulus bit length of the key generation.
+	minGenerateBits = 256
+
+	// safePrimeSieveSize -- the number of the odd candidates searched from one random start.
+	safePrimeSieveSize = 1 << 16
+)
+
+// smallPrimes -- the odd primes below 2^11 to sieve the safe prime candidates.
+var smallPrimes = func() []uint64 {
+	var primes []uint64
+	for n := uint64(3); n < 1<<11; n += 2 {
+		isPrime := true
+		for _, p := range primes {
+			if p*p > n {
+				break
+			}
+			if n%p == 0 {
+				isPrime = false
+				break
+			}
+		}
+		if isPrime {
+			primes = append(primes, n)
+		}
+	}
+	return primes
+}()
+
+// getBlumPrime -- returns a bits prime p = 3 mod 4, the top two bits are set.
+func getBlumPrime(bits int) (*big.Int, error) {
+	for {
+		p, err := rand.Prime(rand.Reader, bits)
+		if err != nil {
+			return nil, err
+		}
+		if p.Bit(1) == 1 {
+			return p, nil
+		}
+	}
+}
+
+// getSafePrime -- returns a bits safe prime p = 2p'+1 with the prime p', the top two bits are set.
+// The odd p' candidates from a random start are sieved by the small primes s,
+// p' mod s must not be 0 or (s-1)/2, which would make p' or p divisible by s.
+func getSafePrime(bits int) (*big.Int, error) {
+	if bits < 16 {
+		return nil, fmt.Errorf("paillier.safe.prime.bits[%v].invalid", bits)
+	}
+
+	mods := make([]uint64, len(smallPrimes))
+	for {
+		// p' has bits-1 bits with the top two set, so p has bits bits with the top two set.
+		start, err := rand.Int(rand.Reader, new(big.Int).Lsh(one, uint(bits-1)))
+		if err != nil {
+			return nil, err
+		}
+		start.SetBit(start, bits-2, 1)
+		start.SetBit(start, bits-3, 1)
+		start.SetBit(start, 0, 1)
+
+		m := new(big.Int)
+		for i, s := range smallPrimes {
+			mods[i] = m.Mod(start, new(big.Int).SetUint64(s)).Uint64()
+		}
+
+	next:
+		for delta := uint64(0); delta < 2*safePrimeSieveSize; delta += 2 {
+			for i, s := range smallPrimes {
+				r := (mods[i] + delta) % s
+				if r == 0 || r == (s-1)/2 {
+					continue next
+				}
+			}
+			q := new(big.Int).Add(start, new(big.Int).SetUint64(delta))
+			if q.BitLen() != bits-1 {
+				break
+			}
+			p := new(big.Int).Lsh(q, 1)
+			p.Add(p, one)
+			// Fermat base 2 on p first, the cheap filter for the most composites.
+			if new(big.Int).Exp(big.NewInt(2), new(big.Int).Sub(p, one), p).Cmp(one) != 0 {
+				continue
+			}
+			if q.ProbablyPrime(20) && p.ProbablyPrime(20) {
+				return p, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package paillier
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+const (
+	// modulusProofIterations -- the number of the challenges, the soundness error is 2^-80.
+	modulusProofIterations = 80
+	modulusProofTag        = "paillier.modulus.proof"
+)
+
+// ModulusProof -- the non-interactive Paillier-Blum modulus proof of
+// Canetti et al. "UC Non-Interactive, Proactive, Threshold ECDSA with Identifiable Aborts" Figure 16.
+// For the challenges y[i] derived from N and W:
+//
+//	Z[i]^N = y[i] mod N, the N-th roots exist only if gcd(N, φ(N)) = 1, so N is square-free.
+//	X[i]^4 = (-1)^A[i] * W^B[i] * y[i] mod N, the fourth roots exist only if N = p^a*q^b with p = q = 3 mod 4.
+//
+// Together N is the product of two distinct Blum primes.
+type ModulusProof struct {
+	W *big.Int
+	X []*big.Int
+	A []bool
+	B []bool
+	Z []*big.Int
+}
+
+// ProveModulus -- creates the ModulusProof of the private key modulus, the primes must be 3 mod 4.
+func ProveModulus(sk *PrvKey) (*ModulusProof, error) {
+	n, p, q := sk.pk.N, sk.p, sk.q
+	if p.Bit(1) == 0 || q.Bit(1) == 0 {
+		return nil, fmt.Errorf("paillier.modulus.not.blum")
+	}
+	ninv := new(big.Int).ModInverse(n, sk.lambda)
+	if ninv == nil {
+		return nil, fmt.Errorf("paillier.modulus.not.square.free")
+	}
+
+	// W is a quadratic non-residue with the Jacobi symbol -1.
+	var w *big.Int
+	for {
+		r, err := getRandom(n)
+		if err != nil {
+			return nil, err
+		}
+		if big.Jacobi(r, n) == -1 {
+			w = r
+			break
+		}
+	}
+
+	// The unique square root of a quadratic residue which is also a residue is a^((p+1)/4) mod p,
+	// the fourth root is a^((p+1)/4)^2 mod p.
+	ep := fourthRootExp(p)
+	eq := fourthRootExp(q)
+	np := new(big.Int).Mod(ninv, new(big.Int).Sub(p, one))
+	nq := new(big.Int).Mod(ninv, new(big.Int).Sub(q, one))
+
+	proof := &ModulusProof{
+		W: w,
+		X: make([]*big.Int, modulusProofIterations),
+		A: make([]bool, modulusProofIterations),
+		B: make([]bool, modulusProofIterations),
+		Z: make([]*big.Int, modulusProofIterations),
+	}
+	for i := 0; i < modulusProofIterations; i++ {
+		y := modulusChallenge(n, w, i)
+		zp := new(big.Int).Exp(y, np, p)
+		zq := new(big.Int).Exp(y, nq, q)
+		proof.Z[i] = crt(zq, zp, q, p, sk.qinv)
+
+		// Exactly one of ±y, ±w*y is a quadratic residue modulo both p and q.
+		found := false
+		for _, a := range []bool{false, true} {
+			for _, b := range []bool{false, true} {
+				v := modulusFudge(n, w, y, a, b)
+				if big.Jacobi(v, p) == 1 && big.Jacobi(v, q) == 1 {
+					xp := new(big.Int).Exp(v, ep, p)
+					xq := new(big.Int).Exp(v, eq, q)
+					proof.X[i] = crt(xq, xp, q, p, sk.qinv)
+					proof.A[i], proof.B[i] = a, b
+					found = true
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("paillier.modulus.challenge[%v].not.unit", i)
+		}
+	}
+	return proof, nil
+}
+
+// Verify -- checks the N of the public key is a Paillier-Blum modulus.
+func (proof *ModulusProof) Verify(pk *PubKey) bool {
+	if proof == nil || pk == nil || pk.N == nil || proof.W == nil {
+		return false
+	}
+	if len(proof.X) != modulusProofIterations || len(proof.A) != modulusProofIterations ||
+		len(proof.B) != modulusProofIterations || len(proof.Z) != modulusProofIterations {
+		return false
+	}
+	n := pk.N
+	if n.Sign() <= 0 || n.Bit(0) == 0 || n.ProbablyPrime(20) {
+		return false
+	}
+	if !inUnitRange(proof.W, n) || big.Jacobi(proof.W, n) != -1 {
+		return false
+	}
+
+	four := big.NewInt(4)
+	for i := 0; i < modulusProofIterations; i++ {
+		x, z := proof.X[i], proof.Z[i]
+		if !inUnitRange(x, n) || !inUnitRange(z, n) {
+			return false
+		}
+		y := modulusChallenge(n, proof.W, i)
+		if new(big.Int).Exp(z, n, n).Cmp(y) != 0 {
+			return false
+		}
+		v := modulusFudge(n, proof.W, y, proof.A[i], proof.B[i])
+		if new(big.Int).Exp(x, four, n).Cmp(v) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// modulusChallenge -- returns the i-th challenge y in [0, n) expanded from
+// sha256(tag || n || w || i || counter), with 128 more bits than n for the uniform reduction.
+func modulusChallenge(n, w *big.Int, i int) *big.Int {
+	size := (n.BitLen()+7)/8 + 16
+	var buf []byte
+	var idx [8]byte
+	binary.BigEndian.PutUint32(idx[:4], uint32(i))
+	for counter := uint32(0); len(buf) < size; counter++ {
+		binary.BigEndian.PutUint32(idx[4:], counter)
+		h := sha256.New()
+		h.Write([]byte(modulusProofTag))
+		writeInt(h.Write, n)
+		writeInt(h.Write, w)
+		h.Write(idx[:])
+		buf = h.Sum(buf)
+	}
+	y := new(big.Int).SetBytes(buf[:size])
+	return y.Mod(y, n)
+}
+
+// modulusFudge -- returns (-1)^a * w^b * y mod n.
+func modulusFudge(n, w, y *big.Int, a, b bool) *big.Int {
+	v := new(big.Int).Set(y)
+	if a {
+		v.Sub(n, v)
+	}
+	if b {
+		v.Mul(v, w)
+		v.Mod(v, n)
+	}
+	return v
+}
+
+// fourthRootExp -- returns ((p+1)/4)^2 mod (p-1).
+func fourthRootExp(p *big.Int) *big.Int {
+	e := new(big.Int).Add(p, one)
+	e.Rsh(e, 2)
+	e.Mul(e, e)
+	return e.Mod(e, new(big.Int).Sub(p, one))
+}
+
+// writeInt -- writes len(v) || v.
+func writeInt(write func([]byte) (int, error), v *big.Int) {
+	b := v.Bytes()
+	var l [4]byte
+	binary.BigEndian.PutUint32(l[:], uint32(len(b)))
+	write(l[:])
+	write(b)
+}
+
+// inUnitRange -- returns whether v is in [1, n).
+func inUnitRange(v *big.Int, n *big.Int) bool {
+	return v != nil && v.Sign() > 0 && v.Cmp(n) < 0
+}
@@ -0,0 +1,119 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package paillier
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModulusProof(t *testing.T) {
+	pk, sk, err := GenerateKeyPair(1024)
+	assert.Nil(t, err)
+
+	proof, err := ProveModulus(sk)
+	assert.Nil(t, err)
+	assert.True(t, proof.Verify(pk))
+
+	// The loaded key proves the same modulus.
+	{
+		sk1, err := PrvKeyFromBytes(sk.Serialize())
+		assert.Nil(t, err)
+		proof1, err := ProveModulus(sk1)
+		assert.Nil(t, err)
+		assert.True(t, proof1.Verify(pk))
+	}
+
+	// Other modulus.
+	{
+		pk2, _, err := GenerateKeyPair(1024)
+		assert.Nil(t, err)
+		assert.False(t, proof.Verify(pk2))
+	}
+
+	// Tampered proofs.
+	{
+		tamper := func(f func(p *ModulusProof)) *ModulusProof {
+			p := &ModulusProof{
+				W: new(big.Int).Set(proof.W),
+				X: append([]*big.Int{}, proof.X...),
+				A: append([]bool{}, proof.A...),
+				B: append([]bool{}, proof.B...),
+				Z: append([]*big.Int{}, proof.Z...),
+			}
+			f(p)
+			return p
+		}
+		assert.False(t, tamper(func(p *ModulusProof) { p.W = new(big.Int).Add(p.W, one) }).Verify(pk))
+		assert.False(t, tamper(func(p *ModulusProof) { p.X[7] = new(big.Int).Add(p.X[7], one) }).Verify(pk))
+		assert.False(t, tamper(func(p *ModulusProof) { p.A[3] = !p.A[3] }).Verify(pk))
+		assert.False(t, tamper(func(p *ModulusProof) { p.B[79] = !p.B[79] }).Verify(pk))
+		assert.False(t, tamper(func(p *ModulusProof) { p.Z[0] = new(big.Int).Add(p.Z[0], one) }).Verify(pk))
+		assert.False(t, tamper(func(p *ModulusProof) { p.Z = p.Z[1:] }).Verify(pk))
+		assert.False(t, tamper(func(p *ModulusProof) { p.X[1] = pk.N }).Verify(pk))
+		assert.False(t, (*ModulusProof)(nil).Verify(pk))
+
+		// -x is also a fourth root.
+		assert.True(t, tamper(func(p *ModulusProof) { p.X[7] = new(big.Int).Sub(pk.N, p.X[7]) }).Verify(pk))
+	}
+
+	// The prime modulus.
+	{
+		prime := &PubKey{N: sk.p}
+		assert.False(t, proof.Verify(prime))
+	}
+}
+
+func TestModulusProofNotBlum(t *testing.T) {
+	var ps []*big.Int
+	for len(ps) < 2 {
+		p, err := rand.Prime(rand.Reader, 256)
+		assert.Nil(t, err)
+		if p.Bit(1) == 0 {
+			ps = append(ps, p)
+		}
+	}
+	n := new(big.Int).Mul(ps[0], ps[1])
+	pk := &PubKey{G: new(big.Int).Add(n, one), N: n, NN: new(big.Int).Mul(n, n)}
+	sk := newPrvKey(pk, ps[0], ps[1])
+	_, err := ProveModulus(sk)
+	assert.Equal(t, "paillier.modulus.not.blum", err.Error())
+}
+
+func BenchmarkProveModulus2048(b *testing.B) {
+	_, sk, err := GenerateKeyPair(2048)
+	if err != nil {
+		panic(err)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := ProveModulus(sk); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func BenchmarkVerifyModulus2048(b *testing.B) {
+	pk, sk, err := GenerateKeyPair(2048)
+	if err != nil {
+		panic(err)
+	}
+	proof, err := ProveModulus(sk)
+	if err != nil {
+		panic(err)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if !proof.Verify(pk) {
+			panic("verify.failed")
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"math/big"
 
+	"github.com/keyfuse/tokucore/xcrypto/schnorr"
 	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
 )
 
@@ -18,6 +19,7 @@ const (
 	// Length
 	pubKeyBytesLenCompressed   = 33
 	pubKeyBytesLenUncompressed = 65
+	pubKeyBytesLenXOnly        = 32
 
 	// Format
 	pubkeyCompressed   byte = 0x2 // y_bit + x coord
@@ -84,6 +86,19 @@ func PubKeyFromBytes(key []byte) (*PubKey, error) {
 	return &pubkey, nil
 }
 
+// PubKeyFromXOnly -- parse the 32-byte x-only public key of BIP340, the y coord is even.
+func PubKeyFromXOnly(key []byte) (*PubKey, error) {
+	if len(key) != pubKeyBytesLenXOnly {
+		return nil, fmt.Errorf("pubkey.size[%v].invalid", len(key))
+	}
+	curve := secp256k1.SECP256K1()
+	x, y := schnorr.LiftX(curve, key)
+	if x == nil {
+		return nil, fmt.Errorf("pubkey.xonly[%x].is.not.on.the.curve", key)
+	}
+	return &PubKey{X: x, Y: y, Curve: curve}, nil
+}
+
 // XBytes -- returns the x coord bytes.
 func (p *PubKey) XBytes() []byte {
 	return p.X.Bytes()
@@ -141,6 +156,11 @@ func (p *PubKey) SerializeCompressed() []byte {
 	return secp256k1.SecMarshal(p.Curve, p.X, p.Y)
 }
 
+// SerializeXOnly -- encoding a public key in the 32-byte x-only format of BIP340.
+func (p *PubKey) SerializeXOnly() []byte {
+	return p.SerializeCompressed()[1:]
+}
+
 // Hash160 -- returns the Hash160 of the compressed public key.
 func (p *PubKey) Hash160() []byte {
 	return Hash160(p.SerializeCompressed())
@@ -279,3 +279,25 @@ func TestPubKeyAdd(t *testing.T) {
 	pubkey3 := prvkey3.PubKey()
 	assert.Equal(t, pubkey2, pubkey3)
 }
+
+func TestPubKeyXOnly(t *testing.T) {
+	prvkey := PrvKeyFromBytes([]byte{0x03})
+	pubkey := prvkey.PubKey()
+
+	xonly := pubkey.SerializeXOnly()
+	assert.Equal(t, "f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9", hex.EncodeToString(xonly))
+
+	// The lifted point has the even y.
+	lifted, err := PubKeyFromXOnly(xonly)
+	assert.Nil(t, err)
+	assert.Equal(t, pubkey.X, lifted.X)
+	assert.Equal(t, uint(0), lifted.Y.Bit(0))
+
+	_, err = PubKeyFromXOnly(xonly[1:])
+	assert.NotNil(t, err)
+
+	// x is not on the curve.
+	bad, _ := hex.DecodeString("eefdea4cdb677750a420fee807eacf21eb9898ae79b9768766e4faa04a2d4a34")
+	_, err = PubKeyFromXOnly(bad)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,158 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package schnorr
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"math/big"
+
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+)
+
+// weightBound -- the bound of the random weights.
+var weightBound = new(big.Int).Lsh(big.NewInt(1), 128)
+
+// multiMult -- the curve which computes baseScalar*G + Σ scalars[i]*P[i] in one pass, such as secp256k1.
+type multiMult interface {
+	MultiMult(Xs, Ys []*big.Int, baseScalar []byte, scalars [][]byte) (x, y *big.Int)
+}
+
+// BatchVerify -- verifies all the signatures with one multi-scalar multiplication.
+// The R[i] is lifted from r[i] with the quadratic residue y, then with the random weights a[i], a[0] = 1:
+//
+//	(Σ a[i]*s[i])G = Σ a[i]*R[i] + Σ a[i]*e[i]*P[i]
+//
+// Returns true if all the signatures are valid, false if any is invalid.
+func BatchVerify(pubs []*ecdsa.PublicKey, ms [][]byte, rs []*big.Int, ss []*big.Int) bool {
+	n := len(pubs)
+	if len(ms) != n || len(rs) != n || len(ss) != n {
+		return false
+	}
+	if n == 0 {
+		return true
+	}
+
+	curve := secp256k1.SECP256K1()
+	P := curve.Params().P
+	N := curve.Params().N
+	Rxs := make([]*big.Int, n)
+	Rys := make([]*big.Int, n)
+	Pxs := make([]*big.Int, n)
+	Pys := make([]*big.Int, n)
+	es := make([]*big.Int, n)
+	for i := 0; i < n; i++ {
+		pub, r, s := pubs[i], rs[i], ss[i]
+		if pub.Curve != curve || r.Cmp(P) >= 0 || s.Cmp(N) >= 0 {
+			return false
+		}
+		Rx, Ry := LiftX(curve, IntToByte(r))
+		if Rx == nil {
+			return false
+		}
+		// The lifted y is even, the other one is the quadratic residue if it is not.
+		if big.Jacobi(Ry, P) != 1 {
+			Ry.Sub(P, Ry)
+		}
+		Rxs[i], Rys[i] = Rx, Ry
+		Pxs[i], Pys[i] = pub.X, pub.Y
+		es[i] = GetE(curve, ms[i], pub.X, pub.Y, IntToByte(r))
+	}
+	return batchVerify(curve.(multiMult), N, Rxs, Rys, Pxs, Pys, es, ss)
+}
+
+// BatchVerifyBIP340 -- verifies all the BIP340 signatures against the 32-byte x-only public keys
+// with one multi-scalar multiplication, the R[i] and P[i] are lifted with the even y.
+// Returns true if all the signatures are valid, false if any is invalid.
+func BatchVerifyBIP340(pks [][]byte, ms [][]byte, sigs [][]byte) bool {
+	n := len(pks)
+	if len(ms) != n || len(sigs) != n {
+		return false
+	}
+	if n == 0 {
+		return true
+	}
+
+	curve := secp256k1.SECP256K1()
+	P := curve.Params().P
+	N := curve.Params().N
+	Rxs := make([]*big.Int, n)
+	Rys := make([]*big.Int, n)
+	Pxs := make([]*big.Int, n)
+	Pys := make([]*big.Int, n)
+	es := make([]*big.Int, n)
+	ss := make([]*big.Int, n)
+	for i := 0; i < n; i++ {
+		pk, sig := pks[i], sigs[i]
+		if len(pk) != 32 || len(sig) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if r.Cmp(P) >= 0 || s.Cmp(N) >= 0 {
+			return false
+		}
+		Px, Py := LiftX(curve, pk)
+		if Px == nil {
+			return false
+		}
+		Rx, Ry := LiftX(curve, sig[:32])
+		if Rx == nil {
+			return false
+		}
+		Rxs[i], Rys[i] = Rx, Ry
+		Pxs[i], Pys[i] = Px, Py
+		es[i] = GetEBIP340(curve, ms[i], pk, sig[:32])
+		ss[i] = s
+	}
+	return batchVerify(curve.(multiMult), N, Rxs, Rys, Pxs, Pys, es, ss)
+}
+
+// batchVerify -- checks (-Σ a[i]*s[i])G + Σ a[i]*R[i] + Σ a[i]*e[i]*P[i] is the infinity.
+func batchVerify(mm multiMult, N *big.Int, Rxs, Rys, Pxs, Pys, es, ss []*big.Int) bool {
+	n := len(ss)
+	Xs := make([]*big.Int, 0, 2*n)
+	Ys := make([]*big.Int, 0, 2*n)
+	scalars := make([][]byte, 0, 2*n)
+	sum := new(big.Int)
+	for i := 0; i < n; i++ {
+		a := big.NewInt(1)
+		if i > 0 {
+			var err error
+			if a, err = randWeight(); err != nil {
+				return false
+			}
+		}
+
+		// a*s
+		as := new(big.Int).Mul(a, ss[i])
+		sum.Add(sum, as)
+
+		// a*e
+		ae := new(big.Int).Mul(a, es[i])
+		ae.Mod(ae, N)
+
+		Xs = append(Xs, Rxs[i], Pxs[i])
+		Ys = append(Ys, Rys[i], Pys[i])
+		scalars = append(scalars, IntToByte(a), IntToByte(ae))
+	}
+	sum.Mod(sum, N)
+	sum.Sub(N, sum)
+	sum.Mod(sum, N)
+
+	x, y := mm.MultiMult(Xs, Ys, IntToByte(sum), scalars)
+	return x.Sign() == 0 && y.Sign() == 0
+}
+
+// randWeight -- returns a random 128-bit weight in [1, 2^128], a forged batch passes with the
+// probability 2^-128 and the short a[i]*R[i] needs half of the doublings.
+func randWeight() (*big.Int, error) {
+	a, err := rand.Int(rand.Reader, weightBound)
+	if err != nil {
+		return nil, err
+	}
+	return a.Add(a, big.NewInt(1)), nil
+}
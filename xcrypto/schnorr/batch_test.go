@@ -0,0 +1,153 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package schnorr
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+	"github.com/stretchr/testify/assert"
+)
+
+func batchKey(i int) *ecdsa.PrivateKey {
+	curve := secp256k1.SECP256K1()
+	prv := &ecdsa.PrivateKey{D: big.NewInt(int64(i + 1))}
+	prv.Curve = curve
+	prv.X, prv.Y = curve.ScalarBaseMult(prv.D.Bytes())
+	return prv
+}
+
+func TestBatchVerify(t *testing.T) {
+	var pubs []*ecdsa.PublicKey
+	var ms [][]byte
+	var rs, ss []*big.Int
+	for i := 0; i < 16; i++ {
+		prv := batchKey(i)
+		m := sha256.Sum256([]byte{byte(i)})
+		r, s, err := Sign(prv, m[:])
+		assert.Nil(t, err)
+		assert.True(t, Verify(&prv.PublicKey, m[:], r, s))
+
+		pubs = append(pubs, &prv.PublicKey)
+		ms = append(ms, m[:])
+		rs = append(rs, r)
+		ss = append(ss, s)
+	}
+	assert.True(t, BatchVerify(pubs, ms, rs, ss))
+	assert.True(t, BatchVerify(nil, nil, nil, nil))
+	assert.False(t, BatchVerify(pubs, ms[1:], rs, ss))
+
+	// Bad s.
+	{
+		bad := append([]*big.Int{}, ss...)
+		bad[7] = new(big.Int).Add(ss[7], big.NewInt(1))
+		assert.False(t, BatchVerify(pubs, ms, rs, bad))
+	}
+
+	// Swapped messages.
+	{
+		bad := append([][]byte{}, ms...)
+		bad[3], bad[4] = ms[4], ms[3]
+		assert.False(t, BatchVerify(pubs, bad, rs, ss))
+	}
+
+	// r is not on the curve.
+	{
+		bad := append([]*big.Int{}, rs...)
+		bad[0] = big.NewInt(5)
+		assert.False(t, BatchVerify(pubs, ms, bad, ss))
+	}
+}
+
+func TestBatchVerifyBIP340(t *testing.T) {
+	var pks, ms, sigs [][]byte
+	for _, test := range bip340Tests {
+		if !test.result {
+			continue
+		}
+		pk, _ := hex.DecodeString(test.pk)
+		m, _ := hex.DecodeString(test.m)
+		sig, _ := hex.DecodeString(test.sig)
+		pks = append(pks, pk)
+		ms = append(ms, m)
+		sigs = append(sigs, sig)
+	}
+	for i := 0; i < 16; i++ {
+		prv := batchKey(i)
+		m := sha256.Sum256([]byte{byte(i)})
+		sig, err := SignBIP340(prv, m[:], make([]byte, 32))
+		assert.Nil(t, err)
+		pks = append(pks, IntToByte(prv.X))
+		ms = append(ms, m[:])
+		sigs = append(sigs, sig)
+	}
+	assert.True(t, BatchVerifyBIP340(pks, ms, sigs))
+	assert.True(t, BatchVerifyBIP340(nil, nil, nil))
+	assert.False(t, BatchVerifyBIP340(pks, ms, sigs[1:]))
+
+	// Every invalid vector fails the batch.
+	for _, test := range bip340Tests {
+		if test.result {
+			continue
+		}
+		pk, _ := hex.DecodeString(test.pk)
+		m, _ := hex.DecodeString(test.m)
+		sig, _ := hex.DecodeString(test.sig)
+		assert.False(t, BatchVerifyBIP340(append(pks, pk), append(ms, m), append(sigs, sig)), test.desc)
+	}
+
+	// Bad s.
+	{
+		bad := append([][]byte{}, sigs...)
+		sig := append([]byte{}, sigs[5]...)
+		sig[63] ^= 0x01
+		bad[5] = sig
+		assert.False(t, BatchVerifyBIP340(pks, ms, bad))
+	}
+}
+
+func BenchmarkBatchVerifyBIP340(b *testing.B) {
+	var pks, ms, sigs [][]byte
+	for i := 0; i < 64; i++ {
+		prv := batchKey(i)
+		m := sha256.Sum256([]byte{byte(i)})
+		sig, err := SignBIP340(prv, m[:], make([]byte, 32))
+		if err != nil {
+			panic(err)
+		}
+		pks = append(pks, IntToByte(prv.X))
+		ms = append(ms, m[:])
+		sigs = append(sigs, sig)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if !BatchVerifyBIP340(pks, ms, sigs) {
+			panic("batch.verify.failed")
+		}
+	}
+}
+
+func BenchmarkVerifyBIP340(b *testing.B) {
+	prv := batchKey(0)
+	m := sha256.Sum256([]byte{0x00})
+	sig, err := SignBIP340(prv, m[:], make([]byte, 32))
+	if err != nil {
+		panic(err)
+	}
+	pk := IntToByte(prv.X)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if !VerifyBIP340(pk, m[:], sig) {
+			panic("verify.failed")
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package schnorr
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+)
+
+// TaggedHash -- returns the BIP340 tagged hash sha256(sha256(tag) || sha256(tag) || msgs).
+func TaggedHash(tag string, msgs ...[]byte) []byte {
+	th := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(th[:])
+	h.Write(th[:])
+	for _, msg := range msgs {
+		h.Write(msg)
+	}
+	return h.Sum(nil)
+}
+
+// SignBIP340 -- signature with BIP340 Schnorr, returning a 64 byte signature.
+// https://github.com/bitcoin/bips/blob/master/bip-0340.mediawiki#default-signing
+// Input:
+//
+//	The secret key sk: a 32-byte array
+//	The message m: a 32-byte array
+//	Auxiliary random data a: a 32-byte array
+//
+// To sign m for public key bytes(dG):
+//
+//	Let d' = int(sk), fail if d' = 0 or d' ≥ n
+//	Let P = d'G
+//	Let d = d' if has_even_y(P), otherwise let d = n - d'
+//	Let t be the byte-wise xor of bytes(d) and hash_BIP0340/aux(a)
+//	Let rand = hash_BIP0340/nonce(t || bytes(P) || m)
+//	Let k' = int(rand) mod n, fail if k' = 0
+//	Let R = k'G
+//	Let k = k' if has_even_y(R), otherwise let k = n - k'
+//	Let e = int(hash_BIP0340/challenge(bytes(R) || bytes(P) || m)) mod n
+//	The signature is bytes(R) || bytes((k + ed) mod n)
+func SignBIP340(prv *ecdsa.PrivateKey, m []byte, aux []byte) ([]byte, error) {
+	curve := prv.Curve
+	N := curve.Params().N
+
+	if prv.D.Sign() == 0 || prv.D.Cmp(N) >= 0 {
+		return nil, errors.New("secret key is out of range")
+	}
+	if len(aux) != 32 {
+		return nil, errors.New("aux must be 32 bytes")
+	}
+
+	// P = d'G
+	Px, Py := curve.ScalarBaseMult(IntToByte(prv.D))
+	d := new(big.Int).Set(prv.D)
+	if Py.Bit(0) == 1 {
+		d.Sub(N, d)
+	}
+	db := IntToByte(d)
+	pX := IntToByte(Px)
+
+	// t = bytes(d) xor hash_BIP0340/aux(a)
+	t := TaggedHash("BIP0340/aux", aux)
+	for i := range t {
+		t[i] ^= db[i]
+	}
+
+	// k' = int(hash_BIP0340/nonce(t || bytes(P) || m)) mod n
+	k := new(big.Int).SetBytes(TaggedHash("BIP0340/nonce", t, pX, m))
+	k.Mod(k, N)
+	zeroSlice(t)
+	if k.Sign() == 0 {
+		return nil, errors.New("k is zero")
+	}
+
+	// R = k'G
+	Rx, Ry := curve.ScalarBaseMult(IntToByte(k))
+	if Ry.Bit(0) == 1 {
+		k.Sub(N, k)
+	}
+	rX := IntToByte(Rx)
+
+	// s = k + ed
+	e := GetEBIP340(curve, m, pX, rX)
+	s := e.Mul(e, d)
+	s.Add(s, k)
+	s.Mod(s, N)
+
+	// Clean.
+	zeroSlice(db)
+	d.SetInt64(0)
+	k.SetInt64(0)
+
+	sig := make([]byte, 64)
+	copy(sig[:32], rX)
+	copy(sig[32:], IntToByte(s))
+	return sig, nil
+}
+
+// VerifyBIP340 -- verify the BIP340 signature against the 32-byte x-only public key.
+// https://github.com/bitcoin/bips/blob/master/bip-0340.mediawiki#verification
+// Input:
+//
+//	The public key pk: a 32-byte array
+//	The message m: a 32-byte array
+//	A signature sig: a 64-byte array
+//
+//	Let P = lift_x(int(pk)); fail if that fails
+//	Let r = int(sig[0:32]); fail if r ≥ p
+//	Let s = int(sig[32:64]); fail if s ≥ n
+//	Let e = int(hash_BIP0340/challenge(bytes(r) || bytes(P) || m)) mod n
+//	Let R = sG - eP
+//	Fail if is_infinite(R), not has_even_y(R) or x(R) ≠ r
+func VerifyBIP340(pk []byte, m []byte, sig []byte) bool {
+	if len(pk) != 32 || len(sig) != 64 {
+		return false
+	}
+	curve := secp256k1.SECP256K1()
+	P := curve.Params().P
+	N := curve.Params().N
+
+	Px, Py := LiftX(curve, pk)
+	if Px == nil {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if r.Cmp(P) >= 0 || s.Cmp(N) >= 0 {
+		return false
+	}
+
+	e := GetEBIP340(curve, m, pk, sig[:32])
+
+	// R=sG-eP
+	Rx, Ry := SubMult(curve, Px, Py, s, e)
+	if (Rx.Sign() == 0 && Ry.Sign() == 0) || Ry.Bit(0) == 1 || Rx.Cmp(r) != 0 {
+		return false
+	}
+	return true
+}
+
+// LiftX -- returns the point with the x coordinate and an even y, nil if x is not on the curve.
+func LiftX(curve elliptic.Curve, x []byte) (*big.Int, *big.Int) {
+	P := curve.Params().P
+	X := new(big.Int).SetBytes(x)
+	if X.Cmp(P) >= 0 {
+		return nil, nil
+	}
+
+	// y^2 = x^3 + b
+	c := new(big.Int).Exp(X, big.NewInt(3), P)
+	c.Add(c, curve.Params().B)
+	c.Mod(c, P)
+	Y := new(big.Int).ModSqrt(c, P)
+	if Y == nil {
+		return nil, nil
+	}
+	if Y.Bit(0) == 1 {
+		Y.Sub(P, Y)
+	}
+	return X, Y
+}
+
+// GetEBIP340 -- used get e under BIP340, pX and rX are the 32-byte x coords.
+func GetEBIP340(curve elliptic.Curve, m []byte, pX []byte, rX []byte) *big.Int {
+	N := curve.Params().N
+	h := TaggedHash("BIP0340/challenge", rX, pX, m)
+	i := new(big.Int).SetBytes(h)
+	return i.Mod(i, N)
+}
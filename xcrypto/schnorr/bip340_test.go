@@ -0,0 +1,126 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package schnorr
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+	"github.com/stretchr/testify/assert"
+)
+
+// https://github.com/bitcoin/bips/blob/master/bip-0340/test-vectors.csv
+var (
+	bip340Tests = []struct {
+		d      string
+		pk     string
+		aux    string
+		m      string
+		sig    string
+		result bool
+		desc   string
+	}{
+		{
+			d:      "0000000000000000000000000000000000000000000000000000000000000003",
+			pk:     "F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+			aux:    "0000000000000000000000000000000000000000000000000000000000000000",
+			m:      "0000000000000000000000000000000000000000000000000000000000000000",
+			sig:    "E907831F80848D1069A5371B402410364BDF1C5F8307B0084C55F1CE2DCA821525F66A4A85EA8B71E482A74F382D2CE5EBEEE8FDB2172F477DF4900D310536C0",
+			result: true,
+		},
+		{
+			d:      "B7E151628AED2A6ABF7158809CF4F3C762E7160F38B4DA56A784D9045190CFEF",
+			pk:     "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			aux:    "0000000000000000000000000000000000000000000000000000000000000001",
+			m:      "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			sig:    "6896BD60EEAE296DB48A229FF71DFE071BDE413E6D43F917DC8DCF8C78DE33418906D11AC976ABCCB20B091292BFF4EA897EFCB639EA871CFA95F6DE339E4B0A",
+			result: true,
+		},
+		{
+			d:      "C90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B14E5C9",
+			pk:     "DD308AFEC5777E13121FA72B9CC1B7CC0139715309B086C960E18FD969774EB8",
+			aux:    "C87AA53824B4D7AE2EB035A2B5BBBCCC080E76CDC6D1692C4B0B62D798E6D906",
+			m:      "7E2D58D8B3BCDF1ABADEC7829054F90DDA9805AAB56C77333024B9D0A508B75C",
+			sig:    "5831AAEED7B44BB74E5EAB94BA9D4294C49BCF2A60728D8B4C200F50DD313C1BAB745879A5AD954A72C45A91C3A51D3C7ADEA98D82F8481E0E1E03674A6F3FB7",
+			result: true,
+		},
+		{
+			d:      "0B432B2677937381AEF05BB02A66ECD012773062CF3FA2549E44F58ED2401710",
+			pk:     "25D1DFF95105F5253C4022F628A996AD3A0D95FBF21D468A1B33F8C160D8F517",
+			aux:    "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF",
+			m:      "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF",
+			sig:    "7EB0509757E246F19449885651611CB965ECC1A187DD51B64FDA1EDC9637D5EC97582B9CB13DB3933705B32BA982AF5AF25FD78881EBB32771FC5922EFC66EA3",
+			result: true,
+		},
+		{
+			pk:     "D69C3509BB99E412E68B0FE8544E72837DFA30746D8BE2AA65975F29D22DC7B9",
+			m:      "4DF3C3F68FCC83B27E9D42C90431A72499F17875C81A599B566C9889B9696703",
+			sig:    "00000000000000000000003B78CE563F89A0ED9414F5AA28AD0D96D6795F9C6376AFB1548AF603B3EB45C9F8207DEE1060CB71C04E80F593060B07D28308D7F4",
+			result: true,
+		},
+		{
+			pk:     "EEFDEA4CDB677750A420FEE807EACF21EB9898AE79B9768766E4FAA04A2D4A34",
+			m:      "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			sig:    "6CFF5C3BA86C69EA4B7376F31A9BCB4F74C1976089B2D9963DA2E5543E17776969E89B4C5564D00349106B8497785DD7D1D713A8AE82B32FA79D5F7FC407D39B",
+			result: false,
+			desc:   "public key not on the curve",
+		},
+		{
+			pk:     "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			m:      "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			sig:    "FFF97BD5755EEEA420453A14355235D382F6472F8568A18B2F057A14602975563CC27944640AC607CD107AE10923D9EF7A73C643E166BE5EBEAFA34B1AC553E2",
+			result: false,
+			desc:   "has_even_y(R) is false",
+		},
+		{
+			pk:     "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			m:      "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			sig:    "6CFF5C3BA86C69EA4B7376F31A9BCB4F74C1976089B2D9963DA2E5543E177769FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141",
+			result: false,
+			desc:   "sig[32:64] is equal to curve order",
+		},
+	}
+)
+
+func TestBIP340Sign(t *testing.T) {
+	curve := secp256k1.SECP256K1()
+	for _, test := range bip340Tests {
+		if test.d == "" {
+			continue
+		}
+		d, _ := new(big.Int).SetString(test.d, 16)
+		prv := &ecdsa.PrivateKey{
+			D: d,
+		}
+		prv.Curve = curve
+
+		mbytes, _ := hex.DecodeString(test.m)
+		aux, _ := hex.DecodeString(test.aux)
+		sig, err := SignBIP340(prv, mbytes, aux)
+		assert.Nil(t, err)
+		assert.Equal(t, strings.ToLower(test.sig), hex.EncodeToString(sig))
+	}
+}
+
+func TestBIP340Verify(t *testing.T) {
+	for _, test := range bip340Tests {
+		pk, _ := hex.DecodeString(test.pk)
+		mbytes, _ := hex.DecodeString(test.m)
+		sig, _ := hex.DecodeString(test.sig)
+		got := VerifyBIP340(pk, mbytes, sig)
+		assert.Equal(t, test.result, got, test.desc)
+	}
+}
+
+func TestTaggedHash(t *testing.T) {
+	// The tagged hash of an empty message with the empty tag is sha256(sha256("") || sha256("")).
+	got := TaggedHash("")
+	assert.Equal(t, "2dba5dbc339e7316aea2683faf839c1b7b1ee2313db792112588118df066aa35", hex.EncodeToString(got))
+}
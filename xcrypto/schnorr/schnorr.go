@@ -94,18 +94,40 @@ func Verify(pub *ecdsa.PublicKey, m []byte, r *big.Int, s *big.Int) bool {
 	}
 
 	e := GetE(curve, m, pub.X, pub.Y, IntToByte(r))
+
+	// R=sG-eP
+	Rx, Ry := SubMult(curve, pub.X, pub.Y, s, e)
+	if (Rx.Sign() == 0 && Ry.Sign() == 0) || (big.Jacobi(Ry, P) != 1) || (Rx.Cmp(r) != 0) {
+		return false
+	}
+	return true
+}
+
+// combinedMult -- the curve which computes baseScalar*G + scalar*P in one pass, such as secp256k1.
+type combinedMult interface {
+	CombinedMult(Px, Py *big.Int, baseScalar, scalar []byte) (x, y *big.Int)
+}
+
+// SubMult -- returns sG-eP, in one pass if the curve has CombinedMult.
+func SubMult(curve elliptic.Curve, Px, Py *big.Int, s *big.Int, e *big.Int) (*big.Int, *big.Int) {
+	P := curve.Params().P
+	N := curve.Params().N
+
+	// sG-eP=sG+(N-e)P
+	if cm, ok := curve.(combinedMult); ok {
+		ne := new(big.Int).Sub(N, e)
+		ne.Mod(ne, N)
+		return cm.CombinedMult(Px, Py, IntToByte(s), IntToByte(ne))
+	}
+
 	sGx, sGy := curve.ScalarBaseMult(IntToByte(s))
-	ePx, ePy := curve.ScalarMult(pub.X, pub.Y, IntToByte(e))
+	ePx, ePy := curve.ScalarMult(Px, Py, IntToByte(e))
 
 	// eP Inverse.
 	ePy.Sub(P, ePy)
 
 	// R=sG-eP=sG+(eP inverse)
-	Rx, Ry := curve.Add(sGx, sGy, ePx, ePy)
-	if (Rx.Sign() == 0 && Ry.Sign() == 0) || (big.Jacobi(Ry, P) != 1) || (Rx.Cmp(r) != 0) {
-		return false
-	}
-	return true
+	return curve.Add(sGx, sGy, ePx, ePy)
 }
 
 // GetK0 -- used get k0 under schnorr BIP.
@@ -50,6 +50,9 @@ func (alice *EcdsaAlice) ScriptlessPhase5(shareR *secp256k1.Scalar, sign2 *big.I
 	kinv := alice.kinv
 	encprv := alice.encprv
 
+	if err := encprv.PubKey().ValidCiphertext(sign2); err != nil {
+		return nil, err
+	}
 	sig, err := encprv.Decrypt(sign2)
 	if err != nil {
 		return nil, err
@@ -117,6 +120,9 @@ func (bob *EcdsaBob) ScriptlessPhase5(shareR *secp256k1.Scalar, sign2 *big.Int)
 	secret := bob.secret
 	tinv := new(big.Int).ModInverse(secret, N)
 
+	if err := encprv.PubKey().ValidCiphertext(sign2); err != nil {
+		return nil, err
+	}
 	sig, err := encprv.Decrypt(sign2)
 	if err != nil {
 		return nil, err
@@ -127,18 +133,13 @@ func (bob *EcdsaBob) ScriptlessPhase5(shareR *secp256k1.Scalar, sign2 *big.Int)
 
 // ScriptlessPhase6 -- returns the DER signature.
 func (bob *EcdsaBob) ScriptlessPhase6(shareR *secp256k1.Scalar, sig *big.Int) ([]byte, error) {
-	N := bob.N
-
 	s := new(big.Int).Set(sig)
-	halfOrder := new(big.Int).Rsh(N, 1)
-	if s.Cmp(halfOrder) == 1 {
-		s.Sub(N, s)
-	}
 	if s.Sign() == 0 {
 		return nil, errors.New("calculated S is zero")
 	}
 	esig := NewSignatureEcdsa()
 	esig.R = shareR.X
 	esig.S = s
+	esig.NormalizeS()
 	return esig.Serialize()
 }
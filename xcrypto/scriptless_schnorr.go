@@ -0,0 +1,398 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/keyfuse/tokucore/xcrypto/schnorr"
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+)
+
+// The BIP340 adaptor signature locks a Schnorr signature to the adaptor
+// point T = t*G: the pre-signature is not a valid signature, but anyone
+// who knows t can adapt it into one, and anyone who sees both can extract t.
+//
+// The pre-signature is the 33-byte compressed R = k*G + T and the 32-byte s'.
+// Let h = 1 if R has an even y, otherwise h = -1, and e the BIP340 challenge
+// of R, P and m, then s' = h*k + e*d and the signature is (R.x, s' + h*t).
+// The pre-signature is valid if s'*G = h*(R - T) + e*P.
+//
+// The SchnorrAdaptorParty signs with the MuSig aggregated key of two parties,
+// the key share xi is weighted by ai = hash(L || Xi) and the nonces are
+// committed before they are revealed.
+
+const (
+	// SchnorrPresignatureSize -- the size of the BIP340 adaptor pre-signature.
+	SchnorrPresignatureSize = 65
+)
+
+// SchnorrAdaptorSign -- returns the BIP340 pre-signature of the hash under the adaptor point T.
+func SchnorrAdaptorSign(prv *PrvKey, hash []byte, T *PubKey) ([]byte, error) {
+	N := prv.Curve.Params().N
+	if T == nil || !prv.Curve.IsOnCurve(T.X, T.Y) {
+		return nil, fmt.Errorf("schnorr.adaptor.point.invalid")
+	}
+
+	// d = d' if has_even_y(P), otherwise d = n - d'
+	d := new(big.Int).Set(prv.D)
+	if prv.Y.Bit(0) == 1 {
+		d.Sub(N, d)
+	}
+	defer d.SetInt64(0)
+
+	k, err := randModN()
+	if err != nil {
+		return nil, err
+	}
+	defer k.SetInt64(0)
+	R := pointBaseMult(k).Add(T)
+	if R.X.Sign() == 0 && R.Y.Sign() == 0 {
+		return nil, fmt.Errorf("schnorr.adaptor.nonce.infinity")
+	}
+	if R.Y.Bit(0) == 1 {
+		k.Sub(N, k)
+	}
+
+	// s' = h*k + e*d
+	e := schnorrChallenge(R, prv.PubKey(), hash)
+	s := e.Mul(e, d)
+	s.Add(s, k)
+	s.Mod(s, N)
+	return schnorrPresignature(R, s), nil
+}
+
+// SchnorrAdaptorVerify -- verifies the pre-signature of the hash against the x-only public key of pub and the adaptor point T.
+func SchnorrAdaptorVerify(pub *PubKey, hash []byte, T *PubKey, presig []byte) error {
+	R, s, err := parseSchnorrPresignature(presig)
+	if err != nil {
+		return err
+	}
+	if T == nil || !R.Curve.IsOnCurve(T.X, T.Y) {
+		return fmt.Errorf("schnorr.adaptor.point.invalid")
+	}
+	P, err := PubKeyFromXOnly(pub.SerializeXOnly())
+	if err != nil {
+		return err
+	}
+
+	// s'*G = h*(R - T) + e*P
+	Rk := R.Add(pointNeg(T))
+	if R.Y.Bit(0) == 1 {
+		Rk = pointNeg(Rk)
+	}
+	e := schnorrChallenge(R, P, hash)
+	if !pointEqual(pointBaseMult(s), Rk.Add(pointMult(P, e))) {
+		return fmt.Errorf("schnorr.adaptor.presignature.verify.failed")
+	}
+	return nil
+}
+
+// SchnorrAdaptorAdapt -- adapts the pre-signature with the adaptor secret t.
+// Return the 64-byte BIP340 signature.
+func SchnorrAdaptorAdapt(presig []byte, t *big.Int) ([]byte, error) {
+	R, s, err := parseSchnorrPresignature(presig)
+	if err != nil {
+		return nil, err
+	}
+	N := R.Curve.Params().N
+
+	// s = s' + h*t
+	if R.Y.Bit(0) == 1 {
+		s.Sub(s, t)
+	} else {
+		s.Add(s, t)
+	}
+	s.Mod(s, N)
+	sig := make([]byte, 64)
+	copy(sig[:32], R.SerializeXOnly())
+	copy(sig[32:], schnorr.IntToByte(s))
+	return sig, nil
+}
+
+// SchnorrAdaptorExtract -- extracts the adaptor secret t from the pre-signature and the adapted signature.
+func SchnorrAdaptorExtract(presig []byte, sig []byte) (*big.Int, error) {
+	R, s1, err := parseSchnorrPresignature(presig)
+	if err != nil {
+		return nil, err
+	}
+	N := R.Curve.Params().N
+	if len(sig) != 64 {
+		return nil, fmt.Errorf("schnorr.signature.size[%v].invalid", len(sig))
+	}
+	if !bytes.Equal(sig[:32], R.SerializeXOnly()) {
+		return nil, fmt.Errorf("schnorr.adaptor.signature.nonce.mismatch")
+	}
+
+	// t = h*(s - s')
+	t := new(big.Int).SetBytes(sig[32:])
+	if R.Y.Bit(0) == 1 {
+		t.Sub(s1, t)
+	} else {
+		t.Sub(t, s1)
+	}
+	return t.Mod(t, N), nil
+}
+
+// SchnorrAdaptorParty -- the party of the two-party BIP340 signature with the MuSig aggregated key.
+type SchnorrAdaptorParty struct {
+	N       *big.Int
+	curve   elliptic.Curve
+	prv     *PrvKey
+	pub     *PubKey
+	pub2    *PubKey
+	coef    *big.Int
+	coef2   *big.Int
+	shared  *PubKey
+	hash    []byte
+	k       *big.Int
+	R       *PubKey
+	commit2 []byte
+	R2      *PubKey
+	sharedR *PubKey
+	adaptor *PubKey
+	e       *big.Int
+	s       *big.Int
+}
+
+// NewSchnorrAdaptorParty -- creates new SchnorrAdaptorParty.
+func NewSchnorrAdaptorParty(prv *PrvKey) *SchnorrAdaptorParty {
+	curve := secp256k1.SECP256K1()
+	return &SchnorrAdaptorParty{
+		N:     curve.Params().N,
+		curve: curve,
+		prv:   prv,
+		pub:   prv.PubKey(),
+	}
+}
+
+// Phase1 -- aggregates the public keys of the two parties.
+// Return the aggregated PubKey, its x-only key is the BIP340 public key.
+func (party *SchnorrAdaptorParty) Phase1(pub2 *PubKey) (*PubKey, error) {
+	if party.prv == nil {
+		return nil, fmt.Errorf("mpc.party.closed")
+	}
+	if pub2 == nil || !party.curve.IsOnCurve(pub2.X, pub2.Y) {
+		return nil, fmt.Errorf("mpc.adaptor.pubkey.invalid")
+	}
+	me := party.pub.SerializeCompressed()
+	other := pub2.SerializeCompressed()
+	order := bytes.Compare(me, other)
+	if order == 0 {
+		return nil, fmt.Errorf("mpc.adaptor.pubkey.duplicate")
+	}
+
+	// L = hash(sorted keys), ai = hash(L || Xi).
+	var L []byte
+	if order < 0 {
+		L = schnorr.TaggedHash("KeyAgg list", me, other)
+	} else {
+		L = schnorr.TaggedHash("KeyAgg list", other, me)
+	}
+	coef := new(big.Int).SetBytes(schnorr.TaggedHash("KeyAgg coefficient", L, me))
+	coef.Mod(coef, party.N)
+	coef2 := new(big.Int).SetBytes(schnorr.TaggedHash("KeyAgg coefficient", L, other))
+	coef2.Mod(coef2, party.N)
+
+	shared := pointMult(party.pub, coef).Add(pointMult(pub2, coef2))
+	if shared.X.Sign() == 0 && shared.Y.Sign() == 0 {
+		return nil, fmt.Errorf("mpc.adaptor.pubkey.infinity")
+	}
+	party.pub2 = pub2
+	party.coef = coef
+	party.coef2 = coef2
+	party.shared = shared
+	return shared, nil
+}
+
+// Phase2 -- generates the nonce for the hash.
+// Return the nonce commitment to send to the other party.
+func (party *SchnorrAdaptorParty) Phase2(hash []byte) ([]byte, error) {
+	if party.shared == nil {
+		return nil, fmt.Errorf("mpc.adaptor.phase1.missing")
+	}
+	k, err := randModN()
+	if err != nil {
+		return nil, err
+	}
+	party.clearNonce()
+	party.hash = hash
+	party.k = k
+	party.R = pointBaseMult(k)
+	party.commit2 = nil
+	party.R2 = nil
+	party.sharedR = nil
+	party.s = nil
+	return schnorrNonceCommit(party.R), nil
+}
+
+// Phase3 -- keeps the nonce commitment of the other party.
+// Return the nonce to send to the other party.
+func (party *SchnorrAdaptorParty) Phase3(commit2 []byte) (*PubKey, error) {
+	if party.k == nil {
+		return nil, fmt.Errorf("mpc.adaptor.phase2.missing")
+	}
+	if len(commit2) != sha256.Size {
+		return nil, fmt.Errorf("mpc.adaptor.commitment.size[%v].invalid", len(commit2))
+	}
+	party.commit2 = commit2
+	return party.R, nil
+}
+
+// Phase4 -- checks the nonce of the other party against its commitment, T is the adaptor point or nil for a plain signature.
+// Return the partial signature to send to the other party.
+func (party *SchnorrAdaptorParty) Phase4(R2 *PubKey, T *PubKey) (*big.Int, error) {
+	N := party.N
+	if party.k == nil || party.commit2 == nil {
+		return nil, fmt.Errorf("mpc.adaptor.phase3.missing")
+	}
+	if R2 == nil || !party.curve.IsOnCurve(R2.X, R2.Y) {
+		return nil, fmt.Errorf("mpc.adaptor.nonce.invalid")
+	}
+	if !bytes.Equal(party.commit2, schnorrNonceCommit(R2)) {
+		return nil, fmt.Errorf("mpc.adaptor.nonce.commitment.mismatch")
+	}
+	sharedR := party.R.Add(R2)
+	if T != nil {
+		if !party.curve.IsOnCurve(T.X, T.Y) {
+			return nil, fmt.Errorf("schnorr.adaptor.point.invalid")
+		}
+		sharedR = sharedR.Add(T)
+	}
+	if sharedR.X.Sign() == 0 && sharedR.Y.Sign() == 0 {
+		return nil, fmt.Errorf("mpc.adaptor.nonce.infinity")
+	}
+
+	// si = h*ki + e*g*ai*xi
+	e := schnorrChallenge(sharedR, party.shared, party.hash)
+	k := new(big.Int).Set(party.k)
+	if sharedR.Y.Bit(0) == 1 {
+		k.Sub(N, k)
+	}
+	d := new(big.Int).Mul(party.coef, party.prv.D)
+	if party.shared.Y.Bit(0) == 1 {
+		d.Neg(d)
+	}
+	s := d.Mul(d, e)
+	s.Add(s, k)
+	s.Mod(s, N)
+	k.SetInt64(0)
+	party.clearNonce()
+
+	party.R2 = R2
+	party.sharedR = sharedR
+	party.adaptor = T
+	party.e = e
+	party.s = s
+	return new(big.Int).Set(s), nil
+}
+
+// Phase5 -- checks the partial signature of the other party and combines the two.
+// Return the 65-byte pre-signature if there is an adaptor point, otherwise the 64-byte BIP340 signature.
+func (party *SchnorrAdaptorParty) Phase5(s2 *big.Int) ([]byte, error) {
+	N := party.N
+	if party.s == nil {
+		return nil, fmt.Errorf("mpc.adaptor.phase4.missing")
+	}
+	if s2 == nil || s2.Sign() < 0 || s2.Cmp(N) >= 0 {
+		return nil, fmt.Errorf("mpc.adaptor.partial.invalid")
+	}
+
+	// s2*G = h*R2 + e*g*a2*X2
+	R2 := party.R2
+	if party.sharedR.Y.Bit(0) == 1 {
+		R2 = pointNeg(R2)
+	}
+	X2 := pointMult(party.pub2, party.coef2)
+	if party.shared.Y.Bit(0) == 1 {
+		X2 = pointNeg(X2)
+	}
+	if !pointEqual(pointBaseMult(s2), R2.Add(pointMult(X2, party.e))) {
+		return nil, fmt.Errorf("mpc.adaptor.partial.verify.failed")
+	}
+
+	s := new(big.Int).Add(party.s, s2)
+	s.Mod(s, N)
+	party.s = nil
+	if party.adaptor != nil {
+		presig := schnorrPresignature(party.sharedR, s)
+		if err := SchnorrAdaptorVerify(party.shared, party.hash, party.adaptor, presig); err != nil {
+			return nil, err
+		}
+		return presig, nil
+	}
+	sig := make([]byte, 64)
+	copy(sig[:32], party.sharedR.SerializeXOnly())
+	copy(sig[32:], schnorr.IntToByte(s))
+	if err := SchnorrBIP340Verify(party.shared, party.hash, sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// Close -- clears the key share and the nonce of the party.
+func (party *SchnorrAdaptorParty) Close() {
+	party.clearNonce()
+	party.prv = nil
+	party.s = nil
+}
+
+func (party *SchnorrAdaptorParty) clearNonce() {
+	if party.k != nil {
+		party.k.SetInt64(0)
+		party.k = nil
+	}
+}
+
+// schnorrChallenge -- returns the BIP340 challenge of the nonce R and the public key P.
+func schnorrChallenge(R *PubKey, P *PubKey, hash []byte) *big.Int {
+	return schnorr.GetEBIP340(R.Curve, hash, P.SerializeXOnly(), R.SerializeXOnly())
+}
+
+// schnorrNonceCommit -- returns the commitment of the nonce point.
+func schnorrNonceCommit(R *PubKey) []byte {
+	h := sha256.New()
+	h.Write([]byte("mpc.adaptor.nonce"))
+	h.Write(R.SerializeCompressed())
+	return h.Sum(nil)
+}
+
+// schnorrPresignature -- returns the compressed R || s'.
+func schnorrPresignature(R *PubKey, s *big.Int) []byte {
+	presig := make([]byte, SchnorrPresignatureSize)
+	copy(presig[:33], R.SerializeCompressed())
+	copy(presig[33:], schnorr.IntToByte(s))
+	return presig
+}
+
+func parseSchnorrPresignature(presig []byte) (*PubKey, *big.Int, error) {
+	if len(presig) != SchnorrPresignatureSize {
+		return nil, nil, fmt.Errorf("schnorr.adaptor.presignature.size[%v].invalid", len(presig))
+	}
+	R, err := PubKeyFromBytes(presig[:33])
+	if err != nil {
+		return nil, nil, err
+	}
+	s := new(big.Int).SetBytes(presig[33:])
+	if s.Cmp(R.Curve.Params().N) >= 0 {
+		return nil, nil, fmt.Errorf("schnorr.adaptor.presignature.s.invalid")
+	}
+	return R, s, nil
+}
+
+// pointNeg -- returns -P.
+func pointNeg(p *PubKey) *PubKey {
+	P := p.Curve.Params().P
+	y := new(big.Int)
+	if p.Y.Sign() != 0 {
+		y.Sub(P, p.Y)
+	}
+	return &PubKey{X: new(big.Int).Set(p.X), Y: y, Curve: p.Curve}
+}
@@ -0,0 +1,214 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptlessSchnorr(t *testing.T) {
+	hash := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+	secret := new(big.Int).SetInt64(2019)
+	T := pointBaseMult(secret)
+
+	// The pubkey of 0x06 has an odd y, the nonce parity is random so run a few times.
+	for _, key := range []byte{0x02, 0x06} {
+		prv := PrvKeyFromBytes([]byte{key})
+		pub := prv.PubKey()
+		for i := 0; i < 8; i++ {
+			presig, err := SchnorrAdaptorSign(prv, hash, T)
+			assert.Nil(t, err)
+			assert.Equal(t, SchnorrPresignatureSize, len(presig))
+
+			err = SchnorrAdaptorVerify(pub, hash, T, presig)
+			assert.Nil(t, err)
+
+			// Wrong adaptor point.
+			err = SchnorrAdaptorVerify(pub, hash, pointBaseMult(big.NewInt(2020)), presig)
+			assert.NotNil(t, err)
+
+			// The pre-signature is not a valid signature.
+			bad := append(presig[1:33:33], presig[33:]...)
+			err = SchnorrBIP340Verify(pub, hash, bad)
+			assert.NotNil(t, err)
+
+			// Adapt.
+			sig, err := SchnorrAdaptorAdapt(presig, secret)
+			assert.Nil(t, err)
+			err = SchnorrBIP340Verify(pub, hash, sig)
+			assert.Nil(t, err)
+
+			// Extract.
+			got, err := SchnorrAdaptorExtract(presig, sig)
+			assert.Nil(t, err)
+			assert.Equal(t, secret, got)
+		}
+	}
+}
+
+func TestScriptlessSchnorrErrors(t *testing.T) {
+	hash := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+	prv := PrvKeyFromBytes([]byte{0x02})
+	secret := new(big.Int).SetInt64(2019)
+	T := pointBaseMult(secret)
+
+	_, err := SchnorrAdaptorSign(prv, hash, nil)
+	assert.Equal(t, "schnorr.adaptor.point.invalid", err.Error())
+
+	presig, err := SchnorrAdaptorSign(prv, hash, T)
+	assert.Nil(t, err)
+
+	err = SchnorrAdaptorVerify(prv.PubKey(), hash, T, presig[1:])
+	assert.Equal(t, "schnorr.adaptor.presignature.size[64].invalid", err.Error())
+
+	// Signature of another nonce.
+	sig, err := SchnorrBIP340Sign(prv, hash)
+	assert.Nil(t, err)
+	_, err = SchnorrAdaptorExtract(presig, sig)
+	assert.Equal(t, "schnorr.adaptor.signature.nonce.mismatch", err.Error())
+}
+
+func mockSchnorrAdaptorParties() (*SchnorrAdaptorParty, *SchnorrAdaptorParty, *PubKey) {
+	p1, _ := new(big.Int).SetString("15bafcb56279dbfd985d4d17cdaf9bbfc6701b628f9fb00d6d1e0d2cb503ede3", 16)
+	p2, _ := new(big.Int).SetString("76818c328b8aa1e8f17bd599016fef8134b7d5ec315e0b6373953da7e8b5c0c9", 16)
+	prv1 := PrvKeyFromBytes(p1.Bytes())
+	prv2 := PrvKeyFromBytes(p2.Bytes())
+	alice := NewSchnorrAdaptorParty(prv1)
+	bob := NewSchnorrAdaptorParty(prv2)
+
+	shared1, _ := alice.Phase1(prv2.PubKey())
+	shared2, _ := bob.Phase1(prv1.PubKey())
+	if !pointEqual(shared1, shared2) {
+		panic("shared.pubkey.mismatch")
+	}
+	return alice, bob, shared1
+}
+
+func TestScriptlessSchnorrParty(t *testing.T) {
+	hash := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+	secret := new(big.Int).SetInt64(2019)
+	T := pointBaseMult(secret)
+	alice, bob, shared := mockSchnorrAdaptorParties()
+	defer alice.Close()
+	defer bob.Close()
+
+	for i := 0; i < 4; i++ {
+		// Phase 2.
+		commit1, err := alice.Phase2(hash)
+		assert.Nil(t, err)
+		commit2, err := bob.Phase2(hash)
+		assert.Nil(t, err)
+
+		// Phase 3.
+		R1, err := alice.Phase3(commit2)
+		assert.Nil(t, err)
+		R2, err := bob.Phase3(commit1)
+		assert.Nil(t, err)
+
+		// Phase 4.
+		s1, err := alice.Phase4(R2, T)
+		assert.Nil(t, err)
+		s2, err := bob.Phase4(R1, T)
+		assert.Nil(t, err)
+
+		// Phase 5.
+		presig1, err := alice.Phase5(s2)
+		assert.Nil(t, err)
+		presig2, err := bob.Phase5(s1)
+		assert.Nil(t, err)
+		assert.Equal(t, presig1, presig2)
+
+		err = SchnorrAdaptorVerify(shared, hash, T, presig1)
+		assert.Nil(t, err)
+
+		sig, err := SchnorrAdaptorAdapt(presig1, secret)
+		assert.Nil(t, err)
+		err = SchnorrBIP340Verify(shared, hash, sig)
+		assert.Nil(t, err)
+
+		got, err := SchnorrAdaptorExtract(presig2, sig)
+		assert.Nil(t, err)
+		assert.Equal(t, secret, got)
+	}
+
+	// Plain signature without the adaptor point.
+	{
+		commit1, _ := alice.Phase2(hash)
+		commit2, _ := bob.Phase2(hash)
+		R1, _ := alice.Phase3(commit2)
+		R2, _ := bob.Phase3(commit1)
+		s1, err := alice.Phase4(R2, nil)
+		assert.Nil(t, err)
+		s2, err := bob.Phase4(R1, nil)
+		assert.Nil(t, err)
+		sig, err := alice.Phase5(s2)
+		assert.Nil(t, err)
+		assert.Equal(t, 64, len(sig))
+		sig2, err := bob.Phase5(s1)
+		assert.Nil(t, err)
+		assert.Equal(t, sig, sig2)
+		err = SchnorrBIP340Verify(shared, hash, sig)
+		assert.Nil(t, err)
+	}
+}
+
+func TestScriptlessSchnorrPartyErrors(t *testing.T) {
+	hash := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+	T := pointBaseMult(big.NewInt(2019))
+	alice, bob, _ := mockSchnorrAdaptorParties()
+
+	// Same key.
+	{
+		_, err := alice.Phase1(alice.pub)
+		assert.Equal(t, "mpc.adaptor.pubkey.duplicate", err.Error())
+	}
+
+	// Out of order.
+	{
+		party := NewSchnorrAdaptorParty(PrvKeyFromBytes([]byte{0x02}))
+		_, err := party.Phase2(hash)
+		assert.Equal(t, "mpc.adaptor.phase1.missing", err.Error())
+		_, err = alice.Phase3(make([]byte, 32))
+		assert.Equal(t, "mpc.adaptor.phase2.missing", err.Error())
+		_, err = alice.Phase5(big.NewInt(1))
+		assert.Equal(t, "mpc.adaptor.phase4.missing", err.Error())
+	}
+
+	// The nonce does not match its commitment.
+	{
+		commit1, _ := alice.Phase2(hash)
+		commit2, _ := bob.Phase2(hash)
+		R1, _ := alice.Phase3(commit2)
+		_, err := bob.Phase3(commit1)
+		assert.Nil(t, err)
+		_, err = alice.Phase4(R1, T)
+		assert.Equal(t, "mpc.adaptor.nonce.commitment.mismatch", err.Error())
+	}
+
+	// Bad partial signature.
+	{
+		commit1, _ := alice.Phase2(hash)
+		commit2, _ := bob.Phase2(hash)
+		R1, _ := alice.Phase3(commit2)
+		R2, _ := bob.Phase3(commit1)
+		_, err := alice.Phase4(R2, T)
+		assert.Nil(t, err)
+		s2, err := bob.Phase4(R1, T)
+		assert.Nil(t, err)
+		_, err = alice.Phase5(s2.Add(s2, big.NewInt(1)))
+		assert.Equal(t, "mpc.adaptor.partial.verify.failed", err.Error())
+	}
+
+	// Closed.
+	{
+		alice.Close()
+		_, err := alice.Phase1(bob.pub)
+		assert.Equal(t, "mpc.party.closed", err.Error())
+	}
+}
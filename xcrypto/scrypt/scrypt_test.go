@@ -0,0 +1,84 @@
+// Copyright 2012 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scrypt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+type testVector struct {
+	password string
+	salt     string
+	N, r, p  int
+	output   string
+}
+
+// Test vectors from RFC 7914 section 12.
+var good = []testVector{
+	{
+		"",
+		"",
+		16, 1, 1,
+		"77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906",
+	},
+	{
+		"password",
+		"NaCl",
+		1024, 8, 16,
+		"fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640",
+	},
+	{
+		"pleaseletmein",
+		"SodiumChloride",
+		16384, 8, 1,
+		"7023bdcb3afd7348461c06cd81fd38ebfda8fbba904f8e3ea9b543f6545da1f2d5432955613f0fcf62d49705242a9af9e61e85dc0d651e40dfcf017b45575887",
+	},
+	// // This one takes too long
+	// {
+	// 	"pleaseletmein",
+	// 	"SodiumChloride",
+	// 	1048576, 8, 1,
+	// 	"2101cb9b6a511aaeaddbbe09cf70f881ec568d574a2ffd4dabe5ee9820adaa478e56fd8f4ba5d09ffa1c6d927c40f4c337304049e8a952fbcbf45c6fa77a41a4",
+	// },
+}
+
+var bad = []testVector{
+	{"p", "s", 0, 1, 1, ""},                    // N == 0
+	{"p", "s", 1, 1, 1, ""},                    // N == 1
+	{"p", "s", 7, 8, 1, ""},                    // N is not power of 2
+	{"p", "s", 16, 0, 1, ""},                   // r == 0
+	{"p", "s", 16, 1, 0, ""},                   // p == 0
+	{"p", "s", 16, maxInt / 2, maxInt / 2, ""}, // p * r too large
+}
+
+func TestKey(t *testing.T) {
+	for i, v := range good {
+		k, err := Key([]byte(v.password), []byte(v.salt), v.N, v.r, v.p, 64)
+		if err != nil {
+			t.Errorf("%d: got unexpected error: %s", i, err)
+		}
+		want, _ := hex.DecodeString(v.output)
+		if !bytes.Equal(want, k) {
+			t.Errorf("%d: expected %x, got %x", i, want, k)
+		}
+	}
+	for i, v := range bad {
+		_, err := Key([]byte(v.password), []byte(v.salt), v.N, v.r, v.p, 32)
+		if err == nil {
+			t.Errorf("%d: expected error, got nil", i)
+		}
+	}
+	if _, err := Key([]byte("p"), []byte("s"), 16, 1, 1, 0); err == nil {
+		t.Errorf("expected key length error, got nil")
+	}
+}
+
+func BenchmarkKey(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Key([]byte("password"), []byte("salt"), 1<<14, 8, 1, 64)
+	}
+}
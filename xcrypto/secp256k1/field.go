@@ -0,0 +1,350 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package secp256k1
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+)
+
+const (
+	fieldMask   = 0xfffffffffffff // 2^52 - 1
+	fieldMask48 = 0xffffffffffff  // 2^48 - 1
+	fieldC      = 0x1000003d1     // 2^256 mod p
+	fieldR      = 0x1000003d10    // 2^260 mod p
+)
+
+var (
+	// fieldPrimeBig -- p as big.Int.
+	fieldPrimeBig, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", 16)
+
+	// fieldPrime -- p in 5x52 limbs.
+	fieldPrime = [5]uint64{0xffffefffffc2f, fieldMask, fieldMask, fieldMask, fieldMask48}
+
+	// fieldInvExp -- p-2, the exponent of the inverse.
+	fieldInvExp = [32]byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe, 0xff, 0xff, 0xfc, 0x2d,
+	}
+
+	// fieldSqrtExp -- (p+1)/4, the exponent of the square root.
+	fieldSqrtExp = [32]byte{
+		0x3f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xbf, 0xff, 0xff, 0x0c,
+	}
+)
+
+// fieldVal -- the element of the secp256k1 field in 5x52 limbs, value = sum(n[i] << (52*i)).
+// After each operation the limbs 0..3 are less than 2^52 and the limb 4 is less than 2^49,
+// the value is less than 2p but not fully reduced until normalize.
+// All the operations are constant time.
+type fieldVal struct {
+	n [5]uint64
+}
+
+// uint128 -- the accumulator of the 52-bit limb products.
+type uint128 struct {
+	hi, lo uint64
+}
+
+// mulAdd -- u += a * b.
+func (u *uint128) mulAdd(a uint64, b uint64) {
+	hi, lo := bits.Mul64(a, b)
+	var c uint64
+	u.lo, c = bits.Add64(u.lo, lo, 0)
+	u.hi += hi + c
+}
+
+// add -- u += v.
+func (u *uint128) add(v uint128) {
+	var c uint64
+	u.lo, c = bits.Add64(u.lo, v.lo, 0)
+	u.hi += v.hi + c
+}
+
+// add64 -- u += v.
+func (u *uint128) add64(v uint64) {
+	var c uint64
+	u.lo, c = bits.Add64(u.lo, v, 0)
+	u.hi += c
+}
+
+// rsh52 -- u >>= 52.
+func (u *uint128) rsh52() {
+	u.lo = u.lo>>52 | u.hi<<12
+	u.hi >>= 52
+}
+
+// SetInt -- sets f to the small integer.
+func (f *fieldVal) SetInt(v uint64) *fieldVal {
+	f.n = [5]uint64{v & fieldMask, v >> 52, 0, 0, 0}
+	return f
+}
+
+// SetBytes -- sets f to the 32-byte big-endian value, the value may be not less than p.
+func (f *fieldVal) SetBytes(b *[32]byte) *fieldVal {
+	w3 := binary.BigEndian.Uint64(b[0:8])
+	w2 := binary.BigEndian.Uint64(b[8:16])
+	w1 := binary.BigEndian.Uint64(b[16:24])
+	w0 := binary.BigEndian.Uint64(b[24:32])
+	f.n[0] = w0 & fieldMask
+	f.n[1] = (w0>>52 | w1<<12) & fieldMask
+	f.n[2] = (w1>>40 | w2<<24) & fieldMask
+	f.n[3] = (w2>>28 | w3<<36) & fieldMask
+	f.n[4] = w3 >> 16
+	return f
+}
+
+// SetBig -- sets f to x mod p.
+func (f *fieldVal) SetBig(x *big.Int) *fieldVal {
+	var b [32]byte
+	if x.Sign() < 0 || x.BitLen() > 256 {
+		x = new(big.Int).Mod(x, fieldPrimeBig)
+	}
+	x.FillBytes(b[:])
+	return f.SetBytes(&b)
+}
+
+// Bytes -- returns the 32-byte big-endian value of the normalized f.
+func (f *fieldVal) Bytes() [32]byte {
+	var b [32]byte
+	t := *f
+	t.normalize()
+	binary.BigEndian.PutUint64(b[0:8], t.n[3]>>36|t.n[4]<<16)
+	binary.BigEndian.PutUint64(b[8:16], t.n[2]>>24|t.n[3]<<28)
+	binary.BigEndian.PutUint64(b[16:24], t.n[1]>>12|t.n[2]<<40)
+	binary.BigEndian.PutUint64(b[24:32], t.n[0]|t.n[1]<<52)
+	return b
+}
+
+// Big -- returns the normalized f as big.Int.
+func (f *fieldVal) Big() *big.Int {
+	b := f.Bytes()
+	return new(big.Int).SetBytes(b[:])
+}
+
+// reduce -- carries the limbs and folds the bits above 2^256, keeps the value mod p.
+func (f *fieldVal) reduce() {
+	t0, t1, t2, t3, t4 := f.n[0], f.n[1], f.n[2], f.n[3], f.n[4]
+
+	t1 += t0 >> 52
+	t0 &= fieldMask
+	t2 += t1 >> 52
+	t1 &= fieldMask
+	t3 += t2 >> 52
+	t2 &= fieldMask
+	t4 += t3 >> 52
+	t3 &= fieldMask
+
+	// 2^256 = fieldC mod p.
+	t0 += (t4 >> 48) * fieldC
+	t4 &= fieldMask48
+
+	t1 += t0 >> 52
+	t0 &= fieldMask
+	t2 += t1 >> 52
+	t1 &= fieldMask
+	t3 += t2 >> 52
+	t2 &= fieldMask
+	t4 += t3 >> 52
+	t3 &= fieldMask
+	f.n = [5]uint64{t0, t1, t2, t3, t4}
+}
+
+// normalize -- fully reduces f to [0, p).
+func (f *fieldVal) normalize() *fieldVal {
+	f.reduce()
+
+	// t = f + 2^256 - p, f >= p if t overflows 2^256.
+	t0 := f.n[0] + fieldC
+	t1 := f.n[1] + t0>>52
+	t0 &= fieldMask
+	t2 := f.n[2] + t1>>52
+	t1 &= fieldMask
+	t3 := f.n[3] + t2>>52
+	t2 &= fieldMask
+	t4 := f.n[4] + t3>>52
+	t3 &= fieldMask
+
+	mask := -(t4 >> 48)
+	t4 &= fieldMask48
+	f.n[0] = (t0 & mask) | (f.n[0] &^ mask)
+	f.n[1] = (t1 & mask) | (f.n[1] &^ mask)
+	f.n[2] = (t2 & mask) | (f.n[2] &^ mask)
+	f.n[3] = (t3 & mask) | (f.n[3] &^ mask)
+	f.n[4] = (t4 & mask) | (f.n[4] &^ mask)
+	return f
+}
+
+// cmov -- sets f to g if flag is 1, keeps f if flag is 0.
+func (f *fieldVal) cmov(g *fieldVal, flag uint64) {
+	mask := -flag
+	for i := range f.n {
+		f.n[i] = (g.n[i] & mask) | (f.n[i] &^ mask)
+	}
+}
+
+// IsZero -- returns 1 if f = 0 mod p, otherwise 0.
+func (f *fieldVal) IsZero() uint64 {
+	t := *f
+	t.normalize()
+	return ctIsZero(t.n[0] | t.n[1] | t.n[2] | t.n[3] | t.n[4])
+}
+
+// IsOdd -- returns 1 if the normalized f is odd, otherwise 0.
+func (f *fieldVal) IsOdd() uint64 {
+	t := *f
+	t.normalize()
+	return t.n[0] & 1
+}
+
+// Equals -- returns 1 if f = g mod p, otherwise 0.
+func (f *fieldVal) Equals(g *fieldVal) uint64 {
+	var t fieldVal
+	t.Neg(g)
+	t.Add(f)
+	return t.IsZero()
+}
+
+// Add -- f = f + g.
+func (f *fieldVal) Add(g *fieldVal) *fieldVal {
+	for i := range f.n {
+		f.n[i] += g.n[i]
+	}
+	f.reduce()
+	return f
+}
+
+// Add2 -- f = a + b.
+func (f *fieldVal) Add2(a *fieldVal, b *fieldVal) *fieldVal {
+	*f = *a
+	return f.Add(b)
+}
+
+// Neg -- f = -a.
+func (f *fieldVal) Neg(a *fieldVal) *fieldVal {
+	// 4p limb by limb is larger than any reduced limb.
+	for i := range f.n {
+		f.n[i] = 4*fieldPrime[i] - a.n[i]
+	}
+	f.reduce()
+	return f
+}
+
+// Sub2 -- f = a - b.
+func (f *fieldVal) Sub2(a *fieldVal, b *fieldVal) *fieldVal {
+	var t fieldVal
+	t.Neg(b)
+	return f.Add2(a, &t)
+}
+
+// MulInt -- f = f * v, v must be less than 2^11.
+func (f *fieldVal) MulInt(v uint64) *fieldVal {
+	for i := range f.n {
+		f.n[i] *= v
+	}
+	f.reduce()
+	return f
+}
+
+// Mul2 -- f = a * b.
+func (f *fieldVal) Mul2(a *fieldVal, b *fieldVal) *fieldVal {
+	var t [10]uint128
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 5; j++ {
+			t[i+j].mulAdd(a.n[i], b.n[j])
+		}
+	}
+
+	// Carry the product into 52-bit limbs.
+	var r [10]uint64
+	var c uint128
+	for k := 0; k < 9; k++ {
+		c.add(t[k])
+		r[k] = c.lo & fieldMask
+		c.rsh52()
+	}
+	c.add(t[9])
+	r[9] = c.lo
+
+	// Fold the limbs 5..9 with 2^260 = fieldR mod p.
+	var d uint128
+	var n [5]uint64
+	for k := 0; k < 5; k++ {
+		d.add64(r[k])
+		d.mulAdd(r[k+5], fieldR)
+		n[k] = d.lo & fieldMask
+		d.rsh52()
+	}
+	var e uint128
+	e.add64(n[0])
+	e.mulAdd(d.lo, fieldR)
+	n[0] = e.lo & fieldMask
+	e.rsh52()
+	n[1] += e.lo
+
+	f.n = n
+	f.reduce()
+	return f
+}
+
+// Mul -- f = f * g.
+func (f *fieldVal) Mul(g *fieldVal) *fieldVal {
+	return f.Mul2(f, g)
+}
+
+// Square -- f = f * f.
+func (f *fieldVal) Square() *fieldVal {
+	return f.Mul2(f, f)
+}
+
+// Square2 -- f = a * a.
+func (f *fieldVal) Square2(a *fieldVal) *fieldVal {
+	return f.Mul2(a, a)
+}
+
+// exp -- f = a^e, the exponent e is public.
+func (f *fieldVal) exp(a *fieldVal, e *[32]byte) *fieldVal {
+	var r fieldVal
+	b := *a
+	r.SetInt(1)
+	for _, v := range e {
+		for bit := 7; bit >= 0; bit-- {
+			r.Square()
+			if (v>>uint(bit))&1 == 1 {
+				r.Mul(&b)
+			}
+		}
+	}
+	*f = r
+	return f
+}
+
+// Inverse -- f = a^-1 by Fermat's little theorem, the inverse of 0 is 0.
+func (f *fieldVal) Inverse(a *fieldVal) *fieldVal {
+	return f.exp(a, &fieldInvExp)
+}
+
+// Sqrt -- f = sqrt(a), returns 1 if a is a square, otherwise 0.
+func (f *fieldVal) Sqrt(a *fieldVal) uint64 {
+	var r, s fieldVal
+	r.exp(a, &fieldSqrtExp)
+	s.Square2(&r)
+	ok := s.Equals(a)
+	*f = r
+	return ok
+}
+
+// ctIsZero -- returns 1 if v is 0, otherwise 0.
+func ctIsZero(v uint64) uint64 {
+	return 1 ^ ((v | -v) >> 63)
+}
+
+// ctEq -- returns 1 if a equals b, otherwise 0.
+func ctEq(a uint64, b uint64) uint64 {
+	return ctIsZero(a ^ b)
+}
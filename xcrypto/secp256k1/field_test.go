@@ -0,0 +1,132 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package secp256k1
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randFieldTest(t *testing.T) (*big.Int, fieldVal) {
+	var f fieldVal
+	x, err := rand.Int(rand.Reader, fieldPrimeBig)
+	assert.Nil(t, err)
+	f.SetBig(x)
+	return x, f
+}
+
+func assertBigEqual(t *testing.T, want *big.Int, got *big.Int) {
+	assert.Equal(t, 0, want.Cmp(got), "want[%x].got[%x]", want, got)
+}
+
+func TestField(t *testing.T) {
+	P := fieldPrimeBig
+	for i := 0; i < 200; i++ {
+		a, fa := randFieldTest(t)
+		b, fb := randFieldTest(t)
+
+		// Add.
+		{
+			var f fieldVal
+			f.Add2(&fa, &fb)
+			want := new(big.Int).Add(a, b)
+			assertBigEqual(t, want.Mod(want, P), f.Big())
+		}
+
+		// Sub.
+		{
+			var f fieldVal
+			f.Sub2(&fa, &fb)
+			want := new(big.Int).Sub(a, b)
+			assertBigEqual(t, want.Mod(want, P), f.Big())
+		}
+
+		// Mul.
+		{
+			var f fieldVal
+			f.Mul2(&fa, &fb)
+			want := new(big.Int).Mul(a, b)
+			assertBigEqual(t, want.Mod(want, P), f.Big())
+		}
+
+		// Inverse.
+		{
+			var f fieldVal
+			f.Inverse(&fa)
+			want := new(big.Int).ModInverse(a, P)
+			assertBigEqual(t, want, f.Big())
+		}
+
+		// Sqrt.
+		{
+			var f, sq fieldVal
+			sq.Square2(&fa)
+			assert.Equal(t, uint64(1), f.Sqrt(&sq))
+			assert.Equal(t, uint64(1), f.Equals(&fa)|f.Equals(new(fieldVal).Neg(&fa)))
+		}
+	}
+}
+
+func TestFieldEdges(t *testing.T) {
+	P := fieldPrimeBig
+
+	// The values around p.
+	tests := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		new(big.Int).Sub(P, big.NewInt(1)),
+		new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1)),
+		P,
+	}
+	for _, x := range tests {
+		var b [32]byte
+		var f, g fieldVal
+		x.FillBytes(b[:])
+		f.SetBytes(&b)
+		assertBigEqual(t, new(big.Int).Mod(x, P), f.Big())
+
+		// -x + x = 0
+		g.Neg(&f)
+		g.Add(&f)
+		assert.Equal(t, uint64(1), g.IsZero())
+
+		// x*x
+		g.Square2(&f)
+		want := new(big.Int).Mul(x, x)
+		assertBigEqual(t, want.Mod(want, P), g.Big())
+	}
+
+	// Not a square: 3 is not a quadratic residue mod p.
+	var f, r fieldVal
+	f.SetInt(3)
+	assert.Equal(t, uint64(0), r.Sqrt(&f))
+
+	// Odd.
+	f.SetInt(7)
+	assert.Equal(t, uint64(1), f.IsOdd())
+	f.Neg(&f)
+	assert.Equal(t, uint64(0), f.IsOdd())
+}
+
+func BenchmarkFieldMul(b *testing.B) {
+	var f, g fieldVal
+	f.SetInt(0x123456789)
+	g.SetInt(0x987654321)
+	for i := 0; i < b.N; i++ {
+		f.Mul(&g)
+	}
+}
+
+func BenchmarkFieldInverse(b *testing.B) {
+	var f, g fieldVal
+	g.SetInt(0x987654321)
+	for i := 0; i < b.N; i++ {
+		f.Inverse(&g)
+	}
+}
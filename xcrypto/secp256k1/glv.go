@@ -0,0 +1,167 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package secp256k1
+
+import (
+	"math/big"
+)
+
+// The GLV endomorphism of secp256k1: lambda*(x, y) = (beta*x, y).
+// k*P = k1*P + k2*(lambda*P) with k1, k2 about 128 bits, halves the doublings of the verification.
+// https://www.iacr.org/archive/crypto2001/21390189.pdf
+var (
+	endoBeta      fieldVal
+	endoLambda, _ = new(big.Int).SetString("5363ad4cc05c30e0a5261c028812645a122e22ea20816678df02967c1b23bd72", 16)
+	endoA1, _     = new(big.Int).SetString("3086d221a7d46bcde86c90e49284eb15", 16)
+	endoB1, _     = new(big.Int).SetString("-e4437ed6010e88286f547fa90abfe4c3", 16)
+	endoA2, _     = new(big.Int).SetString("114ca50f7a8e2f3f657c1108d9d44cfd8", 16)
+	endoB2, _     = new(big.Int).SetString("3086d221a7d46bcde86c90e49284eb15", 16)
+)
+
+const (
+	// wnafWidth -- the window width of the wNAF.
+	wnafWidth = 5
+)
+
+func init() {
+	beta, _ := new(big.Int).SetString("7ae96a2b657c07106e64479eac3434e99cf0497512f58995c1396c28719501ee", 16)
+	endoBeta.SetBig(beta)
+}
+
+// splitK -- decomposes k into k1 + k2*lambda mod N, k1 and k2 may be negative.
+//
+//	c1 = round(b2*k/N), c2 = round(-b1*k/N)
+//	k1 = k - c1*a1 - c2*a2
+//	k2 = -c1*b1 - c2*b2
+func splitK(k *big.Int) (*big.Int, *big.Int) {
+	N := curveOrderBig
+	c1 := roundDiv(new(big.Int).Mul(endoB2, k), N)
+	c2 := roundDiv(new(big.Int).Mul(new(big.Int).Neg(endoB1), k), N)
+
+	k1 := new(big.Int).Set(k)
+	k1.Sub(k1, new(big.Int).Mul(c1, endoA1))
+	k1.Sub(k1, new(big.Int).Mul(c2, endoA2))
+
+	k2 := new(big.Int).Mul(c1, endoB1)
+	k2.Neg(k2)
+	k2.Sub(k2, new(big.Int).Mul(c2, endoB2))
+	return k1, k2
+}
+
+// roundDiv -- returns round(x/n) for x >= 0.
+func roundDiv(x *big.Int, n *big.Int) *big.Int {
+	r := new(big.Int).Lsh(x, 1)
+	r.Add(r, n)
+	return r.Div(r, new(big.Int).Lsh(n, 1))
+}
+
+// wnaf -- returns the width-w non-adjacent form of k >= 0, the lowest digit first.
+func wnaf(k *big.Int) []int {
+	var digits []int
+	window := big.NewInt(1 << wnafWidth)
+	t := new(big.Int).Set(k)
+	mod := new(big.Int)
+	for t.Sign() > 0 {
+		d := 0
+		if t.Bit(0) == 1 {
+			d = int(mod.Mod(t, window).Int64())
+			if d >= 1<<(wnafWidth-1) {
+				d -= 1 << wnafWidth
+			}
+			t.Sub(t, big.NewInt(int64(d)))
+		}
+		digits = append(digits, d)
+		t.Rsh(t, 1)
+	}
+	return digits
+}
+
+// oddMultiples -- returns [q, 3q, 5q, ...] for the wNAF digits.
+func oddMultiples(q *jacobianPoint) []jacobianPoint {
+	table := make([]jacobianPoint, 1<<(wnafWidth-2))
+	var q2 jacobianPoint
+	q2.doubleJacobian(q)
+	table[0] = *q
+	for i := 1; i < len(table); i++ {
+		table[i].addJacobian(&table[i-1], &q2)
+	}
+	return table
+}
+
+// combinedMultVartime -- sets p to u1*G + u2*q in variable time for the verification.
+func combinedMultVartime(p *jacobianPoint, u1 *ModNScalar, u2 *ModNScalar, q *jacobianPoint) {
+	multiMultVartime(p, u1, []ModNScalar{*u2}, []jacobianPoint{*q})
+}
+
+// multiMultVartime -- sets p to u1*G + Σ u[i]*q[i] in variable time for the (batch) verification.
+// u1*G uses the precomputed table, every u[i]*q[i] is split by the GLV endomorphism into two
+// half-length wNAFs unless u[i] is already short, and all the wNAFs share the doublings(Strauss).
+func multiMultVartime(p *jacobianPoint, u1 *ModNScalar, u []ModNScalar, q []jacobianPoint) {
+	var r1 jacobianPoint
+	scalarBaseMultCT(&r1, u1)
+
+	tables := make([][]jacobianPoint, 0, 2*len(q))
+	nafs := make([][]int, 0, 2*len(q))
+	for i := range q {
+		if q[i].isInfinity() == 1 || u[i].IsZero() {
+			continue
+		}
+
+		// The short scalar such as the random weight of the batch is not split.
+		k := u[i].Big()
+		if k.BitLen() <= 128 {
+			tables = append(tables, oddMultiples(&q[i]))
+			nafs = append(nafs, wnaf(k))
+			continue
+		}
+
+		k1, k2 := splitK(k)
+		q1 := q[i]
+		if k1.Sign() < 0 {
+			k1.Neg(k1)
+			q1.neg(&q1)
+		}
+
+		// lambda*q = (beta*x, y).
+		q2 := q[i]
+		q2.x.Mul(&endoBeta)
+		if k2.Sign() < 0 {
+			k2.Neg(k2)
+			q2.neg(&q2)
+		}
+		tables = append(tables, oddMultiples(&q1), oddMultiples(&q2))
+		nafs = append(nafs, wnaf(k1), wnaf(k2))
+	}
+
+	n := 0
+	for _, naf := range nafs {
+		if len(naf) > n {
+			n = len(naf)
+		}
+	}
+
+	var r, t jacobianPoint
+	for i := n - 1; i >= 0; i-- {
+		r.doubleJacobian(&r)
+		for j, naf := range nafs {
+			if i < len(naf) {
+				addWnafDigit(&r, tables[j], naf[i], &t)
+			}
+		}
+	}
+	p.addJacobian(&r, &r1)
+}
+
+// addWnafDigit -- r += d*q with the odd multiples of q.
+func addWnafDigit(r *jacobianPoint, table []jacobianPoint, d int, t *jacobianPoint) {
+	switch {
+	case d > 0:
+		r.addJacobian(r, &table[d/2])
+	case d < 0:
+		t.neg(&table[-d/2])
+		r.addJacobian(r, t)
+	}
+}
@@ -0,0 +1,174 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package secp256k1
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGLVEndomorphism(t *testing.T) {
+	s256 := SECP256K1()
+	params := s256.Params()
+
+	// lambda*G = (beta*Gx, Gy)
+	x, y := s256.ScalarBaseMult(endoLambda.Bytes())
+	var beta fieldVal
+	beta.SetBig(params.Gx)
+	beta.Mul(&endoBeta)
+	assertBigEqual(t, beta.Big(), x)
+	assertBigEqual(t, params.Gy, y)
+}
+
+func TestGLVSplitK(t *testing.T) {
+	N := curveOrderBig
+	bound := new(big.Int).Lsh(big.NewInt(1), 129)
+	tests := []*big.Int{
+		big.NewInt(1),
+		new(big.Int).Sub(N, big.NewInt(1)),
+		endoLambda,
+	}
+	for i := 0; i < 100; i++ {
+		k, err := rand.Int(rand.Reader, N)
+		assert.Nil(t, err)
+		tests = append(tests, k)
+	}
+	for _, k := range tests {
+		k1, k2 := splitK(k)
+		assert.True(t, new(big.Int).Abs(k1).Cmp(bound) < 0)
+		assert.True(t, new(big.Int).Abs(k2).Cmp(bound) < 0)
+
+		// k1 + k2*lambda = k mod N
+		got := new(big.Int).Mul(k2, endoLambda)
+		got.Add(got, k1)
+		got.Mod(got, N)
+		assertBigEqual(t, k, got)
+	}
+}
+
+func TestCombinedMult(t *testing.T) {
+	s256 := SECP256K1()
+	cm := s256.(interface {
+		CombinedMult(Px, Py *big.Int, baseScalar, scalar []byte) (*big.Int, *big.Int)
+	})
+	N := curveOrderBig
+
+	for i := 0; i < 50; i++ {
+		d, _ := rand.Int(rand.Reader, N)
+		u1, _ := rand.Int(rand.Reader, N)
+		u2, _ := rand.Int(rand.Reader, N)
+		Px, Py := s256.ScalarBaseMult(d.Bytes())
+
+		ax, ay := s256.ScalarBaseMult(u1.Bytes())
+		bx, by := s256.ScalarMult(Px, Py, u2.Bytes())
+		wx, wy := s256.Add(ax, ay, bx, by)
+		x, y := cm.CombinedMult(Px, Py, u1.Bytes(), u2.Bytes())
+		assertBigEqual(t, wx, x)
+		assertBigEqual(t, wy, y)
+	}
+
+	// u1*G + u2*P = infinity with P = G and u2 = N - u1.
+	{
+		params := s256.Params()
+		u1 := big.NewInt(2019)
+		u2 := new(big.Int).Sub(N, u1)
+		x, y := cm.CombinedMult(params.Gx, params.Gy, u1.Bytes(), u2.Bytes())
+		assert.Equal(t, 0, x.Sign())
+		assert.Equal(t, 0, y.Sign())
+
+		// u1*G + u1*G = 2*u1*G.
+		x, y = cm.CombinedMult(params.Gx, params.Gy, u1.Bytes(), u1.Bytes())
+		wx, wy := s256.ScalarBaseMult(big.NewInt(4038).Bytes())
+		assertBigEqual(t, wx, x)
+		assertBigEqual(t, wy, y)
+
+		// Zero scalars.
+		x, y = cm.CombinedMult(params.Gx, params.Gy, nil, u1.Bytes())
+		wx, wy = s256.ScalarBaseMult(u1.Bytes())
+		assertBigEqual(t, wx, x)
+		assertBigEqual(t, wy, y)
+		x, y = cm.CombinedMult(params.Gx, params.Gy, u1.Bytes(), nil)
+		assertBigEqual(t, wx, x)
+		assertBigEqual(t, wy, y)
+	}
+}
+
+func TestMultiMult(t *testing.T) {
+	s256 := SECP256K1()
+	mm := s256.(interface {
+		MultiMult(Xs, Ys []*big.Int, baseScalar []byte, scalars [][]byte) (*big.Int, *big.Int)
+	})
+	N := curveOrderBig
+
+	for _, n := range []int{0, 1, 2, 7, 16} {
+		u1, _ := rand.Int(rand.Reader, N)
+		wx, wy := s256.ScalarBaseMult(u1.Bytes())
+
+		var Xs, Ys []*big.Int
+		var scalars [][]byte
+		for i := 0; i < n; i++ {
+			d, _ := rand.Int(rand.Reader, N)
+			u, _ := rand.Int(rand.Reader, N)
+			Px, Py := s256.ScalarBaseMult(d.Bytes())
+			Xs, Ys = append(Xs, Px), append(Ys, Py)
+			scalars = append(scalars, u.Bytes())
+
+			bx, by := s256.ScalarMult(Px, Py, u.Bytes())
+			wx, wy = s256.Add(wx, wy, bx, by)
+		}
+		x, y := mm.MultiMult(Xs, Ys, u1.Bytes(), scalars)
+		assertBigEqual(t, wx, x)
+		assertBigEqual(t, wy, y)
+	}
+
+	// u*P + (N-u)*P + k*G = k*G, the terms cancel out.
+	{
+		params := s256.Params()
+		u := big.NewInt(2019)
+		nu := new(big.Int).Sub(N, u)
+		Px, Py := s256.ScalarBaseMult(big.NewInt(7).Bytes())
+		x, y := mm.MultiMult([]*big.Int{Px, Px, params.Gx}, []*big.Int{Py, Py, params.Gy}, nil, [][]byte{u.Bytes(), nu.Bytes(), nil})
+		assert.Equal(t, 0, x.Sign())
+		assert.Equal(t, 0, y.Sign())
+	}
+}
+
+func BenchmarkCombinedMult(b *testing.B) {
+	s256 := SECP256K1()
+	cm := s256.(interface {
+		CombinedMult(Px, Py *big.Int, baseScalar, scalar []byte) (*big.Int, *big.Int)
+	})
+	k, _ := new(big.Int).SetString(s256BaseMultTests[0].k, 16)
+	Px, Py := s256.ScalarBaseMult(k.Bytes())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cm.CombinedMult(Px, Py, k.Bytes(), k.Bytes())
+	}
+}
+
+func BenchmarkMultiMult64(b *testing.B) {
+	s256 := SECP256K1()
+	mm := s256.(interface {
+		MultiMult(Xs, Ys []*big.Int, baseScalar []byte, scalars [][]byte) (*big.Int, *big.Int)
+	})
+	var Xs, Ys []*big.Int
+	var scalars [][]byte
+	for i := 0; i < 64; i++ {
+		k, _ := rand.Int(rand.Reader, curveOrderBig)
+		Px, Py := s256.ScalarBaseMult(k.Bytes())
+		Xs, Ys = append(Xs, Px), append(Ys, Py)
+		scalars = append(scalars, k.Bytes())
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mm.MultiMult(Xs, Ys, scalars[0], scalars)
+	}
+}
@@ -0,0 +1,256 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package secp256k1
+
+import (
+	"math/big"
+)
+
+// affinePoint -- the point (x, y), the infinity is not representable.
+type affinePoint struct {
+	x, y fieldVal
+}
+
+// jacobianPoint -- the point (x/z², y/z³), z = 0 for the infinity.
+type jacobianPoint struct {
+	x, y, z fieldVal
+}
+
+// setAffine -- sets p to the affine point q.
+func (p *jacobianPoint) setAffine(q *affinePoint) {
+	p.x = q.x
+	p.y = q.y
+	p.z.SetInt(1)
+}
+
+// setBig -- sets p to the point (x, y), (0, 0) is the infinity.
+func (p *jacobianPoint) setBig(x *big.Int, y *big.Int) {
+	p.x.SetBig(x)
+	p.y.SetBig(y)
+	if x.Sign() == 0 && y.Sign() == 0 {
+		p.z.SetInt(0)
+	} else {
+		p.z.SetInt(1)
+	}
+}
+
+// isInfinity -- returns 1 if p is the infinity, otherwise 0.
+func (p *jacobianPoint) isInfinity() uint64 {
+	return p.z.IsZero()
+}
+
+// cmov -- sets p to q if flag is 1, keeps p if flag is 0.
+func (p *jacobianPoint) cmov(q *jacobianPoint, flag uint64) {
+	p.x.cmov(&q.x, flag)
+	p.y.cmov(&q.y, flag)
+	p.z.cmov(&q.z, flag)
+}
+
+// cmov -- sets p to q if flag is 1, keeps p if flag is 0.
+func (p *affinePoint) cmov(q *affinePoint, flag uint64) {
+	p.x.cmov(&q.x, flag)
+	p.y.cmov(&q.y, flag)
+}
+
+// neg -- sets p to -q.
+func (p *jacobianPoint) neg(q *jacobianPoint) {
+	p.x = q.x
+	p.y.Neg(&q.y)
+	p.z = q.z
+}
+
+// toAffine -- returns the affine coordinates as big.Int, (0, 0) for the infinity.
+func (p *jacobianPoint) toAffine() (*big.Int, *big.Int) {
+	if p.isInfinity() == 1 {
+		return new(big.Int), new(big.Int)
+	}
+	var zinv, zinv2, x, y fieldVal
+	zinv.Inverse(&p.z)
+	zinv2.Square2(&zinv)
+	x.Mul2(&p.x, &zinv2)
+	zinv2.Mul(&zinv)
+	y.Mul2(&p.y, &zinv2)
+	return x.Big(), y.Big()
+}
+
+// doubleJacobian -- sets p to 2q, the infinity stays the infinity.
+// See http://hyperelliptic.org/EFD/g1p/auto-shortw-jacobian-0.html#doubling-dbl-2009-l
+func (p *jacobianPoint) doubleJacobian(q *jacobianPoint) {
+	var a, b, c, d, e, f, t fieldVal
+
+	a.Square2(&q.x)
+	b.Square2(&q.y)
+	c.Square2(&b)
+
+	// d = 2*((x+b)²-a-c)
+	d.Add2(&q.x, &b)
+	d.Square()
+	t.Add2(&a, &c)
+	d.Sub2(&d, &t)
+	d.MulInt(2)
+
+	// e = 3*a, f = e²
+	e = a
+	e.MulInt(3)
+	f.Square2(&e)
+
+	// z3 = 2*y*z
+	p.z.Mul2(&q.y, &q.z)
+	p.z.MulInt(2)
+
+	// x3 = f-2*d
+	t = d
+	t.MulInt(2)
+	p.x.Sub2(&f, &t)
+
+	// y3 = e*(d-x3)-8*c
+	d.Sub2(&d, &p.x)
+	p.y.Mul2(&e, &d)
+	c.MulInt(8)
+	p.y.Sub2(&p.y, &c)
+}
+
+// addJacobianCT -- sets p to q1 + q2 without branches.
+// The caller must make sure q1, q2 are not the infinity and q1 != ±q2.
+// See http://hyperelliptic.org/EFD/g1p/auto-shortw-jacobian-0.html#addition-add-2007-bl
+func (p *jacobianPoint) addJacobianCT(q1 *jacobianPoint, q2 *jacobianPoint) {
+	var z1z1, z2z2, u1, u2, s1, s2 fieldVal
+
+	z1z1.Square2(&q1.z)
+	z2z2.Square2(&q2.z)
+	u1.Mul2(&q1.x, &z2z2)
+	u2.Mul2(&q2.x, &z1z1)
+	s1.Mul2(&q1.y, &q2.z)
+	s1.Mul(&z2z2)
+	s2.Mul2(&q2.y, &q1.z)
+	s2.Mul(&z1z1)
+	p.addJacobianUS(q1, q2, &z1z1, &z2z2, &u1, &u2, &s1, &s2)
+}
+
+// addJacobianUS -- the addition with the precomputed z1², z2², u1, u2, s1, s2 of addJacobianCT.
+func (p *jacobianPoint) addJacobianUS(q1 *jacobianPoint, q2 *jacobianPoint, z1z1, z2z2, u1, u2, s1, s2 *fieldVal) {
+	var h, i, j, r, v, t fieldVal
+
+	// h = u2-u1, i = (2*h)², j = h*i
+	h.Sub2(u2, u1)
+	i = h
+	i.MulInt(2)
+	i.Square()
+	j.Mul2(&h, &i)
+
+	// r = 2*(s2-s1), v = u1*i
+	r.Sub2(s2, s1)
+	r.MulInt(2)
+	v.Mul2(u1, &i)
+
+	// z3 = ((z1+z2)²-z1z1-z2z2)*h
+	var z3 fieldVal
+	z3.Add2(&q1.z, &q2.z)
+	z3.Square()
+	t.Add2(z1z1, z2z2)
+	z3.Sub2(&z3, &t)
+	z3.Mul(&h)
+
+	// x3 = r²-j-2*v
+	var x3 fieldVal
+	x3.Square2(&r)
+	x3.Sub2(&x3, &j)
+	t = v
+	t.MulInt(2)
+	x3.Sub2(&x3, &t)
+
+	// y3 = r*(v-x3)-2*s1*j
+	var y3, sj fieldVal
+	v.Sub2(&v, &x3)
+	y3.Mul2(&r, &v)
+	sj.Mul2(s1, &j)
+	sj.MulInt(2)
+	y3.Sub2(&y3, &sj)
+
+	p.x, p.y, p.z = x3, y3, z3
+}
+
+// addMixedCT -- sets p to q1 + q2 without branches, q2 is affine.
+// The caller must make sure q1 is not the infinity and q1 != ±q2.
+// See http://hyperelliptic.org/EFD/g1p/auto-shortw-jacobian-0.html#addition-madd-2007-bl
+func (p *jacobianPoint) addMixedCT(q1 *jacobianPoint, q2 *affinePoint) {
+	var z1z1, u2, s2, h, hh, i, j, r, v, t fieldVal
+
+	z1z1.Square2(&q1.z)
+	u2.Mul2(&q2.x, &z1z1)
+	s2.Mul2(&q2.y, &q1.z)
+	s2.Mul(&z1z1)
+
+	// h = u2-x1, hh = h², i = 4*hh, j = h*i
+	h.Sub2(&u2, &q1.x)
+	hh.Square2(&h)
+	i = hh
+	i.MulInt(4)
+	j.Mul2(&h, &i)
+
+	// r = 2*(s2-y1), v = x1*i
+	r.Sub2(&s2, &q1.y)
+	r.MulInt(2)
+	v.Mul2(&q1.x, &i)
+
+	// z3 = (z1+h)²-z1z1-hh
+	var z3 fieldVal
+	z3.Add2(&q1.z, &h)
+	z3.Square()
+	t.Add2(&z1z1, &hh)
+	z3.Sub2(&z3, &t)
+
+	// x3 = r²-j-2*v
+	var x3 fieldVal
+	x3.Square2(&r)
+	x3.Sub2(&x3, &j)
+	t = v
+	t.MulInt(2)
+	x3.Sub2(&x3, &t)
+
+	// y3 = r*(v-x3)-2*y1*j
+	var y3 fieldVal
+	v.Sub2(&v, &x3)
+	y3.Mul2(&r, &v)
+	t.Mul2(&q1.y, &j)
+	t.MulInt(2)
+	y3.Sub2(&y3, &t)
+
+	p.x, p.y, p.z = x3, y3, z3
+}
+
+// addJacobian -- sets p to q1 + q2 for any points, variable time for the public points.
+func (p *jacobianPoint) addJacobian(q1 *jacobianPoint, q2 *jacobianPoint) {
+	if q1.isInfinity() == 1 {
+		*p = *q2
+		return
+	}
+	if q2.isInfinity() == 1 {
+		*p = *q1
+		return
+	}
+
+	var z1z1, z2z2, u1, u2, s1, s2 fieldVal
+	z1z1.Square2(&q1.z)
+	z2z2.Square2(&q2.z)
+	u1.Mul2(&q1.x, &z2z2)
+	u2.Mul2(&q2.x, &z1z1)
+	s1.Mul2(&q1.y, &q2.z)
+	s1.Mul(&z2z2)
+	s2.Mul2(&q2.y, &q1.z)
+	s2.Mul(&z1z1)
+
+	// Same x: double or the infinity.
+	if u1.Equals(&u2) == 1 {
+		if s1.Equals(&s2) == 1 {
+			p.doubleJacobian(q1)
+		} else {
+			*p = jacobianPoint{}
+		}
+		return
+	}
+	p.addJacobianUS(q1, q2, &z1z1, &z2z2, &u1, &u2, &s1, &s2)
+}
@@ -0,0 +1,269 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package secp256k1
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+)
+
+var (
+	// curveOrderBig -- N as big.Int.
+	curveOrderBig, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+
+	// orderN -- the group order N in 4x64 limbs, little endian.
+	orderN = [4]uint64{0xbfd25e8cd0364141, 0xbaaedce6af48a03b, 0xfffffffffffffffe, 0xffffffffffffffff}
+
+	// orderC -- 2^256 - N.
+	orderC = [3]uint64{0x402da1732fc9bebf, 0x4551231950b75fc4, 0x1}
+
+	// orderHalf -- N/2.
+	orderHalf = [4]uint64{0xdfe92f46681b20a0, 0x5d576e7357a4501d, 0xffffffffffffffff, 0x7fffffffffffffff}
+
+	// orderInvExp -- N-2, the exponent of the inverse.
+	orderInvExp = [32]byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe,
+		0xba, 0xae, 0xdc, 0xe6, 0xaf, 0x48, 0xa0, 0x3b, 0xbf, 0xd2, 0x5e, 0x8c, 0xd0, 0x36, 0x41, 0x3f,
+	}
+)
+
+// ModNScalar -- the integer modulo the group order N in 4x64 limbs, little endian.
+// The value is always less than N and all the operations are constant time.
+type ModNScalar struct {
+	n [4]uint64
+}
+
+// NewModNScalar -- creates new ModNScalar from the 32-byte big-endian value mod N.
+func NewModNScalar(b []byte) *ModNScalar {
+	s := &ModNScalar{}
+	s.SetByteSlice(b)
+	return s
+}
+
+// SetInt -- sets s to the small integer.
+func (s *ModNScalar) SetInt(v uint64) *ModNScalar {
+	s.n = [4]uint64{v, 0, 0, 0}
+	return s
+}
+
+// SetBytes -- sets s to the 32-byte big-endian value mod N.
+// Returns 1 if the value is not less than N, otherwise 0.
+func (s *ModNScalar) SetBytes(b *[32]byte) uint64 {
+	s.n[3] = binary.BigEndian.Uint64(b[0:8])
+	s.n[2] = binary.BigEndian.Uint64(b[8:16])
+	s.n[1] = binary.BigEndian.Uint64(b[16:24])
+	s.n[0] = binary.BigEndian.Uint64(b[24:32])
+	return s.reduceOnce(0)
+}
+
+// SetByteSlice -- sets s to the big-endian value mod N, the bytes before the last 32 are reduced with big.Int.
+// Returns true if the value is not less than N.
+func (s *ModNScalar) SetByteSlice(b []byte) bool {
+	var b32 [32]byte
+	if len(b) > 32 {
+		x := new(big.Int).SetBytes(b)
+		x.Mod(x, curveOrderBig)
+		x.FillBytes(b32[:])
+		s.SetBytes(&b32)
+		return true
+	}
+	copy(b32[32-len(b):], b)
+	overflow := s.SetBytes(&b32) == 1
+	zeroArray32(&b32)
+	return overflow
+}
+
+// SetBig -- sets s to x mod N.
+func (s *ModNScalar) SetBig(x *big.Int) *ModNScalar {
+	var b [32]byte
+	if x.Sign() < 0 || x.BitLen() > 256 {
+		x = new(big.Int).Mod(x, curveOrderBig)
+	}
+	x.FillBytes(b[:])
+	s.SetBytes(&b)
+	return s
+}
+
+// Bytes -- returns the 32-byte big-endian value.
+func (s *ModNScalar) Bytes() [32]byte {
+	var b [32]byte
+	binary.BigEndian.PutUint64(b[0:8], s.n[3])
+	binary.BigEndian.PutUint64(b[8:16], s.n[2])
+	binary.BigEndian.PutUint64(b[16:24], s.n[1])
+	binary.BigEndian.PutUint64(b[24:32], s.n[0])
+	return b
+}
+
+// Big -- returns s as big.Int.
+func (s *ModNScalar) Big() *big.Int {
+	b := s.Bytes()
+	return new(big.Int).SetBytes(b[:])
+}
+
+// Zero -- sets s to 0, used to clean the secret.
+func (s *ModNScalar) Zero() {
+	s.n = [4]uint64{}
+}
+
+// IsZero -- returns true if s is 0.
+func (s *ModNScalar) IsZero() bool {
+	return ctIsZero(s.n[0]|s.n[1]|s.n[2]|s.n[3]) == 1
+}
+
+// Equals -- returns true if s equals s2.
+func (s *ModNScalar) Equals(s2 *ModNScalar) bool {
+	return ctIsZero((s.n[0]^s2.n[0])|(s.n[1]^s2.n[1])|(s.n[2]^s2.n[2])|(s.n[3]^s2.n[3])) == 1
+}
+
+// IsOverHalfOrder -- returns true if s is greater than N/2.
+func (s *ModNScalar) IsOverHalfOrder() bool {
+	// N/2 - s borrows if s > N/2.
+	var borrow uint64
+	_, borrow = bits.Sub64(orderHalf[0], s.n[0], 0)
+	_, borrow = bits.Sub64(orderHalf[1], s.n[1], borrow)
+	_, borrow = bits.Sub64(orderHalf[2], s.n[2], borrow)
+	_, borrow = bits.Sub64(orderHalf[3], s.n[3], borrow)
+	return borrow == 1
+}
+
+// reduceOnce -- subtracts N from the 257-bit value (hi, s) if it is not less than N.
+// Returns 1 if N is subtracted, otherwise 0.
+func (s *ModNScalar) reduceOnce(hi uint64) uint64 {
+	var t [4]uint64
+	var c uint64
+
+	// t = s + 2^256 - N, s >= N if it overflows 2^256.
+	t[0], c = bits.Add64(s.n[0], orderC[0], 0)
+	t[1], c = bits.Add64(s.n[1], orderC[1], c)
+	t[2], c = bits.Add64(s.n[2], orderC[2], c)
+	t[3], c = bits.Add64(s.n[3], 0, c)
+
+	over := c | hi
+	mask := -over
+	for i := range s.n {
+		s.n[i] = (t[i] & mask) | (s.n[i] &^ mask)
+	}
+	return over
+}
+
+// Add -- s = s + s2.
+func (s *ModNScalar) Add(s2 *ModNScalar) *ModNScalar {
+	var c uint64
+	s.n[0], c = bits.Add64(s.n[0], s2.n[0], 0)
+	s.n[1], c = bits.Add64(s.n[1], s2.n[1], c)
+	s.n[2], c = bits.Add64(s.n[2], s2.n[2], c)
+	s.n[3], c = bits.Add64(s.n[3], s2.n[3], c)
+	s.reduceOnce(c)
+	return s
+}
+
+// Add2 -- s = a + b.
+func (s *ModNScalar) Add2(a *ModNScalar, b *ModNScalar) *ModNScalar {
+	*s = *a
+	return s.Add(b)
+}
+
+// Negate -- s = -s.
+func (s *ModNScalar) Negate() *ModNScalar {
+	// N - s, masked to 0 if s is 0.
+	mask := -(1 ^ ctIsZero(s.n[0]|s.n[1]|s.n[2]|s.n[3]))
+	var b uint64
+	s.n[0], b = bits.Sub64(orderN[0], s.n[0], 0)
+	s.n[1], b = bits.Sub64(orderN[1], s.n[1], b)
+	s.n[2], b = bits.Sub64(orderN[2], s.n[2], b)
+	s.n[3], _ = bits.Sub64(orderN[3], s.n[3], b)
+	for i := range s.n {
+		s.n[i] &= mask
+	}
+	return s
+}
+
+// Mul -- s = s * s2.
+func (s *ModNScalar) Mul(s2 *ModNScalar) *ModNScalar {
+	return s.Mul2(s, s2)
+}
+
+// Mul2 -- s = a * b.
+func (s *ModNScalar) Mul2(a *ModNScalar, b *ModNScalar) *ModNScalar {
+	var w [8]uint64
+	addMulWords(w[:], a.n[:], b.n[:])
+	s.reduce512(&w)
+	return s
+}
+
+// Square -- s = s * s.
+func (s *ModNScalar) Square() *ModNScalar {
+	return s.Mul2(s, s)
+}
+
+// reduce512 -- sets s to the 512-bit w mod N, folds the high words with 2^256 = orderC mod N.
+func (s *ModNScalar) reduce512(w *[8]uint64) {
+	// 385 bits.
+	var m [7]uint64
+	copy(m[:4], w[:4])
+	addMulWords(m[:], w[4:8], orderC[:])
+
+	// 260 bits.
+	var r [5]uint64
+	copy(r[:4], m[:4])
+	addMulWords(r[:], m[4:7], orderC[:])
+
+	// 257 bits.
+	var t [5]uint64
+	copy(t[:4], r[:4])
+	addMulWords(t[:], r[4:5], orderC[:])
+
+	// 256 bits, t[0:4] is small if t[4] is 1.
+	var u [4]uint64
+	copy(u[:], t[:4])
+	addMulWords(u[:], t[4:5], orderC[:])
+
+	s.n = u
+	s.reduceOnce(0)
+}
+
+// Inverse -- s = s^-1 by Fermat's little theorem, the inverse of 0 is 0.
+func (s *ModNScalar) Inverse() *ModNScalar {
+	var r ModNScalar
+	b := *s
+	r.SetInt(1)
+	for _, v := range orderInvExp {
+		for bit := 7; bit >= 0; bit-- {
+			r.Square()
+			if (v>>uint(bit))&1 == 1 {
+				r.Mul(&b)
+			}
+		}
+	}
+	*s = r
+	return s
+}
+
+// addMulWords -- acc += a * b, the carry out of acc is dropped.
+func addMulWords(acc []uint64, a []uint64, b []uint64) {
+	for i := range a {
+		var carry uint64
+		for j := range b {
+			hi, lo := bits.Mul64(a[i], b[j])
+			var c uint64
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			acc[i+j], c = bits.Add64(acc[i+j], lo, 0)
+			carry = hi + c
+		}
+		for k := i + len(b); k < len(acc); k++ {
+			acc[k], carry = bits.Add64(acc[k], carry, 0)
+		}
+	}
+}
+
+// zeroArray32 -- cleans the 32-byte array.
+func zeroArray32(b *[32]byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,126 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package secp256k1
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randScalarTest(t *testing.T) (*big.Int, *ModNScalar) {
+	x, err := rand.Int(rand.Reader, curveOrderBig)
+	assert.Nil(t, err)
+	return x, new(ModNScalar).SetBig(x)
+}
+
+func TestModNScalar(t *testing.T) {
+	N := curveOrderBig
+	for i := 0; i < 200; i++ {
+		a, sa := randScalarTest(t)
+		b, sb := randScalarTest(t)
+
+		// Add.
+		{
+			s := new(ModNScalar).Add2(sa, sb)
+			want := new(big.Int).Add(a, b)
+			assertBigEqual(t, want.Mod(want, N), s.Big())
+		}
+
+		// Mul.
+		{
+			s := new(ModNScalar).Mul2(sa, sb)
+			want := new(big.Int).Mul(a, b)
+			assertBigEqual(t, want.Mod(want, N), s.Big())
+		}
+
+		// Negate.
+		{
+			s := *sa
+			s.Negate()
+			want := new(big.Int).Neg(a)
+			assertBigEqual(t, want.Mod(want, N), s.Big())
+		}
+
+		// Inverse.
+		{
+			s := *sa
+			s.Inverse()
+			want := new(big.Int).ModInverse(a, N)
+			assertBigEqual(t, want, s.Big())
+		}
+
+		// Half order.
+		{
+			half := new(big.Int).Rsh(N, 1)
+			assert.Equal(t, a.Cmp(half) > 0, sa.IsOverHalfOrder())
+		}
+	}
+}
+
+func TestModNScalarEdges(t *testing.T) {
+	N := curveOrderBig
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	tests := []struct {
+		x        *big.Int
+		overflow bool
+	}{
+		{big.NewInt(0), false},
+		{new(big.Int).Sub(N, big.NewInt(1)), false},
+		{N, true},
+		{new(big.Int).Add(N, big.NewInt(1)), true},
+		{max, true},
+	}
+	for _, test := range tests {
+		var b [32]byte
+		var s ModNScalar
+		test.x.FillBytes(b[:])
+		assert.Equal(t, test.overflow, s.SetByteSlice(b[:]))
+		assertBigEqual(t, new(big.Int).Mod(test.x, N), s.Big())
+
+		// (N-1)^2 and max^2 reduce to the worst case.
+		sq := new(ModNScalar).Mul2(&s, &s)
+		want := new(big.Int).Mul(test.x, test.x)
+		assertBigEqual(t, want.Mod(want, N), sq.Big())
+	}
+
+	// Short and long slices.
+	{
+		s := NewModNScalar([]byte{0x01, 0x02})
+		assertBigEqual(t, big.NewInt(0x0102), s.Big())
+
+		long := append([]byte{0x01}, make([]byte, 32)...)
+		s = NewModNScalar(long)
+		want := new(big.Int).SetBytes(long)
+		assertBigEqual(t, want.Mod(want, N), s.Big())
+	}
+
+	// Zero.
+	{
+		var s ModNScalar
+		assert.True(t, s.IsZero())
+		s.Negate()
+		assert.True(t, s.IsZero())
+		s.Inverse()
+		assert.True(t, s.IsZero())
+		s.SetInt(1)
+		assert.False(t, s.IsZero())
+		assert.True(t, s.Equals(new(ModNScalar).SetInt(1)))
+		s.Zero()
+		assert.True(t, s.IsZero())
+	}
+}
+
+func BenchmarkModNScalarMul(b *testing.B) {
+	var s, s2 ModNScalar
+	s.SetInt(0x123456789)
+	s2.SetInt(0x987654321)
+	for i := 0; i < b.N; i++ {
+		s.Mul(&s2)
+	}
+}
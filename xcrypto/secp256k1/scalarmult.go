@@ -0,0 +1,182 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package secp256k1
+
+import (
+	"sync"
+)
+
+const (
+	// windowBits -- the bits of the fixed window.
+	windowBits = 4
+
+	// windowSize -- the points of each window.
+	windowSize = 1 << windowBits
+
+	// windowCount -- the windows of the 256-bit scalar.
+	windowCount = 256 / windowBits
+)
+
+var (
+	baseTableOnce sync.Once
+
+	// baseTable -- baseTable[w][j] = j * 16^w * G, j = 0 is unused.
+	baseTable *[windowCount][windowSize]affinePoint
+)
+
+// initBaseTable -- precomputes the multiples of G for every window.
+func initBaseTable() {
+	var table [windowCount][windowSize]affinePoint
+	var base jacobianPoint
+	params := SECP256K1().Params()
+	base.setBig(params.Gx, params.Gy)
+
+	points := make([]jacobianPoint, 0, windowCount*(windowSize-1))
+	for w := 0; w < windowCount; w++ {
+		p := base
+		points = append(points, p)
+		for j := 2; j < windowSize; j++ {
+			p.addJacobian(&p, &base)
+			points = append(points, p)
+		}
+		// base = 16 * base
+		for i := 0; i < windowBits; i++ {
+			base.doubleJacobian(&base)
+		}
+	}
+
+	affines := batchToAffine(points)
+	for w := 0; w < windowCount; w++ {
+		for j := 1; j < windowSize; j++ {
+			table[w][j] = affines[w*(windowSize-1)+j-1]
+		}
+	}
+	baseTable = &table
+}
+
+// batchToAffine -- converts the points to affine with one inversion, the points must not be the infinity.
+func batchToAffine(points []jacobianPoint) []affinePoint {
+	n := len(points)
+	affines := make([]affinePoint, n)
+	if n == 0 {
+		return affines
+	}
+
+	// prods[i] = z0 * z1 * ... * zi
+	prods := make([]fieldVal, n)
+	prods[0] = points[0].z
+	for i := 1; i < n; i++ {
+		prods[i].Mul2(&prods[i-1], &points[i].z)
+	}
+
+	var inv, zinv, zinv2 fieldVal
+	inv.Inverse(&prods[n-1])
+	for i := n - 1; i >= 0; i-- {
+		if i > 0 {
+			zinv.Mul2(&inv, &prods[i-1])
+			inv.Mul(&points[i].z)
+		} else {
+			zinv = inv
+		}
+		zinv2.Square2(&zinv)
+		affines[i].x.Mul2(&points[i].x, &zinv2)
+		zinv2.Mul(&zinv)
+		affines[i].y.Mul2(&points[i].y, &zinv2)
+		affines[i].x.normalize()
+		affines[i].y.normalize()
+	}
+	return affines
+}
+
+// nibble -- returns the w-th 4-bit window of the 32-byte big-endian k, the window 0 is the lowest.
+func nibble(k *[32]byte, w int) uint64 {
+	b := k[31-w/2]
+	if w%2 == 1 {
+		b >>= 4
+	}
+	return uint64(b & 0x0f)
+}
+
+// scalarBaseMultCT -- sets p to k*G in constant time with the precomputed table.
+// The partial sum of the windows below w is less than 16^w, and k < N, so the mixed
+// addition never meets the doubling or the infinity except the selected zero windows.
+func scalarBaseMultCT(p *jacobianPoint, k *ModNScalar) {
+	baseTableOnce.Do(initBaseTable)
+
+	kb := k.Bytes()
+	var r, sum, qj jacobianPoint
+	var q affinePoint
+	rIsInf := uint64(1)
+	for w := 0; w < windowCount; w++ {
+		idx := nibble(&kb, w)
+		for j := 1; j < windowSize; j++ {
+			q.cmov(&baseTable[w][j], ctEq(uint64(j), idx))
+		}
+		sum.addMixedCT(&r, &q)
+
+		// r is the infinity: sum = q.
+		qj.setAffine(&q)
+		sum.cmov(&qj, rIsInf)
+
+		// Zero window: keeps r.
+		nz := 1 ^ ctIsZero(idx)
+		r.cmov(&sum, nz)
+		rIsInf &= 1 ^ nz
+	}
+	zeroArray32(&kb)
+
+	// k = 0.
+	var inf jacobianPoint
+	r.cmov(&inf, rIsInf)
+	*p = r
+}
+
+// scalarMultCT -- sets p to k*q in constant time with the 4-bit fixed window.
+// The accumulator is 16 times the prefix of k and the selected point is less than 16
+// times q, and k < N, so the addition never meets the doubling.
+func scalarMultCT(p *jacobianPoint, k *ModNScalar, q *jacobianPoint) {
+	if q.isInfinity() == 1 {
+		*p = jacobianPoint{}
+		return
+	}
+
+	// table[j] = j*q
+	var table [windowSize]jacobianPoint
+	table[1] = *q
+	table[2].doubleJacobian(q)
+	for j := 3; j < windowSize; j++ {
+		table[j].addJacobian(&table[j-1], q)
+	}
+
+	kb := k.Bytes()
+	var r, sum, sel jacobianPoint
+	rIsInf := uint64(1)
+	for w := windowCount - 1; w >= 0; w-- {
+		for i := 0; i < windowBits; i++ {
+			r.doubleJacobian(&r)
+		}
+
+		idx := nibble(&kb, w)
+		sel = jacobianPoint{}
+		for j := 1; j < windowSize; j++ {
+			sel.cmov(&table[j], ctEq(uint64(j), idx))
+		}
+		sum.addJacobianCT(&r, &sel)
+
+		// r is the infinity: sum = sel.
+		sum.cmov(&sel, rIsInf)
+
+		// Zero window: keeps r.
+		nz := 1 ^ ctIsZero(idx)
+		r.cmov(&sum, nz)
+		rIsInf &= 1 ^ nz
+	}
+	zeroArray32(&kb)
+
+	var inf jacobianPoint
+	r.cmov(&inf, rIsInf)
+	*p = r
+}
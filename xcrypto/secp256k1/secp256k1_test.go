@@ -6,6 +6,7 @@
 package secp256k1
 
 import (
+	"crypto/rand"
 	"fmt"
 	"math/big"
 	"testing"
@@ -78,3 +79,157 @@ func BenchmarkBaseMult(b *testing.B) {
 		s256.ScalarBaseMult(k.Bytes())
 	}
 }
+
+// refScalarMult -- the affine double-and-add reference with big.Int.
+func refScalarMult(x, y *big.Int, k *big.Int) (*big.Int, *big.Int) {
+	P := fieldPrimeBig
+	var rx, ry *big.Int
+	add := func(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+		if x1 == nil {
+			return x2, y2
+		}
+		var l *big.Int
+		if x1.Cmp(x2) == 0 {
+			if y1.Cmp(y2) != 0 {
+				return nil, nil
+			}
+			// l = 3x²/2y
+			l = new(big.Int).Mul(x1, x1)
+			l.Mul(l, big.NewInt(3))
+			l.Mul(l, new(big.Int).ModInverse(new(big.Int).Lsh(y1, 1), P))
+		} else {
+			l = new(big.Int).Sub(y2, y1)
+			l.Mul(l, new(big.Int).ModInverse(new(big.Int).Sub(x2, x1).Mod(new(big.Int).Sub(x2, x1), P), P))
+		}
+		l.Mod(l, P)
+		x3 := new(big.Int).Mul(l, l)
+		x3.Sub(x3, x1).Sub(x3, x2).Mod(x3, P)
+		y3 := new(big.Int).Sub(x1, x3)
+		y3.Mul(y3, l).Sub(y3, y1).Mod(y3, P)
+		return x3, y3
+	}
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		if rx != nil {
+			rx, ry = add(rx, ry, rx, ry)
+		}
+		if k.Bit(i) == 1 {
+			rx, ry = add(rx, ry, x, y)
+		}
+	}
+	if rx == nil {
+		return new(big.Int), new(big.Int)
+	}
+	return rx, ry
+}
+
+func TestScalarMult(t *testing.T) {
+	s256 := SECP256K1()
+	params := s256.Params()
+	N := params.N
+
+	ks := []*big.Int{
+		big.NewInt(1),
+		big.NewInt(2),
+		big.NewInt(15),
+		big.NewInt(16),
+		big.NewInt(17),
+		new(big.Int).Sub(N, big.NewInt(1)),
+		new(big.Int).Sub(N, big.NewInt(16)),
+		new(big.Int).Lsh(big.NewInt(1), 255),
+	}
+	for i := 0; i < 20; i++ {
+		k, _ := rand.Int(rand.Reader, N)
+		ks = append(ks, k)
+	}
+
+	Px, Py := refScalarMult(params.Gx, params.Gy, big.NewInt(0x2019))
+	for _, k := range ks {
+		// k*G
+		wx, wy := refScalarMult(params.Gx, params.Gy, k)
+		x, y := s256.ScalarBaseMult(k.Bytes())
+		if x.Cmp(wx) != 0 || y.Cmp(wy) != 0 {
+			t.Fatalf("base.mult.mismatch.k[%x]", k)
+		}
+
+		// k*P
+		wx, wy = refScalarMult(Px, Py, k)
+		x, y = s256.ScalarMult(Px, Py, k.Bytes())
+		if x.Cmp(wx) != 0 || y.Cmp(wy) != 0 {
+			t.Fatalf("mult.mismatch.k[%x]", k)
+		}
+		if !s256.IsOnCurve(x, y) {
+			t.Fatalf("mult.not.on.curve.k[%x]", k)
+		}
+	}
+
+	// k = 0, k = N and the infinity are the infinity, k > N is k mod N.
+	{
+		for _, k := range [][]byte{nil, {0x00}, N.Bytes()} {
+			x, y := s256.ScalarBaseMult(k)
+			if x.Sign() != 0 || y.Sign() != 0 {
+				t.Fatalf("base.mult.not.infinity.k[%x]", k)
+			}
+			x, y = s256.ScalarMult(Px, Py, k)
+			if x.Sign() != 0 || y.Sign() != 0 {
+				t.Fatalf("mult.not.infinity.k[%x]", k)
+			}
+		}
+		x, y := s256.ScalarMult(new(big.Int), new(big.Int), []byte{0x01})
+		if x.Sign() != 0 || y.Sign() != 0 {
+			t.Fatal("infinity.mult.not.infinity")
+		}
+
+		k := new(big.Int).Add(N, big.NewInt(5))
+		x, y = s256.ScalarMult(Px, Py, k.Bytes())
+		wx, wy := s256.ScalarMult(Px, Py, []byte{0x05})
+		if x.Cmp(wx) != 0 || y.Cmp(wy) != 0 {
+			t.Fatal("mult.k.over.n.mismatch")
+		}
+	}
+}
+
+func TestAddDouble(t *testing.T) {
+	s256 := SECP256K1()
+	params := s256.Params()
+	zero := new(big.Int)
+
+	// G + G = 2G.
+	x, y := s256.Add(params.Gx, params.Gy, params.Gx, params.Gy)
+	wx, wy := s256.Double(params.Gx, params.Gy)
+	if x.Cmp(wx) != 0 || y.Cmp(wy) != 0 {
+		t.Fatal("add.double.mismatch")
+	}
+	wx, wy = s256.ScalarBaseMult([]byte{0x02})
+	if x.Cmp(wx) != 0 || y.Cmp(wy) != 0 {
+		t.Fatal("double.mult.mismatch")
+	}
+
+	// G + (-G) = infinity.
+	negY := new(big.Int).Sub(params.P, params.Gy)
+	x, y = s256.Add(params.Gx, params.Gy, params.Gx, negY)
+	if x.Sign() != 0 || y.Sign() != 0 {
+		t.Fatal("add.inverse.not.infinity")
+	}
+
+	// infinity + G = G.
+	x, y = s256.Add(zero, zero, params.Gx, params.Gy)
+	if x.Cmp(params.Gx) != 0 || y.Cmp(params.Gy) != 0 {
+		t.Fatal("add.infinity.mismatch")
+	}
+	x, y = s256.Add(params.Gx, params.Gy, zero, zero)
+	if x.Cmp(params.Gx) != 0 || y.Cmp(params.Gy) != 0 {
+		t.Fatal("add.infinity.mismatch")
+	}
+}
+
+func BenchmarkScalarMult(b *testing.B) {
+	s256 := SECP256K1()
+	e := s256BaseMultTests[0]
+	k, _ := new(big.Int).SetString(e.k, 16)
+	Px, Py := s256.ScalarBaseMult(k.Bytes())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s256.ScalarMult(Px, Py, k.Bytes())
+	}
+}
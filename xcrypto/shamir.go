@@ -0,0 +1,131 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+const (
+	// ShamirMaxShares -- the max share count, the indexes are bytes.
+	ShamirMaxShares = 255
+)
+
+var (
+	gf256Exp [255]byte
+	gf256Log [256]byte
+)
+
+// The GF(256) is the Rijndael field with the polynomial x^8 + x^4 + x^3 + x + 1,
+// the tables are the powers and logarithms of the generator x + 1.
+func init() {
+	p := 1
+	for i := 0; i < 255; i++ {
+		gf256Exp[i] = byte(p)
+		gf256Log[p] = byte(i)
+		p ^= p << 1
+		if p&0x100 != 0 {
+			p ^= 0x11b
+		}
+	}
+}
+
+// ShamirShare -- the share of the secret, the Value is the y of every secret byte at the Index x.
+type ShamirShare struct {
+	Index byte
+	Value []byte
+}
+
+// ShamirSplit -- splits the secret into count shares with the indexes 1..count,
+// any threshold of them recover the secret which is the polynomial at x = 0.
+func ShamirSplit(threshold int, count int, secret []byte) ([]*ShamirShare, error) {
+	if threshold < 1 || threshold > count || count > ShamirMaxShares {
+		return nil, fmt.Errorf("shamir.threshold[%v].count[%v].invalid", threshold, count)
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("shamir.secret.empty")
+	}
+
+	// The threshold-1 random shares and the secret define the polynomial.
+	base := []*ShamirShare{{Index: 0, Value: secret}}
+	shares := make([]*ShamirShare, 0, count)
+	for i := 1; i < threshold; i++ {
+		value := make([]byte, len(secret))
+		if _, err := rand.Read(value); err != nil {
+			return nil, err
+		}
+		share := &ShamirShare{Index: byte(i), Value: value}
+		base = append(base, share)
+		shares = append(shares, share)
+	}
+	for i := threshold; i <= count; i++ {
+		value, err := ShamirInterpolate(base, byte(i))
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, &ShamirShare{Index: byte(i), Value: value})
+	}
+	return shares, nil
+}
+
+// ShamirCombine -- recovers the secret from the threshold shares of ShamirSplit.
+func ShamirCombine(shares []*ShamirShare) ([]byte, error) {
+	for _, share := range shares {
+		if share.Index == 0 {
+			return nil, fmt.Errorf("shamir.share.index[0].invalid")
+		}
+	}
+	return ShamirInterpolate(shares, 0)
+}
+
+// ShamirInterpolate -- returns the Lagrange interpolation of the shares at x.
+func ShamirInterpolate(shares []*ShamirShare, x byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("shamir.shares.empty")
+	}
+	size := len(shares[0].Value)
+	seen := make(map[byte]bool)
+	for _, share := range shares {
+		if seen[share.Index] {
+			return nil, fmt.Errorf("shamir.share.index[%v].duplicate", share.Index)
+		}
+		seen[share.Index] = true
+		if len(share.Value) != size {
+			return nil, fmt.Errorf("shamir.share.size[%v].mismatch[%v]", len(share.Value), size)
+		}
+	}
+	for _, share := range shares {
+		if share.Index == x {
+			return append([]byte{}, share.Value...), nil
+		}
+	}
+
+	// basis[i] = Π (x - x[j]) / (x[i] - x[j]) for j != i, the minus is the xor in GF(256).
+	logProd := 0
+	for _, share := range shares {
+		logProd += int(gf256Log[share.Index^x])
+	}
+	result := make([]byte, size)
+	for _, share := range shares {
+		logBasis := logProd - int(gf256Log[share.Index^x])
+		for _, other := range shares {
+			if other.Index != share.Index {
+				logBasis -= int(gf256Log[share.Index^other.Index])
+			}
+		}
+		logBasis %= 255
+		if logBasis < 0 {
+			logBasis += 255
+		}
+		for k, v := range share.Value {
+			if v != 0 {
+				result[k] ^= gf256Exp[(int(gf256Log[v])+logBasis)%255]
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,92 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShamir(t *testing.T) {
+	secret := Sha256([]byte("tokucore.shamir.secret"))
+
+	tests := []struct {
+		threshold int
+		count     int
+	}{
+		{1, 1},
+		{1, 3},
+		{2, 3},
+		{3, 5},
+		{5, 5},
+		{16, 255},
+	}
+	for _, test := range tests {
+		shares, err := ShamirSplit(test.threshold, test.count, secret)
+		assert.Nil(t, err)
+		assert.Equal(t, test.count, len(shares))
+
+		// The last threshold shares.
+		got, err := ShamirCombine(shares[test.count-test.threshold:])
+		assert.Nil(t, err)
+		assert.Equal(t, secret, got)
+
+		// All the shares.
+		got, err = ShamirCombine(shares)
+		assert.Nil(t, err)
+		assert.Equal(t, secret, got)
+
+		// Less than the threshold.
+		if test.threshold > 1 {
+			got, err = ShamirCombine(shares[:test.threshold-1])
+			assert.Nil(t, err)
+			assert.NotEqual(t, secret, got)
+		}
+	}
+}
+
+func TestShamirInterpolate(t *testing.T) {
+	// f(x) = 0x01 + 0x02*x, 0x02*0x03 = 0x06 and 0x02*0x8d = 0x01 in GF(256).
+	shares := []*ShamirShare{
+		{Index: 1, Value: []byte{0x03}},
+		{Index: 3, Value: []byte{0x07}},
+	}
+	got, err := ShamirInterpolate(shares, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "01", hex.EncodeToString(got))
+	got, err = ShamirInterpolate(shares, 0x8d)
+	assert.Nil(t, err)
+	assert.Equal(t, "00", hex.EncodeToString(got))
+	got, err = ShamirInterpolate(shares, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, "07", hex.EncodeToString(got))
+}
+
+func TestShamirErrors(t *testing.T) {
+	secret := []byte{0x01, 0x02}
+
+	_, err := ShamirSplit(0, 3, secret)
+	assert.Equal(t, "shamir.threshold[0].count[3].invalid", err.Error())
+	_, err = ShamirSplit(4, 3, secret)
+	assert.Equal(t, "shamir.threshold[4].count[3].invalid", err.Error())
+	_, err = ShamirSplit(2, 256, secret)
+	assert.Equal(t, "shamir.threshold[2].count[256].invalid", err.Error())
+	_, err = ShamirSplit(2, 3, nil)
+	assert.Equal(t, "shamir.secret.empty", err.Error())
+
+	shares, err := ShamirSplit(2, 3, secret)
+	assert.Nil(t, err)
+	_, err = ShamirCombine(nil)
+	assert.Equal(t, "shamir.shares.empty", err.Error())
+	_, err = ShamirCombine([]*ShamirShare{shares[0], shares[0]})
+	assert.Equal(t, "shamir.share.index[1].duplicate", err.Error())
+	_, err = ShamirCombine([]*ShamirShare{shares[0], {Index: 2, Value: []byte{0x01}}})
+	assert.Equal(t, "shamir.share.size[1].mismatch[2]", err.Error())
+	_, err = ShamirCombine([]*ShamirShare{shares[0], {Index: 0, Value: secret}})
+	assert.Equal(t, "shamir.share.index[0].invalid", err.Error())
+}
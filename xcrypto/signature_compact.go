@@ -0,0 +1,111 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	xecdsa "github.com/keyfuse/tokucore/xcrypto/ecdsa"
+	"github.com/keyfuse/tokucore/xcrypto/schnorr"
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+)
+
+const (
+	// CompactSignatureSize -- the size of the compact recoverable signature: header + r + s.
+	CompactSignatureSize = 65
+
+	// compactHeaderBase -- the header is 27 + recid, plus 4 for the compressed public key.
+	compactHeaderBase       byte = 27
+	compactHeaderCompressed byte = 4
+)
+
+// SignatureCompact -- a type representing a compact recoverable ECDSA signature.
+type SignatureCompact struct {
+	R          *big.Int
+	S          *big.Int
+	RecID      byte
+	Compressed bool
+}
+
+// NewSignatureCompact -- create new SignatureCompact.
+func NewSignatureCompact() *SignatureCompact {
+	return &SignatureCompact{}
+}
+
+// Serialize -- used to serialize the struct to the 65-byte signature.
+func (sig *SignatureCompact) Serialize() ([]byte, error) {
+	if sig.RecID > 3 {
+		return nil, fmt.Errorf("ecdsa.compact.signature.recid[%v].invalid", sig.RecID)
+	}
+	header := compactHeaderBase + sig.RecID
+	if sig.Compressed {
+		header += compactHeaderCompressed
+	}
+	sigFinal := make([]byte, CompactSignatureSize)
+	sigFinal[0] = header
+	copy(sigFinal[1:33], schnorr.IntToByte(sig.R))
+	copy(sigFinal[33:], schnorr.IntToByte(sig.S))
+	return sigFinal, nil
+}
+
+// Deserialize -- used to deserialize the 65-byte signature to struct.
+func (sig *SignatureCompact) Deserialize(sign []byte) error {
+	if len(sign) != CompactSignatureSize {
+		return fmt.Errorf("ecdsa.compact.signature.size[%v].invalid", len(sign))
+	}
+	header := sign[0]
+	if header < compactHeaderBase || header >= compactHeaderBase+2*compactHeaderCompressed {
+		return fmt.Errorf("ecdsa.compact.signature.header[%v].invalid", header)
+	}
+	header -= compactHeaderBase
+	sig.Compressed = header >= compactHeaderCompressed
+	sig.RecID = header % compactHeaderCompressed
+	sig.R = new(big.Int).SetBytes(sign[1:33])
+	sig.S = new(big.Int).SetBytes(sign[33:])
+	return nil
+}
+
+// EcdsaSignCompact -- used get the 65-byte compact recoverable ecdsa signature.
+// The compressed indicates the public key recovered from the signature is compressed or not.
+func EcdsaSignCompact(prv *PrvKey, hash []byte, compressed bool) ([]byte, error) {
+	eprv := (*ecdsa.PrivateKey)(prv)
+	r, s, recid, err := xecdsa.SignRecoverable(eprv, hash)
+	if err != nil {
+		return nil, err
+	}
+	sig := &SignatureCompact{R: r, S: s, RecID: recid, Compressed: compressed}
+	return sig.Serialize()
+}
+
+// RecoverPubKey -- recovers the public key from the compact signature of the hash.
+// Returns the public key and whether it is compressed.
+func RecoverPubKey(hash []byte, sign []byte) (*PubKey, bool, error) {
+	sig := NewSignatureCompact()
+	if err := sig.Deserialize(sign); err != nil {
+		return nil, false, err
+	}
+
+	curve := secp256k1.SECP256K1()
+	x, y, err := xecdsa.RecoverPubKey(curve, hash, sig.R, sig.S, sig.RecID)
+	if err != nil {
+		return nil, false, fmt.Errorf("ecdsa.recover.pubkey.failed:%v", err)
+	}
+	return &PubKey{Curve: curve, X: x, Y: y}, sig.Compressed, nil
+}
+
+// EcdsaVerifyCompact -- used to verify the compact signature, the recovered public key must be the pub.
+func EcdsaVerifyCompact(pub *PubKey, hash []byte, sign []byte) error {
+	recovered, _, err := RecoverPubKey(hash, sign)
+	if err != nil {
+		return err
+	}
+	if recovered.X.Cmp(pub.X) != 0 || recovered.Y.Cmp(pub.Y) != 0 {
+		return fmt.Errorf("ecdsa.compact.signature.verify.failed")
+	}
+	return nil
+}
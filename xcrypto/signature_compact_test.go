@@ -0,0 +1,126 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignatureCompact(t *testing.T) {
+	key1 := PrvKeyFromBytes([]byte{0x01})
+	key2 := PrvKeyFromBytes([]byte{0x02})
+
+	recids := make(map[byte]bool)
+	for i := 0; i < 32; i++ {
+		msg := DoubleSha256([]byte{byte(i)})
+		for _, compressed := range []bool{true, false} {
+			signature, err := EcdsaSignCompact(key1, msg, compressed)
+			assert.Nil(t, err)
+			assert.Equal(t, CompactSignatureSize, len(signature))
+
+			pub, gotCompressed, err := RecoverPubKey(msg, signature)
+			assert.Nil(t, err)
+			assert.Equal(t, compressed, gotCompressed)
+			assert.Equal(t, key1.PubKey().SerializeUncompressed(), pub.SerializeUncompressed())
+
+			err = EcdsaVerifyCompact(key1.PubKey(), msg, signature)
+			assert.Nil(t, err)
+			err = EcdsaVerifyCompact(key2.PubKey(), msg, signature)
+			assert.Equal(t, "ecdsa.compact.signature.verify.failed", err.Error())
+
+			// Same r and s as the DER signature.
+			sig := NewSignatureCompact()
+			assert.Nil(t, sig.Deserialize(signature))
+			der, err := (&SignatureEcdsa{R: sig.R, S: sig.S}).Serialize()
+			assert.Nil(t, err)
+			assert.Nil(t, EcdsaVerify(key1.PubKey(), msg, der))
+			recids[sig.RecID] = true
+		}
+	}
+	assert.True(t, recids[0])
+	assert.True(t, recids[1])
+}
+
+func TestSignatureCompactErrors(t *testing.T) {
+	msg := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+	key1 := PrvKeyFromBytes([]byte{0x01})
+	signature, err := EcdsaSignCompact(key1, msg, true)
+	assert.Nil(t, err)
+
+	// Size.
+	{
+		_, _, err := RecoverPubKey(msg, signature[1:])
+		assert.Equal(t, "ecdsa.compact.signature.size[64].invalid", err.Error())
+	}
+
+	// Header.
+	{
+		bad := append([]byte{}, signature...)
+		bad[0] = 26
+		_, _, err := RecoverPubKey(msg, bad)
+		assert.Equal(t, "ecdsa.compact.signature.header[26].invalid", err.Error())
+		bad[0] = 35
+		_, _, err = RecoverPubKey(msg, bad)
+		assert.Equal(t, "ecdsa.compact.signature.header[35].invalid", err.Error())
+	}
+
+	// Wrong recovery id recovers another key.
+	{
+		sig := NewSignatureCompact()
+		assert.Nil(t, sig.Deserialize(signature))
+		sig.RecID ^= 0x01
+		bad, err := sig.Serialize()
+		assert.Nil(t, err)
+		pub, _, err := RecoverPubKey(msg, bad)
+		assert.Nil(t, err)
+		assert.NotEqual(t, key1.PubKey().SerializeCompressed(), pub.SerializeCompressed())
+		assert.NotNil(t, EcdsaVerifyCompact(key1.PubKey(), msg, bad))
+	}
+
+	// R.x >= N is out of the field for most r.
+	{
+		sig := NewSignatureCompact()
+		assert.Nil(t, sig.Deserialize(signature))
+		sig.RecID |= 0x02
+		bad, err := sig.Serialize()
+		assert.Nil(t, err)
+		_, _, err = RecoverPubKey(msg, bad)
+		assert.NotNil(t, err)
+	}
+
+	// Zero s.
+	{
+		bad := append([]byte{}, signature...)
+		copy(bad[33:], make([]byte, 32))
+		_, _, err := RecoverPubKey(msg, bad)
+		assert.NotNil(t, err)
+	}
+
+	// Serialize.
+	{
+		sig := &SignatureCompact{R: big.NewInt(1), S: big.NewInt(1), RecID: 4}
+		_, err := sig.Serialize()
+		assert.Equal(t, "ecdsa.compact.signature.recid[4].invalid", err.Error())
+	}
+}
+
+func BenchmarkRecoverPubKey(b *testing.B) {
+	msg := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+	key1 := PrvKeyFromBytes([]byte{0x01})
+	signature, err := EcdsaSignCompact(key1, msg, true)
+	if err != nil {
+		panic(err)
+	}
+
+	for n := 0; n < b.N; n++ {
+		if _, _, err := RecoverPubKey(msg, signature); err != nil {
+			panic(err)
+		}
+	}
+}
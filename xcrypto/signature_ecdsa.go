@@ -12,6 +12,15 @@ import (
 	"math/big"
 
 	xecdsa "github.com/keyfuse/tokucore/xcrypto/ecdsa"
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+)
+
+const (
+	// minSigLen -- the shortest DER signature: 0x30 len 0x02 1 r 0x02 1 s.
+	minSigLen = 8
+
+	// maxSigLen -- the longest DER signature with 33-byte r and s.
+	maxSigLen = 72
 )
 
 // SignatureEcdsa -- a type representing an ECDSA signature.
@@ -34,13 +43,82 @@ func (sig *SignatureEcdsa) Serialize() ([]byte, error) {
 	return der, nil
 }
 
-// Deserialize -- used to deserialize the signature to struct.
+// Deserialize -- used to deserialize the strict DER signature to struct.
 func (sig *SignatureEcdsa) Deserialize(sign []byte) error {
-	_, err := asn1.Unmarshal(sign, sig)
-	return err
+	if err := CheckStrictDER(sign); err != nil {
+		return err
+	}
+	lenR := int(sign[3])
+	sig.R = new(big.Int).SetBytes(sign[4 : 4+lenR])
+	sig.S = new(big.Int).SetBytes(sign[6+lenR:])
+	return nil
+}
+
+// IsLowS -- returns true if the S is not greater than N/2.
+func (sig *SignatureEcdsa) IsLowS() bool {
+	halfOrder := new(big.Int).Rsh(secp256k1.SECP256K1().Params().N, 1)
+	return sig.S.Cmp(halfOrder) <= 0
 }
 
-// EcdsaSign -- used get the ecdsa signature.
+// NormalizeS -- replaces the S with N-S if it is greater than N/2, BIP62 and BIP146.
+// Returns true if the S is changed.
+func (sig *SignatureEcdsa) NormalizeS() bool {
+	if sig.IsLowS() {
+		return false
+	}
+	sig.S = new(big.Int).Sub(secp256k1.SECP256K1().Params().N, sig.S)
+	return true
+}
+
+// CheckStrictDER -- checks the signature(without the sighash type) is the strict DER encoding of BIP66.
+// Format: 0x30 [total-length] 0x02 [R-length] [R] 0x02 [S-length] [S]
+// https://github.com/bitcoin/bips/blob/master/bip-0066.mediawiki
+func CheckStrictDER(sign []byte) error {
+	size := len(sign)
+	if size < minSigLen || size > maxSigLen {
+		return fmt.Errorf("ecdsa.signature.der.size[%v].invalid", size)
+	}
+	if sign[0] != 0x30 {
+		return fmt.Errorf("ecdsa.signature.der.compound.marker[%x].invalid", sign[0])
+	}
+	if int(sign[1]) != size-2 {
+		return fmt.Errorf("ecdsa.signature.der.length[%v].mismatch[%v]", sign[1], size-2)
+	}
+
+	// The lengths of R and S must cover the signature exactly.
+	lenR := int(sign[3])
+	if 5+lenR >= size {
+		return fmt.Errorf("ecdsa.signature.der.r.length[%v].invalid", lenR)
+	}
+	lenS := int(sign[5+lenR])
+	if lenR+lenS+6 != size {
+		return fmt.Errorf("ecdsa.signature.der.s.length[%v].invalid", lenS)
+	}
+
+	if err := checkStrictDERInteger("r", sign[2], sign[4:4+lenR]); err != nil {
+		return err
+	}
+	return checkStrictDERInteger("s", sign[4+lenR], sign[6+lenR:])
+}
+
+// checkStrictDERInteger -- checks the DER integer is positive and has no padding.
+func checkStrictDERInteger(name string, marker byte, v []byte) error {
+	if marker != 0x02 {
+		return fmt.Errorf("ecdsa.signature.der.%s.marker[%x].invalid", name, marker)
+	}
+	if len(v) == 0 {
+		return fmt.Errorf("ecdsa.signature.der.%s.empty", name)
+	}
+	if v[0]&0x80 != 0 {
+		return fmt.Errorf("ecdsa.signature.der.%s.negative", name)
+	}
+	if len(v) > 1 && v[0] == 0x00 && v[1]&0x80 == 0 {
+		return fmt.Errorf("ecdsa.signature.der.%s.padded", name)
+	}
+	return nil
+}
+
+// EcdsaSign -- used get the ecdsa signature, the S is always low.
 func EcdsaSign(prv *PrvKey, hash []byte) ([]byte, error) {
 	eprv := (*ecdsa.PrivateKey)(prv)
 	r, s, err := xecdsa.Sign(eprv, hash)
@@ -48,16 +126,33 @@ func EcdsaSign(prv *PrvKey, hash []byte) ([]byte, error) {
 		return nil, err
 	}
 	sig := &SignatureEcdsa{R: r, S: s}
+	sig.NormalizeS()
 	return sig.Serialize()
 }
 
-// EcdsaVerify -- used to verify the ecdsa signature.
+// EcdsaVerify -- used to verify the strict DER ecdsa signature, the high S is accepted as the consensus.
 func EcdsaVerify(pub *PubKey, hash []byte, sign []byte) error {
 	sig := NewSignatureEcdsa()
 	if err := sig.Deserialize(sign); err != nil {
 		return err
 	}
+	return ecdsaVerify(pub, hash, sig)
+}
+
+// EcdsaVerifyLowS -- used to verify the strict DER ecdsa signature with the low S, the DERSIG and LOW_S policy.
+func EcdsaVerifyLowS(pub *PubKey, hash []byte, sign []byte) error {
+	sig := NewSignatureEcdsa()
+	if err := sig.Deserialize(sign); err != nil {
+		return err
+	}
+	if !sig.IsLowS() {
+		return fmt.Errorf("ecdsa.signature.s.not.low")
+	}
+	return ecdsaVerify(pub, hash, sig)
+}
 
+// ecdsaVerify -- verifies the r and s of the signature.
+func ecdsaVerify(pub *PubKey, hash []byte, sig *SignatureEcdsa) error {
 	epub := (*ecdsa.PublicKey)(pub)
 	if !xecdsa.Verify(epub, hash, sig.R, sig.S) {
 		return fmt.Errorf("ecdsa.signature.verify.failed")
@@ -6,8 +6,12 @@
 package xcrypto
 
 import (
+	"encoding/hex"
+	"math/big"
 	"testing"
 
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,6 +35,78 @@ func TestSignatureEcdsaSignerAndVerifer(t *testing.T) {
 	}
 }
 
+func TestSignatureEcdsaStrictDER(t *testing.T) {
+	msg := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+	key1 := PrvKeyFromBytes([]byte{0x01})
+	signature, err := EcdsaSign(key1, msg)
+	assert.Nil(t, err)
+	assert.Nil(t, CheckStrictDER(signature))
+
+	tests := []struct {
+		name string
+		sig  string
+		err  string
+	}{
+		{"short", "30060201010201", "ecdsa.signature.der.size[7].invalid"},
+		{"marker", "3106020101020101", "ecdsa.signature.der.compound.marker[31].invalid"},
+		{"total.length", "3007020101020101", "ecdsa.signature.der.length[7].mismatch[6]"},
+		{"r.length", "3006020401020101", "ecdsa.signature.der.r.length[4].invalid"},
+		{"s.length", "3006020101020201", "ecdsa.signature.der.s.length[2].invalid"},
+		{"r.marker", "3006030101020101", "ecdsa.signature.der.r.marker[3].invalid"},
+		{"r.empty", "3006020002020101", "ecdsa.signature.der.r.empty"},
+		{"r.negative", "3006020181020101", "ecdsa.signature.der.r.negative"},
+		{"r.padded", "300702020001020101", "ecdsa.signature.der.r.padded"},
+		{"s.marker", "3006020101030101", "ecdsa.signature.der.s.marker[3].invalid"},
+		{"s.negative", "3006020101020181", "ecdsa.signature.der.s.negative"},
+		{"s.padded", "300702010102020001", "ecdsa.signature.der.s.padded"},
+		{"ok.padded.high.bit", "300702020081020101", ""},
+	}
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.sig)
+		err := CheckStrictDER(data)
+		if test.err == "" {
+			assert.Nil(t, err, test.name)
+		} else {
+			assert.Equal(t, test.err, err.Error(), test.name)
+		}
+	}
+
+	// Trailing garbage.
+	{
+		err := EcdsaVerify(key1.PubKey(), msg, append(signature, 0x00))
+		assert.NotNil(t, err)
+	}
+}
+
+func TestSignatureEcdsaLowS(t *testing.T) {
+	N := secp256k1.SECP256K1().Params().N
+	key1 := PrvKeyFromBytes([]byte{0x01})
+
+	for i := 0; i < 16; i++ {
+		msg := DoubleSha256([]byte{byte(i)})
+		signature, err := EcdsaSign(key1, msg)
+		assert.Nil(t, err)
+
+		sig := NewSignatureEcdsa()
+		assert.Nil(t, sig.Deserialize(signature))
+		assert.True(t, sig.IsLowS())
+		assert.False(t, sig.NormalizeS())
+		assert.Nil(t, EcdsaVerifyLowS(key1.PubKey(), msg, signature))
+
+		// The high S is valid for the consensus, not for the policy.
+		high := &SignatureEcdsa{R: sig.R, S: new(big.Int).Sub(N, sig.S)}
+		assert.False(t, high.IsLowS())
+		highsig, err := high.Serialize()
+		assert.Nil(t, err)
+		assert.Nil(t, EcdsaVerify(key1.PubKey(), msg, highsig))
+		err = EcdsaVerifyLowS(key1.PubKey(), msg, highsig)
+		assert.Equal(t, "ecdsa.signature.s.not.low", err.Error())
+
+		assert.True(t, high.NormalizeS())
+		assert.Equal(t, 0, high.S.Cmp(sig.S))
+	}
+}
+
 func BenchmarkSignatureEcdsaSigner(b *testing.B) {
 	msg := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
 
@@ -7,6 +7,7 @@ package xcrypto
 
 import (
 	"crypto/ecdsa"
+	"crypto/rand"
 	"fmt"
 	"math/big"
 
@@ -63,3 +64,95 @@ func SchnorrVerify(pub *PubKey, hash []byte, sign []byte) error {
 	}
 	return nil
 }
+
+// SchnorrBIP340Sign -- used get the 64-byte BIP340 schnorr signature.
+// https://github.com/bitcoin/bips/blob/master/bip-0340.mediawiki
+func SchnorrBIP340Sign(prv *PrvKey, hash []byte) ([]byte, error) {
+	aux := make([]byte, 32)
+	if _, err := rand.Read(aux); err != nil {
+		return nil, err
+	}
+	eprv := (*ecdsa.PrivateKey)(prv)
+	return schnorr.SignBIP340(eprv, hash, aux)
+}
+
+// SchnorrBIP340Verify -- used to verify the BIP340 schnorr signature against the x-only public key of pub.
+func SchnorrBIP340Verify(pub *PubKey, hash []byte, sign []byte) error {
+	if !schnorr.VerifyBIP340(pub.SerializeXOnly(), hash, sign) {
+		return fmt.Errorf("schnorr.bip340.signature.verify.failed")
+	}
+	return nil
+}
+
+// SchnorrBatchItem -- the (pubkey, hash, signature) triple of the batch verification.
+type SchnorrBatchItem struct {
+	PubKey    *PubKey
+	Hash      []byte
+	Signature []byte
+}
+
+// SchnorrBatchVerify -- used to verify the schnorr signatures with one multi-scalar multiplication.
+// If the batch fails, the items are verified one by one to identify the bad one.
+func SchnorrBatchVerify(items []SchnorrBatchItem) error {
+	pubs := make([]*ecdsa.PublicKey, len(items))
+	hashes := make([][]byte, len(items))
+	rs := make([]*big.Int, len(items))
+	ss := make([]*big.Int, len(items))
+	for i, item := range items {
+		if err := schnorrBatchItemCheck("schnorr.batch", i, item); err != nil {
+			return err
+		}
+		if len(item.Signature) != 64 {
+			return fmt.Errorf("schnorr.batch.signature[%v].size[%v].invalid", i, len(item.Signature))
+		}
+		sig := NewSignatureSchnorr()
+		if err := sig.Deserialize(item.Signature); err != nil {
+			return err
+		}
+		pubs[i] = (*ecdsa.PublicKey)(item.PubKey)
+		hashes[i] = item.Hash
+		rs[i], ss[i] = sig.R, sig.S
+	}
+	if schnorr.BatchVerify(pubs, hashes, rs, ss) {
+		return nil
+	}
+	for i, item := range items {
+		if err := SchnorrVerify(item.PubKey, item.Hash, item.Signature); err != nil {
+			return fmt.Errorf("schnorr.batch.signature[%v].verify.failed", i)
+		}
+	}
+	return nil
+}
+
+// SchnorrBIP340BatchVerify -- used to verify the BIP340 schnorr signatures with one multi-scalar multiplication.
+// If the batch fails, the items are verified one by one to identify the bad one.
+func SchnorrBIP340BatchVerify(items []SchnorrBatchItem) error {
+	pks := make([][]byte, len(items))
+	hashes := make([][]byte, len(items))
+	sigs := make([][]byte, len(items))
+	for i, item := range items {
+		if err := schnorrBatchItemCheck("schnorr.bip340.batch", i, item); err != nil {
+			return err
+		}
+		pks[i] = item.PubKey.SerializeXOnly()
+		hashes[i] = item.Hash
+		sigs[i] = item.Signature
+	}
+	if schnorr.BatchVerifyBIP340(pks, hashes, sigs) {
+		return nil
+	}
+	for i, item := range items {
+		if err := SchnorrBIP340Verify(item.PubKey, item.Hash, item.Signature); err != nil {
+			return fmt.Errorf("schnorr.bip340.batch.signature[%v].verify.failed", i)
+		}
+	}
+	return nil
+}
+
+// schnorrBatchItemCheck -- rejects the batch item without the public key, the hash or the signature.
+func schnorrBatchItemCheck(batch string, i int, item SchnorrBatchItem) error {
+	if item.PubKey == nil || item.PubKey.X == nil || item.PubKey.Y == nil || item.Hash == nil || item.Signature == nil {
+		return fmt.Errorf("%v.item[%v].incomplete", batch, i)
+	}
+	return nil
+}
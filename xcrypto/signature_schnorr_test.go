@@ -30,6 +30,104 @@ func TestSignatureSchnorrSignerAndVerifer(t *testing.T) {
 		assert.Equal(t, want, got)
 	}
 }
+
+func TestSignatureSchnorrBIP340(t *testing.T) {
+	msg := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+
+	// The pubkey of key1 has an odd y.
+	key1 := PrvKeyFromBytes([]byte{0x06})
+	key2 := PrvKeyFromBytes([]byte{0x02})
+	assert.Equal(t, uint(1), key1.PubKey().Y.Bit(0))
+
+	{
+		signature, err := SchnorrBIP340Sign(key1, msg)
+		assert.Nil(t, err)
+		assert.Equal(t, 64, len(signature))
+
+		err = SchnorrBIP340Verify(key1.PubKey(), msg, signature)
+		assert.Nil(t, err)
+
+		xonly, err := PubKeyFromXOnly(key1.PubKey().SerializeXOnly())
+		assert.Nil(t, err)
+		err = SchnorrBIP340Verify(xonly, msg, signature)
+		assert.Nil(t, err)
+
+		err = SchnorrBIP340Verify(key2.PubKey(), msg, signature)
+		got := err.Error()
+		want := "schnorr.bip340.signature.verify.failed"
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestSignatureSchnorrBatchVerify(t *testing.T) {
+	var items []SchnorrBatchItem
+	var items340 []SchnorrBatchItem
+	for i := 0; i < 8; i++ {
+		key := PrvKeyFromBytes([]byte{byte(i + 1)})
+		msg := DoubleSha256([]byte{byte(i)})
+
+		signature, err := SchnorrSign(key, msg)
+		assert.Nil(t, err)
+		items = append(items, SchnorrBatchItem{PubKey: key.PubKey(), Hash: msg, Signature: signature})
+
+		signature, err = SchnorrBIP340Sign(key, msg)
+		assert.Nil(t, err)
+		items340 = append(items340, SchnorrBatchItem{PubKey: key.PubKey(), Hash: msg, Signature: signature})
+	}
+	assert.Nil(t, SchnorrBatchVerify(items))
+	assert.Nil(t, SchnorrBIP340BatchVerify(items340))
+	assert.Nil(t, SchnorrBatchVerify(nil))
+	assert.Nil(t, SchnorrBIP340BatchVerify(nil))
+
+	// The bad item is identified.
+	{
+		bad := append([]SchnorrBatchItem{}, items...)
+		bad[5].Hash = items[4].Hash
+		err := SchnorrBatchVerify(bad)
+		assert.Equal(t, "schnorr.batch.signature[5].verify.failed", err.Error())
+
+		bad340 := append([]SchnorrBatchItem{}, items340...)
+		bad340[2].PubKey = items340[3].PubKey
+		err = SchnorrBIP340BatchVerify(bad340)
+		assert.Equal(t, "schnorr.bip340.batch.signature[2].verify.failed", err.Error())
+
+		// The legacy signature is not the BIP340 one.
+		err = SchnorrBIP340BatchVerify(items)
+		assert.Equal(t, "schnorr.bip340.batch.signature[0].verify.failed", err.Error())
+	}
+
+	// Size.
+	{
+		bad := append([]SchnorrBatchItem{}, items...)
+		bad[1].Signature = bad[1].Signature[1:]
+		err := SchnorrBatchVerify(bad)
+		assert.Equal(t, "schnorr.batch.signature[1].size[63].invalid", err.Error())
+		err = SchnorrBIP340BatchVerify(bad)
+		assert.NotNil(t, err)
+	}
+
+	// Incomplete.
+	{
+		for i := 0; i < 4; i++ {
+			bad := append([]SchnorrBatchItem{}, items340...)
+			switch i {
+			case 0:
+				bad[3].PubKey = nil
+			case 1:
+				bad[3].PubKey = &PubKey{}
+			case 2:
+				bad[3].Hash = nil
+			case 3:
+				bad[3].Signature = nil
+			}
+			err := SchnorrBatchVerify(bad)
+			assert.Equal(t, "schnorr.batch.item[3].incomplete", err.Error())
+			err = SchnorrBIP340BatchVerify(bad)
+			assert.Equal(t, "schnorr.bip340.batch.item[3].incomplete", err.Error())
+		}
+	}
+}
+
 func BenchmarkSignatureSchnorrSigner(b *testing.B) {
 	msg := DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
 
@@ -0,0 +1,59 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// taprootTweak -- returns the BIP341 tweak t = tagged_hash("TapTweak", P.x || merkleRoot).
+func taprootTweak(pub *PubKey, merkleRoot []byte) (*big.Int, error) {
+	t := new(big.Int).SetBytes(TaggedHash("TapTweak", pub.SerializeXOnly(), merkleRoot))
+	if t.Cmp(pub.Curve.Params().N) >= 0 {
+		return nil, fmt.Errorf("taproot.tweak.out.of.range")
+	}
+	return t, nil
+}
+
+// TaprootTweakPubKey -- returns the BIP341 output key Q = P + t·G of the internal key P,
+// P is taken with the even y coord. The nil merkleRoot is the key path only output of BIP86.
+func TaprootTweakPubKey(pub *PubKey, merkleRoot []byte) (*PubKey, error) {
+	t, err := taprootTweak(pub, merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+	internal, err := PubKeyFromXOnly(pub.SerializeXOnly())
+	if err != nil {
+		return nil, err
+	}
+	out := internal.Add(PrvKeyFromBytes(t.Bytes()).PubKey())
+	if out.X.Sign() == 0 && out.Y.Sign() == 0 {
+		return nil, fmt.Errorf("taproot.output.key.is.infinity")
+	}
+	return out, nil
+}
+
+// TaprootTweakPrvKey -- returns the private key of the BIP341 output key,
+// d' = d + t with the d negated if the internal key has an odd y coord.
+func TaprootTweakPrvKey(prv *PrvKey, merkleRoot []byte) (*PrvKey, error) {
+	pub := prv.PubKey()
+	t, err := taprootTweak(pub, merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+	n := prv.Curve.Params().N
+	d := new(big.Int).Set(prv.D)
+	if pub.Y.Bit(0) == 1 {
+		d.Sub(n, d)
+	}
+	d.Add(d, t)
+	d.Mod(d, n)
+	if d.Sign() == 0 {
+		return nil, fmt.Errorf("taproot.tweaked.prvkey.is.zero")
+	}
+	return PrvKeyFromBytes(d.Bytes()), nil
+}
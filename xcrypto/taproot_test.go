@@ -0,0 +1,57 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaprootTweak(t *testing.T) {
+	// BIP86 m/86'/0'/0'/0/0 and m/86'/0'/0'/1/0.
+	tests := []struct {
+		prv      string
+		internal string
+		output   string
+	}{
+		{
+			prv:      "41f41d69260df4cf277826a9b65a3717e4eeddbeedf637f212ca096576479361",
+			internal: "cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115",
+			output:   "a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c",
+		},
+		{
+			prv:      "6ccbca4a02ac648702dde463d9c1b0d328a4df1e068ef9dc2bc788b33a4f0412",
+			internal: "399f1b2f4393f29a18c937859c5dd8a77350103157eb880f02e8c08214277cef",
+			output:   "882d74e5d0572d5a816cef0041a96b6c1de832f6f9676d9605c44d5e9a97d3dc",
+		},
+	}
+
+	for _, test := range tests {
+		key, _ := hex.DecodeString(test.prv)
+		prv := PrvKeyFromBytes(key)
+		assert.Equal(t, test.internal, hex.EncodeToString(prv.PubKey().SerializeXOnly()))
+
+		output, err := TaprootTweakPubKey(prv.PubKey(), nil)
+		assert.Nil(t, err)
+		assert.Equal(t, test.output, hex.EncodeToString(output.SerializeXOnly()))
+
+		// The tweaked private key owns the output key.
+		tweaked, err := TaprootTweakPrvKey(prv, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, output.SerializeXOnly(), tweaked.PubKey().SerializeXOnly())
+
+		// The merkle root commits to the script tree.
+		root := Sha256([]byte("script.tree"))
+		output2, err := TaprootTweakPubKey(prv.PubKey(), root)
+		assert.Nil(t, err)
+		assert.NotEqual(t, output.SerializeXOnly(), output2.SerializeXOnly())
+		tweaked2, err := TaprootTweakPrvKey(prv, root)
+		assert.Nil(t, err)
+		assert.Equal(t, output2.SerializeXOnly(), tweaked2.PubKey().SerializeXOnly())
+	}
+}
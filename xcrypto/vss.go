@@ -0,0 +1,200 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+)
+
+// VssPolynomial -- the Feldman verifiable secret sharing polynomial
+// f(x) = a0 + a1*x + ... + a(t-1)*x^(t-1) mod N, with a0 the secret.
+// Share i is f(i) and the public commitments are Ck = ak*G, so anyone can check
+// a share by f(i)*G = sum(Ck * i^k).
+type VssPolynomial struct {
+	coeffs []*big.Int
+}
+
+// NewVssPolynomial -- creates a random polynomial of degree threshold-1 with the secret as f(0).
+func NewVssPolynomial(secret *big.Int, threshold int) (*VssPolynomial, error) {
+	N := secp256k1.SECP256K1().Params().N
+	if threshold < 1 {
+		return nil, fmt.Errorf("vss.threshold[%v].invalid", threshold)
+	}
+	if secret.Sign() <= 0 || secret.Cmp(N) >= 0 {
+		return nil, fmt.Errorf("vss.secret.out.of.range")
+	}
+
+	coeffs := make([]*big.Int, threshold)
+	coeffs[0] = new(big.Int).Set(secret)
+	for i := 1; i < threshold; i++ {
+		a, err := randModN()
+		if err != nil {
+			return nil, err
+		}
+		coeffs[i] = a
+	}
+	return &VssPolynomial{coeffs: coeffs}, nil
+}
+
+// Threshold -- returns the number of shares required to recover.
+func (p *VssPolynomial) Threshold() int {
+	return len(p.coeffs)
+}
+
+// Secret -- returns f(0).
+func (p *VssPolynomial) Secret() *big.Int {
+	return p.coeffs[0]
+}
+
+// Share -- returns f(id) mod N, the id must not be zero.
+func (p *VssPolynomial) Share(id uint32) *big.Int {
+	N := secp256k1.SECP256K1().Params().N
+	x := new(big.Int).SetUint64(uint64(id))
+
+	// Horner.
+	y := new(big.Int)
+	for i := len(p.coeffs) - 1; i >= 0; i-- {
+		y.Mul(y, x)
+		y.Add(y, p.coeffs[i])
+		y.Mod(y, N)
+	}
+	return y
+}
+
+// Commitments -- returns the Feldman commitments ak*G.
+func (p *VssPolynomial) Commitments() []*PubKey {
+	commitments := make([]*PubKey, len(p.coeffs))
+	for i, a := range p.coeffs {
+		commitments[i] = pointBaseMult(a)
+	}
+	return commitments
+}
+
+// Close -- zeroes the coefficients.
+func (p *VssPolynomial) Close() {
+	for _, a := range p.coeffs {
+		a.SetInt64(0)
+	}
+}
+
+// VssCommitmentAt -- returns sum(Ck * id^k), which is f(id)*G.
+func VssCommitmentAt(commitments []*PubKey, id uint32) *PubKey {
+	N := secp256k1.SECP256K1().Params().N
+	x := new(big.Int).SetUint64(uint64(id))
+	xk := big.NewInt(1)
+
+	var sum *PubKey
+	for _, c := range commitments {
+		term := pointMult(c, xk)
+		if sum == nil {
+			sum = term
+		} else {
+			sum = sum.Add(term)
+		}
+		xk = new(big.Int).Mul(xk, x)
+		xk.Mod(xk, N)
+	}
+	return sum
+}
+
+// VssVerify -- checks the share of id against the commitments.
+func VssVerify(commitments []*PubKey, id uint32, share *big.Int) bool {
+	N := secp256k1.SECP256K1().Params().N
+	if len(commitments) == 0 || id == 0 || share == nil || share.Sign() < 0 || share.Cmp(N) >= 0 {
+		return false
+	}
+	return pointEqual(pointBaseMult(share), VssCommitmentAt(commitments, id))
+}
+
+// LagrangeCoefficient -- returns the Lagrange coefficient of id at x=0 for the set ids,
+// that is prod(j/(j-id)) mod N for j in ids and j != id.
+func LagrangeCoefficient(id uint32, ids []uint32) (*big.Int, error) {
+	N := secp256k1.SECP256K1().Params().N
+	num := big.NewInt(1)
+	den := big.NewInt(1)
+	xi := new(big.Int).SetUint64(uint64(id))
+
+	found := false
+	seen := make(map[uint32]bool)
+	for _, j := range ids {
+		if j == 0 || seen[j] {
+			return nil, fmt.Errorf("vss.ids.invalid[%v]", ids)
+		}
+		seen[j] = true
+		if j == id {
+			found = true
+			continue
+		}
+		xj := new(big.Int).SetUint64(uint64(j))
+		num.Mul(num, xj)
+		num.Mod(num, N)
+		d := new(big.Int).Sub(xj, xi)
+		den.Mul(den, d)
+		den.Mod(den, N)
+	}
+	if !found {
+		return nil, fmt.Errorf("vss.id[%v].not.in.ids[%v]", id, ids)
+	}
+	den.ModInverse(den, N)
+	return num.Mul(num, den).Mod(num, N), nil
+}
+
+// VssCombine -- recovers f(0) from the shares of the ids.
+func VssCombine(shares map[uint32]*big.Int) (*big.Int, error) {
+	N := secp256k1.SECP256K1().Params().N
+	ids := make([]uint32, 0, len(shares))
+	for id := range shares {
+		ids = append(ids, id)
+	}
+
+	secret := new(big.Int)
+	for id, share := range shares {
+		lambda, err := LagrangeCoefficient(id, ids)
+		if err != nil {
+			return nil, err
+		}
+		secret.Add(secret, lambda.Mul(lambda, share))
+		secret.Mod(secret, N)
+	}
+	return secret, nil
+}
+
+// randModN -- returns a random integer in [1, N-1].
+func randModN() (*big.Int, error) {
+	N := secp256k1.SECP256K1().Params().N
+	for {
+		k, err := rand.Int(rand.Reader, N)
+		if err != nil {
+			return nil, err
+		}
+		if k.Sign() != 0 {
+			return k, nil
+		}
+	}
+}
+
+// pointBaseMult -- returns k*G.
+func pointBaseMult(k *big.Int) *PubKey {
+	curve := secp256k1.SECP256K1()
+	x, y := curve.ScalarBaseMult(k.Bytes())
+	return &PubKey{X: x, Y: y, Curve: curve}
+}
+
+// pointMult -- returns k*P.
+func pointMult(p *PubKey, k *big.Int) *PubKey {
+	curve := secp256k1.SECP256K1()
+	x, y := curve.ScalarMult(p.X, p.Y, k.Bytes())
+	return &PubKey{X: x, Y: y, Curve: curve}
+}
+
+// pointEqual -- returns whether the two points are equal.
+func pointEqual(p1 *PubKey, p2 *PubKey) bool {
+	return p1.X.Cmp(p2.X) == 0 && p1.Y.Cmp(p2.Y) == 0
+}
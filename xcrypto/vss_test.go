@@ -0,0 +1,69 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xcrypto
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVss(t *testing.T) {
+	secret, _ := new(big.Int).SetString("15bafcb56279dbfd985d4d17cdaf9bbfc6701b628f9fb00d6d1e0d2cb503ede3", 16)
+	poly, err := NewVssPolynomial(secret, 3)
+	assert.Nil(t, err)
+	defer poly.Close()
+	assert.Equal(t, 3, poly.Threshold())
+	assert.Equal(t, secret, poly.Secret())
+
+	commitments := poly.Commitments()
+	shares := make(map[uint32]*big.Int)
+	for id := uint32(1); id <= 5; id++ {
+		shares[id] = poly.Share(id)
+		assert.True(t, VssVerify(commitments, id, shares[id]))
+	}
+
+	// Bad share.
+	bad := new(big.Int).Add(shares[1], big.NewInt(1))
+	assert.False(t, VssVerify(commitments, 1, bad))
+	assert.False(t, VssVerify(commitments, 2, shares[1]))
+	assert.False(t, VssVerify(commitments, 0, shares[1]))
+	assert.False(t, VssVerify(nil, 1, shares[1]))
+
+	// Any 3 shares recover the secret.
+	got, err := VssCombine(map[uint32]*big.Int{1: shares[1], 3: shares[3], 5: shares[5]})
+	assert.Nil(t, err)
+	assert.Equal(t, secret, got)
+	got, err = VssCombine(map[uint32]*big.Int{2: shares[2], 3: shares[3], 4: shares[4]})
+	assert.Nil(t, err)
+	assert.Equal(t, secret, got)
+
+	// 2 shares do not.
+	got, err = VssCombine(map[uint32]*big.Int{1: shares[1], 2: shares[2]})
+	assert.Nil(t, err)
+	assert.NotEqual(t, secret, got)
+}
+
+func TestVssError(t *testing.T) {
+	N := PrvKeyFromBytes([]byte{0x01}).PubKey().Curve.Params().N
+
+	_, err := NewVssPolynomial(big.NewInt(1), 0)
+	assert.NotNil(t, err)
+	_, err = NewVssPolynomial(big.NewInt(0), 2)
+	assert.NotNil(t, err)
+	_, err = NewVssPolynomial(N, 2)
+	assert.NotNil(t, err)
+
+	_, err = LagrangeCoefficient(1, []uint32{1, 1, 2})
+	assert.NotNil(t, err)
+	_, err = LagrangeCoefficient(1, []uint32{0, 2})
+	assert.NotNil(t, err)
+	_, err = LagrangeCoefficient(3, []uint32{1, 2})
+	assert.NotNil(t, err)
+	_, err = VssCombine(map[uint32]*big.Int{0: big.NewInt(1)})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,101 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xmpc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/keyfuse/tokucore/xbase"
+)
+
+const (
+	// EnvelopeVersion -- the wire format version of the session envelope.
+	EnvelopeVersion byte = 0x01
+
+	maxPartyIDLen = 64
+)
+
+// SessionID -- the 32-byte identifier both parties agree on before running.
+type SessionID [32]byte
+
+// NewSessionID -- creates a random SessionID.
+func NewSessionID() (SessionID, error) {
+	var id SessionID
+	if _, err := io.ReadFull(rand.Reader, id[:]); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
+// String -- returns the hex of the id.
+func (id SessionID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Envelope -- wraps an xcrypto.MpcMessage with the session binding.
+// The wire format is:
+//
+//	version(1) || session(32) || seq(4) || varstring(from) || round(1) || varbytes(payload)
+type Envelope struct {
+	Session SessionID
+	Seq     uint32
+	From    string
+	Round   uint8
+	Payload []byte
+}
+
+// Encode -- encodes the envelope to the wire format.
+func (e *Envelope) Encode() []byte {
+	buffer := xbase.NewBuffer()
+	buffer.WriteU8(EnvelopeVersion)
+	buffer.WriteBytes(e.Session[:])
+	buffer.WriteU32(e.Seq)
+	buffer.WriteVarString(e.From)
+	buffer.WriteU8(e.Round)
+	buffer.WriteVarBytes(e.Payload)
+	return buffer.Bytes()
+}
+
+// Decode -- decodes the envelope from the wire format.
+func (e *Envelope) Decode(data []byte) error {
+	var err error
+	var version byte
+	var session []byte
+
+	buffer := xbase.NewBufferReader(data)
+	if version, err = buffer.ReadU8(); err != nil {
+		return err
+	}
+	if version != EnvelopeVersion {
+		return fmt.Errorf("mpc.envelope.version[%v].unsupported", version)
+	}
+	if session, err = buffer.ReadBytes(len(e.Session)); err != nil {
+		return err
+	}
+	copy(e.Session[:], session)
+	if e.Seq, err = buffer.ReadU32(); err != nil {
+		return err
+	}
+	if e.From, err = buffer.ReadVarString(); err != nil {
+		return err
+	}
+	if len(e.From) == 0 || len(e.From) > maxPartyIDLen {
+		return fmt.Errorf("mpc.envelope.from.size[%v].invalid", len(e.From))
+	}
+	if e.Round, err = buffer.ReadU8(); err != nil {
+		return err
+	}
+	if e.Payload, err = buffer.ReadVarBytes(); err != nil {
+		return err
+	}
+	if !buffer.End() {
+		return fmt.Errorf("mpc.envelope.trailing.bytes[%v]", len(buffer.Remaining()))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xmpc
+
+import (
+	"testing"
+
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelope(t *testing.T) {
+	id, err := NewSessionID()
+	assert.Nil(t, err)
+	assert.Equal(t, 64, len(id.String()))
+
+	want := &Envelope{
+		Session: id,
+		Seq:     7,
+		From:    "alice",
+		Round:   RoundNonce,
+		Payload: []byte{0x01, 0x02},
+	}
+	got := &Envelope{}
+	assert.Nil(t, got.Decode(want.Encode()))
+	assert.Equal(t, want, got)
+
+	// Trailing.
+	assert.NotNil(t, got.Decode(append(want.Encode(), 0x00)))
+	// Version.
+	bad := want.Encode()
+	bad[0] = 0x02
+	assert.NotNil(t, got.Decode(bad))
+	// From empty.
+	want.From = ""
+	assert.NotNil(t, got.Decode(want.Encode()))
+}
+
+func TestEnvelopeError(t *testing.T) {
+	e := &Envelope{From: "alice"}
+
+	f0 := func(buffer *xbase.Buffer) {
+		buffer.WriteU8(EnvelopeVersion)
+	}
+	f1 := func(buffer *xbase.Buffer) {
+		buffer.WriteBytes(e.Session[:])
+	}
+	f2 := func(buffer *xbase.Buffer) {
+		buffer.WriteU32(e.Seq)
+	}
+	f3 := func(buffer *xbase.Buffer) {
+		buffer.WriteVarString(e.From)
+	}
+	f4 := func(buffer *xbase.Buffer) {
+		buffer.WriteU8(e.Round)
+	}
+	f5 := func(buffer *xbase.Buffer) {
+		buffer.WriteVarBytes(e.Payload)
+	}
+
+	buffer := xbase.NewBuffer()
+	fs := []func(buff *xbase.Buffer){f0, f1, f2, f3, f4, f5}
+	for _, fn := range fs {
+		env := &Envelope{}
+		err := env.Decode(buffer.Bytes())
+		assert.NotNil(t, err)
+		fn(buffer)
+	}
+	env := &Envelope{}
+	assert.Nil(t, env.Decode(buffer.Bytes()))
+}
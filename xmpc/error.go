@@ -0,0 +1,43 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xmpc
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrSessionUsed -- returned when a finished or aborted session is run again.
+	// The envelope sequence of a session starts once, so a rerun could not be
+	// told apart from a replay. Nonce reuse is prevented by the parties, which
+	// draw a fresh nonce on every signing.
+	ErrSessionUsed = errors.New("mpc.session.already.used")
+
+	// ErrCommitment -- the revealed Phase2 message does not match the peer's commitment.
+	ErrCommitment = errors.New("mpc.session.nonce.commitment.mismatch")
+
+	// ErrReplay -- the envelope sequence is not the next expected one.
+	ErrReplay = errors.New("mpc.session.envelope.replayed.or.out.of.order")
+)
+
+// AbortError -- the identifiable abort error, which names the party that
+// misbehaved or failed to respond in the round.
+type AbortError struct {
+	Party string
+	Round uint8
+	Err   error
+}
+
+// Error -- implements the error interface.
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("mpc.session.aborted.by.party[%v].at.round[%v]:%v", e.Party, e.Round, e.Err)
+}
+
+// Unwrap -- returns the underlying error.
+func (e *AbortError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,260 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xmpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/keyfuse/tokucore/xcrypto"
+)
+
+const (
+	// DefaultRoundTimeout -- the default deadline of each round.
+	DefaultRoundTimeout = 30 * time.Second
+)
+
+// Rounds of the two-party signing, matching the party Phase numbers.
+// RoundCommit runs before RoundNonce and carries the commitment to the
+// Phase2 message, so neither party can choose its nonce after seeing
+// the other's.
+const (
+	RoundPubKey    uint8 = 1
+	RoundNonce     uint8 = 2
+	RoundSignature uint8 = 4
+	RoundFinal     uint8 = 5
+	RoundCommit    uint8 = 6
+)
+
+// Session -- the state machine which drives a two-party signing over a Transport.
+// The session is bound to its SessionID and the two party identifiers:
+// every envelope carries them with a strictly increasing sequence, so
+// messages from other sessions, other senders, or replayed ones abort the run.
+//
+// A session can run only one signing. After it returns, successful or not,
+// a new session with a new SessionID is required.
+type Session struct {
+	id           SessionID
+	self         string
+	peer         string
+	transport    Transport
+	roundTimeout time.Duration
+	sendSeq      uint32
+	recvSeq      uint32
+	used         bool
+}
+
+// NewSession -- creates new Session.
+func NewSession(id SessionID, self string, peer string, transport Transport) *Session {
+	return &Session{
+		id:           id,
+		self:         self,
+		peer:         peer,
+		transport:    transport,
+		roundTimeout: DefaultRoundTimeout,
+	}
+}
+
+// SetRoundTimeout -- sets the deadline of each round.
+func (s *Session) SetRoundTimeout(timeout time.Duration) {
+	s.roundTimeout = timeout
+}
+
+// ID -- returns the session id.
+func (s *Session) ID() SessionID {
+	return s.id
+}
+
+// SignEcdsa -- runs the 2P-ECDSA signing of the hash with the party.
+// Returns the DER signature and the shared public key.
+func (s *Session) SignEcdsa(ctx context.Context, party *xcrypto.EcdsaParty, hash []byte) ([]byte, *xcrypto.PubKey, error) {
+	if err := s.begin(); err != nil {
+		return nil, nil, err
+	}
+
+	// Phase 1.
+	msg, err := s.exchange(ctx, RoundPubKey, xcrypto.NewMpcPubKeyMsg(party.PubKey()))
+	if err != nil {
+		return nil, nil, err
+	}
+	sharePub := party.Phase1(msg.(*xcrypto.MpcPubKeyMsg).PubKey)
+
+	// Phase 2.
+	encpk, encpub, scalarR := party.Phase2(hash)
+	if scalarR == nil {
+		return nil, nil, fmt.Errorf("mpc.session.phase2.failed")
+	}
+	msg, err = s.exchangeNonce(ctx, xcrypto.NewMpcEcdsaPhase2Msg(encpk, encpub, scalarR))
+	if err != nil {
+		return nil, nil, err
+	}
+	peer2 := msg.(*xcrypto.MpcEcdsaPhase2Msg)
+
+	// Phase 3.
+	shareR := party.Phase3(peer2.R)
+
+	// Phase 4.
+	cipher, err := party.Phase4(peer2.EncPK, peer2.EncPub, shareR)
+	if err != nil {
+		return nil, nil, s.abort(RoundSignature, err)
+	}
+	msg, err = s.exchange(ctx, RoundSignature, xcrypto.NewMpcEcdsaPhase4Msg(cipher))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Phase 5.
+	sig, err := party.Phase5(shareR, msg.(*xcrypto.MpcEcdsaPhase4Msg).Cipher)
+	if err != nil {
+		return nil, nil, s.abort(RoundFinal, err)
+	}
+	if err := xcrypto.EcdsaVerify(sharePub, hash, sig); err != nil {
+		return nil, nil, s.abort(RoundFinal, err)
+	}
+	return sig, sharePub, nil
+}
+
+// SignSchnorr -- runs the two-party Schnorr signing of the hash with the party.
+// Returns the 64-byte signature and the shared public key.
+func (s *Session) SignSchnorr(ctx context.Context, party *xcrypto.SchnorrParty, hash []byte) ([]byte, *xcrypto.PubKey, error) {
+	if err := s.begin(); err != nil {
+		return nil, nil, err
+	}
+
+	// Phase 1.
+	msg, err := s.exchange(ctx, RoundPubKey, xcrypto.NewMpcPubKeyMsg(party.PubKey()))
+	if err != nil {
+		return nil, nil, err
+	}
+	sharePub := party.Phase1(msg.(*xcrypto.MpcPubKeyMsg).PubKey)
+
+	// Phase 2.
+	scalarR := party.Phase2(hash)
+	if scalarR == nil {
+		return nil, nil, fmt.Errorf("mpc.session.phase2.failed")
+	}
+	msg, err = s.exchangeNonce(ctx, xcrypto.NewMpcSchnorrPhase2Msg(scalarR))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Phase 3.
+	shareR := party.Phase3(msg.(*xcrypto.MpcSchnorrPhase2Msg).R)
+
+	// Phase 4.
+	sig1, err := party.Phase4(sharePub, shareR)
+	if err != nil {
+		return nil, nil, s.abort(RoundSignature, err)
+	}
+	msg, err = s.exchange(ctx, RoundSignature, xcrypto.NewMpcSchnorrPhase4Msg(sig1))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Phase 5.
+	sig, err := party.Phase5(shareR, sig1, msg.(*xcrypto.MpcSchnorrPhase4Msg).Sig)
+	if err != nil {
+		return nil, nil, s.abort(RoundFinal, err)
+	}
+	if err := xcrypto.SchnorrVerify(sharePub, hash, sig); err != nil {
+		return nil, nil, s.abort(RoundFinal, err)
+	}
+	return sig, sharePub, nil
+}
+
+// begin -- marks the session used.
+func (s *Session) begin() error {
+	if s.used {
+		return ErrSessionUsed
+	}
+	s.used = true
+	return nil
+}
+
+// exchange -- sends our message of the round and receives the peer's.
+func (s *Session) exchange(ctx context.Context, round uint8, msg xcrypto.MpcMessage) (xcrypto.MpcMessage, error) {
+	rctx, cancel := context.WithTimeout(ctx, s.roundTimeout)
+	defer cancel()
+
+	s.sendSeq++
+	env := &Envelope{
+		Session: s.id,
+		Seq:     s.sendSeq,
+		From:    s.self,
+		Round:   round,
+		Payload: msg.Encode(),
+	}
+	if err := s.transport.Send(rctx, env.Encode()); err != nil {
+		return nil, err
+	}
+
+	data, err := s.transport.Recv(rctx)
+	if err != nil {
+		return nil, s.abort(round, err)
+	}
+	got := &Envelope{}
+	if err := got.Decode(data); err != nil {
+		return nil, s.abort(round, err)
+	}
+	if got.Session != s.id {
+		return nil, s.abort(round, fmt.Errorf("mpc.session.id.mismatch.want[%v].got[%v]", s.id, got.Session))
+	}
+	if got.From != s.peer {
+		return nil, s.abort(round, fmt.Errorf("mpc.session.from.mismatch.want[%v].got[%v]", s.peer, got.From))
+	}
+	if got.Seq != s.recvSeq+1 {
+		return nil, s.abort(round, ErrReplay)
+	}
+	s.recvSeq = got.Seq
+	if got.Round != round {
+		return nil, s.abort(round, fmt.Errorf("mpc.session.round.mismatch.want[%v].got[%v]", round, got.Round))
+	}
+
+	peer, err := xcrypto.DecodeMpcMessage(got.Payload)
+	if err != nil {
+		return nil, s.abort(round, err)
+	}
+	if peer.Type() != msg.Type() {
+		return nil, s.abort(round, fmt.Errorf("mpc.session.message.type.mismatch.want[%v].got[%v]", msg.Type(), peer.Type()))
+	}
+	return peer, nil
+}
+
+// exchangeNonce -- commits to our Phase2 message, then reveals it and checks
+// the peer's against its commitment.
+func (s *Session) exchangeNonce(ctx context.Context, msg xcrypto.MpcMessage) (xcrypto.MpcMessage, error) {
+	commit, err := s.exchange(ctx, RoundCommit, xcrypto.NewMpcCommitMsg(s.commitment(s.self, msg)))
+	if err != nil {
+		return nil, err
+	}
+	peer, err := s.exchange(ctx, RoundNonce, msg)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(commit.(*xcrypto.MpcCommitMsg).Commitment, s.commitment(s.peer, peer)) {
+		return nil, s.abort(RoundNonce, ErrCommitment)
+	}
+	return peer, nil
+}
+
+// commitment -- returns sha256(session || varstring(from) || varbytes(msg)).
+// The Phase2 message carries a fresh random nonce point, which keeps the
+// commitment hiding without a separate blinding factor.
+func (s *Session) commitment(from string, msg xcrypto.MpcMessage) []byte {
+	buffer := xbase.NewBuffer()
+	buffer.WriteBytes(s.id[:])
+	buffer.WriteVarString(from)
+	buffer.WriteVarBytes(msg.Encode())
+	return xcrypto.Sha256(buffer.Bytes())
+}
+
+// abort -- returns the AbortError blaming the peer.
+func (s *Session) abort(round uint8, err error) error {
+	return &AbortError{Party: s.peer, Round: round, Err: err}
+}
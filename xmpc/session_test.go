@@ -0,0 +1,310 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xmpc
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/keyfuse/tokucore/xcrypto"
+	"github.com/keyfuse/tokucore/xcrypto/schnorr"
+	"github.com/keyfuse/tokucore/xcrypto/secp256k1"
+	"github.com/stretchr/testify/assert"
+)
+
+type signResult struct {
+	sig []byte
+	pub *xcrypto.PubKey
+	err error
+}
+
+func mockPrvKeys() (*xcrypto.PrvKey, *xcrypto.PrvKey) {
+	p1, _ := new(big.Int).SetString("15bafcb56279dbfd985d4d17cdaf9bbfc6701b628f9fb00d6d1e0d2cb503ede3", 16)
+	p2, _ := new(big.Int).SetString("76818c328b8aa1e8f17bd599016fef8134b7d5ec315e0b6373953da7e8b5c0c9", 16)
+	return xcrypto.PrvKeyFromBytes(p1.Bytes()), xcrypto.PrvKeyFromBytes(p2.Bytes())
+}
+
+func runEcdsa(t *testing.T, t1 Transport, t2 Transport) {
+	hash := xcrypto.DoubleSha256([]byte{0x01, 0x02, 0x03, 0x04})
+	prv1, prv2 := mockPrvKeys()
+	id, err := NewSessionID()
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+	ch := make(chan signResult, 1)
+	go func() {
+		party := xcrypto.NewEcdsaParty(prv2)
+		defer party.Close()
+		sig, pub, err := NewSession(id, "bob", "alice", t2).SignEcdsa(ctx, party, hash)
+		ch <- signResult{sig, pub, err}
+	}()
+
+	party := xcrypto.NewEcdsaParty(prv1)
+	defer party.Close()
+	session := NewSession(id, "alice", "bob", t1)
+	sig, pub, err := session.SignEcdsa(ctx, party, hash)
+	assert.Nil(t, err)
+	res := <-ch
+	assert.Nil(t, res.err)
+	assert.Equal(t, sig, res.sig)
+	assert.Equal(t, pub, res.pub)
+	assert.Nil(t, xcrypto.EcdsaVerify(pub, hash, sig))
+
+	// Session can not be reused.
+	_, _, err = session.SignEcdsa(ctx, party, hash)
+	assert.Equal(t, ErrSessionUsed, err)
+}
+
+func runSchnorr(t *testing.T, t1 Transport, t2 Transport) {
+	hash := xcrypto.Sha256([]byte{0x01, 0x02, 0x03, 0x04})
+	prv1, prv2 := mockPrvKeys()
+	id, err := NewSessionID()
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+	ch := make(chan signResult, 1)
+	go func() {
+		party, _ := xcrypto.NewSchnorrParty(prv2)
+		defer party.Close()
+		sig, pub, err := NewSession(id, "bob", "alice", t2).SignSchnorr(ctx, party, hash)
+		ch <- signResult{sig, pub, err}
+	}()
+
+	party, _ := xcrypto.NewSchnorrParty(prv1)
+	defer party.Close()
+	sig, pub, err := NewSession(id, "alice", "bob", t1).SignSchnorr(ctx, party, hash)
+	assert.Nil(t, err)
+	res := <-ch
+	assert.Nil(t, res.err)
+	assert.Equal(t, sig, res.sig)
+	assert.Nil(t, xcrypto.SchnorrVerify(pub, hash, sig))
+}
+
+func TestSessionMemory(t *testing.T) {
+	t1, t2 := NewMemoryTransportPair()
+	defer t1.Close()
+	runEcdsa(t, t1, t2)
+
+	t3, t4 := NewMemoryTransportPair()
+	defer t3.Close()
+	runSchnorr(t, t3, t4)
+}
+
+func TestSessionTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	accept := func() *TCPTransport {
+		conn, err := listener.Accept()
+		assert.Nil(t, err)
+		return NewTCPTransport(conn)
+	}
+	connect := func() (*TCPTransport, *TCPTransport) {
+		ch := make(chan *TCPTransport, 1)
+		go func() { ch <- accept() }()
+		t1, err := DialTCPTransport(context.Background(), listener.Addr().String())
+		assert.Nil(t, err)
+		return t1, <-ch
+	}
+
+	t1, t2 := connect()
+	runEcdsa(t, t1, t2)
+	t1.Close()
+	t2.Close()
+
+	t3, t4 := connect()
+	runSchnorr(t, t3, t4)
+	t3.Close()
+	t4.Close()
+}
+
+// replayTransport -- sends every message twice.
+type replayTransport struct {
+	Transport
+}
+
+func (r *replayTransport) Send(ctx context.Context, data []byte) error {
+	if err := r.Transport.Send(ctx, data); err != nil {
+		return err
+	}
+	return r.Transport.Send(ctx, data)
+}
+
+// tamperTransport -- rewrites the outgoing envelopes.
+type tamperTransport struct {
+	Transport
+	fn func(env *Envelope)
+}
+
+func (r *tamperTransport) Send(ctx context.Context, data []byte) error {
+	env := &Envelope{}
+	if err := env.Decode(data); err != nil {
+		return err
+	}
+	r.fn(env)
+	return r.Transport.Send(ctx, env.Encode())
+}
+
+// recordTransport -- keeps the received envelopes.
+type recordTransport struct {
+	Transport
+	recv []*Envelope
+}
+
+func (r *recordTransport) Recv(ctx context.Context) ([]byte, error) {
+	data, err := r.Transport.Recv(ctx)
+	if err == nil {
+		env := &Envelope{}
+		if env.Decode(data) == nil {
+			r.recv = append(r.recv, env)
+		}
+	}
+	return data, err
+}
+
+// round -- returns the payload received in the round.
+func (r *recordTransport) round(round uint8) []byte {
+	for _, env := range r.recv {
+		if env.Round == round {
+			return env.Payload
+		}
+	}
+	return nil
+}
+
+func TestSessionNonceReuse(t *testing.T) {
+	hash := xcrypto.Sha256([]byte{0x01, 0x02, 0x03, 0x04})
+	prv1, _ := mockPrvKeys()
+	curve := prv1.Curve
+	N := curve.Params().N
+	ctx := context.Background()
+
+	// Mallory runs two sessions with alice on the same hash, with a
+	// different key share each time to change the challenge.
+	var ss, es []*big.Int
+	var rs [][]byte
+	for _, mallory := range []byte{0x07, 0x08} {
+		id, _ := NewSessionID()
+		t1, t2 := NewMemoryTransportPair()
+		go func() {
+			party, _ := xcrypto.NewSchnorrParty(prv1)
+			NewSession(id, "alice", "mallory", t1).SignSchnorr(ctx, party, hash)
+		}()
+
+		record := &recordTransport{Transport: t2}
+		party, _ := xcrypto.NewSchnorrParty(xcrypto.PrvKeyFromBytes([]byte{mallory}))
+		sig, pub, err := NewSession(id, "mallory", "alice", record).SignSchnorr(ctx, party, hash)
+		assert.Nil(t, err)
+		t2.Close()
+
+		msg := &xcrypto.MpcSchnorrPhase4Msg{}
+		assert.Nil(t, msg.Decode(record.round(RoundSignature)))
+		ss = append(ss, new(big.Int).SetBytes(msg.Sig))
+		es = append(es, schnorr.GetE(curve, hash, pub.X, pub.Y, sig[:32]))
+		rs = append(rs, record.round(RoundNonce))
+	}
+
+	// Alice draws a new nonce in every session.
+	assert.NotEqual(t, rs[0], rs[1])
+
+	// d1 = (s1-s1')/(e-e') or, with the nonce negated once, (s1+s1')/(e+e').
+	for _, sign := range []int64{1, -1} {
+		s := new(big.Int).Mul(ss[1], big.NewInt(sign))
+		s.Sub(ss[0], s).Mod(s, N)
+		e := new(big.Int).Mul(es[1], big.NewInt(sign))
+		e.Sub(es[0], e).Mod(e, N)
+		d := s.Mul(s, new(big.Int).ModInverse(e, N)).Mod(s, N)
+		assert.NotEqual(t, 0, d.Cmp(prv1.D))
+	}
+}
+
+func TestSessionAbort(t *testing.T) {
+	hash := xcrypto.Sha256([]byte{0x01, 0x02, 0x03, 0x04})
+	prv1, prv2 := mockPrvKeys()
+	id, _ := NewSessionID()
+	other, _ := NewSessionID()
+
+	tests := []struct {
+		name  string
+		wrap  func(Transport) Transport
+		round uint8
+	}{
+		{"replay", func(t Transport) Transport { return &replayTransport{t} }, RoundCommit},
+		{"session", func(t Transport) Transport {
+			return &tamperTransport{t, func(env *Envelope) { env.Session = other }}
+		}, RoundPubKey},
+		{"from", func(t Transport) Transport {
+			return &tamperTransport{t, func(env *Envelope) { env.From = "mallory" }}
+		}, RoundPubKey},
+		{"round", func(t Transport) Transport {
+			return &tamperTransport{t, func(env *Envelope) { env.Round = 9 }}
+		}, RoundPubKey},
+		{"payload", func(t Transport) Transport {
+			return &tamperTransport{t, func(env *Envelope) { env.Payload[len(env.Payload)-1] ^= 0x01 }}
+		}, RoundPubKey},
+		{"commitment", func(t Transport) Transport {
+			return &tamperTransport{t, func(env *Envelope) {
+				if env.Round == RoundNonce {
+					g := xcrypto.PrvKeyFromBytes([]byte{0x01}).PubKey()
+					env.Payload = xcrypto.NewMpcSchnorrPhase2Msg(secp256k1.NewScalar(g.X, g.Y)).Encode()
+				}
+			}}
+		}, RoundNonce},
+		{"signature", func(t Transport) Transport {
+			return &tamperTransport{t, func(env *Envelope) {
+				if env.Round == RoundSignature {
+					env.Payload = xcrypto.NewMpcSchnorrPhase4Msg(make([]byte, 31)).Encode()
+					env.Payload = append(env.Payload, 0x01)
+				}
+			}}
+		}, RoundFinal},
+	}
+
+	for _, test := range tests {
+		t1, t2 := NewMemoryTransportPair()
+		ctx := context.Background()
+		go func() {
+			party, _ := xcrypto.NewSchnorrParty(prv2)
+			NewSession(id, "bob", "alice", test.wrap(t2)).SignSchnorr(ctx, party, hash)
+		}()
+
+		party, _ := xcrypto.NewSchnorrParty(prv1)
+		session := NewSession(id, "alice", "bob", t1)
+		session.SetRoundTimeout(time.Second)
+		_, _, err := session.SignSchnorr(ctx, party, hash)
+		abort, ok := err.(*AbortError)
+		assert.True(t, ok, test.name)
+		if ok {
+			assert.Equal(t, "bob", abort.Party, test.name)
+			assert.Equal(t, test.round, abort.Round, test.name)
+		}
+		t1.Close()
+	}
+}
+
+func TestSessionTimeout(t *testing.T) {
+	hash := xcrypto.Sha256([]byte{0x01, 0x02, 0x03, 0x04})
+	prv1, _ := mockPrvKeys()
+	id, _ := NewSessionID()
+
+	// Peer never answers.
+	t1, t2 := NewMemoryTransportPair()
+	defer t2.Close()
+	party, _ := xcrypto.NewSchnorrParty(prv1)
+	session := NewSession(id, "alice", "bob", t1)
+	session.SetRoundTimeout(50 * time.Millisecond)
+	_, _, err := session.SignSchnorr(context.Background(), party, hash)
+	abort, ok := err.(*AbortError)
+	assert.True(t, ok)
+	assert.Equal(t, "bob", abort.Party)
+	assert.Equal(t, RoundPubKey, abort.Round)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
@@ -0,0 +1,84 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xmpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrTransportClosed -- returned by a closed transport.
+var ErrTransportClosed = errors.New("mpc.transport.closed")
+
+// Transport -- the point-to-point channel between two parties.
+// Send and Recv must honor the ctx deadline and cancellation.
+type Transport interface {
+	Send(ctx context.Context, data []byte) error
+	Recv(ctx context.Context) ([]byte, error)
+	Close() error
+}
+
+// MemoryTransport -- an in-process Transport backed by channels.
+type MemoryTransport struct {
+	in     <-chan []byte
+	out    chan<- []byte
+	done   chan struct{}
+	closer *sync.Once
+}
+
+// NewMemoryTransportPair -- creates two connected in-memory transports.
+// Closing either side closes both.
+func NewMemoryTransportPair() (*MemoryTransport, *MemoryTransport) {
+	a2b := make(chan []byte, 16)
+	b2a := make(chan []byte, 16)
+	done := make(chan struct{})
+	closer := &sync.Once{}
+
+	a := &MemoryTransport{in: b2a, out: a2b, done: done, closer: closer}
+	b := &MemoryTransport{in: a2b, out: b2a, done: done, closer: closer}
+	return a, b
+}
+
+// Send -- sends a copy of data to the peer.
+func (t *MemoryTransport) Send(ctx context.Context, data []byte) error {
+	clone := make([]byte, len(data))
+	copy(clone, data)
+
+	select {
+	case <-t.done:
+		return ErrTransportClosed
+	default:
+	}
+	select {
+	case t.out <- clone:
+		return nil
+	case <-t.done:
+		return ErrTransportClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Recv -- receives the next data from the peer.
+func (t *MemoryTransport) Recv(ctx context.Context) ([]byte, error) {
+	select {
+	case data := <-t.in:
+		return data, nil
+	case <-t.done:
+		return nil, ErrTransportClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close -- closes both sides of the pair.
+func (t *MemoryTransport) Close() error {
+	t.closer.Do(func() {
+		close(t.done)
+	})
+	return nil
+}
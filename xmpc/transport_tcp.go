@@ -0,0 +1,141 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xmpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// maxFrameSize -- the largest frame accepted from the peer.
+	maxFrameSize = 1 << 20
+)
+
+// TCPTransport -- a Transport over a stream connection.
+// Each frame is size(4 bytes, little-endian) || data.
+type TCPTransport struct {
+	conn   net.Conn
+	rmutex sync.Mutex
+	wmutex sync.Mutex
+	reader *bufio.Reader
+}
+
+// NewTCPTransport -- creates new TCPTransport on the connection.
+func NewTCPTransport(conn net.Conn) *TCPTransport {
+	return &TCPTransport{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+}
+
+// DialTCPTransport -- dials the address and returns the transport.
+func DialTCPTransport(ctx context.Context, address string) (*TCPTransport, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return NewTCPTransport(conn), nil
+}
+
+// Send -- writes one frame to the peer.
+func (t *TCPTransport) Send(ctx context.Context, data []byte) error {
+	if len(data) > maxFrameSize {
+		return fmt.Errorf("mpc.transport.frame.size[%v].too.large", len(data))
+	}
+
+	t.wmutex.Lock()
+	defer t.wmutex.Unlock()
+	stop, err := t.watch(ctx, t.conn.SetWriteDeadline)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	frame := make([]byte, 4+len(data))
+	binary.LittleEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	if _, err := t.conn.Write(frame); err != nil {
+		return t.ctxErr(ctx, err)
+	}
+	return nil
+}
+
+// Recv -- reads one frame from the peer.
+func (t *TCPTransport) Recv(ctx context.Context) ([]byte, error) {
+	t.rmutex.Lock()
+	defer t.rmutex.Unlock()
+	stop, err := t.watch(ctx, t.conn.SetReadDeadline)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	var size uint32
+	if err := binary.Read(t.reader, binary.LittleEndian, &size); err != nil {
+		return nil, t.ctxErr(ctx, err)
+	}
+	if size > maxFrameSize {
+		return nil, fmt.Errorf("mpc.transport.frame.size[%v].too.large", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(t.reader, data); err != nil {
+		return nil, t.ctxErr(ctx, err)
+	}
+	return data, nil
+}
+
+// Close -- closes the connection.
+func (t *TCPTransport) Close() error {
+	return t.conn.Close()
+}
+
+// watch -- applies the ctx deadline to the connection and interrupts the
+// blocked I/O when ctx is canceled. The returned func must be called when done.
+func (t *TCPTransport) watch(ctx context.Context, setDeadline func(time.Time) error) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	if err := setDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			_ = setDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}, nil
+}
+
+// ctxErr -- prefers the ctx error when the I/O was interrupted by it.
+func (t *TCPTransport) ctxErr(ctx context.Context, err error) error {
+	if cerr := ctx.Err(); cerr != nil {
+		return cerr
+	}
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		if _, ok := ctx.Deadline(); ok {
+			return context.DeadlineExceeded
+		}
+	}
+	return err
+}
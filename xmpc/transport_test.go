@@ -0,0 +1,88 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xmpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testTransport(t *testing.T, t1 Transport, t2 Transport) {
+	ctx := context.Background()
+
+	// Ping-pong.
+	assert.Nil(t, t1.Send(ctx, []byte("ping")))
+	data, err := t2.Recv(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("ping"), data)
+	assert.Nil(t, t2.Send(ctx, []byte("pong")))
+	data, err = t1.Recv(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("pong"), data)
+
+	// Empty frame.
+	assert.Nil(t, t1.Send(ctx, nil))
+	data, err = t2.Recv(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(data))
+
+	// Deadline.
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = t1.Recv(tctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// Cancel.
+	cctx, cancel1 := context.WithCancel(ctx)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel1()
+	}()
+	_, err = t1.Recv(cctx)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestMemoryTransport(t *testing.T) {
+	t1, t2 := NewMemoryTransportPair()
+	testTransport(t, t1, t2)
+
+	assert.Nil(t, t1.Close())
+	assert.Nil(t, t2.Close())
+	_, err := t2.Recv(context.Background())
+	assert.Equal(t, ErrTransportClosed, err)
+	assert.Equal(t, ErrTransportClosed, t1.Send(context.Background(), nil))
+}
+
+func TestTCPTransport(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	ch := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		assert.Nil(t, err)
+		ch <- conn
+	}()
+	t1, err := DialTCPTransport(context.Background(), listener.Addr().String())
+	assert.Nil(t, err)
+	t2 := NewTCPTransport(<-ch)
+	testTransport(t, t1, t2)
+
+	// Frame too large.
+	err = t1.Send(context.Background(), make([]byte, maxFrameSize+1))
+	assert.NotNil(t, err)
+
+	// Peer closed.
+	assert.Nil(t, t2.Close())
+	_, err = t1.Recv(context.Background())
+	assert.NotNil(t, err)
+	t1.Close()
+}
@@ -19,19 +19,28 @@ type SigHashFn func(hashType byte) ([]byte, error)
 // SigVerifyFn -- verify function for checksig.
 type SigVerifyFn func(pubkey []byte, hash []byte, signature []byte) error
 
+// LockTimeVerifyFn -- verify function for checklocktimeverify.
+type LockTimeVerifyFn func(lockTime int64) error
+
+// SequenceVerifyFn -- verify function for checksequenceverify.
+type SequenceVerifyFn func(sequence int64) error
+
 // Engine -- the virtual matchine to execute the bitcoin scripts.
 type Engine struct {
-	pc          uint64 // Program counter.
-	debug       bool
-	dstack      *Stack // Data stack.
-	cstack      []int  // Control stack.
-	sigHasher   SigHashFn
-	sigVerifier SigVerifyFn
-	reader      *ScriptReader
-	instruction *Instruction // Current instruction
-	traces      []Trace
-	lastStack   string // Last stack.
-	lastOp      string // Last opcode.
+	pc               uint64 // Program counter.
+	debug            bool
+	dstack           *Stack // Data stack.
+	cstack           []int  // Control stack.
+	sigHasher        SigHashFn
+	sigVerifier      SigVerifyFn
+	lockTimeVerifier LockTimeVerifyFn
+	sequenceVerifier SequenceVerifyFn
+	tapscript        bool
+	reader           *ScriptReader
+	instruction      *Instruction // Current instruction
+	traces           []Trace
+	lastStack        string // Last stack.
+	lastOp           string // Last opcode.
 }
 
 // NewEngine -- creates new Engine.
@@ -61,6 +70,21 @@ func (vm *Engine) SetSigVerifyFn(fn SigVerifyFn) {
 	vm.sigVerifier = fn
 }
 
+// SetLockTimeVerifyFn -- set the locktime verify function of OP_CHECKLOCKTIMEVERIFY.
+func (vm *Engine) SetLockTimeVerifyFn(fn LockTimeVerifyFn) {
+	vm.lockTimeVerifier = fn
+}
+
+// SetSequenceVerifyFn -- set the sequence verify function of OP_CHECKSEQUENCEVERIFY.
+func (vm *Engine) SetSequenceVerifyFn(fn SequenceVerifyFn) {
+	vm.sequenceVerifier = fn
+}
+
+// EnableTapscript -- executes the BIP342 tapscript, the 64-byte signatures have the default hash type.
+func (vm *Engine) EnableTapscript() {
+	vm.tapscript = true
+}
+
 // Step --
 // will execute the next instruction and move the program counter to the
 // next opcode in the script, or the next script if the current has ended.
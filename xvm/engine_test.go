@@ -7,6 +7,7 @@ package xvm
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"testing"
 
@@ -110,3 +111,65 @@ func TestEngineExecute(t *testing.T) {
 	err = engine.Execute(script)
 	assert.Nil(t, err)
 }
+
+func TestEngineSha256(t *testing.T) {
+	engine := NewEngine()
+	script, err := NewScriptBuilder().
+		AddData([]byte("tokucore")).
+		AddOp(OP_SHA256).
+		AddData(xcrypto.Sha256([]byte("tokucore"))).
+		AddOp(OP_EQUAL).
+		Script()
+	assert.Nil(t, err)
+	assert.Nil(t, engine.Execute(script))
+}
+
+func TestEngineLockTime(t *testing.T) {
+	lockTimeScript := func(n int64, op byte) []byte {
+		script, err := NewScriptBuilder().AddInt64(n).AddOp(op).AddOp(OP_DROP).AddOp(OP_1).Script()
+		assert.Nil(t, err)
+		return script
+	}
+
+	// CLTV.
+	{
+		engine := NewEngine()
+		err := engine.Execute(lockTimeScript(100, OP_CHECKLOCKTIMEVERIFY))
+		assert.EqualError(t, err, "vm.execute.opcode[opCheckLockTimeVerify:vm.locktime.verifier.func.is.nil].failed (errno 1300) (state TVM00)")
+
+		var got int64
+		engine = NewEngine()
+		engine.SetLockTimeVerifyFn(func(lockTime int64) error {
+			got = lockTime
+			if lockTime > 500 {
+				return fmt.Errorf("locktime.unsatisfied")
+			}
+			return nil
+		})
+		assert.Nil(t, engine.Execute(lockTimeScript(0xffffffff>>24, OP_CHECKLOCKTIMEVERIFY)))
+		assert.Equal(t, int64(0xff), got)
+		err = engine.Execute(lockTimeScript(0xffffffff, OP_CHECKLOCKTIMEVERIFY))
+		assert.EqualError(t, err, "vm.execute.opcode[opCheckLockTimeVerify:locktime.unsatisfied].failed (errno 1300) (state TVM00)")
+		assert.Equal(t, int64(0xffffffff), got)
+		err = engine.Execute(lockTimeScript(-2, OP_CHECKLOCKTIMEVERIFY))
+		assert.EqualError(t, err, "vm.execute.opcode[opCheckLockTimeVerify:negative.locktime[-2]].failed (errno 1300) (state TVM00)")
+	}
+
+	// CSV.
+	{
+		var got int64
+		engine := NewEngine()
+		engine.SetSequenceVerifyFn(func(sequence int64) error {
+			got = sequence
+			return fmt.Errorf("sequence.unsatisfied")
+		})
+		err := engine.Execute(lockTimeScript(144, OP_CHECKSEQUENCEVERIFY))
+		assert.EqualError(t, err, "vm.execute.opcode[opCheckSequenceVerify:sequence.unsatisfied].failed (errno 1300) (state TVM00)")
+		assert.Equal(t, int64(144), got)
+
+		// The disable flag is a NOP.
+		got = 0
+		assert.Nil(t, engine.Execute(lockTimeScript(1<<31, OP_CHECKSEQUENCEVERIFY)))
+		assert.Equal(t, int64(0), got)
+	}
+}
@@ -211,7 +211,12 @@ var opcodes = map[byte]opcode{
 	// Numeric opcodes.
 	OP_ADD: {OP_ADD, "OP_ADD", 1, opAdd},
 
+	// Locktime opcodes.
+	OP_CHECKLOCKTIMEVERIFY: {OP_CHECKLOCKTIMEVERIFY, "OP_CHECKLOCKTIMEVERIFY", 1, opCheckLockTimeVerify},
+	OP_CHECKSEQUENCEVERIFY: {OP_CHECKSEQUENCEVERIFY, "OP_CHECKSEQUENCEVERIFY", 1, opCheckSequenceVerify},
+
 	// Crypto opcodes.
+	OP_SHA256:              {OP_SHA256, "OP_SHA256", 1, opSha256},
 	OP_HASH160:             {OP_HASH160, "OP_HASH160", 1, opHash160},
 	OP_CHECKSIG:            {OP_CHECKSIG, "OP_CHECKSIG", 1, opCheckSig},
 	OP_CHECKSIGVERIFY:      {OP_CHECKSIGVERIFY, "OP_CHECKSIGVERIFY", 1, opCheckSigVerify},
@@ -12,6 +12,17 @@ import (
 	"github.com/keyfuse/tokucore/xerror"
 )
 
+// Stack:
+// [... x] -> [... sha256(x)]
+func opSha256(vm *Engine) error {
+	x, err := vm.dstack.PopByteArray()
+	if err != nil {
+		return err
+	}
+	vm.dstack.PushByteArray(xcrypto.Sha256(x))
+	return nil
+}
+
 // Stack:
 // [... signature pubkey] -> [... bool]
 func opHash160(vm *Engine) error {
@@ -48,6 +59,21 @@ func opCheckSig(vm *Engine) error {
 
 	hashType := sig[len(sig)-1]
 	sigDER := sig[:len(sig)-1]
+	// The 64-byte BIP340 signature of the tapscript has no hash type byte,
+	// the 65-byte one must not carry SIGHASH_DEFAULT explicitly.
+	if vm.tapscript {
+		switch len(sig) {
+		case 64:
+			hashType = 0
+			sigDER = sig
+		case 65:
+			if hashType == 0 {
+				return xerror.NewError(Errors, ER_VM_EXEC_OPCODE_FAILED, "opCheckSig:tapscript.sighash.type[0].explicit")
+			}
+		default:
+			return xerror.NewError(Errors, ER_VM_EXEC_OPCODE_FAILED, fmt.Sprintf("opCheckSig:tapscript.signature.size[%v].invalid", len(sig)))
+		}
+	}
 	hash, err := vm.sigHasher(hashType)
 	if err != nil {
 		return err
@@ -0,0 +1,77 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xvm
+
+import (
+	"fmt"
+
+	"github.com/keyfuse/tokucore/xerror"
+)
+
+const (
+	// lockTimeScriptNumLen -- the locktime and the sequence are 5-byte script numbers to reach 2^32-1.
+	lockTimeScriptNumLen = 5
+
+	// sequenceLockTimeDisabled -- the BIP68 disable flag, the OP_CHECKSEQUENCEVERIFY is a NOP with it.
+	sequenceLockTimeDisabled = 1 << 31
+)
+
+// opCheckLockTimeVerify -- BIP65, fails if the top item is larger than the transaction locktime.
+// The item is left on the stack.
+//
+// Stack:
+// [... locktime] -> [... locktime]
+func opCheckLockTimeVerify(vm *Engine) error {
+	lockTime, err := peekLockTime(vm, "opCheckLockTimeVerify")
+	if err != nil {
+		return err
+	}
+	if vm.lockTimeVerifier == nil {
+		return xerror.NewError(Errors, ER_VM_EXEC_OPCODE_FAILED, "opCheckLockTimeVerify:vm.locktime.verifier.func.is.nil")
+	}
+	if err := vm.lockTimeVerifier(lockTime); err != nil {
+		return xerror.NewError(Errors, ER_VM_EXEC_OPCODE_FAILED, fmt.Sprintf("opCheckLockTimeVerify:%v", err))
+	}
+	return nil
+}
+
+// opCheckSequenceVerify -- BIP112, fails if the top item is larger than the BIP68 relative locktime of the input.
+// The item is left on the stack.
+//
+// Stack:
+// [... sequence] -> [... sequence]
+func opCheckSequenceVerify(vm *Engine) error {
+	sequence, err := peekLockTime(vm, "opCheckSequenceVerify")
+	if err != nil {
+		return err
+	}
+	if sequence&sequenceLockTimeDisabled != 0 {
+		return nil
+	}
+	if vm.sequenceVerifier == nil {
+		return xerror.NewError(Errors, ER_VM_EXEC_OPCODE_FAILED, "opCheckSequenceVerify:vm.sequence.verifier.func.is.nil")
+	}
+	if err := vm.sequenceVerifier(sequence); err != nil {
+		return xerror.NewError(Errors, ER_VM_EXEC_OPCODE_FAILED, fmt.Sprintf("opCheckSequenceVerify:%v", err))
+	}
+	return nil
+}
+
+// peekLockTime -- returns the non-negative 5-byte script number on the top of the stack.
+func peekLockTime(vm *Engine, name string) (int64, error) {
+	so, err := vm.dstack.PeekByteArray(0)
+	if err != nil {
+		return 0, err
+	}
+	n, err := MakeScriptNum(so, lockTimeScriptNumLen)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, xerror.NewError(Errors, ER_VM_EXEC_OPCODE_FAILED, fmt.Sprintf("%v:negative.locktime[%v]", name, n))
+	}
+	return int64(n), nil
+}
@@ -0,0 +1,197 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xwallet
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xcore"
+	"github.com/keyfuse/tokucore/xcore/bip32"
+	"github.com/keyfuse/tokucore/xerror"
+)
+
+// https://github.com/bitcoin/bips/blob/master/bip-0380.mediawiki
+const (
+	descriptorInputCharset    = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	descriptorChecksumSize    = 8
+
+	// descriptorMultipath -- the BIP389 receive and change chains suffix.
+	descriptorMultipath = "/<0;1>/*"
+)
+
+var descriptorGenerator = [5]uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd}
+
+// descriptorScripts -- the script wrappers of the single key accounts.
+var descriptorScripts = []struct {
+	prefix  string
+	suffix  string
+	purpose uint32
+}{
+	{"pkh(", ")", xcore.PurposeP2PKH},
+	{"sh(wpkh(", "))", xcore.PurposeP2WPKHInP2SH},
+	{"wpkh(", ")", xcore.PurposeP2WPKH},
+	{"tr(", ")", xcore.PurposeP2TR},
+}
+
+// Descriptor -- the output descriptor of a single key account with both chains:
+// pkh(KEY), sh(wpkh(KEY)), wpkh(KEY) or tr(KEY),
+// KEY is the account key [fingerprint/path]xpub with the /<0;1>/* suffix.
+type Descriptor struct {
+	Purpose uint32
+	Key     *bip32.HDKey
+}
+
+// ParseDescriptor -- parses the descriptor, the checksum is verified if present.
+// A bare key without the /<0;1>/* suffix is rejected, it names a single script, not the account chains.
+// A private account key is converted to its public key.
+func ParseDescriptor(desc string, net *network.Network) (*Descriptor, error) {
+	if i := strings.IndexByte(desc, '#'); i >= 0 {
+		want, err := DescriptorChecksum(desc[:i])
+		if err != nil {
+			return nil, err
+		}
+		if desc[i+1:] != want {
+			return nil, xerror.NewError(Errors, ER_WALLET_DESCRIPTOR_CHECKSUM_MISMATCH, desc[i+1:], want)
+		}
+		desc = desc[:i]
+	}
+
+	var purpose uint32
+	var keyExpr string
+	for _, script := range descriptorScripts {
+		if strings.HasPrefix(desc, script.prefix) && strings.HasSuffix(desc, script.suffix) && len(desc) > len(script.prefix)+len(script.suffix) {
+			purpose = script.purpose
+			keyExpr = desc[len(script.prefix) : len(desc)-len(script.suffix)]
+			break
+		}
+	}
+	if keyExpr == "" {
+		return nil, xerror.NewError(Errors, ER_WALLET_DESCRIPTOR_SCRIPT_UNSUPPORTED, desc)
+	}
+
+	// Key origin.
+	var masterFP []byte
+	var origin bip32.Path
+	if strings.HasPrefix(keyExpr, "[") {
+		end := strings.IndexByte(keyExpr, ']')
+		if end < 0 {
+			return nil, xerror.NewError(Errors, ER_WALLET_DESCRIPTOR_INVALID, desc)
+		}
+		parts := strings.SplitN(keyExpr[1:end], "/", 2)
+		fp, err := hex.DecodeString(parts[0])
+		if err != nil || len(fp) != 4 {
+			return nil, xerror.NewError(Errors, ER_WALLET_DESCRIPTOR_INVALID, desc)
+		}
+		masterFP = fp
+		origin = bip32.Path{}
+		if len(parts) == 2 {
+			path, absolute, err := bip32.ParsePath(parts[1])
+			if err != nil || absolute {
+				return nil, xerror.NewError(Errors, ER_WALLET_DESCRIPTOR_INVALID, desc)
+			}
+			origin = path
+		}
+		keyExpr = keyExpr[end+1:]
+	}
+
+	// Key and the chains.
+	i := strings.IndexByte(keyExpr, '/')
+	if i < 0 {
+		return nil, xerror.NewError(Errors, ER_WALLET_DESCRIPTOR_MULTIPATH_MISSING, keyExpr)
+	}
+	if keyExpr[i:] != descriptorMultipath {
+		return nil, xerror.NewError(Errors, ER_WALLET_DESCRIPTOR_PATH_UNSUPPORTED, keyExpr[i:])
+	}
+	keyStr := keyExpr[:i]
+	key, keyType, err := bip32.NewHDKeyFromStringWithNetwork(keyStr, net)
+	if err != nil {
+		return nil, err
+	}
+	if keyType != bip32.KeyTypeP2PKH {
+		return nil, xerror.NewError(Errors, ER_WALLET_DESCRIPTOR_KEY_UNSUPPORTED, keyType)
+	}
+	key = key.HDPublicKey()
+	if masterFP != nil {
+		if err := key.SetOrigin(masterFP, origin); err != nil {
+			return nil, err
+		}
+	}
+	return &Descriptor{Purpose: purpose, Key: key}, nil
+}
+
+// ToString -- the descriptor with the key origin if known, the multipath suffix and the checksum.
+func (d *Descriptor) ToString(net *network.Network) string {
+	var b strings.Builder
+	var suffix string
+	for _, script := range descriptorScripts {
+		if script.purpose == d.Purpose {
+			b.WriteString(script.prefix)
+			suffix = script.suffix
+		}
+	}
+	if path := d.Key.Path(); path != nil {
+		b.WriteString("[")
+		b.WriteString(hex.EncodeToString(d.Key.MasterFingerprint()))
+		b.WriteString(strings.TrimPrefix(path.String(), "m"))
+		b.WriteString("]")
+	}
+	b.WriteString(d.Key.HDPublicKey().ToString(net))
+	b.WriteString(descriptorMultipath)
+	b.WriteString(suffix)
+
+	desc := b.String()
+	checksum, _ := DescriptorChecksum(desc)
+	return desc + "#" + checksum
+}
+
+// DescriptorChecksum -- returns the 8 characters BIP380 checksum of the descriptor.
+func DescriptorChecksum(desc string) (string, error) {
+	var symbols []uint64
+	var groups []uint64
+	for _, c := range desc {
+		v := strings.IndexRune(descriptorInputCharset, c)
+		if v < 0 {
+			return "", xerror.NewError(Errors, ER_WALLET_DESCRIPTOR_CHARACTER_INVALID, c)
+		}
+		symbols = append(symbols, uint64(v&31))
+		groups = append(groups, uint64(v>>5))
+		if len(groups) == 3 {
+			symbols = append(symbols, groups[0]*9+groups[1]*3+groups[2])
+			groups = groups[:0]
+		}
+	}
+	switch len(groups) {
+	case 1:
+		symbols = append(symbols, groups[0])
+	case 2:
+		symbols = append(symbols, groups[0]*3+groups[1])
+	}
+	symbols = append(symbols, make([]uint64, descriptorChecksumSize)...)
+
+	chk := descriptorPolymod(symbols) ^ 1
+	out := make([]byte, descriptorChecksumSize)
+	for i := range out {
+		out[i] = descriptorChecksumCharset[(chk>>(5*(7-uint(i))))&31]
+	}
+	return string(out), nil
+}
+
+func descriptorPolymod(symbols []uint64) uint64 {
+	chk := uint64(1)
+	for _, v := range symbols {
+		top := chk >> 35
+		chk = (chk&0x7ffffffff)<<5 ^ v
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= descriptorGenerator[i]
+			}
+		}
+	}
+	return chk
+}
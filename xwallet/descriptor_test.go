@@ -0,0 +1,107 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xwallet
+
+import (
+	"testing"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xcore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescriptorChecksum(t *testing.T) {
+	tests := []struct {
+		desc     string
+		checksum string
+	}{
+		{desc: "raw(deadbeef)", checksum: "89f8spxm"},
+		{desc: "addr(mkmZxiEcEd8ZqjQWVZuC6so5dFMKEFpN2j)", checksum: "02wpgw69"},
+		{desc: "wpkh([73c5da0a/84'/0'/0']xpub6CatWdiZiodmUeTDp8LT5or8nmbKNcuyvz7WyksVFkKB4RHwCD3XyuvPEbvqAQY3rAPshWcMLoP2fMFMKHPJ4ZeZXYVUhLv1VMrjPC7PW6V/<0;1>/*)", checksum: "hpg6d6w2"},
+	}
+	for _, test := range tests {
+		checksum, err := DescriptorChecksum(test.desc)
+		assert.Nil(t, err)
+		assert.Equal(t, test.checksum, checksum)
+	}
+
+	_, err := DescriptorChecksum("raw(deadé)")
+	assert.NotNil(t, err)
+}
+
+func TestDescriptor(t *testing.T) {
+	net := network.MainNet
+	xpub := "xpub6CatWdiZiodmUeTDp8LT5or8nmbKNcuyvz7WyksVFkKB4RHwCD3XyuvPEbvqAQY3rAPshWcMLoP2fMFMKHPJ4ZeZXYVUhLv1VMrjPC7PW6V"
+
+	tests := []struct {
+		desc    string
+		purpose uint32
+	}{
+		{desc: "pkh(" + xpub + "/<0;1>/*)", purpose: xcore.PurposeP2PKH},
+		{desc: "sh(wpkh(" + xpub + "/<0;1>/*))", purpose: xcore.PurposeP2WPKHInP2SH},
+		{desc: "wpkh([73c5da0a/84h/0h/0h]" + xpub + "/<0;1>/*)", purpose: xcore.PurposeP2WPKH},
+		{desc: "tr(" + xpub + "/<0;1>/*)", purpose: xcore.PurposeP2TR},
+	}
+	for _, test := range tests {
+		d, err := ParseDescriptor(test.desc, net)
+		assert.Nil(t, err)
+		assert.Equal(t, test.purpose, d.Purpose)
+		assert.Equal(t, xpub, d.Key.ToString(net))
+
+		again, err := ParseDescriptor(d.ToString(net), net)
+		assert.Nil(t, err)
+		assert.Equal(t, d.ToString(net), again.ToString(net))
+	}
+
+	d, err := ParseDescriptor("wpkh([73c5da0a/84h/0h/0h]"+xpub+"/<0;1>/*)", net)
+	assert.Nil(t, err)
+	assert.Equal(t, "m/84'/0'/0'", d.Key.Path().String())
+}
+
+func TestDescriptorError(t *testing.T) {
+	net := network.MainNet
+	xpub := "xpub6CatWdiZiodmUeTDp8LT5or8nmbKNcuyvz7WyksVFkKB4RHwCD3XyuvPEbvqAQY3rAPshWcMLoP2fMFMKHPJ4ZeZXYVUhLv1VMrjPC7PW6V"
+	zpub := "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+
+	tests := []struct {
+		desc string
+		err  string
+	}{
+		{
+			desc: "wpkh(" + xpub + ")#hpg6d6w2",
+			err:  "wallet.descriptor.checksum[hpg6d6w2].mismatch.want[",
+		},
+		{
+			desc: "wsh(" + xpub + ")",
+			err:  "wallet.descriptor.script[wsh(" + xpub + ")].unsupported (errno 7104) (state TWL00)",
+		},
+		{
+			desc: "wpkh(" + xpub + "/0/*)",
+			err:  "wallet.descriptor.key.path[/0/*].unsupported (errno 7106) (state TWL00)",
+		},
+		{
+			desc: "wpkh(" + xpub + ")",
+			err:  "wallet.descriptor.key[" + xpub + "].multipath.missing (errno 7107) (state TWL00)",
+		},
+		{
+			desc: "tr([73c5da0a/86h/0h/0h]" + xpub + ")",
+			err:  "wallet.descriptor.key[" + xpub + "].multipath.missing (errno 7107) (state TWL00)",
+		},
+		{
+			desc: "wpkh([73c5da/84h]" + xpub + ")",
+			err:  "wallet.descriptor.invalid[wpkh([73c5da/84h]" + xpub + ")] (errno 7101) (state TWL00)",
+		},
+		{
+			desc: "wpkh(" + zpub + "/<0;1>/*)",
+			err:  "wallet.descriptor.key.type[p2wpkh].unsupported (errno 7105) (state TWL00)",
+		},
+	}
+	for _, test := range tests {
+		_, err := ParseDescriptor(test.desc, net)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), test.err)
+	}
+}
@@ -0,0 +1,42 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xwallet
+
+import (
+	"github.com/keyfuse/tokucore/xerror"
+)
+
+// Error type.
+const (
+	ER_WALLET_DESCRIPTOR_INVALID            int = 7101
+	ER_WALLET_DESCRIPTOR_CHARACTER_INVALID  int = 7102
+	ER_WALLET_DESCRIPTOR_CHECKSUM_MISMATCH  int = 7103
+	ER_WALLET_DESCRIPTOR_SCRIPT_UNSUPPORTED int = 7104
+	ER_WALLET_DESCRIPTOR_KEY_UNSUPPORTED    int = 7105
+	ER_WALLET_DESCRIPTOR_PATH_UNSUPPORTED   int = 7106
+	ER_WALLET_DESCRIPTOR_MULTIPATH_MISSING  int = 7107
+	ER_WALLET_GAP_LIMIT_INVALID             int = 7201
+	ER_WALLET_TRANSACTION_MALFORMED         int = 7202
+	ER_WALLET_SNAPSHOT_MALFORMED            int = 7301
+	ER_WALLET_SNAPSHOT_VERSION_UNSUPPORTED  int = 7302
+	ER_WALLET_SNAPSHOT_USED_INDEX_INVALID   int = 7303
+)
+
+// Errors -- the jump table of error.
+var Errors = map[int]*xerror.Error{
+	ER_WALLET_DESCRIPTOR_INVALID:            {Num: ER_WALLET_DESCRIPTOR_INVALID, State: "TWL00", Message: "wallet.descriptor.invalid[%v]"},
+	ER_WALLET_DESCRIPTOR_CHARACTER_INVALID:  {Num: ER_WALLET_DESCRIPTOR_CHARACTER_INVALID, State: "TWL00", Message: "wallet.descriptor.character[%q].invalid"},
+	ER_WALLET_DESCRIPTOR_CHECKSUM_MISMATCH:  {Num: ER_WALLET_DESCRIPTOR_CHECKSUM_MISMATCH, State: "TWL00", Message: "wallet.descriptor.checksum[%v].mismatch.want[%v]"},
+	ER_WALLET_DESCRIPTOR_SCRIPT_UNSUPPORTED: {Num: ER_WALLET_DESCRIPTOR_SCRIPT_UNSUPPORTED, State: "TWL00", Message: "wallet.descriptor.script[%v].unsupported"},
+	ER_WALLET_DESCRIPTOR_KEY_UNSUPPORTED:    {Num: ER_WALLET_DESCRIPTOR_KEY_UNSUPPORTED, State: "TWL00", Message: "wallet.descriptor.key.type[%v].unsupported"},
+	ER_WALLET_DESCRIPTOR_PATH_UNSUPPORTED:   {Num: ER_WALLET_DESCRIPTOR_PATH_UNSUPPORTED, State: "TWL00", Message: "wallet.descriptor.key.path[%v].unsupported"},
+	ER_WALLET_DESCRIPTOR_MULTIPATH_MISSING:  {Num: ER_WALLET_DESCRIPTOR_MULTIPATH_MISSING, State: "TWL00", Message: "wallet.descriptor.key[%v].multipath.missing"},
+	ER_WALLET_GAP_LIMIT_INVALID:             {Num: ER_WALLET_GAP_LIMIT_INVALID, State: "TWL01", Message: "wallet.gap.limit[%v].invalid"},
+	ER_WALLET_TRANSACTION_MALFORMED:         {Num: ER_WALLET_TRANSACTION_MALFORMED, State: "TWL01", Message: "wallet.transaction.malformed[%v]"},
+	ER_WALLET_SNAPSHOT_MALFORMED:            {Num: ER_WALLET_SNAPSHOT_MALFORMED, State: "TWL02", Message: "wallet.snapshot.malformed[%v]"},
+	ER_WALLET_SNAPSHOT_VERSION_UNSUPPORTED:  {Num: ER_WALLET_SNAPSHOT_VERSION_UNSUPPORTED, State: "TWL02", Message: "wallet.snapshot.version[%v].unsupported"},
+	ER_WALLET_SNAPSHOT_USED_INDEX_INVALID:   {Num: ER_WALLET_SNAPSHOT_USED_INDEX_INVALID, State: "TWL02", Message: "wallet.snapshot.chain[%v].used.index[%v].invalid"},
+}
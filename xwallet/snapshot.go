@@ -0,0 +1,122 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xwallet
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xcore/bip32"
+	"github.com/keyfuse/tokucore/xerror"
+)
+
+const (
+	// snapshotVersion -- the version of the persisted wallet.
+	snapshotVersion = 1
+)
+
+// snapshot -- the persisted wallet, the addresses are derived again from the descriptor.
+type snapshot struct {
+	Version    int                `json:"version"`
+	Descriptor string             `json:"descriptor"`
+	GapLimit   uint32             `json:"gap_limit"`
+	Used       [2][]uint32        `json:"used"`
+	UTXOs      []*UTXO            `json:"utxos"`
+	Txs        map[string]int64   `json:"txs"`
+	Spends     map[string][]*UTXO `json:"spends,omitempty"`
+}
+
+// Save -- serializes the wallet state to JSON.
+func (w *Wallet) Save() ([]byte, error) {
+	utxos := w.UTXOs()
+	desc := w.Descriptor()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	snap := &snapshot{
+		Version:    snapshotVersion,
+		Descriptor: desc,
+		GapLimit:   w.gapLimit,
+		UTXOs:      utxos,
+		Txs:        w.txs,
+		Spends:     w.spends,
+	}
+	for i, c := range w.chains {
+		snap.Used[i] = []uint32{}
+		for _, a := range c.addresses {
+			if a.Used {
+				snap.Used[i] = append(snap.Used[i], a.Index)
+			}
+		}
+	}
+	return json.Marshal(snap)
+}
+
+// LoadWallet -- reloads the wallet saved by Save.
+func LoadWallet(data []byte, net *network.Network) (*Wallet, error) {
+	snap := &snapshot{}
+	if err := json.Unmarshal(data, snap); err != nil {
+		return nil, xerror.NewError(Errors, ER_WALLET_SNAPSHOT_MALFORMED, err)
+	}
+	if snap.Version != snapshotVersion {
+		return nil, xerror.NewError(Errors, ER_WALLET_SNAPSHOT_VERSION_UNSUPPORTED, snap.Version)
+	}
+
+	w, err := NewWalletFromDescriptor(snap.Descriptor, net, snap.GapLimit)
+	if err != nil {
+		return nil, err
+	}
+	for chain, used := range snap.Used {
+		if err := checkUsed(uint32(chain), used, snap.GapLimit); err != nil {
+			return nil, err
+		}
+	}
+	for chain, used := range snap.Used {
+		// Mark the highest index first so the chain is derived in one pass.
+		for i := len(used) - 1; i >= 0; i-- {
+			c := w.chains[chain]
+			if int(used[i]) >= len(c.addresses) {
+				c.lastUsed = int(used[i])
+				if err := w.extend(uint32(chain)); err != nil {
+					return nil, err
+				}
+			}
+			if err := w.markUsed(c.addresses[used[i]]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, utxo := range snap.UTXOs {
+		w.utxos[outpointKey(utxo.TxID, utxo.Vout)] = utxo
+	}
+	for txID, height := range snap.Txs {
+		w.txs[txID] = height
+	}
+	for txID, spent := range snap.Spends {
+		w.spends[txID] = spent
+	}
+	return w, nil
+}
+
+// checkUsed -- checks the used indexes are below the hardened ones and each
+// is within the gap limit of the previous one, as the wallet derives them.
+// A corrupt index would otherwise derive every address up to it.
+func checkUsed(chain uint32, used []uint32, gapLimit uint32) error {
+	sorted := make([]uint32, len(used))
+	copy(sorted, used)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	last := int64(-1)
+	for _, index := range sorted {
+		if index >= bip32.HardenedKeyStart || int64(index) > last+int64(gapLimit) {
+			return xerror.NewError(Errors, ER_WALLET_SNAPSHOT_USED_INDEX_INVALID, chain, index)
+		}
+		last = int64(index)
+	}
+	return nil
+}
@@ -0,0 +1,442 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xwallet
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/keyfuse/tokucore/xcore"
+	"github.com/keyfuse/tokucore/xcore/bip32"
+	"github.com/keyfuse/tokucore/xerror"
+	"github.com/keyfuse/tokucore/xprotocol"
+	"github.com/keyfuse/tokucore/xrpc"
+)
+
+const (
+	// DefaultGapLimit -- the BIP44 address gap limit.
+	DefaultGapLimit = 20
+)
+
+// Address -- a derived address of the wallet.
+type Address struct {
+	Address string
+	Chain   uint32
+	Index   uint32
+	Script  []byte
+	Used    bool
+}
+
+// UTXO -- an unspent output paying to the wallet, Height is 0 while unconfirmed.
+type UTXO struct {
+	TxID    string `json:"txid"`
+	Vout    uint32 `json:"vout"`
+	Value   uint64 `json:"value"`
+	Script  string `json:"script"`
+	Address string `json:"address"`
+	Chain   uint32 `json:"chain"`
+	Index   uint32 `json:"index"`
+	Height  int64  `json:"height"`
+}
+
+// ToCoin -- the coin of the output for the transaction builder.
+func (u *UTXO) ToCoin() *xcore.Coin {
+	return xcore.NewCoinBuilder().AddOutput(u.TxID, u.Vout, u.Value, u.Script).ToCoins()[0]
+}
+
+// Activity -- the wallet outputs received and spent by a transaction.
+type Activity struct {
+	TxID     string
+	Height   int64
+	Received []*UTXO
+	Spent    []*UTXO
+}
+
+// chain -- the addresses of the receive or change chain.
+type chain struct {
+	addresses []*Address
+	lastUsed  int
+}
+
+// Wallet -- a watch-only wallet of a single key account.
+// The wallet keeps gapLimit unused addresses after the last used one on each chain,
+// transactions must be fed in the chain order so the spends find their outputs.
+// The outputs spent by every transaction are kept, so a transaction that never
+// confirms can be rolled back by RemoveTransaction.
+type Wallet struct {
+	mu       sync.Mutex
+	net      *network.Network
+	account  *xcore.Account
+	gapLimit uint32
+	chains   [2]*chain
+	scripts  map[string]*Address
+	utxos    map[string]*UTXO
+	txs      map[string]int64
+	spends   map[string][]*UTXO
+}
+
+// NewWallet -- creates the watch-only wallet from the account extended key,
+// a private key is converted to its public key.
+func NewWallet(key *bip32.HDKey, purpose uint32, net *network.Network, gapLimit uint32) (*Wallet, error) {
+	if gapLimit == 0 {
+		return nil, xerror.NewError(Errors, ER_WALLET_GAP_LIMIT_INVALID, gapLimit)
+	}
+	account, err := xcore.NewAccountFromHDKey(key.HDPublicKey(), purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Wallet{
+		net:      net,
+		account:  account,
+		gapLimit: gapLimit,
+		scripts:  make(map[string]*Address),
+		utxos:    make(map[string]*UTXO),
+		txs:      make(map[string]int64),
+		spends:   make(map[string][]*UTXO),
+	}
+	for i := range w.chains {
+		w.chains[i] = &chain{lastUsed: -1}
+		if err := w.extend(uint32(i)); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// NewWalletFromDescriptor -- creates the watch-only wallet from the output descriptor.
+func NewWalletFromDescriptor(desc string, net *network.Network, gapLimit uint32) (*Wallet, error) {
+	d, err := ParseDescriptor(desc, net)
+	if err != nil {
+		return nil, err
+	}
+	return NewWallet(d.Key, d.Purpose, net, gapLimit)
+}
+
+// Descriptor -- the output descriptor of the wallet.
+func (w *Wallet) Descriptor() string {
+	d := &Descriptor{Purpose: w.account.Purpose(), Key: w.account.HDKey()}
+	return d.ToString(w.net)
+}
+
+// GapLimit -- the gap limit of the wallet.
+func (w *Wallet) GapLimit() uint32 {
+	return w.gapLimit
+}
+
+// NextReceiveAddress -- the receive address after the last used one.
+func (w *Wallet) NextReceiveAddress() *Address {
+	return w.nextAddress(xcore.ChainReceive)
+}
+
+// NextChangeAddress -- the change address after the last used one.
+func (w *Wallet) NextChangeAddress() *Address {
+	return w.nextAddress(xcore.ChainChange)
+}
+
+// Addresses -- the derived addresses of the chain, the last gapLimit of them are unused.
+// It returns nil for a chain other than the receive and change ones.
+func (w *Wallet) Addresses(chain uint32) []*Address {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if chain >= uint32(len(w.chains)) {
+		return nil
+	}
+	c := w.chains[chain]
+	addrs := make([]*Address, len(c.addresses))
+	copy(addrs, c.addresses)
+	return addrs
+}
+
+func (w *Wallet) nextAddress(chain uint32) *Address {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if chain >= uint32(len(w.chains)) {
+		return nil
+	}
+	c := w.chains[chain]
+	return c.addresses[c.lastUsed+1]
+}
+
+// extend -- derives the addresses until gapLimit unused ones follow the last used one.
+func (w *Wallet) extend(chain uint32) error {
+	c := w.chains[chain]
+	for len(c.addresses) < c.lastUsed+1+int(w.gapLimit) {
+		index := uint32(len(c.addresses))
+		addr, err := w.account.Address(chain, index)
+		if err != nil {
+			return err
+		}
+		script, err := addr.LockingScript()
+		if err != nil {
+			return err
+		}
+		a := &Address{
+			Address: addr.ToString(w.net),
+			Chain:   chain,
+			Index:   index,
+			Script:  script,
+		}
+		c.addresses = append(c.addresses, a)
+		w.scripts[hex.EncodeToString(script)] = a
+	}
+	return nil
+}
+
+// markUsed -- marks the address used and keeps the gap after it.
+func (w *Wallet) markUsed(a *Address) error {
+	a.Used = true
+	c := w.chains[a.Chain]
+	if int(a.Index) > c.lastUsed {
+		c.lastUsed = int(a.Index)
+	}
+	return w.extend(a.Chain)
+}
+
+// AddTransaction -- applies the transaction at the block height (0 for the mempool) to the wallet.
+// It returns nil if the transaction does not touch the wallet.
+// A transaction seen before only has its height updated.
+func (w *Wallet) AddTransaction(tx *xcore.Transaction, height int64) (*Activity, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	txID := tx.ID()
+	if old, ok := w.txs[txID]; ok {
+		if height != old {
+			w.txs[txID] = height
+			for _, utxo := range w.utxos {
+				if utxo.TxID == txID {
+					utxo.Height = height
+				}
+			}
+			for _, spent := range w.spends {
+				for _, utxo := range spent {
+					if utxo.TxID == txID {
+						utxo.Height = height
+					}
+				}
+			}
+		}
+		return nil, nil
+	}
+
+	activity := &Activity{TxID: txID, Height: height}
+	for _, in := range tx.Inputs() {
+		outpoint := outpointKey(xbase.NewIDToString(in.Hash), in.Index)
+		if utxo, ok := w.utxos[outpoint]; ok {
+			delete(w.utxos, outpoint)
+			activity.Spent = append(activity.Spent, utxo)
+		}
+	}
+
+	// The outputs are rescanned while the gap window grows,
+	// a later output may pay to an address derived for an earlier one.
+	outputs := tx.Outputs()
+	for found := true; found; {
+		found = false
+		for vout, out := range outputs {
+			a, ok := w.scripts[hex.EncodeToString(out.Script)]
+			if !ok {
+				continue
+			}
+			outpoint := outpointKey(txID, uint32(vout))
+			if _, ok := w.utxos[outpoint]; ok {
+				continue
+			}
+			utxo := &UTXO{
+				TxID:    txID,
+				Vout:    uint32(vout),
+				Value:   out.Value,
+				Script:  hex.EncodeToString(out.Script),
+				Address: a.Address,
+				Chain:   a.Chain,
+				Index:   a.Index,
+				Height:  height,
+			}
+			w.utxos[outpoint] = utxo
+			activity.Received = append(activity.Received, utxo)
+			if err := w.markUsed(a); err != nil {
+				return nil, err
+			}
+			found = true
+		}
+	}
+
+	if len(activity.Received) == 0 && len(activity.Spent) == 0 {
+		return nil, nil
+	}
+	w.txs[txID] = height
+	if len(activity.Spent) > 0 {
+		w.spends[txID] = activity.Spent
+	}
+	return activity, nil
+}
+
+// RemoveTransaction -- rolls back the transaction replaced in or evicted from the mempool,
+// or dropped by a reorg: its outputs are removed and the outputs it spent are unspent again.
+// The wallet transactions spending its outputs are rolled back first.
+// It returns the rolled back activities, the descendants first, or nil if the transaction is unknown.
+// A transaction which a reorg moves back to the mempool is re-added at height 0 instead.
+func (w *Wallet) RemoveTransaction(txID string) []*Activity {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.removeTransaction(txID)
+}
+
+func (w *Wallet) removeTransaction(txID string) []*Activity {
+	height, ok := w.txs[txID]
+	if !ok {
+		return nil
+	}
+
+	var activities []*Activity
+	var children []string
+	for child, spent := range w.spends {
+		for _, utxo := range spent {
+			if utxo.TxID == txID {
+				children = append(children, child)
+				break
+			}
+		}
+	}
+	sort.Strings(children)
+	for _, child := range children {
+		activities = append(activities, w.removeTransaction(child)...)
+	}
+
+	activity := &Activity{TxID: txID, Height: height}
+	for outpoint, utxo := range w.utxos {
+		if utxo.TxID == txID {
+			delete(w.utxos, outpoint)
+			activity.Received = append(activity.Received, utxo)
+		}
+	}
+	sort.Slice(activity.Received, func(i, j int) bool {
+		return activity.Received[i].Vout < activity.Received[j].Vout
+	})
+	for _, utxo := range w.spends[txID] {
+		w.utxos[outpointKey(utxo.TxID, utxo.Vout)] = utxo
+		activity.Spent = append(activity.Spent, utxo)
+	}
+	delete(w.spends, txID)
+	delete(w.txs, txID)
+	return append(activities, activity)
+}
+
+// AddRawTransaction -- applies the serialized transaction, with or without witness.
+func (w *Wallet) AddRawTransaction(data []byte, height int64) (*Activity, error) {
+	tx := xcore.NewTransaction()
+	decode := tx.DeserializeNoWitness
+	if len(data) > 5 && data[4] == 0x00 {
+		decode = tx.Deserialize
+	}
+	if err := decode(data); err != nil {
+		return nil, xerror.NewError(Errors, ER_WALLET_TRANSACTION_MALFORMED, err)
+	}
+	return w.AddTransaction(tx, height)
+}
+
+// AddMsgTx -- applies the transaction relayed by the p2p network, it is unconfirmed.
+func (w *Wallet) AddMsgTx(msg *xprotocol.MsgTx) (*Activity, error) {
+	return w.AddRawTransaction(msg.Data, 0)
+}
+
+// AddTransactionResult -- applies the transaction returned by the RPC at the block height.
+func (w *Wallet) AddTransactionResult(res *xrpc.TransactionResult, height int64) (*Activity, error) {
+	data, err := hex.DecodeString(res.Hex)
+	if err != nil {
+		return nil, xerror.NewError(Errors, ER_WALLET_TRANSACTION_MALFORMED, err)
+	}
+	return w.AddRawTransaction(data, height)
+}
+
+// AddBlock -- applies the transactions of the verbose block returned by the RPC.
+func (w *Wallet) AddBlock(block *xrpc.BlockResult) ([]*Activity, error) {
+	var activities []*Activity
+	for i := range block.Tx {
+		activity, err := w.AddTransactionResult(&block.Tx[i], block.Height)
+		if err != nil {
+			return nil, err
+		}
+		if activity != nil {
+			activities = append(activities, activity)
+		}
+	}
+	return activities, nil
+}
+
+// UTXOs -- the unspent outputs ordered by height, txid and vout.
+func (w *Wallet) UTXOs() []*UTXO {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	utxos := make([]*UTXO, 0, len(w.utxos))
+	for _, utxo := range w.utxos {
+		utxos = append(utxos, utxo)
+	}
+	sort.Slice(utxos, func(i, j int) bool {
+		if utxos[i].Height != utxos[j].Height {
+			return utxos[i].Height < utxos[j].Height
+		}
+		if utxos[i].TxID != utxos[j].TxID {
+			return utxos[i].TxID < utxos[j].TxID
+		}
+		return utxos[i].Vout < utxos[j].Vout
+	})
+	return utxos
+}
+
+// Balance -- the sum of the unspent outputs, confirmed and unconfirmed.
+func (w *Wallet) Balance() (confirmed uint64, unconfirmed uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, utxo := range w.utxos {
+		if utxo.Height > 0 {
+			confirmed += utxo.Value
+		} else {
+			unconfirmed += utxo.Value
+		}
+	}
+	return
+}
+
+// AddressBalance -- the sum of the unspent outputs paying to the address.
+func (w *Wallet) AddressBalance(addr string) uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var balance uint64
+	for _, utxo := range w.utxos {
+		if utxo.Address == addr {
+			balance += utxo.Value
+		}
+	}
+	return balance
+}
+
+// Balances -- the balance of every address holding unspent outputs.
+func (w *Wallet) Balances() map[string]uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	balances := make(map[string]uint64)
+	for _, utxo := range w.utxos {
+		balances[utxo.Address] += utxo.Value
+	}
+	return balances
+}
+
+func outpointKey(txID string, vout uint32) string {
+	return fmt.Sprintf("%s:%d", txID, vout)
+}
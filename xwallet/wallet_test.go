@@ -0,0 +1,284 @@
+// tokucore
+//
+// Copyright 2019 by KeyFuse Labs
+// BSD License
+
+package xwallet
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/keyfuse/tokucore/network"
+	"github.com/keyfuse/tokucore/xbase"
+	"github.com/keyfuse/tokucore/xcore"
+	"github.com/keyfuse/tokucore/xcore/bip32"
+	"github.com/keyfuse/tokucore/xcore/bip39"
+	"github.com/keyfuse/tokucore/xprotocol"
+	"github.com/keyfuse/tokucore/xrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockAccount(t *testing.T) *xcore.Account {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	master := bip32.NewHDKey(bip39.NewSeed(mnemonic, ""))
+	account, err := xcore.NewAccount(master, xcore.PurposeP2WPKH, 0, 0)
+	assert.Nil(t, err)
+	return account
+}
+
+// mockTx -- a legacy transaction spending the outpoints and paying the scripts.
+func mockTx(t *testing.T, spends []*UTXO, scripts [][]byte, values []uint64) *xcore.Transaction {
+	tx := xcore.NewTransaction()
+	for _, spend := range spends {
+		hash, err := xbase.NewIDFromString(spend.TxID)
+		assert.Nil(t, err)
+		tx.AddInput(&xcore.TxIn{Hash: hash, Index: spend.Vout, Sequence: 0xffffffff})
+	}
+	if len(spends) == 0 {
+		tx.AddInput(&xcore.TxIn{Hash: make([]byte, 32), Index: 0, Sequence: 0xffffffff})
+	}
+	for i, script := range scripts {
+		tx.AddOutput(xcore.NewTxOut(values[i], script))
+	}
+	return tx
+}
+
+func TestWallet(t *testing.T) {
+	net := network.MainNet
+	account := mockAccount(t)
+	other, err := xcore.DecodeAddress("1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA", net)
+	assert.Nil(t, err)
+	otherScript, err := other.LockingScript()
+	assert.Nil(t, err)
+
+	w, err := NewWallet(account.HDKey(), xcore.PurposeP2WPKH, net, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, "wpkh([73c5da0a/84'/0'/0']xpub6CatWdiZiodmUeTDp8LT5or8nmbKNcuyvz7WyksVFkKB4RHwCD3XyuvPEbvqAQY3rAPshWcMLoP2fMFMKHPJ4ZeZXYVUhLv1VMrjPC7PW6V/<0;1>/*)#hpg6d6w2", w.Descriptor())
+	assert.Equal(t, 5, len(w.Addresses(xcore.ChainReceive)))
+	assert.Equal(t, 5, len(w.Addresses(xcore.ChainChange)))
+	assert.Equal(t, "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", w.NextReceiveAddress().Address)
+	assert.Equal(t, "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el", w.NextChangeAddress().Address)
+
+	receive := func(index uint32) []byte {
+		addr, err := account.ReceiveAddress(index)
+		assert.Nil(t, err)
+		script, err := addr.LockingScript()
+		assert.Nil(t, err)
+		return script
+	}
+
+	// Irrelevant.
+	{
+		tx := mockTx(t, nil, [][]byte{otherScript}, []uint64{1000})
+		activity, err := w.AddTransaction(tx, 100)
+		assert.Nil(t, err)
+		assert.Nil(t, activity)
+	}
+
+	// Index 8 is beyond the gap window until index 4 is used.
+	tx1 := mockTx(t, nil, [][]byte{receive(8), otherScript, receive(4)}, []uint64{20000, 1000, 30000})
+	{
+		block := &xrpc.BlockResult{
+			Height: 100,
+			Tx:     []xrpc.TransactionResult{{Hex: hex.EncodeToString(tx1.Serialize())}},
+		}
+		activities, err := w.AddBlock(block)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(activities))
+		assert.Equal(t, tx1.ID(), activities[0].TxID)
+		assert.Equal(t, 2, len(activities[0].Received))
+		assert.Equal(t, 0, len(activities[0].Spent))
+		assert.Equal(t, 14, len(w.Addresses(xcore.ChainReceive)))
+		assert.Equal(t, uint32(9), w.NextReceiveAddress().Index)
+
+		confirmed, unconfirmed := w.Balance()
+		assert.Equal(t, uint64(50000), confirmed)
+		assert.Equal(t, uint64(0), unconfirmed)
+	}
+
+	// Spend the index 4 output with change, from the mempool.
+	change, err := account.ChangeAddress(0)
+	assert.Nil(t, err)
+	changeScript, err := change.LockingScript()
+	assert.Nil(t, err)
+	spend := &UTXO{TxID: tx1.ID(), Vout: 2}
+	tx2 := mockTx(t, []*UTXO{spend}, [][]byte{otherScript, changeScript}, []uint64{10000, 19000})
+	{
+		activity, err := w.AddMsgTx(xprotocol.NewMsgTx(tx2.Serialize()))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(activity.Received))
+		assert.Equal(t, 1, len(activity.Spent))
+		assert.Equal(t, uint64(30000), activity.Spent[0].Value)
+		assert.Equal(t, uint32(1), w.NextChangeAddress().Index)
+		assert.Equal(t, 6, len(w.Addresses(xcore.ChainChange)))
+
+		confirmed, unconfirmed := w.Balance()
+		assert.Equal(t, uint64(20000), confirmed)
+		assert.Equal(t, uint64(19000), unconfirmed)
+	}
+
+	// Confirmed later.
+	{
+		activity, err := w.AddTransactionResult(&xrpc.TransactionResult{Hex: hex.EncodeToString(tx2.Serialize())}, 101)
+		assert.Nil(t, err)
+		assert.Nil(t, activity)
+
+		confirmed, unconfirmed := w.Balance()
+		assert.Equal(t, uint64(39000), confirmed)
+		assert.Equal(t, uint64(0), unconfirmed)
+	}
+
+	// Balances.
+	{
+		addr8, err := account.ReceiveAddress(8)
+		assert.Nil(t, err)
+		addr4, err := account.ReceiveAddress(4)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(20000), w.AddressBalance(addr8.ToString(net)))
+		assert.Equal(t, uint64(0), w.AddressBalance(addr4.ToString(net)))
+		assert.Equal(t, map[string]uint64{
+			addr8.ToString(net):  20000,
+			change.ToString(net): 19000,
+		}, w.Balances())
+
+		utxos := w.UTXOs()
+		assert.Equal(t, 2, len(utxos))
+		assert.Equal(t, tx1.ID(), utxos[0].TxID)
+		assert.Equal(t, uint32(0), utxos[0].Vout)
+		assert.Equal(t, int64(101), utxos[1].Height)
+		assert.NotNil(t, utxos[0].ToCoin())
+	}
+
+	// Save and reload.
+	{
+		data, err := w.Save()
+		assert.Nil(t, err)
+		loaded, err := LoadWallet(data, net)
+		assert.Nil(t, err)
+		assert.Equal(t, w.Descriptor(), loaded.Descriptor())
+		assert.Equal(t, w.GapLimit(), loaded.GapLimit())
+		assert.Equal(t, w.Addresses(xcore.ChainReceive), loaded.Addresses(xcore.ChainReceive))
+		assert.Equal(t, w.Addresses(xcore.ChainChange), loaded.Addresses(xcore.ChainChange))
+		assert.Equal(t, w.UTXOs(), loaded.UTXOs())
+
+		// Already known.
+		activity, err := loaded.AddTransaction(tx2, 101)
+		assert.Nil(t, err)
+		assert.Nil(t, activity)
+
+		// The spent outputs are kept for the rollback.
+		activities := loaded.RemoveTransaction(tx2.ID())
+		assert.Equal(t, 1, len(activities))
+		assert.Equal(t, 1, len(activities[0].Spent))
+		confirmed, unconfirmed := loaded.Balance()
+		assert.Equal(t, uint64(50000), confirmed)
+		assert.Equal(t, uint64(0), unconfirmed)
+	}
+
+	// Roll back tx2 replaced in the mempool, with its descendant.
+	{
+		changeSpend := &UTXO{TxID: tx2.ID(), Vout: 1}
+		tx3 := mockTx(t, []*UTXO{changeSpend}, [][]byte{otherScript}, []uint64{18000})
+		activity, err := w.AddTransaction(tx3, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(activity.Spent))
+
+		activities := w.RemoveTransaction(tx2.ID())
+		assert.Equal(t, 2, len(activities))
+		assert.Equal(t, tx3.ID(), activities[0].TxID)
+		assert.Equal(t, 0, len(activities[0].Received))
+		assert.Equal(t, 1, len(activities[0].Spent))
+		assert.Equal(t, tx2.ID(), activities[1].TxID)
+		assert.Equal(t, int64(101), activities[1].Height)
+		assert.Equal(t, 1, len(activities[1].Received))
+		assert.Equal(t, 1, len(activities[1].Spent))
+		assert.Equal(t, uint64(30000), activities[1].Spent[0].Value)
+
+		confirmed, unconfirmed := w.Balance()
+		assert.Equal(t, uint64(50000), confirmed)
+		assert.Equal(t, uint64(0), unconfirmed)
+		utxos := w.UTXOs()
+		assert.Equal(t, 2, len(utxos))
+		assert.Equal(t, uint32(2), utxos[1].Vout)
+
+		// Unknown.
+		assert.Nil(t, w.RemoveTransaction(tx2.ID()))
+		assert.Nil(t, w.RemoveTransaction(tx3.ID()))
+
+		// The replacement is seen again.
+		activity, err = w.AddTransaction(tx2, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(activity.Spent))
+	}
+}
+
+func TestWalletFromDescriptor(t *testing.T) {
+	net := network.MainNet
+	desc := "wpkh([73c5da0a/84'/0'/0']xpub6CatWdiZiodmUeTDp8LT5or8nmbKNcuyvz7WyksVFkKB4RHwCD3XyuvPEbvqAQY3rAPshWcMLoP2fMFMKHPJ4ZeZXYVUhLv1VMrjPC7PW6V/<0;1>/*)#hpg6d6w2"
+
+	w, err := NewWalletFromDescriptor(desc, net, DefaultGapLimit)
+	assert.Nil(t, err)
+	assert.Equal(t, desc, w.Descriptor())
+	assert.Equal(t, DefaultGapLimit, len(w.Addresses(xcore.ChainReceive)))
+	assert.Equal(t, "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", w.NextReceiveAddress().Address)
+}
+
+func TestWalletError(t *testing.T) {
+	net := network.MainNet
+	account := mockAccount(t)
+
+	{
+		_, err := NewWallet(account.HDKey(), xcore.PurposeP2WPKH, net, 0)
+		assert.Equal(t, "wallet.gap.limit[0].invalid (errno 7201) (state TWL01)", err.Error())
+	}
+
+	{
+		w, err := NewWallet(account.HDKey(), xcore.PurposeP2WPKH, net, 5)
+		assert.Nil(t, err)
+		_, err = w.AddRawTransaction([]byte{0x01, 0x00}, 0)
+		assert.NotNil(t, err)
+		_, err = w.AddTransactionResult(&xrpc.TransactionResult{Hex: "zz"}, 0)
+		assert.NotNil(t, err)
+
+		// Unknown chain.
+		assert.Nil(t, w.Addresses(2))
+		assert.Nil(t, w.nextAddress(2))
+	}
+
+	{
+		_, err := LoadWallet([]byte("{"), net)
+		assert.NotNil(t, err)
+		_, err = LoadWallet([]byte(`{"version":2}`), net)
+		assert.Equal(t, "wallet.snapshot.version[2].unsupported (errno 7302) (state TWL02)", err.Error())
+	}
+
+	// The used indexes beyond the gap or hardened are rejected before deriving.
+	{
+		w, err := NewWallet(account.HDKey(), xcore.PurposeP2WPKH, net, 5)
+		assert.Nil(t, err)
+		data, err := w.Save()
+		assert.Nil(t, err)
+		tests := []struct {
+			used string
+			err  string
+		}{
+			{`[[4,9],[]]`, ""},
+			{`[[4,10],[]]`, "wallet.snapshot.chain[0].used.index[10].invalid (errno 7303) (state TWL02)"},
+			{`[[],[5]]`, "wallet.snapshot.chain[1].used.index[5].invalid (errno 7303) (state TWL02)"},
+			{`[[2147483647],[]]`, "wallet.snapshot.chain[0].used.index[2147483647].invalid (errno 7303) (state TWL02)"},
+			{`[[2147483648],[]]`, "wallet.snapshot.chain[0].used.index[2147483648].invalid (errno 7303) (state TWL02)"},
+		}
+		for _, test := range tests {
+			snap := strings.Replace(string(data), `"used":[[],[]]`, `"used":`+test.used, 1)
+			loaded, err := LoadWallet([]byte(snap), net)
+			if test.err == "" {
+				assert.Nil(t, err, test.used)
+				assert.Equal(t, 15, len(loaded.Addresses(xcore.ChainReceive)), test.used)
+			} else {
+				assert.EqualError(t, err, test.err, test.used)
+			}
+		}
+	}
+}